@@ -0,0 +1,19 @@
+CREATE TYPE "public"."pool_strategy" AS ENUM('round_robin', 'sticky', 'least_loaded');--> statement-breakpoint
+CREATE TABLE "pool_members" (
+	"id" serial PRIMARY KEY NOT NULL,
+	"pool_id" integer NOT NULL,
+	"instance_id" integer NOT NULL,
+	CONSTRAINT "pool_instance_idx" UNIQUE("pool_id","instance_id")
+);
+--> statement-breakpoint
+CREATE TABLE "pools" (
+	"id" serial PRIMARY KEY NOT NULL,
+	"user_id" integer NOT NULL,
+	"name" varchar(256) NOT NULL,
+	"strategy" "pool_strategy" DEFAULT 'round_robin' NOT NULL,
+	"created_at" timestamp DEFAULT now() NOT NULL
+);
+--> statement-breakpoint
+ALTER TABLE "pool_members" ADD CONSTRAINT "pool_members_pool_id_pools_id_fk" FOREIGN KEY ("pool_id") REFERENCES "public"."pools"("id") ON DELETE cascade ON UPDATE no action;--> statement-breakpoint
+ALTER TABLE "pool_members" ADD CONSTRAINT "pool_members_instance_id_instances_id_fk" FOREIGN KEY ("instance_id") REFERENCES "public"."instances"("id") ON DELETE cascade ON UPDATE no action;--> statement-breakpoint
+ALTER TABLE "pools" ADD CONSTRAINT "pools_user_id_users_id_fk" FOREIGN KEY ("user_id") REFERENCES "public"."users"("id") ON DELETE cascade ON UPDATE no action;
\ No newline at end of file
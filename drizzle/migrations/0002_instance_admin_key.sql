@@ -0,0 +1 @@
+ALTER TABLE "instances" ADD COLUMN "admin_api_key" varchar(64);
\ No newline at end of file
@@ -29,6 +29,7 @@ export const instances = pgTable('instances', {
     status: instanceStatusEnum('status').default('creating').notNull(),
     cpuLimit: varchar('cpu_limit', { length: 10 }).default('0.5'), // e.g., "0.5"
     memoryLimit: varchar('memory_limit', { length: 10 }).default('512m'), // e.g., "512m"
+    adminApiKey: varchar('admin_api_key', { length: 64 }), // ADMIN_API_KEY of the instance's container
     createdAt: timestamp('created_at').defaultNow().notNull(),
   }, (table) => {
     return {
@@ -53,8 +54,30 @@ export const instanceState = pgTable('instance_state', {
     };
 });
 
+export const poolStrategyEnum = pgEnum('pool_strategy', ['round_robin', 'sticky', 'least_loaded']);
+
+// A sending pool spreads sends over several instances of the same user.
+export const pools = pgTable('pools', {
+    id: serial('id').primaryKey(),
+    userId: integer('user_id').notNull().references(() => users.id, { onDelete: 'cascade' }),
+    name: varchar('name', { length: 256 }).notNull(),
+    strategy: poolStrategyEnum('strategy').default('round_robin').notNull(),
+    createdAt: timestamp('created_at').defaultNow().notNull(),
+});
+
+export const poolMembers = pgTable('pool_members', {
+    id: serial('id').primaryKey(),
+    poolId: integer('pool_id').notNull().references(() => pools.id, { onDelete: 'cascade' }),
+    instanceId: integer('instance_id').notNull().references(() => instances.id, { onDelete: 'cascade' }),
+}, (table) => {
+    return {
+        poolInstanceIdx: unique('pool_instance_idx').on(table.poolId, table.instanceId),
+    };
+});
+
 export const userRelations = relations(users, ({ many }) => ({
   instances: many(instances),
+  pools: many(pools),
 }));
 
 export const instanceRelations = relations(instances, ({ one, many }) => ({
@@ -67,6 +90,7 @@ export const instanceRelations = relations(instances, ({ one, many }) => ({
     references: [nodes.id],
   }),
   state: many(instanceState),
+  pools: many(poolMembers),
 }));
 
 export const instanceStateRelations = relations(instanceState, ({ one }) => ({
@@ -78,4 +102,23 @@ export const instanceStateRelations = relations(instanceState, ({ one }) => ({
 
 export const nodeRelations = relations(nodes, ({ many }) => ({
     instances: many(instances),
+}));
+
+export const poolRelations = relations(pools, ({ one, many }) => ({
+    user: one(users, {
+        fields: [pools.userId],
+        references: [users.id],
+    }),
+    members: many(poolMembers),
+}));
+
+export const poolMemberRelations = relations(poolMembers, ({ one }) => ({
+    pool: one(pools, {
+        fields: [poolMembers.poolId],
+        references: [pools.id],
+    }),
+    instance: one(instances, {
+        fields: [poolMembers.instanceId],
+        references: [instances.id],
+    }),
 }));
\ No newline at end of file
@@ -1,7 +1,9 @@
+import { randomBytes } from 'node:crypto';
 import { Elysia, t } from 'elysia';
-import { eq, and, not } from 'drizzle-orm';
+import { eq, and, not, inArray } from 'drizzle-orm';
 import * as schema from '../../drizzle/schema';
 import { createAndStartContainer, stopAndRemoveContainer, type WorkerNode } from './docker.service';
+import { routeOrder, beginSend, endSend } from './pool.service';
 
 function getInstanceProxyUrl(instance: { id: number }, node: { publicHost: string }, subPath: string): string {
   // In test environment, we connect directly to the mapped port on localhost.
@@ -14,6 +16,17 @@ function getInstanceProxyUrl(instance: { id: number }, node: { publicHost: strin
   return `http://${node.publicHost}/instances/${instance.id}${subPath}`;
 }
 
+// Each container gets its own ADMIN_API_KEY. The gateway keeps it to
+// authenticate the calls it proxies; the provider refuses admin calls
+// without one.
+function generateAdminApiKey(): string {
+  return randomBytes(32).toString('hex');
+}
+
+function instanceHeaders(instance: { adminApiKey: string | null }, headers: Record<string, string> = {}): Record<string, string> {
+  return instance.adminApiKey ? { ...headers, 'X-Admin-Key': instance.adminApiKey } : headers;
+}
+
 /**
  * Creates the Elysia app instance with all routes configured.
  * Database connection is injected to avoid circular dependencies in tests.
@@ -30,6 +43,20 @@ export function createApp(db: any) {
     }
   }
 
+  // Loads a user's pool with its member instance IDs.
+  async function loadPool(poolId: number, userId: number) {
+    const [pool] = await db.select().from(schema.pools).where(and(eq(schema.pools.id, poolId), eq(schema.pools.userId, userId)));
+    if (!pool) return null;
+    const members = await db.select({ instanceId: schema.poolMembers.instanceId }).from(schema.poolMembers).where(eq(schema.poolMembers.poolId, poolId));
+    return { ...pool, instanceIds: members.map((m: { instanceId: number }) => m.instanceId) };
+  }
+
+  const strategySchema = t.Union([
+    t.Literal('round_robin'),
+    t.Literal('sticky'),
+    t.Literal('least_loaded'),
+  ]);
+
   return new Elysia()
     .get('/', () => ({ status: 'ok' }))
     .group('/api', (app) => app
@@ -63,6 +90,7 @@ export function createApp(db: any) {
           return { error: 'No available worker nodes to schedule instance.' };
         }
 
+        const adminApiKey = generateAdminApiKey();
         // user is guaranteed to be non-null by the onBeforeHandle guard.
         const [newInstance] = await db.insert(schema.instances).values({
           nodeId: node.id,
@@ -73,6 +101,7 @@ export function createApp(db: any) {
           webhookUrl: body.webhook,
           cpuLimit: body.resources?.cpu,
           memoryLimit: body.resources?.memory,
+          adminApiKey,
           status: 'creating',
         }).returning();
 
@@ -90,6 +119,7 @@ export function createApp(db: any) {
             cpuLimit: newInstance.cpuLimit || '0.5',
             memoryLimit: newInstance.memoryLimit || '512m',
             provider: newInstance.provider,
+            adminApiKey,
           });
           const [updatedInstance] = await db.update(schema.instances)
             .set({ status: 'running' })
@@ -137,7 +167,7 @@ export function createApp(db: any) {
         }
 
         const instanceUrl = getInstanceProxyUrl(instanceData.instances, instanceData.nodes, '/qr');
-        const qrResponse = await proxyToInstance(instanceUrl);
+        const qrResponse = await proxyToInstance(instanceUrl, { headers: instanceHeaders(instanceData.instances) });
         if (!qrResponse) {
           set.status = 503;
           return { error: "Failed to connect to instance container." };
@@ -169,7 +199,7 @@ export function createApp(db: any) {
 
         const sendResponse = await proxyToInstance(instanceUrl, {
           method: 'POST',
-          headers: { 'Content-Type': 'application/json' },
+          headers: instanceHeaders(instanceData.instances, { 'Content-Type': 'application/json' }),
           body: JSON.stringify(body)
         });
 
@@ -231,6 +261,8 @@ export function createApp(db: any) {
 
         const instance = instanceData.instances;
         const currentNode = instanceData.nodes;
+        // Instances created before per-instance keys get one on migration.
+        const adminApiKey = instance.adminApiKey || generateAdminApiKey();
 
         // Find a new node to migrate to
         const [newNode] = await db.select().from(schema.nodes).where(not(eq(schema.nodes.id, currentNode.id))).limit(1);
@@ -258,6 +290,7 @@ export function createApp(db: any) {
             cpuLimit: instance.cpuLimit || '0.5',
             memoryLimit: instance.memoryLimit || '512m',
             provider: instance.provider,
+            adminApiKey,
           });
           console.log(`New container for instance ${instanceId} started on node ${newNode.name}.`);
 
@@ -265,6 +298,7 @@ export function createApp(db: any) {
           const [updatedInstance] = await db.update(schema.instances).set({
             status: 'running',
             nodeId: newNode.id,
+            adminApiKey,
           })
             .where(eq(schema.instances.id, instanceId))
             .returning();
@@ -282,6 +316,112 @@ export function createApp(db: any) {
           target_node: t.Optional(t.String()),
         })
       })
+      .post('/pools', async ({ body, set, user }) => {
+        const instanceIds = [...new Set(body.instance_ids)];
+        const owned = await db.select({ id: schema.instances.id }).from(schema.instances)
+          .where(and(inArray(schema.instances.id, instanceIds), eq(schema.instances.userId, user!.id)));
+        if (owned.length !== instanceIds.length) {
+          set.status = 404;
+          return { error: 'Instance not found' };
+        }
+
+        const [pool] = await db.insert(schema.pools).values({
+          userId: user!.id,
+          name: body.name,
+          strategy: body.strategy,
+        }).returning();
+        if (!pool) {
+          set.status = 500;
+          return { error: 'Failed to create pool in database' };
+        }
+        await db.insert(schema.poolMembers).values(instanceIds.map((instanceId) => ({ poolId: pool.id, instanceId })));
+        return { ...pool, instanceIds };
+      }, {
+        body: t.Object({
+          name: t.String(),
+          strategy: t.Optional(strategySchema),
+          instance_ids: t.Array(t.Number(), { minItems: 1 }),
+        })
+      })
+      .get('/pools', async ({ user }) => {
+        const pools = await db.select().from(schema.pools).where(eq(schema.pools.userId, user!.id));
+        return Promise.all(pools.map((pool: { id: number }) => loadPool(pool.id, user!.id)));
+      })
+      .get('/pools/:id', async ({ params, set, user }) => {
+        const pool = await loadPool(params.id, user!.id);
+        if (!pool) {
+          set.status = 404;
+          return { error: 'Pool not found' };
+        }
+        return pool;
+      }, {
+        params: t.Object({ id: t.Numeric() })
+      })
+      .delete('/pools/:id', async ({ params, set, user }) => {
+        const result = await db.delete(schema.pools)
+          .where(and(eq(schema.pools.id, params.id), eq(schema.pools.userId, user!.id)))
+          .returning();
+        if (result.length === 0) {
+          set.status = 404;
+          return { error: 'Pool not found' };
+        }
+        set.status = 204;
+      }, {
+        params: t.Object({ id: t.Numeric() })
+      })
+      .post('/pools/:id/send', async ({ params, body, set, user }) => {
+        const pool = await loadPool(params.id, user!.id);
+        if (!pool) {
+          set.status = 404;
+          return { error: 'Pool not found' };
+        }
+        const members = pool.instanceIds.length === 0 ? [] : await db.select().from(schema.instances)
+          .where(and(inArray(schema.instances.id, pool.instanceIds), eq(schema.instances.status, 'running')))
+          .innerJoin(schema.nodes, eq(schema.instances.nodeId, schema.nodes.id));
+        const byId = new Map<number, any>(members.map((m: any) => [m.instances.id, m]));
+
+        // Try the instances in the strategy's order. One that can't take the
+        // send (unreachable, or shedding load while disconnected or
+        // backlogged) passes it on to the next; any other answer is final.
+        for (const instanceId of routeOrder(pool.id, pool.strategy, [...byId.keys()], body.to)) {
+          const member = byId.get(instanceId);
+          const instanceUrl = getInstanceProxyUrl(member.instances, member.nodes, '/send');
+          beginSend(instanceId);
+          let sendResponse: Response | null;
+          try {
+            sendResponse = await proxyToInstance(instanceUrl, {
+              method: 'POST',
+              headers: instanceHeaders(member.instances, { 'Content-Type': 'application/json' }),
+              body: JSON.stringify(body)
+            });
+          } finally {
+            endSend(instanceId);
+          }
+          if (!sendResponse || sendResponse.status === 503) {
+            continue;
+          }
+
+          set.status = sendResponse.status;
+          if (!sendResponse.ok) {
+            const errorText = await sendResponse.text();
+            try {
+              return { ...JSON.parse(errorText), instance_id: instanceId };
+            } catch (e) {
+              return { error: errorText.trim(), instance_id: instanceId };
+            }
+          }
+          return { ...(await sendResponse.json()), instance_id: instanceId };
+        }
+
+        set.status = 503;
+        return { error: 'No instance in the pool can send right now.' };
+      }, {
+        params: t.Object({ id: t.Numeric() }),
+        body: t.Object({
+          to: t.String(),
+          text: t.String(),
+        })
+      })
     )
     // New internal API group for state management
     .group('/internal', (app) => app
@@ -27,6 +27,7 @@ interface CreateContainerOptions {
     cpuLimit: string;
     memoryLimit: string;
     provider: string;
+    adminApiKey: string;
 }
 
 export function sanitizeForContainerName(name: string): string {
@@ -62,6 +63,7 @@ export async function createAndStartContainer(options: CreateContainerOptions) {
             `GATEWAY_URL=${gatewayUrl}`,
             `INTERNAL_API_SECRET=${internalApiSecret}`,
             `WEBHOOK_URL=${options.webhookUrl}`,
+            `ADMIN_API_KEY=${options.adminApiKey}`,
             `PORT=8080`,
             `GOMAXPROCS=1`
         ],
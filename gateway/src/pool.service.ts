@@ -0,0 +1,64 @@
+import { createHash } from 'node:crypto';
+
+export type PoolStrategy = 'round_robin' | 'sticky' | 'least_loaded';
+
+// Per-pool round-robin position and per-instance sends in flight. Both live in
+// this process only; a restart simply starts the rotation over.
+const roundRobinCursors = new Map<number, number>();
+const inFlightSends = new Map<number, number>();
+
+export function beginSend(instanceId: number) {
+    inFlightSends.set(instanceId, (inFlightSends.get(instanceId) || 0) + 1);
+}
+
+export function endSend(instanceId: number) {
+    const count = (inFlightSends.get(instanceId) || 0) - 1;
+    if (count > 0) {
+        inFlightSends.set(instanceId, count);
+    } else {
+        inFlightSends.delete(instanceId);
+    }
+}
+
+export function sendsInFlight(instanceId: number): number {
+    return inFlightSends.get(instanceId) || 0;
+}
+
+// Phone numbers are compared by their digits, so "+1 555-0100" and
+// "15550100" stick to the same instance.
+export function normalizeRecipient(recipient: string): string {
+    return recipient.includes('@') ? recipient.trim().toLowerCase() : recipient.replace(/\D/g, '');
+}
+
+function stickyScore(recipient: string, instanceId: number): number {
+    return createHash('sha256').update(`${recipient}:${instanceId}`).digest().readUInt32BE(0);
+}
+
+/**
+ * Orders a pool's instances for a send, best first. The caller tries them in
+ * order, moving on when an instance can't take the send.
+ *
+ * - round_robin rotates the first choice through the instances.
+ * - sticky keeps each recipient on the same instance (rendezvous hashing, so
+ *   adding or removing an instance only moves the recipients it gains or had).
+ * - least_loaded prefers the instance with the fewest sends in flight.
+ */
+export function routeOrder(poolId: number, strategy: PoolStrategy, instanceIds: number[], recipient: string): number[] {
+    const ids = [...instanceIds].sort((a, b) => a - b);
+    if (ids.length === 0) return ids;
+    switch (strategy) {
+        case 'sticky': {
+            const key = normalizeRecipient(recipient);
+            return ids.sort((a, b) => stickyScore(key, b) - stickyScore(key, a) || a - b);
+        }
+        case 'least_loaded':
+            return ids.sort((a, b) => sendsInFlight(a) - sendsInFlight(b) || a - b);
+        case 'round_robin':
+        default: {
+            const cursor = roundRobinCursors.get(poolId) || 0;
+            roundRobinCursors.set(poolId, cursor + 1);
+            const start = cursor % ids.length;
+            return [...ids.slice(start), ...ids.slice(0, start)];
+        }
+    }
+}
@@ -0,0 +1,57 @@
+import { describe, test, expect } from 'bun:test';
+import { routeOrder, normalizeRecipient, beginSend, endSend, sendsInFlight } from '../../src/pool.service';
+
+describe('Pool Routing', () => {
+    describe('round_robin', () => {
+        test('should rotate the first choice through the instances', () => {
+            const firsts = [0, 1, 2, 3].map(() => routeOrder(1, 'round_robin', [3, 1, 2], '123')[0]);
+            expect(firsts).toEqual([1, 2, 3, 1]);
+        });
+
+        test('should keep every instance as a fallback', () => {
+            expect(routeOrder(2, 'round_robin', [1, 2, 3], '123').sort()).toEqual([1, 2, 3]);
+        });
+    });
+
+    describe('sticky', () => {
+        test('should send a recipient to the same instance every time', () => {
+            const first = routeOrder(3, 'sticky', [1, 2, 3], '15550100')[0];
+            for (let i = 0; i < 5; i++) {
+                expect(routeOrder(3, 'sticky', [1, 2, 3], '15550100')[0]).toBe(first);
+            }
+        });
+
+        test('should only move the recipients of a removed instance', () => {
+            const recipients = Array.from({ length: 50 }, (_, i) => `1555${i}`);
+            for (const recipient of recipients) {
+                const before = routeOrder(4, 'sticky', [1, 2, 3], recipient)[0];
+                const after = routeOrder(4, 'sticky', [1, 2], recipient)[0];
+                if (before !== 3) {
+                    expect(after).toBe(before);
+                }
+            }
+        });
+
+        test('should treat formatting variants of a number alike', () => {
+            expect(normalizeRecipient('+1 555-0100')).toBe('15550100');
+            expect(routeOrder(5, 'sticky', [1, 2, 3], '+1 555-0100')).toEqual(routeOrder(5, 'sticky', [1, 2, 3], '15550100'));
+        });
+    });
+
+    describe('least_loaded', () => {
+        test('should prefer the instance with the fewest sends in flight', () => {
+            beginSend(101);
+            beginSend(101);
+            beginSend(102);
+            expect(routeOrder(6, 'least_loaded', [101, 102, 103], '123')).toEqual([103, 102, 101]);
+            endSend(101);
+            endSend(101);
+            endSend(102);
+            expect(sendsInFlight(101)).toBe(0);
+        });
+    });
+
+    test('should return nothing for an empty pool', () => {
+        expect(routeOrder(7, 'round_robin', [], '123')).toEqual([]);
+    });
+});
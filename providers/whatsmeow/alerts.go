@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Operational alerts go to an operator channel (Slack, PagerDuty, email),
+// never to the tenant's data webhook. Each rule notifies once when it starts
+// firing and once when it resolves.
+
+var (
+	alertDisconnectedAfter  = envDuration("ALERT_DISCONNECTED_AFTER", 5*time.Minute)
+	alertWebhookFailureRate = envInt("ALERT_WEBHOOK_FAILURE_RATE", 50) // percent, 0 disables
+	alertWebhookWindow      = envDuration("ALERT_WEBHOOK_WINDOW", 10*time.Minute)
+	alertWebhookMinSamples  = envInt("ALERT_WEBHOOK_MIN_SAMPLES", 10)
+	alertQueueDepth         = envInt("ALERT_QUEUE_DEPTH", 1000) // 0 disables
+
+	alertSlackWebhookURL = os.Getenv("ALERT_SLACK_WEBHOOK_URL")
+	alertPagerDutyKey    = os.Getenv("ALERT_PAGERDUTY_ROUTING_KEY")
+	alertEmailTo         = os.Getenv("ALERT_EMAIL_TO")
+	alertSMTPAddr        = os.Getenv("ALERT_SMTP_ADDR") // host:port
+	alertSMTPFrom        = os.Getenv("ALERT_SMTP_FROM")
+	alertSMTPUser        = os.Getenv("ALERT_SMTP_USER")
+	alertSMTPPassword    = os.Getenv("ALERT_SMTP_PASSWORD")
+)
+
+// --- Health signals ---
+
+var (
+	connStateMu       sync.Mutex
+	disconnectedSince = time.Now() // not connected until the first Connected event
+)
+
+func markConnected() {
+	connStateMu.Lock()
+	disconnectedSince = time.Time{}
+	connStateMu.Unlock()
+}
+
+func markDisconnected() {
+	connStateMu.Lock()
+	if disconnectedSince.IsZero() {
+		disconnectedSince = time.Now()
+	}
+	connStateMu.Unlock()
+}
+
+func disconnectedFor() time.Duration {
+	connStateMu.Lock()
+	defer connStateMu.Unlock()
+	if disconnectedSince.IsZero() {
+		return 0
+	}
+	return time.Since(disconnectedSince)
+}
+
+type webhookOutcome struct {
+	at time.Time
+	ok bool
+}
+
+var (
+	webhookOutcomesMu sync.Mutex
+	webhookOutcomes   []webhookOutcome
+)
+
+func recordWebhookOutcome(ok bool) {
+	webhookOutcomesMu.Lock()
+	defer webhookOutcomesMu.Unlock()
+	now := time.Now()
+	webhookOutcomes = append(webhookOutcomes, webhookOutcome{at: now, ok: ok})
+	cutoff := now.Add(-alertWebhookWindow)
+	i := 0
+	for i < len(webhookOutcomes) && webhookOutcomes[i].at.Before(cutoff) {
+		i++
+	}
+	webhookOutcomes = webhookOutcomes[i:]
+}
+
+// webhookFailureRate returns the failure percentage over the alert window.
+func webhookFailureRate() (rate float64, samples int) {
+	webhookOutcomesMu.Lock()
+	defer webhookOutcomesMu.Unlock()
+	cutoff := time.Now().Add(-alertWebhookWindow)
+	failed := 0
+	for _, o := range webhookOutcomes {
+		if o.at.Before(cutoff) {
+			continue
+		}
+		samples++
+		if !o.ok {
+			failed++
+		}
+	}
+	if samples == 0 {
+		return 0, 0
+	}
+	return float64(failed) * 100 / float64(samples), samples
+}
+
+// pendingSends counts outbound messages currently being handed to WhatsApp.
+// Together with the persisted queue it's the outbound queue depth.
+var pendingSends atomic.Int64
+
+func outboundQueueDepth() int64 {
+	return pendingSends.Load() + queuedOutboundCount()
+}
+
+// --- Rules ---
+
+type alertRule struct {
+	Name    string
+	check   func() (firing bool, detail string)
+	firing  bool
+	since   time.Time
+	details string
+}
+
+type alertStatus struct {
+	Rule   string     `json:"rule"`
+	Firing bool       `json:"firing"`
+	Since  *time.Time `json:"since,omitempty"`
+	Detail string     `json:"detail,omitempty"`
+}
+
+var (
+	alertRulesMu sync.Mutex
+	alertRules   = []*alertRule{
+		{Name: "session_disconnected", check: func() (bool, string) {
+			d := disconnectedFor()
+			return alertDisconnectedAfter > 0 && d > alertDisconnectedAfter && !sessionArchived(),
+				fmt.Sprintf("WhatsApp session disconnected for %s", d.Round(time.Second))
+		}},
+		{Name: "webhook_failure_rate", check: func() (bool, string) {
+			rate, samples := webhookFailureRate()
+			return alertWebhookFailureRate > 0 && samples >= alertWebhookMinSamples && rate > float64(alertWebhookFailureRate),
+				fmt.Sprintf("Webhook failure rate %.1f%% over %d deliveries in the last %s", rate, samples, alertWebhookWindow)
+		}},
+		{Name: "queue_depth", check: func() (bool, string) {
+			depth := outboundQueueDepth()
+			return alertQueueDepth > 0 && depth > int64(alertQueueDepth),
+				fmt.Sprintf("Outbound queue depth %d exceeds %d", depth, alertQueueDepth)
+		}},
+	}
+)
+
+func alertChannelConfigured() bool {
+	return alertSlackWebhookURL != "" || alertPagerDutyKey != "" || (alertEmailTo != "" && alertSMTPAddr != "")
+}
+
+// runAlertEvaluator evaluates the rules periodically and notifies on every
+// state transition.
+func runAlertEvaluator() {
+	if !alertChannelConfigured() {
+		waLogger.Infof("Alerting disabled: no alert channel configured")
+		return
+	}
+	for range time.Tick(30 * time.Second) {
+		evaluateAlerts()
+	}
+}
+
+func evaluateAlerts() {
+	alertRulesMu.Lock()
+	var transitions []alertRule
+	for _, rule := range alertRules {
+		firing, detail := rule.check()
+		if firing == rule.firing {
+			continue
+		}
+		rule.firing = firing
+		rule.details = detail
+		if firing {
+			rule.since = time.Now()
+		} else {
+			rule.since = time.Time{}
+		}
+		transitions = append(transitions, *rule)
+	}
+	alertRulesMu.Unlock()
+	for _, t := range transitions {
+		notifyAlert(t.Name, t.firing, t.details)
+	}
+}
+
+func currentAlerts() []alertStatus {
+	alertRulesMu.Lock()
+	defer alertRulesMu.Unlock()
+	statuses := make([]alertStatus, 0, len(alertRules))
+	for _, rule := range alertRules {
+		s := alertStatus{Rule: rule.Name, Firing: rule.firing}
+		if rule.firing {
+			since := rule.since
+			s.Since = &since
+			s.Detail = rule.details
+		}
+		statuses = append(statuses, s)
+	}
+	return statuses
+}
+
+func getAlerts(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"channel_configured": alertChannelConfigured(),
+		"alerts":             currentAlerts(),
+	})
+}
+
+// --- Channels ---
+
+func notifyAlert(rule string, firing bool, detail string) {
+	instance := instanceID
+	if instance == "" {
+		instance, _ = os.Hostname()
+	}
+	state := "RESOLVED"
+	if firing {
+		state = "FIRING"
+	}
+	summary := fmt.Sprintf("[%s] %s on instance %s: %s", state, rule, instance, detail)
+	waLogger.Warnf("Alert %s", summary)
+
+	if alertSlackWebhookURL != "" {
+		if err := postAlertJSON(alertSlackWebhookURL, map[string]string{"text": summary}); err != nil {
+			waLogger.Errorf("Failed to send Slack alert: %v", err)
+		}
+	}
+	if alertPagerDutyKey != "" {
+		action := "resolve"
+		if firing {
+			action = "trigger"
+		}
+		event := map[string]interface{}{
+			"routing_key":  alertPagerDutyKey,
+			"event_action": action,
+			"dedup_key":    instance + "/" + rule,
+			"payload": map[string]string{
+				"summary":  summary,
+				"source":   instance,
+				"severity": "error",
+			},
+		}
+		if err := postAlertJSON("https://events.pagerduty.com/v2/enqueue", event); err != nil {
+			waLogger.Errorf("Failed to send PagerDuty alert: %v", err)
+		}
+	}
+	if alertEmailTo != "" && alertSMTPAddr != "" {
+		if err := sendAlertEmail(summary); err != nil {
+			waLogger.Errorf("Failed to send alert email: %v", err)
+		}
+	}
+}
+
+func postAlertJSON(url string, body interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+	resp, err := httpClient.Post(url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("status %s", resp.Status)
+	}
+	return nil
+}
+
+func sendAlertEmail(summary string) error {
+	from := alertSMTPFrom
+	if from == "" {
+		from = alertSMTPUser
+	}
+	recipients := strings.Split(alertEmailTo, ",")
+	for i := range recipients {
+		recipients[i] = strings.TrimSpace(recipients[i])
+	}
+	msg := "From: " + from + "\r\n" +
+		"To: " + strings.Join(recipients, ", ") + "\r\n" +
+		"Subject: " + summary + "\r\n" +
+		"\r\n" + summary + "\r\n"
+	var auth smtp.Auth
+	if alertSMTPUser != "" {
+		host, _, _ := strings.Cut(alertSMTPAddr, ":")
+		auth = smtp.PlainAuth("", alertSMTPUser, alertSMTPPassword, host)
+	}
+	return smtp.SendMail(alertSMTPAddr, auth, from, recipients, []byte(msg))
+}
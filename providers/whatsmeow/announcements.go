@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"google.golang.org/protobuf/proto"
+)
+
+// Announcements are schedules that send to several groups. Each group is
+// sent to separately and reported in the run's results; announce-only groups
+// are skipped unless the account is one of their admins.
+
+type announcementResult struct {
+	Group     string          `json:"group"`
+	MessageID types.MessageID `json:"message_id,omitempty"`
+	Error     string          `json:"error,omitempty"`
+}
+
+// parseAnnouncementTargets validates the groups and tag of an announcement
+// against the caller's send policy. Groups behind a tag are only known at run
+// time, so tags need a key that may send to any group.
+func parseAnnouncementTargets(ctx context.Context, groups []string, tag string) ([]string, error) {
+	parsed := []string{}
+	for _, g := range groups {
+		jid, ok := parseJID(g)
+		if !ok || jid.Server != types.GroupServer {
+			return nil, fmt.Errorf("invalid group JID %q", g)
+		}
+		if err := checkSendPolicy(ctx, jid); err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, jid.ToNonAD().String())
+	}
+	if tag != "" {
+		if !tagRe.MatchString(normalizeTag(tag)) {
+			return nil, fmt.Errorf("invalid tag %q", tag)
+		}
+		if key := apiKeyFromContext(ctx); key != nil && (!key.Policy.AllowGroups || len(key.Policy.GroupAllowlist) > 0) {
+			return nil, fmt.Errorf("%w: announcing to a tag needs a key that may send to any group", errDestinationNotAllowed)
+		}
+	}
+	return parsed, nil
+}
+
+// announcementGroups returns the schedule's groups plus the groups currently
+// carrying its tag, without duplicates.
+func announcementGroups(ctx context.Context, s schedule) ([]types.JID, error) {
+	seen := map[types.JID]bool{}
+	var groups []types.JID
+	add := func(g string) {
+		if jid, ok := parseJID(g); ok && jid.Server == types.GroupServer && !seen[jid] {
+			seen[jid] = true
+			groups = append(groups, jid)
+		}
+	}
+	for _, g := range s.Groups {
+		add(g)
+	}
+	if s.Tag != "" {
+		rows, err := gatewayDB.QueryContext(ctx, `SELECT chat_jid FROM chat_tags WHERE tag = ? ORDER BY chat_jid`, s.Tag)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var jid string
+			if err := rows.Scan(&jid); err != nil {
+				return nil, err
+			}
+			add(jid)
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return groups, nil
+}
+
+// announceToGroup sends one announcement, refusing announce-only groups the
+// account can't post in.
+func announceToGroup(ctx context.Context, group types.JID, text string) (sendResult, error) {
+	info, err := cachedGroupInfoFor(ctx, group)
+	if err != nil {
+		return sendResult{}, fmt.Errorf("failed to get group info: %w", err)
+	}
+	if info.IsAnnounce && !isGroupAdmin(info, ownUsers()...) {
+		return sendResult{}, fmt.Errorf("group is announce-only and the account is not an admin")
+	}
+	msg := &waE2E.Message{Conversation: proto.String(text)}
+	return sendOrQueue(ctx, group, msg, sendOptions{AllowDuplicate: true})
+}
+
+// runAnnouncement sends a claimed occurrence of an announcement schedule.
+// The run counts as successful when at least one group received it.
+func runAnnouncement(ctx context.Context, s schedule, occurrence, now time.Time) {
+	groups, err := announcementGroups(ctx, s)
+	if err != nil {
+		waLogger.Errorf("Failed to resolve groups of schedule %d: %v", s.ID, err)
+		return
+	}
+	results := []announcementResult{}
+	failed := 0
+	for _, group := range groups {
+		result := announcementResult{Group: group.String()}
+		res, err := announceToGroup(ctx, group, s.Text)
+		if err != nil {
+			failed++
+			result.Error = err.Error()
+			waLogger.Errorf("Failed to send announcement %d to %s: %v", s.ID, group, err)
+		} else {
+			result.MessageID = res.ID
+		}
+		results = append(results, result)
+	}
+	lastError := ""
+	switch {
+	case len(groups) == 0:
+		lastError = "no groups to announce to"
+	case failed > 0:
+		lastError = fmt.Sprintf("%d of %d groups failed", failed, len(groups))
+	}
+	succeeded := len(groups) > failed
+	resultsJSON, _ := json.Marshal(results)
+	_, err = gatewayDB.ExecContext(ctx,
+		`UPDATE schedules SET last_run_at = ?, run_count = run_count + ?, last_error = ?, last_results = ? WHERE id = ?`,
+		now.Unix(), boolInt(succeeded), lastError, string(resultsJSON), s.ID)
+	if err != nil {
+		waLogger.Errorf("Failed to record run of schedule %d: %v", s.ID, err)
+	}
+	data := map[string]interface{}{"id": s.ID, "due_at": occurrence, "results": results}
+	if succeeded {
+		emitWebhook("schedule.sent", data)
+	} else {
+		data["error"] = lastError
+		emitWebhook("schedule.failed", data)
+	}
+}
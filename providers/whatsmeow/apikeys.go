@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// Integration API keys carry a send policy that limits which destinations
+// they may message. Send endpoints stay open until the first key is created;
+// after that every send needs a key (Authorization: Bearer or X-API-Key).
+
+type sendPolicy struct {
+	AllowIndividuals bool     `json:"allow_individuals"`
+	AllowGroups      bool     `json:"allow_groups"`
+	GroupAllowlist   []string `json:"group_allowlist,omitempty"` // empty allows any group
+	NoNewContacts    bool     `json:"no_new_contacts"`           // only chats that have messaged us
+
+	ContentFilterOverride bool `json:"content_filter_override"` // exempt from the content policy
+	Priority              bool `json:"priority"`                // never shed, queued ahead of other sends
+}
+
+var defaultSendPolicy = sendPolicy{AllowIndividuals: true, AllowGroups: true}
+
+type apiKey struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Policy     sendPolicy `json:"policy"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+var errDestinationNotAllowed = errors.New("destination not allowed for this API key")
+
+type apiKeyContextKey struct{}
+
+func apiKeyFromContext(ctx context.Context) *apiKey {
+	key, _ := ctx.Value(apiKeyContextKey{}).(*apiKey)
+	return key
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func requestAPIKey(r *http.Request) string {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimPrefix(auth, "Bearer ")
+	}
+	return ""
+}
+
+func lookupAPIKey(ctx context.Context, plaintext string) (*apiKey, error) {
+	var key apiKey
+	var policy string
+	var created, lastUsed int64
+	err := gatewayDB.QueryRowContext(ctx,
+		`SELECT id, name, policy, created_at, last_used_at FROM api_keys WHERE key_hash = ?`, hashAPIKey(plaintext)).
+		Scan(&key.ID, &key.Name, &policy, &created, &lastUsed)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(policy), &key.Policy); err != nil {
+		return nil, fmt.Errorf("invalid policy on key %s: %w", key.ID, err)
+	}
+	key.CreatedAt = time.Unix(created, 0).UTC()
+	key.LastUsedAt = unixPtr(lastUsed)
+	return &key, nil
+}
+
+// requireAPIKey authenticates send endpoints and attaches the key to the
+// request context so its policy is enforced at send time. The operator's
+// X-Admin-Key is accepted too, without a policy, which is how the gateway
+// proxies sends for the instance owner.
+func requireAPIKey(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if isAdminRequest(r) {
+			next(w, r)
+			return
+		}
+		plaintext := requestAPIKey(r)
+		if plaintext == "" {
+			var exists bool
+			if err := gatewayDB.QueryRowContext(r.Context(), `SELECT EXISTS (SELECT 1 FROM api_keys)`).Scan(&exists); err != nil {
+				waLogger.Errorf("Failed to check API keys: %v", err)
+				http.Error(w, "Failed to check API key", http.StatusInternalServerError)
+				return
+			}
+			if exists {
+				http.Error(w, "API key required", http.StatusUnauthorized)
+				return
+			}
+			next(w, r)
+			return
+		}
+		key, err := lookupAPIKey(r.Context(), plaintext)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Invalid API key", http.StatusUnauthorized)
+			return
+		} else if err != nil {
+			waLogger.Errorf("Failed to look up API key: %v", err)
+			http.Error(w, "Failed to check API key", http.StatusInternalServerError)
+			return
+		}
+		gatewayDB.ExecContext(r.Context(), `UPDATE api_keys SET last_used_at = ? WHERE id = ?`, time.Now().Unix(), key.ID)
+		next(w, r.WithContext(context.WithValue(r.Context(), apiKeyContextKey{}, key)))
+	}
+}
+
+// checkSendPolicy enforces the policy of the request's API key, if any.
+func checkSendPolicy(ctx context.Context, to types.JID) error {
+	key := apiKeyFromContext(ctx)
+	if key == nil {
+		return nil
+	}
+	policy := key.Policy
+	if to.Server == types.GroupServer {
+		if !policy.AllowGroups {
+			return fmt.Errorf("%w: groups are not allowed", errDestinationNotAllowed)
+		}
+		if len(policy.GroupAllowlist) == 0 {
+			return nil
+		}
+		for _, jid := range policy.GroupAllowlist {
+			if jid == to.ToNonAD().String() {
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: group %s is not on the allowlist", errDestinationNotAllowed, to.ToNonAD())
+	}
+	if !policy.AllowIndividuals {
+		return fmt.Errorf("%w: individual chats are not allowed", errDestinationNotAllowed)
+	}
+	if policy.NoNewContacts {
+		chat, err := getChat(ctx, to.ToNonAD())
+		if err != nil && err != sql.ErrNoRows {
+			return fmt.Errorf("failed to check contact history: %w", err)
+		}
+		if err == sql.ErrNoRows || chat.LastInboundAt == nil {
+			return fmt.Errorf("%w: %s has not messaged this number before", errDestinationNotAllowed, to.ToNonAD())
+		}
+	}
+	return nil
+}
+
+// normalizePolicy canonicalizes the group allowlist so it matches recipient
+// JIDs at send time.
+func normalizePolicy(policy *sendPolicy) error {
+	for i, group := range policy.GroupAllowlist {
+		jid, err := types.ParseJID(strings.TrimSpace(group))
+		if err != nil || jid.Server != types.GroupServer {
+			return fmt.Errorf("invalid group JID %q", group)
+		}
+		policy.GroupAllowlist[i] = jid.ToNonAD().String()
+	}
+	return nil
+}
+
+func listAPIKeys(w http.ResponseWriter, r *http.Request) {
+	rows, err := gatewayDB.QueryContext(r.Context(),
+		`SELECT id, name, policy, created_at, last_used_at FROM api_keys ORDER BY created_at, id`)
+	if err != nil {
+		waLogger.Errorf("Failed to list API keys: %v", err)
+		http.Error(w, "Failed to list API keys", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	keys := []apiKey{}
+	for rows.Next() {
+		var key apiKey
+		var policy string
+		var created, lastUsed int64
+		if err := rows.Scan(&key.ID, &key.Name, &policy, &created, &lastUsed); err != nil {
+			waLogger.Errorf("Failed to scan API key: %v", err)
+			http.Error(w, "Failed to list API keys", http.StatusInternalServerError)
+			return
+		}
+		json.Unmarshal([]byte(policy), &key.Policy)
+		key.CreatedAt = time.Unix(created, 0).UTC()
+		key.LastUsedAt = unixPtr(lastUsed)
+		keys = append(keys, key)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"api_keys": keys})
+}
+
+type apiKeyRequest struct {
+	Name   string      `json:"name"`
+	Policy *sendPolicy `json:"policy"`
+}
+
+// createAPIKey returns the plaintext key once; only its hash is stored.
+func createAPIKey(w http.ResponseWriter, r *http.Request) {
+	var req apiKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Name == "" {
+		http.Error(w, "name is required", http.StatusBadRequest)
+		return
+	}
+	policy := defaultSendPolicy
+	if req.Policy != nil {
+		policy = *req.Policy
+	}
+	if err := normalizePolicy(&policy); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	policyJSON, _ := json.Marshal(policy)
+	key := apiKey{ID: randomHex(8), Name: req.Name, Policy: policy, CreatedAt: time.Now().UTC()}
+	plaintext := "wgk_" + randomHex(24)
+	_, err := gatewayDB.ExecContext(r.Context(),
+		`INSERT INTO api_keys (id, name, key_hash, policy, created_at) VALUES (?, ?, ?, ?, ?)`,
+		key.ID, key.Name, hashAPIKey(plaintext), string(policyJSON), key.CreatedAt.Unix())
+	if err != nil {
+		waLogger.Errorf("Failed to create API key: %v", err)
+		http.Error(w, "Failed to create API key", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusCreated, struct {
+		apiKey
+		Key string `json:"key"`
+	}{key, plaintext})
+}
+
+func putAPIKeyPolicy(w http.ResponseWriter, r *http.Request) {
+	var policy sendPolicy
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := normalizePolicy(&policy); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	policyJSON, _ := json.Marshal(policy)
+	id := r.PathValue("id")
+	res, err := gatewayDB.ExecContext(r.Context(), `UPDATE api_keys SET policy = ? WHERE id = ?`, string(policyJSON), id)
+	if err != nil {
+		waLogger.Errorf("Failed to update policy of API key %s: %v", id, err)
+		http.Error(w, "Failed to update API key", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		http.Error(w, "API key not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, policy)
+}
+
+func deleteAPIKey(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	res, err := gatewayDB.ExecContext(r.Context(), `DELETE FROM api_keys WHERE id = ?`, id)
+	if err != nil {
+		waLogger.Errorf("Failed to delete API key %s: %v", id, err)
+		http.Error(w, "Failed to delete API key", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		http.Error(w, "API key not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow/appstate"
+)
+
+// App-state resync re-downloads the synced collections from the phone when
+// local state has drifted. A resync runs in the background, one at a time;
+// GET /admin/appstate/resync reports its progress.
+
+// appStateCollections maps the collections exposed by the API to the
+// WhatsApp app-state patches that hold them. The blocklist isn't app state
+// and is fetched separately.
+var appStateCollections = map[string][]appstate.WAPatchName{
+	"contacts":      {appstate.WAPatchCriticalUnblockLow},
+	"chat_metadata": {appstate.WAPatchRegularHigh, appstate.WAPatchRegular, appstate.WAPatchRegularLow},
+	"settings":      {appstate.WAPatchCriticalBlock},
+	"blocklist":     nil,
+}
+
+var appStateCollectionOrder = []string{"contacts", "chat_metadata", "settings", "blocklist"}
+
+type appStateStep struct {
+	Collection string     `json:"collection"`
+	State      string     `json:"state"` // pending, running, done, failed
+	Error      string     `json:"error,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+type appStateResync struct {
+	State      string         `json:"state"` // running, done, failed
+	FullSync   bool           `json:"full_sync"`
+	StartedAt  time.Time      `json:"started_at"`
+	FinishedAt *time.Time     `json:"finished_at,omitempty"`
+	Steps      []appStateStep `json:"steps"`
+}
+
+var (
+	appStateMu     sync.Mutex
+	appStateLatest *appStateResync
+)
+
+type appStateResyncRequest struct {
+	Collections []string `json:"collections"` // empty means all
+	FullSync    *bool    `json:"full_sync"`   // default true
+}
+
+func startAppStateResync(w http.ResponseWriter, r *http.Request) {
+	if !requireClient(w, r, false) {
+		return
+	}
+	var req appStateResyncRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+	collections := req.Collections
+	if len(collections) == 0 {
+		collections = appStateCollectionOrder
+	}
+	job := &appStateResync{State: "running", FullSync: req.FullSync == nil || *req.FullSync, StartedAt: time.Now().UTC()}
+	for _, c := range collections {
+		if _, ok := appStateCollections[c]; !ok {
+			http.Error(w, "Unknown collection: "+c, http.StatusBadRequest)
+			return
+		}
+		job.Steps = append(job.Steps, appStateStep{Collection: c, State: "pending"})
+	}
+
+	appStateMu.Lock()
+	if appStateLatest != nil && appStateLatest.State == "running" {
+		appStateMu.Unlock()
+		http.Error(w, "A resync is already running", http.StatusConflict)
+		return
+	}
+	appStateLatest = job
+	snapshot := *job
+	snapshot.Steps = append([]appStateStep(nil), job.Steps...)
+	appStateMu.Unlock()
+
+	go runAppStateResync(job)
+	writeJSON(w, http.StatusAccepted, snapshot)
+}
+
+func runAppStateResync(job *appStateResync) {
+	ctx := context.Background()
+	failed := false
+	for i := range job.Steps {
+		appStateMu.Lock()
+		job.Steps[i].State = "running"
+		collection := job.Steps[i].Collection
+		appStateMu.Unlock()
+
+		var err error
+		if collection == "blocklist" {
+			_, err = client.GetBlocklist(ctx)
+		}
+		for _, patch := range appStateCollections[collection] {
+			if err = client.FetchAppState(ctx, patch, job.FullSync, false); err != nil {
+				break
+			}
+		}
+
+		now := time.Now().UTC()
+		appStateMu.Lock()
+		job.Steps[i].FinishedAt = &now
+		if err != nil {
+			failed = true
+			job.Steps[i].State = "failed"
+			job.Steps[i].Error = err.Error()
+			waLogger.Errorf("App-state resync of %s failed: %v", collection, err)
+		} else {
+			job.Steps[i].State = "done"
+		}
+		appStateMu.Unlock()
+	}
+
+	now := time.Now().UTC()
+	appStateMu.Lock()
+	job.FinishedAt = &now
+	job.State = "done"
+	if failed {
+		job.State = "failed"
+	}
+	appStateMu.Unlock()
+	waLogger.Infof("App-state resync finished: %s", job.State)
+}
+
+func getAppStateResync(w http.ResponseWriter, r *http.Request) {
+	appStateMu.Lock()
+	defer appStateMu.Unlock()
+	if appStateLatest == nil {
+		http.Error(w, "No resync has run", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, appStateLatest)
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"google.golang.org/protobuf/proto"
+)
+
+// WhatsApp only renders OGG Opus as a voice note; anything else arrives as a
+// plain audio file. /send/audio converts other formats with ffmpeg and sends
+// the result as a voice note, with the duration and waveform the voice note
+// player draws; without them it shows a flat line and 0:00.
+
+var audioMaxBytes = int64(envInt("AUDIO_MAX_BYTES", 16<<20))
+
+const (
+	voiceMimetype = "audio/ogg; codecs=opus"
+	// Opus granule positions always count 48 kHz samples.
+	opusSampleRate = 48000
+	// waveformSamples is how many bars the voice note player draws.
+	waveformSamples = 64
+	// waveformSampleRate is plenty to follow loudness.
+	waveformSampleRate = 8000
+)
+
+// isOggOpus checks for an Ogg stream whose first packet is an Opus header.
+func isOggOpus(data []byte) bool {
+	return len(data) >= 36 && bytes.HasPrefix(data, []byte("OggS")) && bytes.Equal(data[28:36], []byte("OpusHead"))
+}
+
+// oggOpusSeconds reads the duration of an Ogg Opus stream from the granule
+// position of its last page, less the encoder's pre-skip.
+func oggOpusSeconds(data []byte) uint32 {
+	if !isOggOpus(data) || len(data) < 40 {
+		return 0
+	}
+	preSkip := int64(binary.LittleEndian.Uint16(data[38:]))
+	var granule int64
+	for page := data; len(page) >= 27 && bytes.HasPrefix(page, []byte("OggS")); {
+		segments := int(page[26])
+		if len(page) < 27+segments {
+			break
+		}
+		size := 27 + segments
+		for _, lacing := range page[27 : 27+segments] {
+			size += int(lacing)
+		}
+		if len(page) < size {
+			break
+		}
+		// -1 marks pages where no packet ends.
+		if g := int64(binary.LittleEndian.Uint64(page[6:])); g >= 0 {
+			granule = g
+		}
+		page = page[size:]
+	}
+	if granule <= preSkip {
+		return 0
+	}
+	return uint32((granule - preSkip + opusSampleRate/2) / opusSampleRate)
+}
+
+// voiceWaveform decodes a voice note and returns its waveform and duration.
+func voiceWaveform(ctx context.Context, data []byte) ([]byte, uint32, error) {
+	pcm, err := runFFmpeg(ctx, data, "-vn", "-ac", "1", "-ar", strconv.Itoa(waveformSampleRate), "-f", "s16le")
+	if err != nil {
+		return nil, 0, err
+	}
+	samples := len(pcm) / 2
+	seconds := uint32((samples + waveformSampleRate/2) / waveformSampleRate)
+	return waveformFromPCM(pcm), seconds, nil
+}
+
+// waveformFromPCM splits 16-bit mono PCM into waveformSamples slices and
+// scales the loudness (RMS) of each to 0-100 against the loudest, as
+// WhatsApp's own clients do. Silence gives all zeros.
+func waveformFromPCM(pcm []byte) []byte {
+	samples := len(pcm) / 2
+	if samples == 0 {
+		return nil
+	}
+	var sums [waveformSamples]float64
+	var counts [waveformSamples]int
+	for i := 0; i < samples; i++ {
+		s := float64(int16(binary.LittleEndian.Uint16(pcm[2*i:])))
+		bar := i * waveformSamples / samples
+		sums[bar] += s * s
+		counts[bar]++
+	}
+	var levels [waveformSamples]float64
+	peak := 0.0
+	for i := range levels {
+		if counts[i] > 0 {
+			levels[i] = math.Sqrt(sums[i] / float64(counts[i]))
+		}
+		peak = max(peak, levels[i])
+	}
+	waveform := make([]byte, waveformSamples)
+	if peak > 0 {
+		for i, level := range levels {
+			waveform[i] = byte(math.Round(level / peak * 100))
+		}
+	}
+	return waveform
+}
+
+// voiceNoteInfo describes an audio message in webhooks.
+type voiceNoteInfo struct {
+	PTT      bool   `json:"ptt"` // recorded as a voice note
+	Seconds  uint32 `json:"seconds,omitempty"`
+	Waveform []int  `json:"waveform,omitempty"` // 0-100 per bar, as the sender computed it
+}
+
+// parseAudioMetadata returns the duration and waveform of an audio message,
+// or nil for other messages.
+func parseAudioMetadata(msg *waE2E.Message) *voiceNoteInfo {
+	audio := msg.GetAudioMessage()
+	if audio == nil {
+		return nil
+	}
+	info := &voiceNoteInfo{PTT: audio.GetPTT(), Seconds: audio.GetSeconds()}
+	// As numbers rather than the base64 a []byte would marshal to.
+	for _, level := range audio.GetWaveform() {
+		info.Waveform = append(info.Waveform, int(level))
+	}
+	return info
+}
+
+// convertToVoiceNote converts audio to mono OGG Opus.
+func convertToVoiceNote(ctx context.Context, data []byte) ([]byte, error) {
+	out, err := runFFmpeg(ctx, data,
+		"-vn", "-map_metadata", "-1", "-ac", "1", "-ar", "48000",
+		"-c:a", "libopus", "-b:a", "32k", "-application", "voip",
+		"-f", "ogg")
+	if err != nil {
+		return nil, err
+	}
+	if !isOggOpus(out) {
+		return nil, fmt.Errorf("ffmpeg produced no Opus audio")
+	}
+	return out, nil
+}
+
+func sendAudio(w http.ResponseWriter, r *http.Request) {
+	if sessionArchived() {
+		http.Error(w, "Session is archived", http.StatusConflict)
+		return
+	}
+	if !requireClient(w, r, false) {
+		return
+	}
+	up, err := readMediaUpload(w, r, "audio", audioMaxBytes)
+	if err != nil {
+		writeMediaError(w, err)
+		return
+	}
+	defer up.File.Remove()
+	recipient, err := parseRecipient(up.To)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	recipient = toPhoneJID(r.Context(), recipient)
+	// Check the policy before spending time on the conversion and upload.
+	if err := checkSendPolicy(r.Context(), recipient); err != nil {
+		writeSendError(w, recipient, err)
+		return
+	}
+
+	// Voice notes are small and mostly converted, so they're read whole.
+	data, err := up.File.ReadAll()
+	if err != nil {
+		waLogger.Errorf("Failed to read spooled audio: %v", err)
+		http.Error(w, "Failed to read audio", http.StatusInternalServerError)
+		return
+	}
+	file := up.File
+	if !isOggOpus(data) {
+		if data, err = convertToVoiceNote(r.Context(), data); errors.Is(err, errUnsupportedMedia) {
+			writeMediaError(w, unsupportedMedia("Unsupported audio format"))
+			return
+		} else if err != nil {
+			waLogger.Errorf("Failed to convert audio for %s: %v", recipient, err)
+			http.Error(w, "Failed to convert audio", http.StatusInternalServerError)
+			return
+		}
+		if file, err = spoolBytes(data); err != nil {
+			waLogger.Errorf("Failed to spool audio for %s: %v", recipient, err)
+			http.Error(w, "Failed to convert audio", http.StatusInternalServerError)
+			return
+		}
+		defer file.Remove()
+	}
+	audio := &waE2E.AudioMessage{
+		FileLength: proto.Uint64(uint64(len(data))),
+		Mimetype:   proto.String(voiceMimetype),
+		PTT:        proto.Bool(true),
+	}
+	if up.ViewOnce {
+		audio.ViewOnce = proto.Bool(true)
+	}
+	seconds := oggOpusSeconds(data)
+	if waveform, decoded, err := voiceWaveform(r.Context(), data); err != nil {
+		waLogger.Warnf("Failed to compute waveform of audio for %s: %v", recipient, err)
+	} else {
+		audio.Waveform = waveform
+		if seconds == 0 {
+			seconds = decoded
+		}
+	}
+	if seconds > 0 {
+		audio.Seconds = proto.Uint32(seconds)
+	}
+
+	opts := sendOptions{AllowDuplicate: up.AllowDuplicate, Expiration: up.Expiration}
+	res, err := sendMedia(r.Context(), recipient, file, &waE2E.Message{AudioMessage: audio}, opts)
+	if errors.Is(err, errMediaUpload) {
+		waLogger.Errorf("Failed to upload audio for %s: %v", recipient, err)
+		http.Error(w, "Failed to upload audio", http.StatusBadGateway)
+		return
+	} else if err != nil {
+		writeSendError(w, recipient, err)
+		return
+	}
+	writeSendResult(w, recipient, res)
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// whatsmeow always sends delivery receipts and never read receipts. The
+// auto-read policy decides which incoming messages get the blue ticks:
+//
+//	off   never (default)
+//	all   every message
+//	bot   chats the bot is handling, i.e. not taken over by an agent
+//	chats only the listed chats
+
+type autoReadPolicy struct {
+	Mode         string   `json:"mode"`
+	Chats        []string `json:"chats,omitempty"`
+	Groups       bool     `json:"groups"` // also read group messages
+	DelaySeconds int      `json:"delay_seconds,omitempty"`
+}
+
+var (
+	autoReadMu     sync.RWMutex
+	autoReadConfig = autoReadPolicy{Mode: "off"}
+)
+
+func validAutoReadMode(mode string) bool {
+	switch mode {
+	case "off", "all", "bot", "chats":
+		return true
+	}
+	return false
+}
+
+func loadAutoReadPolicy(ctx context.Context) {
+	raw := getSetting(ctx, "auto_read_policy", "")
+	if raw == "" {
+		return
+	}
+	var policy autoReadPolicy
+	if err := json.Unmarshal([]byte(raw), &policy); err != nil || !validAutoReadMode(policy.Mode) {
+		waLogger.Errorf("Ignoring invalid auto-read policy %q", raw)
+		return
+	}
+	autoReadMu.Lock()
+	autoReadConfig = policy
+	autoReadMu.Unlock()
+}
+
+func currentAutoReadPolicy() autoReadPolicy {
+	autoReadMu.RLock()
+	defer autoReadMu.RUnlock()
+	return autoReadConfig
+}
+
+func (p autoReadPolicy) matches(ctx context.Context, chat types.JID, isGroup, botHandled bool) bool {
+	if isGroup && !p.Groups {
+		return false
+	}
+	switch p.Mode {
+	case "all":
+		return true
+	case "bot":
+		return botHandled
+	case "chats":
+		for _, c := range p.Chats {
+			if c == canonicalJID(ctx, chat).String() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// autoMarkRead sends a read receipt for an incoming message when the policy
+// matches. botHandled is the bot state already resolved for the webhook.
+func autoMarkRead(evt *events.Message, chat types.JID, botHandled bool) {
+	ctx := context.Background()
+	policy := currentAutoReadPolicy()
+	if evt.Info.IsFromMe || !featureEnabled(flagReadReceipts) || !policy.matches(ctx, chat, evt.Info.IsGroup, botHandled) {
+		return
+	}
+	if policy.DelaySeconds > 0 {
+		time.Sleep(time.Duration(policy.DelaySeconds) * time.Second)
+	}
+	err := client.MarkRead(ctx, []types.MessageID{evt.Info.ID}, time.Now(), evt.Info.Chat, evt.Info.Sender)
+	if err != nil {
+		waLogger.Warnf("Failed to mark message %s as read: %v", evt.Info.ID, err)
+	}
+}
+
+func getAutoReadPolicy(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, currentAutoReadPolicy())
+}
+
+func putAutoReadPolicy(w http.ResponseWriter, r *http.Request) {
+	var policy autoReadPolicy
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil || !validAutoReadMode(policy.Mode) {
+		http.Error(w, "mode must be one of off, all, bot, chats", http.StatusBadRequest)
+		return
+	}
+	if policy.DelaySeconds < 0 {
+		http.Error(w, "delay_seconds must not be negative", http.StatusBadRequest)
+		return
+	}
+	for i, c := range policy.Chats {
+		if c == "" {
+			http.Error(w, "Invalid JID in chats", http.StatusBadRequest)
+			return
+		}
+		jid, ok := parseJID(c)
+		if !ok {
+			http.Error(w, "Invalid JID: "+c, http.StatusBadRequest)
+			return
+		}
+		policy.Chats[i] = canonicalJID(r.Context(), jid).String()
+	}
+	raw, _ := json.Marshal(policy)
+	if err := setSetting(r.Context(), "auto_read_policy", string(raw)); err != nil {
+		waLogger.Errorf("Failed to save auto-read policy: %v", err)
+		http.Error(w, "Failed to save policy", http.StatusInternalServerError)
+		return
+	}
+	autoReadMu.Lock()
+	autoReadConfig = policy
+	autoReadMu.Unlock()
+	writeJSON(w, http.StatusOK, policy)
+}
@@ -0,0 +1,536 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Backups copy the SQLite file holding the whatsmeow session and the gateway
+// tables every BACKUP_INTERVAL to BACKUP_TARGET (local or s3), keeping them
+// for BACKUP_RETENTION; the newest backup is always kept. They're
+// independent of the state snapshot, which only lives as long as the
+// gateway keeps it. A restore is staged next to the database and swapped in
+// at the next start, so /admin/backups/{name}/restore shuts the instance
+// down for the container to restart it; the replaced database is kept as
+// .pre-restore.
+
+var (
+	backupInterval  = envDuration("BACKUP_INTERVAL", 24*time.Hour)
+	backupRetention = envDuration("BACKUP_RETENTION", 7*24*time.Hour)
+)
+
+var backupNameRe = regexp.MustCompile(`^whatsmeow-(\d{8}T\d{6}Z)\.db$`)
+
+const backupTimeLayout = "20060102T150405Z"
+
+type backupInfo struct {
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type backupStore interface {
+	Put(ctx context.Context, name, path string) error
+	List(ctx context.Context) ([]backupInfo, error)
+	Get(ctx context.Context, name string, w io.Writer) error
+	Delete(ctx context.Context, name string) error
+}
+
+// activeBackupStore is nil when backups are off.
+var activeBackupStore backupStore
+
+// backupMu keeps backups and restores from overlapping.
+var backupMu sync.Mutex
+
+func newBackupStore(target string) backupStore {
+	switch strings.ToLower(target) {
+	case "":
+		return nil
+	case "local":
+		// Preferably a separate volume; on the session volume a backup
+		// doesn't outlive losing it.
+		return &localBackupStore{dir: envString("BACKUP_DIR", "/app/session/backups")}
+	case "s3":
+		bucket := envString("BACKUP_S3_BUCKET", "")
+		if bucket == "" {
+			waLogger.Errorf("BACKUP_S3_BUCKET is required for S3 backups, backups disabled")
+			return nil
+		}
+		region := envString("BACKUP_S3_REGION", envString("AWS_REGION", "us-east-1"))
+		// Any S3-compatible service (MinIO, R2, ...) works through its
+		// endpoint; objects are addressed path-style.
+		endpoint := envString("BACKUP_S3_ENDPOINT", "https://s3."+region+".amazonaws.com")
+		return &s3Store{
+			endpoint:  strings.TrimSuffix(endpoint, "/"),
+			bucket:    bucket,
+			prefix:    envString("BACKUP_S3_PREFIX", ""),
+			region:    region,
+			accessKey: envString("BACKUP_S3_ACCESS_KEY_ID", envString("AWS_ACCESS_KEY_ID", "")),
+			secretKey: envString("BACKUP_S3_SECRET_ACCESS_KEY", envString("AWS_SECRET_ACCESS_KEY", "")),
+		}
+	default:
+		waLogger.Errorf("Unknown BACKUP_TARGET %q, backups disabled", target)
+		return nil
+	}
+}
+
+func backupCreatedAt(name string) (time.Time, bool) {
+	m := backupNameRe.FindStringSubmatch(name)
+	if m == nil {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(backupTimeLayout, m[1])
+	return t, err == nil
+}
+
+// sortBackups orders backups newest first.
+func sortBackups(backups []backupInfo) {
+	sort.Slice(backups, func(i, j int) bool { return backups[i].CreatedAt.After(backups[j].CreatedAt) })
+}
+
+// createBackup takes a consistent copy of the database, stores it and
+// applies the retention.
+func createBackup(ctx context.Context) (backupInfo, error) {
+	backupMu.Lock()
+	defer backupMu.Unlock()
+	now := time.Now().UTC()
+	info := backupInfo{Name: "whatsmeow-" + now.Format(backupTimeLayout) + ".db", CreatedAt: now.Truncate(time.Second)}
+	tmp := filepath.Join(filepath.Dir(dbPath), "."+info.Name+".tmp")
+	os.Remove(tmp)
+	defer os.Remove(tmp)
+	// VACUUM INTO writes a compacted copy without blocking writers for long.
+	if _, err := gatewayDB.ExecContext(ctx, `VACUUM INTO ?`, tmp); err != nil {
+		return info, fmt.Errorf("failed to copy database: %w", err)
+	}
+	if st, err := os.Stat(tmp); err == nil {
+		info.Size = st.Size()
+	}
+	if err := activeBackupStore.Put(ctx, info.Name, tmp); err != nil {
+		return info, fmt.Errorf("failed to store backup: %w", err)
+	}
+	waLogger.Infof("Backed up database as %s (%d bytes)", info.Name, info.Size)
+	pruneBackups(ctx, now)
+	return info, nil
+}
+
+func pruneBackups(ctx context.Context, now time.Time) {
+	if backupRetention <= 0 {
+		return
+	}
+	backups, err := activeBackupStore.List(ctx)
+	if err != nil {
+		waLogger.Errorf("Failed to list backups for pruning: %v", err)
+		return
+	}
+	sortBackups(backups)
+	for i, b := range backups {
+		if i > 0 && b.CreatedAt.Before(now.Add(-backupRetention)) {
+			if err := activeBackupStore.Delete(ctx, b.Name); err != nil {
+				waLogger.Errorf("Failed to delete expired backup %s: %v", b.Name, err)
+			}
+		}
+	}
+}
+
+// runBackups backs up every BACKUP_INTERVAL, counting from the newest
+// existing backup so restarts don't reset the schedule.
+func runBackups() {
+	if activeBackupStore == nil || backupInterval <= 0 {
+		return
+	}
+	ctx := context.Background()
+	var last time.Time
+	if backups, err := activeBackupStore.List(ctx); err != nil {
+		waLogger.Errorf("Failed to list backups: %v", err)
+	} else if len(backups) > 0 {
+		sortBackups(backups)
+		last = backups[0].CreatedAt
+	}
+	for {
+		time.Sleep(time.Until(last.Add(backupInterval)))
+		last = time.Now()
+		if _, err := createBackup(ctx); err != nil {
+			waLogger.Errorf("Scheduled backup failed: %v", err)
+		}
+	}
+}
+
+// applyPendingRestore swaps in a database staged by a restore. It runs
+// before the database is opened.
+func applyPendingRestore() error {
+	staged := dbPath + ".restore"
+	if _, err := os.Stat(staged); os.IsNotExist(err) {
+		return nil
+	}
+	if _, err := os.Stat(dbPath); err == nil {
+		if err := os.Rename(dbPath, dbPath+".pre-restore"); err != nil {
+			return fmt.Errorf("failed to set aside current database: %w", err)
+		}
+	}
+	// The old database's journal must not be replayed onto the backup.
+	os.Remove(dbPath + "-wal")
+	os.Remove(dbPath + "-shm")
+	if err := os.Rename(staged, dbPath); err != nil {
+		return fmt.Errorf("failed to swap in restored database: %w", err)
+	}
+	waLogger.Warnf("Restored database from backup; the previous one is at %s.pre-restore", dbPath)
+	return nil
+}
+
+// checkDatabaseFile makes sure a downloaded backup is an intact SQLite
+// database before it replaces the live one.
+func checkDatabaseFile(ctx context.Context, path string) error {
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?mode=ro", path))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	var result string
+	if err := db.QueryRowContext(ctx, `PRAGMA quick_check`).Scan(&result); err != nil {
+		return err
+	}
+	if result != "ok" {
+		return fmt.Errorf("integrity check failed: %s", result)
+	}
+	return nil
+}
+
+func listBackups(w http.ResponseWriter, r *http.Request) {
+	if activeBackupStore == nil {
+		http.Error(w, "Backups are disabled, set BACKUP_TARGET", http.StatusConflict)
+		return
+	}
+	backups, err := activeBackupStore.List(r.Context())
+	if err != nil {
+		waLogger.Errorf("Failed to list backups: %v", err)
+		http.Error(w, "Failed to list backups", http.StatusBadGateway)
+		return
+	}
+	sortBackups(backups)
+	if backups == nil {
+		backups = []backupInfo{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"backups":           backups,
+		"interval_seconds":  int64(backupInterval / time.Second),
+		"retention_seconds": int64(backupRetention / time.Second),
+	})
+}
+
+func triggerBackup(w http.ResponseWriter, r *http.Request) {
+	if activeBackupStore == nil {
+		http.Error(w, "Backups are disabled, set BACKUP_TARGET", http.StatusConflict)
+		return
+	}
+	info, err := createBackup(r.Context())
+	if err != nil {
+		waLogger.Errorf("Backup failed: %v", err)
+		http.Error(w, "Backup failed", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusCreated, info)
+}
+
+// restoreBackup downloads and checks a backup, stages it and shuts down so
+// it's swapped in on restart.
+func restoreBackup(w http.ResponseWriter, r *http.Request) {
+	if activeBackupStore == nil {
+		http.Error(w, "Backups are disabled, set BACKUP_TARGET", http.StatusConflict)
+		return
+	}
+	name := r.PathValue("name")
+	if !backupNameRe.MatchString(name) {
+		http.Error(w, "Invalid backup name", http.StatusBadRequest)
+		return
+	}
+	if !backupMu.TryLock() {
+		http.Error(w, "A backup or restore is in progress", http.StatusConflict)
+		return
+	}
+	defer backupMu.Unlock()
+
+	staged := dbPath + ".restore"
+	f, err := os.Create(staged)
+	if err != nil {
+		waLogger.Errorf("Failed to stage restore of %s: %v", name, err)
+		http.Error(w, "Failed to stage restore", http.StatusInternalServerError)
+		return
+	}
+	err = activeBackupStore.Get(r.Context(), name, f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		os.Remove(staged)
+		http.Error(w, "Backup not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		os.Remove(staged)
+		waLogger.Errorf("Failed to download backup %s: %v", name, err)
+		http.Error(w, "Failed to download backup", http.StatusBadGateway)
+		return
+	}
+	if err := checkDatabaseFile(r.Context(), staged); err != nil {
+		os.Remove(staged)
+		waLogger.Errorf("Backup %s is unusable: %v", name, err)
+		http.Error(w, "Backup is not a usable database", http.StatusUnprocessableEntity)
+		return
+	}
+
+	waLogger.Warnf("Restore of %s staged; shutting down to apply it", name)
+	writeJSON(w, http.StatusAccepted, map[string]interface{}{"backup": name, "status": "restarting"})
+	go func() {
+		time.Sleep(time.Second)
+		syscall.Kill(os.Getpid(), syscall.SIGTERM)
+	}()
+}
+
+type localBackupStore struct {
+	dir string
+}
+
+func (s *localBackupStore) Put(ctx context.Context, name, path string) error {
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return err
+	}
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	part := filepath.Join(s.dir, name+".part")
+	dst, err := os.OpenFile(part, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		os.Remove(part)
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(part)
+		return err
+	}
+	return os.Rename(part, filepath.Join(s.dir, name))
+}
+
+func (s *localBackupStore) List(ctx context.Context) ([]backupInfo, error) {
+	entries, err := os.ReadDir(s.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var backups []backupInfo
+	for _, e := range entries {
+		created, ok := backupCreatedAt(e.Name())
+		if !ok {
+			continue
+		}
+		if fi, err := e.Info(); err == nil {
+			backups = append(backups, backupInfo{Name: e.Name(), Size: fi.Size(), CreatedAt: created})
+		}
+	}
+	return backups, nil
+}
+
+func (s *localBackupStore) Get(ctx context.Context, name string, w io.Writer) error {
+	f, err := os.Open(filepath.Join(s.dir, name))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+func (s *localBackupStore) Delete(ctx context.Context, name string) error {
+	return os.Remove(filepath.Join(s.dir, name))
+}
+
+// s3Store talks to the S3 REST API directly, signing requests with
+// AWS Signature Version 4. Besides backups it holds offloaded media.
+type s3Store struct {
+	endpoint  string
+	bucket    string
+	prefix    string
+	region    string
+	accessKey string
+	secretKey string
+}
+
+var s3Client = &http.Client{Timeout: 10 * time.Minute}
+
+// s3Escape encodes a query component the way SigV4 expects.
+func s3Escape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func (s *s3Store) signingKey(now time.Time) []byte {
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), now.Format("20060102"))
+	for _, part := range []string{s.region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	return key
+}
+
+func (s *s3Store) do(ctx context.Context, method, name string, query url.Values, body io.Reader, size int64) (*http.Response, error) {
+	u, err := url.Parse(s.endpoint + "/" + s.bucket)
+	if err != nil {
+		return nil, err
+	}
+	if name != "" {
+		u.Path += "/" + s.prefix + name
+	}
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var params []string
+	for _, k := range keys {
+		params = append(params, s3Escape(k)+"="+s3Escape(query.Get(k)))
+	}
+	u.RawQuery = strings.Join(params, "&")
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	now := time.Now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	scope := now.Format("20060102") + "/" + s.region + "/s3/aws4_request"
+	// Payloads go unsigned; TLS protects them in transit.
+	req.Header.Set("X-Amz-Content-Sha256", "UNSIGNED-PAYLOAD")
+	req.Header.Set("X-Amz-Date", amzDate)
+	canonical := strings.Join([]string{
+		method,
+		u.EscapedPath(),
+		u.RawQuery,
+		"host:" + u.Host + "\nx-amz-content-sha256:UNSIGNED-PAYLOAD\nx-amz-date:" + amzDate + "\n",
+		"host;x-amz-content-sha256;x-amz-date",
+		"UNSIGNED-PAYLOAD",
+	}, "\n")
+	hash := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature=%s",
+		s.accessKey, scope, hex.EncodeToString(hmacSHA256(s.signingKey(now), toSign))))
+
+	resp, err := s3Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound && name != "" {
+		resp.Body.Close()
+		return nil, os.ErrNotExist
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("S3 %s failed with status %s: %s", method, resp.Status, msg)
+	}
+	return resp, nil
+}
+
+func (s *s3Store) Put(ctx context.Context, name, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(ctx, "PUT", name, nil, f, st.Size())
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *s3Store) List(ctx context.Context) ([]backupInfo, error) {
+	var backups []backupInfo
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {s.prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		resp, err := s.do(ctx, "GET", "", query, nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		var result struct {
+			Contents []struct {
+				Key  string
+				Size int64
+			}
+			IsTruncated           bool
+			NextContinuationToken string
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode S3 listing: %w", err)
+		}
+		for _, obj := range result.Contents {
+			name := strings.TrimPrefix(obj.Key, s.prefix)
+			if created, ok := backupCreatedAt(name); ok {
+				backups = append(backups, backupInfo{Name: name, Size: obj.Size, CreatedAt: created})
+			}
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return backups, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+func (s *s3Store) Get(ctx context.Context, name string, w io.Writer) error {
+	resp, err := s.do(ctx, "GET", name, nil, nil, 0)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+func (s *s3Store) Delete(ctx context.Context, name string) error {
+	resp, err := s.do(ctx, "DELETE", name, nil, nil, 0)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBackupAndRestore(t *testing.T) {
+	openTestDB(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+	oldStore, oldRetention := activeBackupStore, backupRetention
+	activeBackupStore, backupRetention = &localBackupStore{dir: dir}, 7*24*time.Hour
+	t.Cleanup(func() { activeBackupStore, backupRetention = oldStore, oldRetention })
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /admin/backups", listBackups)
+	mux.HandleFunc("POST /admin/backups", triggerBackup)
+	mux.HandleFunc("POST /admin/backups/{name}/restore", restoreBackup)
+
+	// A backup past the retention, and a file that isn't a backup.
+	expired := "whatsmeow-" + time.Now().UTC().Add(-30*24*time.Hour).Format(backupTimeLayout) + ".db"
+	os.WriteFile(filepath.Join(dir, expired), []byte("old"), 0600)
+	os.WriteFile(filepath.Join(dir, "notes.txt"), nil, 0600)
+
+	if err := setSetting(ctx, "backup_test", "before"); err != nil {
+		t.Fatal(err)
+	}
+	w := request(t, mux, "POST", "/admin/backups", "")
+	if w.Code != http.StatusCreated {
+		t.Fatalf("POST /admin/backups: %d %s", w.Code, w.Body)
+	}
+	var info backupInfo
+	json.Unmarshal(w.Body.Bytes(), &info)
+	if _, ok := backupCreatedAt(info.Name); !ok || info.Size == 0 {
+		t.Fatalf("POST /admin/backups = %+v, want a named, non-empty backup", info)
+	}
+
+	// Taking the backup pruned the expired one.
+	w = request(t, mux, "GET", "/admin/backups", "")
+	var list struct {
+		Backups []backupInfo `json:"backups"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &list)
+	if len(list.Backups) != 1 || list.Backups[0].Name != info.Name {
+		t.Errorf("GET /admin/backups = %s, want only %s", w.Body, info.Name)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "notes.txt")); err != nil {
+		t.Errorf("pruning removed a file that isn't a backup: %v", err)
+	}
+
+	// Only intact backups are staged for a restore.
+	corrupt := "whatsmeow-20260101T000000Z.db"
+	os.WriteFile(filepath.Join(dir, corrupt), []byte("not a database"), 0600)
+	for target, want := range map[string]int{
+		"/admin/backups/whatsmeow.db/restore":                  http.StatusBadRequest,
+		"/admin/backups/whatsmeow-20250101T000000Z.db/restore": http.StatusNotFound,
+		"/admin/backups/" + corrupt + "/restore":               http.StatusUnprocessableEntity,
+	} {
+		if w := request(t, mux, "POST", target, ""); w.Code != want {
+			t.Errorf("POST %s: %d %s, want %d", target, w.Code, w.Body, want)
+		}
+	}
+	if _, err := os.Stat(dbPath + ".restore"); !os.IsNotExist(err) {
+		t.Errorf("a refused restore was left staged")
+	}
+
+	// A staged backup replaces the database at the next start.
+	if err := setSetting(ctx, "backup_test", "after"); err != nil {
+		t.Fatal(err)
+	}
+	gatewayDB.Close()
+	data, err := os.ReadFile(filepath.Join(dir, info.Name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dbPath+".restore", data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyPendingRestore(); err != nil {
+		t.Fatal(err)
+	}
+	if err := openGatewayDB(); err != nil {
+		t.Fatal(err)
+	}
+	if got := getSetting(ctx, "backup_test", ""); got != "before" {
+		t.Errorf("setting after the restore = %q, want the backed up %q", got, "before")
+	}
+	if _, err := os.Stat(dbPath + ".pre-restore"); err != nil {
+		t.Errorf("the replaced database wasn't kept: %v", err)
+	}
+}
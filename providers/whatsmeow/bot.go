@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// Human takeover: while an agent handles a chat, automated responders must
+// stay quiet. A paused chat resumes automatically once no agent has replied
+// for the idle timeout (per chat, or BOT_PAUSE_IDLE_TIMEOUT).
+
+var botPauseIdleTimeout = envDuration("BOT_PAUSE_IDLE_TIMEOUT", 30*time.Minute)
+
+type botState struct {
+	JID         string     `json:"jid"`
+	Enabled     bool       `json:"enabled"`
+	PausedAt    *time.Time `json:"paused_at,omitempty"`
+	IdleTimeout string     `json:"idle_timeout,omitempty"`
+	ResumesAt   *time.Time `json:"resumes_at,omitempty"`
+}
+
+type botPauseRow struct {
+	paused      bool
+	pausedAt    int64
+	idleTimeout time.Duration
+	lastOutAt   int64
+}
+
+func loadBotPause(ctx context.Context, chat types.JID) (botPauseRow, error) {
+	var row botPauseRow
+	var idleSecs int64
+	err := gatewayDB.QueryRowContext(ctx,
+		`SELECT bot_paused, bot_paused_at, bot_idle_timeout, last_outbound_at FROM chats WHERE jid = ?`,
+		chat.String()).Scan(&row.paused, &row.pausedAt, &idleSecs, &row.lastOutAt)
+	if err == sql.ErrNoRows {
+		return row, nil
+	}
+	row.idleTimeout = time.Duration(idleSecs) * time.Second
+	if row.idleTimeout <= 0 {
+		row.idleTimeout = botPauseIdleTimeout
+	}
+	return row, err
+}
+
+// resumesAt is when a paused chat goes back to the bot: the idle timeout
+// counted from the later of the pause and the last agent reply.
+func (p botPauseRow) resumesAt() time.Time {
+	last := p.pausedAt
+	if p.lastOutAt > last {
+		last = p.lastOutAt
+	}
+	return time.Unix(last, 0).Add(p.idleTimeout)
+}
+
+// botEnabled reports whether automated responders may reply in a chat.
+// Every auto-reply path must check it before sending.
+func botEnabled(ctx context.Context, chat types.JID) bool {
+	if gatewayDB == nil {
+		return true
+	}
+	chat = canonicalJID(ctx, chat)
+	p, err := loadBotPause(ctx, chat)
+	if err != nil {
+		waLogger.Errorf("Failed to load bot state of %s: %v", chat, err)
+		return false // fail closed: never talk over an agent
+	}
+	if !p.paused {
+		return true
+	}
+	if time.Now().After(p.resumesAt()) {
+		resumeBot(ctx, chat, "idle_timeout")
+		return true
+	}
+	return false
+}
+
+func setBotPaused(ctx context.Context, chat types.JID, paused bool, idleTimeout time.Duration) error {
+	var pausedAt int64
+	if paused {
+		pausedAt = time.Now().Unix()
+	}
+	_, err := gatewayDB.ExecContext(ctx, `
+		INSERT INTO chats (jid, bot_paused, bot_paused_at, bot_idle_timeout, updated_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (jid) DO UPDATE SET
+			bot_paused = excluded.bot_paused,
+			bot_paused_at = excluded.bot_paused_at,
+			bot_idle_timeout = excluded.bot_idle_timeout,
+			updated_at = excluded.updated_at`,
+		chat.String(), paused, pausedAt, int64(idleTimeout/time.Second), time.Now().Unix())
+	return err
+}
+
+func resumeBot(ctx context.Context, chat types.JID, reason string) {
+	if err := setBotPaused(ctx, chat, false, 0); err != nil {
+		waLogger.Errorf("Failed to resume bot in %s: %v", chat, err)
+		return
+	}
+	emitWebhook("bot.resumed", map[string]string{"jid": chat.String(), "reason": reason})
+}
+
+// resumeIdleBots periodically hands idle paused chats back to the bot so the
+// bot.resumed webhook fires even if no message arrives.
+func resumeIdleBots() {
+	for range time.Tick(time.Minute) {
+		ctx := context.Background()
+		rows, err := gatewayDB.QueryContext(ctx, `SELECT jid FROM chats WHERE bot_paused = 1`)
+		if err != nil {
+			waLogger.Errorf("Failed to list paused chats: %v", err)
+			continue
+		}
+		var chats []types.JID
+		for rows.Next() {
+			var s string
+			if rows.Scan(&s) == nil {
+				if jid, err := types.ParseJID(s); err == nil {
+					chats = append(chats, jid)
+				}
+			}
+		}
+		rows.Close()
+		for _, chat := range chats {
+			botEnabled(ctx, chat)
+		}
+	}
+}
+
+func currentBotState(ctx context.Context, chat types.JID) (botState, error) {
+	p, err := loadBotPause(ctx, chat)
+	if err != nil {
+		return botState{}, err
+	}
+	state := botState{JID: chat.String(), Enabled: !p.paused}
+	if p.paused {
+		pausedAt := time.Unix(p.pausedAt, 0).UTC()
+		resumes := p.resumesAt().UTC()
+		state.PausedAt = &pausedAt
+		state.ResumesAt = &resumes
+		state.IdleTimeout = p.idleTimeout.String()
+	}
+	return state, nil
+}
+
+func getBotState(w http.ResponseWriter, r *http.Request) {
+	jid, ok := parseJID(r.PathValue("jid"))
+	if !ok {
+		http.Error(w, "Invalid JID", http.StatusBadRequest)
+		return
+	}
+	chat := canonicalJID(r.Context(), jid)
+	botEnabled(r.Context(), chat) // apply a due idle resume first
+	state, err := currentBotState(r.Context(), chat)
+	if err != nil {
+		waLogger.Errorf("Failed to load bot state of %s: %v", chat, err)
+		http.Error(w, "Failed to load bot state", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, state)
+}
+
+type botStateRequest struct {
+	Enabled     bool   `json:"enabled"`
+	IdleTimeout string `json:"idle_timeout,omitempty"` // e.g. "2h"; defaults to BOT_PAUSE_IDLE_TIMEOUT
+}
+
+func putBotState(w http.ResponseWriter, r *http.Request) {
+	jid, ok := parseJID(r.PathValue("jid"))
+	if !ok {
+		http.Error(w, "Invalid JID", http.StatusBadRequest)
+		return
+	}
+	var reqBody botStateRequest
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	var idle time.Duration
+	if reqBody.IdleTimeout != "" {
+		d, err := time.ParseDuration(reqBody.IdleTimeout)
+		if err != nil || d <= 0 {
+			http.Error(w, "Invalid idle_timeout", http.StatusBadRequest)
+			return
+		}
+		idle = d
+	}
+	chat := canonicalJID(r.Context(), jid)
+	if err := setBotPaused(r.Context(), chat, !reqBody.Enabled, idle); err != nil {
+		waLogger.Errorf("Failed to update bot state of %s: %v", chat, err)
+		http.Error(w, "Failed to update bot state", http.StatusInternalServerError)
+		return
+	}
+	event := "bot.paused"
+	if reqBody.Enabled {
+		event = "bot.resumed"
+	}
+	emitWebhook(event, map[string]string{"jid": chat.String(), "reason": "api"})
+	state, err := currentBotState(r.Context(), chat)
+	if err != nil {
+		waLogger.Errorf("Failed to load bot state of %s: %v", chat, err)
+		http.Error(w, "Failed to load bot state", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, state)
+}
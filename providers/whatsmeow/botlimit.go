@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"google.golang.org/protobuf/proto"
+)
+
+// Bot trigger rate limiting stops reply loops, such as two bots answering
+// each other: every inbound message handed to the bot (canned auto-replies
+// here, LLM responders behind the webhook) counts towards its chat's limit of
+// BOT_RATE_LIMIT per BOT_RATE_WINDOW. Past it the chat cools down for
+// BOT_COOLDOWN: messages arrive with bot_enabled false and
+// bot_cooldown_until, nothing is auto-replied, and BOT_COOLDOWN_MESSAGE (if
+// set) is sent once to say so. bot.rate_limited fires when a cooldown starts.
+
+var (
+	botRateLimit       = envInt("BOT_RATE_LIMIT", 10) // 0 disables
+	botRateWindow      = envDuration("BOT_RATE_WINDOW", time.Minute)
+	botCooldown        = envDuration("BOT_COOLDOWN", 5*time.Minute)
+	botCooldownMessage = envString("BOT_COOLDOWN_MESSAGE", "")
+)
+
+type botTriggers struct {
+	hits          []time.Time // within the window, oldest first
+	cooldownUntil time.Time
+}
+
+var (
+	botTriggersMu sync.Mutex
+	botTriggersBy = make(map[string]*botTriggers)
+	botSweep      time.Time
+)
+
+// throttleBot counts a bot trigger in a chat. It reports whether the chat is
+// cooling down instead, and until when; started is true for the trigger that
+// began the cooldown.
+func throttleBot(chat types.JID) (until time.Time, limited, started bool) {
+	if botRateLimit <= 0 {
+		return time.Time{}, false, false
+	}
+	key := chat.ToNonAD().String()
+	now := time.Now()
+	botTriggersMu.Lock()
+	defer botTriggersMu.Unlock()
+	if now.Sub(botSweep) > botRateWindow {
+		for k, t := range botTriggersBy {
+			if now.After(t.cooldownUntil) && (len(t.hits) == 0 || now.Sub(t.hits[len(t.hits)-1]) > botRateWindow) {
+				delete(botTriggersBy, k)
+			}
+		}
+		botSweep = now
+	}
+	t := botTriggersBy[key]
+	if t == nil {
+		t = &botTriggers{}
+		botTriggersBy[key] = t
+	}
+	if now.Before(t.cooldownUntil) {
+		return t.cooldownUntil, true, false
+	}
+	kept := t.hits[:0]
+	for _, at := range t.hits {
+		if now.Sub(at) <= botRateWindow {
+			kept = append(kept, at)
+		}
+	}
+	t.hits = kept
+	if len(t.hits) >= botRateLimit {
+		t.hits = nil
+		t.cooldownUntil = now.Add(botCooldown)
+		return t.cooldownUntil, true, true
+	}
+	t.hits = append(t.hits, now)
+	return time.Time{}, false, false
+}
+
+// startBotCooldown announces a chat's cooldown, in the chat if
+// BOT_COOLDOWN_MESSAGE is set and with the bot.rate_limited webhook.
+func startBotCooldown(chat types.JID, until time.Time) {
+	waLogger.Warnf("Bot triggers in %s exceeded %d per %s, cooling down until %s", chat, botRateLimit, botRateWindow, until.Format(time.RFC3339))
+	if botCooldownMessage != "" {
+		msg := &waE2E.Message{Conversation: proto.String(botCooldownMessage)}
+		if _, err := sendOrQueue(context.Background(), chat, msg, sendOptions{}); err != nil && !errors.Is(err, errDuplicateContent) {
+			waLogger.Errorf("Failed to send cooldown message to %s: %v", chat, err)
+		}
+	}
+	emitWebhook("bot.rate_limited", map[string]interface{}{
+		"jid":            chat.String(),
+		"limit":          botRateLimit,
+		"window":         botRateWindow.String(),
+		"cooldown_until": until.UTC(),
+	})
+}
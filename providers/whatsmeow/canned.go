@@ -0,0 +1,399 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"golang.org/x/text/language"
+	"google.golang.org/protobuf/proto"
+)
+
+// Canned responses are stock replies kept under a key, with one text per
+// language. The variant is picked for each recipient: the locale stored on
+// their gateway contact (the "locale" attribute) comes first, then the
+// language detected in their chat, then the session locale; without a match
+// the first variant is sent. Texts may use contact placeholders.
+//
+// A canned response with triggers is also an auto-reply: a private inbound
+// message consisting of just one of the keywords is answered with it, unless
+// an agent has taken over the chat.
+
+type cannedResponse struct {
+	Key       string            `json:"key"`
+	Variants  map[string]string `json:"variants"` // language tag -> text
+	Triggers  []string          `json:"triggers,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+var cannedKeyRe = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]{0,63}$`)
+
+var errNoCannedVariant = errors.New("canned response has no variant in the requested language")
+
+func loadCannedResponse(ctx context.Context, key string) (cannedResponse, error) {
+	resp := cannedResponse{Key: key, Variants: map[string]string{}}
+	var triggers string
+	var created, updated int64
+	err := gatewayDB.QueryRowContext(ctx,
+		`SELECT triggers, created_at, updated_at FROM canned_responses WHERE key = ?`, key).
+		Scan(&triggers, &created, &updated)
+	if err != nil {
+		return resp, err
+	}
+	json.Unmarshal([]byte(triggers), &resp.Triggers)
+	resp.CreatedAt = time.Unix(created, 0).UTC()
+	resp.UpdatedAt = time.Unix(updated, 0).UTC()
+	rows, err := gatewayDB.QueryContext(ctx,
+		`SELECT language, text FROM canned_response_variants WHERE key = ?`, key)
+	if err != nil {
+		return resp, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var lang, text string
+		if err := rows.Scan(&lang, &text); err != nil {
+			return resp, err
+		}
+		resp.Variants[lang] = text
+	}
+	return resp, rows.Err()
+}
+
+// recipientLanguages lists the recipient's languages in order of preference.
+func recipientLanguages(ctx context.Context, to types.JID) []language.Tag {
+	var prefs []language.Tag
+	add := func(s string) {
+		if s == "" {
+			return
+		}
+		if tag, err := language.Parse(s); err == nil {
+			prefs = append(prefs, tag)
+		}
+	}
+	if contact, err := getGatewayContact(ctx, to); err == nil {
+		if locale, ok := contact.Attributes["locale"].(string); ok {
+			add(locale)
+		}
+	}
+	if chat, err := getChat(ctx, canonicalJID(ctx, to)); err == nil {
+		add(chat.Language)
+	}
+	localeMu.RLock()
+	add(localeCurrent.Locale)
+	localeMu.RUnlock()
+	return prefs
+}
+
+// variant picks the text for the first preferred language with a variant,
+// falling back to the first variant; matched is false for the fallback.
+// Regional variants match their base language either way, so a pt-BR
+// contact gets "pt" and a "pt" contact gets "pt-BR" if that's all there is.
+func (resp cannedResponse) variant(prefs []language.Tag) (lang, text string, matched bool) {
+	if len(resp.Variants) == 0 {
+		return "", "", false
+	}
+	langs := make([]string, 0, len(resp.Variants))
+	for lang := range resp.Variants {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	tags := make([]language.Tag, len(langs))
+	for i, lang := range langs {
+		tags[i] = language.Make(lang)
+	}
+	matcher := language.NewMatcher(tags)
+	for _, pref := range prefs {
+		if _, i, conf := matcher.Match(pref); conf >= language.High {
+			return langs[i], resp.Variants[langs[i]], true
+		}
+	}
+	return langs[0], resp.Variants[langs[0]], false
+}
+
+// cannedMessage builds the canned response for a recipient and returns the
+// language of the chosen variant. lang forces a language instead of the
+// recipient's; it's an error if there's no variant for it.
+func cannedMessage(ctx context.Context, key string, to types.JID, lang string) (*waE2E.Message, string, error) {
+	resp, err := loadCannedResponse(ctx, key)
+	if err != nil {
+		return nil, "", err
+	}
+	prefs := recipientLanguages(ctx, to)
+	if lang != "" {
+		tag, err := language.Parse(lang)
+		if err != nil {
+			return nil, "", fmt.Errorf("%w: %q", errNoCannedVariant, lang)
+		}
+		prefs = []language.Tag{tag}
+	}
+	chosen, text, matched := resp.variant(prefs)
+	if chosen == "" {
+		return nil, "", errNoCannedVariant
+	}
+	if lang != "" && !matched {
+		return nil, "", fmt.Errorf("%w: %q", errNoCannedVariant, lang)
+	}
+	return &waE2E.Message{Conversation: proto.String(text)}, chosen, nil
+}
+
+// normalizeTrigger is how both triggers and inbound texts are compared.
+func normalizeTrigger(s string) string {
+	return strings.ToLower(strings.Trim(s, " \t\r\n.!?¡¿"))
+}
+
+// autoReplyCanned answers a private message matching a canned response
+// trigger. The first response by key wins if several share a keyword.
+func autoReplyCanned(data *messageWebhookData, enabled bool) {
+	if !enabled || data.Info.IsFromMe || data.Info.IsGroup || gatewayDB == nil {
+		return
+	}
+	text := normalizeTrigger(messageText(data.Message.Message))
+	if text == "" {
+		return
+	}
+	ctx := context.Background()
+	rows, err := gatewayDB.QueryContext(ctx,
+		`SELECT key, triggers FROM canned_responses WHERE triggers != '[]' ORDER BY key`)
+	if err != nil {
+		waLogger.Errorf("Failed to load canned response triggers: %v", err)
+		return
+	}
+	key := ""
+	for rows.Next() && key == "" {
+		var k, raw string
+		var triggers []string
+		if rows.Scan(&k, &raw) != nil || json.Unmarshal([]byte(raw), &triggers) != nil {
+			continue
+		}
+		for _, trigger := range triggers {
+			if trigger == text {
+				key = k
+				break
+			}
+		}
+	}
+	rows.Close()
+	if key == "" {
+		return
+	}
+	to := data.Info.Chat
+	msg, lang, err := cannedMessage(ctx, key, to, "")
+	if err != nil {
+		waLogger.Errorf("Failed to build canned response %q for %s: %v", key, to, err)
+		return
+	}
+	res, err := sendOrQueue(ctx, to, msg, sendOptions{})
+	if errors.Is(err, errDuplicateContent) {
+		return // already answered recently
+	} else if err != nil {
+		waLogger.Errorf("Failed to auto-reply with canned response %q to %s: %v", key, to, err)
+		return
+	}
+	emitWebhook("message.auto_reply", map[string]interface{}{
+		"key":        key,
+		"language":   lang,
+		"chat":       to.String(),
+		"message_id": data.Info.ID,
+		"reply_id":   res.ID,
+	})
+}
+
+func listCannedResponses(w http.ResponseWriter, r *http.Request) {
+	rows, err := gatewayDB.QueryContext(r.Context(), `SELECT key FROM canned_responses ORDER BY key`)
+	if err != nil {
+		waLogger.Errorf("Failed to list canned responses: %v", err)
+		http.Error(w, "Failed to list canned responses", http.StatusInternalServerError)
+		return
+	}
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			rows.Close()
+			waLogger.Errorf("Failed to scan canned response: %v", err)
+			http.Error(w, "Failed to list canned responses", http.StatusInternalServerError)
+			return
+		}
+		keys = append(keys, key)
+	}
+	rows.Close()
+	responses := []cannedResponse{}
+	for _, key := range keys {
+		resp, err := loadCannedResponse(r.Context(), key)
+		if err != nil {
+			waLogger.Errorf("Failed to load canned response %q: %v", key, err)
+			http.Error(w, "Failed to list canned responses", http.StatusInternalServerError)
+			return
+		}
+		responses = append(responses, resp)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"responses": responses})
+}
+
+func getCannedResponse(w http.ResponseWriter, r *http.Request) {
+	resp, err := loadCannedResponse(r.Context(), r.PathValue("key"))
+	if err == sql.ErrNoRows {
+		http.Error(w, "Canned response not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		waLogger.Errorf("Failed to load canned response %q: %v", r.PathValue("key"), err)
+		http.Error(w, "Failed to load canned response", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// putCannedResponse creates or replaces a canned response with all its
+// variants.
+func putCannedResponse(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	if !cannedKeyRe.MatchString(key) {
+		http.Error(w, "Invalid key, expected lowercase letters, digits, '_', '.' and '-'", http.StatusBadRequest)
+		return
+	}
+	var req cannedResponse
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(req.Variants) == 0 {
+		http.Error(w, "At least one variant is required", http.StatusBadRequest)
+		return
+	}
+	variants := map[string]string{}
+	for lang, text := range req.Variants {
+		tag, err := language.Parse(lang)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid language %q, expected a BCP 47 tag such as pt-BR", lang), http.StatusBadRequest)
+			return
+		}
+		if strings.TrimSpace(text) == "" {
+			http.Error(w, fmt.Sprintf("Variant %q is empty", lang), http.StatusBadRequest)
+			return
+		}
+		if _, dup := variants[tag.String()]; dup {
+			http.Error(w, fmt.Sprintf("Duplicate variant %q", tag), http.StatusBadRequest)
+			return
+		}
+		variants[tag.String()] = text
+	}
+	triggers := []string{}
+	for _, trigger := range req.Triggers {
+		if t := normalizeTrigger(trigger); t != "" {
+			triggers = append(triggers, t)
+		}
+	}
+	rawTriggers, _ := json.Marshal(triggers)
+
+	tx, err := gatewayDB.BeginTx(r.Context(), nil)
+	if err != nil {
+		waLogger.Errorf("Failed to save canned response %q: %v", key, err)
+		http.Error(w, "Failed to save canned response", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	now := time.Now().Unix()
+	_, err = tx.ExecContext(r.Context(), `
+		INSERT INTO canned_responses (key, triggers, created_at, updated_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (key) DO UPDATE SET triggers = excluded.triggers, updated_at = excluded.updated_at`,
+		key, string(rawTriggers), now, now)
+	if err == nil {
+		_, err = tx.ExecContext(r.Context(), `DELETE FROM canned_response_variants WHERE key = ?`, key)
+	}
+	for lang, text := range variants {
+		if err != nil {
+			break
+		}
+		_, err = tx.ExecContext(r.Context(),
+			`INSERT INTO canned_response_variants (key, language, text) VALUES (?, ?, ?)`, key, lang, text)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		waLogger.Errorf("Failed to save canned response %q: %v", key, err)
+		http.Error(w, "Failed to save canned response", http.StatusInternalServerError)
+		return
+	}
+	getCannedResponse(w, r)
+}
+
+func deleteCannedResponse(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	res, err := gatewayDB.ExecContext(r.Context(), `DELETE FROM canned_responses WHERE key = ?`, key)
+	if err == nil {
+		_, err = gatewayDB.ExecContext(r.Context(), `DELETE FROM canned_response_variants WHERE key = ?`, key)
+	}
+	if err != nil {
+		waLogger.Errorf("Failed to delete canned response %q: %v", key, err)
+		http.Error(w, "Failed to delete canned response", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		http.Error(w, "Canned response not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+type sendCannedRequest struct {
+	To             string `json:"to"`
+	Language       string `json:"language,omitempty"` // overrides the recipient's language
+	AllowDuplicate bool   `json:"allow_duplicate,omitempty"`
+}
+
+// sendCanned sends a canned response in the recipient's language. The chosen
+// variant is reported in the Content-Language header.
+func sendCanned(w http.ResponseWriter, r *http.Request) {
+	if sessionArchived() {
+		http.Error(w, "Session is archived", http.StatusConflict)
+		return
+	}
+	if !requireClient(w, r, true) {
+		return
+	}
+	var req sendCannedRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	recipient, err := parseRecipient(req.To)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	recipient = toPhoneJID(r.Context(), recipient)
+
+	key := r.PathValue("key")
+	msg, lang, err := cannedMessage(r.Context(), key, recipient, req.Language)
+	switch {
+	case err == sql.ErrNoRows:
+		http.Error(w, "Canned response not found", http.StatusNotFound)
+		return
+	case errors.Is(err, errNoCannedVariant):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	case err != nil:
+		waLogger.Errorf("Failed to load canned response %q: %v", key, err)
+		http.Error(w, "Failed to load canned response", http.StatusInternalServerError)
+		return
+	}
+	// The variant is already in the recipient's language; no translation.
+	res, err := sendOrQueue(r.Context(), recipient, msg, sendOptions{AllowDuplicate: req.AllowDuplicate})
+	if err != nil {
+		writeSendError(w, recipient, err)
+		return
+	}
+	w.Header().Set("Content-Language", lang)
+	writeSendResult(w, recipient, res)
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+type chatRecord struct {
+	JID            string     `json:"jid"`
+	Name           string     `json:"name,omitempty"`
+	Status         string     `json:"status"`
+	Assignee       string     `json:"assignee,omitempty"`
+	LastMessageAt  *time.Time `json:"last_message_at,omitempty"`
+	LastInboundAt  *time.Time `json:"last_inbound_at,omitempty"`
+	LastOutboundAt *time.Time `json:"last_outbound_at,omitempty"`
+	Language       string     `json:"language,omitempty"` // detected from inbound messages
+	Tags           []string   `json:"tags"`
+
+	DisappearingTimer uint32 `json:"disappearing_timer,omitempty"` // seconds
+
+}
+
+const chatColumns = `jid, name, status, assignee, last_message_at, last_inbound_at, last_outbound_at, language, disappearing_timer,
+	(SELECT group_concat(tag, ',') FROM chat_tags WHERE chat_jid = chats.jid)`
+
+func scanChat(scan func(dest ...interface{}) error) (chatRecord, error) {
+	var c chatRecord
+	var lastMsg, lastIn, lastOut int64
+	var tags sql.NullString
+	if err := scan(&c.JID, &c.Name, &c.Status, &c.Assignee, &lastMsg, &lastIn, &lastOut, &c.Language, &c.DisappearingTimer, &tags); err != nil {
+		return c, err
+	}
+	c.Tags = splitTags(tags.String)
+	c.LastMessageAt = unixPtr(lastMsg)
+	c.LastInboundAt = unixPtr(lastIn)
+	c.LastOutboundAt = unixPtr(lastOut)
+	return c, nil
+}
+
+func unixPtr(ts int64) *time.Time {
+	if ts == 0 {
+		return nil
+	}
+	t := time.Unix(ts, 0).UTC()
+	return &t
+}
+
+func getChat(ctx context.Context, jid types.JID) (chatRecord, error) {
+	row := gatewayDB.QueryRowContext(ctx, `SELECT `+chatColumns+` FROM chats WHERE jid = ?`, jid.String())
+	return scanChat(row.Scan)
+}
+
+// touchChat records message activity on a chat. An inbound message on a
+// resolved conversation reopens it.
+func touchChat(ctx context.Context, chat types.JID, name string, inbound bool, ts time.Time) {
+	if gatewayDB == nil {
+		return
+	}
+	prev, err := getChat(ctx, chat)
+	if err != nil && err != sql.ErrNoRows {
+		waLogger.Errorf("Failed to load chat %s: %v", chat, err)
+		return
+	}
+	column := "last_outbound_at"
+	if inbound {
+		column = "last_inbound_at"
+	}
+	_, err = gatewayDB.ExecContext(ctx, `
+		INSERT INTO chats (jid, name, status, assignee, last_message_at, `+column+`, updated_at)
+		VALUES (?, ?, 'open', '', ?, ?, ?)
+		ON CONFLICT (jid) DO UPDATE SET
+			name = CASE WHEN excluded.name <> '' THEN excluded.name ELSE chats.name END,
+			last_message_at = MAX(chats.last_message_at, excluded.last_message_at),
+			`+column+` = MAX(chats.`+column+`, excluded.`+column+`),
+			status = CASE WHEN ? AND chats.status = 'resolved' THEN 'open' ELSE chats.status END,
+			updated_at = excluded.updated_at`,
+		chat.String(), name, ts.Unix(), ts.Unix(), time.Now().Unix(), inbound)
+	if err != nil {
+		waLogger.Errorf("Failed to update chat %s: %v", chat, err)
+		return
+	}
+	recordConversationActivity(ctx, chat, inbound, ts)
+	if inbound && prev.Status == "resolved" {
+		emitWebhook("conversation.status_changed", map[string]string{
+			"jid":    chat.String(),
+			"from":   prev.Status,
+			"status": "open",
+			"reason": "inbound_message",
+		})
+	}
+}
+
+func listChats(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	query := `SELECT ` + chatColumns + ` FROM chats WHERE 1 = 1`
+	var args []interface{}
+	if status := q.Get("status"); status != "" {
+		if !validInboxStatus(status) {
+			http.Error(w, "Invalid status, expected open, pending or resolved", http.StatusBadRequest)
+			return
+		}
+		query += ` AND status = ?`
+		args = append(args, status)
+	}
+	if assignee := q.Get("assignee"); assignee != "" {
+		query += ` AND assignee = ?`
+		args = append(args, assignee)
+	}
+	if unassigned, _ := strconv.ParseBool(q.Get("unassigned")); unassigned {
+		query += ` AND assignee = ''`
+	}
+	for _, tag := range q["tag"] {
+		query += ` AND jid IN (SELECT chat_jid FROM chat_tags WHERE tag = ?)`
+		args = append(args, normalizeTag(tag))
+	}
+	limit := 100
+	if l := q.Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 || n > 1000 {
+			http.Error(w, "Invalid limit, expected 1-1000", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	query += ` ORDER BY last_message_at DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := gatewayDB.QueryContext(r.Context(), query, args...)
+	if err != nil {
+		waLogger.Errorf("Failed to list chats: %v", err)
+		http.Error(w, "Failed to list chats", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	chats := []chatRecord{}
+	for rows.Next() {
+		c, err := scanChat(rows.Scan)
+		if err != nil {
+			waLogger.Errorf("Failed to scan chat row: %v", err)
+			http.Error(w, "Failed to list chats", http.StatusInternalServerError)
+			return
+		}
+		chats = append(chats, c)
+	}
+	if err := rows.Err(); err != nil {
+		waLogger.Errorf("Failed to read chat rows: %v", err)
+		http.Error(w, "Failed to list chats", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"chats": chats})
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/proto/waWa6"
+	"go.mau.fi/whatsmeow/store"
+	"google.golang.org/protobuf/proto"
+)
+
+// The client version is the WhatsApp Web version the gateway claims to be.
+// whatsmeow ships with a recent one, but WhatsApp retires old versions
+// between releases: connecting then fails with ClientOutdated. WA_VERSION or
+// PUT /admin/client-version set a newer one without a rebuild, and with
+// WA_VERSION_AUTO_UPDATE the current version is fetched from WhatsApp Web and
+// saved when that happens. A configured version older than whatsmeow's own
+// is ignored, so upgrading the gateway never goes back to a retired version.
+//
+// The user agent parameters (WA_PLATFORM, WA_OS_VERSION, WA_MANUFACTURER,
+// WA_DEVICE) default to whatsmeow's web client values; they're sent on every
+// connect, unlike the device name and platform shown on the phone.
+
+var (
+	builtinWAVersion    = store.GetWAVersion()
+	waVersionAutoUpdate = envBool("WA_VERSION_AUTO_UPDATE")
+)
+
+var (
+	clientVersionMu     sync.Mutex
+	clientVersionSource = "default" // default, env or saved
+	clientOutdatedAt    time.Time
+)
+
+type clientVersionInfo struct {
+	Version      string     `json:"version"`
+	Builtin      string     `json:"builtin"` // whatsmeow's
+	Source       string     `json:"source"`  // default, env or saved
+	AutoUpdate   bool       `json:"auto_update"`
+	OutdatedAt   *time.Time `json:"outdated_at,omitempty"` // last rejection by WhatsApp
+	Platform     string     `json:"platform"`
+	OSVersion    string     `json:"os_version,omitempty"`
+	Manufacturer string     `json:"manufacturer,omitempty"`
+	Device       string     `json:"device,omitempty"`
+}
+
+// olderVersion reports whether a is older than b.
+func olderVersion(a, b store.WAVersionContainer) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return false
+}
+
+// setClientVersion switches to a version, unless it's older than whatsmeow's.
+// It takes effect on the next connect.
+func setClientVersion(v store.WAVersionContainer, source string) bool {
+	if olderVersion(v, builtinWAVersion) {
+		waLogger.Warnf("Ignoring %s client version %s, older than whatsmeow's %s", source, v, builtinWAVersion)
+		return false
+	}
+	store.SetWAVersion(v)
+	clientVersionMu.Lock()
+	clientVersionSource = source
+	clientVersionMu.Unlock()
+	return true
+}
+
+// applyClientVersion applies the configured version and user agent before
+// connecting. A saved version beats WA_VERSION.
+func applyClientVersion(ctx context.Context) {
+	ua := store.BaseClientPayload.UserAgent
+	if p := envString("WA_PLATFORM", ""); p != "" {
+		if v, ok := waWa6.ClientPayload_UserAgent_Platform_value[strings.ToUpper(p)]; ok {
+			ua.Platform = waWa6.ClientPayload_UserAgent_Platform(v).Enum()
+		} else {
+			waLogger.Warnf("Ignoring unknown client platform %q", p)
+		}
+	}
+	if v := envString("WA_OS_VERSION", ""); v != "" {
+		ua.OsVersion = proto.String(v)
+	}
+	if v := envString("WA_MANUFACTURER", ""); v != "" {
+		ua.Manufacturer = proto.String(v)
+	}
+	if v := envString("WA_DEVICE", ""); v != "" {
+		ua.Device = proto.String(v)
+	}
+
+	for _, c := range []struct{ source, raw string }{
+		{"saved", getSetting(ctx, "wa_version", "")},
+		{"env", envString("WA_VERSION", "")},
+	} {
+		if c.raw == "" {
+			continue
+		}
+		v, err := store.ParseVersion(c.raw)
+		if err != nil {
+			waLogger.Warnf("Ignoring invalid %s client version %q: %v", c.source, c.raw, err)
+			continue
+		}
+		if setClientVersion(v, c.source) {
+			return
+		}
+	}
+}
+
+func currentClientVersion() clientVersionInfo {
+	ua := store.BaseClientPayload.GetUserAgent()
+	clientVersionMu.Lock()
+	defer clientVersionMu.Unlock()
+	info := clientVersionInfo{
+		Version:      store.GetWAVersion().String(),
+		Builtin:      builtinWAVersion.String(),
+		Source:       clientVersionSource,
+		AutoUpdate:   waVersionAutoUpdate,
+		Platform:     strings.ToLower(ua.GetPlatform().String()),
+		OSVersion:    ua.GetOsVersion(),
+		Manufacturer: ua.GetManufacturer(),
+		Device:       ua.GetDevice(),
+	}
+	if !clientOutdatedAt.IsZero() {
+		at := clientOutdatedAt.UTC()
+		info.OutdatedAt = &at
+	}
+	return info
+}
+
+// saveClientVersion switches to a version, saves it and reconnects with it.
+func saveClientVersion(ctx context.Context, v store.WAVersionContainer) error {
+	if err := setSetting(ctx, "wa_version", v.String()); err != nil {
+		return err
+	}
+	setClientVersion(v, "saved")
+	if client != nil && client.Store.ID != nil && !sessionArchived() {
+		client.Disconnect()
+		if err := client.Connect(); err != nil {
+			waLogger.Errorf("Failed to reconnect with client version %s: %v", v, err)
+		}
+	}
+	return nil
+}
+
+// handleClientOutdated runs when WhatsApp rejects the client version. The
+// client.outdated webhook says whether the gateway could update itself.
+func handleClientOutdated() {
+	current := store.GetWAVersion()
+	waLogger.Errorf("WhatsApp rejected client version %s as outdated", current)
+	clientVersionMu.Lock()
+	clientOutdatedAt = time.Now()
+	clientVersionMu.Unlock()
+	data := map[string]interface{}{"version": current.String(), "auto_update": waVersionAutoUpdate}
+	if waVersionAutoUpdate {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		latest, err := whatsmeow.GetLatestVersion(ctx, http.DefaultClient)
+		switch {
+		case err != nil:
+			waLogger.Errorf("Failed to fetch the latest client version: %v", err)
+			data["error"] = err.Error()
+		case !olderVersion(current, *latest):
+			data["error"] = "no newer version is published, set one with PUT /admin/client-version"
+		default:
+			waLogger.Infof("Updating client version from %s to %s", current, latest)
+			if err := saveClientVersion(ctx, *latest); err != nil {
+				waLogger.Errorf("Failed to save client version %s: %v", latest, err)
+				data["error"] = err.Error()
+			} else {
+				data["updated_to"] = latest.String()
+			}
+		}
+	}
+	emitWebhook("client.outdated", data)
+}
+
+func getClientVersion(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, currentClientVersion())
+}
+
+type clientVersionRequest struct {
+	Version string `json:"version"` // e.g. 2.3000.1029000000, "latest", or empty for the default
+}
+
+// putClientVersion overrides the client version and reconnects with it.
+func putClientVersion(w http.ResponseWriter, r *http.Request) {
+	var req clientVersionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	var v store.WAVersionContainer
+	switch req.Version {
+	case "":
+		// Back to WA_VERSION or whatsmeow's, from the next connect.
+		if err := setSetting(r.Context(), "wa_version", ""); err != nil {
+			waLogger.Errorf("Failed to clear client version: %v", err)
+			http.Error(w, "Failed to save client version", http.StatusInternalServerError)
+			return
+		}
+		store.SetWAVersion(builtinWAVersion)
+		clientVersionMu.Lock()
+		clientVersionSource = "default"
+		clientVersionMu.Unlock()
+		applyClientVersion(r.Context())
+		writeJSON(w, http.StatusOK, currentClientVersion())
+		return
+	case "latest":
+		latest, err := whatsmeow.GetLatestVersion(r.Context(), http.DefaultClient)
+		if err != nil {
+			waLogger.Errorf("Failed to fetch the latest client version: %v", err)
+			http.Error(w, "Failed to fetch the latest version from WhatsApp Web", http.StatusBadGateway)
+			return
+		}
+		v = *latest
+	default:
+		parsed, err := store.ParseVersion(req.Version)
+		if err != nil {
+			http.Error(w, "Invalid version, expected e.g. 2.3000.1029000000", http.StatusBadRequest)
+			return
+		}
+		v = parsed
+	}
+	if olderVersion(v, builtinWAVersion) {
+		http.Error(w, "Version is older than the built-in "+builtinWAVersion.String(), http.StatusUnprocessableEntity)
+		return
+	}
+	if err := saveClientVersion(r.Context(), v); err != nil {
+		waLogger.Errorf("Failed to save client version %s: %v", v, err)
+		http.Error(w, "Failed to save client version", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, currentClientVersion())
+}
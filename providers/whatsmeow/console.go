@@ -0,0 +1,107 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"io/fs"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// /console/ is a small web console built into the binary, for trying an
+// instance out before there's a frontend: it shows the pairing QR code, the
+// connection status and the most recent events, and sends test messages.
+// The page itself is static; everything it shows comes from the admin
+// endpoints below, called with the admin key the operator enters. That
+// includes the QR code, served again at /admin/console/qr as the page is
+// usually reachable by more people than the unauthenticated /qr.
+
+//go:embed console
+var consoleFiles embed.FS
+
+// consoleEventLimit is how many events the console keeps for display.
+const consoleEventLimit = 200
+
+type consoleEvent struct {
+	ID      int64           `json:"id"`
+	Event   string          `json:"event"`
+	Payload json.RawMessage `json:"payload"`
+	At      time.Time       `json:"at"`
+}
+
+var (
+	consoleEventsMu sync.Mutex
+	consoleEvents   []consoleEvent
+	consoleEventSeq int64
+)
+
+// recordConsoleEvent keeps an emitted webhook for the console.
+func recordConsoleEvent(event string, body []byte) {
+	consoleEventsMu.Lock()
+	defer consoleEventsMu.Unlock()
+	consoleEventSeq++
+	consoleEvents = append(consoleEvents, consoleEvent{ID: consoleEventSeq, Event: event, Payload: body, At: time.Now().UTC()})
+	if len(consoleEvents) > consoleEventLimit {
+		consoleEvents = consoleEvents[len(consoleEvents)-consoleEventLimit:]
+	}
+}
+
+func consoleHandler() http.Handler {
+	files, err := fs.Sub(consoleFiles, "console")
+	if err != nil {
+		panic(err)
+	}
+	return http.StripPrefix("/console/", http.FileServerFS(files))
+}
+
+type consoleState struct {
+	Connected   bool             `json:"connected"`
+	LoggedIn    bool             `json:"logged_in"`
+	PhoneID     string           `json:"phone_id,omitempty"`
+	PushName    string           `json:"push_name,omitempty"`
+	QRAvailable bool             `json:"qr_available"`
+	OfflineSync offlineSyncState `json:"offline_sync"`
+	Version     string           `json:"version"`
+	Uptime      string           `json:"uptime"`
+}
+
+func getConsoleState(w http.ResponseWriter, r *http.Request) {
+	state := consoleState{
+		Connected:   client != nil && client.IsConnected(),
+		OfflineSync: currentOfflineSync(),
+		Version:     version,
+		Uptime:      time.Since(startTime).Round(time.Second).String(),
+	}
+	if client != nil && client.Store.ID != nil {
+		state.LoggedIn = true
+		state.PhoneID = client.Store.ID.String()
+		state.PushName = client.Store.PushName
+	}
+	qrCodeMutex.RLock()
+	state.QRAvailable = qrCodeStr != ""
+	qrCodeMutex.RUnlock()
+	writeJSON(w, http.StatusOK, state)
+}
+
+// listConsoleEvents returns the kept events newer than ?after, oldest first.
+func listConsoleEvents(w http.ResponseWriter, r *http.Request) {
+	var after int64
+	if a := r.URL.Query().Get("after"); a != "" {
+		var err error
+		if after, err = strconv.ParseInt(a, 10, 64); err != nil {
+			http.Error(w, "Invalid after", http.StatusBadRequest)
+			return
+		}
+	}
+	consoleEventsMu.Lock()
+	events := []consoleEvent{}
+	for _, e := range consoleEvents {
+		if e.ID > after {
+			events = append(events, e)
+		}
+	}
+	consoleEventsMu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]interface{}{"events": events})
+}
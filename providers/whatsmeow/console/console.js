@@ -0,0 +1,180 @@
+// Gateway console: polls the admin console endpoints with the admin key kept
+// in sessionStorage. Kept dependency-free so it can be embedded as is.
+(function () {
+    'use strict';
+
+    const keyStorage = 'gateway-admin-key';
+    const pollInterval = 3000;
+    const maxEvents = 200;
+
+    const $ = (id) => document.getElementById(id);
+    let lastEventID = 0;
+    let timer = null;
+    let qrURL = null;
+
+    function adminKey() {
+        return sessionStorage.getItem(keyStorage) || '';
+    }
+
+    async function api(method, path, body) {
+        const headers = { 'X-Admin-Key': adminKey() };
+        if (body !== undefined) {
+            headers['Content-Type'] = 'application/json';
+        }
+        const res = await fetch(path, {
+            method,
+            headers,
+            body: body === undefined ? undefined : JSON.stringify(body),
+        });
+        if (res.status === 401) {
+            showLogin('That admin key was not accepted.');
+            throw new Error('unauthorized');
+        }
+        const text = await res.text();
+        if (!res.ok) {
+            throw new Error(text.trim() || res.statusText);
+        }
+        return text ? JSON.parse(text) : null;
+    }
+
+    // An <img> can't send the admin key, so the QR code is fetched and shown
+    // through an object URL.
+    async function loadQR() {
+        const res = await fetch('/admin/console/qr', { headers: { 'X-Admin-Key': adminKey() } });
+        if (!res.ok) {
+            return;
+        }
+        const url = URL.createObjectURL(await res.blob());
+        $('qr').src = url;
+        if (qrURL) {
+            URL.revokeObjectURL(qrURL);
+        }
+        qrURL = url;
+    }
+
+    function showLogin(message) {
+        clearTimeout(timer);
+        sessionStorage.removeItem(keyStorage);
+        $('console').hidden = true;
+        $('login').hidden = false;
+        $('login-error').hidden = !message;
+        $('login-error').textContent = message || '';
+        $('admin-key').focus();
+    }
+
+    function showConsole() {
+        $('login').hidden = true;
+        $('console').hidden = false;
+        lastEventID = 0;
+        $('events').replaceChildren();
+        poll();
+    }
+
+    function renderState(state) {
+        const status = $('status');
+        if (state.connected) {
+            status.textContent = 'connected';
+            status.className = 'badge online';
+        } else {
+            status.textContent = state.logged_in ? 'disconnected' : 'not paired';
+            status.className = 'badge offline';
+        }
+        const sync = state.offline_sync || {};
+        const rows = [
+            ['Number', state.phone_id || '—'],
+            ['Name', state.push_name || '—'],
+            ['Offline sync', sync.state + (sync.state === 'syncing' ? ' (' + sync.progress + '%)' : '')],
+            ['Version', state.version],
+            ['Uptime', state.uptime],
+        ];
+        $('session').replaceChildren(...rows.flatMap(([term, value]) => {
+            const dt = document.createElement('dt');
+            const dd = document.createElement('dd');
+            dt.textContent = term;
+            dd.textContent = value;
+            return [dt, dd];
+        }));
+        $('pairing').hidden = !state.qr_available;
+        if (state.qr_available) {
+            // The code rotates; reload it on every poll.
+            loadQR().catch(() => {});
+        }
+    }
+
+    function renderEvents(events) {
+        const list = $('events');
+        for (const e of events) {
+            const li = document.createElement('li');
+            const time = document.createElement('time');
+            time.textContent = new Date(e.at).toLocaleTimeString();
+            const name = document.createElement('strong');
+            name.textContent = e.event;
+            const payload = document.createElement('pre');
+            payload.textContent = JSON.stringify(e.payload.data ?? null);
+            li.append(time, name, payload);
+            list.prepend(li);
+            lastEventID = e.id;
+        }
+        while (list.children.length > maxEvents) {
+            list.lastElementChild.remove();
+        }
+    }
+
+    async function poll() {
+        clearTimeout(timer);
+        try {
+            const [state, events] = await Promise.all([
+                api('GET', '/admin/console/state'),
+                api('GET', '/admin/console/events?after=' + lastEventID),
+            ]);
+            renderState(state);
+            renderEvents(events.events);
+        } catch (err) {
+            if (err.message === 'unauthorized') {
+                return;
+            }
+            $('status').textContent = 'unreachable';
+            $('status').className = 'badge offline';
+        }
+        timer = setTimeout(poll, pollInterval);
+    }
+
+    $('login').addEventListener('submit', async (e) => {
+        e.preventDefault();
+        sessionStorage.setItem(keyStorage, $('admin-key').value);
+        try {
+            await api('GET', '/admin/console/state');
+            showConsole();
+        } catch (err) {
+            if (err.message !== 'unauthorized') {
+                showLogin(err.message);
+            }
+        }
+    });
+
+    $('logout').addEventListener('click', () => showLogin());
+
+    $('send').addEventListener('submit', async (e) => {
+        e.preventDefault();
+        const result = $('send-result');
+        result.className = '';
+        result.textContent = 'Sending…';
+        try {
+            const res = await api('POST', '/admin/console/send', {
+                to: $('send-to').value,
+                text: $('send-text').value,
+            });
+            result.textContent = (res.queued ? 'Queued: ' : 'Sent: ') + res.id;
+        } catch (err) {
+            result.className = 'error';
+            result.textContent = err.message;
+        }
+    });
+
+    if (adminKey()) {
+        showConsole();
+    } else {
+        // Without ADMIN_API_KEY the admin endpoints are open.
+        api('GET', '/admin/console/state').then(showConsole, () => showLogin());
+    }
+})();
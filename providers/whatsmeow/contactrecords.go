@@ -0,0 +1,427 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// Gateway contacts are the tenant's own contact records (name, phone and
+// free-form attributes), kept separately from the WhatsApp address book.
+// Attributes are available to template rendering and segment rules.
+
+type gatewayContact struct {
+	JID        string                 `json:"jid"`
+	Phone      string                 `json:"phone"`
+	Name       string                 `json:"name,omitempty"`
+	Attributes map[string]interface{} `json:"attributes"`
+	CreatedAt  time.Time              `json:"created_at"`
+	UpdatedAt  time.Time              `json:"updated_at"`
+
+	Engagement *engagementScore `json:"engagement,omitempty"` // with MESSAGE_STORE
+}
+
+const contactColumns = `jid, phone, name, attributes, created_at, updated_at`
+
+func scanGatewayContact(scan func(dest ...interface{}) error) (gatewayContact, error) {
+	var c gatewayContact
+	var attrs string
+	var created, updated int64
+	if err := scan(&c.JID, &c.Phone, &c.Name, &attrs, &created, &updated); err != nil {
+		return c, err
+	}
+	if err := json.Unmarshal([]byte(attrs), &c.Attributes); err != nil || c.Attributes == nil {
+		c.Attributes = map[string]interface{}{}
+	}
+	c.CreatedAt = time.Unix(created, 0).UTC()
+	c.UpdatedAt = time.Unix(updated, 0).UTC()
+	return c, nil
+}
+
+func getGatewayContact(ctx context.Context, jid types.JID) (gatewayContact, error) {
+	row := gatewayDB.QueryRowContext(ctx, `SELECT `+contactColumns+` FROM contacts WHERE jid = ?`, jid.ToNonAD().String())
+	return scanGatewayContact(row.Scan)
+}
+
+var (
+	phoneSeparators = strings.NewReplacer(" ", "", "-", "", ".", "", "(", "", ")", "", "/", "")
+	phoneDigits     = regexp.MustCompile(`^[1-9][0-9]{6,14}$`)
+	attributeKeyRe  = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+)
+
+// normalizePhone turns an international number ("+49 151 2345-678",
+// "0049...") into E.164 digits without the plus.
+func normalizePhone(phone string) (string, error) {
+	p := phoneSeparators.Replace(strings.TrimSpace(phone))
+	switch {
+	case strings.HasPrefix(p, "+"):
+		p = p[1:]
+	case strings.HasPrefix(p, "00"):
+		p = p[2:]
+	}
+	if !phoneDigits.MatchString(p) {
+		return "", fmt.Errorf("invalid phone number %q, expected international format", phone)
+	}
+	return p, nil
+}
+
+// attributeKey canonicalizes an attribute name so it can be referenced from
+// templates: "First Name" becomes first_name.
+func attributeKey(name string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(name)), " ", "_")
+}
+
+// validateAttributes rejects keys that templates cannot reference and
+// nested values. A null value removes the attribute on update.
+func validateAttributes(attrs map[string]interface{}) error {
+	for key, value := range attrs {
+		if !attributeKeyRe.MatchString(key) {
+			return fmt.Errorf("invalid attribute name %q, expected lowercase letters, digits and underscores", key)
+		}
+		switch value.(type) {
+		case string, float64, bool, nil:
+		default:
+			return fmt.Errorf("attribute %q must be a string, number or boolean", key)
+		}
+	}
+	return nil
+}
+
+type contactImportRow struct {
+	Name       string                 `json:"name"`
+	Phone      string                 `json:"phone"`
+	Attributes map[string]interface{} `json:"attributes"`
+}
+
+// parseContactsCSV reads a CSV with a header row. The phone column is
+// required, name is optional and every other column becomes an attribute;
+// empty cells are left out.
+func parseContactsCSV(r io.Reader) ([]contactImportRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+	phoneCol, nameCol := -1, -1
+	for i, col := range header {
+		header[i] = attributeKey(strings.TrimPrefix(col, "\ufeff"))
+		switch header[i] {
+		case "phone", "phone_number", "number", "mobile":
+			phoneCol = i
+		case "name", "full_name":
+			nameCol = i
+		}
+	}
+	if phoneCol < 0 {
+		return nil, fmt.Errorf("CSV header has no phone column")
+	}
+	var rows []contactImportRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to read CSV: %w", err)
+		}
+		row := contactImportRow{Attributes: map[string]interface{}{}}
+		for i, value := range record {
+			if i >= len(header) || strings.TrimSpace(value) == "" {
+				continue
+			}
+			switch i {
+			case phoneCol:
+				row.Phone = value
+			case nameCol:
+				row.Name = strings.TrimSpace(value)
+			default:
+				row.Attributes[header[i]] = strings.TrimSpace(value)
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// parseContactsJSON accepts either an array of contacts or {"contacts": [...]}.
+func parseContactsJSON(r io.Reader) ([]contactImportRow, error) {
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var rows []contactImportRow
+	if err := json.Unmarshal(body, &rows); err == nil {
+		return rows, nil
+	}
+	var wrapped struct {
+		Contacts []contactImportRow `json:"contacts"`
+	}
+	if err := json.Unmarshal(body, &wrapped); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	return wrapped.Contacts, nil
+}
+
+// upsertGatewayContact creates a contact or merges into an existing one:
+// a non-empty name replaces the stored one and attributes are merged key by
+// key (null removes a key). It reports whether the contact was new.
+func upsertGatewayContact(ctx context.Context, tx *sql.Tx, row contactImportRow) (types.JID, bool, error) {
+	phone, err := normalizePhone(row.Phone)
+	if err != nil {
+		return types.JID{}, false, err
+	}
+	if row.Attributes == nil {
+		row.Attributes = map[string]interface{}{}
+	}
+	if err := validateAttributes(row.Attributes); err != nil {
+		return types.JID{}, false, err
+	}
+	jid := types.NewJID(phone, types.DefaultUserServer)
+	var exists bool
+	if err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM contacts WHERE jid = ?)`, jid.String()).Scan(&exists); err != nil {
+		return jid, false, err
+	}
+	attrs, _ := json.Marshal(row.Attributes)
+	now := time.Now().Unix()
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO contacts (jid, phone, name, attributes, created_at, updated_at)
+		VALUES (?, ?, ?, json_patch('{}', ?), ?, ?)
+		ON CONFLICT (jid) DO UPDATE SET
+			name = CASE WHEN excluded.name <> '' THEN excluded.name ELSE contacts.name END,
+			attributes = json_patch(contacts.attributes, ?),
+			updated_at = excluded.updated_at`,
+		jid.String(), phone, row.Name, string(attrs), now, now, string(attrs))
+	return jid, !exists, err
+}
+
+type contactImportError struct {
+	Row   int    `json:"row"` // 1-based, not counting the CSV header
+	Phone string `json:"phone,omitempty"`
+	Error string `json:"error"`
+}
+
+const contactImportMaxBytes = 10 << 20
+
+// importContacts takes text/csv or JSON. Invalid rows are skipped and
+// reported; the valid ones are stored in a single transaction.
+func importContacts(w http.ResponseWriter, r *http.Request) {
+	body := http.MaxBytesReader(w, r.Body, contactImportMaxBytes)
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	var rows []contactImportRow
+	var err error
+	if mediaType == "text/csv" {
+		rows, err = parseContactsCSV(body)
+	} else {
+		rows, err = parseContactsJSON(body)
+	}
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		http.Error(w, "Import is too large", http.StatusRequestEntityTooLarge)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tx, err := gatewayDB.BeginTx(r.Context(), nil)
+	if err != nil {
+		waLogger.Errorf("Failed to start contact import: %v", err)
+		http.Error(w, "Failed to import contacts", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	created, updated := 0, 0
+	skipped := []contactImportError{}
+	for i, row := range rows {
+		_, isNew, err := upsertGatewayContact(r.Context(), tx, row)
+		if err != nil {
+			skipped = append(skipped, contactImportError{Row: i + 1, Phone: row.Phone, Error: err.Error()})
+			continue
+		}
+		if isNew {
+			created++
+		} else {
+			updated++
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		waLogger.Errorf("Failed to commit contact import: %v", err)
+		http.Error(w, "Failed to import contacts", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"created": created,
+		"updated": updated,
+		"skipped": skipped,
+	})
+}
+
+func listGatewayContacts(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	query := `SELECT ` + contactColumns + ` FROM contacts`
+	var args []interface{}
+	if search := strings.TrimSpace(q.Get("q")); search != "" {
+		query += ` WHERE name LIKE ? ESCAPE '\' OR phone LIKE ? ESCAPE '\'`
+		args = append(args, likePattern(search), likePattern(search))
+	}
+	limit := 100
+	if l := q.Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 || n > 1000 {
+			http.Error(w, "Invalid limit, expected 1-1000", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	offset, _ := strconv.Atoi(q.Get("offset"))
+	query += ` ORDER BY name, phone LIMIT ? OFFSET ?`
+	args = append(args, limit, max(offset, 0))
+
+	rows, err := gatewayDB.QueryContext(r.Context(), query, args...)
+	if err != nil {
+		waLogger.Errorf("Failed to list contacts: %v", err)
+		http.Error(w, "Failed to list contacts", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	contacts := []gatewayContact{}
+	for rows.Next() {
+		c, err := scanGatewayContact(rows.Scan)
+		if err != nil {
+			waLogger.Errorf("Failed to scan contact row: %v", err)
+			http.Error(w, "Failed to list contacts", http.StatusInternalServerError)
+			return
+		}
+		contacts = append(contacts, c)
+	}
+	rows.Close()
+	for i, c := range contacts {
+		if jid, err := types.ParseJID(c.JID); err == nil {
+			contacts[i].Engagement = engagementFor(r.Context(), jid)
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"contacts": contacts})
+}
+
+func getGatewayContactHandler(w http.ResponseWriter, r *http.Request) {
+	jid, ok := parseJID(r.PathValue("jid"))
+	if !ok {
+		http.Error(w, "Invalid JID", http.StatusBadRequest)
+		return
+	}
+	c, err := getGatewayContact(r.Context(), canonicalJID(r.Context(), jid))
+	if err == sql.ErrNoRows {
+		http.Error(w, "Contact not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		waLogger.Errorf("Failed to load contact %s: %v", jid, err)
+		http.Error(w, "Failed to load contact", http.StatusInternalServerError)
+		return
+	}
+	c.Engagement = engagementFor(r.Context(), canonicalJID(r.Context(), jid))
+	writeJSON(w, http.StatusOK, c)
+}
+
+type contactUpdateRequest struct {
+	Name       string                 `json:"name"`
+	Attributes map[string]interface{} `json:"attributes"`
+}
+
+// putGatewayContact creates the contact or merges the given name and
+// attributes into it; an attribute set to null is removed.
+func putGatewayContact(w http.ResponseWriter, r *http.Request) {
+	jid, ok := parseJID(r.PathValue("jid"))
+	if !ok || jid.Server != types.DefaultUserServer {
+		http.Error(w, "Invalid JID", http.StatusBadRequest)
+		return
+	}
+	var req contactUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	tx, err := gatewayDB.BeginTx(r.Context(), nil)
+	if err != nil {
+		waLogger.Errorf("Failed to update contact %s: %v", jid, err)
+		http.Error(w, "Failed to update contact", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	jid, _, err = upsertGatewayContact(r.Context(), tx, contactImportRow{Name: req.Name, Phone: jid.User, Attributes: req.Attributes})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		waLogger.Errorf("Failed to update contact %s: %v", jid, err)
+		http.Error(w, "Failed to update contact", http.StatusInternalServerError)
+		return
+	}
+	c, err := getGatewayContact(r.Context(), jid)
+	if err != nil {
+		waLogger.Errorf("Failed to load contact %s: %v", jid, err)
+		http.Error(w, "Failed to load contact", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, c)
+}
+
+func deleteGatewayContact(w http.ResponseWriter, r *http.Request) {
+	jid, ok := parseJID(r.PathValue("jid"))
+	if !ok {
+		http.Error(w, "Invalid JID", http.StatusBadRequest)
+		return
+	}
+	jid = canonicalJID(r.Context(), jid)
+	res, err := gatewayDB.ExecContext(r.Context(), `DELETE FROM contacts WHERE jid = ?`, jid.String())
+	if err != nil {
+		waLogger.Errorf("Failed to delete contact %s: %v", jid, err)
+		http.Error(w, "Failed to delete contact", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		http.Error(w, "Contact not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func deleteContactAttribute(w http.ResponseWriter, r *http.Request) {
+	jid, ok := parseJID(r.PathValue("jid"))
+	if !ok {
+		http.Error(w, "Invalid JID", http.StatusBadRequest)
+		return
+	}
+	jid = canonicalJID(r.Context(), jid)
+	key := r.PathValue("key")
+	if !attributeKeyRe.MatchString(key) {
+		http.Error(w, "Invalid attribute name", http.StatusBadRequest)
+		return
+	}
+	res, err := gatewayDB.ExecContext(r.Context(),
+		`UPDATE contacts SET attributes = json_remove(attributes, '$.' || ?), updated_at = ? WHERE jid = ?`,
+		key, time.Now().Unix(), jid.String())
+	if err != nil {
+		waLogger.Errorf("Failed to delete attribute %s of %s: %v", key, jid, err)
+		http.Error(w, "Failed to update contact", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		http.Error(w, "Contact not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+type contactDetails struct {
+	DisplayName    string
+	IsBusiness     bool
+	IsSavedContact bool
+}
+
+// resolveContact looks the sender up in the contact store (address book and
+// push-name cache). Address-book names win over business and push names.
+// pushName and verified come from the message itself and are fresher than
+// the store.
+func resolveContact(ctx context.Context, sender types.JID, pushName string, verified *types.VerifiedName) contactDetails {
+	var info types.ContactInfo
+	if client != nil {
+		candidates := []types.JID{toPhoneJID(ctx, sender).ToNonAD()}
+		if sender.ToNonAD() != candidates[0] {
+			candidates = append(candidates, sender.ToNonAD())
+		}
+		for _, jid := range candidates {
+			var err error
+			info, err = client.Store.Contacts.GetContact(ctx, jid)
+			if err != nil {
+				waLogger.Warnf("Failed to look up contact %s: %v", jid, err)
+			}
+			if info.Found {
+				break
+			}
+		}
+	}
+	var details contactDetails
+	details.IsSavedContact = info.FullName != "" || info.FirstName != ""
+	verifiedName := ""
+	if verified != nil && verified.Details != nil {
+		verifiedName = verified.Details.GetVerifiedName()
+	}
+	details.IsBusiness = verifiedName != "" || info.BusinessName != ""
+	for _, name := range []string{info.FullName, info.FirstName, verifiedName, info.BusinessName, pushName, info.PushName} {
+		if name != "" {
+			details.DisplayName = name
+			break
+		}
+	}
+	return details
+}
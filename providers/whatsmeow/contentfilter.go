@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// The outbound content policy checks the text of every send (body and media
+// captions) against the tenant's rules. Violations either reject the send or
+// let it through flagged, depending on the policy action. API keys with
+// content_filter_override in their policy are exempt.
+
+type contentPolicy struct {
+	Action         string   `json:"action"` // reject or flag
+	BannedWords    []string `json:"banned_words,omitempty"`
+	AllowedDomains []string `json:"allowed_domains,omitempty"` // empty allows any domain
+	MaxLinks       int      `json:"max_links,omitempty"`       // 0 means unlimited
+
+	bannedRe *regexp.Regexp
+}
+
+var errContentPolicy = errors.New("message violates the content policy")
+
+var linkRe = regexp.MustCompile(`(?i)\b(?:https?://|www\.)[^\s<>"]+`)
+
+var (
+	contentPolicyMu  sync.RWMutex
+	contentPolicyCur = &contentPolicy{Action: "reject"}
+)
+
+func (p *contentPolicy) compile() error {
+	switch p.Action {
+	case "":
+		p.Action = "reject"
+	case "reject", "flag":
+	default:
+		return fmt.Errorf("action must be reject or flag")
+	}
+	var words []string
+	for _, w := range p.BannedWords {
+		if w = strings.TrimSpace(w); w != "" {
+			words = append(words, regexp.QuoteMeta(w))
+		}
+	}
+	p.bannedRe = nil
+	if len(words) > 0 {
+		p.bannedRe = regexp.MustCompile(`(?i)\b(?:` + strings.Join(words, "|") + `)\b`)
+	}
+	for i, d := range p.AllowedDomains {
+		p.AllowedDomains[i] = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(d), "."))
+	}
+	if p.MaxLinks < 0 {
+		return fmt.Errorf("max_links must not be negative")
+	}
+	return nil
+}
+
+func loadContentPolicy(ctx context.Context) {
+	raw := getSetting(ctx, "content_policy", "")
+	if raw == "" {
+		return
+	}
+	policy := &contentPolicy{}
+	if err := json.Unmarshal([]byte(raw), policy); err != nil {
+		waLogger.Errorf("Ignoring invalid content policy: %v", err)
+		return
+	}
+	if err := policy.compile(); err != nil {
+		waLogger.Errorf("Ignoring invalid content policy: %v", err)
+		return
+	}
+	contentPolicyMu.Lock()
+	contentPolicyCur = policy
+	contentPolicyMu.Unlock()
+}
+
+func currentContentPolicy() *contentPolicy {
+	contentPolicyMu.RLock()
+	defer contentPolicyMu.RUnlock()
+	return contentPolicyCur
+}
+
+// messageText returns the user-visible text of a message: the body or the
+// media caption, or the new text of an edit.
+func messageText(msg *waE2E.Message) string {
+	switch {
+	case msg.Conversation != nil:
+		return msg.GetConversation()
+	case msg.ExtendedTextMessage != nil:
+		return msg.GetExtendedTextMessage().GetText()
+	case msg.ImageMessage != nil:
+		return msg.GetImageMessage().GetCaption()
+	case msg.VideoMessage != nil:
+		return msg.GetVideoMessage().GetCaption()
+	case msg.DocumentMessage != nil:
+		return msg.GetDocumentMessage().GetCaption()
+	case msg.InteractiveMessage != nil:
+		return msg.GetInteractiveMessage().GetBody().GetText()
+	case msg.ListMessage != nil:
+		return msg.GetListMessage().GetDescription()
+	case msg.EditedMessage != nil:
+		return messageText(msg.GetEditedMessage().GetMessage().GetProtocolMessage().GetEditedMessage())
+	}
+	return ""
+}
+
+func (p *contentPolicy) domainAllowed(host string) bool {
+	if len(p.AllowedDomains) == 0 {
+		return true
+	}
+	host = strings.ToLower(host)
+	for _, d := range p.AllowedDomains {
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+// violations lists every rule the text breaks.
+func (p *contentPolicy) violations(text string) []string {
+	var found []string
+	if p.bannedRe != nil {
+		if word := p.bannedRe.FindString(text); word != "" {
+			found = append(found, fmt.Sprintf("contains banned word %q", word))
+		}
+	}
+	links := linkRe.FindAllString(text, -1)
+	if p.MaxLinks > 0 && len(links) > p.MaxLinks {
+		found = append(found, fmt.Sprintf("contains %d links, at most %d allowed", len(links), p.MaxLinks))
+	}
+	for _, link := range links {
+		if !strings.Contains(link, "://") {
+			link = "http://" + link
+		}
+		u, err := url.Parse(link)
+		if err != nil || !p.domainAllowed(u.Hostname()) {
+			found = append(found, fmt.Sprintf("links to a domain that is not allowed: %s", link))
+		}
+	}
+	return found
+}
+
+// checkContentPolicy returns an error wrapping errContentPolicy when the
+// send must be rejected, or the violations to flag on an accepted send.
+func checkContentPolicy(ctx context.Context, to types.JID, msg *waE2E.Message) ([]string, error) {
+	if key := apiKeyFromContext(ctx); key != nil && key.Policy.ContentFilterOverride {
+		return nil, nil
+	}
+	policy := currentContentPolicy()
+	found := policy.violations(messageText(msg))
+	if len(found) == 0 {
+		return nil, nil
+	}
+	if policy.Action == "reject" {
+		return nil, fmt.Errorf("%w: %s", errContentPolicy, strings.Join(found, "; "))
+	}
+	waLogger.Warnf("Flagged message to %s: %s", to, strings.Join(found, "; "))
+	return found, nil
+}
+
+func getContentPolicy(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, currentContentPolicy())
+}
+
+func putContentPolicy(w http.ResponseWriter, r *http.Request) {
+	policy := &contentPolicy{}
+	if err := json.NewDecoder(r.Body).Decode(policy); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := policy.compile(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	raw, _ := json.Marshal(policy)
+	if err := setSetting(r.Context(), "content_policy", string(raw)); err != nil {
+		waLogger.Errorf("Failed to save content policy: %v", err)
+		http.Error(w, "Failed to save content policy", http.StatusInternalServerError)
+		return
+	}
+	contentPolicyMu.Lock()
+	contentPolicyCur = policy
+	contentPolicyMu.Unlock()
+	writeJSON(w, http.StatusOK, policy)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// gatewayDB holds the gateway's own tables. They live in the same SQLite file
+// as the whatsmeow store so state snapshots carry them along on migration.
+var gatewayDB *sql.DB
+
+func openGatewayDB() error {
+	// secure_delete overwrites deleted rows, so erased personal data doesn't
+	// linger in free pages.
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?_foreign_keys=on&_busy_timeout=5000&_secure_delete=on", dbPath))
+	if err != nil {
+		return fmt.Errorf("failed to open gateway database: %w", err)
+	}
+	if err := migrateGatewayDB(context.Background(), db); err != nil {
+		db.Close()
+		return err
+	}
+	gatewayDB = db
+	return nil
+}
+
+// getSetting returns a persisted gateway setting, or def when unset.
+func getSetting(ctx context.Context, key, def string) string {
+	var value string
+	err := gatewayDB.QueryRowContext(ctx, `SELECT value FROM gateway_settings WHERE key = ?`, key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return def
+	} else if err != nil {
+		waLogger.Errorf("Failed to read setting %s: %v", key, err)
+		return def
+	}
+	return value
+}
+
+func setSetting(ctx context.Context, key, value string) error {
+	_, err := gatewayDB.ExecContext(ctx,
+		`INSERT INTO gateway_settings (key, value) VALUES (?, ?) ON CONFLICT (key) DO UPDATE SET value = excluded.value`,
+		key, value)
+	return err
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	waLog "go.mau.fi/whatsmeow/util/log"
+)
+
+// openTestDB points gatewayDB at a freshly migrated database for the
+// duration of a test.
+func openTestDB(t *testing.T) {
+	t.Helper()
+	waLogger = waLog.Noop
+	oldPath := dbPath
+	dbPath = filepath.Join(t.TempDir(), "gateway.db")
+	if err := openGatewayDB(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		gatewayDB.Close()
+		gatewayDB = nil
+		dbPath = oldPath
+	})
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"google.golang.org/protobuf/proto"
+)
+
+// Duplicate-content protection catches integrations that retry a send which
+// actually went through: the same content to the same recipient within the
+// window is blocked (or only flagged with DUPLICATE_SEND_ACTION=warn).
+// Callers can bypass it per request with allow_duplicate.
+
+var (
+	duplicateSendWindow = envDuration("DUPLICATE_SEND_WINDOW", 5*time.Minute) // 0 disables
+	duplicateSendAction = envString("DUPLICATE_SEND_ACTION", "block")
+)
+
+var errDuplicateContent = errors.New("identical message was already sent to this recipient recently")
+
+var (
+	recentSendsMu sync.Mutex
+	recentSends   = make(map[string]time.Time)
+	recentSweep   time.Time
+)
+
+// contentFingerprint identifies what the recipient sees. Media is keyed by
+// the plaintext file hash since every upload gets a fresh media key.
+func contentFingerprint(msg *waE2E.Message) string {
+	h := sha256.New()
+	part := func(kind string, fields ...[]byte) {
+		h.Write([]byte(kind))
+		for _, f := range fields {
+			h.Write([]byte{0})
+			h.Write(f)
+		}
+	}
+	switch {
+	case msg.Conversation != nil:
+		part("text", []byte(msg.GetConversation()))
+	case msg.ExtendedTextMessage != nil:
+		part("text", []byte(msg.GetExtendedTextMessage().GetText()))
+	case msg.ImageMessage != nil:
+		part("image", msg.ImageMessage.GetFileSHA256(), []byte(msg.ImageMessage.GetCaption()))
+	case msg.VideoMessage != nil:
+		part("video", msg.VideoMessage.GetFileSHA256(), []byte(msg.VideoMessage.GetCaption()))
+	case msg.AudioMessage != nil:
+		part("audio", msg.AudioMessage.GetFileSHA256())
+	case msg.DocumentMessage != nil:
+		part("document", msg.DocumentMessage.GetFileSHA256(), []byte(msg.DocumentMessage.GetCaption()))
+	case msg.StickerMessage != nil:
+		part("sticker", msg.StickerMessage.GetFileSHA256())
+	default:
+		data, _ := proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+		part("proto", data)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// claimContent records a send and reports whether the same content already
+// went to the recipient within the window. The claim is taken up front so
+// concurrent retries can't both slip through; releaseContent undoes it when
+// the send fails.
+func claimContent(to types.JID, msg *waE2E.Message) (key string, duplicate bool) {
+	if duplicateSendWindow <= 0 {
+		return "", false
+	}
+	key = to.ToNonAD().String() + "/" + contentFingerprint(msg)
+	now := time.Now()
+	recentSendsMu.Lock()
+	defer recentSendsMu.Unlock()
+	if now.Sub(recentSweep) > duplicateSendWindow {
+		for k, at := range recentSends {
+			if now.Sub(at) > duplicateSendWindow {
+				delete(recentSends, k)
+			}
+		}
+		recentSweep = now
+	}
+	if at, ok := recentSends[key]; ok && now.Sub(at) <= duplicateSendWindow {
+		return "", true
+	}
+	recentSends[key] = now
+	return key, false
+}
+
+func releaseContent(key string) {
+	if key == "" {
+		return
+	}
+	recentSendsMu.Lock()
+	delete(recentSends, key)
+	recentSendsMu.Unlock()
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+
+	"go.mau.fi/whatsmeow/proto/waCompanionReg"
+	"go.mau.fi/whatsmeow/store"
+	"google.golang.org/protobuf/proto"
+)
+
+// The companion name and platform are what the phone shows under Linked
+// devices. They're sent during pairing, so changing them only affects the
+// next pairing. DEVICE_NAME/DEVICE_PLATFORM set the defaults; the API
+// overrides them per session.
+
+type deviceIdentity struct {
+	Name     string `json:"name"`
+	Platform string `json:"platform"`
+	Paired   bool   `json:"paired"`
+}
+
+func parseDevicePlatform(platform string) (waCompanionReg.DeviceProps_PlatformType, bool) {
+	v, ok := waCompanionReg.DeviceProps_PlatformType_value[strings.ToUpper(strings.TrimSpace(platform))]
+	return waCompanionReg.DeviceProps_PlatformType(v), ok
+}
+
+func configuredDeviceIdentity(ctx context.Context) deviceIdentity {
+	return deviceIdentity{
+		Name:     getSetting(ctx, "device_name", os.Getenv("DEVICE_NAME")),
+		Platform: getSetting(ctx, "device_platform", os.Getenv("DEVICE_PLATFORM")),
+	}
+}
+
+// applyDeviceIdentity updates the device props whatsmeow sends when pairing.
+// Empty fields keep the whatsmeow defaults.
+func applyDeviceIdentity(id deviceIdentity) {
+	if id.Name != "" {
+		store.DeviceProps.Os = proto.String(id.Name)
+	}
+	if id.Platform != "" {
+		platform, ok := parseDevicePlatform(id.Platform)
+		if !ok {
+			waLogger.Warnf("Ignoring unknown device platform %q", id.Platform)
+			return
+		}
+		store.DeviceProps.PlatformType = platform.Enum()
+	}
+}
+
+func currentDeviceIdentity() deviceIdentity {
+	return deviceIdentity{
+		Name:     store.DeviceProps.GetOs(),
+		Platform: strings.ToLower(waCompanionReg.DeviceProps_PlatformType_name[int32(store.DeviceProps.GetPlatformType())]),
+		Paired:   client != nil && client.Store.ID != nil,
+	}
+}
+
+func getDeviceIdentity(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, currentDeviceIdentity())
+}
+
+func putDeviceIdentity(w http.ResponseWriter, r *http.Request) {
+	var req deviceIdentity
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if client != nil && client.Store.ID != nil {
+		http.Error(w, "Session is already paired; the device name can only be set before pairing", http.StatusConflict)
+		return
+	}
+	if req.Platform != "" {
+		if _, ok := parseDevicePlatform(req.Platform); !ok {
+			http.Error(w, "Unknown platform: "+req.Platform, http.StatusBadRequest)
+			return
+		}
+	}
+	if err := setSetting(r.Context(), "device_name", req.Name); err != nil {
+		waLogger.Errorf("Failed to save device name: %v", err)
+		http.Error(w, "Failed to save device identity", http.StatusInternalServerError)
+		return
+	}
+	if err := setSetting(r.Context(), "device_platform", req.Platform); err != nil {
+		waLogger.Errorf("Failed to save device platform: %v", err)
+		http.Error(w, "Failed to save device identity", http.StatusInternalServerError)
+		return
+	}
+	applyDeviceIdentity(req)
+	// A QR code that's already on screen was issued with the old props;
+	// reconnect so the next one carries the new identity.
+	if client != nil && client.IsConnected() {
+		client.Disconnect()
+		if err := client.Connect(); err != nil {
+			waLogger.Errorf("Failed to reconnect after device identity change: %v", err)
+		}
+	}
+	writeJSON(w, http.StatusOK, currentDeviceIdentity())
+}
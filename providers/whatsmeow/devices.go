@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// A message to a user is encrypted separately for each of their devices:
+// device 0 is the phone, the rest are linked devices (WhatsApp Web, desktop
+// and, for businesses, the extra phones and agents of a multi-device
+// account). When one of them says it never got a message, the device list
+// WhatsApp hands out for the number is the first thing to check.
+
+type userDevice struct {
+	JID     string `json:"jid"`
+	Device  uint16 `json:"device"`
+	Primary bool   `json:"primary"` // the phone the account is registered on
+}
+
+type userDevicesResponse struct {
+	JID     string       `json:"jid"`
+	LID     string       `json:"lid,omitempty"`
+	Count   int          `json:"count"`
+	Devices []userDevice `json:"devices"`
+}
+
+// getUserDevices lists the devices messages to a user are encrypted for.
+// An unregistered number has none.
+func getUserDevices(w http.ResponseWriter, r *http.Request) {
+	jid, ok := parseJID(r.PathValue("jid"))
+	if !ok || (jid.Server != types.DefaultUserServer && jid.Server != types.HiddenUserServer) {
+		http.Error(w, "Invalid JID", http.StatusBadRequest)
+		return
+	}
+	if !requireClient(w, r, false) {
+		return
+	}
+	jid = jid.ToNonAD()
+	devices, err := client.GetUserDevices(r.Context(), []types.JID{jid})
+	if err != nil {
+		waLogger.Errorf("Failed to get devices of %s: %v", jid, err)
+		http.Error(w, "Failed to get devices", http.StatusBadGateway)
+		return
+	}
+	resp := userDevicesResponse{JID: jid.String(), Devices: make([]userDevice, 0, len(devices))}
+	if lid, found := lookupLID(r.Context(), jid); found {
+		resp.LID = lid.String()
+	}
+	for _, d := range devices {
+		resp.Devices = append(resp.Devices, userDevice{JID: d.String(), Device: d.Device, Primary: d.Device == 0})
+	}
+	sort.Slice(resp.Devices, func(i, j int) bool { return resp.Devices[i].Device < resp.Devices[j].Device })
+	resp.Count = len(resp.Devices)
+	writeJSON(w, http.StatusOK, resp)
+}
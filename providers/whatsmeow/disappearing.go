@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	"google.golang.org/protobuf/proto"
+)
+
+// Disappearing messages: PUT /chats/{jid}/disappearing sets a chat's timer
+// (off, 24h, 7d or 90d) for both sides, and the gateway remembers it, also
+// when it's changed from a phone. WhatsApp only expires a message that says
+// so itself, so sends to a chat with a timer carry it; a send can also set
+// its own with ephemeral_expiration, where "off" keeps it in such a chat.
+
+var disappearingTimers = map[string]time.Duration{
+	"off": whatsmeow.DisappearingTimerOff,
+	"24h": whatsmeow.DisappearingTimer24Hours,
+	"7d":  whatsmeow.DisappearingTimer7Days,
+	"90d": whatsmeow.DisappearingTimer90Days,
+}
+
+func parseDisappearingTimer(s string) (time.Duration, error) {
+	d, ok := disappearingTimers[s]
+	if !ok {
+		return 0, fmt.Errorf("invalid timer %q, expected off, 24h, 7d or 90d", s)
+	}
+	return d, nil
+}
+
+func disappearingTimerName(seconds uint32) string {
+	for name, d := range disappearingTimers {
+		if uint32(d/time.Second) == seconds {
+			return name
+		}
+	}
+	return fmt.Sprintf("%ds", seconds)
+}
+
+// parseEphemeralExpiration reads the ephemeral_expiration of a send; nil
+// leaves it to the chat's timer.
+func parseEphemeralExpiration(s string) (*uint32, error) {
+	if s == "" {
+		return nil, nil
+	}
+	d, err := parseDisappearingTimer(s)
+	if err != nil {
+		return nil, fmt.Errorf("ephemeral_expiration: %w", err)
+	}
+	return proto.Uint32(uint32(d / time.Second)), nil
+}
+
+func setChatDisappearingTimer(ctx context.Context, chat types.JID, seconds uint32) error {
+	_, err := gatewayDB.ExecContext(ctx, `
+		INSERT INTO chats (jid, disappearing_timer, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT (jid) DO UPDATE SET
+			disappearing_timer = excluded.disappearing_timer,
+			updated_at = excluded.updated_at`,
+		chat.String(), seconds, time.Now().Unix())
+	return err
+}
+
+// recordDisappearingSetting remembers a chat timer changed from a phone.
+func recordDisappearingSetting(evt *events.Message) {
+	pm := evt.Message.GetProtocolMessage()
+	if pm == nil || pm.GetType() != waE2E.ProtocolMessage_EPHEMERAL_SETTING || gatewayDB == nil {
+		return
+	}
+	ctx := context.Background()
+	chat := canonicalJID(ctx, evt.Info.Chat)
+	if err := setChatDisappearingTimer(ctx, chat, pm.GetEphemeralExpiration()); err != nil {
+		waLogger.Errorf("Failed to record disappearing timer of %s: %v", chat, err)
+	}
+}
+
+// applyEphemeralExpiration marks msg to disappear after the given number of
+// seconds, or the chat's timer when nil. Messages without context info
+// (reactions, polls and the like) are left as they are.
+func applyEphemeralExpiration(ctx context.Context, to types.JID, msg *waE2E.Message, expiration *uint32) {
+	seconds := uint32(0)
+	if expiration != nil {
+		seconds = *expiration
+	} else if gatewayDB != nil {
+		if chat, err := getChat(ctx, canonicalJID(ctx, to)); err == nil {
+			seconds = chat.DisappearingTimer
+		}
+	}
+	if seconds == 0 {
+		return
+	}
+	if msg.Conversation != nil {
+		msg.ExtendedTextMessage = &waE2E.ExtendedTextMessage{Text: msg.Conversation}
+		msg.Conversation = nil
+	}
+	info := &waE2E.ContextInfo{}
+	if existing := messageContextInfo(msg); existing != nil {
+		info = existing
+	}
+	info.Expiration = proto.Uint32(seconds)
+	switch {
+	case msg.ExtendedTextMessage != nil:
+		msg.ExtendedTextMessage.ContextInfo = info
+	case msg.ImageMessage != nil:
+		msg.ImageMessage.ContextInfo = info
+	case msg.VideoMessage != nil:
+		msg.VideoMessage.ContextInfo = info
+	case msg.AudioMessage != nil:
+		msg.AudioMessage.ContextInfo = info
+	case msg.DocumentMessage != nil:
+		msg.DocumentMessage.ContextInfo = info
+	case msg.StickerMessage != nil:
+		msg.StickerMessage.ContextInfo = info
+	case msg.LocationMessage != nil:
+		msg.LocationMessage.ContextInfo = info
+	case msg.ContactMessage != nil:
+		msg.ContactMessage.ContextInfo = info
+	}
+}
+
+func getChatDisappearing(w http.ResponseWriter, r *http.Request) {
+	jid, ok := parseJID(r.PathValue("jid"))
+	if !ok {
+		http.Error(w, "Invalid JID", http.StatusBadRequest)
+		return
+	}
+	chat := canonicalJID(r.Context(), jid)
+	var seconds uint32
+	if c, err := getChat(r.Context(), chat); err == nil {
+		seconds = c.DisappearingTimer
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"jid": chat.String(), "timer": disappearingTimerName(seconds), "seconds": seconds,
+	})
+}
+
+type disappearingRequest struct {
+	Timer string `json:"timer"` // off, 24h, 7d or 90d
+}
+
+func putChatDisappearing(w http.ResponseWriter, r *http.Request) {
+	jid, ok := parseJID(r.PathValue("jid"))
+	if !ok {
+		http.Error(w, "Invalid JID", http.StatusBadRequest)
+		return
+	}
+	if !requireClient(w, r, false) {
+		return
+	}
+	var req disappearingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	timer, err := parseDisappearingTimer(req.Timer)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	chat := canonicalJID(r.Context(), jid)
+	if err := client.SetDisappearingTimer(r.Context(), toPhoneJID(r.Context(), jid), timer, time.Now()); err != nil {
+		waLogger.Errorf("Failed to set disappearing timer of %s: %v", chat, err)
+		http.Error(w, "Failed to set disappearing timer", http.StatusBadGateway)
+		return
+	}
+	seconds := uint32(timer / time.Second)
+	if err := setChatDisappearingTimer(r.Context(), chat, seconds); err != nil {
+		waLogger.Errorf("Failed to record disappearing timer of %s: %v", chat, err)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"jid": chat.String(), "timer": req.Timer, "seconds": seconds,
+	})
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"google.golang.org/protobuf/proto"
+)
+
+// /messages/{id}/edit replaces the text of a message we sent. WhatsApp only
+// applies edits within whatsmeow.EditWindow of the original; recipients
+// silently drop later ones, so when the send time is known (message store
+// or outbound queue) an expired edit is refused with the
+// edit_window_expired code instead.
+
+type editMessageRequest struct {
+	Chat string `json:"chat,omitempty"` // optional when the message is in the message store or queue
+	Text string `json:"text"`
+}
+
+func editMessage(w http.ResponseWriter, r *http.Request) {
+	if sessionArchived() {
+		http.Error(w, "Session is archived", http.StatusConflict)
+		return
+	}
+	if !requireClient(w, r, true) {
+		return
+	}
+	id := r.PathValue("id")
+	var req editMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Text) == "" {
+		http.Error(w, "text is required", http.StatusBadRequest)
+		return
+	}
+	chat := types.EmptyJID
+	if req.Chat != "" {
+		jid, err := parseRecipient(req.Chat)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		chat = toPhoneJID(r.Context(), jid)
+	}
+	original, found := ownMessage(r.Context(), chat, id)
+	if found && chat.IsEmpty() {
+		chat, _ = types.ParseJID(original.Chat)
+	}
+	if chat.IsEmpty() {
+		http.Error(w, "chat is required for messages not in the message store", http.StatusBadRequest)
+		return
+	}
+	if found && !original.Timestamp.IsZero() && time.Since(original.Timestamp) > whatsmeow.EditWindow {
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{
+			"code":                "edit_window_expired",
+			"error":               "the message is too old to be edited",
+			"sent_at":             original.Timestamp.UTC(),
+			"edit_window_seconds": int64(whatsmeow.EditWindow / time.Second),
+		})
+		return
+	}
+
+	msg := client.BuildEdit(chat, id, &waE2E.Message{Conversation: proto.String(req.Text)})
+	res, err := sendOrQueue(r.Context(), chat, msg, sendOptions{AllowDuplicate: true})
+	if err != nil {
+		writeSendError(w, chat, err)
+		return
+	}
+	if messageStoreEnabled {
+		_, err := gatewayDB.ExecContext(r.Context(), `UPDATE messages SET text = ? WHERE chat_jid = ? AND id = ? AND from_me = 1`,
+			encryptStoreValue(req.Text), chat.ToNonAD().String(), id)
+		if err != nil {
+			waLogger.Errorf("Failed to store edit of message %s: %v", id, err)
+		}
+	}
+	writeSendResult(w, chat, res)
+}
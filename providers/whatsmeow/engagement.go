@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// A contact's engagement score (0-100) rates how they respond to what the
+// session sends them over the last ENGAGEMENT_WINDOW: how often they reply,
+// how much of it they read and how quickly, and whether it's delivered at
+// all. WhatsApp doesn't tell senders they've been blocked or reported, but a
+// blocked sender's messages are never delivered, so a run of undelivered
+// messages caps the score; a suppressed number scores 0. It needs the
+// message store, which keeps the messages and receipts it's computed from.
+//
+// With ENGAGEMENT_CAMPAIGN_MIN_SCORE, sends tagged with a campaign skip
+// contacts scoring below it. Contacts without enough history to be scored
+// are never skipped.
+
+var (
+	engagementWindow           = envDuration("ENGAGEMENT_WINDOW", 90*24*time.Hour)
+	engagementMinMessages      = envInt("ENGAGEMENT_MIN_MESSAGES", 3)
+	engagementCampaignMinScore = envInt("ENGAGEMENT_CAMPAIGN_MIN_SCORE", 0) // 0 turns it off
+)
+
+const (
+	// engagementSettle is how long a message gets to be delivered and
+	// answered before it counts.
+	engagementSettle = 24 * time.Hour
+	// engagementSlowRead is the read latency that earns no credit for speed.
+	engagementSlowRead = 72 * time.Hour
+	// engagementUndelivered messages in a row without a receipt look like a
+	// block.
+	engagementUndelivered = 3
+	engagementBlockedCap  = 10
+)
+
+var errLowEngagement = errors.New("recipient's engagement score is below the campaign minimum")
+
+type engagementScore struct {
+	Score        *int     `json:"score"` // null without enough history
+	Sent         int      `json:"sent"`
+	ReplyRate    float64  `json:"reply_rate"`
+	ReadRate     float64  `json:"read_rate"`
+	DeliveryRate float64  `json:"delivery_rate"`
+	ReadLatency  *int64   `json:"avg_read_latency_seconds,omitempty"`
+	Signals      []string `json:"signals,omitempty"` // suppressed, undelivered
+}
+
+// contactEngagement scores a contact from the stored messages and receipts of
+// their chat.
+func contactEngagement(ctx context.Context, jid types.JID) (engagementScore, error) {
+	var e engagementScore
+	pn := canonicalJID(ctx, jid)
+	if suppressed, err := isSuppressed(ctx, pn.User); err != nil {
+		return e, err
+	} else if suppressed {
+		zero := 0
+		e.Score, e.Signals = &zero, []string{"suppressed"}
+		return e, nil
+	}
+
+	now := time.Now()
+	chat := pn.String()
+	since, settled := now.Add(-engagementWindow).Unix(), now.Add(-engagementSettle).Unix()
+	var replied, delivered, read sql.NullInt64
+	err := gatewayDB.QueryRowContext(ctx, `
+		SELECT COUNT(*),
+			SUM(EXISTS (SELECT 1 FROM messages i WHERE i.chat_jid = m.chat_jid AND i.from_me = 0
+				AND i.timestamp > m.timestamp AND i.timestamp <= m.timestamp + ?)),
+			SUM(EXISTS (SELECT 1 FROM message_receipts r WHERE r.chat_jid = m.chat_jid AND r.message_id = m.id)),
+			SUM(EXISTS (SELECT 1 FROM message_receipts r WHERE r.chat_jid = m.chat_jid AND r.message_id = m.id
+				AND r.type IN ('read', 'played')))
+		FROM messages m
+		WHERE m.chat_jid = ? AND m.from_me = 1 AND m.type <> 'reaction' AND m.timestamp BETWEEN ? AND ?`,
+		int64(engagementSettle/time.Second), chat, since, settled).Scan(&e.Sent, &replied, &delivered, &read)
+	if err != nil {
+		return e, err
+	}
+	if e.Sent < max(engagementMinMessages, 1) {
+		return e, nil
+	}
+	sent := float64(e.Sent)
+	e.ReplyRate = float64(replied.Int64) / sent
+	e.DeliveryRate = float64(delivered.Int64) / sent
+	e.ReadRate = float64(read.Int64) / sent
+
+	speed := 0.0
+	var latency sql.NullFloat64
+	err = gatewayDB.QueryRowContext(ctx, `
+		SELECT AVG(MIN(r.first_read - m.timestamp, ?))
+		FROM messages m
+		JOIN (SELECT message_id, MIN(timestamp) AS first_read FROM message_receipts
+			WHERE chat_jid = ? AND type IN ('read', 'played') GROUP BY message_id) r ON r.message_id = m.id
+		WHERE m.chat_jid = ? AND m.from_me = 1 AND m.timestamp BETWEEN ? AND ?`,
+		int64(engagementSlowRead/time.Second), chat, chat, since, settled).Scan(&latency)
+	if err != nil {
+		return e, err
+	}
+	if latency.Valid {
+		seconds := int64(math.Max(latency.Float64, 0))
+		e.ReadLatency = &seconds
+		speed = 1 - float64(seconds)/engagementSlowRead.Seconds()
+	}
+
+	score := int(math.Round(100 * (0.5*e.ReplyRate + 0.3*e.ReadRate*(0.5+0.5*speed) + 0.2*e.DeliveryRate)))
+	var undelivered int
+	err = gatewayDB.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM (SELECT id, chat_jid FROM messages
+			WHERE chat_jid = ? AND from_me = 1 AND type <> 'reaction' AND timestamp <= ?
+			ORDER BY timestamp DESC LIMIT ?) m
+		WHERE NOT EXISTS (SELECT 1 FROM message_receipts r WHERE r.chat_jid = m.chat_jid AND r.message_id = m.id)`,
+		chat, settled, engagementUndelivered).Scan(&undelivered)
+	if err != nil {
+		return e, err
+	}
+	if undelivered >= engagementUndelivered {
+		e.Signals = append(e.Signals, "undelivered")
+		score = min(score, engagementBlockedCap)
+	}
+	e.Score = &score
+	return e, nil
+}
+
+// engagementFor is contactEngagement for responses that go on without it: nil
+// when the message store is off or scoring failed.
+func engagementFor(ctx context.Context, jid types.JID) *engagementScore {
+	if !messageStoreEnabled || gatewayDB == nil {
+		return nil
+	}
+	e, err := contactEngagement(ctx, jid)
+	if err != nil {
+		waLogger.Errorf("Failed to score engagement of %s: %v", jid, err)
+		return nil
+	}
+	return &e
+}
+
+// checkCampaignEngagement turns away campaign sends to contacts scoring below
+// ENGAGEMENT_CAMPAIGN_MIN_SCORE.
+func checkCampaignEngagement(ctx context.Context, to types.JID, campaign string) error {
+	if campaign == "" || engagementCampaignMinScore <= 0 || to.Server != types.DefaultUserServer {
+		return nil
+	}
+	e := engagementFor(ctx, to)
+	if e == nil || e.Score == nil || *e.Score >= engagementCampaignMinScore {
+		return nil
+	}
+	return fmt.Errorf("%w (%d < %d)", errLowEngagement, *e.Score, engagementCampaignMinScore)
+}
+
+func getContactEngagement(w http.ResponseWriter, r *http.Request) {
+	jid, ok := parseJID(r.PathValue("jid"))
+	if !ok || (jid.Server != types.DefaultUserServer && jid.Server != types.HiddenUserServer) {
+		http.Error(w, "Invalid contact JID", http.StatusBadRequest)
+		return
+	}
+	if !messageStoreEnabled {
+		http.Error(w, "Engagement scoring is disabled, enable MESSAGE_STORE", http.StatusConflict)
+		return
+	}
+	e, err := contactEngagement(r.Context(), jid)
+	if err != nil {
+		waLogger.Errorf("Failed to score engagement of %s: %v", jid, err)
+		http.Error(w, "Failed to score engagement", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, e)
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// Inbound messages are enriched by external services: translation, virus
+// scanning, voice note transcription, image analysis, and the download of
+// media that is kept. Those calls can take a while, so they don't hold up
+// the event handler: the message is handed to a bounded pool of
+// ENRICHMENT_WORKERS, which gives each message ENRICHMENT_TIMEOUT in all.
+//
+// The message webhook waits up to ENRICHMENT_INLINE_WAIT for them and
+// carries what they found. Past that it goes out without, and the rest
+// follows in a message.enriched event naming the message by id and chat.
+//
+// Forwarding waits for the virus scan, so a message with something to
+// enrich is forwarded by its worker. When the queue is full a message with
+// a recorded media download is left to the media job recovery (see
+// mediajobs.go), which replays it after the next reconnect; others go
+// without enrichment.
+
+var (
+	enrichmentWorkers = envInt("ENRICHMENT_WORKERS", 4)
+	enrichmentTimeout = envDuration("ENRICHMENT_TIMEOUT", 2*time.Minute)
+	enrichmentWait    = envDuration("ENRICHMENT_INLINE_WAIT", 10*time.Second)
+	enrichmentQueue   = make(chan *enrichmentTask, envInt("ENRICHMENT_QUEUE", 1000))
+)
+
+type enrichmentTask struct {
+	evt       *events.Message
+	data      *messageWebhookData
+	job       int64 // media job of the download, 0 if untracked
+	exhausted bool  // earlier attempts at the download used up its attempts
+
+	mu       sync.Mutex
+	sent     bool // the message webhook has gone out
+	deadline *time.Timer
+}
+
+// newEnrichmentTask starts the wait of the message webhook for the task.
+func newEnrichmentTask(evt *events.Message, data *messageWebhookData, job int64, exhausted bool) *enrichmentTask {
+	task := &enrichmentTask{evt: evt, data: data, job: job, exhausted: exhausted}
+	task.mu.Lock()
+	defer task.mu.Unlock()
+	task.deadline = time.AfterFunc(enrichmentWait, func() { task.sendMessage(nil) })
+	return task
+}
+
+// sendMessage emits the message webhook with the enrichment, or without it
+// when res is nil. Only the first call sends; it reports whether the
+// enrichment went out with the message.
+func (t *enrichmentTask) sendMessage(res *messageEnrichment) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.sent {
+		return false
+	}
+	t.sent = true
+	t.deadline.Stop()
+	if res != nil {
+		// A copy, as the handlers started for the message still read it.
+		enriched := *t.data
+		enriched.TranslatedText, enriched.DetectedLanguage = res.TranslatedText, res.DetectedLanguage
+		enriched.Transcript = res.Transcript
+		enriched.ImageAnalysis, enriched.Scan, enriched.Media = res.ImageAnalysis, res.Scan, res.Media
+		t.data = &enriched
+	}
+	emitWebhook("message", t.data)
+	return res != nil
+}
+
+// messageEnrichment is the payload of message.enriched.
+type messageEnrichment struct {
+	ID     types.MessageID `json:"id"`
+	Chat   types.JID       `json:"chat"`
+	Sender types.JID       `json:"sender"`
+
+	TranslatedText   string `json:"translated_text,omitempty"`
+	DetectedLanguage string `json:"detected_language,omitempty"`
+	Transcript       string `json:"transcript,omitempty"` // voice notes
+
+	ImageAnalysis *imageAnalysis `json:"image_analysis,omitempty"`
+	Scan          *scanVerdict   `json:"scan,omitempty"` // documents
+	Media         *mediaInfo     `json:"media,omitempty"`
+}
+
+func (e *messageEnrichment) infected() bool {
+	return e.Scan != nil && e.Scan.Status == "infected"
+}
+
+func (e *messageEnrichment) empty() bool {
+	return e.TranslatedText == "" && e.DetectedLanguage == "" && e.Transcript == "" &&
+		e.ImageAnalysis == nil && e.Scan == nil && e.Media == nil
+}
+
+// needsEnrichment reports whether an inbound message has anything for the
+// enrichment workers to do.
+func needsEnrichment(msg *waE2E.Message) bool {
+	translating := activeTranslator != nil && (translateInbound || translateOutbound)
+	return needsDownload(msg) || (translating && strings.TrimSpace(messageText(msg)) != "")
+}
+
+// enqueueEnrichment hands an inbound message to the enrichment workers,
+// which send its webhook and forward it. It reports false when there is
+// nothing to enrich and the caller should do both itself.
+func enqueueEnrichment(evt *events.Message, data *messageWebhookData) bool {
+	if !needsEnrichment(evt.Message) {
+		return false
+	}
+	job, exhausted := startDownloadJob(context.Background(), evt)
+	task := newEnrichmentTask(evt, data, job, exhausted)
+	select {
+	case enrichmentQueue <- task:
+		return true
+	default:
+	}
+	task.sendMessage(nil)
+	if job == 0 {
+		waLogger.Warnf("Enrichment queue is full, message %s goes without", evt.Info.ID)
+		go forwardMessage(data)
+		return true
+	}
+	waLogger.Warnf("Enrichment queue is full, message %s is left to media job %d", evt.Info.ID, job)
+	releaseMediaJob(job)
+	return true
+}
+
+func runEnrichmentWorkers() {
+	workers := enrichmentWorkers
+	if workers < 1 {
+		workers = 1
+	}
+	for i := 0; i < workers; i++ {
+		go func() {
+			for task := range enrichmentQueue {
+				enrichMessage(task)
+			}
+		}()
+	}
+}
+
+func enrichMessage(task *enrichmentTask) {
+	// The job is finished once the enrichment has been reported.
+	defer finishMediaJob(context.Background(), task.job)
+	ctx, cancel := context.WithTimeout(context.Background(), enrichmentTimeout)
+	defer cancel()
+
+	evt, data := task.evt, task.data
+	res := &messageEnrichment{ID: data.Info.ID, Chat: data.Info.Chat, Sender: data.Info.Sender}
+	if tr, ok := translateIncoming(ctx, data.Info.Chat, evt.Message); ok {
+		res.TranslatedText, res.DetectedLanguage = tr.Text, tr.Source
+	}
+	if !task.exhausted {
+		res.Scan = scanAttachment(ctx, evt)
+		res.Transcript = transcribeVoiceNote(ctx, evt.Message)
+		if !res.infected() {
+			res.ImageAnalysis = analyzeImage(ctx, evt.Message)
+			res.Media = keepInboundMedia(ctx, evt, res.Scan)
+		}
+	}
+	inline := task.sendMessage(res)
+	if !res.infected() {
+		go forwardMessage(task.data)
+	}
+	if res.Transcript != "" {
+		storeMessage(context.Background(), storedMessage{
+			ID:         data.Info.ID,
+			Chat:       data.Info.Chat.ToNonAD().String(),
+			Sender:     data.Info.Sender.ToNonAD().String(),
+			FromMe:     evt.Info.IsFromMe,
+			Type:       messageType(evt.Message),
+			Text:       messageText(evt.Message),
+			Transcript: res.Transcript,
+			Timestamp:  evt.Info.Timestamp,
+		})
+	}
+	if ctx.Err() != nil {
+		waLogger.Warnf("Enrichment of message %s timed out after %s", data.Info.ID, enrichmentTimeout)
+	}
+	if !inline && !res.empty() {
+		emitWebhook("message.enriched", res)
+	}
+}
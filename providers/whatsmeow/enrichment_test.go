@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	waLog "go.mau.fi/whatsmeow/util/log"
+)
+
+func TestEnrichmentInMessageWebhook(t *testing.T) {
+	waLogger = waLog.Noop
+	webhooks := captureWebhooks(t)
+	sender := types.NewJID("15551234567", types.DefaultUserServer)
+	defer func(wait time.Duration) { enrichmentWait = wait }(enrichmentWait)
+	newTask := func(id types.MessageID, wait time.Duration) *enrichmentTask {
+		enrichmentWait = wait
+		evt := &events.Message{Info: types.MessageInfo{MessageSource: types.MessageSource{Chat: sender, Sender: sender}, ID: id}}
+		return newEnrichmentTask(evt, &messageWebhookData{Message: evt}, 0, false)
+	}
+	translation := func(hook capturedWebhook) string {
+		var data struct {
+			TranslatedText string `json:"translated_text"`
+		}
+		if err := json.Unmarshal(hook.Data, &data); err != nil {
+			t.Fatal(err)
+		}
+		return data.TranslatedText
+	}
+	res := &messageEnrichment{TranslatedText: "hello", DetectedLanguage: "es"}
+
+	// Finished in time, the enrichment goes out with the message, once.
+	task := newTask("IN1", time.Hour)
+	if !task.sendMessage(res) {
+		t.Error("enrichment in time wasn't sent inline")
+	}
+	if hook := nextWebhook(t, webhooks); hook.Event != "message" || translation(hook) != "hello" {
+		t.Errorf("got %s %s, want the message with its translation", hook.Event, hook.Data)
+	}
+	if task.sendMessage(nil) {
+		t.Error("message was sent twice")
+	}
+
+	// Past the deadline the message goes out without it.
+	task = newTask("LATE1", time.Millisecond)
+	if hook := nextWebhook(t, webhooks); hook.Event != "message" || translation(hook) != "" {
+		t.Errorf("got %s %s, want the message without a translation", hook.Event, hook.Data)
+	}
+	if task.sendMessage(res) {
+		t.Error("late enrichment was sent inline")
+	}
+	select {
+	case hook := <-webhooks:
+		t.Errorf("unexpected webhook %s %s", hook.Event, hook.Data)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
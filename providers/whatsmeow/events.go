@@ -0,0 +1,311 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// Events eventHandler doesn't act on itself are forwarded as webhooks named
+// <subject>.<what happened>, with the fields a consumer needs rather than
+// whatsmeow's structs (which carry protobufs and raw XML nodes). Events of a
+// type the gateway doesn't know, such as ones added by a whatsmeow update,
+// are forwarded as "unknown" with their Go type name, so nothing is dropped
+// silently.
+
+// forwardEvent emits the webhook of an event eventHandler leaves to it.
+func forwardEvent(evt interface{}) {
+	event, data := describeEvent(evt)
+	if event != "" {
+		emitWebhook(event, data)
+	}
+}
+
+// describeEvent returns the webhook event and data of a whatsmeow event, or
+// an empty event for ones reported elsewhere.
+func describeEvent(evt interface{}) (string, interface{}) {
+	switch v := evt.(type) {
+	// The pairing flow reports the QR codes (pairing.go), and each app
+	// state mutation also arrives as its own event below.
+	case *events.QR, *events.AppState:
+		return "", nil
+
+	case *events.PairSuccess:
+		return "pairing.success", map[string]interface{}{
+			"jid": v.ID.String(), "lid": v.LID.String(), "business_name": v.BusinessName, "platform": v.Platform,
+		}
+	case *events.PairError:
+		return "pairing.failed", map[string]interface{}{
+			"jid": v.ID.String(), "business_name": v.BusinessName, "platform": v.Platform, "error": v.Error.Error(),
+		}
+	case *events.QRScannedWithoutMultidevice:
+		return "pairing.multidevice_required", nil
+
+	case *events.KeepAliveTimeout:
+		return "connection.keepalive_timeout", map[string]interface{}{
+			"error_count": v.ErrorCount, "last_success": v.LastSuccess,
+		}
+	case *events.KeepAliveRestored:
+		return "connection.keepalive_restored", nil
+	case *events.ConnectFailure:
+		return "connection.failed", map[string]interface{}{
+			"code": int(v.Reason), "reason": v.Reason.String(), "message": v.Message,
+		}
+	case *events.StreamError:
+		return "connection.stream_error", map[string]interface{}{"code": v.Code}
+	case *events.ManualLoginReconnect:
+		return "connection.login_reconnect", nil
+
+	case *events.LoggedOut:
+		return "session.logged_out", map[string]interface{}{
+			"on_connect": v.OnConnect, "code": int(v.Reason), "reason": v.Reason.String(),
+		}
+	case *events.StreamReplaced:
+		return "session.replaced", nil
+	case *events.TemporaryBan:
+		return "session.temporary_ban", map[string]interface{}{
+			"code": int(v.Code), "expires_in_seconds": int64(v.Expire.Seconds()), "message": v.String(),
+		}
+
+	case *events.UndecryptableMessage:
+		return "message.undecryptable", map[string]interface{}{
+			"info": v.Info, "unavailable": v.IsUnavailable,
+		}
+	case *events.ChatPresence:
+		return "chat.presence", map[string]interface{}{
+			"chat": v.Chat.String(), "sender": v.Sender.String(), "state": v.State, "media": v.Media,
+		}
+	case *events.Presence:
+		data := map[string]interface{}{"jid": v.From.String(), "online": !v.Unavailable}
+		if !v.LastSeen.IsZero() {
+			data["last_seen"] = v.LastSeen
+		}
+		return "contact.presence", data
+	case *events.Receipt:
+		// Delivery receipts have no type on the wire.
+		kind := string(v.Type)
+		if v.Type == types.ReceiptTypeDelivered {
+			kind = "delivered"
+		}
+		return "message.receipt", map[string]interface{}{
+			"chat": v.Chat.String(), "sender": v.Sender.String(), "from_me": v.IsFromMe, "ids": v.MessageIDs,
+			"type": kind, "timestamp": v.Timestamp,
+		}
+	case *events.MediaRetry:
+		data := map[string]interface{}{
+			"chat": v.ChatID.String(), "sender": v.SenderID.String(), "from_me": v.FromMe, "id": v.MessageID,
+			"timestamp": v.Timestamp,
+		}
+		if v.Error != nil {
+			data["error_code"] = v.Error.Code
+		}
+		return "media.retry", data
+
+	case *events.Picture:
+		return "picture.changed", map[string]interface{}{
+			"jid": v.JID.String(), "author": jidString(v.Author), "timestamp": v.Timestamp,
+			"removed": v.Remove, "picture_id": v.PictureID,
+		}
+	case *events.UserAbout:
+		return "contact.about_changed", map[string]interface{}{
+			"jid": v.JID.String(), "about": v.Status, "timestamp": v.Timestamp,
+		}
+	case *events.PushName:
+		return "contact.push_name_changed", map[string]interface{}{
+			"jid": v.JID.String(), "old": v.OldPushName, "new": v.NewPushName,
+		}
+	case *events.BusinessName:
+		return "contact.business_name_changed", map[string]interface{}{
+			"jid": v.JID.String(), "old": v.OldBusinessName, "new": v.NewBusinessName,
+		}
+	case *events.Contact:
+		return "contact.updated", map[string]interface{}{
+			"jid": v.JID.String(), "full_name": v.Action.GetFullName(), "first_name": v.Action.GetFirstName(),
+			"timestamp": v.Timestamp,
+		}
+	case *events.UserStatusMute:
+		return "contact.status_muted", map[string]interface{}{
+			"jid": v.JID.String(), "muted": v.Action.GetMuted(), "timestamp": v.Timestamp,
+		}
+	case *events.Blocklist:
+		changes := make([]map[string]interface{}, 0, len(v.Changes))
+		for _, c := range v.Changes {
+			changes = append(changes, map[string]interface{}{"jid": c.JID.String(), "action": c.Action})
+		}
+		return "contact.blocklist_changed", map[string]interface{}{"action": v.Action, "changes": changes}
+	case *events.PrivacySettings:
+		return "privacy.changed", v.NewSettings
+
+	case *events.HistorySync:
+		return "sync.history", map[string]interface{}{
+			"type": v.Data.GetSyncType().String(), "progress": v.Data.GetProgress(),
+			"conversations": len(v.Data.GetConversations()),
+		}
+	case *events.AppStateSyncComplete:
+		return "sync.appstate_completed", map[string]interface{}{"name": v.Name}
+
+	case *events.JoinedGroup:
+		return "group.joined", map[string]interface{}{
+			"jid": v.JID.String(), "name": v.Name, "reason": v.Reason, "type": v.Type, "sender": jidPtrString(v.Sender),
+		}
+
+	case *events.GroupInfo:
+		// Only the settings the notification changed are included.
+		data := map[string]interface{}{"jid": v.JID.String(), "sender": jidPtrString(v.Sender), "timestamp": v.Timestamp}
+		if v.Name != nil {
+			data["name"] = v.Name.Name
+		}
+		if v.Topic != nil {
+			data["topic"] = v.Topic.Topic
+		}
+		if v.Locked != nil {
+			data["locked"] = v.Locked.IsLocked
+		}
+		if v.Announce != nil {
+			data["announce"] = v.Announce.IsAnnounce
+		}
+		if v.Ephemeral != nil {
+			data["disappearing_timer"] = v.Ephemeral.DisappearingTimer
+		}
+		if v.Delete != nil {
+			data["deleted"] = true
+		}
+		for key, participants := range map[string][]types.JID{"join": v.Join, "leave": v.Leave, "promote": v.Promote, "demote": v.Demote} {
+			if len(participants) > 0 {
+				data[key] = jidStrings(participants)
+			}
+		}
+		return "group.updated", data
+
+	case *events.NewsletterJoin:
+		return "newsletter.joined", map[string]interface{}{"jid": v.ID.String(), "name": v.ThreadMeta.Name.Text}
+	case *events.NewsletterLeave:
+		return "newsletter.left", map[string]interface{}{"jid": v.ID.String(), "role": v.Role}
+	case *events.NewsletterMuteChange:
+		return "newsletter.mute_changed", map[string]interface{}{"jid": v.ID.String(), "mute": v.Mute}
+	case *events.NewsletterLiveUpdate:
+		posts := make([]map[string]interface{}, 0, len(v.Messages))
+		for _, msg := range v.Messages {
+			posts = append(posts, map[string]interface{}{
+				"server_id": msg.MessageServerID, "message_id": msg.MessageID, "views": msg.ViewsCount,
+				"reactions": msg.ReactionCounts,
+			})
+		}
+		return "newsletter.stats_updated", map[string]interface{}{"jid": v.JID.String(), "timestamp": v.Time, "posts": posts}
+
+	case *events.CallOffer:
+		return "call.offer", callEventData(v.BasicCallMeta, nil)
+	case *events.CallOfferNotice:
+		return "call.offer", callEventData(v.BasicCallMeta, map[string]interface{}{"media": v.Media, "type": v.Type})
+	case *events.CallAccept:
+		return "call.accepted", callEventData(v.BasicCallMeta, nil)
+	case *events.CallReject:
+		return "call.rejected", callEventData(v.BasicCallMeta, nil)
+	case *events.CallTerminate:
+		return "call.ended", callEventData(v.BasicCallMeta, map[string]interface{}{"reason": v.Reason})
+	// Signalling within a call the gateway can't take part in.
+	case *events.CallPreAccept, *events.CallTransport, *events.CallRelayLatency:
+		return "", nil
+
+	case *events.Mute:
+		return "chat.muted", map[string]interface{}{
+			"jid": v.JID.String(), "muted": v.Action.GetMuted(), "until": v.Action.GetMuteEndTimestamp(), "timestamp": v.Timestamp,
+		}
+	case *events.Pin:
+		return "chat.pinned", map[string]interface{}{
+			"jid": v.JID.String(), "pinned": v.Action.GetPinned(), "timestamp": v.Timestamp,
+		}
+	case *events.Archive:
+		return "chat.archived", map[string]interface{}{
+			"jid": v.JID.String(), "archived": v.Action.GetArchived(), "timestamp": v.Timestamp,
+		}
+	case *events.MarkChatAsRead:
+		return "chat.marked_read", map[string]interface{}{
+			"jid": v.JID.String(), "read": v.Action.GetRead(), "timestamp": v.Timestamp,
+		}
+	case *events.ClearChat:
+		return "chat.cleared", map[string]interface{}{"jid": v.JID.String(), "timestamp": v.Timestamp}
+	case *events.DeleteChat:
+		return "chat.deleted", map[string]interface{}{"jid": v.JID.String(), "timestamp": v.Timestamp}
+	case *events.Star:
+		return "message.starred", map[string]interface{}{
+			"chat": v.ChatJID.String(), "sender": v.SenderJID.String(), "from_me": v.IsFromMe, "id": v.MessageID,
+			"starred": v.Action.GetStarred(), "timestamp": v.Timestamp,
+		}
+	case *events.DeleteForMe:
+		return "message.deleted_for_me", map[string]interface{}{
+			"chat": v.ChatJID.String(), "sender": v.SenderJID.String(), "from_me": v.IsFromMe, "id": v.MessageID,
+			"timestamp": v.Timestamp,
+		}
+
+	case *events.LabelEdit:
+		return "label.edited", map[string]interface{}{
+			"id": v.LabelID, "name": v.Action.GetName(), "color": v.Action.GetColor(), "deleted": v.Action.GetDeleted(),
+			"timestamp": v.Timestamp,
+		}
+	case *events.LabelAssociationChat:
+		return "label.chat", map[string]interface{}{
+			"id": v.LabelID, "jid": v.JID.String(), "labeled": v.Action.GetLabeled(), "timestamp": v.Timestamp,
+		}
+	case *events.LabelAssociationMessage:
+		return "label.message", map[string]interface{}{
+			"id": v.LabelID, "jid": v.JID.String(), "message_id": v.MessageID, "labeled": v.Action.GetLabeled(),
+			"timestamp": v.Timestamp,
+		}
+
+	case *events.PushNameSetting:
+		return "settings.push_name_changed", map[string]interface{}{"name": v.Action.GetName(), "timestamp": v.Timestamp}
+	case *events.UnarchiveChatsSetting:
+		return "settings.unarchive_chats_changed", map[string]interface{}{
+			"unarchive_chats": v.Action.GetUnarchiveChats(), "timestamp": v.Timestamp,
+		}
+	}
+	return "unknown", unknownEvent(evt)
+}
+
+func callEventData(meta types.BasicCallMeta, extra map[string]interface{}) map[string]interface{} {
+	data := map[string]interface{}{
+		"call_id": meta.CallID, "from": meta.From.String(), "creator": meta.CallCreator.String(), "timestamp": meta.Timestamp,
+	}
+	for k, v := range extra {
+		data[k] = v
+	}
+	return data
+}
+
+// unknownEvent is the webhook data of an event of unknown type: its Go type
+// name and, if it marshals, the event itself.
+func unknownEvent(evt interface{}) map[string]interface{} {
+	typ := fmt.Sprintf("%T", evt)
+	waLogger.Debugf("Forwarding event of unknown type %s", typ)
+	data := map[string]interface{}{"type": typ}
+	if raw, err := json.Marshal(evt); err == nil {
+		data["event"] = json.RawMessage(raw)
+	}
+	return data
+}
+
+// jidString is a JID as a string, or empty if it's unset.
+func jidString(jid types.JID) string {
+	if jid.IsEmpty() {
+		return ""
+	}
+	return jid.String()
+}
+
+func jidPtrString(jid *types.JID) string {
+	if jid == nil {
+		return ""
+	}
+	return jidString(*jid)
+}
+
+func jidStrings(jids []types.JID) []string {
+	out := make([]string, len(jids))
+	for i, jid := range jids {
+		out[i] = jid.String()
+	}
+	return out
+}
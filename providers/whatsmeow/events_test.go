@@ -0,0 +1,85 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	waLog "go.mau.fi/whatsmeow/util/log"
+)
+
+// customEvent stands in for an event type added by a whatsmeow update.
+type customEvent struct {
+	JID   types.JID
+	Count int
+}
+
+func TestEventWebhooks(t *testing.T) {
+	waLogger = waLog.Noop
+	webhooks := captureWebhooks(t)
+	user := types.NewJID("15551234567", types.DefaultUserServer)
+	group := types.NewJID("120363000000000000", types.GroupServer)
+	newsletter := types.NewJID("120363000000000001", types.NewsletterServer)
+	at := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	expect := func(evt interface{}, event, data string) {
+		t.Helper()
+		eventHandler(evt)
+		hook := nextWebhook(t, webhooks)
+		if hook.Event != event || string(hook.Data) != data {
+			t.Errorf("%T sent %s %s, want %s %s", evt, hook.Event, hook.Data, event, data)
+		}
+	}
+
+	expect(&events.PairSuccess{ID: user, LID: types.NewJID("123456789", types.HiddenUserServer), BusinessName: "Shop", Platform: "android"},
+		"pairing.success", `{"business_name":"Shop","jid":"15551234567@s.whatsapp.net","lid":"123456789@lid","platform":"android"}`)
+	expect(&events.KeepAliveTimeout{ErrorCount: 3, LastSuccess: at},
+		"connection.keepalive_timeout", `{"error_count":3,"last_success":"2026-03-01T12:00:00Z"}`)
+	// A picture removed by its owner has no author.
+	expect(&events.Picture{JID: user, Timestamp: at, Remove: true},
+		"picture.changed", `{"author":"","jid":"15551234567@s.whatsapp.net","picture_id":"","removed":true,"timestamp":"2026-03-01T12:00:00Z"}`)
+	expect(&events.PushName{JID: user, OldPushName: "Ann", NewPushName: "Anna"},
+		"contact.push_name_changed", `{"jid":"15551234567@s.whatsapp.net","new":"Anna","old":"Ann"}`)
+	expect(&events.Blocklist{Action: events.BlocklistAction("modify"), Changes: []events.BlocklistChange{{JID: user, Action: events.BlocklistChangeAction("block")}}},
+		"contact.blocklist_changed", `{"action":"modify","changes":[{"action":"block","jid":"15551234567@s.whatsapp.net"}]}`)
+	expect(&events.JoinedGroup{Reason: "invite", GroupInfo: types.GroupInfo{JID: group, GroupName: types.GroupName{Name: "Team"}}},
+		"group.joined", `{"jid":"120363000000000000@g.us","name":"Team","reason":"invite","sender":"","type":""}`)
+	expect(&events.CallTerminate{BasicCallMeta: types.BasicCallMeta{From: user, CallCreator: user, CallID: "CALL1", Timestamp: at}, Reason: "timeout"},
+		"call.ended", `{"call_id":"CALL1","creator":"15551234567@s.whatsapp.net","from":"15551234567@s.whatsapp.net","reason":"timeout","timestamp":"2026-03-01T12:00:00Z"}`)
+	expect(&events.DeleteChat{JID: group, Timestamp: at},
+		"chat.deleted", `{"jid":"120363000000000000@g.us","timestamp":"2026-03-01T12:00:00Z"}`)
+
+	// Events the gateway also records go out once it has.
+	expect(&events.Receipt{MessageSource: types.MessageSource{Chat: user, Sender: user}, MessageIDs: []types.MessageID{"A1", "A2"}, Timestamp: at},
+		"message.receipt", `{"chat":"15551234567@s.whatsapp.net","from_me":false,"ids":["A1","A2"],"sender":"15551234567@s.whatsapp.net","timestamp":"2026-03-01T12:00:00Z","type":"delivered"}`)
+	expect(&events.Receipt{MessageSource: types.MessageSource{Chat: user, Sender: user}, MessageIDs: []types.MessageID{"A1"}, Timestamp: at, Type: types.ReceiptTypeRead},
+		"message.receipt", `{"chat":"15551234567@s.whatsapp.net","from_me":false,"ids":["A1"],"sender":"15551234567@s.whatsapp.net","timestamp":"2026-03-01T12:00:00Z","type":"read"}`)
+	expect(&events.Presence{From: user, Unavailable: true, LastSeen: at},
+		"contact.presence", `{"jid":"15551234567@s.whatsapp.net","last_seen":"2026-03-01T12:00:00Z","online":false}`)
+	expect(&events.Presence{From: user},
+		"contact.presence", `{"jid":"15551234567@s.whatsapp.net","online":true}`)
+	expect(&events.GroupInfo{JID: group, Sender: &user, Timestamp: at, Name: &types.GroupName{Name: "Team"}, Join: []types.JID{user}},
+		"group.updated", `{"jid":"120363000000000000@g.us","join":["15551234567@s.whatsapp.net"],"name":"Team","sender":"15551234567@s.whatsapp.net","timestamp":"2026-03-01T12:00:00Z"}`)
+	expect(&events.GroupInfo{JID: group, Timestamp: at, Announce: &types.GroupAnnounce{IsAnnounce: true}},
+		"group.updated", `{"announce":true,"jid":"120363000000000000@g.us","sender":"","timestamp":"2026-03-01T12:00:00Z"}`)
+	expect(&events.MediaRetry{MessageID: "M1", ChatID: user, SenderID: user, Timestamp: at, Error: &events.MediaRetryError{Code: 2}},
+		"media.retry", `{"chat":"15551234567@s.whatsapp.net","error_code":2,"from_me":false,"id":"M1","sender":"15551234567@s.whatsapp.net","timestamp":"2026-03-01T12:00:00Z"}`)
+	openTestDB(t)
+	expect(&events.NewsletterLiveUpdate{JID: newsletter, Time: at, Messages: []*types.NewsletterMessage{{MessageServerID: 7, ViewsCount: 40, ReactionCounts: map[string]int{"👍": 3}}}},
+		"newsletter.stats_updated", `{"jid":"120363000000000001@newsletter","posts":[{"message_id":"","reactions":{"👍":3},"server_id":7,"views":40}],"timestamp":"2026-03-01T12:00:00Z"}`)
+
+	// Events of a type the gateway doesn't know go out with their type name,
+	// and as they are if they marshal.
+	expect(&customEvent{JID: user, Count: 2},
+		"unknown", `{"event":{"JID":"15551234567@s.whatsapp.net","Count":2},"type":"*main.customEvent"}`)
+	expect(make(chan int), "unknown", `{"type":"chan int"}`)
+
+	// Call signalling isn't forwarded, and the QR codes and app state come
+	// from the pairing flow and their own events.
+	for _, evt := range []interface{}{&events.CallTransport{}, &events.CallRelayLatency{}, &events.QR{Codes: []string{"code"}}, &events.AppState{}} {
+		if event, _ := describeEvent(evt); event != "" {
+			t.Errorf("%T is forwarded as %s", evt, event)
+		}
+	}
+}
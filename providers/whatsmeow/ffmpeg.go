@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Media conversions (voice notes, stickers, GIFs, thumbnails, transcoding)
+// shell out to ffmpeg, which the container image ships with.
+
+var (
+	ffmpegPath    = envString("FFMPEG_PATH", "ffmpeg")
+	ffmpegTimeout = envDuration("FFMPEG_TIMEOUT", 2*time.Minute)
+)
+
+// errUnsupportedMedia is returned when ffmpeg can't read or convert the
+// input.
+var errUnsupportedMedia = errors.New("unsupported media format")
+
+// runFFmpeg converts data with ffmpeg and returns what it writes to stdout;
+// args are the options between the input and the output. The input goes
+// through a temporary file since some containers (e.g. M4A) can't be read
+// from a pipe.
+func runFFmpeg(ctx context.Context, data []byte, args ...string) ([]byte, error) {
+	in, err := os.CreateTemp("", "media-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(in.Name())
+	_, err = in.Write(data)
+	if cerr := in.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, err
+	}
+	return runFFmpegFile(ctx, in.Name(), args...)
+}
+
+// runFFmpegFile is runFFmpeg for a file already on disk.
+func runFFmpegFile(ctx context.Context, path string, args ...string) ([]byte, error) {
+	var out bytes.Buffer
+	if err := execFFmpeg(ctx, path, "pipe:1", &out, args...); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// runFFmpegToFile converts the file at in to a new file at out. Unlike a
+// pipe, a file lets ffmpeg seek back, e.g. to put the index of an MP4 first.
+func runFFmpegToFile(ctx context.Context, in, out string, args ...string) error {
+	return execFFmpeg(ctx, in, out, nil, append(args, "-y")...)
+}
+
+func execFFmpeg(ctx context.Context, in, out string, stdout io.Writer, args ...string) error {
+	ctx, cancel := context.WithTimeout(ctx, ffmpegTimeout)
+	defer cancel()
+	cmdArgs := append([]string{"-hide_banner", "-loglevel", "error", "-nostdin", "-i", in}, args...)
+	cmd := exec.CommandContext(ctx, ffmpegPath, append(cmdArgs, out)...)
+	var stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && ctx.Err() == nil {
+			return fmt.Errorf("%w: %s", errUnsupportedMedia, strings.TrimSpace(stderr.String()))
+		}
+		return fmt.Errorf("ffmpeg failed: %w", err)
+	}
+	return nil
+}
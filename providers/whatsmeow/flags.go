@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"hash/fnv"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Feature flags switch subsystems on and off for this session without a
+// restart. FEATURE_FLAGS sets the fleet-wide defaults as name=on, name=off
+// or name=N% to stage a rollout: a flag at N% is on for the instances whose
+// INSTANCE_ID hashes into the first N of 100 buckets, so raising N turns it
+// on for more of the fleet without switching any instance back off. An
+// override set through /admin/flags takes precedence until it's removed.
+
+const (
+	flagResponder    = "responder"
+	flagAutoDownload = "auto_download"
+	flagCampaigns    = "campaigns"
+	flagReadReceipts = "read_receipts"
+)
+
+type featureFlagDef struct {
+	Name        string
+	Description string
+}
+
+// featureFlagDefs lists the flags; all of them are on by default.
+var featureFlagDefs = []featureFlagDef{
+	{flagResponder, "bot_enabled in message webhooks and canned auto-replies; off marks every chat as handled by a human"},
+	{flagAutoDownload, "downloading media on arrival (auto in the media download policy) and offloading it; off leaves it on demand"},
+	{flagCampaigns, "sends with a campaign, including surveys; off refuses them"},
+	{flagReadReceipts, "read receipts from the auto-read policy; off suppresses them"},
+}
+
+var errCampaignsDisabled = errors.New("campaign sends are disabled by the campaigns feature flag")
+
+type featureFlagDefault struct {
+	enabled bool
+	rollout int // percentage, -1 when given as on or off
+}
+
+type featureFlagOverride struct {
+	enabled   bool
+	updatedAt time.Time
+}
+
+var (
+	featureFlagMu        sync.RWMutex
+	featureFlagDefaults  = map[string]featureFlagDefault{}
+	featureFlagOverrides = map[string]featureFlagOverride{}
+)
+
+func knownFeatureFlag(name string) bool {
+	for _, def := range featureFlagDefs {
+		if def.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// featureEnabled reports whether the named flag is on for this session.
+func featureEnabled(name string) bool {
+	featureFlagMu.RLock()
+	defer featureFlagMu.RUnlock()
+	if o, ok := featureFlagOverrides[name]; ok {
+		return o.enabled
+	}
+	if d, ok := featureFlagDefaults[name]; ok {
+		return d.enabled
+	}
+	return true
+}
+
+// rolloutBucket places this instance in one of 100 buckets for a flag, the
+// same one on every restart. Each flag hashes differently, so the instances
+// that get one flag first don't also get every other one first.
+func rolloutBucket(name string) int {
+	h := fnv.New32a()
+	h.Write([]byte(name + "/" + instanceID))
+	return int(h.Sum32() % 100)
+}
+
+// parseFeatureFlagDefaults parses FEATURE_FLAGS, skipping invalid entries.
+func parseFeatureFlagDefaults(raw string) map[string]featureFlagDefault {
+	defaults := map[string]featureFlagDefault{}
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, value, _ := strings.Cut(entry, "=")
+		name, value = strings.TrimSpace(name), strings.ToLower(strings.TrimSpace(value))
+		if !knownFeatureFlag(name) {
+			waLogger.Errorf("Ignoring unknown feature flag %q in FEATURE_FLAGS", name)
+			continue
+		}
+		switch value {
+		case "on", "true", "1":
+			defaults[name] = featureFlagDefault{enabled: true, rollout: -1}
+		case "off", "false", "0":
+			defaults[name] = featureFlagDefault{enabled: false, rollout: -1}
+		default:
+			pct, err := strconv.Atoi(strings.TrimSuffix(value, "%"))
+			if err != nil || !strings.HasSuffix(value, "%") || pct < 0 || pct > 100 {
+				waLogger.Errorf("Ignoring invalid value %q for feature flag %s in FEATURE_FLAGS", value, name)
+				continue
+			}
+			defaults[name] = featureFlagDefault{enabled: rolloutBucket(name) < pct, rollout: pct}
+		}
+	}
+	return defaults
+}
+
+// loadFeatureFlags applies FEATURE_FLAGS and the persisted overrides.
+func loadFeatureFlags(ctx context.Context) {
+	defaults := parseFeatureFlagDefaults(envString("FEATURE_FLAGS", ""))
+	overrides := map[string]featureFlagOverride{}
+	rows, err := gatewayDB.QueryContext(ctx, `SELECT name, enabled, updated_at FROM feature_flags`)
+	if err != nil {
+		waLogger.Errorf("Failed to load feature flags: %v", err)
+	} else {
+		defer rows.Close()
+		for rows.Next() {
+			var name string
+			var enabled bool
+			var updatedAt int64
+			if err := rows.Scan(&name, &enabled, &updatedAt); err != nil {
+				waLogger.Errorf("Failed to load feature flags: %v", err)
+				break
+			}
+			overrides[name] = featureFlagOverride{enabled: enabled, updatedAt: time.Unix(updatedAt, 0)}
+		}
+	}
+	featureFlagMu.Lock()
+	featureFlagDefaults, featureFlagOverrides = defaults, overrides
+	featureFlagMu.Unlock()
+	for _, def := range featureFlagDefs {
+		if !featureEnabled(def.Name) {
+			waLogger.Infof("Feature %s is disabled", def.Name)
+		}
+	}
+}
+
+func setFeatureFlag(ctx context.Context, name string, enabled bool) error {
+	now := time.Now()
+	_, err := gatewayDB.ExecContext(ctx, `
+		INSERT INTO feature_flags (name, enabled, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT (name) DO UPDATE SET enabled = excluded.enabled, updated_at = excluded.updated_at`,
+		name, enabled, now.Unix())
+	if err != nil {
+		return err
+	}
+	featureFlagMu.Lock()
+	featureFlagOverrides[name] = featureFlagOverride{enabled: enabled, updatedAt: now}
+	featureFlagMu.Unlock()
+	return nil
+}
+
+func clearFeatureFlag(ctx context.Context, name string) error {
+	if _, err := gatewayDB.ExecContext(ctx, `DELETE FROM feature_flags WHERE name = ?`, name); err != nil {
+		return err
+	}
+	featureFlagMu.Lock()
+	delete(featureFlagOverrides, name)
+	featureFlagMu.Unlock()
+	return nil
+}
+
+// checkCampaignsEnabled turns away campaign sends while the campaigns flag
+// is off.
+func checkCampaignsEnabled(campaign string) error {
+	if campaign != "" && !featureEnabled(flagCampaigns) {
+		return errCampaignsDisabled
+	}
+	return nil
+}
+
+type featureFlagState struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Enabled     bool       `json:"enabled"`
+	Source      string     `json:"source"`            // default, env, rollout or override
+	Default     bool       `json:"default"`           // what it is without the override
+	Rollout     *int       `json:"rollout,omitempty"` // percentage, for a staged rollout
+	UpdatedAt   *time.Time `json:"updated_at,omitempty"`
+}
+
+func featureFlagStates() []featureFlagState {
+	featureFlagMu.RLock()
+	defer featureFlagMu.RUnlock()
+	states := make([]featureFlagState, 0, len(featureFlagDefs))
+	for _, def := range featureFlagDefs {
+		s := featureFlagState{Name: def.Name, Description: def.Description, Default: true, Source: "default"}
+		if d, ok := featureFlagDefaults[def.Name]; ok {
+			s.Default, s.Source = d.enabled, "env"
+			if d.rollout >= 0 {
+				rollout := d.rollout
+				s.Rollout, s.Source = &rollout, "rollout"
+			}
+		}
+		s.Enabled = s.Default
+		if o, ok := featureFlagOverrides[def.Name]; ok {
+			updatedAt := o.updatedAt
+			s.Enabled, s.Source, s.UpdatedAt = o.enabled, "override", &updatedAt
+		}
+		states = append(states, s)
+	}
+	return states
+}
+
+func listFeatureFlags(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{"flags": featureFlagStates()})
+}
+
+func writeFeatureFlag(w http.ResponseWriter, name string) {
+	for _, s := range featureFlagStates() {
+		if s.Name == name {
+			writeJSON(w, http.StatusOK, s)
+			return
+		}
+	}
+}
+
+type featureFlagRequest struct {
+	Enabled *bool `json:"enabled"`
+}
+
+func putFeatureFlag(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if !knownFeatureFlag(name) {
+		http.Error(w, "Unknown feature flag", http.StatusNotFound)
+		return
+	}
+	var req featureFlagRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Enabled == nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := setFeatureFlag(r.Context(), name, *req.Enabled); err != nil {
+		waLogger.Errorf("Failed to set feature flag %s: %v", name, err)
+		http.Error(w, "Failed to set feature flag", http.StatusInternalServerError)
+		return
+	}
+	waLogger.Infof("Feature %s overridden: enabled=%t", name, *req.Enabled)
+	writeFeatureFlag(w, name)
+}
+
+// deleteFeatureFlag removes the override, going back to the default.
+func deleteFeatureFlag(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if !knownFeatureFlag(name) {
+		http.Error(w, "Unknown feature flag", http.StatusNotFound)
+		return
+	}
+	if err := clearFeatureFlag(r.Context(), name); err != nil {
+		waLogger.Errorf("Failed to clear feature flag %s: %v", name, err)
+		http.Error(w, "Failed to clear feature flag", http.StatusInternalServerError)
+		return
+	}
+	writeFeatureFlag(w, name)
+}
@@ -0,0 +1,374 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"google.golang.org/protobuf/proto"
+)
+
+// Forward rules copy matching inbound messages from one chat to another, for
+// example from a customer to an internal ops group. Forwarded copies carry a
+// prefix naming the original sender. As a rule sends other people's
+// messages elsewhere, managing rules takes the admin key.
+//
+// Loop protection: our own messages are never forwarded, and copies are
+// marked as forwarded with WhatsApp's forwarding score, which each hop
+// increases. Messages that already reached FORWARD_MAX_SCORE are not
+// forwarded again, so rules pointing at each other (even across gateway
+// instances) stop after a few hops.
+
+var forwardMaxScore = envInt("FORWARD_MAX_SCORE", 3)
+
+const defaultForwardPrefix = "From {name} (+{phone}):"
+
+type forwardRule struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name,omitempty"`
+	Source    string    `json:"source"`
+	Target    string    `json:"target"`
+	Contains  []string  `json:"contains,omitempty"` // any keyword, case-insensitive
+	Pattern   string    `json:"pattern,omitempty"`  // regular expression on the text
+	Types     []string  `json:"types,omitempty"`    // message types; empty matches all forwardable types
+	Prefix    string    `json:"prefix"`             // {name}, {phone} and {chat} are filled in
+	Enabled   bool      `json:"enabled"`
+	CreatedAt time.Time `json:"created_at"`
+
+	patternRe *regexp.Regexp
+}
+
+const forwardRuleColumns = `id, name, source_chat, target_chat, contains, pattern, types, prefix, enabled, created_at`
+
+func scanForwardRule(scan func(dest ...interface{}) error) (forwardRule, error) {
+	var rule forwardRule
+	var contains, typs string
+	var created int64
+	err := scan(&rule.ID, &rule.Name, &rule.Source, &rule.Target, &contains, &rule.Pattern, &typs,
+		&rule.Prefix, &rule.Enabled, &created)
+	if err != nil {
+		return rule, err
+	}
+	json.Unmarshal([]byte(contains), &rule.Contains)
+	json.Unmarshal([]byte(typs), &rule.Types)
+	rule.CreatedAt = time.Unix(created, 0).UTC()
+	if rule.Pattern != "" {
+		rule.patternRe, _ = regexp.Compile(rule.Pattern)
+	}
+	return rule, nil
+}
+
+// forwardableTypes are the message types a rule can copy.
+var forwardableTypes = map[string]bool{
+	"text": true, "image": true, "video": true, "audio": true, "voice": true,
+	"document": true, "sticker": true, "location": true, "contact": true,
+}
+
+func (rule *forwardRule) matches(msgType, text string) bool {
+	if len(rule.Types) > 0 {
+		found := false
+		for _, t := range rule.Types {
+			found = found || t == msgType
+		}
+		if !found {
+			return false
+		}
+	} else if !forwardableTypes[msgType] {
+		return false
+	}
+	if len(rule.Contains) > 0 {
+		lower := strings.ToLower(text)
+		found := false
+		for _, keyword := range rule.Contains {
+			found = found || strings.Contains(lower, strings.ToLower(keyword))
+		}
+		if !found {
+			return false
+		}
+	}
+	if rule.Pattern != "" && (rule.patternRe == nil || !rule.patternRe.MatchString(text)) {
+		return false
+	}
+	return true
+}
+
+// messageContextInfo returns the context info of the message's content, if
+// the content type carries one.
+func messageContextInfo(msg *waE2E.Message) *waE2E.ContextInfo {
+	switch {
+	case msg.ExtendedTextMessage != nil:
+		return msg.GetExtendedTextMessage().GetContextInfo()
+	case msg.ImageMessage != nil:
+		return msg.GetImageMessage().GetContextInfo()
+	case msg.VideoMessage != nil:
+		return msg.GetVideoMessage().GetContextInfo()
+	case msg.AudioMessage != nil:
+		return msg.GetAudioMessage().GetContextInfo()
+	case msg.DocumentMessage != nil:
+		return msg.GetDocumentMessage().GetContextInfo()
+	case msg.StickerMessage != nil:
+		return msg.GetStickerMessage().GetContextInfo()
+	case msg.LocationMessage != nil:
+		return msg.GetLocationMessage().GetContextInfo()
+	case msg.ContactMessage != nil:
+		return msg.GetContactMessage().GetContextInfo()
+	}
+	return nil
+}
+
+// forwardCopy builds the forwarded copy of a message. Text and captions get
+// the prefix inline; for content without a caption the prefix is returned
+// separately to be sent first.
+func forwardCopy(msg *waE2E.Message, prefix string, score uint32) (*waE2E.Message, string) {
+	ctxInfo := &waE2E.ContextInfo{IsForwarded: proto.Bool(true), ForwardingScore: proto.Uint32(score)}
+	out := proto.Clone(msg).(*waE2E.Message)
+	switch {
+	case out.Conversation != nil || out.ExtendedTextMessage != nil:
+		return &waE2E.Message{ExtendedTextMessage: &waE2E.ExtendedTextMessage{
+			Text:        proto.String(prefix + "\n" + messageText(msg)),
+			ContextInfo: ctxInfo,
+		}}, ""
+	case out.ImageMessage != nil:
+		out.ImageMessage.ContextInfo = ctxInfo
+	case out.VideoMessage != nil:
+		out.VideoMessage.ContextInfo = ctxInfo
+	case out.DocumentMessage != nil:
+		out.DocumentMessage.ContextInfo = ctxInfo
+	case out.AudioMessage != nil:
+		out.AudioMessage.ContextInfo = ctxInfo
+		return out, prefix
+	case out.StickerMessage != nil:
+		out.StickerMessage.ContextInfo = ctxInfo
+		return out, prefix
+	case out.LocationMessage != nil:
+		out.LocationMessage.ContextInfo = ctxInfo
+		return out, prefix
+	case out.ContactMessage != nil:
+		out.ContactMessage.ContextInfo = ctxInfo
+		return out, prefix
+	default:
+		return out, prefix
+	}
+	caption := prefix
+	if text := messageText(msg); text != "" {
+		caption += "\n" + text
+	}
+	setMessageText(out, caption)
+	return out, ""
+}
+
+// forwardMessage applies the forward rules of the chat a message arrived in.
+func forwardMessage(data *messageWebhookData) {
+	if data.Info.IsFromMe || gatewayDB == nil {
+		return
+	}
+	ctx := context.Background()
+	score := messageContextInfo(data.Message.Message).GetForwardingScore()
+	if forwardMaxScore > 0 && int(score) >= forwardMaxScore {
+		return
+	}
+	chat := data.Info.Chat.ToNonAD()
+	rows, err := gatewayDB.QueryContext(ctx,
+		`SELECT `+forwardRuleColumns+` FROM forward_rules WHERE enabled = 1 AND source_chat = ? ORDER BY id`, chat.String())
+	if err != nil {
+		waLogger.Errorf("Failed to load forward rules of %s: %v", chat, err)
+		return
+	}
+	var rules []forwardRule
+	for rows.Next() {
+		rule, err := scanForwardRule(rows.Scan)
+		if err != nil {
+			waLogger.Errorf("Failed to scan forward rule: %v", err)
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	rows.Close()
+
+	msgType := messageType(data.Message.Message)
+	text := messageText(data.Message.Message)
+	for _, rule := range rules {
+		if !rule.matches(msgType, text) {
+			continue
+		}
+		target, ok := parseJID(rule.Target)
+		if !ok || target == chat {
+			continue
+		}
+		name := data.DisplayName
+		if name == "" {
+			name = "+" + data.Info.Sender.User
+		}
+		prefix := strings.NewReplacer(
+			"{name}", name,
+			"{phone}", data.Info.Sender.User,
+			"{chat}", chat.User,
+		).Replace(rule.Prefix)
+		copied, separatePrefix := forwardCopy(data.Message.Message, prefix, score+1)
+		if separatePrefix != "" {
+			intro := &waE2E.Message{Conversation: proto.String(separatePrefix)}
+			if _, err := sendOrQueue(ctx, target, intro, sendOptions{AllowDuplicate: true}); err != nil {
+				waLogger.Errorf("Forward rule %d failed to send to %s: %v", rule.ID, target, err)
+				continue
+			}
+		}
+		res, err := sendOrQueue(ctx, target, copied, sendOptions{AllowDuplicate: true})
+		if err != nil {
+			waLogger.Errorf("Forward rule %d failed to send to %s: %v", rule.ID, target, err)
+			continue
+		}
+		emitWebhook("message.forwarded", map[string]interface{}{
+			"rule_id":      rule.ID,
+			"source":       chat.String(),
+			"target":       target.String(),
+			"message_id":   data.Info.ID,
+			"forwarded_id": res.ID,
+		})
+	}
+}
+
+func (rule *forwardRule) normalize(ctx context.Context) error {
+	source, ok := parseJID(rule.Source)
+	if !ok {
+		return fmt.Errorf("invalid source JID %q", rule.Source)
+	}
+	target, ok := parseJID(rule.Target)
+	if !ok {
+		return fmt.Errorf("invalid target JID %q", rule.Target)
+	}
+	rule.Source = canonicalJID(ctx, source).String()
+	rule.Target = canonicalJID(ctx, target).String()
+	if rule.Source == rule.Target {
+		return fmt.Errorf("source and target must differ")
+	}
+	for _, t := range rule.Types {
+		if !forwardableTypes[t] {
+			return fmt.Errorf("type %q cannot be forwarded", t)
+		}
+	}
+	if rule.Pattern != "" {
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid pattern: %w", err)
+		}
+		rule.patternRe = re
+	}
+	if rule.Prefix == "" {
+		rule.Prefix = defaultForwardPrefix
+	}
+	return nil
+}
+
+func listForwardRules(w http.ResponseWriter, r *http.Request) {
+	rows, err := gatewayDB.QueryContext(r.Context(), `SELECT `+forwardRuleColumns+` FROM forward_rules ORDER BY id`)
+	if err != nil {
+		waLogger.Errorf("Failed to list forward rules: %v", err)
+		http.Error(w, "Failed to list forward rules", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	rules := []forwardRule{}
+	for rows.Next() {
+		rule, err := scanForwardRule(rows.Scan)
+		if err != nil {
+			waLogger.Errorf("Failed to scan forward rule: %v", err)
+			http.Error(w, "Failed to list forward rules", http.StatusInternalServerError)
+			return
+		}
+		rules = append(rules, rule)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"rules": rules})
+}
+
+func createForwardRule(w http.ResponseWriter, r *http.Request) {
+	rule := forwardRule{Enabled: true}
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := rule.normalize(r.Context()); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	contains, _ := json.Marshal(rule.Contains)
+	typs, _ := json.Marshal(rule.Types)
+	rule.CreatedAt = time.Now().UTC()
+	res, err := gatewayDB.ExecContext(r.Context(), `
+		INSERT INTO forward_rules (name, source_chat, target_chat, contains, pattern, types, prefix, enabled, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		rule.Name, rule.Source, rule.Target, string(contains), rule.Pattern, string(typs), rule.Prefix, rule.Enabled,
+		rule.CreatedAt.Unix())
+	if err != nil {
+		waLogger.Errorf("Failed to create forward rule: %v", err)
+		http.Error(w, "Failed to create forward rule", http.StatusInternalServerError)
+		return
+	}
+	rule.ID, _ = res.LastInsertId()
+	writeJSON(w, http.StatusCreated, rule)
+}
+
+func updateForwardRule(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Forward rule not found", http.StatusNotFound)
+		return
+	}
+	rule, err := scanForwardRule(gatewayDB.QueryRowContext(r.Context(),
+		`SELECT `+forwardRuleColumns+` FROM forward_rules WHERE id = ?`, id).Scan)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Forward rule not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		waLogger.Errorf("Failed to load forward rule %d: %v", id, err)
+		http.Error(w, "Failed to update forward rule", http.StatusInternalServerError)
+		return
+	}
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	rule.ID = id
+	if err := rule.normalize(r.Context()); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	contains, _ := json.Marshal(rule.Contains)
+	typs, _ := json.Marshal(rule.Types)
+	_, err = gatewayDB.ExecContext(r.Context(), `
+		UPDATE forward_rules SET name = ?, source_chat = ?, target_chat = ?, contains = ?, pattern = ?, types = ?,
+			prefix = ?, enabled = ?
+		WHERE id = ?`,
+		rule.Name, rule.Source, rule.Target, string(contains), rule.Pattern, string(typs), rule.Prefix, rule.Enabled, id)
+	if err != nil {
+		waLogger.Errorf("Failed to update forward rule %d: %v", id, err)
+		http.Error(w, "Failed to update forward rule", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, rule)
+}
+
+func deleteForwardRule(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Forward rule not found", http.StatusNotFound)
+		return
+	}
+	res, err := gatewayDB.ExecContext(r.Context(), `DELETE FROM forward_rules WHERE id = ?`, id)
+	if err != nil {
+		waLogger.Errorf("Failed to delete forward rule %d: %v", id, err)
+		http.Error(w, "Failed to delete forward rule", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		http.Error(w, "Forward rule not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
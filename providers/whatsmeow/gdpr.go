@@ -0,0 +1,562 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"google.golang.org/protobuf/proto"
+)
+
+// Data subject requests (GDPR articles 15 and 17): export everything the
+// gateway stores about a phone number, or erase it. A person may be keyed by
+// their phone-number JID or, in newer chats, their LID; both are covered.
+//
+// Erasure deletes the rows outright (the database runs with secure_delete)
+// and records an erasure certificate in the audit log. The certificate names
+// the subject only by a keyed hash of the number (see dataSubjectHashKey),
+// so the log itself holds no personal data. The suppression list is kept on
+// purpose: it is what stops the number from being messaged again. The
+// whatsmeow device store (contacts synced from the phone, LID mappings)
+// mirrors the WhatsApp account and is left alone.
+
+// dataSubjectTables lists, per table, the condition that selects a subject's
+// rows; each %s stands for the subject's JIDs.
+var dataSubjectTables = []struct {
+	table string
+	where string
+}{
+	{"messages", `chat_jid IN (%s) OR sender_jid IN (%s)`},
+	{"message_receipts", `chat_jid IN (%s) OR sender_jid IN (%s)`},
+	{"media_files", `chat_jid IN (%s) OR sender_jid IN (%s)`},
+	{"notes", `chat_jid IN (%s)`},
+	{"chat_tags", `chat_jid IN (%s)`},
+	{"chats", `jid IN (%s)`},
+	{"contacts", `jid IN (%s)`},
+	{"presence_subscriptions", `contact IN (%s)`},
+	{"presence_events", `contact IN (%s)`},
+	{"group_participant_events", `participant IN (%s) OR actor IN (%s)`},
+	{"moderation_offenses", `participant IN (%s)`},
+	{"join_request_decisions", `participant IN (%s)`},
+	{"outbound_queue", `recipient IN (%s)`},
+	{"schedules", `recipient IN (%s)`},
+	{"forward_rules", `source_chat IN (%s) OR target_chat IN (%s)`},
+	{"poll_votes", `voter IN (%s)`},
+	{"polls", `chat_jid IN (%s)`},
+	{"conversations", `chat_jid IN (%s)`},
+	{"webhook_events", `chat_jid IN (%s)`},
+	{"tracked_links", `recipient IN (%s)`},
+	{"link_clicks", `recipient IN (%s)`},
+	{"survey_answers", `chat_jid IN (%s)`},
+	{"survey_runs", `chat_jid IN (%s)`},
+	{"quarantined_files", `chat_jid IN (%s) OR sender_jid IN (%s)`},
+	{"media_jobs", `chat_jid IN (%s)`},
+}
+
+// dataSubject is a person identified by phone number.
+type dataSubject struct {
+	phone string
+	jids  []string
+}
+
+func resolveDataSubject(ctx context.Context, phone string) (dataSubject, error) {
+	phone, err := normalizePhone(phone)
+	if err != nil {
+		return dataSubject{}, err
+	}
+	pn := types.NewJID(phone, types.DefaultUserServer)
+	subject := dataSubject{phone: phone, jids: []string{pn.String()}}
+	if lid, ok := lookupLID(ctx, pn); ok {
+		subject.jids = append(subject.jids, lid.String())
+	}
+	return subject, nil
+}
+
+// condition expands a dataSubjectTables condition for the subject.
+func (s dataSubject) condition(where string) (string, []interface{}) {
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(s.jids)), ", ")
+	n := strings.Count(where, "%s")
+	var args []interface{}
+	for i := 0; i < n; i++ {
+		for _, jid := range s.jids {
+			args = append(args, jid)
+		}
+	}
+	return strings.ReplaceAll(where, "%s", placeholders), args
+}
+
+// hash names the subject in the audit log without storing the number.
+func (s dataSubject) hash() string {
+	mac := hmac.New(sha256.New, dataSubjectHashKey())
+	mac.Write([]byte(s.phone))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+var (
+	subjectHashKeyOnce sync.Once
+	subjectHashKey     []byte
+)
+
+// dataSubjectHashKey is DATA_SUBJECT_HASH_KEY, or else a random key created
+// on first use and kept in the gateway settings. A plain hash won't do:
+// phone numbers are few enough to try them all.
+func dataSubjectHashKey() []byte {
+	subjectHashKeyOnce.Do(func() {
+		if key := envString("DATA_SUBJECT_HASH_KEY", ""); key != "" {
+			subjectHashKey = []byte(key)
+			return
+		}
+		ctx := context.Background()
+		if key := getSetting(ctx, "data_subject_hash_key", ""); key != "" {
+			subjectHashKey = []byte(key)
+			return
+		}
+		b := make([]byte, 32)
+		rand.Read(b)
+		key := hex.EncodeToString(b)
+		if err := setSetting(ctx, "data_subject_hash_key", key); err != nil {
+			waLogger.Errorf("Failed to save the data subject hash key: %v", err)
+		}
+		subjectHashKey = []byte(key)
+	})
+	return subjectHashKey
+}
+
+// exportRows returns query results as JSON objects keyed by column name.
+func exportRows(ctx context.Context, query string, args ...interface{}) ([]map[string]interface{}, error) {
+	rows, err := gatewayDB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	out := []map[string]interface{}{}
+	for rows.Next() {
+		values := make([]interface{}, len(cols))
+		ptrs := make([]interface{}, len(cols))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+		row := make(map[string]interface{}, len(cols))
+		for i, col := range cols {
+			if b, ok := values[i].([]byte); ok {
+				values[i] = string(b)
+			}
+			row[col] = values[i]
+		}
+		out = append(out, row)
+	}
+	return out, rows.Err()
+}
+
+// exportDataSubject returns everything stored about a phone number.
+// Message text and survey answers are decrypted, queued messages are
+// reduced to their text and media jobs to their status.
+func exportDataSubject(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	subject, err := resolveDataSubject(ctx, r.PathValue("phone"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	export := map[string]interface{}{
+		"phone":       "+" + subject.phone,
+		"jids":        subject.jids,
+		"exported_at": time.Now().UTC(),
+	}
+	for _, t := range dataSubjectTables {
+		where, args := subject.condition(t.where)
+		var data interface{}
+		switch t.table {
+		case "messages":
+			data, err = queryStoredMessages(ctx, `WHERE `+where+` ORDER BY timestamp`, args...)
+		case "outbound_queue":
+			data, err = exportQueuedMessages(ctx, where, args)
+		case "survey_answers":
+			data, err = exportSurveyAnswers(ctx, where, args)
+		case "webhook_events":
+			data, err = queryJournaledEvents(ctx, where+` ORDER BY id`, args...)
+		case "media_jobs":
+			data, err = queryMediaJobs(ctx, `WHERE `+where+` ORDER BY id`, args...)
+		default:
+			data, err = exportRows(ctx, `SELECT * FROM `+t.table+` WHERE `+where, args...)
+		}
+		if err != nil {
+			waLogger.Errorf("Failed to export %s for a data subject: %v", t.table, err)
+			http.Error(w, "Failed to export data", http.StatusInternalServerError)
+			return
+		}
+		export[t.table] = data
+	}
+	suppressions, err := exportRows(ctx, `SELECT * FROM suppressions WHERE phone = ?`, subject.phone)
+	if err != nil {
+		waLogger.Errorf("Failed to export suppressions for a data subject: %v", err)
+		http.Error(w, "Failed to export data", http.StatusInternalServerError)
+		return
+	}
+	export["suppressions"] = suppressions
+	groups, err := subjectGroupCreations(ctx, gatewayDB, subject)
+	if err != nil {
+		waLogger.Errorf("Failed to export group_creations for a data subject: %v", err)
+		http.Error(w, "Failed to export data", http.StatusInternalServerError)
+		return
+	}
+	export["group_creations"] = groups
+	recordAudit(ctx, "data_subject.export", subject.hash(), "admin", map[string]interface{}{})
+	w.Header().Set("Content-Disposition", `attachment; filename="data-subject-`+subject.phone+`.json"`)
+	writeJSON(w, http.StatusOK, export)
+}
+
+func exportQueuedMessages(ctx context.Context, where string, args []interface{}) ([]map[string]interface{}, error) {
+	rows, err := gatewayDB.QueryContext(ctx,
+		`SELECT id, recipient, message_id, message, status, created_at, sent_at FROM outbound_queue WHERE `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := []map[string]interface{}{}
+	for rows.Next() {
+		var id, created, sent int64
+		var recipient, messageID, status string
+		var data []byte
+		if err := rows.Scan(&id, &recipient, &messageID, &data, &status, &created, &sent); err != nil {
+			return nil, err
+		}
+		var msg waE2E.Message
+		if data, err := decryptStoreBytes(data); err == nil {
+			proto.Unmarshal(data, &msg)
+		}
+		out = append(out, map[string]interface{}{
+			"id":         id,
+			"recipient":  recipient,
+			"message_id": messageID,
+			"type":       messageType(&msg),
+			"text":       messageText(&msg),
+			"status":     status,
+			"created_at": created,
+			"sent_at":    sent,
+		})
+	}
+	return out, rows.Err()
+}
+
+// reportPhones are the ways membership reports name the subject: "+" and
+// the user part of each of their JIDs.
+func (s dataSubject) reportPhones() map[string]bool {
+	phones := map[string]bool{}
+	for _, jid := range s.jids {
+		phones["+"+strings.SplitN(jid, "@", 2)[0]] = true
+	}
+	return phones
+}
+
+// subjectGroupCreations returns, per group created from a segment, the
+// subject's entries in its membership report.
+func subjectGroupCreations(ctx context.Context, q interface {
+	QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error)
+}, s dataSubject) (map[string][]groupMemberResult, error) {
+	var patterns []string
+	var args []interface{}
+	phones := s.reportPhones()
+	for phone := range phones {
+		patterns = append(patterns, `members LIKE ?`)
+		args = append(args, `%"`+phone+`"%`)
+	}
+	rows, err := q.QueryContext(ctx, `SELECT group_jid, members FROM group_creations WHERE `+strings.Join(patterns, ` OR `), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := map[string][]groupMemberResult{}
+	for rows.Next() {
+		var group, members string
+		if err := rows.Scan(&group, &members); err != nil {
+			return nil, err
+		}
+		var report []groupMemberResult
+		if err := json.Unmarshal([]byte(members), &report); err != nil {
+			return nil, err
+		}
+		for _, m := range report {
+			if phones[m.Phone] {
+				out[group] = append(out[group], m)
+			}
+		}
+	}
+	return out, rows.Err()
+}
+
+// scrubGroupCreations removes the subject from the membership reports of
+// groups created from a segment, returning how many reports changed.
+func scrubGroupCreations(ctx context.Context, tx *sql.Tx, s dataSubject) (int, error) {
+	groups, err := subjectGroupCreations(ctx, tx, s)
+	if err != nil {
+		return 0, err
+	}
+	phones := s.reportPhones()
+	for group := range groups {
+		var members string
+		if err := tx.QueryRowContext(ctx, `SELECT members FROM group_creations WHERE group_jid = ?`, group).Scan(&members); err != nil {
+			return 0, err
+		}
+		var report []groupMemberResult
+		json.Unmarshal([]byte(members), &report)
+		kept := report[:0]
+		for _, m := range report {
+			if !phones[m.Phone] {
+				kept = append(kept, m)
+			}
+		}
+		encoded, _ := json.Marshal(kept)
+		if _, err := tx.ExecContext(ctx, `UPDATE group_creations SET members = ? WHERE group_jid = ?`, string(encoded), group); err != nil {
+			return 0, err
+		}
+	}
+	return len(groups), nil
+}
+
+// erasureCertificate records that a data subject's data was erased.
+type erasureCertificate struct {
+	ID          string         `json:"id"`
+	SubjectHash string         `json:"subject_hash"` // HMAC-SHA-256 of the E.164 digits
+	ErasedAt    time.Time      `json:"erased_at"`
+	Deleted     map[string]int `json:"deleted"` // rows per table
+	Retained    []string       `json:"retained"`
+	Digest      string         `json:"digest"` // SHA-256 of the certificate without the digest
+}
+
+func eraseDataSubject(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	subject, err := resolveDataSubject(ctx, r.PathValue("phone"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	tx, err := gatewayDB.BeginTx(ctx, nil)
+	if err != nil {
+		waLogger.Errorf("Failed to erase data subject: %v", err)
+		http.Error(w, "Failed to erase data", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	idBytes := make([]byte, 16)
+	rand.Read(idBytes)
+	cert := erasureCertificate{
+		ID:          hex.EncodeToString(idBytes),
+		SubjectHash: subject.hash(),
+		ErasedAt:    time.Now().UTC(),
+		Deleted:     map[string]int{},
+		Retained:    []string{"suppressions"},
+	}
+	// Kept media, quarantined and spooled files are removed with their rows,
+	// after the commit.
+	mediaPaths, err := subjectFilePaths(ctx, tx, subject, "media_files", "path")
+	if err != nil {
+		waLogger.Errorf("Failed to erase media_files for a data subject: %v", err)
+		http.Error(w, "Failed to erase data", http.StatusInternalServerError)
+		return
+	}
+	quarantinePaths, err := subjectFilePaths(ctx, tx, subject, "quarantined_files", "path")
+	if err != nil {
+		waLogger.Errorf("Failed to erase quarantined_files for a data subject: %v", err)
+		http.Error(w, "Failed to erase data", http.StatusInternalServerError)
+		return
+	}
+	spoolPaths, err := subjectFilePaths(ctx, tx, subject, "media_jobs", "spool_path")
+	if err != nil {
+		waLogger.Errorf("Failed to erase media_jobs for a data subject: %v", err)
+		http.Error(w, "Failed to erase data", http.StatusInternalServerError)
+		return
+	}
+	for _, t := range dataSubjectTables {
+		where, args := subject.condition(t.where)
+		res, err := tx.ExecContext(ctx, `DELETE FROM `+t.table+` WHERE `+where, args...)
+		if err != nil {
+			waLogger.Errorf("Failed to erase %s for a data subject: %v", t.table, err)
+			http.Error(w, "Failed to erase data", http.StatusInternalServerError)
+			return
+		}
+		n, _ := res.RowsAffected()
+		cert.Deleted[t.table] = int(n)
+	}
+	// Undelivered webhooks may carry the number too.
+	var patterns []string
+	var patternArgs []interface{}
+	for _, jid := range subject.jids {
+		user := strings.SplitN(jid, "@", 2)[0]
+		patterns = append(patterns, `CAST(payload AS TEXT) LIKE ?`, `CAST(payload AS TEXT) LIKE ?`)
+		patternArgs = append(patternArgs, `%"`+user+`@%`, `%"`+user+`:%`)
+	}
+	res, err := tx.ExecContext(ctx, `DELETE FROM webhook_queue WHERE `+strings.Join(patterns, ` OR `), patternArgs...)
+	if err != nil {
+		waLogger.Errorf("Failed to erase queued webhooks for a data subject: %v", err)
+		http.Error(w, "Failed to erase data", http.StatusInternalServerError)
+		return
+	}
+	n, _ := res.RowsAffected()
+	cert.Deleted["webhook_queue"] = int(n)
+	// Reports of groups created from a segment list the members by number.
+	if cert.Deleted["group_creations"], err = scrubGroupCreations(ctx, tx, subject); err != nil {
+		waLogger.Errorf("Failed to erase group_creations for a data subject: %v", err)
+		http.Error(w, "Failed to erase data", http.StatusInternalServerError)
+		return
+	}
+
+	unsigned, _ := json.Marshal(cert)
+	sum := sha256.Sum256(unsigned)
+	cert.Digest = hex.EncodeToString(sum[:])
+	if err := insertAudit(ctx, tx, "data_subject.erase", cert.SubjectHash, "admin", cert); err != nil {
+		waLogger.Errorf("Failed to record erasure certificate: %v", err)
+		http.Error(w, "Failed to erase data", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		waLogger.Errorf("Failed to erase data subject: %v", err)
+		http.Error(w, "Failed to erase data", http.StatusInternalServerError)
+		return
+	}
+	removeUnreferencedMediaFiles(ctx, mediaPaths)
+	removeUnreferencedQuarantineFiles(ctx, quarantinePaths)
+	for _, path := range spoolPaths {
+		os.Remove(path)
+	}
+	forgetLIDMapping(subject)
+	waLogger.Infof("Erased data subject %s (certificate %s)", cert.SubjectHash, cert.ID)
+	writeJSON(w, http.StatusOK, cert)
+}
+
+// subjectFilePaths lists the files named by column in a subject's rows of a
+// dataSubjectTables table.
+func subjectFilePaths(ctx context.Context, tx *sql.Tx, s dataSubject, table, column string) ([]string, error) {
+	var where string
+	for _, t := range dataSubjectTables {
+		if t.table == table {
+			where = t.where
+		}
+	}
+	where, args := s.condition(`(` + where + `) AND ` + column + ` <> ''`)
+	rows, err := tx.QueryContext(ctx, `SELECT DISTINCT `+column+` FROM `+table+` WHERE `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var paths []string
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, rows.Err()
+}
+
+// forgetLIDMapping drops the subject from the in-memory LID cache.
+func forgetLIDMapping(s dataSubject) {
+	pn := types.NewJID(s.phone, types.DefaultUserServer)
+	lidCacheM.Lock()
+	if lid, ok := pnToLID[pn]; ok {
+		delete(lidToPN, lid)
+	}
+	delete(pnToLID, pn)
+	lidCacheM.Unlock()
+}
+
+// --- Audit log ---
+
+type auditEntry struct {
+	ID        int64           `json:"id"`
+	Action    string          `json:"action"`
+	Subject   string          `json:"subject,omitempty"`
+	Actor     string          `json:"actor,omitempty"`
+	Details   json.RawMessage `json:"details"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+func insertAudit(ctx context.Context, db execer, action, subject, actor string, details interface{}) error {
+	raw, err := json.Marshal(details)
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx,
+		`INSERT INTO audit_log (action, subject, actor, details, created_at) VALUES (?, ?, ?, ?, ?)`,
+		action, subject, actor, string(raw), time.Now().Unix())
+	return err
+}
+
+// recordAudit appends to the audit log, logging failures.
+func recordAudit(ctx context.Context, action, subject, actor string, details interface{}) {
+	if err := insertAudit(ctx, gatewayDB, action, subject, actor, details); err != nil {
+		waLogger.Errorf("Failed to record %s in the audit log: %v", action, err)
+	}
+}
+
+func listAuditLog(w http.ResponseWriter, r *http.Request) {
+	query := `SELECT id, action, subject, actor, details, created_at FROM audit_log WHERE 1 = 1`
+	var args []interface{}
+	q := r.URL.Query()
+	if a := q.Get("action"); a != "" {
+		query += ` AND action = ?`
+		args = append(args, a)
+	}
+	if s := q.Get("subject"); s != "" {
+		query += ` AND subject = ?`
+		args = append(args, s)
+	}
+	limit := 100
+	if l := q.Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 || n > 1000 {
+			http.Error(w, "Invalid limit, expected 1-1000", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	query += ` ORDER BY id DESC LIMIT ?`
+	args = append(args, limit)
+	rows, err := gatewayDB.QueryContext(r.Context(), query, args...)
+	if err != nil {
+		waLogger.Errorf("Failed to list audit log: %v", err)
+		http.Error(w, "Failed to list audit log", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	entries := []auditEntry{}
+	for rows.Next() {
+		var e auditEntry
+		var details string
+		var created int64
+		if err := rows.Scan(&e.ID, &e.Action, &e.Subject, &e.Actor, &details, &created); err != nil {
+			waLogger.Errorf("Failed to scan audit entry: %v", err)
+			http.Error(w, "Failed to list audit log", http.StatusInternalServerError)
+			return
+		}
+		e.Details = json.RawMessage(details)
+		e.CreatedAt = time.Unix(created, 0).UTC()
+		entries = append(entries, e)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"entries": entries})
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDataSubjectCondition(t *testing.T) {
+	subject := dataSubject{phone: "15551234567", jids: []string{"15551234567@s.whatsapp.net", "123456789@lid"}}
+	tests := []struct {
+		where     string
+		wantWhere string
+		wantArgs  []interface{}
+	}{
+		{
+			where:     `jid IN (%s)`,
+			wantWhere: `jid IN (?, ?)`,
+			wantArgs:  []interface{}{"15551234567@s.whatsapp.net", "123456789@lid"},
+		},
+		{
+			where:     `chat_jid IN (%s) OR sender_jid IN (%s)`,
+			wantWhere: `chat_jid IN (?, ?) OR sender_jid IN (?, ?)`,
+			wantArgs:  []interface{}{"15551234567@s.whatsapp.net", "123456789@lid", "15551234567@s.whatsapp.net", "123456789@lid"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.where, func(t *testing.T) {
+			where, args := subject.condition(tt.where)
+			if where != tt.wantWhere || !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("condition() = %q, %v, want %q, %v", where, args, tt.wantWhere, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestDataSubjectHash(t *testing.T) {
+	t.Setenv("DATA_SUBJECT_HASH_KEY", "test-key")
+	subjectHashKeyOnce = sync.Once{}
+	t.Cleanup(func() { subjectHashKeyOnce = sync.Once{} })
+	tests := []struct {
+		phone string
+		want  string // HMAC-SHA-256 with "test-key"
+	}{
+		// A plain SHA-256 would be d6736136ea896c1bfdc553e0e86e702c70d060d805696ca3e4e9e0961353860a.
+		{"15551234567", "ac31f70c31cb844d8df67b9a796abe344d0c94723118c6aebcb7a79334e23f6d"},
+		{"4915112345678", "98dac36d6f97acac887a39353a6da93e38e3d8cafd91bdd4108e84b5b7551eab"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.phone, func(t *testing.T) {
+			if got := (dataSubject{phone: tt.phone}).hash(); got != tt.want {
+				t.Errorf("hash() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScrubGroupCreations(t *testing.T) {
+	openTestDB(t)
+	ctx := context.Background()
+	subject := dataSubject{phone: "15551234567", jids: []string{"15551234567@s.whatsapp.net", "123456789@lid"}}
+	reports := []struct {
+		group   string
+		members []groupMemberResult
+		want    []groupMemberResult // after the erase
+	}{
+		{
+			group: "1@g.us",
+			members: []groupMemberResult{
+				{Phone: "+15551234567", Status: "added"},
+				{Phone: "+15557654321", Status: "added"},
+			},
+			want: []groupMemberResult{{Phone: "+15557654321", Status: "added"}},
+		},
+		{
+			group: "2@g.us",
+			members: []groupMemberResult{
+				{Phone: "+123456789", Status: "invited", InvitedInstead: true},
+			},
+			want: []groupMemberResult{},
+		},
+		{
+			group: "3@g.us",
+			members: []groupMemberResult{
+				{Phone: "+155512345678", Status: "added"},
+			},
+			want: []groupMemberResult{{Phone: "+155512345678", Status: "added"}},
+		},
+	}
+	for _, r := range reports {
+		members, _ := json.Marshal(r.members)
+		_, err := gatewayDB.Exec(`INSERT INTO group_creations (group_jid, name, members, created_at) VALUES (?, 'Test', ?, ?)`,
+			r.group, string(members), time.Now().Unix())
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	exported, err := subjectGroupCreations(ctx, gatewayDB, subject)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantExport := map[string][]groupMemberResult{
+		"1@g.us": {{Phone: "+15551234567", Status: "added"}},
+		"2@g.us": {{Phone: "+123456789", Status: "invited", InvitedInstead: true}},
+	}
+	if !reflect.DeepEqual(exported, wantExport) {
+		t.Errorf("subjectGroupCreations() = %v, want %v", exported, wantExport)
+	}
+
+	tx, err := gatewayDB.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := scrubGroupCreations(ctx, tx, subject)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("scrubGroupCreations() = %d, want 2 reports changed", n)
+	}
+	for _, r := range reports {
+		var members string
+		if err := gatewayDB.QueryRow(`SELECT members FROM group_creations WHERE group_jid = ?`, r.group).Scan(&members); err != nil {
+			t.Fatal(err)
+		}
+		got := []groupMemberResult{}
+		json.Unmarshal([]byte(members), &got)
+		if !reflect.DeepEqual(got, r.want) {
+			t.Errorf("members of %s = %v, want %v", r.group, got, r.want)
+		}
+	}
+}
@@ -0,0 +1,1311 @@
+//go:build ignore
+
+// gen_sdk.go generates the API description and clients from the server's own
+// code: the routes startAPIServer registers, and for each the handler behind
+// it. It reads the path parameters from the route pattern, the query
+// parameters from r.URL.Query() lookups, the request body from the type
+// decoded from r.Body and the response from what's passed to writeJSON, and
+// writes
+//
+//	openapi.json     OpenAPI 3 description, served at GET /openapi.json
+//	sdk/go/client.go Go client (package gatewayclient)
+//	sdk/ts/client.ts TypeScript client
+//
+// Run go generate after changing the API and commit the output with the
+// change. Only the standard library is used, so it runs without the server's
+// dependencies.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	specFile     = "openapi.json"
+	goClientFile = "sdk/go/client.go"
+	tsClientFile = "sdk/ts/client.ts"
+	apiTitle     = "WhatsApp Gateway (whatsmeow provider)"
+	apiVersion   = "0.1.0"
+)
+
+// schema is the part of a JSON schema the generators need.
+type schema struct {
+	Type       string // string, integer, number, boolean, array or object; empty for any
+	Format     string
+	Ref        string // component name
+	Items      *schema
+	Additional *schema // map values
+	Properties []property
+	Pointer    bool // nullable; kept as a pointer in Go
+}
+
+type property struct {
+	Name     string
+	GoName   string
+	Schema   *schema
+	Optional bool // omitempty or a pointer
+	Doc      string
+}
+
+type route struct {
+	Method, Path string
+	Handler      string
+	OperationID  string
+	Auth         string // api_key, admin or empty
+	Summary      string
+	PathParams   []string
+	Query        []string
+	Body         *schema
+	Response     *schema
+	Status       int
+}
+
+type generator struct {
+	types       map[string]ast.Expr
+	funcs       map[string]*ast.FuncDecl
+	components  map[string]*schema
+	compOrder   []string
+	resolving   map[string]bool
+	structNames map[string]string // the component of each struct type
+}
+
+func main() {
+	g := &generator{
+		types:       map[string]ast.Expr{},
+		funcs:       map[string]*ast.FuncDecl{},
+		components:  map[string]*schema{},
+		resolving:   map[string]bool{},
+		structNames: map[string]string{},
+	}
+	g.parsePackage()
+	routes := g.routes()
+	spec, err := json.MarshalIndent(g.openAPI(routes), "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	write(specFile, append(spec, '\n'))
+	goSrc, err := format.Source(g.goClient(routes))
+	if err != nil {
+		log.Fatalf("generated Go client doesn't parse: %v", err)
+	}
+	write(goClientFile, goSrc)
+	write(tsClientFile, g.tsClient(routes))
+	log.Printf("Generated %d operations and %d types", len(routes), len(g.compOrder))
+}
+
+func write(name string, data []byte) {
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(name, data, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func (g *generator) parsePackage() {
+	names, err := filepath.Glob("*.go")
+	if err != nil {
+		log.Fatal(err)
+	}
+	fset := token.NewFileSet()
+	for _, name := range names {
+		if strings.HasSuffix(name, "_test.go") || name == "gen_sdk.go" {
+			continue
+		}
+		f, err := parser.ParseFile(fset, name, nil, parser.ParseComments)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, decl := range f.Decls {
+			switch d := decl.(type) {
+			case *ast.FuncDecl:
+				if d.Recv == nil {
+					g.funcs[d.Name.Name] = d
+				}
+			case *ast.GenDecl:
+				for _, spec := range d.Specs {
+					if ts, ok := spec.(*ast.TypeSpec); ok {
+						g.types[ts.Name.Name] = ts.Type
+					}
+				}
+			}
+		}
+	}
+}
+
+// routes collects the http.HandleFunc calls of startAPIServer.
+func (g *generator) routes() []route {
+	server := g.funcs["startAPIServer"]
+	if server == nil {
+		log.Fatal("startAPIServer not found")
+	}
+	var routes []route
+	registered := map[string]bool{}
+	usedIDs := map[string]bool{}
+	ast.Inspect(server.Body, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || !isSelector(call.Fun, "http", "HandleFunc") || len(call.Args) != 2 {
+			return true
+		}
+		lit, ok := call.Args[0].(*ast.BasicLit)
+		if !ok {
+			return true
+		}
+		pattern, _ := strconv.Unquote(lit.Value)
+		rt := route{Status: 200}
+		if method, path, found := strings.Cut(pattern, " "); found {
+			rt.Method, rt.Path = method, path
+		} else {
+			rt.Path = pattern
+		}
+		handler := call.Args[1]
+		for {
+			inner, ok := handler.(*ast.CallExpr)
+			if !ok || len(inner.Args) == 0 {
+				break
+			}
+			switch name := exprName(inner.Fun); name {
+			case "requireAdmin":
+				rt.Auth = "admin"
+			case "requireAPIKey":
+				rt.Auth = "api_key"
+			}
+			handler = inner.Args[len(inner.Args)-1]
+		}
+		rt.Handler = exprName(handler)
+		if fn := g.funcs[rt.Handler]; fn != nil {
+			g.describeHandler(&rt, fn)
+		}
+		if rt.Method == "" {
+			// Patterns without a method take any; the body tells which.
+			rt.Method = "GET"
+			if rt.Body != nil {
+				rt.Method = "POST"
+			}
+		}
+		// Aliases (the same handler under another path) aren't repeated.
+		if registered[rt.Method+" "+rt.Handler] {
+			return true
+		}
+		registered[rt.Method+" "+rt.Handler] = true
+		for _, m := range regexp.MustCompile(`\{(\w+)(?:\.\.\.)?\}`).FindAllStringSubmatch(rt.Path, -1) {
+			rt.PathParams = append(rt.PathParams, m[1])
+		}
+		rt.OperationID = exported(strings.TrimSuffix(rt.Handler, "Handler"))
+		if usedIDs[rt.OperationID] {
+			rt.OperationID += exported(strings.ToLower(rt.Method))
+		}
+		usedIDs[rt.OperationID] = true
+		// Objects described inline get a name, for the clients' sake.
+		rt.Body = g.nameInline(rt.Body, rt.OperationID+"Request", false)
+		rt.Response = g.nameInline(rt.Response, rt.OperationID+"Response", false)
+		routes = append(routes, rt)
+		return true
+	})
+	return routes
+}
+
+// nameInline makes an object described inline a component. The responses
+// of shared helpers (shared set) are named once, after the helper.
+func (g *generator) nameInline(s *schema, name string, shared bool) *schema {
+	if s == nil || s.Type != "object" || len(s.Properties) == 0 {
+		return s
+	}
+	if shared {
+		if _, done := g.components[name]; done {
+			return &schema{Ref: name}
+		}
+	} else if _, taken := g.components[name]; taken {
+		return s
+	}
+	g.components[name] = s
+	g.compOrder = append(g.compOrder, name)
+	return &schema{Ref: name}
+}
+
+// describeHandler fills in what the handler's body tells about the request
+// and response.
+func (g *generator) describeHandler(rt *route, fn *ast.FuncDecl) {
+	rt.Summary = summary(fn)
+	queryVars := map[string]bool{}
+	seenQuery := map[string]bool{}
+	addQuery := func(name string) {
+		if !seenQuery[name] {
+			seenQuery[name] = true
+			rt.Query = append(rt.Query, name)
+		}
+	}
+	isQuery := func(e ast.Expr) bool {
+		if id, ok := e.(*ast.Ident); ok {
+			return queryVars[id.Name]
+		}
+		call, ok := e.(*ast.CallExpr)
+		return ok && isSelector(call.Fun, "URL", "Query")
+	}
+	var response ast.Expr
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			if len(n.Lhs) == 1 && len(n.Rhs) == 1 && isQuery(n.Rhs[0]) {
+				queryVars[exprName(n.Lhs[0])] = true
+			}
+		case *ast.IndexExpr:
+			if isQuery(n.X) {
+				if name, ok := stringLit(n.Index); ok {
+					addQuery(name)
+				}
+			}
+		case *ast.CallExpr:
+			sel, ok := n.Fun.(*ast.SelectorExpr)
+			switch {
+			case ok && sel.Sel.Name == "Get" && isQuery(sel.X) && len(n.Args) == 1:
+				if name, ok := stringLit(n.Args[0]); ok {
+					addQuery(name)
+				}
+			case ok && sel.Sel.Name == "Decode" && len(n.Args) == 1 && decodesRequestBody(sel.X):
+				if rt.Body == nil {
+					rt.Body = g.schemaOf(g.typeOfVar(fn, unaddr(n.Args[0])))
+				}
+			case exprName(n.Fun) == "writeJSON" && len(n.Args) == 3 && response == nil:
+				status := statusCode(n.Args[1])
+				if status == 0 || status < 300 {
+					response = n.Args[2]
+					if status != 0 {
+						rt.Status = status
+					}
+				}
+			case ok && sel.Sel.Name == "WriteHeader" && len(n.Args) == 1 && response == nil:
+				if status := statusCode(n.Args[0]); status == 204 {
+					rt.Status = status
+				}
+			}
+		}
+		return true
+	})
+	if response != nil {
+		rt.Response = g.schemaOfValue(fn, response)
+		return
+	}
+	// Handlers that leave the response to a helper, like writeSendResult.
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || rt.Response != nil || len(call.Args) == 0 || exprName(call.Args[0]) != "w" {
+			return true
+		}
+		name := exprName(call.Fun)
+		helper := g.funcs[name]
+		if helper == nil || name == "writeJSON" || !strings.HasPrefix(name, "write") || strings.Contains(name, "Error") {
+			return true
+		}
+		var sub route
+		g.describeHandler(&sub, helper)
+		rt.Response = g.nameInline(sub.Response, exported(strings.TrimPrefix(name, "write")), true)
+		if sub.Status != 0 {
+			rt.Status = sub.Status
+		}
+		return true
+	})
+}
+
+func decodesRequestBody(e ast.Expr) bool {
+	call, ok := e.(*ast.CallExpr)
+	return ok && isSelector(call.Fun, "json", "NewDecoder") && len(call.Args) == 1 && isSelector(call.Args[0], "r", "Body")
+}
+
+// typeOfVar finds the type of a variable declared in fn: from var x T, or
+// x := T{...} / f(...).
+func (g *generator) typeOfVar(fn *ast.FuncDecl, e ast.Expr) ast.Expr {
+	id, ok := e.(*ast.Ident)
+	if !ok {
+		return nil
+	}
+	var found ast.Expr
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		if found != nil {
+			return false
+		}
+		switch n := n.(type) {
+		case *ast.ValueSpec:
+			for _, name := range n.Names {
+				if name.Name == id.Name && n.Type != nil {
+					found = n.Type
+				}
+			}
+		case *ast.AssignStmt:
+			if n.Tok != token.DEFINE {
+				return true
+			}
+			for i, lhs := range n.Lhs {
+				if exprName(lhs) != id.Name {
+					continue
+				}
+				if len(n.Rhs) == len(n.Lhs) {
+					found = g.typeOfExpr(n.Rhs[i], 0)
+				} else if len(n.Rhs) == 1 {
+					found = g.typeOfExpr(n.Rhs[0], i)
+				}
+			}
+		}
+		return true
+	})
+	return found
+}
+
+// typeOfExpr is the type of a composite literal or of result i of a call
+// to a function of the package.
+func (g *generator) typeOfExpr(e ast.Expr, i int) ast.Expr {
+	switch e := e.(type) {
+	case *ast.CompositeLit:
+		return e.Type
+	case *ast.UnaryExpr:
+		if e.Op == token.AND {
+			return g.typeOfExpr(e.X, i)
+		}
+	case *ast.CallExpr:
+		id, ok := e.Fun.(*ast.Ident)
+		if !ok {
+			return nil
+		}
+		fn := g.funcs[id.Name]
+		if fn == nil || fn.Type.Results == nil {
+			return nil
+		}
+		var results []ast.Expr
+		for _, field := range fn.Type.Results.List {
+			for range max(1, len(field.Names)) {
+				results = append(results, field.Type)
+			}
+		}
+		if i < len(results) {
+			return results[i]
+		}
+	}
+	return nil
+}
+
+// schemaOfValue describes the JSON of a value passed to writeJSON.
+func (g *generator) schemaOfValue(fn *ast.FuncDecl, e ast.Expr) *schema {
+	switch v := e.(type) {
+	case *ast.Ident:
+		switch v.Name {
+		case "true", "false":
+			return &schema{Type: "boolean"}
+		case "nil":
+			return &schema{}
+		}
+		if lit := mapLiteralOf(fn, v.Name); lit != nil {
+			s := g.schemaOfValue(fn, lit)
+			addIndexedKeys(g, fn, v.Name, s)
+			return s
+		}
+		return g.schemaOf(g.typeOfVar(fn, v))
+	case *ast.BasicLit:
+		if v.Kind == token.STRING {
+			return &schema{Type: "string"}
+		}
+		return &schema{Type: "number"}
+	case *ast.CallExpr:
+		if name := exprName(v.Fun); name == "len" {
+			return &schema{Type: "integer"}
+		} else if sel, ok := v.Fun.(*ast.SelectorExpr); ok && sel.Sel.Name == "String" && len(v.Args) == 0 {
+			return &schema{Type: "string"}
+		}
+		return g.schemaOf(g.typeOfExpr(v, 0))
+	case *ast.UnaryExpr:
+		return g.schemaOfValue(fn, v.X)
+	case *ast.SelectorExpr:
+		// A field of a variable or parameter of a struct type.
+		if id, ok := v.X.(*ast.Ident); ok {
+			if t := g.fieldType(g.varType(fn, id), v.Sel.Name); t != nil {
+				return g.schemaOf(t)
+			}
+		}
+	case *ast.CompositeLit:
+		// Map literals with string keys list what they hold.
+		if m, ok := v.Type.(*ast.MapType); ok && exprName(m.Key) == "string" {
+			s := &schema{Type: "object"}
+			for _, elt := range v.Elts {
+				kv, ok := elt.(*ast.KeyValueExpr)
+				if !ok {
+					continue
+				}
+				if key, ok := stringLit(kv.Key); ok {
+					s.Properties = append(s.Properties, property{
+						Name: key, GoName: goFieldName(key), Schema: g.schemaOfValue(fn, kv.Value), Optional: true,
+					})
+				}
+			}
+			if len(s.Properties) == 0 {
+				s.Additional = g.schemaOf(m.Value)
+			}
+			return s
+		}
+		return g.schemaOf(v.Type)
+	}
+	return &schema{}
+}
+
+// varType is the type of a parameter or variable of fn.
+func (g *generator) varType(fn *ast.FuncDecl, id *ast.Ident) ast.Expr {
+	for _, field := range fn.Type.Params.List {
+		for _, name := range field.Names {
+			if name.Name == id.Name {
+				return field.Type
+			}
+		}
+	}
+	return g.typeOfVar(fn, id)
+}
+
+// fieldType is the type of a field of a struct type of the package.
+func (g *generator) fieldType(t ast.Expr, name string) ast.Expr {
+	if star, ok := t.(*ast.StarExpr); ok {
+		t = star.X
+	}
+	id, ok := t.(*ast.Ident)
+	if !ok {
+		return nil
+	}
+	st, ok := g.types[id.Name].(*ast.StructType)
+	if !ok {
+		return nil
+	}
+	for _, field := range st.Fields.List {
+		for _, n := range field.Names {
+			if n.Name == name {
+				return field.Type
+			}
+		}
+	}
+	return nil
+}
+
+// mapLiteralOf is the map literal a variable of fn is defined with, if it is.
+func mapLiteralOf(fn *ast.FuncDecl, name string) *ast.CompositeLit {
+	var lit *ast.CompositeLit
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		as, ok := n.(*ast.AssignStmt)
+		if !ok || lit != nil || as.Tok != token.DEFINE || len(as.Lhs) != 1 || len(as.Rhs) != 1 || exprName(as.Lhs[0]) != name {
+			return true
+		}
+		if cl, ok := as.Rhs[0].(*ast.CompositeLit); ok {
+			if _, isMap := cl.Type.(*ast.MapType); isMap {
+				lit = cl
+			}
+		}
+		return true
+	})
+	return lit
+}
+
+// addIndexedKeys adds the keys set later with name["key"] = value.
+func addIndexedKeys(g *generator, fn *ast.FuncDecl, name string, s *schema) {
+	if s.Type != "object" {
+		return
+	}
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		as, ok := n.(*ast.AssignStmt)
+		if !ok || len(as.Lhs) != 1 || len(as.Rhs) != 1 {
+			return true
+		}
+		idx, ok := as.Lhs[0].(*ast.IndexExpr)
+		if !ok || exprName(idx.X) != name {
+			return true
+		}
+		key, ok := stringLit(idx.Index)
+		if !ok {
+			return true
+		}
+		for _, p := range s.Properties {
+			if p.Name == key {
+				return true
+			}
+		}
+		s.Additional = nil
+		s.Properties = append(s.Properties, property{Name: key, GoName: goFieldName(key), Schema: g.schemaOfValue(fn, as.Rhs[0]), Optional: true})
+		return true
+	})
+}
+
+// schemaOf describes the JSON encoding of a Go type.
+func (g *generator) schemaOf(t ast.Expr) *schema {
+	switch t := t.(type) {
+	case nil:
+		return &schema{}
+	case *ast.Ident:
+		switch t.Name {
+		case "string":
+			return &schema{Type: "string"}
+		case "bool":
+			return &schema{Type: "boolean"}
+		case "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64", "byte", "rune":
+			return &schema{Type: "integer"}
+		case "float32", "float64":
+			return &schema{Type: "number"}
+		case "error":
+			return &schema{Type: "string"}
+		case "any":
+			return &schema{}
+		}
+		def, ok := g.types[t.Name]
+		if !ok {
+			return &schema{}
+		}
+		if _, isStruct := def.(*ast.StructType); !isStruct {
+			if g.resolving[t.Name] {
+				return &schema{}
+			}
+			g.resolving[t.Name] = true
+			defer delete(g.resolving, t.Name)
+			return g.schemaOf(def)
+		}
+		name, done := g.structNames[t.Name]
+		if !done {
+			name = exported(t.Name)
+			if _, taken := g.components[name]; taken {
+				name += "Data"
+			}
+			g.structNames[t.Name] = name
+			g.components[name] = nil // reserved, for recursive types
+			g.compOrder = append(g.compOrder, name)
+			g.components[name] = g.schemaOf(def)
+		}
+		return &schema{Ref: name}
+	case *ast.StarExpr:
+		s := *g.schemaOf(t.X)
+		s.Pointer = true
+		return &s
+	case *ast.ArrayType:
+		if exprName(t.Elt) == "byte" {
+			return &schema{Type: "string", Format: "byte"}
+		}
+		return &schema{Type: "array", Items: g.schemaOf(t.Elt)}
+	case *ast.MapType:
+		return &schema{Type: "object", Additional: g.schemaOf(t.Value)}
+	case *ast.SelectorExpr:
+		switch exprName(t.X) + "." + t.Sel.Name {
+		case "time.Time":
+			return &schema{Type: "string", Format: "date-time"}
+		case "time.Duration":
+			return &schema{Type: "integer"}
+		case "types.JID", "types.MessageID":
+			return &schema{Type: "string"}
+		case "sql.NullString":
+			return &schema{Type: "string", Pointer: true}
+		case "sql.NullInt64":
+			return &schema{Type: "integer", Pointer: true}
+		}
+		return &schema{}
+	case *ast.StructType:
+		s := &schema{Type: "object"}
+		g.addFields(s, t)
+		return s
+	}
+	return &schema{}
+}
+
+// addFields adds the JSON fields of a struct, inlining embedded structs as
+// encoding/json does.
+func (g *generator) addFields(s *schema, st *ast.StructType) {
+	for _, field := range st.Fields.List {
+		tag := ""
+		if field.Tag != nil {
+			raw, _ := strconv.Unquote(field.Tag.Value)
+			tag = reflect.StructTag(raw).Get("json")
+		}
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if len(field.Names) == 0 {
+			if id, ok := field.Type.(*ast.Ident); ok && name == "" {
+				if embedded, ok := g.types[id.Name].(*ast.StructType); ok {
+					g.addFields(s, embedded)
+				}
+			}
+			continue
+		}
+		for _, fieldName := range field.Names {
+			if !fieldName.IsExported() {
+				continue
+			}
+			jsonName := name
+			if jsonName == "" {
+				jsonName = fieldName.Name
+			}
+			fs := g.schemaOf(field.Type)
+			s.Properties = append(s.Properties, property{
+				Name:     jsonName,
+				GoName:   fieldName.Name,
+				Schema:   fs,
+				Optional: strings.Contains(opts, "omitempty") || fs.Pointer,
+				Doc:      fieldDoc(field),
+			})
+		}
+	}
+}
+
+// OpenAPI
+
+func (g *generator) openAPI(routes []route) map[string]interface{} {
+	paths := map[string]map[string]interface{}{}
+	for _, rt := range routes {
+		op := map[string]interface{}{
+			"operationId": rt.OperationID,
+			"tags":        []string{tagOf(rt.Path)},
+		}
+		if rt.Summary != "" {
+			op["summary"] = rt.Summary
+		}
+		var params []map[string]interface{}
+		for _, p := range rt.PathParams {
+			params = append(params, map[string]interface{}{
+				"name": p, "in": "path", "required": true, "schema": map[string]string{"type": "string"},
+			})
+		}
+		for _, q := range rt.Query {
+			params = append(params, map[string]interface{}{
+				"name": q, "in": "query", "schema": map[string]string{"type": "string"},
+			})
+		}
+		if len(params) > 0 {
+			op["parameters"] = params
+		}
+		if rt.Body != nil {
+			op["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content":  map[string]interface{}{"application/json": map[string]interface{}{"schema": rt.Body.openAPI()}},
+			}
+		}
+		resp := map[string]interface{}{"description": http200(rt.Status)}
+		if rt.Response != nil {
+			resp["content"] = map[string]interface{}{"application/json": map[string]interface{}{"schema": rt.Response.openAPI()}}
+		}
+		op["responses"] = map[string]interface{}{
+			strconv.Itoa(rt.Status): resp,
+			"default":               map[string]interface{}{"description": "Error, described in the plain-text body"},
+		}
+		switch rt.Auth {
+		case "api_key":
+			op["security"] = []map[string][]string{{"apiKey": {}}, {"bearer": {}}}
+		case "admin":
+			op["security"] = []map[string][]string{{"adminKey": {}}}
+		}
+		path := regexp.MustCompile(`\{(\w+)\.\.\.\}`).ReplaceAllString(rt.Path, "{$1}")
+		if paths[path] == nil {
+			paths[path] = map[string]interface{}{}
+		}
+		paths[path][strings.ToLower(rt.Method)] = op
+	}
+	schemas := map[string]interface{}{}
+	for _, name := range g.compOrder {
+		schemas[name] = g.components[name].openAPI()
+	}
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info":    map[string]string{"title": apiTitle, "version": apiVersion},
+		"paths":   paths,
+		"components": map[string]interface{}{
+			"schemas": schemas,
+			"securitySchemes": map[string]interface{}{
+				"apiKey":   map[string]string{"type": "apiKey", "in": "header", "name": "X-API-Key"},
+				"bearer":   map[string]string{"type": "http", "scheme": "bearer"},
+				"adminKey": map[string]string{"type": "apiKey", "in": "header", "name": "X-Admin-Key"},
+			},
+		},
+	}
+}
+
+func (s *schema) openAPI() map[string]interface{} {
+	out := map[string]interface{}{}
+	if s.Ref != "" {
+		out["$ref"] = "#/components/schemas/" + s.Ref
+		return out
+	}
+	if s.Type != "" {
+		out["type"] = s.Type
+	}
+	if s.Format != "" {
+		out["format"] = s.Format
+	}
+	if s.Pointer {
+		out["nullable"] = true
+	}
+	if s.Items != nil {
+		out["items"] = s.Items.openAPI()
+	}
+	if s.Additional != nil {
+		out["additionalProperties"] = s.Additional.openAPI()
+	}
+	if len(s.Properties) > 0 {
+		props := map[string]interface{}{}
+		var required []string
+		for _, p := range s.Properties {
+			ps := p.Schema.openAPI()
+			if p.Doc != "" && p.Schema.Ref == "" {
+				ps["description"] = p.Doc
+			}
+			props[p.Name] = ps
+			if !p.Optional {
+				required = append(required, p.Name)
+			}
+		}
+		out["properties"] = props
+		if len(required) > 0 {
+			out["required"] = required
+		}
+	}
+	return out
+}
+
+// Go client
+
+func (g *generator) goClient(routes []route) []byte {
+	var b bytes.Buffer
+	b.WriteString(`// Client calls one provider instance. APIKey is sent as X-API-Key to the
+// endpoints that take one, AdminKey as X-Admin-Key to the operator ones.
+type Client struct {
+	BaseURL    string
+	APIKey     string
+	AdminKey   string
+	HTTPClient *http.Client
+}
+
+// New returns a client for the instance at baseURL.
+func New(baseURL, apiKey string) *Client {
+	return &Client{BaseURL: strings.TrimSuffix(baseURL, "/"), APIKey: apiKey, HTTPClient: http.DefaultClient}
+}
+
+// Error is a response with an error status; Message is its body.
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return http.StatusText(e.StatusCode) + ": " + e.Message
+}
+
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out interface{}) error {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+	u := c.BaseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, reqBody)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.APIKey != "" {
+		req.Header.Set("X-API-Key", c.APIKey)
+	}
+	if c.AdminKey != "" {
+		req.Header.Set("X-Admin-Key", c.AdminKey)
+	}
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return &Error{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(msg))}
+	}
+	switch out := out.(type) {
+	case nil:
+		return nil
+	case *[]byte:
+		*out, err = io.ReadAll(resp.Body)
+		return err
+	default:
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+}
+`)
+	for _, name := range g.compOrder {
+		fmt.Fprintf(&b, "\ntype %s %s\n", name, goType(g.components[name], true))
+	}
+	for _, rt := range routes {
+		b.WriteString("\n")
+		if rt.Summary != "" {
+			fmt.Fprintf(&b, "// %s %s\n//\n", rt.OperationID, lowerFirst(rt.Summary))
+		}
+		fmt.Fprintf(&b, "// %s %s\n", rt.Method, rt.Path)
+		params := []string{"ctx context.Context"}
+		for _, p := range rt.PathParams {
+			params = append(params, goIdent(p)+" string")
+		}
+		if len(rt.Query) > 0 {
+			params = append(params, "query url.Values")
+		}
+		bodyArg := "nil"
+		if rt.Body != nil {
+			params = append(params, "body "+goType(rt.Body, false))
+			bodyArg = "body"
+		} else if rt.Method == "POST" || rt.Method == "PUT" || rt.Method == "PATCH" {
+			params = append(params, "body interface{}")
+			bodyArg = "body"
+		}
+		queryArg := "nil"
+		if len(rt.Query) > 0 {
+			queryArg = "query"
+		}
+		path := goPath(rt.Path)
+		if rt.Status == 204 {
+			fmt.Fprintf(&b, "func (c *Client) %s(%s) error {\n", rt.OperationID, strings.Join(params, ", "))
+			fmt.Fprintf(&b, "\treturn c.do(ctx, %q, %s, %s, %s, nil)\n}\n", rt.Method, path, queryArg, bodyArg)
+			continue
+		}
+		result := "[]byte"
+		if rt.Response != nil && rt.Response.Type+rt.Response.Ref != "" {
+			result = goType(rt.Response, false)
+		}
+		fmt.Fprintf(&b, "func (c *Client) %s(%s) (%s, error) {\n", rt.OperationID, strings.Join(params, ", "), result)
+		fmt.Fprintf(&b, "\tvar out %s\n", result)
+		fmt.Fprintf(&b, "\terr := c.do(ctx, %q, %s, %s, %s, &out)\n\treturn out, err\n}\n", rt.Method, path, queryArg, bodyArg)
+	}
+	imports := []string{"bytes", "context", "encoding/json", "io", "net/http", "net/url", "strings"}
+	if bytes.Contains(b.Bytes(), []byte("time.Time")) {
+		imports = append(imports, "time")
+	}
+	var head bytes.Buffer
+	head.WriteString(`// Code generated by gen_sdk.go; DO NOT EDIT.
+
+// Package gatewayclient is a client for the HTTP API of the whatsmeow
+// provider, generated from the server's handlers alongside openapi.json.
+package gatewayclient
+
+import (
+`)
+	for _, imp := range imports {
+		fmt.Fprintf(&head, "\t%q\n", imp)
+	}
+	head.WriteString(")\n\n")
+	return append(head.Bytes(), b.Bytes()...)
+}
+
+func goType(s *schema, definition bool) string {
+	t := "interface{}"
+	switch {
+	case s.Ref != "":
+		t = s.Ref
+	case s.Type == "string" && s.Format == "date-time":
+		t = "time.Time"
+	case s.Type == "string" && s.Format == "byte":
+		t = "[]byte"
+	case s.Type == "string":
+		t = "string"
+	case s.Type == "integer":
+		t = "int64"
+	case s.Type == "number":
+		t = "float64"
+	case s.Type == "boolean":
+		t = "bool"
+	case s.Type == "array":
+		t = "[]" + goType(s.Items, false)
+	case s.Type == "object" && len(s.Properties) > 0:
+		var b strings.Builder
+		b.WriteString("struct {\n")
+		for _, p := range s.Properties {
+			omit := ""
+			if p.Optional {
+				omit = ",omitempty"
+			}
+			if p.Doc != "" && definition {
+				fmt.Fprintf(&b, "\t// %s\n", p.Doc)
+			}
+			fmt.Fprintf(&b, "\t%s %s `json:\"%s%s\"`\n", goIdent(p.GoName), goType(p.Schema, false), p.Name, omit)
+		}
+		b.WriteString("}")
+		t = b.String()
+	case s.Type == "object" && s.Additional != nil:
+		t = "map[string]" + goType(s.Additional, false)
+	case s.Type == "object":
+		t = "map[string]interface{}"
+	}
+	if s.Pointer && !definition && !strings.HasPrefix(t, "[]") && !strings.HasPrefix(t, "map[") && t != "interface{}" {
+		t = "*" + t
+	}
+	return t
+}
+
+// goPath builds the request path from the path parameters.
+func goPath(path string) string {
+	parts := regexp.MustCompile(`\{(\w+)(\.\.\.)?\}`).Split(path, -1)
+	params := regexp.MustCompile(`\{(\w+)(\.\.\.)?\}`).FindAllStringSubmatch(path, -1)
+	expr := strconv.Quote(parts[0])
+	for i, p := range params {
+		if p[2] != "" {
+			expr += " + " + goIdent(p[1])
+		} else {
+			expr += " + url.PathEscape(" + goIdent(p[1]) + ")"
+		}
+		if parts[i+1] != "" {
+			expr += " + " + strconv.Quote(parts[i+1])
+		}
+	}
+	return expr
+}
+
+// TypeScript client
+
+func (g *generator) tsClient(routes []route) []byte {
+	var b bytes.Buffer
+	b.WriteString(`// Code generated by gen_sdk.go; DO NOT EDIT.
+//
+// Client for the HTTP API of the whatsmeow provider, generated from the
+// server's handlers alongside openapi.json.
+
+export interface ClientOptions {
+    baseUrl: string;
+    /** Sent as X-API-Key to the endpoints that take one. */
+    apiKey?: string;
+    /** Sent as X-Admin-Key to the operator endpoints. */
+    adminKey?: string;
+    fetch?: typeof fetch;
+}
+
+export type Query = Record<string, string | number | boolean | undefined>;
+
+/** A response with an error status; the message is its body. */
+export class GatewayError extends Error {
+    constructor(readonly status: number, message: string) {
+        super(message);
+        this.name = 'GatewayError';
+    }
+}
+`)
+	for _, name := range g.compOrder {
+		fmt.Fprintf(&b, "\nexport interface %s %s\n", name, tsType(g.components[name], 0))
+	}
+	b.WriteString(`
+export class GatewayClient {
+    private readonly baseUrl: string;
+
+    constructor(private readonly options: ClientOptions) {
+        this.baseUrl = options.baseUrl.replace(/\/$/, '');
+    }
+
+    private async request<T>(method: string, path: string, query?: Query, body?: unknown): Promise<T> {
+        let url = this.baseUrl + path;
+        if (query) {
+            const params = new URLSearchParams();
+            for (const [key, value] of Object.entries(query)) {
+                if (value !== undefined) {
+                    params.set(key, String(value));
+                }
+            }
+            if (params.size > 0) {
+                url += '?' + params.toString();
+            }
+        }
+        const headers: Record<string, string> = {};
+        if (body !== undefined) {
+            headers['Content-Type'] = 'application/json';
+        }
+        if (this.options.apiKey) {
+            headers['X-API-Key'] = this.options.apiKey;
+        }
+        if (this.options.adminKey) {
+            headers['X-Admin-Key'] = this.options.adminKey;
+        }
+        const doFetch = this.options.fetch ?? fetch;
+        const res = await doFetch(url, {
+            method,
+            headers,
+            body: body === undefined ? undefined : JSON.stringify(body),
+        });
+        if (!res.ok) {
+            throw new GatewayError(res.status, (await res.text()).trim());
+        }
+        if (res.status === 204) {
+            return undefined as T;
+        }
+        if (res.headers.get('Content-Type')?.includes('application/json')) {
+            return (await res.json()) as T;
+        }
+        return (await res.arrayBuffer()) as T;
+    }
+`)
+	for _, rt := range routes {
+		b.WriteString("\n")
+		if rt.Summary != "" {
+			fmt.Fprintf(&b, "    /** %s */\n", strings.ReplaceAll(rt.Summary, "*/", "*\\/"))
+		}
+		var params []string
+		for _, p := range rt.PathParams {
+			params = append(params, tsIdent(p)+": string")
+		}
+		bodyArg := "undefined"
+		if rt.Body != nil {
+			params = append(params, "body: "+tsType(rt.Body, 1))
+			bodyArg = "body"
+		} else if rt.Method == "POST" || rt.Method == "PUT" || rt.Method == "PATCH" {
+			params = append(params, "body?: unknown")
+			bodyArg = "body"
+		}
+		queryArg := "undefined"
+		if len(rt.Query) > 0 {
+			fields := make([]string, len(rt.Query))
+			for i, q := range rt.Query {
+				fields[i] = tsKey(q) + "?: string | number | boolean"
+			}
+			params = append(params, "query: { "+strings.Join(fields, "; ")+" } = {}")
+			queryArg = "query"
+		}
+		result := "unknown"
+		switch {
+		case rt.Status == 204:
+			result = "void"
+		case rt.Response != nil && rt.Response.Type+rt.Response.Ref != "":
+			result = tsType(rt.Response, 1)
+		}
+		fmt.Fprintf(&b, "    %s(%s): Promise<%s> {\n", lowerFirst(rt.OperationID), strings.Join(params, ", "), result)
+		fmt.Fprintf(&b, "        return this.request('%s', %s, %s, %s);\n    }\n", rt.Method, tsPath(rt.Path), queryArg, bodyArg)
+	}
+	b.WriteString("}\n")
+	return b.Bytes()
+}
+
+func tsType(s *schema, depth int) string {
+	t := "unknown"
+	switch {
+	case s.Ref != "":
+		t = s.Ref
+	case s.Type == "string":
+		t = "string"
+	case s.Type == "integer" || s.Type == "number":
+		t = "number"
+	case s.Type == "boolean":
+		t = "boolean"
+	case s.Type == "array":
+		t = tsType(s.Items, depth)
+		if strings.ContainsAny(t, " |") {
+			t = "(" + t + ")"
+		}
+		t += "[]"
+	case s.Type == "object" && len(s.Properties) > 0:
+		indent := strings.Repeat("    ", depth)
+		var b strings.Builder
+		b.WriteString("{\n")
+		for _, p := range s.Properties {
+			if p.Doc != "" {
+				fmt.Fprintf(&b, "%s    /** %s */\n", indent, strings.ReplaceAll(p.Doc, "*/", "*\\/"))
+			}
+			opt := ""
+			if p.Optional {
+				opt = "?"
+			}
+			fmt.Fprintf(&b, "%s    %s%s: %s;\n", indent, tsKey(p.Name), opt, tsType(p.Schema, depth+1))
+		}
+		b.WriteString(indent + "}")
+		t = b.String()
+	case s.Type == "object" && s.Additional != nil:
+		t = "Record<string, " + tsType(s.Additional, depth) + ">"
+	case s.Type == "object":
+		t = "Record<string, unknown>"
+	}
+	if s.Pointer && t != "unknown" {
+		t += " | null"
+	}
+	return t
+}
+
+func tsPath(path string) string {
+	re := regexp.MustCompile(`\{(\w+)(\.\.\.)?\}`)
+	if !re.MatchString(path) {
+		return "'" + path + "'"
+	}
+	return "`" + re.ReplaceAllStringFunc(path, func(m string) string {
+		p := re.FindStringSubmatch(m)
+		if p[2] != "" {
+			return "${" + tsIdent(p[1]) + "}"
+		}
+		return "${encodeURIComponent(" + tsIdent(p[1]) + ")}"
+	}) + "`"
+}
+
+// Helpers
+
+func isSelector(e ast.Expr, x, sel string) bool {
+	s, ok := e.(*ast.SelectorExpr)
+	return ok && s.Sel.Name == sel && exprName(s.X) == x
+}
+
+// exprName is the name of an identifier or the last name of a selector.
+func exprName(e ast.Expr) string {
+	switch e := e.(type) {
+	case *ast.Ident:
+		return e.Name
+	case *ast.SelectorExpr:
+		return e.Sel.Name
+	}
+	return ""
+}
+
+func unaddr(e ast.Expr) ast.Expr {
+	if u, ok := e.(*ast.UnaryExpr); ok && u.Op == token.AND {
+		return u.X
+	}
+	return e
+}
+
+func stringLit(e ast.Expr) (string, bool) {
+	lit, ok := e.(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return "", false
+	}
+	s, err := strconv.Unquote(lit.Value)
+	return s, err == nil
+}
+
+var statusCodes = map[string]int{
+	"StatusOK": 200, "StatusCreated": 201, "StatusAccepted": 202, "StatusNoContent": 204,
+	"StatusMultiStatus": 207, "StatusBadRequest": 400, "StatusNotFound": 404, "StatusConflict": 409,
+}
+
+// statusCode is the status of an http.StatusX constant, or 0 when the
+// status is computed.
+func statusCode(e ast.Expr) int {
+	if sel, ok := e.(*ast.SelectorExpr); ok && exprName(sel.X) == "http" {
+		if code, ok := statusCodes[sel.Sel.Name]; ok {
+			return code
+		}
+		return 500
+	}
+	return 0
+}
+
+func http200(status int) string {
+	switch status {
+	case 201:
+		return "Created"
+	case 202:
+		return "Accepted"
+	case 204:
+		return "No content"
+	}
+	return "OK"
+}
+
+// summary is the first sentence of a handler's doc comment, without the
+// handler's name.
+func summary(fn *ast.FuncDecl) string {
+	if fn.Doc == nil {
+		return ""
+	}
+	text := strings.Join(strings.Fields(fn.Doc.Text()), " ")
+	text, _, _ = strings.Cut(text, ". ")
+	text = strings.TrimSuffix(text, ".")
+	text = strings.TrimPrefix(text, fn.Name.Name+" ")
+	if text == "" {
+		return ""
+	}
+	return exported(text) + "."
+}
+
+func fieldDoc(f *ast.Field) string {
+	doc := f.Comment
+	if doc == nil {
+		doc = f.Doc
+	}
+	if doc == nil {
+		return ""
+	}
+	return strings.Join(strings.Fields(doc.Text()), " ")
+}
+
+// tagOf groups operations by the first path segment.
+func tagOf(path string) string {
+	seg, _, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	if seg == "admin" {
+		rest := strings.TrimPrefix(path, "/admin/")
+		seg, _, _ = strings.Cut(rest, "/")
+		return "admin/" + seg
+	}
+	return seg
+}
+
+func exported(s string) string {
+	if s == "" {
+		return s
+	}
+	r := []rune(s)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
+
+var initialisms = map[string]bool{"id": true, "ids": true, "jid": true, "lid": true, "url": true, "api": true, "qr": true, "ip": true, "sla": true}
+
+// goFieldName is the Go name of a snake_case JSON key.
+func goFieldName(key string) string {
+	words := strings.FieldsFunc(key, func(r rune) bool { return r == '_' || r == '-' || r == '.' })
+	for i, w := range words {
+		if initialisms[w] {
+			words[i] = strings.ToUpper(w)
+			if w == "ids" {
+				words[i] = "IDs"
+			}
+		} else {
+			words[i] = exported(w)
+		}
+	}
+	return strings.Join(words, "")
+}
+
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	r := []rune(s)
+	r[0] = unicode.ToLower(r[0])
+	return string(r)
+}
+
+var goKeywords = map[string]bool{"type": true, "func": true, "var": true, "range": true, "map": true, "chan": true, "go": true}
+
+func goIdent(s string) string {
+	if goKeywords[s] {
+		return s + "_"
+	}
+	return s
+}
+
+func tsIdent(s string) string {
+	return s
+}
+
+func tsKey(s string) string {
+	if regexp.MustCompile(`^[A-Za-z_$][\w$]*$`).MatchString(s) {
+		return s
+	}
+	return strconv.Quote(s)
+}
@@ -0,0 +1,411 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"google.golang.org/protobuf/proto"
+)
+
+// POST /groups creates a group and fills it from a segment of the tenant's
+// contacts: private chats with a tag and/or gateway contacts whose
+// attributes match. WhatsApp throttles adding many people at once, so the
+// group is created with the first batch and the rest are added in batches of
+// GROUP_ADD_BATCH_SIZE every GROUP_ADD_INTERVAL in the background. People
+// whose privacy settings don't allow being added are sent a GroupInviteMessage
+// instead (reported with invited_instead), unless invite_instead is false. The
+// membership report is kept under /groups/{jid}/creation and sent as the
+// group.populated webhook; a population cut short by a restart isn't
+// resumed.
+//
+// Without a segment, a group whose participants all fit in the first batch
+// is created synchronously: the answer is the membership report with each
+// participant's result, status 201.
+
+var (
+	groupAddBatchSize = envInt("GROUP_ADD_BATCH_SIZE", 20)
+	groupAddInterval  = envDuration("GROUP_ADD_INTERVAL", 5*time.Second)
+)
+
+const (
+	maxGroupParticipants = 1023 // besides us
+	maxGroupNameLength   = 100
+)
+
+type groupSegment struct {
+	Tag        string                 `json:"tag,omitempty"`        // private chats with this tag
+	Attributes map[string]interface{} `json:"attributes,omitempty"` // gateway contacts with these attribute values
+}
+
+type createGroupRequest struct {
+	Name         string       `json:"name"`
+	Subject      string       `json:"subject,omitempty"` // same as name
+	Segment      groupSegment `json:"segment"`
+	Participants []string     `json:"participants,omitempty"` // added to the segment
+	// Invite those who can't be added; defaults to true.
+	InviteInstead *bool `json:"invite_instead,omitempty"`
+}
+
+type groupMemberResult struct {
+	Phone  string `json:"phone"`
+	Status string `json:"status"`          // added, invited, already_member, removed, promoted, demoted, not_member, not_on_whatsapp or failed
+	Error  int    `json:"error,omitempty"` // WhatsApp's code when failed
+
+	// Their privacy settings kept them from being added, so they were sent
+	// an invite.
+	InvitedInstead bool `json:"invited_instead,omitempty"`
+}
+
+type groupCreation struct {
+	Group      string              `json:"group"`
+	Name       string              `json:"name"`
+	Segment    groupSegment        `json:"segment"`
+	Status     string              `json:"status"` // populating or done
+	Members    []groupMemberResult `json:"members"`
+	Summary    map[string]int      `json:"summary"`
+	CreatedAt  time.Time           `json:"created_at"`
+	FinishedAt *time.Time          `json:"finished_at,omitempty"`
+}
+
+func (c *groupCreation) summarize() {
+	c.Summary = map[string]int{}
+	for _, m := range c.Members {
+		c.Summary[m.Status]++
+	}
+}
+
+// segmentMembers resolves a segment to phone-number JIDs, sorted and without
+// duplicates.
+func segmentMembers(ctx context.Context, seg groupSegment) ([]types.JID, error) {
+	query := `SELECT jid FROM contacts WHERE 1 = 1`
+	var args []interface{}
+	if seg.Tag != "" {
+		query = `SELECT chat_jid FROM chat_tags WHERE tag = ? AND chat_jid LIKE '%@` + types.DefaultUserServer + `'`
+		args = append(args, normalizeTag(seg.Tag))
+		if len(seg.Attributes) > 0 {
+			query += ` AND chat_jid IN (SELECT jid FROM contacts WHERE 1 = 1`
+		}
+	}
+	for key, value := range seg.Attributes {
+		if !attributeKeyRe.MatchString(key) {
+			return nil, fmt.Errorf("invalid attribute %q", key)
+		}
+		switch v := value.(type) {
+		case string, float64:
+		case bool:
+			// json_extract gives booleans as 1 and 0.
+			if value = 0; v {
+				value = 1
+			}
+		default:
+			return nil, fmt.Errorf("attribute %q must be a string, number or boolean", key)
+		}
+		query += ` AND json_extract(attributes, ?) = ?`
+		args = append(args, "$."+key, value)
+	}
+	if seg.Tag != "" && len(seg.Attributes) > 0 {
+		query += `)`
+	}
+	rows, err := gatewayDB.QueryContext(ctx, query+` ORDER BY 1`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var members []types.JID
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return nil, err
+		}
+		if jid, err := types.ParseJID(s); err == nil && jid.Server == types.DefaultUserServer {
+			members = append(members, jid)
+		}
+	}
+	return members, rows.Err()
+}
+
+func saveGroupCreation(ctx context.Context, c *groupCreation) {
+	c.summarize()
+	segment, _ := json.Marshal(c.Segment)
+	members, _ := json.Marshal(c.Members)
+	var finished int64
+	if c.FinishedAt != nil {
+		finished = c.FinishedAt.Unix()
+	}
+	_, err := gatewayDB.ExecContext(ctx, `
+		INSERT INTO group_creations (group_jid, name, segment, status, members, created_at, finished_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (group_jid) DO UPDATE SET status = excluded.status, members = excluded.members,
+			finished_at = excluded.finished_at`,
+		c.Group, c.Name, string(segment), c.Status, string(members), c.CreatedAt.Unix(), finished)
+	if err != nil {
+		waLogger.Errorf("Failed to save creation report of group %s: %v", c.Group, err)
+	}
+}
+
+// participantResult turns WhatsApp's answer for one participant into a
+// report entry; invite is set when they have to be invited instead.
+func participantResult(p types.GroupParticipant) (res groupMemberResult, invite bool) {
+	phone := p.PhoneNumber.User
+	if phone == "" {
+		phone = p.JID.User
+	}
+	res = groupMemberResult{Phone: "+" + phone, Status: "added"}
+	switch p.Error {
+	case 0, 200:
+	case 403:
+		return res, true
+	case 409:
+		res.Status = "already_member"
+	default:
+		res.Status, res.Error = "failed", p.Error
+	}
+	return res, false
+}
+
+// groupInvite is what's needed to invite people who couldn't be added to a
+// group; link is fetched the first time it's needed.
+type groupInvite struct {
+	group types.JID
+	name  string
+	link  string
+}
+
+// inviteToGroup sends someone who couldn't be added an invite: the private
+// invite WhatsApp issued for them as a GroupInviteMessage, or else the
+// group's invite link.
+func inviteToGroup(ctx context.Context, inv *groupInvite, p types.GroupParticipant) error {
+	to := p.PhoneNumber
+	if to.IsEmpty() {
+		to = p.JID
+	}
+	to = toPhoneJID(ctx, to)
+	var msg *waE2E.Message
+	if p.AddRequest != nil && p.AddRequest.Code != "" {
+		msg = &waE2E.Message{GroupInviteMessage: &waE2E.GroupInviteMessage{
+			GroupJID:         proto.String(inv.group.String()),
+			GroupName:        proto.String(inv.name),
+			InviteCode:       proto.String(p.AddRequest.Code),
+			InviteExpiration: proto.Int64(p.AddRequest.Expiration.Unix()),
+		}}
+	} else {
+		if inv.link == "" {
+			l, err := client.GetGroupInviteLink(ctx, inv.group, false)
+			if err != nil {
+				return fmt.Errorf("failed to get invite link: %w", err)
+			}
+			inv.link = l
+		}
+		msg = &waE2E.Message{Conversation: proto.String(fmt.Sprintf("You're invited to join %s: %s", inv.name, inv.link))}
+	}
+	_, err := sendOrQueue(ctx, to, msg, sendOptions{AllowDuplicate: true})
+	return err
+}
+
+// participantResults reports what happened to each participant of an add.
+// Those who can't be added are invited when inv is set.
+func participantResults(ctx context.Context, inv *groupInvite, participants []types.GroupParticipant) []groupMemberResult {
+	results := make([]groupMemberResult, 0, len(participants))
+	for _, p := range participants {
+		res, invite := participantResult(p)
+		if invite && inv == nil {
+			res.Status, res.Error = "failed", p.Error
+		} else if invite {
+			if err := inviteToGroup(ctx, inv, p); err != nil {
+				waLogger.Errorf("Failed to invite %s to group %s: %v", res.Phone, inv.group, err)
+				res.Status, res.Error = "failed", p.Error
+			} else {
+				res.Status, res.InvitedInstead = "invited", true
+			}
+		}
+		results = append(results, res)
+	}
+	return results
+}
+
+// populateGroup adds the remaining members batch by batch and reports the
+// final membership.
+func populateGroup(ctx context.Context, c *groupCreation, group types.JID, created []types.GroupParticipant, rest []types.JID, invite bool) {
+	var inv *groupInvite
+	if invite {
+		inv = &groupInvite{group: group, name: c.Name}
+	}
+	c.Members = append(c.Members, participantResults(ctx, inv, created)...)
+	saveGroupCreation(ctx, c)
+	for len(rest) > 0 {
+		time.Sleep(groupAddInterval)
+		batch := rest[:min(groupAddBatchSize, len(rest))]
+		rest = rest[len(batch):]
+		participants, err := client.UpdateGroupParticipants(ctx, group, batch, whatsmeow.ParticipantChangeAdd)
+		if err != nil {
+			waLogger.Errorf("Failed to add %d participants to group %s: %v", len(batch), group, err)
+			for _, jid := range batch {
+				c.Members = append(c.Members, groupMemberResult{Phone: "+" + jid.User, Status: "failed"})
+			}
+		} else {
+			c.Members = append(c.Members, participantResults(ctx, inv, participants)...)
+		}
+		saveGroupCreation(ctx, c)
+	}
+	now := time.Now().UTC()
+	c.Status, c.FinishedAt = "done", &now
+	saveGroupCreation(ctx, c)
+	waLogger.Infof("Group %s populated: %v", group, c.Summary)
+	emitWebhook("group.populated", c)
+}
+
+func createGroupFromSegment(w http.ResponseWriter, r *http.Request) {
+	if sessionArchived() {
+		http.Error(w, "Session is archived", http.StatusConflict)
+		return
+	}
+	if !requireClient(w, r, false) {
+		return
+	}
+	var req createGroupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Name == "" {
+		req.Name = req.Subject
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len([]rune(req.Name)) > maxGroupNameLength {
+		http.Error(w, fmt.Sprintf("name (or subject) is required and at most %d characters", maxGroupNameLength), http.StatusBadRequest)
+		return
+	}
+	if req.Segment.Tag == "" && len(req.Segment.Attributes) == 0 && len(req.Participants) == 0 {
+		http.Error(w, "Set a segment tag and/or attributes, or participants", http.StatusBadRequest)
+		return
+	}
+	if req.Segment.Tag != "" && !tagRe.MatchString(normalizeTag(req.Segment.Tag)) {
+		http.Error(w, fmt.Sprintf("invalid tag %q", req.Segment.Tag), http.StatusBadRequest)
+		return
+	}
+	if key := apiKeyFromContext(r.Context()); key != nil && (!key.Policy.AllowGroups || len(key.Policy.GroupAllowlist) > 0) {
+		http.Error(w, "creating groups needs a key that may send to any group", http.StatusForbidden)
+		return
+	}
+
+	var members []types.JID
+	if req.Segment.Tag != "" || len(req.Segment.Attributes) > 0 {
+		var err error
+		if members, err = segmentMembers(r.Context(), req.Segment); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	for _, p := range req.Participants {
+		jid, err := parseRecipient(p)
+		if err != nil || jid.Server != types.DefaultUserServer {
+			http.Error(w, fmt.Sprintf("invalid participant %q", p), http.StatusBadRequest)
+			return
+		}
+		members = append(members, jid)
+	}
+	seen := map[string]bool{}
+	if client.Store.ID != nil {
+		seen[client.Store.ID.User] = true
+	}
+	var phones []string
+	for _, jid := range members {
+		if !seen[jid.User] {
+			seen[jid.User] = true
+			phones = append(phones, "+"+jid.User)
+		}
+	}
+	if len(phones) == 0 {
+		http.Error(w, "The segment matched no contacts", http.StatusUnprocessableEntity)
+		return
+	}
+	if len(phones) > maxGroupParticipants {
+		http.Error(w, fmt.Sprintf("The segment has %d contacts, a group takes at most %d", len(phones), maxGroupParticipants),
+			http.StatusUnprocessableEntity)
+		return
+	}
+
+	creation := &groupCreation{Name: req.Name, Segment: req.Segment, Status: "populating",
+		Members: []groupMemberResult{}, CreatedAt: time.Now().UTC()}
+	onWhatsApp, err := client.IsOnWhatsApp(r.Context(), phones)
+	if err != nil {
+		waLogger.Errorf("Failed to look up group members on WhatsApp: %v", err)
+		http.Error(w, "Failed to look up members on WhatsApp", http.StatusBadGateway)
+		return
+	}
+	var joinable []types.JID
+	for _, res := range onWhatsApp {
+		if res.IsIn {
+			joinable = append(joinable, res.JID)
+		} else {
+			creation.Members = append(creation.Members, groupMemberResult{Phone: "+" + onlyDigits(res.Query), Status: "not_on_whatsapp"})
+		}
+	}
+	if len(joinable) == 0 {
+		http.Error(w, "None of the segment's contacts are on WhatsApp", http.StatusUnprocessableEntity)
+		return
+	}
+
+	first := joinable[:min(groupAddBatchSize, len(joinable))]
+	info, err := client.CreateGroup(r.Context(), whatsmeow.ReqCreateGroup{Name: req.Name, Participants: first})
+	if err != nil {
+		waLogger.Errorf("Failed to create group %q: %v", req.Name, err)
+		http.Error(w, "Failed to create group", http.StatusBadGateway)
+		return
+	}
+	creation.Group = info.JID.String()
+	waLogger.Infof("Created group %s (%q) for %d members", info.JID, req.Name, len(joinable))
+	saveGroupCreation(r.Context(), creation)
+	// Invites go through the send policy of the caller's key.
+	invite := req.InviteInstead == nil || *req.InviteInstead
+	rest := joinable[len(first):]
+	if len(rest) == 0 && req.Segment.Tag == "" && len(req.Segment.Attributes) == 0 {
+		populateGroup(context.WithoutCancel(r.Context()), creation, info.JID, info.Participants, nil, invite)
+		writeJSON(w, http.StatusCreated, creation)
+		return
+	}
+	go populateGroup(context.WithoutCancel(r.Context()), creation, info.JID, info.Participants, rest, invite)
+
+	writeJSON(w, http.StatusAccepted, map[string]interface{}{
+		"group":   creation.Group,
+		"name":    creation.Name,
+		"status":  creation.Status,
+		"members": len(joinable),
+	})
+}
+
+func getGroupCreation(w http.ResponseWriter, r *http.Request) {
+	jid, ok := parseJID(r.PathValue("jid"))
+	if !ok || jid.Server != types.GroupServer {
+		http.Error(w, "Invalid group JID", http.StatusBadRequest)
+		return
+	}
+	var c groupCreation
+	var segment, members string
+	var created, finished int64
+	err := gatewayDB.QueryRowContext(r.Context(),
+		`SELECT group_jid, name, segment, status, members, created_at, finished_at FROM group_creations WHERE group_jid = ?`,
+		jid.String()).Scan(&c.Group, &c.Name, &segment, &c.Status, &members, &created, &finished)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Group was not created from a segment", http.StatusNotFound)
+		return
+	} else if err != nil {
+		waLogger.Errorf("Failed to load creation report of group %s: %v", jid, err)
+		http.Error(w, "Failed to load creation report", http.StatusInternalServerError)
+		return
+	}
+	json.Unmarshal([]byte(segment), &c.Segment)
+	json.Unmarshal([]byte(members), &c.Members)
+	c.CreatedAt = time.Unix(created, 0).UTC()
+	c.FinishedAt = unixPtr(finished)
+	c.summarize()
+	writeJSON(w, http.StatusOK, c)
+}
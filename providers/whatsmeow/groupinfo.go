@@ -0,0 +1,104 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// GET /groups/{jid} describes a group we're in: its subject and
+// description, who created it and when, the settings admins control and the
+// participants with their roles. The info is cached for a few minutes (see
+// cachedGroupInfoFor); ?refresh=true fetches it anew.
+
+type groupParticipantInfo struct {
+	JID         string `json:"jid"`
+	Phone       string `json:"phone,omitempty"`
+	LID         string `json:"lid,omitempty"`
+	DisplayName string `json:"display_name,omitempty"`
+	Admin       bool   `json:"admin"`
+	SuperAdmin  bool   `json:"super_admin"` // the creator, who can't be demoted
+}
+
+type groupInfoResponse struct {
+	JID            string     `json:"jid"`
+	Subject        string     `json:"subject"`
+	SubjectSetAt   *time.Time `json:"subject_set_at,omitempty"`
+	SubjectSetBy   string     `json:"subject_set_by,omitempty"`
+	Description    string     `json:"description,omitempty"`
+	DescriptionID  string     `json:"description_id,omitempty"`
+	Owner          string     `json:"owner,omitempty"`
+	CreatedAt      *time.Time `json:"created_at,omitempty"`
+	Announce       bool       `json:"announce"`        // only admins send messages
+	Locked         bool       `json:"locked"`          // only admins edit the group info
+	ApprovalNeeded bool       `json:"approval_needed"` // joining takes an admin's approval
+	// Seconds until messages disappear; 0 when they don't.
+	DisappearingTimer uint32                 `json:"disappearing_timer,omitempty"`
+	IsCommunity       bool                   `json:"is_community,omitempty"`
+	Community         string                 `json:"community,omitempty"` // the community the group belongs to
+	Participants      []groupParticipantInfo `json:"participants"`
+	ParticipantCount  int                    `json:"participant_count"`
+	AdminCount        int                    `json:"admin_count"`
+}
+
+func getGroupInfo(w http.ResponseWriter, r *http.Request) {
+	group, ok := parseJID(r.PathValue("jid"))
+	if !ok || group.Server != types.GroupServer {
+		http.Error(w, "Invalid group JID", http.StatusBadRequest)
+		return
+	}
+	if !requireClient(w, r, false) {
+		return
+	}
+	if r.URL.Query().Get("refresh") == "true" {
+		forgetGroupInfo(group)
+	}
+	info, err := cachedGroupInfoFor(r.Context(), group)
+	if err != nil {
+		writeGroupError(w, group, "get group info", err)
+		return
+	}
+	ctx := r.Context()
+	resp := groupInfoResponse{
+		JID:               group.String(),
+		Subject:           info.Name,
+		SubjectSetBy:      jidString(toPhoneJID(ctx, info.NameSetBy.ToNonAD())),
+		Description:       info.Topic,
+		DescriptionID:     info.TopicID,
+		Owner:             jidString(toPhoneJID(ctx, info.OwnerJID.ToNonAD())),
+		Announce:          info.IsAnnounce,
+		Locked:            info.IsLocked,
+		ApprovalNeeded:    info.IsJoinApprovalRequired,
+		DisappearingTimer: info.DisappearingTimer,
+		IsCommunity:       info.IsParent,
+		Community:         jidString(info.LinkedParentJID),
+		Participants:      make([]groupParticipantInfo, 0, len(info.Participants)),
+	}
+	if !info.NameSetAt.IsZero() {
+		resp.SubjectSetAt = &info.NameSetAt
+	}
+	if !info.GroupCreated.IsZero() {
+		resp.CreatedAt = &info.GroupCreated
+	}
+	for _, p := range info.Participants {
+		participant := groupParticipantInfo{
+			JID:         p.JID.String(),
+			LID:         jidString(p.LID),
+			DisplayName: p.DisplayName,
+			Admin:       p.IsAdmin || p.IsSuperAdmin,
+			SuperAdmin:  p.IsSuperAdmin,
+		}
+		if !p.PhoneNumber.IsEmpty() {
+			participant.Phone = "+" + p.PhoneNumber.User
+		} else if p.JID.Server == types.DefaultUserServer {
+			participant.Phone = "+" + p.JID.User
+		}
+		if participant.Admin {
+			resp.AdminCount++
+		}
+		resp.Participants = append(resp.Participants, participant)
+	}
+	resp.ParticipantCount = len(resp.Participants)
+	writeJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// POST /groups/{jid}/participants adds people to or removes them from a
+// group we administer, or makes members admins (promote) and takes that
+// back (demote), answering with the result for each. People whose privacy
+// settings don't allow being added are sent an invite instead, as when
+// creating a group (see groupcreate.go), unless invite_instead is false.
+
+type groupParticipantsRequest struct {
+	Action       string   `json:"action"` // add, remove, promote or demote
+	Participants []string `json:"participants"`
+	// Invite those who can't be added; defaults to true.
+	InviteInstead *bool `json:"invite_instead,omitempty"`
+}
+
+var groupParticipantActions = map[string]whatsmeow.ParticipantChange{
+	"add":     whatsmeow.ParticipantChangeAdd,
+	"remove":  whatsmeow.ParticipantChangeRemove,
+	"promote": whatsmeow.ParticipantChangePromote,
+	"demote":  whatsmeow.ParticipantChangeDemote,
+}
+
+// changedParticipantStatus is the result status of a member an action other
+// than add went through for.
+var changedParticipantStatus = map[whatsmeow.ParticipantChange]string{
+	whatsmeow.ParticipantChangeRemove:  "removed",
+	whatsmeow.ParticipantChangePromote: "promoted",
+	whatsmeow.ParticipantChangeDemote:  "demoted",
+}
+
+// changedParticipantResult reports what happened to one participant of a
+// removal, promotion or demotion.
+func changedParticipantResult(p types.GroupParticipant, action whatsmeow.ParticipantChange) groupMemberResult {
+	phone := p.PhoneNumber.User
+	if phone == "" {
+		phone = p.JID.User
+	}
+	res := groupMemberResult{Phone: "+" + phone, Status: changedParticipantStatus[action]}
+	switch p.Error {
+	case 0, 200:
+	case 404:
+		res.Status = "not_member"
+	default:
+		res.Status, res.Error = "failed", p.Error
+	}
+	return res
+}
+
+// writeGroupError reports a failed group request WhatsApp turned down.
+func writeGroupError(w http.ResponseWriter, group types.JID, what string, err error) {
+	switch {
+	case errors.Is(err, whatsmeow.ErrGroupNotFound), errors.Is(err, whatsmeow.ErrNotInGroup), errors.Is(err, whatsmeow.ErrIQNotFound):
+		http.Error(w, "Group not found or not a member", http.StatusNotFound)
+	case errors.Is(err, whatsmeow.ErrIQForbidden), errors.Is(err, whatsmeow.ErrIQNotAuthorized):
+		http.Error(w, "Not an admin of the group", http.StatusForbidden)
+	default:
+		waLogger.Errorf("Failed to %s of group %s: %v", what, group, err)
+		http.Error(w, "Failed to "+what, http.StatusBadGateway)
+	}
+}
+
+func updateGroupParticipants(w http.ResponseWriter, r *http.Request) {
+	if sessionArchived() {
+		http.Error(w, "Session is archived", http.StatusConflict)
+		return
+	}
+	if !requireClient(w, r, false) {
+		return
+	}
+	group, ok := parseJID(r.PathValue("jid"))
+	if !ok || group.Server != types.GroupServer {
+		http.Error(w, "Invalid group JID", http.StatusBadRequest)
+		return
+	}
+	var req groupParticipantsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	action, ok := groupParticipantActions[req.Action]
+	if !ok {
+		http.Error(w, "action must be add, remove, promote or demote", http.StatusBadRequest)
+		return
+	}
+	if len(req.Participants) == 0 {
+		http.Error(w, "participants is required", http.StatusBadRequest)
+		return
+	}
+	if len(req.Participants) > maxGroupParticipants {
+		http.Error(w, fmt.Sprintf("At most %d participants at a time", maxGroupParticipants), http.StatusBadRequest)
+		return
+	}
+	if err := checkSendPolicy(r.Context(), group); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	var jids []types.JID
+	seen := map[string]bool{}
+	for _, p := range req.Participants {
+		jid, err := parseRecipient(p)
+		if err != nil || jid.Server != types.DefaultUserServer {
+			http.Error(w, fmt.Sprintf("invalid participant %q", p), http.StatusBadRequest)
+			return
+		}
+		if !seen[jid.User] {
+			seen[jid.User] = true
+			jids = append(jids, jid)
+		}
+	}
+	info, err := cachedGroupInfoFor(r.Context(), group)
+	if err != nil {
+		writeGroupError(w, group, "get group info", err)
+		return
+	}
+
+	results := []groupMemberResult{}
+	if action == whatsmeow.ParticipantChangeAdd {
+		phones := make([]string, len(jids))
+		for i, jid := range jids {
+			phones[i] = "+" + jid.User
+		}
+		onWhatsApp, err := client.IsOnWhatsApp(r.Context(), phones)
+		if err != nil {
+			waLogger.Errorf("Failed to look up participants on WhatsApp: %v", err)
+			http.Error(w, "Failed to look up participants on WhatsApp", http.StatusBadGateway)
+			return
+		}
+		jids = jids[:0]
+		for _, res := range onWhatsApp {
+			if res.IsIn {
+				jids = append(jids, res.JID)
+			} else {
+				results = append(results, groupMemberResult{Phone: "+" + onlyDigits(res.Query), Status: "not_on_whatsapp"})
+			}
+		}
+	}
+	if len(jids) > 0 {
+		participants, err := client.UpdateGroupParticipants(r.Context(), group, jids, action)
+		if err != nil {
+			writeGroupError(w, group, "update participants", err)
+			return
+		}
+		forgetGroupInfo(group)
+		if action == whatsmeow.ParticipantChangeAdd {
+			var inv *groupInvite
+			if req.InviteInstead == nil || *req.InviteInstead {
+				inv = &groupInvite{group: group, name: info.Name}
+			}
+			results = append(results, participantResults(r.Context(), inv, participants)...)
+		} else {
+			for _, p := range participants {
+				results = append(results, changedParticipantResult(p, action))
+			}
+		}
+	}
+	summary := map[string]int{}
+	for _, res := range results {
+		summary[res.Status]++
+	}
+	waLogger.Infof("Participants of group %s updated (%s): %v", group, req.Action, summary)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"group":        group.String(),
+		"action":       req.Action,
+		"participants": results,
+		"summary":      summary,
+	})
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// --- Group Participant Audit Trail ---
+
+type groupAuditEntry struct {
+	ID          int64     `json:"id"`
+	Group       string    `json:"group"`
+	Participant string    `json:"participant"`
+	Action      string    `json:"action"`
+	Actor       string    `json:"actor,omitempty"`
+	Reason      string    `json:"reason,omitempty"`
+	Timestamp   time.Time `json:"timestamp"`
+}
+
+// recordGroupParticipantChanges persists every membership change carried by a
+// group notification. Joins and leaves are told apart from adds and removals
+// by comparing the participant with the actor.
+func recordGroupParticipantChanges(evt *events.GroupInfo) {
+	if gatewayDB == nil {
+		return
+	}
+	ctx := context.Background()
+	var actor types.JID
+	if evt.Sender != nil {
+		actor = canonicalJID(ctx, *evt.Sender)
+	}
+	record := func(participants []types.JID, action, selfAction string) {
+		for _, p := range participants {
+			participant := canonicalJID(ctx, p)
+			act := action
+			if selfAction != "" && (actor.IsEmpty() || actor == participant) {
+				act = selfAction
+			}
+			actorStr := ""
+			if !actor.IsEmpty() {
+				actorStr = actor.String()
+			}
+			_, err := gatewayDB.Exec(
+				`INSERT INTO group_participant_events (group_jid, participant, action, actor, reason, timestamp) VALUES (?, ?, ?, ?, ?, ?)`,
+				evt.JID.String(), participant.String(), act, actorStr, evt.JoinReason, evt.Timestamp.Unix(),
+			)
+			if err != nil {
+				waLogger.Errorf("Failed to record group audit event for %s: %v", evt.JID, err)
+			}
+		}
+	}
+	record(evt.Join, "add", "join")
+	record(evt.Leave, "remove", "leave")
+	record(evt.Promote, "promote", "")
+	record(evt.Demote, "demote", "")
+}
+
+func getGroupAudit(w http.ResponseWriter, r *http.Request) {
+	group, ok := parseJID(r.PathValue("jid"))
+	if !ok || group.Server != types.GroupServer {
+		http.Error(w, "Invalid group JID", http.StatusBadRequest)
+		return
+	}
+	query := `SELECT id, group_jid, participant, action, actor, reason, timestamp FROM group_participant_events WHERE group_jid = ?`
+	args := []interface{}{group.String()}
+
+	q := r.URL.Query()
+	if p := q.Get("participant"); p != "" {
+		participant, ok := parseJID(p)
+		if !ok {
+			http.Error(w, "Invalid participant JID", http.StatusBadRequest)
+			return
+		}
+		query += ` AND participant = ?`
+		args = append(args, canonicalJID(r.Context(), participant).String())
+	}
+	if a := q.Get("action"); a != "" {
+		query += ` AND action = ?`
+		args = append(args, a)
+	}
+	for _, bound := range []struct{ param, op string }{{"since", ">="}, {"until", "<"}} {
+		v := q.Get(bound.param)
+		if v == "" {
+			continue
+		}
+		ts, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(w, "Invalid "+bound.param+" timestamp, expected RFC 3339", http.StatusBadRequest)
+			return
+		}
+		query += ` AND timestamp ` + bound.op + ` ?`
+		args = append(args, ts.Unix())
+	}
+	limit := 100
+	if l := q.Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 || n > 1000 {
+			http.Error(w, "Invalid limit, expected 1-1000", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	query += ` ORDER BY timestamp DESC, id DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := gatewayDB.QueryContext(r.Context(), query, args...)
+	if err != nil {
+		waLogger.Errorf("Failed to query group audit for %s: %v", group, err)
+		http.Error(w, "Failed to load audit trail", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	entries := []groupAuditEntry{}
+	for rows.Next() {
+		var e groupAuditEntry
+		var ts int64
+		if err := rows.Scan(&e.ID, &e.Group, &e.Participant, &e.Action, &e.Actor, &e.Reason, &ts); err != nil {
+			waLogger.Errorf("Failed to scan group audit row: %v", err)
+			http.Error(w, "Failed to load audit trail", http.StatusInternalServerError)
+			return
+		}
+		e.Timestamp = time.Unix(ts, 0).UTC()
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		waLogger.Errorf("Failed to read group audit rows: %v", err)
+		http.Error(w, "Failed to load audit trail", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"group": group.String(), "events": entries})
+}
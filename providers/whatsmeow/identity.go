@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// When a contact's encryption keys change (they reinstalled WhatsApp or
+// moved to a new phone, or someone else registered the number), WhatsApp
+// shows "security code changed" in the chat. The gateway reports it as the
+// contact.identity_changed webhook and, with IDENTITY_CHANGE_NOTE, leaves
+// an internal note in the chat so agents see it in the conversation.
+
+var (
+	identityChangeNote     = envBool("IDENTITY_CHANGE_NOTE")
+	identityChangeNoteText = envString("IDENTITY_CHANGE_NOTE_TEXT",
+		"Security code changed: this contact's encryption keys changed. Verify who you're talking to before sharing anything sensitive.")
+)
+
+// identityChangeNoteAuthor marks the notes the gateway leaves itself.
+const identityChangeNoteAuthor = "gateway"
+
+type identityChange struct {
+	JID       string    `json:"jid"`
+	LID       string    `json:"lid,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+	// Noticed while decrypting a message rather than announced by the server.
+	Implicit bool  `json:"implicit"`
+	NoteID   int64 `json:"note_id,omitempty"`
+}
+
+func handleIdentityChange(evt *events.IdentityChange) {
+	ctx := context.Background()
+	jid := toPhoneJID(ctx, evt.JID.ToNonAD())
+	if client != nil && client.Store.ID != nil && jid.User == client.Store.ID.User {
+		return // one of our own devices
+	}
+	change := identityChange{JID: jid.String(), Timestamp: evt.Timestamp, Implicit: evt.Implicit}
+	if evt.JID.Server != jid.Server {
+		change.LID = evt.JID.ToNonAD().String()
+	}
+	waLogger.Infof("Identity of %s changed (implicit: %t)", jid, evt.Implicit)
+	if identityChangeNote && gatewayDB != nil {
+		change.NoteID = addIdentityChangeNote(ctx, canonicalJID(ctx, jid).String(), evt.Timestamp)
+	}
+	emitWebhook("contact.identity_changed", change)
+}
+
+// addIdentityChangeNote leaves the identity change note in a chat and
+// returns its ID, or 0 if it couldn't.
+func addIdentityChangeNote(ctx context.Context, chat string, at time.Time) int64 {
+	if at.IsZero() {
+		at = time.Now()
+	}
+	res, err := gatewayDB.ExecContext(ctx,
+		`INSERT INTO notes (chat_jid, message_id, author, text, created_at, updated_at) VALUES (?, '', ?, ?, ?, ?)`,
+		chat, identityChangeNoteAuthor, identityChangeNoteText, at.Unix(), at.Unix())
+	if err != nil {
+		waLogger.Errorf("Failed to note identity change in %s: %v", chat, err)
+		return 0
+	}
+	id, _ := res.LastInsertId()
+	return id
+}
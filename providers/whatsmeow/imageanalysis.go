@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+)
+
+// Inbound images (and images sent as documents) can be run through a
+// pluggable OCR/labeling provider (IMAGE_ANALYSIS_PROVIDER). The extracted
+// text and labels are reported as image_analysis with the message (see
+// enrichment.go).
+
+var imageAnalysisMaxBytes = envInt("IMAGE_ANALYSIS_MAX_BYTES", 10<<20)
+
+type imageLabel struct {
+	Label string  `json:"label"`
+	Score float64 `json:"score"`
+}
+
+type imageAnalysis struct {
+	Text   string       `json:"text,omitempty"`
+	Labels []imageLabel `json:"labels,omitempty"`
+}
+
+type imageAnalyzer interface {
+	Analyze(ctx context.Context, image []byte) (*imageAnalysis, error)
+}
+
+// activeImageAnalyzer is nil when no provider is configured.
+var activeImageAnalyzer imageAnalyzer
+
+func newImageAnalyzer(provider string) imageAnalyzer {
+	switch strings.ToLower(provider) {
+	case "":
+		return nil
+	case "google":
+		return &googleVisionAnalyzer{
+			url:       strings.TrimSuffix(envString("IMAGE_ANALYSIS_API_URL", "https://vision.googleapis.com/v1"), "/"),
+			key:       envString("IMAGE_ANALYSIS_API_KEY", ""),
+			maxLabels: envInt("IMAGE_ANALYSIS_MAX_LABELS", 10),
+		}
+	default:
+		waLogger.Errorf("Unknown IMAGE_ANALYSIS_PROVIDER %q, image analysis disabled", provider)
+		return nil
+	}
+}
+
+// googleVisionAnalyzer runs text and label detection in one Cloud Vision
+// request.
+type googleVisionAnalyzer struct {
+	url       string
+	key       string
+	maxLabels int
+}
+
+func (a *googleVisionAnalyzer) Analyze(ctx context.Context, image []byte) (*imageAnalysis, error) {
+	req := map[string]interface{}{
+		"requests": []interface{}{map[string]interface{}{
+			"image": map[string]string{"content": base64.StdEncoding.EncodeToString(image)},
+			"features": []interface{}{
+				map[string]interface{}{"type": "DOCUMENT_TEXT_DETECTION"},
+				map[string]interface{}{"type": "LABEL_DETECTION", "maxResults": a.maxLabels},
+			},
+		}},
+	}
+	var resp struct {
+		Responses []struct {
+			FullTextAnnotation struct {
+				Text string `json:"text"`
+			} `json:"fullTextAnnotation"`
+			LabelAnnotations []struct {
+				Description string  `json:"description"`
+				Score       float64 `json:"score"`
+			} `json:"labelAnnotations"`
+			Error *struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		} `json:"responses"`
+	}
+	if err := providerJSON(ctx, a.url+"/images:annotate?key="+url.QueryEscape(a.key), nil, req, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Responses) == 0 {
+		return nil, fmt.Errorf("provider returned no result")
+	}
+	res := resp.Responses[0]
+	if res.Error != nil {
+		return nil, fmt.Errorf("provider error: %s", res.Error.Message)
+	}
+	out := &imageAnalysis{Text: strings.TrimSpace(res.FullTextAnnotation.Text)}
+	for _, l := range res.LabelAnnotations {
+		out.Labels = append(out.Labels, imageLabel{Label: l.Description, Score: l.Score})
+	}
+	return out, nil
+}
+
+// imageToAnalyze returns the message's image (or image document) if it
+// should be analyzed.
+func imageToAnalyze(msg *waE2E.Message) whatsmeow.DownloadableMessage {
+	if activeImageAnalyzer == nil || mediaSkipped(msg) {
+		return nil
+	}
+	var media whatsmeow.DownloadableMessage
+	var size uint64
+	if img := msg.GetImageMessage(); img != nil {
+		media, size = img, img.GetFileLength()
+	} else if doc := msg.GetDocumentMessage(); doc != nil && strings.HasPrefix(doc.GetMimetype(), "image/") {
+		media, size = doc, doc.GetFileLength()
+	} else {
+		return nil
+	}
+	if imageAnalysisMaxBytes > 0 && size > uint64(imageAnalysisMaxBytes) {
+		return nil
+	}
+	return media
+}
+
+// analyzeImage downloads and analyzes an inbound image. It returns nil for
+// other messages or when analysis is off or fails.
+func analyzeImage(ctx context.Context, msg *waE2E.Message) *imageAnalysis {
+	media := imageToAnalyze(msg)
+	if media == nil {
+		return nil
+	}
+	data, err := client.Download(ctx, media)
+	if err != nil {
+		waLogger.Errorf("Failed to download image for analysis: %v", err)
+		return nil
+	}
+	result, err := activeImageAnalyzer.Analyze(ctx, data)
+	if err != nil {
+		waLogger.Errorf("Failed to analyze image: %v", err)
+		return nil
+	}
+	return result
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// The shared inbox lets support agents work conversations: each chat has an
+// optional assignee and an open/pending/resolved status.
+
+type inboxAgent struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func validInboxStatus(status string) bool {
+	switch status {
+	case "open", "pending", "resolved":
+		return true
+	}
+	return false
+}
+
+func listInboxAgents(w http.ResponseWriter, r *http.Request) {
+	rows, err := gatewayDB.QueryContext(r.Context(), `SELECT id, name, email, created_at FROM inbox_agents ORDER BY name, id`)
+	if err != nil {
+		waLogger.Errorf("Failed to list inbox agents: %v", err)
+		http.Error(w, "Failed to list agents", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	agents := []inboxAgent{}
+	for rows.Next() {
+		var a inboxAgent
+		var created int64
+		if err := rows.Scan(&a.ID, &a.Name, &a.Email, &created); err != nil {
+			waLogger.Errorf("Failed to scan inbox agent: %v", err)
+			http.Error(w, "Failed to list agents", http.StatusInternalServerError)
+			return
+		}
+		a.CreatedAt = time.Unix(created, 0).UTC()
+		agents = append(agents, a)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"agents": agents})
+}
+
+func createInboxAgent(w http.ResponseWriter, r *http.Request) {
+	var agent inboxAgent
+	if err := json.NewDecoder(r.Body).Decode(&agent); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	agent.ID = strings.TrimSpace(agent.ID)
+	if agent.ID == "" || agent.Name == "" {
+		http.Error(w, "id and name are required", http.StatusBadRequest)
+		return
+	}
+	agent.CreatedAt = time.Now().UTC()
+	_, err := gatewayDB.ExecContext(r.Context(),
+		`INSERT INTO inbox_agents (id, name, email, created_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET name = excluded.name, email = excluded.email`,
+		agent.ID, agent.Name, agent.Email, agent.CreatedAt.Unix())
+	if err != nil {
+		waLogger.Errorf("Failed to save inbox agent %s: %v", agent.ID, err)
+		http.Error(w, "Failed to save agent", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, agent)
+}
+
+// deleteInboxAgent removes an agent and returns their conversations to the
+// unassigned pool.
+func deleteInboxAgent(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	res, err := gatewayDB.ExecContext(r.Context(), `DELETE FROM inbox_agents WHERE id = ?`, id)
+	if err != nil {
+		waLogger.Errorf("Failed to delete inbox agent %s: %v", id, err)
+		http.Error(w, "Failed to delete agent", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		http.Error(w, "Agent not found", http.StatusNotFound)
+		return
+	}
+	if _, err := gatewayDB.ExecContext(r.Context(), `UPDATE chats SET assignee = '' WHERE assignee = ?`, id); err != nil {
+		waLogger.Errorf("Failed to unassign chats of agent %s: %v", id, err)
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+type chatAssignmentRequest struct {
+	AgentID string `json:"agent_id"` // empty to unassign
+}
+
+func assignChat(w http.ResponseWriter, r *http.Request) {
+	jid, ok := parseJID(r.PathValue("jid"))
+	if !ok {
+		http.Error(w, "Invalid JID", http.StatusBadRequest)
+		return
+	}
+	var reqBody chatAssignmentRequest
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if reqBody.AgentID != "" {
+		var exists int
+		err := gatewayDB.QueryRowContext(r.Context(), `SELECT 1 FROM inbox_agents WHERE id = ?`, reqBody.AgentID).Scan(&exists)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Unknown agent", http.StatusBadRequest)
+			return
+		} else if err != nil {
+			waLogger.Errorf("Failed to look up agent %s: %v", reqBody.AgentID, err)
+			http.Error(w, "Failed to assign chat", http.StatusInternalServerError)
+			return
+		}
+	}
+	chat := canonicalJID(r.Context(), jid)
+	prev, err := getChat(r.Context(), chat)
+	if err != nil && err != sql.ErrNoRows {
+		waLogger.Errorf("Failed to load chat %s: %v", chat, err)
+		http.Error(w, "Failed to assign chat", http.StatusInternalServerError)
+		return
+	}
+	_, err = gatewayDB.ExecContext(r.Context(), `
+		INSERT INTO chats (jid, status, assignee, updated_at) VALUES (?, 'open', ?, ?)
+		ON CONFLICT (jid) DO UPDATE SET assignee = excluded.assignee, updated_at = excluded.updated_at`,
+		chat.String(), reqBody.AgentID, time.Now().Unix())
+	if err != nil {
+		waLogger.Errorf("Failed to assign chat %s: %v", chat, err)
+		http.Error(w, "Failed to assign chat", http.StatusInternalServerError)
+		return
+	}
+	if prev.Assignee != reqBody.AgentID {
+		assignConversation(r.Context(), chat, reqBody.AgentID)
+		emitWebhook("conversation.assigned", map[string]string{
+			"jid":           chat.String(),
+			"assignee":      reqBody.AgentID,
+			"prev_assignee": prev.Assignee,
+		})
+	}
+	updated, _ := getChat(r.Context(), chat)
+	writeJSON(w, http.StatusOK, updated)
+}
+
+type chatStatusRequest struct {
+	Status string `json:"status"`
+}
+
+func setChatStatus(w http.ResponseWriter, r *http.Request) {
+	jid, ok := parseJID(r.PathValue("jid"))
+	if !ok {
+		http.Error(w, "Invalid JID", http.StatusBadRequest)
+		return
+	}
+	var reqBody chatStatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil || !validInboxStatus(reqBody.Status) {
+		http.Error(w, "Invalid status, expected open, pending or resolved", http.StatusBadRequest)
+		return
+	}
+	chat := canonicalJID(r.Context(), jid)
+	prev, err := getChat(r.Context(), chat)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Chat not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		waLogger.Errorf("Failed to load chat %s: %v", chat, err)
+		http.Error(w, "Failed to update status", http.StatusInternalServerError)
+		return
+	}
+	_, err = gatewayDB.ExecContext(r.Context(), `UPDATE chats SET status = ?, updated_at = ? WHERE jid = ?`,
+		reqBody.Status, time.Now().Unix(), chat.String())
+	if err != nil {
+		waLogger.Errorf("Failed to update status of chat %s: %v", chat, err)
+		http.Error(w, "Failed to update status", http.StatusInternalServerError)
+		return
+	}
+	if prev.Status != reqBody.Status {
+		if reqBody.Status == "resolved" {
+			resolveConversation(r.Context(), chat)
+		}
+		emitWebhook("conversation.status_changed", map[string]string{
+			"jid":    chat.String(),
+			"from":   prev.Status,
+			"status": reqBody.Status,
+			"reason": "api",
+		})
+	}
+	updated, _ := getChat(r.Context(), chat)
+	writeJSON(w, http.StatusOK, updated)
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"google.golang.org/protobuf/proto"
+)
+
+// /send/buttons sends an interactive message with quick-reply and URL
+// buttons, the native flow buttons the Cloud API calls reply and cta_url.
+// When a recipient taps a reply button the message webhook carries
+// button_reply with the button's id, whichever of the button message kinds
+// (native flow, legacy buttons or template buttons) the reply came from.
+//
+// /send/list sends a list (menu) message: a button that opens sections of
+// rows to pick one from. The pick arrives as a message with list_reply.
+
+const (
+	maxReplyButtons = 3
+	maxButtonText   = 20
+	maxListRows     = 10
+	maxRowTitle     = 24
+	maxRowDesc      = 72
+)
+
+type messageButton struct {
+	Type string `json:"type"` // reply or url
+	ID   string `json:"id,omitempty"`
+	Text string `json:"text"`
+	URL  string `json:"url,omitempty"`
+}
+
+type sendButtonsRequest struct {
+	To             string          `json:"to"`
+	Header         string          `json:"header,omitempty"`
+	Text           string          `json:"text"`
+	Footer         string          `json:"footer,omitempty"`
+	Buttons        []messageButton `json:"buttons"`
+	AllowDuplicate bool            `json:"allow_duplicate,omitempty"`
+}
+
+func (req *sendButtonsRequest) validate() error {
+	if strings.TrimSpace(req.Text) == "" {
+		return fmt.Errorf("text is required")
+	}
+	if len(req.Buttons) == 0 {
+		return fmt.Errorf("at least one button is required")
+	}
+	ids := map[string]bool{}
+	replies := 0
+	for i := range req.Buttons {
+		b := &req.Buttons[i]
+		b.Text = strings.TrimSpace(b.Text)
+		if b.Text == "" {
+			return fmt.Errorf("button %d has no text", i+1)
+		}
+		if len([]rune(b.Text)) > maxButtonText {
+			return fmt.Errorf("button %d text is longer than %d characters", i+1, maxButtonText)
+		}
+		switch b.Type {
+		case "reply":
+			if b.ID == "" {
+				return fmt.Errorf("reply button %d needs an id", i+1)
+			}
+			if ids[b.ID] {
+				return fmt.Errorf("duplicate button id %q", b.ID)
+			}
+			ids[b.ID] = true
+			replies++
+		case "url":
+			u, err := url.Parse(b.URL)
+			if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+				return fmt.Errorf("url button %d needs an http(s) url", i+1)
+			}
+		default:
+			return fmt.Errorf("button %d has unknown type %q, expected reply or url", i+1, b.Type)
+		}
+	}
+	if replies > maxReplyButtons {
+		return fmt.Errorf("a message can have at most %d reply buttons", maxReplyButtons)
+	}
+	return nil
+}
+
+// buildButtonsMessage builds the native flow message for the buttons.
+func buildButtonsMessage(req sendButtonsRequest) *waE2E.Message {
+	buttons := make([]*waE2E.InteractiveMessage_NativeFlowMessage_NativeFlowButton, 0, len(req.Buttons))
+	for _, b := range req.Buttons {
+		var name string
+		var params interface{}
+		switch b.Type {
+		case "reply":
+			name, params = "quick_reply", map[string]string{"display_text": b.Text, "id": b.ID}
+		case "url":
+			name, params = "cta_url", map[string]string{"display_text": b.Text, "url": b.URL, "merchant_url": b.URL}
+		}
+		raw, _ := json.Marshal(params)
+		buttons = append(buttons, &waE2E.InteractiveMessage_NativeFlowMessage_NativeFlowButton{
+			Name:             proto.String(name),
+			ButtonParamsJSON: proto.String(string(raw)),
+		})
+	}
+	interactive := &waE2E.InteractiveMessage{
+		Body: &waE2E.InteractiveMessage_Body{Text: proto.String(req.Text)},
+		InteractiveMessage: &waE2E.InteractiveMessage_NativeFlowMessage_{
+			NativeFlowMessage: &waE2E.InteractiveMessage_NativeFlowMessage{
+				Buttons:        buttons,
+				MessageVersion: proto.Int32(1),
+			},
+		},
+	}
+	if req.Header != "" {
+		interactive.Header = &waE2E.InteractiveMessage_Header{Title: proto.String(req.Header), HasMediaAttachment: proto.Bool(false)}
+	}
+	if req.Footer != "" {
+		interactive.Footer = &waE2E.InteractiveMessage_Footer{Text: proto.String(req.Footer)}
+	}
+	return &waE2E.Message{InteractiveMessage: interactive}
+}
+
+func sendButtons(w http.ResponseWriter, r *http.Request) {
+	if sessionArchived() {
+		http.Error(w, "Session is archived", http.StatusConflict)
+		return
+	}
+	if !requireClient(w, r, true) {
+		return
+	}
+	var req sendButtonsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := req.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	recipient, err := parseRecipient(req.To)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	recipient = toPhoneJID(r.Context(), recipient)
+
+	res, err := sendOrQueue(r.Context(), recipient, buildButtonsMessage(req), sendOptions{AllowDuplicate: req.AllowDuplicate})
+	if err != nil {
+		writeSendError(w, recipient, err)
+		return
+	}
+	writeSendResult(w, recipient, res)
+}
+
+type buttonReply struct {
+	ID      string `json:"id"`
+	Text    string `json:"text,omitempty"`
+	ReplyTo string `json:"reply_to,omitempty"` // id of the message with the buttons
+}
+
+// parseButtonReply returns the button a message is a reply to, if any.
+func parseButtonReply(msg *waE2E.Message) *buttonReply {
+	switch {
+	case msg.GetInteractiveResponseMessage() != nil:
+		resp := msg.GetInteractiveResponseMessage()
+		flow := resp.GetNativeFlowResponseMessage()
+		if flow == nil {
+			return nil
+		}
+		var params struct {
+			ID string `json:"id"`
+		}
+		if json.Unmarshal([]byte(flow.GetParamsJSON()), &params) != nil || params.ID == "" {
+			return nil
+		}
+		return &buttonReply{ID: params.ID, Text: resp.GetBody().GetText(), ReplyTo: resp.GetContextInfo().GetStanzaID()}
+	case msg.GetButtonsResponseMessage() != nil:
+		resp := msg.GetButtonsResponseMessage()
+		return &buttonReply{ID: resp.GetSelectedButtonID(), Text: resp.GetSelectedDisplayText(), ReplyTo: resp.GetContextInfo().GetStanzaID()}
+	case msg.GetTemplateButtonReplyMessage() != nil:
+		resp := msg.GetTemplateButtonReplyMessage()
+		return &buttonReply{ID: resp.GetSelectedID(), Text: resp.GetSelectedDisplayText(), ReplyTo: resp.GetContextInfo().GetStanzaID()}
+	}
+	return nil
+}
+
+type listRow struct {
+	ID          string `json:"id"`
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+}
+
+type listSection struct {
+	Title string    `json:"title,omitempty"`
+	Rows  []listRow `json:"rows"`
+}
+
+type sendListRequest struct {
+	To             string        `json:"to"`
+	Title          string        `json:"title,omitempty"`
+	Text           string        `json:"text"`
+	Footer         string        `json:"footer,omitempty"`
+	Button         string        `json:"button"` // opens the list
+	Sections       []listSection `json:"sections"`
+	AllowDuplicate bool          `json:"allow_duplicate,omitempty"`
+}
+
+func (req *sendListRequest) validate() error {
+	if strings.TrimSpace(req.Text) == "" {
+		return fmt.Errorf("text is required")
+	}
+	req.Button = strings.TrimSpace(req.Button)
+	if req.Button == "" || len([]rune(req.Button)) > maxButtonText {
+		return fmt.Errorf("button is required, up to %d characters", maxButtonText)
+	}
+	if len(req.Sections) == 0 {
+		return fmt.Errorf("at least one section is required")
+	}
+	ids := map[string]bool{}
+	for i := range req.Sections {
+		section := &req.Sections[i]
+		if len(req.Sections) > 1 && strings.TrimSpace(section.Title) == "" {
+			return fmt.Errorf("section %d needs a title when there are several", i+1)
+		}
+		if len([]rune(section.Title)) > maxRowTitle {
+			return fmt.Errorf("section %d title is longer than %d characters", i+1, maxRowTitle)
+		}
+		if len(section.Rows) == 0 {
+			return fmt.Errorf("section %d has no rows", i+1)
+		}
+		for j, row := range section.Rows {
+			switch {
+			case row.ID == "":
+				return fmt.Errorf("row %d of section %d needs an id", j+1, i+1)
+			case ids[row.ID]:
+				return fmt.Errorf("duplicate row id %q", row.ID)
+			case strings.TrimSpace(row.Title) == "" || len([]rune(row.Title)) > maxRowTitle:
+				return fmt.Errorf("row %q needs a title of up to %d characters", row.ID, maxRowTitle)
+			case len([]rune(row.Description)) > maxRowDesc:
+				return fmt.Errorf("row %q description is longer than %d characters", row.ID, maxRowDesc)
+			}
+			ids[row.ID] = true
+		}
+	}
+	if len(ids) > maxListRows {
+		return fmt.Errorf("a list can have at most %d rows", maxListRows)
+	}
+	return nil
+}
+
+func buildListMessage(req sendListRequest) *waE2E.Message {
+	sections := make([]*waE2E.ListMessage_Section, 0, len(req.Sections))
+	for _, s := range req.Sections {
+		section := &waE2E.ListMessage_Section{Title: proto.String(s.Title)}
+		for _, row := range s.Rows {
+			r := &waE2E.ListMessage_Row{RowID: proto.String(row.ID), Title: proto.String(row.Title)}
+			if row.Description != "" {
+				r.Description = proto.String(row.Description)
+			}
+			section.Rows = append(section.Rows, r)
+		}
+		sections = append(sections, section)
+	}
+	list := &waE2E.ListMessage{
+		Title:       proto.String(req.Title),
+		Description: proto.String(req.Text),
+		ButtonText:  proto.String(req.Button),
+		ListType:    waE2E.ListMessage_SINGLE_SELECT.Enum(),
+		Sections:    sections,
+	}
+	if req.Footer != "" {
+		list.FooterText = proto.String(req.Footer)
+	}
+	return &waE2E.Message{ListMessage: list}
+}
+
+func sendList(w http.ResponseWriter, r *http.Request) {
+	if sessionArchived() {
+		http.Error(w, "Session is archived", http.StatusConflict)
+		return
+	}
+	if !requireClient(w, r, true) {
+		return
+	}
+	var req sendListRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := req.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	recipient, err := parseRecipient(req.To)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	recipient = toPhoneJID(r.Context(), recipient)
+
+	res, err := sendOrQueue(r.Context(), recipient, buildListMessage(req), sendOptions{AllowDuplicate: req.AllowDuplicate})
+	if err != nil {
+		writeSendError(w, recipient, err)
+		return
+	}
+	writeSendResult(w, recipient, res)
+}
+
+type listReply struct {
+	ID          string `json:"id"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	ReplyTo     string `json:"reply_to,omitempty"` // id of the list message
+}
+
+// parseListReply returns the row a message picked from a list, if any.
+func parseListReply(msg *waE2E.Message) *listReply {
+	resp := msg.GetListResponseMessage()
+	if resp == nil || resp.GetSingleSelectReply().GetSelectedRowID() == "" {
+		return nil
+	}
+	return &listReply{
+		ID:          resp.GetSingleSelectReply().GetSelectedRowID(),
+		Title:       resp.GetTitle(),
+		Description: resp.GetDescription(),
+		ReplyTo:     resp.GetContextInfo().GetStanzaID(),
+	}
+}
@@ -0,0 +1,407 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// Join request automation decides pending requests to join groups that
+// require admin approval. Rules are per group and are checked in order:
+// suppressed numbers and reject prefixes are rejected, approve prefixes and
+// known contacts are approved, and everything else gets the default action
+// (leaving it pending unless configured otherwise). Each decision is logged
+// and reported as a group.join_request webhook.
+//
+// WhatsApp does not reliably notify about new requests, so groups with
+// rules are polled every JOIN_REQUEST_POLL_INTERVAL.
+
+var joinRequestPollInterval = envDuration("JOIN_REQUEST_POLL_INTERVAL", time.Minute)
+
+type joinRequestRules struct {
+	Enabled        bool     `json:"enabled"`
+	ApprovePrefix  []string `json:"approve_prefixes,omitempty"` // international prefixes such as "+49"
+	RejectPrefix   []string `json:"reject_prefixes,omitempty"`
+	ApproveKnown   bool     `json:"approve_known_contacts"` // saved in the address book or imported
+	RejectSuppress bool     `json:"reject_suppressed"`
+	DefaultAction  string   `json:"default_action"` // approve, reject or none
+}
+
+func (j *joinRequestRules) compile() error {
+	switch j.DefaultAction {
+	case "":
+		j.DefaultAction = "none"
+	case "approve", "reject", "none":
+	default:
+		return fmt.Errorf("default_action must be approve, reject or none")
+	}
+	for _, prefixes := range []*[]string{&j.ApprovePrefix, &j.RejectPrefix} {
+		for i, p := range *prefixes {
+			digits := strings.TrimPrefix(phoneSeparators.Replace(strings.TrimSpace(p)), "+")
+			if digits == "" || strings.Trim(digits, "0123456789") != "" {
+				return fmt.Errorf("invalid prefix %q, expected digits", p)
+			}
+			(*prefixes)[i] = digits
+		}
+	}
+	return nil
+}
+
+func loadJoinRequestRules(ctx context.Context, group types.JID) (*joinRequestRules, error) {
+	var raw string
+	err := gatewayDB.QueryRowContext(ctx, `SELECT rules FROM join_request_rules WHERE group_jid = ?`, group.String()).Scan(&raw)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	rules := &joinRequestRules{}
+	if err := json.Unmarshal([]byte(raw), rules); err != nil {
+		return nil, err
+	}
+	return rules, rules.compile()
+}
+
+func isSuppressed(ctx context.Context, phone string) (bool, error) {
+	var n int
+	err := gatewayDB.QueryRowContext(ctx, `SELECT COUNT(*) FROM suppressions WHERE phone = ?`, phone).Scan(&n)
+	return n > 0, err
+}
+
+// isKnownContact reports whether a number is saved in the phone's address
+// book or was imported into the gateway's contacts.
+func isKnownContact(ctx context.Context, jid types.JID) bool {
+	if resolveContact(ctx, jid, "", nil).IsSavedContact {
+		return true
+	}
+	_, err := getGatewayContact(ctx, canonicalJID(ctx, jid))
+	return err == nil
+}
+
+func hasPrefix(phone string, prefixes []string) (string, bool) {
+	for _, p := range prefixes {
+		if strings.HasPrefix(phone, p) {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+// decide returns approve, reject or none, with the reason.
+func (j *joinRequestRules) decide(ctx context.Context, requester types.JID) (string, string, error) {
+	pn := toPhoneJID(ctx, requester)
+	phone := ""
+	if pn.Server == types.DefaultUserServer {
+		phone = pn.User
+	}
+	if j.RejectSuppress && phone != "" {
+		suppressed, err := isSuppressed(ctx, phone)
+		if err != nil {
+			return "", "", err
+		}
+		if suppressed {
+			return "reject", "number is on the suppression list", nil
+		}
+	}
+	if p, ok := hasPrefix(phone, j.RejectPrefix); ok {
+		return "reject", "number matches reject prefix +" + p, nil
+	}
+	if p, ok := hasPrefix(phone, j.ApprovePrefix); ok {
+		return "approve", "number matches approve prefix +" + p, nil
+	}
+	if j.ApproveKnown && isKnownContact(ctx, requester) {
+		return "approve", "known contact", nil
+	}
+	return j.DefaultAction, "default action", nil
+}
+
+// processJoinRequests decides the pending join requests of one group.
+func processJoinRequests(ctx context.Context, group types.JID, rules *joinRequestRules) {
+	requests, err := client.GetGroupRequestParticipants(ctx, group)
+	if err != nil {
+		waLogger.Errorf("Failed to get join requests of %s: %v", group, err)
+		return
+	}
+	for _, req := range requests {
+		decision, reason, err := rules.decide(ctx, req.JID)
+		if err != nil {
+			waLogger.Errorf("Failed to evaluate join request of %s to %s: %v", req.JID, group, err)
+			continue
+		}
+		if decision == "none" {
+			continue
+		}
+		action := whatsmeow.ParticipantChangeApprove
+		if decision == "reject" {
+			action = whatsmeow.ParticipantChangeReject
+		}
+		if _, err := client.UpdateGroupRequestParticipants(ctx, group, []types.JID{req.JID}, action); err != nil {
+			waLogger.Errorf("Failed to %s join request of %s to %s: %v", decision, req.JID, group, err)
+			continue
+		}
+		participant := canonicalJID(ctx, req.JID)
+		now := time.Now()
+		_, err = gatewayDB.ExecContext(ctx, `
+			INSERT INTO join_request_decisions (group_jid, participant, decision, reason, requested_at, decided_at)
+			VALUES (?, ?, ?, ?, ?, ?)`,
+			group.String(), participant.String(), decision, reason, req.RequestedAt.Unix(), now.Unix())
+		if err != nil {
+			waLogger.Errorf("Failed to log join request decision for %s: %v", participant, err)
+		}
+		waLogger.Infof("Join request of %s to %s: %s (%s)", participant, group, decision, reason)
+		emitWebhook("group.join_request", map[string]interface{}{
+			"group":        group.String(),
+			"participant":  participant.String(),
+			"decision":     decision,
+			"reason":       reason,
+			"requested_at": req.RequestedAt,
+		})
+	}
+}
+
+func runJoinRequestPoller() {
+	if joinRequestPollInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(joinRequestPollInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		pollJoinRequests(context.Background())
+	}
+}
+
+func pollJoinRequests(ctx context.Context) {
+	if client == nil || !client.IsConnected() || sessionArchived() {
+		return
+	}
+	rows, err := gatewayDB.QueryContext(ctx, `SELECT group_jid, rules FROM join_request_rules`)
+	if err != nil {
+		waLogger.Errorf("Failed to load join request rules: %v", err)
+		return
+	}
+	type groupRules struct {
+		group types.JID
+		rules *joinRequestRules
+	}
+	var pending []groupRules
+	for rows.Next() {
+		var jid, raw string
+		if err := rows.Scan(&jid, &raw); err != nil {
+			waLogger.Errorf("Failed to scan join request rules: %v", err)
+			continue
+		}
+		group, ok := parseJID(jid)
+		rules := &joinRequestRules{}
+		if !ok || json.Unmarshal([]byte(raw), rules) != nil || rules.compile() != nil {
+			waLogger.Errorf("Ignoring invalid join request rules of %s", jid)
+			continue
+		}
+		if rules.Enabled {
+			pending = append(pending, groupRules{group, rules})
+		}
+	}
+	rows.Close()
+	for _, g := range pending {
+		processJoinRequests(ctx, g.group, g.rules)
+	}
+}
+
+// --- HTTP API ---
+
+func getJoinRequestRules(w http.ResponseWriter, r *http.Request) {
+	group, ok := parseGroupPath(w, r)
+	if !ok {
+		return
+	}
+	rules, err := loadJoinRequestRules(r.Context(), group)
+	if err != nil {
+		waLogger.Errorf("Failed to load join request rules of %s: %v", group, err)
+		http.Error(w, "Failed to load join request rules", http.StatusInternalServerError)
+		return
+	}
+	if rules == nil {
+		rules = &joinRequestRules{DefaultAction: "none"}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"group": group.String(), "rules": rules})
+}
+
+func putJoinRequestRules(w http.ResponseWriter, r *http.Request) {
+	group, ok := parseGroupPath(w, r)
+	if !ok {
+		return
+	}
+	rules := &joinRequestRules{}
+	if err := json.NewDecoder(r.Body).Decode(rules); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := rules.compile(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	raw, _ := json.Marshal(rules)
+	_, err := gatewayDB.ExecContext(r.Context(), `
+		INSERT INTO join_request_rules (group_jid, rules, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT (group_jid) DO UPDATE SET rules = excluded.rules, updated_at = excluded.updated_at`,
+		group.String(), string(raw), time.Now().Unix())
+	if err != nil {
+		waLogger.Errorf("Failed to save join request rules of %s: %v", group, err)
+		http.Error(w, "Failed to save join request rules", http.StatusInternalServerError)
+		return
+	}
+	if rules.Enabled && client != nil && client.IsConnected() {
+		go processJoinRequests(context.Background(), group, rules)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"group": group.String(), "rules": rules})
+}
+
+func deleteJoinRequestRules(w http.ResponseWriter, r *http.Request) {
+	group, ok := parseGroupPath(w, r)
+	if !ok {
+		return
+	}
+	if _, err := gatewayDB.ExecContext(r.Context(), `DELETE FROM join_request_rules WHERE group_jid = ?`, group.String()); err != nil {
+		waLogger.Errorf("Failed to delete join request rules of %s: %v", group, err)
+		http.Error(w, "Failed to delete join request rules", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func listJoinRequestDecisions(w http.ResponseWriter, r *http.Request) {
+	group, ok := parseGroupPath(w, r)
+	if !ok {
+		return
+	}
+	query := `SELECT id, participant, decision, reason, requested_at, decided_at FROM join_request_decisions WHERE group_jid = ?`
+	args := []interface{}{group.String()}
+	if d := r.URL.Query().Get("decision"); d != "" {
+		query += ` AND decision = ?`
+		args = append(args, d)
+	}
+	limit := 100
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 || n > 1000 {
+			http.Error(w, "Invalid limit, expected 1-1000", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	query += ` ORDER BY decided_at DESC, id DESC LIMIT ?`
+	args = append(args, limit)
+	rows, err := gatewayDB.QueryContext(r.Context(), query, args...)
+	if err != nil {
+		waLogger.Errorf("Failed to list join request decisions of %s: %v", group, err)
+		http.Error(w, "Failed to list decisions", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	type decisionRecord struct {
+		ID          int64     `json:"id"`
+		Participant string    `json:"participant"`
+		Decision    string    `json:"decision"`
+		Reason      string    `json:"reason"`
+		RequestedAt time.Time `json:"requested_at"`
+		DecidedAt   time.Time `json:"decided_at"`
+	}
+	decisions := []decisionRecord{}
+	for rows.Next() {
+		var d decisionRecord
+		var requested, decided int64
+		if err := rows.Scan(&d.ID, &d.Participant, &d.Decision, &d.Reason, &requested, &decided); err != nil {
+			waLogger.Errorf("Failed to scan join request decision: %v", err)
+			http.Error(w, "Failed to list decisions", http.StatusInternalServerError)
+			return
+		}
+		d.RequestedAt = time.Unix(requested, 0).UTC()
+		d.DecidedAt = time.Unix(decided, 0).UTC()
+		decisions = append(decisions, d)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"group": group.String(), "decisions": decisions})
+}
+
+// --- Suppression list ---
+
+type suppression struct {
+	Phone     string    `json:"phone"`
+	Reason    string    `json:"reason,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func listSuppressions(w http.ResponseWriter, r *http.Request) {
+	rows, err := gatewayDB.QueryContext(r.Context(), `SELECT phone, reason, created_at FROM suppressions ORDER BY phone`)
+	if err != nil {
+		waLogger.Errorf("Failed to list suppressions: %v", err)
+		http.Error(w, "Failed to list suppressions", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	list := []suppression{}
+	for rows.Next() {
+		var s suppression
+		var created int64
+		if err := rows.Scan(&s.Phone, &s.Reason, &created); err != nil {
+			waLogger.Errorf("Failed to scan suppression: %v", err)
+			http.Error(w, "Failed to list suppressions", http.StatusInternalServerError)
+			return
+		}
+		s.CreatedAt = time.Unix(created, 0).UTC()
+		list = append(list, s)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"suppressions": list})
+}
+
+func addSuppression(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Phone  string `json:"phone"`
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	phone, err := normalizePhone(req.Phone)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s := suppression{Phone: phone, Reason: req.Reason, CreatedAt: time.Now().UTC()}
+	_, err = gatewayDB.ExecContext(r.Context(), `
+		INSERT INTO suppressions (phone, reason, created_at) VALUES (?, ?, ?)
+		ON CONFLICT (phone) DO UPDATE SET reason = excluded.reason`,
+		s.Phone, s.Reason, s.CreatedAt.Unix())
+	if err != nil {
+		waLogger.Errorf("Failed to add suppression: %v", err)
+		http.Error(w, "Failed to add suppression", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, s)
+}
+
+func deleteSuppression(w http.ResponseWriter, r *http.Request) {
+	phone, err := normalizePhone(r.PathValue("phone"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	res, err := gatewayDB.ExecContext(r.Context(), `DELETE FROM suppressions WHERE phone = ?`, phone)
+	if err != nil {
+		waLogger.Errorf("Failed to delete suppression: %v", err)
+		http.Error(w, "Failed to delete suppression", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		http.Error(w, "Suppression not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// WhatsApp increasingly addresses users by LID ("linked identity", @lid)
+// instead of their phone number, most visibly in groups. whatsmeow persists
+// the LID<->PN mappings it learns in its store; this layer caches lookups and
+// makes sure API consumers see the phone-number form whenever it's known.
+
+var (
+	lidToPN   = make(map[types.JID]types.JID)
+	pnToLID   = make(map[types.JID]types.JID)
+	lidCacheM sync.RWMutex
+)
+
+func rememberLIDMapping(lid, pn types.JID) {
+	lid, pn = lid.ToNonAD(), pn.ToNonAD()
+	lidCacheM.Lock()
+	lidToPN[lid] = pn
+	pnToLID[pn] = lid
+	lidCacheM.Unlock()
+}
+
+// lookupPN returns the phone-number JID for a LID, if known.
+func lookupPN(ctx context.Context, lid types.JID) (types.JID, bool) {
+	lid = lid.ToNonAD()
+	lidCacheM.RLock()
+	pn, ok := lidToPN[lid]
+	lidCacheM.RUnlock()
+	if ok {
+		return pn, true
+	}
+	if client == nil || client.Store.LIDs == nil {
+		return types.EmptyJID, false
+	}
+	pn, err := client.Store.LIDs.GetPNForLID(ctx, lid)
+	if err != nil || pn.IsEmpty() {
+		return types.EmptyJID, false
+	}
+	rememberLIDMapping(lid, pn)
+	return pn, true
+}
+
+// lookupLID returns the LID for a phone-number JID, if known.
+func lookupLID(ctx context.Context, pn types.JID) (types.JID, bool) {
+	pn = pn.ToNonAD()
+	lidCacheM.RLock()
+	lid, ok := pnToLID[pn]
+	lidCacheM.RUnlock()
+	if ok {
+		return lid, true
+	}
+	if client == nil || client.Store.LIDs == nil {
+		return types.EmptyJID, false
+	}
+	lid, err := client.Store.LIDs.GetLIDForPN(ctx, pn)
+	if err != nil || lid.IsEmpty() {
+		return types.EmptyJID, false
+	}
+	rememberLIDMapping(lid, pn)
+	return lid, true
+}
+
+// toPhoneJID swaps a LID for its phone-number JID when the mapping is known,
+// keeping the device part. Any other JID is returned unchanged.
+func toPhoneJID(ctx context.Context, jid types.JID) types.JID {
+	if jid.Server != types.HiddenUserServer {
+		return jid
+	}
+	pn, ok := lookupPN(ctx, jid)
+	if !ok {
+		return jid
+	}
+	pn.Device = jid.Device
+	return pn
+}
+
+// canonicalJID is the key used for per-contact state: the phone-number JID
+// without device when known, otherwise the LID.
+func canonicalJID(ctx context.Context, jid types.JID) types.JID {
+	return toPhoneJID(ctx, jid.ToNonAD())
+}
+
+func getLIDMapping(w http.ResponseWriter, r *http.Request) {
+	jid, ok := parseJID(r.PathValue("jid"))
+	if !ok {
+		http.Error(w, "Invalid JID", http.StatusBadRequest)
+		return
+	}
+	var lid, pn types.JID
+	var found bool
+	if jid.Server == types.HiddenUserServer {
+		lid = jid.ToNonAD()
+		pn, found = lookupPN(r.Context(), lid)
+	} else {
+		pn = jid.ToNonAD()
+		lid, found = lookupLID(r.Context(), pn)
+	}
+	if !found {
+		http.Error(w, "No LID mapping known for this JID", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"lid": lid.String(), "pn": pn.String()})
+}
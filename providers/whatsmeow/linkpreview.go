@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"google.golang.org/protobuf/proto"
+)
+
+// Sends with "link_preview": true fetch the first URL in the text and attach
+// its title, description and a small thumbnail of its image, the way the
+// WhatsApp apps do. Pages are fetched from the gateway, so only public
+// addresses are allowed; a preview that can't be made leaves the text as is
+// with a warning.
+
+const (
+	linkPreviewTimeout   = 10 * time.Second
+	linkPreviewMaxHTML   = 512 << 10
+	linkPreviewMaxImage  = 5 << 20
+	linkPreviewThumbSize = 192
+)
+
+var (
+	linkURLRe  = regexp.MustCompile(`https?://[^\s<>"]+`)
+	metaTagRe  = regexp.MustCompile(`(?is)<meta\s[^>]*>`)
+	metaAttrRe = regexp.MustCompile(`(?is)(property|name|content)\s*=\s*("[^"]*"|'[^']*')`)
+	titleTagRe = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+)
+
+// errPrivateAddress keeps fetches of user-given URLs (link previews, media
+// sent by URL) from reaching the gateway's own network.
+var errPrivateAddress = errors.New("address is not public")
+
+var publicTransport = &http.Transport{
+	DialContext: (&net.Dialer{
+		Timeout: 5 * time.Second,
+		// Checked on the resolved address, so DNS tricks and redirects
+		// are covered too.
+		Control: func(network, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+				ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsMulticast() {
+				return errPrivateAddress
+			}
+			return nil
+		},
+	}).DialContext,
+}
+
+var linkPreviewClient = &http.Client{Timeout: linkPreviewTimeout, Transport: publicTransport}
+
+type pageMetadata struct {
+	Title       string
+	Description string
+	Image       string
+}
+
+func fetchLimited(ctx context.Context, target string, limit int64) ([]byte, string, *url.URL, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", target, nil)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; WhatsAppGateway-LinkPreview/1.0)")
+	resp, err := linkPreviewClient.Do(req)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return nil, "", nil, fmt.Errorf("%s returned %s", req.URL.Host, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit))
+	return data, resp.Header.Get("Content-Type"), resp.Request.URL, err
+}
+
+// parsePageMetadata reads the Open Graph tags of a page, falling back to its
+// title and meta description.
+func parsePageMetadata(page string) pageMetadata {
+	var meta pageMetadata
+	for _, tag := range metaTagRe.FindAllString(page, -1) {
+		var key, content string
+		for _, attr := range metaAttrRe.FindAllStringSubmatch(tag, -1) {
+			value := html.UnescapeString(strings.Trim(attr[2], `"'`))
+			if strings.EqualFold(attr[1], "content") {
+				content = value
+			} else {
+				key = strings.ToLower(value)
+			}
+		}
+		content = strings.TrimSpace(content)
+		switch {
+		case content == "":
+		case key == "og:title", key == "twitter:title" && meta.Title == "":
+			meta.Title = content
+		case key == "og:description", (key == "description" || key == "twitter:description") && meta.Description == "":
+			meta.Description = content
+		case key == "og:image", key == "og:image:url", key == "twitter:image" && meta.Image == "":
+			meta.Image = content
+		}
+	}
+	if meta.Title == "" {
+		if m := titleTagRe.FindStringSubmatch(page); m != nil {
+			meta.Title = strings.Join(strings.Fields(html.UnescapeString(m[1])), " ")
+		}
+	}
+	return meta
+}
+
+// addLinkPreview fills in the preview of the first URL in the message text.
+func addLinkPreview(ctx context.Context, ext *waE2E.ExtendedTextMessage) error {
+	link := linkURLRe.FindString(ext.GetText())
+	if link == "" {
+		return fmt.Errorf("no link in the text")
+	}
+	link = strings.TrimRight(link, ".,;:!?)")
+	ctx, cancel := context.WithTimeout(ctx, linkPreviewTimeout)
+	defer cancel()
+	page, contentType, final, err := fetchLimited(ctx, link, linkPreviewMaxHTML)
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(contentType, "html") {
+		return fmt.Errorf("%s is not a web page", link)
+	}
+	meta := parsePageMetadata(string(page))
+	if meta.Title == "" {
+		return fmt.Errorf("%s has no title", link)
+	}
+	ext.MatchedText = proto.String(link)
+	ext.Title = proto.String(meta.Title)
+	if meta.Description != "" {
+		ext.Description = proto.String(meta.Description)
+	}
+	ext.PreviewType = waE2E.ExtendedTextMessage_NONE.Enum()
+	if meta.Image == "" {
+		return nil
+	}
+	// The page is still worth previewing without its image.
+	if imageURL, err := final.Parse(meta.Image); err == nil && (imageURL.Scheme == "https" || imageURL.Scheme == "http") {
+		if thumb, w, h, err := linkThumbnail(ctx, imageURL.String()); err != nil {
+			waLogger.Warnf("Failed to make preview thumbnail for %s: %v", link, err)
+		} else {
+			ext.JPEGThumbnail = thumb
+			ext.ThumbnailWidth, ext.ThumbnailHeight = proto.Uint32(uint32(w)), proto.Uint32(uint32(h))
+		}
+	}
+	return nil
+}
+
+// linkThumbnail downloads a preview image and scales it down to a JPEG
+// thumbnail.
+func linkThumbnail(ctx context.Context, imageURL string) ([]byte, int, int, error) {
+	data, _, _, err := fetchLimited(ctx, imageURL, linkPreviewMaxImage)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	return scaleToJPEG(ctx, data, "", linkPreviewThumbSize)
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"google.golang.org/protobuf/proto"
+)
+
+// Sends with "track_links": true have each URL in the text replaced by a
+// short link to the gateway's /l/{token}, which records the click and
+// redirects to the original URL. Links are recorded per message and
+// recipient; a send can also name a campaign, whose click-through is totalled
+// by /analytics/campaigns/{campaign}. LINK_BASE_URL is the gateway's public
+// address that short links point to; tracking is unavailable without it.
+
+var linkBaseURL = strings.TrimSuffix(envString("LINK_BASE_URL", ""), "/")
+
+func linkTrackingEnabled() bool {
+	return linkBaseURL != "" && gatewayDB != nil
+}
+
+// linkTarget cuts the punctuation that usually ends a sentence rather than a
+// URL off a linkURLRe match.
+func linkTarget(match string) string {
+	return strings.TrimRight(match, ".,;:!?)]}'")
+}
+
+func newLinkToken() string {
+	b := make([]byte, 9)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// trackMessageLinks replaces the URLs in msg's text with tracked short links
+// and returns their tokens, to be tied to the message once it's sent.
+func trackMessageLinks(ctx context.Context, to types.JID, msg *waE2E.Message, campaign string) ([]string, error) {
+	text := messageText(msg)
+	matches := linkURLRe.FindAllString(text, -1)
+	if len(matches) == 0 {
+		return nil, nil
+	}
+	tx, err := gatewayDB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	short := map[string]string{} // the same URL twice shares a link
+	var tokens []string
+	now := time.Now().Unix()
+	for _, match := range matches {
+		target := linkTarget(match)
+		if short[target] != "" || strings.HasPrefix(target, linkBaseURL+"/l/") {
+			continue
+		}
+		token := newLinkToken()
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO tracked_links (token, url, recipient, campaign, created_at) VALUES (?, ?, ?, ?, ?)`,
+			token, target, to.String(), campaign, now); err != nil {
+			return nil, err
+		}
+		short[target] = linkBaseURL + "/l/" + token
+		tokens = append(tokens, token)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	setMessageText(msg, linkURLRe.ReplaceAllStringFunc(text, func(match string) string {
+		target := linkTarget(match)
+		if short[target] == "" {
+			return match
+		}
+		return short[target] + match[len(target):]
+	}))
+	// Keep a link preview attached to its (now shortened) link.
+	if ext := msg.GetExtendedTextMessage(); ext != nil && short[linkTarget(ext.GetMatchedText())] != "" {
+		ext.MatchedText = proto.String(short[linkTarget(ext.GetMatchedText())])
+	}
+	return tokens, nil
+}
+
+// finishTrackedLinks ties tracked links to the message they were sent in, or
+// drops them if the send failed.
+func finishTrackedLinks(ctx context.Context, tokens []string, id types.MessageID, sent bool) {
+	if len(tokens) == 0 {
+		return
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(tokens)), ", ")
+	args := make([]interface{}, 0, len(tokens)+1)
+	query := `DELETE FROM tracked_links WHERE token IN (` + placeholders + `)`
+	if sent {
+		args = append(args, id)
+		query = `UPDATE tracked_links SET message_id = ? WHERE token IN (` + placeholders + `)`
+	}
+	for _, token := range tokens {
+		args = append(args, token)
+	}
+	if _, err := gatewayDB.ExecContext(ctx, query, args...); err != nil {
+		waLogger.Errorf("Failed to update tracked links of message %s: %v", id, err)
+	}
+}
+
+// followLink records a click on a tracked link and redirects to its URL.
+func followLink(w http.ResponseWriter, r *http.Request) {
+	if gatewayDB == nil {
+		http.NotFound(w, r)
+		return
+	}
+	token := r.PathValue("token")
+	var target, recipient string
+	err := gatewayDB.QueryRowContext(r.Context(),
+		`SELECT url, recipient FROM tracked_links WHERE token = ?`, token).Scan(&target, &recipient)
+	if err == sql.ErrNoRows {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		waLogger.Errorf("Failed to look up link %s: %v", token, err)
+		http.Error(w, "Failed to follow link", http.StatusInternalServerError)
+		return
+	}
+	// HEAD requests come from link checkers, not people.
+	if r.Method == http.MethodGet {
+		if _, err := gatewayDB.ExecContext(r.Context(),
+			`INSERT INTO link_clicks (token, recipient, clicked_at) VALUES (?, ?, ?)`,
+			token, recipient, time.Now().Unix()); err != nil {
+			waLogger.Errorf("Failed to record click on link %s: %v", token, err)
+		}
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+type trackedLink struct {
+	Token         string     `json:"token"`
+	URL           string     `json:"url"`
+	ShortURL      string     `json:"short_url"`
+	Recipient     string     `json:"recipient"`
+	MessageID     string     `json:"message_id,omitempty"`
+	Campaign      string     `json:"campaign,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	Clicks        int        `json:"clicks"`
+	LastClickedAt *time.Time `json:"last_clicked_at,omitempty"`
+}
+
+// listTrackedLinks lists tracked links with their clicks, newest first,
+// filtered by ?campaign=, ?recipient= and ?message_id=.
+func listTrackedLinks(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var conds []string
+	var args []interface{}
+	if c := q.Get("campaign"); c != "" {
+		conds, args = append(conds, `l.campaign = ?`), append(args, c)
+	}
+	if rcpt := q.Get("recipient"); rcpt != "" {
+		jid, err := parseRecipient(rcpt)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		conds, args = append(conds, `l.recipient = ?`), append(args, toPhoneJID(r.Context(), jid).String())
+	}
+	if id := q.Get("message_id"); id != "" {
+		conds, args = append(conds, `l.message_id = ?`), append(args, id)
+	}
+	limit := 100
+	if l := q.Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 || n > 1000 {
+			http.Error(w, "Invalid limit, expected 1-1000", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	where := ""
+	if len(conds) > 0 {
+		where = `WHERE ` + strings.Join(conds, ` AND `)
+	}
+	rows, err := gatewayDB.QueryContext(r.Context(), `
+		SELECT l.token, l.url, l.recipient, l.message_id, l.campaign, l.created_at,
+			COUNT(c.id), COALESCE(MAX(c.clicked_at), 0)
+		FROM tracked_links l LEFT JOIN link_clicks c ON c.token = l.token
+		`+where+`
+		GROUP BY l.token ORDER BY l.created_at DESC, l.token LIMIT ?`, append(args, limit)...)
+	if err != nil {
+		waLogger.Errorf("Failed to list tracked links: %v", err)
+		http.Error(w, "Failed to list links", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	links := []trackedLink{}
+	for rows.Next() {
+		var l trackedLink
+		var created, lastClicked int64
+		if err := rows.Scan(&l.Token, &l.URL, &l.Recipient, &l.MessageID, &l.Campaign, &created, &l.Clicks, &lastClicked); err != nil {
+			waLogger.Errorf("Failed to read tracked link: %v", err)
+			http.Error(w, "Failed to list links", http.StatusInternalServerError)
+			return
+		}
+		l.ShortURL = linkBaseURL + "/l/" + l.Token
+		l.CreatedAt = time.Unix(created, 0).UTC()
+		l.LastClickedAt = unixPtr(lastClicked)
+		links = append(links, l)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"links": links})
+}
+
+type linkStats struct {
+	URL        string  `json:"url,omitempty"`
+	Recipients int     `json:"recipients"`
+	Clicks     int     `json:"clicks"`
+	Clickers   int     `json:"unique_clicks"` // recipients who clicked
+	CTR        float64 `json:"click_through_rate"`
+}
+
+func (s *linkStats) rate() {
+	if s.Recipients > 0 {
+		s.CTR = float64(s.Clickers) / float64(s.Recipients)
+	}
+}
+
+// getCampaignLinkAnalytics returns a campaign's click-through overall and per
+// URL. Links in group messages count the group as one recipient.
+func getCampaignLinkAnalytics(w http.ResponseWriter, r *http.Request) {
+	campaign := r.PathValue("campaign")
+	const stats = `COUNT(DISTINCT l.recipient), COUNT(c.id), COUNT(DISTINCT c.recipient)`
+	var total linkStats
+	var messages int
+	err := gatewayDB.QueryRowContext(r.Context(), `
+		SELECT COUNT(DISTINCT l.message_id), `+stats+`
+		FROM tracked_links l LEFT JOIN link_clicks c ON c.token = l.token
+		WHERE l.campaign = ? AND l.message_id <> ''`, campaign).
+		Scan(&messages, &total.Recipients, &total.Clicks, &total.Clickers)
+	if err != nil {
+		waLogger.Errorf("Failed to compute link analytics of campaign %s: %v", campaign, err)
+		http.Error(w, "Failed to compute link analytics", http.StatusInternalServerError)
+		return
+	}
+	if messages == 0 {
+		http.Error(w, "No tracked links in this campaign", http.StatusNotFound)
+		return
+	}
+	total.rate()
+	rows, err := gatewayDB.QueryContext(r.Context(), `
+		SELECT l.url, `+stats+`
+		FROM tracked_links l LEFT JOIN link_clicks c ON c.token = l.token
+		WHERE l.campaign = ? AND l.message_id <> ''
+		GROUP BY l.url ORDER BY COUNT(c.id) DESC, l.url`, campaign)
+	if err != nil {
+		waLogger.Errorf("Failed to compute link analytics of campaign %s: %v", campaign, err)
+		http.Error(w, "Failed to compute link analytics", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	urls := []linkStats{}
+	for rows.Next() {
+		var s linkStats
+		if err := rows.Scan(&s.URL, &s.Recipients, &s.Clicks, &s.Clickers); err != nil {
+			waLogger.Errorf("Failed to read link analytics of campaign %s: %v", campaign, err)
+			http.Error(w, "Failed to compute link analytics", http.StatusInternalServerError)
+			return
+		}
+		s.rate()
+		urls = append(urls, s)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"campaign":           campaign,
+		"messages":           messages,
+		"recipients":         total.Recipients,
+		"clicks":             total.Clicks,
+		"unique_clicks":      total.Clickers,
+		"click_through_rate": total.CTR,
+		"urls":               urls,
+	})
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/text/language"
+)
+
+// Each session has its own timezone and locale. Anything that works with
+// wall-clock time (quiet hours, schedules, away messages, analytics, exports)
+// uses sessionLocation instead of the server's clock.
+
+type localeSettings struct {
+	Timezone string `json:"timezone"`
+	Locale   string `json:"locale"`
+}
+
+var (
+	localeMu      sync.RWMutex
+	localeCurrent = localeSettings{Timezone: "UTC", Locale: "en"}
+	localeTZ      = time.UTC
+)
+
+func loadLocaleSettings(ctx context.Context) {
+	settings := localeSettings{
+		Timezone: getSetting(ctx, "timezone", envString("SESSION_TIMEZONE", "UTC")),
+		Locale:   getSetting(ctx, "locale", envString("SESSION_LOCALE", "en")),
+	}
+	loc, err := time.LoadLocation(settings.Timezone)
+	if err != nil {
+		waLogger.Errorf("Invalid timezone %q, using UTC: %v", settings.Timezone, err)
+		settings.Timezone, loc = "UTC", time.UTC
+	}
+	if _, err := language.Parse(settings.Locale); err != nil {
+		waLogger.Errorf("Invalid locale %q, using en: %v", settings.Locale, err)
+		settings.Locale = "en"
+	}
+	localeMu.Lock()
+	localeCurrent, localeTZ = settings, loc
+	localeMu.Unlock()
+}
+
+// sessionLocation returns the session's timezone.
+func sessionLocation() *time.Location {
+	localeMu.RLock()
+	defer localeMu.RUnlock()
+	return localeTZ
+}
+
+// requestLocation lets a request override the session timezone with ?tz=.
+func requestLocation(r *http.Request) (*time.Location, error) {
+	if tz := r.URL.Query().Get("tz"); tz != "" {
+		return time.LoadLocation(tz)
+	}
+	return sessionLocation(), nil
+}
+
+func getLocaleSettings(w http.ResponseWriter, r *http.Request) {
+	localeMu.RLock()
+	settings := localeCurrent
+	localeMu.RUnlock()
+	writeJSON(w, http.StatusOK, settings)
+}
+
+func putLocaleSettings(w http.ResponseWriter, r *http.Request) {
+	localeMu.RLock()
+	settings := localeCurrent
+	localeMu.RUnlock()
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	loc, err := time.LoadLocation(settings.Timezone)
+	if err != nil || settings.Timezone == "" || settings.Timezone == "Local" {
+		http.Error(w, "Invalid timezone, expected an IANA name such as Europe/Berlin", http.StatusBadRequest)
+		return
+	}
+	tag, err := language.Parse(settings.Locale)
+	if err != nil {
+		http.Error(w, "Invalid locale, expected a BCP 47 tag such as pt-BR", http.StatusBadRequest)
+		return
+	}
+	settings.Locale = tag.String()
+	for key, value := range map[string]string{"timezone": settings.Timezone, "locale": settings.Locale} {
+		if err := setSetting(r.Context(), key, value); err != nil {
+			waLogger.Errorf("Failed to save %s: %v", key, err)
+			http.Error(w, "Failed to save settings", http.StatusInternalServerError)
+			return
+		}
+	}
+	localeMu.Lock()
+	localeCurrent, localeTZ = settings, loc
+	localeMu.Unlock()
+	writeJSON(w, http.StatusOK, settings)
+}
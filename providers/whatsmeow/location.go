@@ -0,0 +1,199 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+const (
+	// liveLocationTTL is how long a live share stays queryable after its last
+	// update. WhatsApp doesn't send an explicit "share ended" message.
+	liveLocationTTL = 15 * time.Minute
+	// liveLocationMaxPoints bounds memory per track for long-running shares.
+	liveLocationMaxPoints = 500
+)
+
+type normalizedLocation struct {
+	Latitude  float64 `json:"lat"`
+	Longitude float64 `json:"lng"`
+	Accuracy  uint32  `json:"accuracy_m,omitempty"`
+	Speed     float32 `json:"speed_mps,omitempty"`
+	Heading   uint32  `json:"heading_deg,omitempty"`
+	Name      string  `json:"name,omitempty"`
+	Address   string  `json:"address,omitempty"`
+	URL       string  `json:"url,omitempty"`
+	Caption   string  `json:"caption,omitempty"`
+	IsLive    bool    `json:"is_live"`
+	Sequence  int64   `json:"sequence,omitempty"`
+}
+
+// parseLocationMessage normalizes static and live location messages into a
+// single shape. It returns nil for any other message type.
+func parseLocationMessage(msg *waE2E.Message) *normalizedLocation {
+	if loc := msg.GetLocationMessage(); loc != nil {
+		return &normalizedLocation{
+			Latitude:  loc.GetDegreesLatitude(),
+			Longitude: loc.GetDegreesLongitude(),
+			Accuracy:  loc.GetAccuracyInMeters(),
+			Speed:     loc.GetSpeedInMps(),
+			Heading:   loc.GetDegreesClockwiseFromMagneticNorth(),
+			Name:      loc.GetName(),
+			Address:   loc.GetAddress(),
+			URL:       loc.GetURL(),
+			Caption:   loc.GetComment(),
+			IsLive:    loc.GetIsLive(),
+		}
+	}
+	if loc := msg.GetLiveLocationMessage(); loc != nil {
+		return &normalizedLocation{
+			Latitude:  loc.GetDegreesLatitude(),
+			Longitude: loc.GetDegreesLongitude(),
+			Accuracy:  loc.GetAccuracyInMeters(),
+			Speed:     loc.GetSpeedInMps(),
+			Heading:   loc.GetDegreesClockwiseFromMagneticNorth(),
+			Caption:   loc.GetCaption(),
+			IsLive:    true,
+			Sequence:  loc.GetSequenceNumber(),
+		}
+	}
+	return nil
+}
+
+// --- Live Location Tracking ---
+
+type liveLocationPoint struct {
+	Latitude  float64   `json:"lat"`
+	Longitude float64   `json:"lng"`
+	Accuracy  uint32    `json:"accuracy_m,omitempty"`
+	Speed     float32   `json:"speed_mps,omitempty"`
+	Heading   uint32    `json:"heading_deg,omitempty"`
+	Sequence  int64     `json:"sequence,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+type liveLocationTrack struct {
+	Contact   string              `json:"contact"`
+	Chat      string              `json:"chat"`
+	Caption   string              `json:"caption,omitempty"`
+	StartedAt time.Time           `json:"started_at"`
+	UpdatedAt time.Time           `json:"updated_at"`
+	Points    []liveLocationPoint `json:"points"`
+}
+
+var (
+	liveLocations   = make(map[string]*liveLocationTrack)
+	liveLocationsMu sync.Mutex
+)
+
+// recordLiveLocation appends a live location update to the sender's track,
+// starting a new track if the previous share has gone stale.
+func recordLiveLocation(evt *events.Message, loc *normalizedLocation) {
+	if loc == nil || !loc.IsLive {
+		return
+	}
+	contact := evt.Info.Sender.ToNonAD().String()
+	point := liveLocationPoint{
+		Latitude:  loc.Latitude,
+		Longitude: loc.Longitude,
+		Accuracy:  loc.Accuracy,
+		Speed:     loc.Speed,
+		Heading:   loc.Heading,
+		Sequence:  loc.Sequence,
+		Timestamp: evt.Info.Timestamp,
+	}
+
+	liveLocationsMu.Lock()
+	defer liveLocationsMu.Unlock()
+	track, ok := liveLocations[contact]
+	if !ok || time.Since(track.UpdatedAt) > liveLocationTTL {
+		track = &liveLocationTrack{
+			Contact:   contact,
+			Chat:      evt.Info.Chat.String(),
+			StartedAt: evt.Info.Timestamp,
+		}
+		liveLocations[contact] = track
+	}
+	if loc.Caption != "" {
+		track.Caption = loc.Caption
+	}
+	track.UpdatedAt = time.Now()
+	track.Points = append(track.Points, point)
+	if len(track.Points) > liveLocationMaxPoints {
+		track.Points = track.Points[len(track.Points)-liveLocationMaxPoints:]
+	}
+}
+
+// activeLiveLocation returns a copy of the contact's track if the share is
+// still active, pruning it otherwise.
+func activeLiveLocation(contact string) (liveLocationTrack, bool) {
+	liveLocationsMu.Lock()
+	defer liveLocationsMu.Unlock()
+	track, ok := liveLocations[contact]
+	if !ok {
+		return liveLocationTrack{}, false
+	}
+	if time.Since(track.UpdatedAt) > liveLocationTTL {
+		delete(liveLocations, contact)
+		return liveLocationTrack{}, false
+	}
+	cp := *track
+	cp.Points = append([]liveLocationPoint(nil), track.Points...)
+	return cp, true
+}
+
+func listLiveLocations(w http.ResponseWriter, r *http.Request) {
+	liveLocationsMu.Lock()
+	var contacts []string
+	for contact := range liveLocations {
+		contacts = append(contacts, contact)
+	}
+	liveLocationsMu.Unlock()
+	sort.Strings(contacts)
+
+	type trackSummary struct {
+		Contact   string             `json:"contact"`
+		Chat      string             `json:"chat"`
+		StartedAt time.Time          `json:"started_at"`
+		UpdatedAt time.Time          `json:"updated_at"`
+		Updates   int                `json:"updates"`
+		Last      *liveLocationPoint `json:"last,omitempty"`
+	}
+	summaries := []trackSummary{}
+	for _, contact := range contacts {
+		track, ok := activeLiveLocation(contact)
+		if !ok {
+			continue
+		}
+		summary := trackSummary{
+			Contact:   track.Contact,
+			Chat:      track.Chat,
+			StartedAt: track.StartedAt,
+			UpdatedAt: track.UpdatedAt,
+			Updates:   len(track.Points),
+		}
+		if len(track.Points) > 0 {
+			summary.Last = &track.Points[len(track.Points)-1]
+		}
+		summaries = append(summaries, summary)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"tracks": summaries})
+}
+
+func getLiveLocation(w http.ResponseWriter, r *http.Request) {
+	jid, ok := parseJID(r.PathValue("jid"))
+	if !ok {
+		http.Error(w, "Invalid JID", http.StatusBadRequest)
+		return
+	}
+	track, ok := activeLiveLocation(canonicalJID(r.Context(), jid).String())
+	if !ok {
+		http.Error(w, "No active live location for this contact", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, track)
+}
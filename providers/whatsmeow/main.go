@@ -3,14 +3,15 @@ package main
 import (
 	"bytes"
 	"context"
+	"crypto/subtle"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
-	"strings"
 	"sync"
 	"syscall"
 	"time"
@@ -34,10 +35,10 @@ var startTime = time.Now()
 
 // --- State Snapshotting ---
 var (
-	gatewayURL      = os.Getenv("GATEWAY_URL")
-	instanceID      = os.Getenv("INSTANCE_ID")
+	gatewayURL        = os.Getenv("GATEWAY_URL")
+	instanceID        = os.Getenv("INSTANCE_ID")
 	internalAPISecret = os.Getenv("INTERNAL_API_SECRET")
-	dbPath          = "/app/session/whatsmeow.db"
+	dbPath            = "/app/session/whatsmeow.db"
 )
 
 func fetchStateSnapshot() error {
@@ -133,55 +134,252 @@ type webhookPayload struct {
 	Data  interface{} `json:"data"`
 }
 
-func eventHandler(evt interface{}) {
-	webhookURL := os.Getenv("WEBHOOK_URL")
-	if webhookURL == "" {
-		return // No webhook configured
-	}
+// messageWebhookData is the "message" event payload: the raw whatsmeow event
+// plus normalized fields derived from it.
+type messageWebhookData struct {
+	*events.Message
+	SenderLID      string               `json:"sender_lid,omitempty"`
+	ChatLID        string               `json:"chat_lid,omitempty"`
+	DisplayName    string               `json:"display_name"`
+	IsBusiness     bool                 `json:"is_business"`
+	IsSavedContact bool                 `json:"is_saved_contact"`
+	BotEnabled     *bool                `json:"bot_enabled,omitempty"` // false while an agent has taken over or the chat cools down
+	BotCooldown    *time.Time           `json:"bot_cooldown_until,omitempty"`
+	Contacts       []vCardContact       `json:"contacts,omitempty"`
+	Location       *normalizedLocation  `json:"location,omitempty"`
+	ButtonReply    *buttonReply         `json:"button_reply,omitempty"`
+	ListReply      *listReply           `json:"list_reply,omitempty"`
+	Payment        *paymentNotification `json:"payment,omitempty"`
+	Audio          *voiceNoteInfo       `json:"audio,omitempty"`
+	ViewOnce       bool                 `json:"view_once,omitempty"`
+
+	// Filled in by the enrichment workers (see enrichment.go) when they
+	// finish within ENRICHMENT_INLINE_WAIT.
+	TranslatedText   string `json:"translated_text,omitempty"`
+	DetectedLanguage string `json:"detected_language,omitempty"`
+	Transcript       string `json:"transcript,omitempty"` // voice notes
+
+	ImageAnalysis *imageAnalysis `json:"image_analysis,omitempty"`
+	Scan          *scanVerdict   `json:"scan,omitempty"` // documents
+	Media         *mediaInfo     `json:"media,omitempty"`
+
+	SurveyRunID int64 `json:"survey_run_id,omitempty"` // taken as the answer to a survey question
+}
 
+// newMessageWebhookData builds the payload from a copy of the event whose
+// chat and sender are rewritten to phone-number JIDs when the LID mapping is
+// known; the original LIDs are kept in sender_lid/chat_lid.
+func newMessageWebhookData(evt *events.Message) *messageWebhookData {
+	ctx := context.Background()
+	normalized := *evt
+	data := &messageWebhookData{
+		Message:     &normalized,
+		Contacts:    parseContactMessages(evt.Message),
+		Location:    parseLocationMessage(evt.Message),
+		ButtonReply: parseButtonReply(evt.Message),
+		ListReply:   parseListReply(evt.Message),
+		Payment:     parsePaymentMessage(evt.Message),
+		Audio:       parseAudioMetadata(evt.Message),
+		ViewOnce:    isViewOnce(evt),
+	}
+	if evt.Info.Sender.Server == types.HiddenUserServer {
+		data.SenderLID = evt.Info.Sender.ToNonAD().String()
+		normalized.Info.Sender = toPhoneJID(ctx, evt.Info.Sender)
+	}
+	if evt.Info.Chat.Server == types.HiddenUserServer {
+		data.ChatLID = evt.Info.Chat.String()
+		normalized.Info.Chat = toPhoneJID(ctx, evt.Info.Chat)
+	}
+	contact := resolveContact(ctx, evt.Info.Sender, evt.Info.PushName, evt.Info.VerifiedName)
+	data.DisplayName = contact.DisplayName
+	data.IsBusiness = contact.IsBusiness
+	data.IsSavedContact = contact.IsSavedContact
+	return data
+}
+
+func eventHandler(evt interface{}) {
+	countOfflineSyncEvent()
 	var payload webhookPayload
 	switch v := evt.(type) {
 	case *events.Message:
+		if v.Message.GetPollUpdateMessage() != nil {
+			go handlePollVote(v)
+			return
+		}
 		waLogger.Infof("Received message from %s: %s", v.Info.Sender, v.Message.GetConversation())
-		payload = webhookPayload{Event: "message", Data: v}
+		recordPollMessage(v)
+		recordDisappearingSetting(v)
+		data := newMessageWebhookData(v)
+		recordLiveLocation(data.Message, data.Location)
+		chatName := ""
+		if !v.Info.IsGroup && !v.Info.IsFromMe {
+			chatName = v.Info.PushName
+		}
+		touchChat(context.Background(), data.Info.Chat, chatName, !v.Info.IsFromMe, v.Info.Timestamp)
+		if !v.Info.IsFromMe {
+			enabled := featureEnabled(flagResponder) && botEnabled(context.Background(), data.Info.Chat)
+			if enabled {
+				if until, limited, started := throttleBot(data.Info.Chat); limited {
+					enabled = false
+					data.BotCooldown = &until
+					if started {
+						go startBotCooldown(data.Info.Chat, until)
+					}
+				}
+			}
+			data.BotEnabled = &enabled
+			go autoMarkRead(v, data.Info.Chat, enabled)
+			answered := false
+			if !v.Info.IsGroup {
+				data.SurveyRunID, answered = answerSurvey(context.Background(), data.Info.Chat, v.Message)
+			}
+			go autoReplyCanned(data, enabled && !answered)
+			if data.Payment != nil {
+				emitPaymentWebhook(data)
+			}
+			go moderateGroupMessage(v, data)
+		}
+		storeMessage(context.Background(), storedMessage{
+			ID:        data.Info.ID,
+			Chat:      data.Info.Chat.ToNonAD().String(),
+			Sender:    data.Info.Sender.ToNonAD().String(),
+			FromMe:    v.Info.IsFromMe,
+			Type:      messageType(v.Message),
+			Text:      messageText(v.Message),
+			Timestamp: v.Info.Timestamp,
+		})
+		// Messages with translation, scans and the like to do go out from
+		// the enrichment workers, which forward them once scanned.
+		if v.Info.IsFromMe {
+			emitWebhook("message", data)
+		} else if !enqueueEnrichment(v, data) {
+			emitWebhook("message", data)
+			go forwardMessage(data)
+		}
+		return
 	case *events.Connected:
 		waLogger.Infof("Connected to WhatsApp")
+		markConnected()
+		offlineSyncConnected()
+		go resubscribePresence()
+		go runNewsletterStatsCollector()
 		payload = webhookPayload{Event: "connected", Data: nil}
+	case *events.ClientOutdated:
+		go handleClientOutdated()
+		return
+	case *events.MediaRetry:
+		handleMediaRetry(v)
+		forwardEvent(v)
+		return
+	case *events.OfflineSyncPreview:
+		payload = webhookPayload{Event: "sync.offline_preview", Data: offlineSyncPreview(v)}
+	case *events.OfflineSyncCompleted:
+		go resumeMediaJobs()
+		payload = webhookPayload{Event: "sync.offline_completed", Data: offlineSyncCompleted(v)}
 	case *events.Disconnected:
 		waLogger.Infof("Disconnected from WhatsApp")
+		markDisconnected()
+		offlineSyncDisconnected()
 		payload = webhookPayload{Event: "disconnected", Data: nil}
+	case *events.GroupInfo:
+		forgetGroupInfo(v.JID)
+		recordGroupParticipantChanges(v)
+		forwardEvent(v)
+		return
+	case *events.Presence:
+		recordPresence(v)
+		forwardEvent(v)
+		return
+	case *events.IdentityChange:
+		go handleIdentityChange(v)
+		return
+	case *events.Receipt:
+		recordReceipt(v)
+		forwardEvent(v)
+		return
+	case *events.NewsletterLiveUpdate:
+		recordNewsletterMessages(context.Background(), v.JID, v.Messages)
+		forwardEvent(v)
+		return
 	default:
-		return // Ignore other events for now
+		forwardEvent(evt)
+		return
 	}
 
-	go sendWebhook(webhookURL, payload)
+	emitWebhook(payload.Event, payload.Data)
 }
 
-func sendWebhook(url string, payload webhookPayload) {
-	data, err := json.Marshal(payload)
+// emitWebhook delivers an event to the tenant webhook in the background, or
+// buffers it while maintenance mode is on.
+func emitWebhook(event string, data interface{}) {
+	body, err := json.Marshal(webhookPayload{Event: event, Data: data})
 	if err != nil {
 		waLogger.Errorf("Failed to marshal webhook payload: %v", err)
 		return
 	}
+	journalWebhookEvent(event, data, body)
+	recordConsoleEvent(event, body)
+	webhookURL := os.Getenv("WEBHOOK_URL")
+	if webhookURL == "" {
+		return // No webhook configured
+	}
+	if maintenanceActive() {
+		bufferWebhook(webhookURL, body)
+		return
+	}
+	go sendWebhook(webhookURL, body)
+}
+
+// webhookClient is shared by all webhook deliveries so connections to the
+// tenant's endpoint are pooled and kept alive instead of paying a TLS
+// handshake per event.
+var webhookClient = newWebhookClient()
+
+func newWebhookClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{
+		Timeout:   10 * time.Second,
+		KeepAlive: envDuration("WEBHOOK_KEEP_ALIVE", 30*time.Second),
+	}).DialContext
+	transport.MaxIdleConns = envInt("WEBHOOK_MAX_IDLE_CONNS", 100)
+	transport.MaxIdleConnsPerHost = envInt("WEBHOOK_MAX_IDLE_CONNS_PER_HOST", 32)
+	transport.MaxConnsPerHost = envInt("WEBHOOK_MAX_CONNS_PER_HOST", 64)
+	transport.IdleConnTimeout = envDuration("WEBHOOK_IDLE_CONN_TIMEOUT", 90*time.Second)
+	return &http.Client{
+		Timeout:   envDuration("WEBHOOK_TIMEOUT", 10*time.Second),
+		Transport: transport,
+	}
+}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(data))
+func sendWebhook(url string, body []byte) {
+	if err := postWebhook(url, body); err != nil {
+		waLogger.Errorf("Failed to send webhook: %v", err)
+	}
+}
+
+// postWebhook makes a single delivery attempt and records its outcome.
+func postWebhook(url string, body []byte) error {
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(body))
 	if err != nil {
-		waLogger.Errorf("Failed to create webhook request: %v", err)
-		return
+		return fmt.Errorf("failed to create webhook request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
+	signWebhook(req, body)
 
-	httpClient := &http.Client{Timeout: 10 * time.Second}
-	resp, err := httpClient.Do(req)
+	resp, err := webhookClient.Do(req)
 	if err != nil {
-		waLogger.Errorf("Failed to send webhook: %v", err)
-		return
+		recordWebhookOutcome(false)
+		return err
 	}
 	defer resp.Body.Close()
+	// The connection is only reused once the body has been read.
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
 
+	recordWebhookOutcome(resp.StatusCode < 300)
 	if resp.StatusCode >= 300 {
-		waLogger.Warnf("Webhook call failed with status: %s", resp.Status)
+		return fmt.Errorf("webhook call failed with status: %s", resp.Status)
 	}
+	return nil
 }
 
 func getQR(w http.ResponseWriter, r *http.Request) {
@@ -203,31 +401,72 @@ func getQR(w http.ResponseWriter, r *http.Request) {
 }
 
 type sendMessageRequest struct {
-	To   string `json:"to"`
-	Text string `json:"text"`
+	To             string `json:"to"`
+	Text           string `json:"text"`
+	AllowDuplicate bool   `json:"allow_duplicate,omitempty"`
+	Translate      *bool  `json:"translate,omitempty"` // defaults to TRANSLATE_OUTBOUND
+
+	// Reply to an earlier message in the chat; quoted_sender can often be
+	// left out (see messageSender).
+	QuotedMessageID string `json:"quoted_message_id,omitempty"`
+	QuotedSender    string `json:"quoted_sender,omitempty"`
+
+	LinkPreview bool `json:"link_preview,omitempty"` // preview the first URL in the text
+
+	// Numbers or JIDs to notify; each needs its @<number> in the text.
+	Mentions []string `json:"mentions,omitempty"`
+
+	TrackLinks bool   `json:"track_links,omitempty"` // see links.go
+	Campaign   string `json:"campaign,omitempty"`
+
+	EphemeralExpiration string `json:"ephemeral_expiration,omitempty"` // off, 24h, 7d or 90d
 }
 
+// parseJID is parseRecipient for callers that only need to know whether the
+// input is usable.
 func parseJID(arg string) (types.JID, bool) {
-	if arg[0] == '+' {
-		arg = arg[1:]
-	}
-	if !strings.ContainsRune(arg, '@') {
-		return types.NewJID(arg, types.DefaultUserServer), true
-	}
-	recipient, err := types.ParseJID(arg)
+	recipient, err := parseRecipient(arg)
 	if err != nil {
-		waLogger.Errorf("Invalid JID %s: %v", arg, err)
-		return recipient, false
-	} else if recipient.User == "" {
-		waLogger.Errorf("Invalid JID %s: no user specified", arg)
+		waLogger.Errorf("Invalid JID: %v", err)
 		return recipient, false
 	}
 	return recipient, true
 }
 
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+var adminAPIKey = os.Getenv("ADMIN_API_KEY")
+
+// requireAdmin guards operator endpoints with the X-Admin-Key header. The
+// endpoints are disabled when ADMIN_API_KEY is unset.
+func requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if adminAPIKey == "" {
+			http.Error(w, "ADMIN_API_KEY is not configured", http.StatusServiceUnavailable)
+			return
+		}
+		if !isAdminRequest(r) {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func isAdminRequest(r *http.Request) bool {
+	return adminAPIKey != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("X-Admin-Key")), []byte(adminAPIKey)) == 1
+}
+
 func sendText(w http.ResponseWriter, r *http.Request) {
-	if client == nil || !client.IsConnected() {
-		http.Error(w, "Client not connected", http.StatusServiceUnavailable)
+	if sessionArchived() {
+		http.Error(w, "Session is archived", http.StatusConflict)
+		return
+	}
+	if !requireClient(w, r, true) {
 		return
 	}
 
@@ -237,26 +476,73 @@ func sendText(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	recipient, ok := parseJID(reqBody.To)
-	if !ok {
-		http.Error(w, fmt.Sprintf("Invalid JID: %s", reqBody.To), http.StatusBadRequest)
+	recipient, err := parseRecipient(reqBody.To)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	// Either form is accepted; a known LID is sent to the phone-number chat so
+	// the conversation doesn't split in two.
+	recipient = toPhoneJID(r.Context(), recipient)
 
 	msg := &waE2E.Message{
 		Conversation: proto.String(reqBody.Text),
 	}
-
-	ts, err := client.SendMessage(context.Background(), recipient, msg)
+	if reqBody.TrackLinks && !linkTrackingEnabled() {
+		http.Error(w, "Link tracking is not configured (LINK_BASE_URL)", http.StatusBadRequest)
+		return
+	}
+	expiration, err := parseEphemeralExpiration(reqBody.EphemeralExpiration)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	mentioned, err := mentionedJIDs(reqBody.Text, reqBody.Mentions)
 	if err != nil {
-		waLogger.Errorf("Error sending message: %v", err)
-		http.Error(w, "Failed to send message", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	var warnings []string
+	if reqBody.QuotedMessageID != "" || reqBody.LinkPreview || len(mentioned) > 0 {
+		ext := &waE2E.ExtendedTextMessage{Text: proto.String(reqBody.Text)}
+		if reqBody.QuotedMessageID != "" {
+			if ext.ContextInfo, err = quotedContext(r.Context(), recipient, reqBody.QuotedMessageID, reqBody.QuotedSender); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if len(mentioned) > 0 {
+			if ext.ContextInfo == nil {
+				ext.ContextInfo = &waE2E.ContextInfo{}
+			}
+			ext.ContextInfo.MentionedJID = mentioned
+		}
+		if reqBody.LinkPreview {
+			if err := addLinkPreview(r.Context(), ext); err != nil {
+				waLogger.Warnf("No link preview for message to %s: %v", recipient, err)
+				warnings = append(warnings, "link preview unavailable: "+err.Error())
+			}
+		}
+		msg = &waE2E.Message{ExtendedTextMessage: ext}
+	}
 
-	waLogger.Infof("Message sent to %s (ID: %s, Timestamp: %s)", recipient.String(), ts.ID, ts.Timestamp)
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "ok", "id": ts.ID})
+	opts := sendOptions{
+		AllowDuplicate: reqBody.AllowDuplicate,
+		Translate:      translateOutbound,
+		TrackLinks:     reqBody.TrackLinks,
+		Campaign:       reqBody.Campaign,
+		Expiration:     expiration,
+	}
+	if reqBody.Translate != nil {
+		opts.Translate = *reqBody.Translate
+	}
+	res, err := sendOrQueue(r.Context(), recipient, msg, opts)
+	if err != nil {
+		writeSendError(w, recipient, err)
+		return
+	}
+	res.Warnings = append(res.Warnings, warnings...)
+	writeSendResult(w, recipient, res)
 }
 
 func healthHandler(w http.ResponseWriter, r *http.Request) {
@@ -269,12 +555,13 @@ func healthHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	response := map[string]interface{}{
-		"status":      "healthy",
-		"connected":   connected,
-		"phone_id":    phoneID,
-		"uptime":      time.Since(startTime).String(),
-		"version":     "1.0.0",
-		"timestamp":   time.Now().Unix(),
+		"status":    "healthy",
+		"connected": connected,
+		"caught_up": currentOfflineSync().CaughtUp,
+		"phone_id":  phoneID,
+		"uptime":    time.Since(startTime).String(),
+		"version":   version,
+		"timestamp": time.Now().Unix(),
 	}
 
 	json.NewEncoder(w).Encode(response)
@@ -283,8 +570,169 @@ func healthHandler(w http.ResponseWriter, r *http.Request) {
 func startAPIServer() {
 	http.HandleFunc("/health", healthHandler)
 	http.HandleFunc("/status", healthHandler) // Alias for health
+	http.HandleFunc("GET /version", versionHandler)
+	http.HandleFunc("GET /openapi.json", getOpenAPISpec)
+	http.HandleFunc("GET /sync/offline", getOfflineSync)
 	http.HandleFunc("/qr", getQR)
-	http.HandleFunc("/send", sendText)
+	http.HandleFunc("POST /webhooks/test", requireAPIKey(testWebhook))
+	http.HandleFunc("/send", requireAPIKey(shedLoad(sendText)))
+	http.HandleFunc("POST /send/video", requireAPIKey(shedLoad(sendVideo)))
+	http.HandleFunc("POST /send/document", requireAPIKey(shedLoad(sendDocument)))
+	http.HandleFunc("POST /send/audio", requireAPIKey(shedLoad(sendAudio)))
+	http.HandleFunc("POST /send/sticker", requireAPIKey(shedLoad(sendSticker)))
+	http.HandleFunc("POST /send/contact", requireAPIKey(shedLoad(sendContact)))
+	http.HandleFunc("POST /send/canned/{key}", requireAPIKey(shedLoad(sendCanned)))
+	http.HandleFunc("POST /send/poll", requireAPIKey(shedLoad(sendPoll)))
+	http.HandleFunc("POST /send/buttons", requireAPIKey(shedLoad(sendButtons)))
+	http.HandleFunc("POST /send/list", requireAPIKey(shedLoad(sendList)))
+	http.HandleFunc("POST /send/payment", requireAPIKey(shedLoad(sendPayment)))
+	http.HandleFunc("POST /send/reaction", requireAPIKey(shedLoad(sendReaction)))
+	http.HandleFunc("GET /polls/{id}", getPoll)
+	http.HandleFunc("GET /surveys", requireAPIKey(listSurveys))
+	http.HandleFunc("POST /surveys", requireAPIKey(createSurvey))
+	http.HandleFunc("GET /surveys/{id}", requireAPIKey(getSurvey))
+	http.HandleFunc("DELETE /surveys/{id}", requireAPIKey(deleteSurvey))
+	http.HandleFunc("POST /surveys/{id}/send", requireAPIKey(shedLoad(sendSurvey)))
+	http.HandleFunc("GET /surveys/{id}/responses", requireAPIKey(listSurveyResponses))
+	http.HandleFunc("GET /schedules", requireAPIKey(listSchedules))
+	http.HandleFunc("POST /schedules", requireAPIKey(createSchedule))
+	http.HandleFunc("GET /schedules/{id}", requireAPIKey(getSchedule))
+	http.HandleFunc("POST /schedules/{id}/pause", requireAPIKey(pauseSchedule))
+	http.HandleFunc("POST /schedules/{id}/resume", requireAPIKey(resumeSchedule))
+	http.HandleFunc("DELETE /schedules/{id}", requireAPIKey(deleteSchedule))
+	http.HandleFunc("GET /locations/live", requireAPIKey(listLiveLocations))
+	http.HandleFunc("GET /locations/live/{jid}", requireAPIKey(getLiveLocation))
+	http.HandleFunc("GET /lid/{jid}", getLIDMapping)
+	http.HandleFunc("POST /groups", requireAPIKey(createGroupFromSegment))
+	http.HandleFunc("GET /groups/{jid}", getGroupInfo)
+	http.HandleFunc("GET /groups/{jid}/creation", getGroupCreation)
+	http.HandleFunc("POST /groups/{jid}/participants", requireAPIKey(updateGroupParticipants))
+	http.HandleFunc("GET /groups/{jid}/audit", getGroupAudit)
+	http.HandleFunc("GET /groups/{jid}/moderation", getGroupModeration)
+	http.HandleFunc("PUT /groups/{jid}/moderation", requireAPIKey(putGroupModeration))
+	http.HandleFunc("DELETE /groups/{jid}/moderation", requireAPIKey(deleteGroupModeration))
+	http.HandleFunc("GET /groups/{jid}/moderation/offenses", requireAPIKey(listModerationOffenses))
+	http.HandleFunc("DELETE /groups/{jid}/moderation/offenses/{participant}", requireAPIKey(pardonParticipant))
+	http.HandleFunc("GET /groups/{jid}/join-requests/rules", getJoinRequestRules)
+	http.HandleFunc("PUT /groups/{jid}/join-requests/rules", requireAPIKey(putJoinRequestRules))
+	http.HandleFunc("DELETE /groups/{jid}/join-requests/rules", requireAPIKey(deleteJoinRequestRules))
+	http.HandleFunc("GET /groups/{jid}/join-requests/decisions", requireAPIKey(listJoinRequestDecisions))
+	http.HandleFunc("GET /suppressions", requireAPIKey(listSuppressions))
+	http.HandleFunc("POST /suppressions", requireAPIKey(addSuppression))
+	http.HandleFunc("DELETE /suppressions/{phone}", requireAPIKey(deleteSuppression))
+	http.HandleFunc("GET /presence/subscriptions", listPresenceSubscriptions)
+	http.HandleFunc("POST /presence/subscriptions", subscribePresence)
+	http.HandleFunc("DELETE /presence/subscriptions/{jid}", unsubscribePresence)
+	http.HandleFunc("GET /analytics/presence/{jid}", getPresenceAnalytics)
+	http.HandleFunc("GET /analytics/sla", getSLAAnalytics)
+	http.HandleFunc("GET /analytics/sla/conversations", listSLAConversations)
+	http.HandleFunc("GET /analytics/links", listTrackedLinks)
+	http.HandleFunc("GET /analytics/campaigns/{campaign}", getCampaignLinkAnalytics)
+	http.HandleFunc("GET /analytics/surveys/{id}", requireAPIKey(getSurveyAnalytics))
+	http.HandleFunc("GET /l/{token}", followLink)
+	http.HandleFunc("GET /newsletters/{jid}/posts", listNewsletterPostStats)
+	http.HandleFunc("GET /newsletters/{jid}/posts/{id}/stats", getNewsletterPostStats)
+	http.HandleFunc("GET /chats", listChats)
+	http.HandleFunc("PUT /chats/{jid}/assignment", assignChat)
+	http.HandleFunc("PUT /chats/{jid}/status", setChatStatus)
+	http.HandleFunc("GET /chats/{jid}/bot", getBotState)
+	http.HandleFunc("PUT /chats/{jid}/bot", putBotState)
+	http.HandleFunc("GET /chats/{jid}/disappearing", getChatDisappearing)
+	http.HandleFunc("PUT /chats/{jid}/disappearing", requireAPIKey(shedLoad(putChatDisappearing)))
+	http.HandleFunc("GET /chats/{jid}/tags", getChatTags)
+	http.HandleFunc("POST /chats/{jid}/tags", updateChatTags)
+	http.HandleFunc("PUT /chats/{jid}/tags", updateChatTags)
+	http.HandleFunc("DELETE /chats/{jid}/tags/{tag}", deleteChatTag)
+	http.HandleFunc("GET /tags", listTags)
+	http.HandleFunc("GET /chats/{jid}/notes", listChatNotes)
+	http.HandleFunc("POST /chats/{jid}/notes", createNote)
+	http.HandleFunc("GET /chats/{jid}/summary", requireAPIKey(shedLoad(getChatSummary)))
+	http.HandleFunc("POST /chats/{jid}/events/replay", requireAdmin(replayChatEvents))
+	http.HandleFunc("PUT /notes/{id}", updateNote)
+	http.HandleFunc("DELETE /notes/{id}", deleteNote)
+	http.HandleFunc("GET /forward-rules", requireAdmin(listForwardRules))
+	http.HandleFunc("POST /forward-rules", requireAdmin(createForwardRule))
+	http.HandleFunc("PUT /forward-rules/{id}", requireAdmin(updateForwardRule))
+	http.HandleFunc("DELETE /forward-rules/{id}", requireAdmin(deleteForwardRule))
+	http.HandleFunc("GET /messages/search", requireAPIKey(searchMessages))
+	http.HandleFunc("GET /messages/{id}/media", requireAPIKey(getMessageMedia))
+	http.HandleFunc("GET /media/{id}", requireAPIKey(getMedia))
+	http.HandleFunc("POST /messages/{id}/edit", requireAPIKey(shedLoad(editMessage)))
+	http.HandleFunc("POST /messages/{id}/revoke", requireAPIKey(shedLoad(revokeMessage)))
+	http.HandleFunc("GET /contacts", requireAPIKey(listGatewayContacts))
+	http.HandleFunc("POST /contacts/import", requireAPIKey(importContacts))
+	http.HandleFunc("GET /contacts/{jid}", requireAPIKey(getGatewayContactHandler))
+	http.HandleFunc("PUT /contacts/{jid}", requireAPIKey(putGatewayContact))
+	http.HandleFunc("GET /contacts/{jid}/timeline", requireAPIKey(getContactTimeline))
+	http.HandleFunc("GET /contacts/{jid}/engagement", requireAPIKey(getContactEngagement))
+	http.HandleFunc("GET /contacts/{jid}/devices", requireAPIKey(getUserDevices))
+	http.HandleFunc("DELETE /contacts/{jid}", requireAPIKey(deleteGatewayContact))
+	http.HandleFunc("DELETE /contacts/{jid}/attributes/{key}", requireAPIKey(deleteContactAttribute))
+	http.HandleFunc("POST /templates/render", previewTemplate)
+	http.HandleFunc("GET /canned-responses", listCannedResponses)
+	http.HandleFunc("GET /canned-responses/{key}", getCannedResponse)
+	http.HandleFunc("PUT /canned-responses/{key}", requireAPIKey(putCannedResponse))
+	http.HandleFunc("DELETE /canned-responses/{key}", requireAPIKey(deleteCannedResponse))
+	http.HandleFunc("GET /settings/locale", getLocaleSettings)
+	http.HandleFunc("PUT /settings/locale", requireAdmin(putLocaleSettings))
+	http.HandleFunc("GET /settings/auto-read", getAutoReadPolicy)
+	http.HandleFunc("PUT /settings/auto-read", requireAdmin(putAutoReadPolicy))
+	http.HandleFunc("GET /settings/media-download", getMediaDownloadPolicy)
+	http.HandleFunc("PUT /settings/media-download", requireAdmin(putMediaDownloadPolicy))
+	http.HandleFunc("GET /inbox/agents", listInboxAgents)
+	http.HandleFunc("POST /inbox/agents", createInboxAgent)
+	http.HandleFunc("DELETE /inbox/agents/{id}", deleteInboxAgent)
+	http.HandleFunc("GET /queue", requireAdmin(listQueue))
+	http.HandleFunc("POST /queue/pause", requireAdmin(pauseQueue))
+	http.HandleFunc("POST /queue/resume", requireAdmin(resumeQueue))
+	http.HandleFunc("GET /queue/{id}", requireAdmin(getQueueItem))
+	http.HandleFunc("PUT /queue/{id}/priority", requireAdmin(setQueuePriority))
+	http.HandleFunc("DELETE /queue/{id}", requireAdmin(cancelQueueItem))
+	http.HandleFunc("GET /admin/warmup", requireAdmin(getWarmup))
+	http.HandleFunc("POST /admin/warmup", requireAdmin(restartWarmup))
+	http.HandleFunc("DELETE /admin/warmup", requireAdmin(endWarmup))
+	http.HandleFunc("PUT /admin/warmup/override", requireAdmin(putWarmupOverride))
+	http.HandleFunc("DELETE /admin/warmup/override", requireAdmin(deleteWarmupOverride))
+	http.HandleFunc("GET /admin/alerts", requireAdmin(getAlerts))
+	http.HandleFunc("GET /admin/maintenance", requireAdmin(getMaintenance))
+	http.HandleFunc("PUT /admin/maintenance", requireAdmin(putMaintenance))
+	http.HandleFunc("GET /admin/flags", requireAdmin(listFeatureFlags))
+	http.HandleFunc("PUT /admin/flags/{name}", requireAdmin(putFeatureFlag))
+	http.HandleFunc("DELETE /admin/flags/{name}", requireAdmin(deleteFeatureFlag))
+	http.HandleFunc("GET /admin/migrations", requireAdmin(getMigrations))
+	http.HandleFunc("GET /admin/api-keys", requireAdmin(listAPIKeys))
+	http.HandleFunc("POST /admin/api-keys", requireAdmin(createAPIKey))
+	http.HandleFunc("PUT /admin/api-keys/{id}/policy", requireAdmin(putAPIKeyPolicy))
+	http.HandleFunc("DELETE /admin/api-keys/{id}", requireAdmin(deleteAPIKey))
+	http.HandleFunc("GET /admin/content-policy", requireAdmin(getContentPolicy))
+	http.HandleFunc("PUT /admin/content-policy", requireAdmin(putContentPolicy))
+	http.HandleFunc("GET /admin/message-store", requireAdmin(getMessageStoreStatus))
+	http.HandleFunc("GET /admin/media-jobs", requireAdmin(listMediaJobs))
+	http.HandleFunc("GET /admin/quarantine", requireAdmin(listQuarantine))
+	http.HandleFunc("DELETE /admin/quarantine/{id}", requireAdmin(deleteQuarantinedFile))
+	http.HandleFunc("GET /admin/data-subjects/{phone}", requireAdmin(exportDataSubject))
+	http.HandleFunc("DELETE /admin/data-subjects/{phone}", requireAdmin(eraseDataSubject))
+	http.HandleFunc("GET /admin/audit-log", requireAdmin(listAuditLog))
+	http.HandleFunc("GET /admin/appstate/resync", requireAdmin(getAppStateResync))
+	http.HandleFunc("POST /admin/appstate/resync", requireAdmin(startAppStateResync))
+	http.HandleFunc("GET /admin/device", requireAdmin(getDeviceIdentity))
+	http.HandleFunc("PUT /admin/device", requireAdmin(putDeviceIdentity))
+	http.HandleFunc("GET /admin/client-version", requireAdmin(getClientVersion))
+	http.HandleFunc("PUT /admin/client-version", requireAdmin(putClientVersion))
+	http.HandleFunc("GET /admin/backups", requireAdmin(listBackups))
+	http.HandleFunc("POST /admin/backups", requireAdmin(triggerBackup))
+	http.HandleFunc("POST /admin/backups/{name}/restore", requireAdmin(restoreBackup))
+	http.HandleFunc("GET /admin/session", requireAdmin(getSession))
+	http.Handle("GET /console/", consoleHandler())
+	http.HandleFunc("GET /admin/console/state", requireAdmin(getConsoleState))
+	http.HandleFunc("GET /admin/console/qr", requireAdmin(getQR))
+	http.HandleFunc("GET /admin/console/events", requireAdmin(listConsoleEvents))
+	http.HandleFunc("POST /admin/console/send", requireAdmin(shedLoad(sendText)))
+	http.HandleFunc("POST /admin/session/archive", requireAdmin(archiveSession))
+	http.HandleFunc("POST /admin/session/restore", requireAdmin(restoreSession))
+	if adminAPIKey == "" {
+		waLogger.Warnf("ADMIN_API_KEY not set; /admin endpoints are disabled")
+	}
 	waLogger.Infof("Starting internal API server on :8080")
 	if err := http.ListenAndServe(":8080", nil); err != nil {
 		log.Fatalf("API server failed: %v", err)
@@ -302,6 +750,46 @@ func main() {
 		panic(fmt.Errorf("critical error during state restoration: %w", err))
 	}
 
+	if err := applyPendingRestore(); err != nil {
+		panic(fmt.Errorf("critical error during backup restore: %w", err))
+	}
+
+	if err := openGatewayDB(); err != nil {
+		panic(err)
+	}
+	loadMaintenance(context.Background())
+	loadFeatureFlags(context.Background())
+	loadQueuePause(context.Background())
+	loadWarmup(context.Background())
+	loadSessionState(context.Background())
+	loadAutoReadPolicy(context.Background())
+	loadMediaDownloadPolicy(context.Background())
+	loadLocaleSettings(context.Background())
+	loadContentPolicy(context.Background())
+	loadMessageStoreKey(context.Background())
+	activeTranslator = newTranslator(envString("TRANSLATE_PROVIDER", ""))
+	activeTranscriber = newTranscriber(envString("TRANSCRIBE_PROVIDER", ""))
+	activeImageAnalyzer = newImageAnalyzer(envString("IMAGE_ANALYSIS_PROVIDER", ""))
+	activeVirusScanner = newVirusScanner(envString("SCAN_PROVIDER", ""))
+	activeSummarizer = newSummarizer(envString("SUMMARY_PROVIDER", ""))
+	activeTranscoder = newTranscoder(envString("TRANSCODE_PROVIDER", ""))
+	activeBackupStore = newBackupStore(envString("BACKUP_TARGET", ""))
+	applyDeviceIdentity(configuredDeviceIdentity(context.Background()))
+	applyClientVersion(context.Background())
+	go resumeIdleBots()
+	go runSLAMonitor()
+	go runAlertEvaluator()
+	go runOutboundDispatcher()
+	go runWebhookFlusher()
+	go runWebhookJournalPruner()
+	go runMediaPruner()
+	go runMediaJobPruner()
+	runEnrichmentWorkers()
+	go pollNewsletterStats()
+	go runScheduler()
+	go runJoinRequestPoller()
+	go runBackups()
+
 	ctx := context.Background()
 	container, err := sqlstore.New(ctx, "sqlite3", fmt.Sprintf("file:%s?_foreign_keys=on", dbPath), dbLog)
 	if err != nil {
@@ -314,6 +802,9 @@ func main() {
 
 	client = whatsmeow.NewClient(deviceStore, waLogger)
 	client.AddEventHandler(eventHandler)
+	if err := configureConnectionRoute(client); err != nil {
+		panic(err)
+	}
 
 	go startAPIServer()
 
@@ -323,24 +814,8 @@ func main() {
 		if err != nil {
 			panic(err)
 		}
-		for evt := range qrChan {
-			if evt.Event == "code" {
-				qrCodeMutex.Lock()
-				qrCodeStr = evt.Code
-				qrCodeMutex.Unlock()
-				// Also print to console for debugging
-				qr, _ := qrcode.New(evt.Code, qrcode.Medium)
-				fmt.Println("QR code:\n" + qr.ToString(true))
-			} else {
-				waLogger.Infof("Login event: %s", evt.Event)
-				if evt.Event == "success" {
-					qrCodeMutex.Lock()
-					qrCodeStr = "" // Clear QR code after login
-					qrCodeMutex.Unlock()
-				}
-			}
-		}
-	} else {
+		watchQRChannel(qrChan)
+	} else if !sessionArchived() {
 		err = client.Connect()
 		if err != nil {
 			panic(err)
@@ -355,4 +830,4 @@ func main() {
 	uploadStateSnapshot()
 	client.Disconnect()
 	waLogger.Infof("Disconnected. Goodbye.")
-}
\ No newline at end of file
+}
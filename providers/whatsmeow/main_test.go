@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// request sends a request through mux, which parses the path values as the
+// server does, and returns the response.
+func request(t *testing.T, mux http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	var r io.Reader
+	if body != "" {
+		r = strings.NewReader(body)
+	}
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(method, target, r))
+	return w
+}
+
+type capturedWebhook struct {
+	Event string          `json:"event"`
+	Data  json.RawMessage `json:"data"`
+}
+
+// captureWebhooks points WEBHOOK_URL at a receiver for the duration of a
+// test and returns the webhooks delivered to it.
+func captureWebhooks(t *testing.T) <-chan capturedWebhook {
+	t.Helper()
+	received := make(chan capturedWebhook, 64)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var hook capturedWebhook
+		if err := json.NewDecoder(r.Body).Decode(&hook); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		received <- hook
+	}))
+	t.Cleanup(srv.Close)
+	t.Setenv("WEBHOOK_URL", srv.URL)
+	return received
+}
+
+// nextWebhook waits for the next webhook delivery.
+func nextWebhook(t *testing.T, received <-chan capturedWebhook) capturedWebhook {
+	t.Helper()
+	select {
+	case hook := <-received:
+		return hook
+	case <-time.After(5 * time.Second):
+		t.Fatal("no webhook was delivered")
+		return capturedWebhook{}
+	}
+}
+
+func TestRequireAdmin(t *testing.T) {
+	defer func(key string) { adminAPIKey = key }(adminAPIKey)
+	ok := requireAdmin(func(w http.ResponseWriter, r *http.Request) {})
+	for _, tc := range []struct {
+		name, configured, sent string
+		want                   int
+	}{
+		{"unconfigured", "", "", http.StatusServiceUnavailable},
+		{"unconfigured with a key", "", "anything", http.StatusServiceUnavailable},
+		{"missing key", "secret", "", http.StatusUnauthorized},
+		{"wrong key", "secret", "guess", http.StatusUnauthorized},
+		{"right key", "secret", "secret", http.StatusOK},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			adminAPIKey = tc.configured
+			r := httptest.NewRequest("GET", "/admin/session", nil)
+			if tc.sent != "" {
+				r.Header.Set("X-Admin-Key", tc.sent)
+			}
+			w := httptest.NewRecorder()
+			ok(w, r)
+			if w.Code != tc.want {
+				t.Errorf("status = %d, want %d", w.Code, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Maintenance mode holds outbound dispatch and webhook delivery while the
+// WhatsApp connection stays up. Sends are queued to outbound_queue and
+// webhooks to webhook_queue; both drain in order once it's switched off.
+
+var (
+	maintenanceMu    sync.RWMutex
+	maintenanceSince time.Time // zero when off
+)
+
+func maintenanceActive() bool {
+	maintenanceMu.RLock()
+	defer maintenanceMu.RUnlock()
+	return !maintenanceSince.IsZero()
+}
+
+// loadMaintenance restores the persisted maintenance state so it survives
+// restarts and snapshot migrations.
+func loadMaintenance(ctx context.Context) {
+	since, _ := strconv.ParseInt(getSetting(ctx, "maintenance_since", "0"), 10, 64)
+	maintenanceMu.Lock()
+	if since > 0 {
+		maintenanceSince = time.Unix(since, 0)
+	}
+	maintenanceMu.Unlock()
+	if since > 0 {
+		waLogger.Warnf("Maintenance mode is on since %s; outbound messages and webhooks are buffered", time.Unix(since, 0))
+	}
+}
+
+func setMaintenance(ctx context.Context, enabled bool) error {
+	maintenanceMu.Lock()
+	defer maintenanceMu.Unlock()
+	if enabled == !maintenanceSince.IsZero() {
+		return nil
+	}
+	since := time.Time{}
+	value := "0"
+	if enabled {
+		since = time.Now()
+		value = strconv.FormatInt(since.Unix(), 10)
+	}
+	if err := setSetting(ctx, "maintenance_since", value); err != nil {
+		return err
+	}
+	maintenanceSince = since
+	return nil
+}
+
+func bufferWebhook(url string, body []byte) {
+	_, err := gatewayDB.Exec(`INSERT INTO webhook_queue (url, payload, created_at) VALUES (?, ?, ?)`,
+		url, body, time.Now().Unix())
+	if err != nil {
+		waLogger.Errorf("Failed to buffer webhook: %v", err)
+	}
+}
+
+var webhookFlushMu sync.Mutex
+
+// flushWebhookQueue delivers buffered webhooks oldest first, stopping at the
+// first failure so ordering is preserved.
+func flushWebhookQueue() {
+	webhookFlushMu.Lock()
+	defer webhookFlushMu.Unlock()
+	for !maintenanceActive() {
+		var id int64
+		var url string
+		var body []byte
+		err := gatewayDB.QueryRow(`SELECT id, url, payload FROM webhook_queue ORDER BY id LIMIT 1`).Scan(&id, &url, &body)
+		if err != nil {
+			return // sql.ErrNoRows when drained
+		}
+		if err := postWebhook(url, body); err != nil {
+			waLogger.Warnf("Failed to deliver buffered webhook %d: %v", id, err)
+			gatewayDB.Exec(`UPDATE webhook_queue SET attempts = attempts + 1 WHERE id = ?`, id)
+			return
+		}
+		gatewayDB.Exec(`DELETE FROM webhook_queue WHERE id = ?`, id)
+	}
+}
+
+// runWebhookFlusher retries buffered webhooks left over from a failed flush
+// or a restart.
+func runWebhookFlusher() {
+	for range time.Tick(30 * time.Second) {
+		flushWebhookQueue()
+	}
+}
+
+func queuedWebhookCount() int64 {
+	var n int64
+	if err := gatewayDB.QueryRow(`SELECT COUNT(*) FROM webhook_queue`).Scan(&n); err != nil {
+		waLogger.Errorf("Failed to count buffered webhooks: %v", err)
+	}
+	return n
+}
+
+type maintenanceRequest struct {
+	Enabled *bool `json:"enabled"`
+}
+
+func maintenanceState() map[string]interface{} {
+	maintenanceMu.RLock()
+	since := maintenanceSince
+	maintenanceMu.RUnlock()
+	state := map[string]interface{}{
+		"enabled":         !since.IsZero(),
+		"queued_outbound": queuedOutboundCount(),
+		"queued_webhooks": queuedWebhookCount(),
+	}
+	if !since.IsZero() {
+		state["since"] = since
+	}
+	return state
+}
+
+func getMaintenance(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, maintenanceState())
+}
+
+func putMaintenance(w http.ResponseWriter, r *http.Request) {
+	var req maintenanceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Enabled == nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := setMaintenance(r.Context(), *req.Enabled); err != nil {
+		waLogger.Errorf("Failed to set maintenance mode: %v", err)
+		http.Error(w, "Failed to set maintenance mode", http.StatusInternalServerError)
+		return
+	}
+	if *req.Enabled {
+		waLogger.Warnf("Maintenance mode enabled")
+	} else {
+		waLogger.Infof("Maintenance mode disabled; draining buffered messages and webhooks")
+		go flushWebhookQueue()
+		wakeDispatcher()
+	}
+	writeJSON(w, http.StatusOK, maintenanceState())
+}
@@ -0,0 +1,629 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image/gif"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"google.golang.org/protobuf/proto"
+)
+
+// Media sends upload the file to WhatsApp's media servers with client.Upload
+// and send a message referencing it. Files are accepted as multipart uploads,
+// base64 in a JSON body, or by URL (see mediaurl.go).
+//
+// Videos with gif_playback loop silently inline like GIFs; a GIF given to
+// /send/video is converted to MP4 with ffmpeg and sent that way.
+
+// WhatsApp rejects videos over 16 MB in chats (larger files have to go as
+// documents), and the preview thumbnail is inlined in the message so it must
+// stay small; without one a thumbnail is generated (see thumbnail.go).
+// Documents may be up to 2 GB; uploads are spooled to disk (see spool.go),
+// but the gateway's default is lower.
+var (
+	videoMaxBytes     = int64(envInt("VIDEO_MAX_BYTES", 16<<20))
+	documentMaxBytes  = int64(envInt("DOCUMENT_MAX_BYTES", 100<<20))
+	thumbnailMaxBytes = 64 << 10
+)
+
+var (
+	errMediaTooLarge = errors.New("file is too large")
+	errMediaUpload   = errors.New("media upload failed")
+)
+
+// mediaUpload is a file to send with its send options.
+type mediaUpload struct {
+	To             string
+	Caption        string
+	File           *spooledFile
+	FileName       string
+	Mimetype       string // as given by the client, may be empty
+	Thumbnail      []byte
+	AllowDuplicate bool
+	Translate      *bool
+	GifPlayback    bool
+	ViewOnce       bool
+	Expiration     *uint32
+}
+
+type mediaJSONRequest struct {
+	To             string `json:"to"`
+	Caption        string `json:"caption"`
+	Data           string `json:"data"`          // base64
+	URL            string `json:"url,omitempty"` // instead of data
+	FileName       string `json:"filename,omitempty"`
+	Mimetype       string `json:"mimetype,omitempty"`
+	Thumbnail      string `json:"thumbnail,omitempty"` // base64 JPEG
+	AllowDuplicate bool   `json:"allow_duplicate,omitempty"`
+	Translate      *bool  `json:"translate,omitempty"`
+	GifPlayback    bool   `json:"gif_playback,omitempty"`
+	ViewOnce       bool   `json:"view_once,omitempty"`
+
+	EphemeralExpiration string `json:"ephemeral_expiration,omitempty"`
+}
+
+// readMediaUpload reads a media send request, spooling the file. field names
+// the file part of a multipart upload. Multipart uploads and URLs are
+// streamed; a JSON body is decoded whole, so base64 suits small files only.
+func readMediaUpload(w http.ResponseWriter, r *http.Request, field string, maxBytes int64) (up mediaUpload, err error) {
+	defer func() {
+		if err != nil {
+			up.File.Remove()
+		}
+	}()
+	// Base64 takes a third more room, plus some slack for the other fields.
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes*4/3+int64(thumbnailMaxBytes)*2+64<<10)
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		err = readMultipartUpload(r, &up, field, maxBytes)
+		return up, err
+	}
+
+	var req mediaJSONRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return up, mediaReadError(err)
+	}
+	up = mediaUpload{
+		To:             req.To,
+		Caption:        req.Caption,
+		FileName:       req.FileName,
+		Mimetype:       req.Mimetype,
+		AllowDuplicate: req.AllowDuplicate,
+		Translate:      req.Translate,
+		GifPlayback:    req.GifPlayback,
+		ViewOnce:       req.ViewOnce,
+	}
+	if up.Expiration, err = parseEphemeralExpiration(req.EphemeralExpiration); err != nil {
+		return up, err
+	}
+	if req.URL != "" {
+		if req.Data != "" {
+			return up, fmt.Errorf("give either data or url, not both")
+		}
+		if err := up.fetchURL(r.Context(), req.URL, maxBytes); err != nil {
+			return up, err
+		}
+	} else {
+		up.File, err = spoolReader(base64.NewDecoder(base64.StdEncoding, strings.NewReader(req.Data)), maxBytes, "file")
+		var corrupt base64.CorruptInputError
+		if errors.As(err, &corrupt) || (err == nil && up.File.Size == 0) {
+			return up, fmt.Errorf("data must be the base64-encoded file, or url its address")
+		} else if err != nil {
+			return up, err
+		}
+	}
+	if req.Thumbnail != "" {
+		if up.Thumbnail, err = base64.StdEncoding.DecodeString(req.Thumbnail); err != nil {
+			return up, fmt.Errorf("thumbnail must be a base64-encoded JPEG")
+		}
+	}
+	if len(up.Thumbnail) > thumbnailMaxBytes {
+		return up, mediaTooLarge(fmt.Sprintf("thumbnail is too large: over %d bytes", thumbnailMaxBytes), int64(len(up.Thumbnail)), int64(thumbnailMaxBytes))
+	}
+	return up, validateMediaUpload(&up, maxBytes)
+}
+
+// readMultipartUpload reads a multipart media send part by part, streaming
+// the file to the spool rather than parsing the whole form first.
+func readMultipartUpload(r *http.Request, up *mediaUpload, field string, maxBytes int64) error {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return mediaReadError(err)
+	}
+	values := url.Values{}
+	var partName, partType string
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return mediaReadError(err)
+		}
+		name := part.FormName()
+		switch {
+		case name == field && part.FileName() != "" && up.File == nil:
+			if up.File, err = spoolReader(part, maxBytes, field); err != nil {
+				return mediaReadError(err)
+			}
+			partName, partType = part.FileName(), part.Header.Get("Content-Type")
+		case name == "thumbnail":
+			if up.Thumbnail, err = io.ReadAll(io.LimitReader(part, int64(thumbnailMaxBytes)+1)); err != nil {
+				return mediaReadError(err)
+			}
+			if len(up.Thumbnail) > thumbnailMaxBytes {
+				return mediaTooLarge(fmt.Sprintf("thumbnail is too large: over %d bytes", thumbnailMaxBytes), 0, int64(thumbnailMaxBytes))
+			}
+		case name != "":
+			value, err := io.ReadAll(io.LimitReader(part, 64<<10))
+			if err != nil {
+				return mediaReadError(err)
+			}
+			values.Set(name, string(value))
+		}
+		part.Close()
+	}
+
+	up.To = values.Get("to")
+	up.Caption = values.Get("caption")
+	up.FileName = values.Get("filename")
+	up.Mimetype = values.Get("mimetype")
+	up.AllowDuplicate, _ = strconv.ParseBool(values.Get("allow_duplicate"))
+	up.GifPlayback, _ = strconv.ParseBool(values.Get("gif_playback"))
+	up.ViewOnce, _ = strconv.ParseBool(values.Get("view_once"))
+	if up.Expiration, err = parseEphemeralExpiration(values.Get("ephemeral_expiration")); err != nil {
+		return err
+	}
+	if v := values.Get("translate"); v != "" {
+		t, _ := strconv.ParseBool(v)
+		up.Translate = &t
+	}
+	if up.File != nil {
+		if up.FileName == "" {
+			up.FileName = partName
+		}
+		if up.Mimetype == "" {
+			up.Mimetype = partType
+		}
+	} else if u := values.Get("url"); u != "" {
+		if err := up.fetchURL(r.Context(), u, maxBytes); err != nil {
+			return err
+		}
+	} else {
+		return fmt.Errorf("%s file or url is required", field)
+	}
+	return validateMediaUpload(up, maxBytes)
+}
+
+func mediaReadError(err error) error {
+	var tooLarge *http.MaxBytesError
+	var rejected *mediaRejection
+	switch {
+	case errors.As(err, &rejected):
+		return err
+	case errors.As(err, &tooLarge):
+		return mediaTooLarge(fmt.Sprintf("request body is too large: over %d bytes", tooLarge.Limit), 0, tooLarge.Limit)
+	}
+	return fmt.Errorf("invalid request body")
+}
+
+// mp4Info is what the gateway reads from an MP4 container for the message
+// metadata; zero values mean unknown.
+type mp4Info struct {
+	Seconds       uint32
+	Width, Height uint32
+	Codecs        []string // sample entry types of the video and audio tracks, e.g. avc1 or mp4a
+}
+
+// mp4Boxes walks the boxes in data, calling fn with each box type and body.
+func mp4Boxes(data []byte, fn func(typ string, body []byte)) {
+	for len(data) >= 8 {
+		size := uint64(binary.BigEndian.Uint32(data))
+		typ := string(data[4:8])
+		header := uint64(8)
+		switch size {
+		case 0:
+			size = uint64(len(data))
+		case 1:
+			if len(data) < 16 {
+				return
+			}
+			size, header = binary.BigEndian.Uint64(data[8:]), 16
+		}
+		if size < header || size > uint64(len(data)) {
+			return
+		}
+		fn(typ, data[header:size])
+		data = data[size:]
+	}
+}
+
+// maxMoovBytes bounds the movie box read into memory; it holds the sample
+// tables, which for even long videos take a few megabytes.
+const maxMoovBytes = 64 << 20
+
+// parseMP4 reads the duration from the movie header and the dimensions from
+// the first track with any. Only the movie box is read, wherever it is in
+// the file.
+func parseMP4(path string) mp4Info {
+	var info mp4Info
+	f, err := os.Open(path)
+	if err != nil {
+		return info
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return info
+	}
+	var header [16]byte
+	for offset, end := int64(0), st.Size(); offset+8 <= end; {
+		if _, err := f.ReadAt(header[:8], offset); err != nil {
+			return info
+		}
+		size, headerSize := int64(binary.BigEndian.Uint32(header[:])), int64(8)
+		switch size {
+		case 0:
+			size = end - offset
+		case 1:
+			if _, err := f.ReadAt(header[8:], offset+8); err != nil {
+				return info
+			}
+			size, headerSize = int64(binary.BigEndian.Uint64(header[8:])), 16
+		}
+		if size < headerSize || size > end-offset {
+			return info
+		}
+		if string(header[4:8]) == "moov" {
+			if size-headerSize > maxMoovBytes {
+				return info
+			}
+			moov := make([]byte, size-headerSize)
+			if _, err := f.ReadAt(moov, offset+headerSize); err != nil {
+				return info
+			}
+			return parseMoov(moov)
+		}
+		offset += size
+	}
+	return info
+}
+
+// parseMoov reads the movie box.
+func parseMoov(moov []byte) mp4Info {
+	var info mp4Info
+	mp4Boxes(moov, func(typ string, body []byte) {
+		switch typ {
+		case "mvhd":
+			var timescale, duration uint64
+			switch {
+			case len(body) >= 32 && body[0] == 1:
+				timescale, duration = uint64(binary.BigEndian.Uint32(body[20:])), binary.BigEndian.Uint64(body[24:])
+			case len(body) >= 20:
+				timescale, duration = uint64(binary.BigEndian.Uint32(body[12:])), uint64(binary.BigEndian.Uint32(body[16:]))
+			}
+			if timescale > 0 {
+				info.Seconds = uint32((duration + timescale/2) / timescale)
+			}
+		case "trak":
+			mp4Boxes(body, func(typ string, box []byte) {
+				switch typ {
+				case "mdia":
+					info.Codecs = append(info.Codecs, mp4TrackCodecs(box)...)
+				case "tkhd":
+					if info.Width != 0 || len(box) < 84 {
+						return
+					}
+					offset := 76
+					if box[0] == 1 {
+						offset = 88
+					}
+					if len(box) >= offset+8 {
+						// 16.16 fixed point
+						info.Width = binary.BigEndian.Uint32(box[offset:]) >> 16
+						info.Height = binary.BigEndian.Uint32(box[offset+4:]) >> 16
+					}
+				}
+			})
+		}
+	})
+	return info
+}
+
+// isMP4 checks for the ftyp box that starts every MP4 (and 3GP) file.
+func isMP4(data []byte) bool {
+	return len(data) >= 12 && bytes.Equal(data[4:8], []byte("ftyp"))
+}
+
+// convertGIFToVideo converts a GIF to an H.264 MP4 and returns it spooled,
+// with the GIF's length, which the fragmented MP4 (ffmpeg can't seek back in
+// a pipe to write a regular one) doesn't say. GIFs are held to the video
+// limit, so the GIF is read whole.
+func convertGIFToVideo(ctx context.Context, in *spooledFile) (*spooledFile, uint32, error) {
+	data, err := in.ReadAll()
+	if err != nil {
+		return nil, 0, err
+	}
+	var seconds uint32
+	if g, err := gif.DecodeAll(bytes.NewReader(data)); err == nil {
+		var delay int // hundredths of a second
+		for _, d := range g.Delay {
+			delay += d
+		}
+		seconds = uint32((delay + 50) / 100)
+	}
+	// H.264 in 4:2:0 needs even dimensions.
+	out, err := runFFmpegFile(ctx, in.Path,
+		"-an", "-vf", "scale=trunc(iw/2)*2:trunc(ih/2)*2,format=yuv420p",
+		"-c:v", "libx264", "-profile:v", "baseline", "-preset", "veryfast", "-crf", "23",
+		"-movflags", "frag_keyframe+empty_moov+default_base_moof", "-f", "mp4")
+	if err != nil {
+		return nil, 0, err
+	}
+	if !isMP4(out) {
+		return nil, 0, fmt.Errorf("ffmpeg produced no MP4")
+	}
+	video, err := spoolBytes(out)
+	return video, seconds, err
+}
+
+// mp4TrackCodecs returns the sample entry types of a video or audio track's
+// media box.
+func mp4TrackCodecs(mdia []byte) []string {
+	var handler string
+	var codecs []string
+	mp4Boxes(mdia, func(typ string, body []byte) {
+		switch typ {
+		case "hdlr":
+			if len(body) >= 12 {
+				handler = string(body[8:12])
+			}
+		case "minf":
+			mp4Boxes(body, func(typ string, stbl []byte) {
+				if typ != "stbl" {
+					return
+				}
+				mp4Boxes(stbl, func(typ string, stsd []byte) {
+					// Version, flags and entry count come before the entries.
+					if typ == "stsd" && len(stsd) >= 8 {
+						mp4Boxes(stsd[8:], func(entry string, _ []byte) {
+							codecs = append(codecs, entry)
+						})
+					}
+				})
+			})
+		}
+	})
+	if handler != "vide" && handler != "soun" {
+		return nil
+	}
+	return codecs
+}
+
+// writeMediaError reports a failed media request.
+func writeMediaError(w http.ResponseWriter, err error) {
+	var rejected *mediaRejection
+	switch {
+	case errors.As(err, &rejected):
+		writeJSON(w, rejected.status(), rejected)
+	case errors.Is(err, errMediaURL):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
+
+func sendVideo(w http.ResponseWriter, r *http.Request) {
+	if sessionArchived() {
+		http.Error(w, "Session is archived", http.StatusConflict)
+		return
+	}
+	if !requireClient(w, r, false) {
+		return
+	}
+	up, err := readMediaUpload(w, r, "video", videoMaxBytes)
+	if err != nil {
+		writeMediaError(w, err)
+		return
+	}
+	defer func() { up.File.Remove() }()
+	var gifSeconds uint32
+	if http.DetectContentType(up.File.Head) == "image/gif" {
+		// WhatsApp has no GIF messages: GIFs are sent as MP4s that loop.
+		video, seconds, err := convertGIFToVideo(r.Context(), up.File)
+		if errors.Is(err, errUnsupportedMedia) {
+			writeMediaError(w, unsupportedMedia("Unsupported GIF"))
+			return
+		} else if err != nil {
+			waLogger.Errorf("Failed to convert GIF: %v", err)
+			http.Error(w, "Failed to convert GIF", http.StatusInternalServerError)
+			return
+		}
+		up.File.Remove()
+		up.File, gifSeconds = video, seconds
+		if up.File.Size > videoMaxBytes {
+			writeMediaError(w, mediaTooLarge(fmt.Sprintf("converted GIF is too large: %d bytes, at most %d allowed", up.File.Size, videoMaxBytes), up.File.Size, videoMaxBytes))
+			return
+		}
+		up.GifPlayback = true
+	}
+	if activeTranscoder != nil && videoNeedsTranscoding(up.File) {
+		video, err := transcode(r.Context(), up.File, up.Mimetype, "video/mp4", videoMaxBytes)
+		var rejected *mediaRejection
+		if errors.Is(err, errUnsupportedMedia) {
+			writeMediaError(w, unsupportedMedia("Unsupported video format"))
+			return
+		} else if errors.As(err, &rejected) {
+			writeMediaError(w, err)
+			return
+		} else if err != nil {
+			waLogger.Errorf("Failed to transcode video: %v", err)
+			http.Error(w, "Failed to transcode video", http.StatusInternalServerError)
+			return
+		}
+		up.File.Remove()
+		up.File = video
+	}
+	if !isMP4(up.File.Head) {
+		writeMediaError(w, unsupportedMedia("Video must be an MP4 file or a GIF"))
+		return
+	}
+	if len(up.Thumbnail) > 0 && http.DetectContentType(up.Thumbnail) != "image/jpeg" {
+		writeMediaError(w, unsupportedMedia("Thumbnail must be a JPEG image"))
+		return
+	}
+	recipient, err := parseRecipient(up.To)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	recipient = toPhoneJID(r.Context(), recipient)
+	// Check the policy before spending time on the upload.
+	if err := checkSendPolicy(r.Context(), recipient); err != nil {
+		writeSendError(w, recipient, err)
+		return
+	}
+
+	if len(up.Thumbnail) == 0 {
+		if up.Thumbnail, err = videoThumbnail(r.Context(), up.File.Path); err != nil {
+			waLogger.Warnf("Failed to make video thumbnail for %s: %v", recipient, err)
+		}
+	}
+
+	info := parseMP4(up.File.Path)
+	video := &waE2E.VideoMessage{
+		FileLength:    proto.Uint64(uint64(up.File.Size)),
+		Mimetype:      proto.String("video/mp4"),
+		JPEGThumbnail: up.Thumbnail,
+	}
+	if up.Caption != "" {
+		video.Caption = proto.String(up.Caption)
+	}
+	if up.GifPlayback {
+		video.GifPlayback = proto.Bool(true)
+	}
+	if up.ViewOnce {
+		video.ViewOnce = proto.Bool(true)
+	}
+	if info.Seconds == 0 {
+		info.Seconds = gifSeconds
+	}
+	if info.Seconds > 0 {
+		video.Seconds = proto.Uint32(info.Seconds)
+	}
+	if info.Width > 0 && info.Height > 0 {
+		video.Width, video.Height = proto.Uint32(info.Width), proto.Uint32(info.Height)
+	}
+
+	opts := sendOptions{AllowDuplicate: up.AllowDuplicate, Translate: translateOutbound, Expiration: up.Expiration}
+	if up.Translate != nil {
+		opts.Translate = *up.Translate
+	}
+	res, err := sendMedia(r.Context(), recipient, up.File, &waE2E.Message{VideoMessage: video}, opts)
+	if errors.Is(err, errMediaUpload) {
+		waLogger.Errorf("Failed to upload video for %s: %v", recipient, err)
+		http.Error(w, "Failed to upload video", http.StatusBadGateway)
+		return
+	} else if err != nil {
+		writeSendError(w, recipient, err)
+		return
+	}
+	writeSendResult(w, recipient, res)
+}
+
+// documentMimetype picks the MIME type of a document: the client's, else one
+// from the file extension, else sniffed from the content.
+func documentMimetype(up mediaUpload) string {
+	if mt, _, err := mime.ParseMediaType(up.Mimetype); err == nil && mt != "application/octet-stream" {
+		return mt
+	}
+	if mt := mime.TypeByExtension(filepath.Ext(up.FileName)); mt != "" {
+		mt, _, _ = mime.ParseMediaType(mt)
+		return mt
+	}
+	mt, _, _ := mime.ParseMediaType(http.DetectContentType(up.File.Head))
+	return mt
+}
+
+func sendDocument(w http.ResponseWriter, r *http.Request) {
+	if sessionArchived() {
+		http.Error(w, "Session is archived", http.StatusConflict)
+		return
+	}
+	if !requireClient(w, r, false) {
+		return
+	}
+	up, err := readMediaUpload(w, r, "document", documentMaxBytes)
+	if err != nil {
+		writeMediaError(w, err)
+		return
+	}
+	defer up.File.Remove()
+	if len(up.Thumbnail) > 0 && http.DetectContentType(up.Thumbnail) != "image/jpeg" {
+		writeMediaError(w, unsupportedMedia("Thumbnail must be a JPEG image"))
+		return
+	}
+	// Recipients see the file name, so keep only the base name.
+	up.FileName = filepath.Base(filepath.Clean("/" + strings.ReplaceAll(up.FileName, "\\", "/")))
+	if up.FileName == "/" || up.FileName == "." {
+		up.FileName = "document"
+	}
+	recipient, err := parseRecipient(up.To)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	recipient = toPhoneJID(r.Context(), recipient)
+	if err := checkSendPolicy(r.Context(), recipient); err != nil {
+		writeSendError(w, recipient, err)
+		return
+	}
+
+	mimetype := documentMimetype(up)
+	doc := &waE2E.DocumentMessage{
+		FileLength:    proto.Uint64(uint64(up.File.Size)),
+		Mimetype:      proto.String(mimetype),
+		FileName:      proto.String(up.FileName),
+		Title:         proto.String(up.FileName),
+		JPEGThumbnail: up.Thumbnail,
+	}
+	if len(up.Thumbnail) == 0 {
+		thumb, width, height, err := documentThumbnail(r.Context(), up.File.Path, mimetype)
+		if err != nil {
+			waLogger.Warnf("Failed to make document thumbnail for %s: %v", recipient, err)
+		} else if thumb != nil {
+			doc.JPEGThumbnail = thumb
+			doc.ThumbnailWidth, doc.ThumbnailHeight = proto.Uint32(uint32(width)), proto.Uint32(uint32(height))
+		}
+	}
+	if up.Caption != "" {
+		doc.Caption = proto.String(up.Caption)
+	}
+
+	opts := sendOptions{AllowDuplicate: up.AllowDuplicate, Translate: translateOutbound, Expiration: up.Expiration}
+	if up.Translate != nil {
+		opts.Translate = *up.Translate
+	}
+	res, err := sendMedia(r.Context(), recipient, up.File, &waE2E.Message{DocumentMessage: doc}, opts)
+	if errors.Is(err, errMediaUpload) {
+		waLogger.Errorf("Failed to upload document for %s: %v", recipient, err)
+		http.Error(w, "Failed to upload document", http.StatusBadGateway)
+		return
+	} else if err != nil {
+		writeSendError(w, recipient, err)
+		return
+	}
+	writeSendResult(w, recipient, res)
+}
@@ -0,0 +1,454 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	"google.golang.org/protobuf/proto"
+)
+
+// Media transfers can take a while for large files, so each one is tracked
+// in media_jobs until it's done: uploads keep the file spooled to
+// MEDIA_SPOOL_DIR (see spool.go), downloads the message event they belong
+// to. Jobs left behind by
+// a crash are restarted once the client has reconnected and caught up on
+// offline messages. WhatsApp's media servers can't resume a partial transfer,
+// so a restarted job starts over, up to mediaJobMaxAttempts times in all.
+//
+// A recovered upload is sent at least once (twice if the crash came between
+// the send and the job's removal) and, as its caller is gone, reported with
+// the media.sent or media.failed webhook. A recovered download replays the
+// message event, so the message webhook goes out again with its transcript
+// or image analysis.
+//
+// The message and its info are encrypted with the message store key (see
+// storecrypt.go). A failed job keeps neither, only its error, and is pruned
+// after MEDIA_JOB_RETENTION.
+
+const mediaJobMaxAttempts = 3
+
+var (
+	mediaSpoolDir     = envString("MEDIA_SPOOL_DIR", "/app/session/media-spool")
+	mediaJobRetention = envDuration("MEDIA_JOB_RETENTION", 7*24*time.Hour)
+)
+
+// activeMediaJobs are the jobs a transfer in this process is working on;
+// recovery leaves them alone.
+var (
+	activeMediaJobsMu sync.Mutex
+	activeMediaJobs   = map[int64]bool{}
+)
+
+// claimMediaJob marks a job active, reporting false if it already was.
+func claimMediaJob(id int64) bool {
+	activeMediaJobsMu.Lock()
+	defer activeMediaJobsMu.Unlock()
+	if activeMediaJobs[id] {
+		return false
+	}
+	activeMediaJobs[id] = true
+	return true
+}
+
+func releaseMediaJob(id int64) {
+	activeMediaJobsMu.Lock()
+	delete(activeMediaJobs, id)
+	activeMediaJobsMu.Unlock()
+}
+
+// uploadMediaType is the media type to upload the file of msg as.
+func uploadMediaType(msg *waE2E.Message) whatsmeow.MediaType {
+	switch {
+	case msg.GetVideoMessage() != nil:
+		return whatsmeow.MediaVideo
+	case msg.GetAudioMessage() != nil:
+		return whatsmeow.MediaAudio
+	case msg.GetImageMessage() != nil, msg.GetStickerMessage() != nil:
+		return whatsmeow.MediaImage
+	}
+	return whatsmeow.MediaDocument
+}
+
+// setMediaUpload fills the upload's references into the media message of msg.
+func setMediaUpload(msg *waE2E.Message, up whatsmeow.UploadResponse) {
+	switch {
+	case msg.GetVideoMessage() != nil:
+		m := msg.VideoMessage
+		m.URL, m.DirectPath = proto.String(up.URL), proto.String(up.DirectPath)
+		m.MediaKey, m.FileEncSHA256, m.FileSHA256 = up.MediaKey, up.FileEncSHA256, up.FileSHA256
+	case msg.GetAudioMessage() != nil:
+		m := msg.AudioMessage
+		m.URL, m.DirectPath = proto.String(up.URL), proto.String(up.DirectPath)
+		m.MediaKey, m.FileEncSHA256, m.FileSHA256 = up.MediaKey, up.FileEncSHA256, up.FileSHA256
+	case msg.GetImageMessage() != nil:
+		m := msg.ImageMessage
+		m.URL, m.DirectPath = proto.String(up.URL), proto.String(up.DirectPath)
+		m.MediaKey, m.FileEncSHA256, m.FileSHA256 = up.MediaKey, up.FileEncSHA256, up.FileSHA256
+	case msg.GetStickerMessage() != nil:
+		m := msg.StickerMessage
+		m.URL, m.DirectPath = proto.String(up.URL), proto.String(up.DirectPath)
+		m.MediaKey, m.FileEncSHA256, m.FileSHA256 = up.MediaKey, up.FileEncSHA256, up.FileSHA256
+	case msg.GetDocumentMessage() != nil:
+		m := msg.DocumentMessage
+		m.URL, m.DirectPath = proto.String(up.URL), proto.String(up.DirectPath)
+		m.MediaKey, m.FileEncSHA256, m.FileSHA256 = up.MediaKey, up.FileEncSHA256, up.FileSHA256
+	}
+}
+
+// sendMedia uploads the spooled file as the file of msg's media message and
+// sends msg. Upload failures wrap errMediaUpload.
+func sendMedia(ctx context.Context, to types.JID, file *spooledFile, msg *waE2E.Message, opts sendOptions) (sendResult, error) {
+	job := startUploadJob(ctx, to, file, msg, opts)
+	// The caller hears about failures too, so the job is done either way.
+	defer finishMediaJob(context.Background(), job)
+	return uploadAndSend(ctx, to, file, msg, opts)
+}
+
+func uploadAndSend(ctx context.Context, to types.JID, file *spooledFile, msg *waE2E.Message, opts sendOptions) (sendResult, error) {
+	uploaded, err := uploadSpooledFile(ctx, file, uploadMediaType(msg))
+	if err != nil {
+		return sendResult{}, fmt.Errorf("%w: %v", errMediaUpload, err)
+	}
+	setMediaUpload(msg, uploaded)
+	res, err := sendOrQueue(ctx, to, msg, opts)
+	if err == nil {
+		res.MediaID = keepOutboundMedia(ctx, to, res.ID, msg, file)
+	}
+	return res, err
+}
+
+// uploadSpooledFile uploads a file from the spool, encrypting it through a
+// second spool file rather than in memory.
+func uploadSpooledFile(ctx context.Context, file *spooledFile, mediaType whatsmeow.MediaType) (whatsmeow.UploadResponse, error) {
+	in, err := os.Open(file.Path)
+	if err != nil {
+		return whatsmeow.UploadResponse{}, err
+	}
+	defer in.Close()
+	encrypted, err := createSpoolFile("encrypted-*")
+	if err != nil {
+		return whatsmeow.UploadResponse{}, err
+	}
+	defer os.Remove(encrypted.Name())
+	defer encrypted.Close()
+	return client.UploadReader(ctx, in, encrypted, mediaType)
+}
+
+// startUploadJob records the upload, with the spooled file to redo it from.
+// The file then goes with the job. It returns 0 if the job couldn't be
+// recorded, in which case the upload goes ahead untracked.
+func startUploadJob(ctx context.Context, to types.JID, file *spooledFile, msg *waE2E.Message, opts sendOptions) int64 {
+	if gatewayDB == nil {
+		return 0
+	}
+	encoded, err := proto.Marshal(msg)
+	if err != nil {
+		waLogger.Errorf("Failed to marshal media message for %s: %v", to, err)
+		return 0
+	}
+	optsJSON, _ := json.Marshal(opts)
+	now := time.Now().Unix()
+	res, err := gatewayDB.ExecContext(ctx, `
+		INSERT INTO media_jobs (direction, media_type, chat_jid, message, info, spool_path, attempts, created_at, updated_at)
+		VALUES ('upload', ?, ?, ?, ?, ?, 1, ?, ?)`,
+		messageType(msg), to.String(), encryptStoreBytes(encoded), encryptStoreValue(string(optsJSON)), file.Path, now, now)
+	if err != nil {
+		waLogger.Warnf("Failed to record upload for %s, sending untracked: %v", to, err)
+		return 0
+	}
+	id, _ := res.LastInsertId()
+	claimMediaJob(id)
+	return id
+}
+
+// needsDownload reports whether msg's media is downloaded on arrival, for
+// transcription, analysis, scanning or the media download policy.
+func needsDownload(msg *waE2E.Message) bool {
+	return voiceNoteToTranscribe(msg) != nil || imageToAnalyze(msg) != nil || documentToScan(msg) != nil || mediaToStore(msg) != nil
+}
+
+// startDownloadJob records that evt's media is about to be downloaded. It
+// returns 0 when there's nothing to download (or the job couldn't be
+// recorded), and exhausted when earlier attempts at the same message used up
+// its attempts and it should be skipped.
+func startDownloadJob(ctx context.Context, evt *events.Message) (id int64, exhausted bool) {
+	if gatewayDB == nil || !needsDownload(evt.Message) {
+		return 0, false
+	}
+	encoded, err := proto.Marshal(evt.Message)
+	if err != nil {
+		waLogger.Errorf("Failed to marshal message %s: %v", evt.Info.ID, err)
+		return 0, false
+	}
+	info, err := json.Marshal(evt.Info)
+	if err != nil {
+		waLogger.Errorf("Failed to marshal info of message %s: %v", evt.Info.ID, err)
+		return 0, false
+	}
+	now := time.Now().Unix()
+	var attempts int
+	err = gatewayDB.QueryRowContext(ctx, `
+		INSERT INTO media_jobs (direction, media_type, chat_jid, message_id, message, info, attempts, created_at, updated_at)
+		VALUES ('download', ?, ?, ?, ?, ?, 1, ?, ?)
+		ON CONFLICT (chat_jid, message_id) WHERE direction = 'download' DO UPDATE SET
+			attempts = media_jobs.attempts + 1,
+			updated_at = excluded.updated_at
+		RETURNING id, attempts`,
+		messageType(evt.Message), evt.Info.Chat.String(), evt.Info.ID, encryptStoreBytes(encoded), encryptStoreValue(string(info)), now, now).Scan(&id, &attempts)
+	if err != nil {
+		waLogger.Errorf("Failed to record media download of message %s: %v", evt.Info.ID, err)
+		return 0, false
+	}
+	if attempts > mediaJobMaxAttempts {
+		failMediaJob(ctx, id, fmt.Sprintf("gave up after %d attempts", mediaJobMaxAttempts))
+		return 0, true
+	}
+	claimMediaJob(id)
+	return id, false
+}
+
+// finishMediaJob removes a job and its spooled file.
+func finishMediaJob(ctx context.Context, id int64) {
+	if id == 0 {
+		return
+	}
+	defer releaseMediaJob(id)
+	var spool string
+	err := gatewayDB.QueryRowContext(ctx, `DELETE FROM media_jobs WHERE id = ? RETURNING spool_path`, id).Scan(&spool)
+	if err != nil && err != sql.ErrNoRows {
+		waLogger.Errorf("Failed to remove media job %d: %v", id, err)
+		return
+	}
+	if spool != "" {
+		os.Remove(spool)
+	}
+}
+
+// failMediaJob keeps a job that won't be retried for inspection, without its
+// spooled file or message.
+func failMediaJob(ctx context.Context, id int64, reason string) {
+	var spool string
+	if err := gatewayDB.QueryRowContext(ctx, `SELECT spool_path FROM media_jobs WHERE id = ?`, id).Scan(&spool); err != nil {
+		waLogger.Errorf("Failed to load media job %d: %v", id, err)
+		return
+	}
+	_, err := gatewayDB.ExecContext(ctx,
+		`UPDATE media_jobs SET status = 'failed', last_error = ?, message = x'', info = '', spool_path = '', updated_at = ? WHERE id = ?`,
+		reason, time.Now().Unix(), id)
+	if err != nil {
+		waLogger.Errorf("Failed to mark media job %d failed: %v", id, err)
+		return
+	}
+	if spool != "" {
+		os.Remove(spool)
+	}
+}
+
+type mediaJob struct {
+	ID        int64     `json:"id"`
+	Direction string    `json:"direction"`
+	Type      string    `json:"type"`
+	Chat      string    `json:"chat"`
+	MessageID string    `json:"message_id,omitempty"`
+	Status    string    `json:"status"`
+	Attempts  int       `json:"attempts"`
+	LastError string    `json:"last_error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	message []byte
+	info    string
+	spool   string
+}
+
+const mediaJobColumns = `id, direction, media_type, chat_jid, message_id, status, attempts, last_error, created_at, updated_at, message, info, spool_path`
+
+func queryMediaJobs(ctx context.Context, query string, args ...interface{}) ([]mediaJob, error) {
+	rows, err := gatewayDB.QueryContext(ctx, `SELECT `+mediaJobColumns+` FROM media_jobs `+query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	jobs := []mediaJob{}
+	for rows.Next() {
+		var j mediaJob
+		var created, updated int64
+		if err := rows.Scan(&j.ID, &j.Direction, &j.Type, &j.Chat, &j.MessageID, &j.Status, &j.Attempts, &j.LastError,
+			&created, &updated, &j.message, &j.info, &j.spool); err != nil {
+			return nil, err
+		}
+		j.CreatedAt = time.Unix(created, 0).UTC()
+		j.UpdatedAt = time.Unix(updated, 0).UTC()
+		jobs = append(jobs, j)
+	}
+	return jobs, rows.Err()
+}
+
+// resumeMediaJobs restarts the pending jobs no transfer in this process is
+// working on, i.e. those interrupted by a crash or restart, and uploads that
+// failed during an earlier recovery.
+func resumeMediaJobs() {
+	if gatewayDB == nil {
+		return
+	}
+	ctx := context.Background()
+	jobs, err := queryMediaJobs(ctx, `WHERE status = 'pending' ORDER BY id`)
+	if err != nil {
+		waLogger.Errorf("Failed to load pending media jobs: %v", err)
+		return
+	}
+	removeStaleSpoolFiles(jobs)
+	for _, job := range jobs {
+		if !claimMediaJob(job.ID) {
+			continue
+		}
+		data, err := decryptStoreBytes(job.message)
+		if err != nil {
+			failMediaJob(ctx, job.ID, fmt.Sprintf("can't decrypt message: %v", err))
+			releaseMediaJob(job.ID)
+			continue
+		}
+		if job.info, err = decryptStoreValue(job.info); err != nil {
+			failMediaJob(ctx, job.ID, fmt.Sprintf("can't decrypt message info: %v", err))
+			releaseMediaJob(job.ID)
+			continue
+		}
+		msg := &waE2E.Message{}
+		if err := proto.Unmarshal(data, msg); err != nil {
+			failMediaJob(ctx, job.ID, fmt.Sprintf("invalid message: %v", err))
+			releaseMediaJob(job.ID)
+			continue
+		}
+		waLogger.Infof("Resuming interrupted media %s %d (attempt %d)", job.Direction, job.ID, job.Attempts+1)
+		if job.Direction == "download" {
+			// The replayed message's enrichment releases the job.
+			resumeDownload(ctx, job, msg)
+			continue
+		}
+		resumeUpload(ctx, job, msg)
+		releaseMediaJob(job.ID)
+	}
+}
+
+// resumeDownload replays the message event the download belonged to, which
+// counts the attempt and removes the job when done.
+func resumeDownload(ctx context.Context, job mediaJob, msg *waE2E.Message) {
+	var info types.MessageInfo
+	if err := json.Unmarshal([]byte(job.info), &info); err != nil {
+		failMediaJob(ctx, job.ID, fmt.Sprintf("invalid message info: %v", err))
+		releaseMediaJob(job.ID)
+		return
+	}
+	eventHandler(&events.Message{Info: info, Message: msg})
+	// With the downloads since turned off the replay doesn't track the
+	// download, so the job is left to remove here.
+	if !needsDownload(msg) {
+		finishMediaJob(ctx, job.ID)
+	}
+}
+
+// resumeUpload redoes an upload and its send. The send policy was checked
+// when the upload was requested.
+func resumeUpload(ctx context.Context, job mediaJob, msg *waE2E.Message) {
+	fail := func(reason string) {
+		failMediaJob(ctx, job.ID, reason)
+		emitWebhook("media.failed", map[string]interface{}{"job_id": job.ID, "to": job.Chat, "type": job.Type, "error": reason})
+	}
+	to, err := types.ParseJID(job.Chat)
+	if err != nil {
+		fail(fmt.Sprintf("invalid recipient: %v", err))
+		return
+	}
+	var opts sendOptions
+	if err := json.Unmarshal([]byte(job.info), &opts); err != nil {
+		fail(fmt.Sprintf("invalid send options: %v", err))
+		return
+	}
+	st, err := os.Stat(job.spool)
+	if err != nil {
+		fail(fmt.Sprintf("spooled file is gone: %v", err))
+		return
+	}
+	file := &spooledFile{Path: job.spool, Size: st.Size()}
+	attempts := job.Attempts + 1
+	if attempts > mediaJobMaxAttempts {
+		fail(fmt.Sprintf("gave up after %d attempts", mediaJobMaxAttempts))
+		return
+	}
+	_, err = gatewayDB.ExecContext(ctx, `UPDATE media_jobs SET attempts = ?, updated_at = ? WHERE id = ?`,
+		attempts, time.Now().Unix(), job.ID)
+	if err != nil {
+		waLogger.Errorf("Failed to update media job %d: %v", job.ID, err)
+		return
+	}
+	res, err := uploadAndSend(ctx, to, file, msg, opts)
+	if err != nil {
+		waLogger.Errorf("Failed to resume media upload %d: %v", job.ID, err)
+		if attempts >= mediaJobMaxAttempts {
+			fail(err.Error())
+			return
+		}
+		_, err = gatewayDB.ExecContext(ctx, `UPDATE media_jobs SET last_error = ? WHERE id = ?`, err.Error(), job.ID)
+		if err != nil {
+			waLogger.Errorf("Failed to update media job %d: %v", job.ID, err)
+		}
+		return
+	}
+	finishMediaJob(ctx, job.ID)
+	emitWebhook("media.sent", map[string]interface{}{"job_id": job.ID, "to": job.Chat, "type": job.Type, "id": res.ID, "queued": res.Queued, "media_id": res.MediaID})
+}
+
+// runMediaJobPruner removes failed jobs past MEDIA_JOB_RETENTION; finished
+// jobs are removed as they finish.
+func runMediaJobPruner() {
+	if gatewayDB == nil || mediaJobRetention <= 0 {
+		return
+	}
+	for range time.Tick(time.Hour) {
+		_, err := gatewayDB.Exec(`DELETE FROM media_jobs WHERE status = 'failed' AND updated_at < ?`,
+			time.Now().Add(-mediaJobRetention).Unix())
+		if err != nil {
+			waLogger.Errorf("Failed to prune failed media jobs: %v", err)
+		}
+	}
+}
+
+// listMediaJobs lists the tracked media transfers: pending ones and failed
+// ones kept for inspection.
+func listMediaJobs(w http.ResponseWriter, r *http.Request) {
+	query := `WHERE 1 = 1`
+	var args []interface{}
+	if s := r.URL.Query().Get("status"); s != "" {
+		query += ` AND status = ?`
+		args = append(args, s)
+	}
+	limit := 100
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 || n > 1000 {
+			http.Error(w, "Invalid limit, expected 1-1000", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	query += ` ORDER BY id DESC LIMIT ?`
+	args = append(args, limit)
+	jobs, err := queryMediaJobs(r.Context(), query, args...)
+	if err != nil {
+		waLogger.Errorf("Failed to list media jobs: %v", err)
+		http.Error(w, "Failed to list media jobs", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"jobs": jobs})
+}
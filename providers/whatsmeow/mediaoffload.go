@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"mime"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// With MEDIA_S3_BUCKET set, inbound media is offloaded: every file the
+// download policy doesn't skip (up to auto_max_bytes) is downloaded on
+// arrival and put in the bucket, and the message webhook's media carries a
+// presigned URL valid for MEDIA_S3_URL_EXPIRY, so consumers never need to
+// call back into the gateway. Objects are named by their SHA-256 and never
+// deleted by the gateway, which leaves their retention (and erasure) to the
+// bucket's lifecycle rules.
+
+var (
+	mediaOffloadStore  = newMediaOffloadStore()
+	mediaOffloadExpiry = min(envDuration("MEDIA_S3_URL_EXPIRY", 24*time.Hour), 7*24*time.Hour) // SigV4's limit
+)
+
+func newMediaOffloadStore() *s3Store {
+	bucket := envString("MEDIA_S3_BUCKET", "")
+	if bucket == "" {
+		return nil
+	}
+	region := envString("MEDIA_S3_REGION", envString("AWS_REGION", "us-east-1"))
+	return &s3Store{
+		endpoint:  strings.TrimSuffix(envString("MEDIA_S3_ENDPOINT", "https://s3."+region+".amazonaws.com"), "/"),
+		bucket:    bucket,
+		prefix:    envString("MEDIA_S3_PREFIX", "media/"),
+		region:    region,
+		accessKey: envString("MEDIA_S3_ACCESS_KEY_ID", envString("AWS_ACCESS_KEY_ID", "")),
+		secretKey: envString("MEDIA_S3_SECRET_ACCESS_KEY", envString("AWS_SECRET_ACCESS_KEY", "")),
+	}
+}
+
+// offloadWanted reports whether a file of the given type and size goes to
+// the bucket.
+func offloadWanted(p mediaDownloadPolicy, typ string, size uint64) bool {
+	return mediaOffloadStore != nil && featureEnabled(flagAutoDownload) && p.mode(typ, size) != "skip" &&
+		(p.AutoMaxBytes == 0 || size <= uint64(p.AutoMaxBytes))
+}
+
+// offloadMedia streams a spooled file to the bucket and sets the presigned
+// URL on info.
+func offloadMedia(ctx context.Context, info *mediaInfo, file *spooledFile) error {
+	in, err := os.Open(file.Path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	name := hex.EncodeToString(file.SHA256)
+	resp, err := mediaOffloadStore.do(ctx, "PUT", name, nil, in, file.Size)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	// Objects are stored without metadata, so the URL sets the type and name.
+	query := url.Values{}
+	if info.Mimetype != "" {
+		query.Set("response-content-type", info.Mimetype)
+	}
+	if info.FileName != "" {
+		query.Set("response-content-disposition", mime.FormatMediaType("attachment", map[string]string{"filename": info.FileName}))
+	}
+	now := time.Now().UTC()
+	info.URL = mediaOffloadStore.presign(name, query, now, mediaOffloadExpiry)
+	expires := now.Add(mediaOffloadExpiry)
+	info.URLExpiresAt = &expires
+	return nil
+}
+
+// presign returns a presigned GET URL for an object, with query added to it.
+func (s *s3Store) presign(name string, query url.Values, now time.Time, expiry time.Duration) string {
+	u, _ := url.Parse(s.endpoint + "/" + s.bucket + "/" + s.prefix + name)
+	amzDate := now.Format("20060102T150405Z")
+	scope := now.Format("20060102") + "/" + s.region + "/s3/aws4_request"
+	query.Set("X-Amz-Algorithm", "AWS4-HMAC-SHA256")
+	query.Set("X-Amz-Credential", s.accessKey+"/"+scope)
+	query.Set("X-Amz-Date", amzDate)
+	query.Set("X-Amz-Expires", strconv.Itoa(int(expiry/time.Second)))
+	query.Set("X-Amz-SignedHeaders", "host")
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var params []string
+	for _, k := range keys {
+		params = append(params, s3Escape(k)+"="+s3Escape(query.Get(k)))
+	}
+	u.RawQuery = strings.Join(params, "&")
+	canonical := strings.Join([]string{
+		"GET",
+		u.EscapedPath(),
+		u.RawQuery,
+		"host:" + u.Host + "\n",
+		"host",
+		"UNSIGNED-PAYLOAD",
+	}, "\n")
+	hash := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+	u.RawQuery += "&X-Amz-Signature=" + hex.EncodeToString(hmacSHA256(s.signingKey(now), toSign))
+	return u.String()
+}
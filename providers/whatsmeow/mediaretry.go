@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/proto/waMmsRetry"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	"google.golang.org/protobuf/proto"
+)
+
+// WhatsApp's media servers drop files after a few weeks, after which a
+// download fails with 404 or 410. The phone that sent the file can still
+// upload it again: the gateway sends a media retry receipt and waits up to
+// MEDIA_RETRY_TIMEOUT for the events.MediaRetry answer with the new path.
+// That needs the sender's phone to be online and to still have the file.
+
+var mediaRetryTimeout = envDuration("MEDIA_RETRY_TIMEOUT", 30*time.Second)
+
+var (
+	errMediaGone         = errors.New("media is no longer available from its sender")
+	errMediaRetryTimeout = errors.New("sender's phone did not upload the media again in time")
+)
+
+// mediaRetryWaiters are the re-uploads being waited for, by message ID.
+var (
+	mediaRetryWaitersMu sync.Mutex
+	mediaRetryWaiters   = map[types.MessageID][]chan *events.MediaRetry{}
+)
+
+// handleMediaRetry passes a re-upload answer on to whoever asked for it.
+func handleMediaRetry(evt *events.MediaRetry) {
+	mediaRetryWaitersMu.Lock()
+	waiters := mediaRetryWaiters[evt.MessageID]
+	delete(mediaRetryWaiters, evt.MessageID)
+	mediaRetryWaitersMu.Unlock()
+	for _, ch := range waiters {
+		ch <- evt
+	}
+}
+
+func stopWaitingForMediaRetry(id types.MessageID, ch chan *events.MediaRetry) {
+	mediaRetryWaitersMu.Lock()
+	defer mediaRetryWaitersMu.Unlock()
+	waiters := mediaRetryWaiters[id]
+	for i, c := range waiters {
+		if c == ch {
+			mediaRetryWaiters[id] = append(waiters[:i], waiters[i+1:]...)
+			break
+		}
+	}
+	if len(mediaRetryWaiters[id]) == 0 {
+		delete(mediaRetryWaiters, id)
+	}
+}
+
+// requestMediaReupload asks the sender of a message to upload its file again
+// and returns the file's new direct path.
+func requestMediaReupload(ctx context.Context, info types.MessageInfo, media whatsmeow.DownloadableMessage) (string, error) {
+	ch := make(chan *events.MediaRetry, 1)
+	mediaRetryWaitersMu.Lock()
+	mediaRetryWaiters[info.ID] = append(mediaRetryWaiters[info.ID], ch)
+	mediaRetryWaitersMu.Unlock()
+	defer stopWaitingForMediaRetry(info.ID, ch)
+
+	if err := client.SendMediaRetryReceipt(ctx, &info, media.GetMediaKey()); err != nil {
+		return "", fmt.Errorf("failed to send media retry receipt: %w", err)
+	}
+	var evt *events.MediaRetry
+	select {
+	case evt = <-ch:
+	case <-time.After(mediaRetryTimeout):
+		return "", errMediaRetryTimeout
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+	if evt.Error != nil {
+		// The phone answers with an error code when it no longer has the file.
+		return "", fmt.Errorf("%w: phone answered with error %d", errMediaGone, evt.Error.Code)
+	}
+	notif, err := whatsmeow.DecryptMediaRetryNotification(evt, media.GetMediaKey())
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt media retry notification: %w", err)
+	}
+	switch notif.GetResult() {
+	case waMmsRetry.MediaRetryNotification_SUCCESS:
+		return notif.GetDirectPath(), nil
+	case waMmsRetry.MediaRetryNotification_NOT_FOUND:
+		return "", errMediaGone
+	}
+	return "", fmt.Errorf("media re-upload failed: %s", notif.GetResult())
+}
+
+// reuploadKeptMedia has the sender upload kept media again and keeps the new
+// path, so the next download of it works directly.
+func reuploadKeptMedia(ctx context.Context, m *keptMedia, msg *waE2E.Message, media whatsmeow.DownloadableMessage) (*spooledFile, error) {
+	var info types.MessageInfo
+	if err := json.Unmarshal([]byte(m.Info), &info); err != nil {
+		return nil, fmt.Errorf("invalid message info: %w", err)
+	}
+	path, err := requestMediaReupload(ctx, info, media)
+	if err != nil {
+		return nil, err
+	}
+	// The old URL points at the expired upload; downloads use the path.
+	setMediaUpload(msg, whatsmeow.UploadResponse{
+		DirectPath:    path,
+		MediaKey:      media.GetMediaKey(),
+		FileEncSHA256: media.GetFileEncSHA256(),
+		FileSHA256:    media.GetFileSHA256(),
+	})
+	if encoded, err := proto.Marshal(msg); err != nil {
+		waLogger.Warnf("Failed to marshal re-uploaded media %d: %v", m.ID, err)
+	} else if _, err := gatewayDB.ExecContext(ctx, `UPDATE media_files SET message = ? WHERE id = ?`,
+		encryptStoreValue(base64.StdEncoding.EncodeToString(encoded)), m.ID); err != nil {
+		waLogger.Warnf("Failed to record re-uploaded media %d: %v", m.ID, err)
+	}
+	media, _ = messageMedia(msg)
+	return downloadToSpool(ctx, media)
+}
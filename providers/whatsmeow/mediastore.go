@@ -0,0 +1,659 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	"google.golang.org/protobuf/proto"
+)
+
+// The media download policy (/settings/media-download) decides per media
+// type what happens to the file of an inbound message:
+//
+//	auto       it's downloaded when the message arrives and kept in MEDIA_DIR
+//	on_demand  only its media keys are kept, so it can be downloaded later
+//	           while WhatsApp still has it (default)
+//	skip       nothing is kept and it isn't downloaded at all, not even for
+//	           transcription, image analysis or scanning
+//
+// Files over auto_max_bytes are kept on demand only. Sent media is always
+// kept in MEDIA_DIR. The message webhook (or the send result, as
+// media_id) says what was done under media, and /messages/{id}/media and
+// /media/{id} serve the file, with range requests, from MEDIA_DIR or
+// downloaded with the kept keys (and re-requested from the sender once
+// expired, see mediaretry.go). Documents not scanned clean (see
+// virusscan.go) are only served with ?allow_unscanned=true, and infected
+// ones not at all. Kept media (files and keys) is removed after
+// MEDIA_RETENTION; past MEDIA_DIR_MAX_BYTES the oldest files are evicted
+// sooner, leaving their keys to download them again on demand.
+//
+// With MEDIA_S3_BUCKET the file is offloaded too, see mediaoffload.go.
+
+var (
+	mediaDir       = envString("MEDIA_DIR", "/app/session/media")
+	mediaRetention = envDuration("MEDIA_RETENTION", 30*24*time.Hour)
+	mediaDirMax    = int64(envInt("MEDIA_DIR_MAX_BYTES", 0)) // 0: no limit
+)
+
+var mediaPolicyTypes = []string{"image", "video", "audio", "voice", "document", "sticker"}
+
+type mediaDownloadPolicy struct {
+	Types        map[string]string `json:"types"` // media type: auto, on_demand or skip
+	AutoMaxBytes int64             `json:"auto_max_bytes,omitempty"`
+}
+
+var (
+	mediaPolicyMu     sync.RWMutex
+	mediaPolicyConfig = mediaDownloadPolicy{Types: map[string]string{}}
+)
+
+func validMediaMode(mode string) bool {
+	switch mode {
+	case "auto", "on_demand", "skip":
+		return true
+	}
+	return false
+}
+
+func (p mediaDownloadPolicy) validate() bool {
+	for typ, mode := range p.Types {
+		if !validMediaMode(mode) || !slices.Contains(mediaPolicyTypes, typ) {
+			return false
+		}
+	}
+	return p.AutoMaxBytes >= 0
+}
+
+func loadMediaDownloadPolicy(ctx context.Context) {
+	raw := getSetting(ctx, "media_download_policy", "")
+	if raw == "" {
+		return
+	}
+	var policy mediaDownloadPolicy
+	if err := json.Unmarshal([]byte(raw), &policy); err != nil || !policy.validate() {
+		waLogger.Errorf("Ignoring invalid media download policy %q", raw)
+		return
+	}
+	mediaPolicyMu.Lock()
+	mediaPolicyConfig = policy
+	mediaPolicyMu.Unlock()
+}
+
+func currentMediaDownloadPolicy() mediaDownloadPolicy {
+	mediaPolicyMu.RLock()
+	defer mediaPolicyMu.RUnlock()
+	return mediaPolicyConfig
+}
+
+// mode returns what to do with a file of the given type and size.
+func (p mediaDownloadPolicy) mode(typ string, size uint64) string {
+	mode := p.Types[typ]
+	if mode == "" {
+		mode = "on_demand"
+	}
+	if mode == "auto" && ((p.AutoMaxBytes > 0 && size > uint64(p.AutoMaxBytes)) || !featureEnabled(flagAutoDownload)) {
+		mode = "on_demand"
+	}
+	return mode
+}
+
+// mediaInfo describes the file of a media message in webhooks.
+type mediaInfo struct {
+	ID       int64  `json:"id,omitempty"` // kept media
+	Type     string `json:"type"`
+	Mimetype string `json:"mimetype,omitempty"`
+	FileName string `json:"file_name,omitempty"`
+	Size     uint64 `json:"size"`
+	SHA256   string `json:"sha256,omitempty"`
+	Status   string `json:"status"` // stored, on_demand, skipped or failed
+
+	OriginalMimetype string `json:"original_mimetype,omitempty"` // before TRANSCODE_INBOUND_AUDIO
+
+	URL          string     `json:"url,omitempty"` // presigned, when offloaded to MEDIA_S3_BUCKET
+	URLExpiresAt *time.Time `json:"url_expires_at,omitempty"`
+}
+
+// messageMedia returns the downloadable file of a media message, if any.
+func messageMedia(msg *waE2E.Message) (whatsmeow.DownloadableMessage, *mediaInfo) {
+	info := &mediaInfo{Type: messageType(msg)}
+	var media whatsmeow.DownloadableMessage
+	var sum []byte
+	switch {
+	case msg.GetImageMessage() != nil:
+		m := msg.GetImageMessage()
+		media, info.Mimetype, info.Size, sum = m, m.GetMimetype(), m.GetFileLength(), m.GetFileSHA256()
+	case msg.GetVideoMessage() != nil:
+		m := msg.GetVideoMessage()
+		media, info.Mimetype, info.Size, sum = m, m.GetMimetype(), m.GetFileLength(), m.GetFileSHA256()
+	case msg.GetAudioMessage() != nil:
+		m := msg.GetAudioMessage()
+		media, info.Mimetype, info.Size, sum = m, m.GetMimetype(), m.GetFileLength(), m.GetFileSHA256()
+	case msg.GetDocumentMessage() != nil:
+		m := msg.GetDocumentMessage()
+		media, info.Mimetype, info.Size, sum = m, m.GetMimetype(), m.GetFileLength(), m.GetFileSHA256()
+		info.FileName = m.GetFileName()
+	case msg.GetStickerMessage() != nil:
+		m := msg.GetStickerMessage()
+		media, info.Mimetype, info.Size, sum = m, m.GetMimetype(), m.GetFileLength(), m.GetFileSHA256()
+	default:
+		return nil, nil
+	}
+	info.SHA256 = hex.EncodeToString(sum)
+	return media, info
+}
+
+// mediaSkipped reports whether the policy says to leave msg's file alone.
+func mediaSkipped(msg *waE2E.Message) bool {
+	media, info := messageMedia(msg)
+	return media != nil && currentMediaDownloadPolicy().mode(info.Type, info.Size) == "skip"
+}
+
+// mediaToStore returns msg's file if the policy says to download it on
+// arrival, or it's offloaded.
+func mediaToStore(msg *waE2E.Message) whatsmeow.DownloadableMessage {
+	media, info := messageMedia(msg)
+	if media == nil {
+		return nil
+	}
+	policy := currentMediaDownloadPolicy()
+	if (gatewayDB == nil || policy.mode(info.Type, info.Size) != "auto") && !offloadWanted(policy, info.Type, info.Size) {
+		return nil
+	}
+	return media
+}
+
+// keepInboundMedia applies the media download policy to an inbound message,
+// recording the verdict of its scan. It returns nil for messages without a
+// file.
+func keepInboundMedia(ctx context.Context, evt *events.Message, scan *scanVerdict) *mediaInfo {
+	media, info := messageMedia(evt.Message)
+	if media == nil {
+		return nil
+	}
+	policy := currentMediaDownloadPolicy()
+	mode := policy.mode(info.Type, info.Size)
+	offload := offloadWanted(policy, info.Type, info.Size)
+	if mode == "skip" || (gatewayDB == nil && !offload) {
+		info.Status = "skipped"
+		return info
+	}
+	path := ""
+	info.Status = "on_demand"
+	if (mode == "auto" && gatewayDB != nil) || offload {
+		file, err := downloadToSpool(ctx, media)
+		if err == nil {
+			defer file.Remove()
+			if mp3 := normalizeInboundAudio(ctx, info, file); mp3 != nil {
+				defer mp3.Remove()
+				file = mp3
+			}
+			if mode == "auto" && gatewayDB != nil {
+				path, err = writeMediaFile(file)
+			}
+		}
+		if err != nil {
+			waLogger.Errorf("Failed to download media of message %s: %v", evt.Info.ID, err)
+			info.Status = "failed"
+		} else {
+			if path != "" {
+				info.Status = "stored"
+			}
+			info.Size = uint64(file.Size)
+			if offload {
+				if err := offloadMedia(ctx, info, file); err != nil {
+					waLogger.Errorf("Failed to offload media of message %s: %v", evt.Info.ID, err)
+				}
+			}
+		}
+	}
+	if gatewayDB == nil {
+		return info
+	}
+	if err := recordMedia(ctx, evt.Info, evt.Message, info, path, scanStatus(evt.Message, scan)); err != nil {
+		waLogger.Errorf("Failed to record media of message %s: %v", evt.Info.ID, err)
+	}
+	return info
+}
+
+// scanStatus is what's recorded of the scan of a kept file: the verdict, or
+// unscanned for a document the scanner skipped. Files it doesn't cover have
+// none.
+func scanStatus(msg *waE2E.Message, scan *scanVerdict) string {
+	switch {
+	case scan != nil:
+		return scan.Status
+	case activeVirusScanner != nil && msg.GetDocumentMessage() != nil:
+		return "unscanned"
+	}
+	return ""
+}
+
+// keepOutboundMedia keeps the file of a sent media message and returns its
+// media ID, or 0 if it couldn't be kept.
+func keepOutboundMedia(ctx context.Context, to types.JID, id types.MessageID, msg *waE2E.Message, file *spooledFile) int64 {
+	media, info := messageMedia(msg)
+	if media == nil || gatewayDB == nil {
+		return 0
+	}
+	path, err := writeMediaFile(file)
+	if err != nil {
+		waLogger.Errorf("Failed to keep media of message %s: %v", id, err)
+	}
+	source := types.MessageSource{Chat: to, IsFromMe: true, IsGroup: to.Server == types.GroupServer}
+	if client != nil && client.Store.ID != nil {
+		source.Sender = client.Store.ID.ToNonAD()
+	}
+	info.Size = uint64(file.Size)
+	if err := recordMedia(ctx, types.MessageInfo{MessageSource: source, ID: id, Timestamp: time.Now()}, msg, info, path, ""); err != nil {
+		waLogger.Errorf("Failed to record media of message %s: %v", id, err)
+		return 0
+	}
+	return info.ID
+}
+
+// recordMedia records the kept media of a message, setting info.ID.
+func recordMedia(ctx context.Context, msgInfo types.MessageInfo, msg *waE2E.Message, info *mediaInfo, path, scanStatus string) error {
+	encoded, err := proto.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	rawInfo, _ := json.Marshal(msgInfo)
+	return gatewayDB.QueryRowContext(ctx, `
+		INSERT INTO media_files (chat_jid, message_id, sender_jid, from_me, type, mimetype, file_name, size, sha256, message, info, path, scan_status, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (chat_jid, message_id) DO UPDATE SET
+			path = CASE WHEN excluded.path <> '' THEN excluded.path ELSE media_files.path END,
+			scan_status = excluded.scan_status
+		RETURNING id`,
+		canonicalJID(ctx, msgInfo.Chat).String(), msgInfo.ID, canonicalJID(ctx, msgInfo.Sender).String(), msgInfo.IsFromMe,
+		info.Type, info.Mimetype, info.FileName, info.Size, info.SHA256,
+		// The media key opens the file, so it's protected like message text.
+		encryptStoreValue(base64.StdEncoding.EncodeToString(encoded)), string(rawInfo), path, scanStatus, time.Now().Unix()).Scan(&info.ID)
+}
+
+// writeMediaFile keeps a spooled file under its hash, so copies share one
+// file. The file is linked into MEDIA_DIR when that's on the spool's
+// filesystem, and copied otherwise.
+func writeMediaFile(file *spooledFile) (string, error) {
+	if err := os.MkdirAll(mediaDir, 0o700); err != nil {
+		return "", err
+	}
+	in, err := os.Open(file.Path)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+	if file.SHA256 == nil {
+		// Uploads resumed after a crash weren't hashed while spooling.
+		hashed, err := readSpool(io.Discard, in, math.MaxInt64-1)
+		if err != nil {
+			return "", err
+		}
+		file.SHA256 = hashed.SHA256
+		if _, err := in.Seek(0, io.SeekStart); err != nil {
+			return "", err
+		}
+	}
+	path := filepath.Join(mediaDir, hex.EncodeToString(file.SHA256))
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+	if os.Link(file.Path, path) == nil {
+		return path, nil
+	}
+	tmp, err := os.CreateTemp(mediaDir, "tmp-*")
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(tmp, in)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return path, nil
+}
+
+// removeUnreferencedMediaFiles deletes the given files unless another kept
+// message still uses them.
+func removeUnreferencedMediaFiles(ctx context.Context, paths []string) {
+	for _, path := range paths {
+		var n int
+		if err := gatewayDB.QueryRowContext(ctx, `SELECT COUNT(*) FROM media_files WHERE path = ?`, path).Scan(&n); err != nil || n > 0 {
+			continue
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			waLogger.Errorf("Failed to delete media file %s: %v", path, err)
+		}
+	}
+}
+
+func runMediaPruner() {
+	if mediaRetention <= 0 && mediaDirMax <= 0 {
+		return
+	}
+	for range time.Tick(time.Hour) {
+		ctx := context.Background()
+		if mediaRetention > 0 {
+			pruneKeptMedia(ctx)
+		}
+		if mediaDirMax > 0 {
+			evictMediaFiles(ctx)
+		}
+	}
+}
+
+// pruneKeptMedia removes kept media past MEDIA_RETENTION.
+func pruneKeptMedia(ctx context.Context) {
+	rows, err := gatewayDB.QueryContext(ctx,
+		`DELETE FROM media_files WHERE created_at < ? RETURNING path`, time.Now().Add(-mediaRetention).Unix())
+	if err != nil {
+		waLogger.Errorf("Failed to prune kept media: %v", err)
+		return
+	}
+	var paths []string
+	for rows.Next() {
+		var path string
+		if rows.Scan(&path) == nil && path != "" {
+			paths = append(paths, path)
+		}
+	}
+	rows.Close()
+	removeUnreferencedMediaFiles(ctx, paths)
+}
+
+// evictMediaFiles deletes the least recently kept files until MEDIA_DIR is
+// within MEDIA_DIR_MAX_BYTES. Their messages keep the media keys, so the
+// files can still be downloaded on demand while WhatsApp has them.
+func evictMediaFiles(ctx context.Context) {
+	rows, err := gatewayDB.QueryContext(ctx,
+		`SELECT path FROM media_files WHERE path <> '' GROUP BY path ORDER BY MAX(created_at)`)
+	if err != nil {
+		waLogger.Errorf("Failed to list kept media files: %v", err)
+		return
+	}
+	type keptFile struct {
+		path string
+		size int64
+	}
+	var files []keptFile
+	var total int64
+	for rows.Next() {
+		var path string
+		if rows.Scan(&path) != nil {
+			continue
+		}
+		if st, err := os.Stat(path); err == nil {
+			files = append(files, keptFile{path, st.Size()})
+			total += st.Size()
+		}
+	}
+	rows.Close()
+	for _, f := range files {
+		if total <= mediaDirMax {
+			break
+		}
+		if _, err := gatewayDB.ExecContext(ctx, `UPDATE media_files SET path = '' WHERE path = ?`, f.path); err != nil {
+			waLogger.Errorf("Failed to evict media file %s: %v", f.path, err)
+			continue
+		}
+		if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+			waLogger.Errorf("Failed to delete media file %s: %v", f.path, err)
+			continue
+		}
+		total -= f.size
+	}
+}
+
+// keptMedia is the media_files row of a message.
+type keptMedia struct {
+	ID       int64
+	Chat     string
+	Mimetype string
+	FileName string
+	Message  string // encrypted, see keepInboundMedia
+	Info     string // message info, as JSON
+	Path     string
+	Scan     string // scan status, see scanStatus
+	Created  time.Time
+}
+
+// loadKeptMedia finds the kept media of a message; chat may be empty unless
+// the ID is in more than one chat.
+func loadKeptMedia(ctx context.Context, id, chat string) ([]keptMedia, error) {
+	where := `message_id = ?`
+	args := []interface{}{id}
+	if chat != "" {
+		where += ` AND chat_jid = ?`
+		args = append(args, chat)
+	}
+	return queryKeptMedia(ctx, where+` LIMIT 2`, args...)
+}
+
+func queryKeptMedia(ctx context.Context, where string, args ...interface{}) ([]keptMedia, error) {
+	rows, err := gatewayDB.QueryContext(ctx,
+		`SELECT id, chat_jid, mimetype, file_name, message, info, path, scan_status, created_at FROM media_files WHERE `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var found []keptMedia
+	for rows.Next() {
+		var m keptMedia
+		var created int64
+		if err := rows.Scan(&m.ID, &m.Chat, &m.Mimetype, &m.FileName, &m.Message, &m.Info, &m.Path, &m.Scan, &created); err != nil {
+			return nil, err
+		}
+		m.Created = time.Unix(created, 0)
+		found = append(found, m)
+	}
+	return found, rows.Err()
+}
+
+// downloadKeptMedia downloads kept media from WhatsApp with its stored keys,
+// having the sender upload it again if it expired, and keeps the file for the
+// next time. The caller removes the returned spool file.
+func downloadKeptMedia(ctx context.Context, m *keptMedia) (*spooledFile, error) {
+	raw, err := decryptStoreValue(m.Message)
+	if err != nil {
+		return nil, err
+	}
+	encoded, err := base64.StdEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, err
+	}
+	msg := &waE2E.Message{}
+	if err := proto.Unmarshal(encoded, msg); err != nil {
+		return nil, err
+	}
+	media, _ := messageMedia(msg)
+	if media == nil {
+		return nil, fmt.Errorf("message has no media")
+	}
+	file, err := downloadToSpool(ctx, media)
+	if errors.Is(err, whatsmeow.ErrMediaDownloadFailedWith404) || errors.Is(err, whatsmeow.ErrMediaDownloadFailedWith410) {
+		file, err = reuploadKeptMedia(ctx, m, msg, media)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if path, err := writeMediaFile(file); err != nil {
+		waLogger.Warnf("Failed to keep downloaded media %d: %v", m.ID, err)
+	} else if _, err := gatewayDB.ExecContext(ctx, `UPDATE media_files SET path = ? WHERE id = ?`, path, m.ID); err != nil {
+		waLogger.Warnf("Failed to record downloaded media %d: %v", m.ID, err)
+	} else {
+		m.Path = path
+	}
+	return file, nil
+}
+
+// getMessageMedia serves the file of a media message: the kept file when the
+// policy downloaded it (or the message was sent), else fresh from WhatsApp
+// while it still has it. ?chat= picks the chat when the message ID isn't
+// unique, and ?allow_unscanned=true serves a document not scanned clean.
+func getMessageMedia(w http.ResponseWriter, r *http.Request) {
+	if gatewayDB == nil {
+		http.Error(w, "Media store unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	chat := ""
+	if c := r.URL.Query().Get("chat"); c != "" {
+		jid, ok := parseJID(c)
+		if !ok {
+			http.Error(w, "Invalid chat JID", http.StatusBadRequest)
+			return
+		}
+		chat = canonicalJID(r.Context(), jid).String()
+	}
+	id := r.PathValue("id")
+	found, err := loadKeptMedia(r.Context(), id, chat)
+	if err != nil {
+		waLogger.Errorf("Failed to load media of message %s: %v", id, err)
+		http.Error(w, "Failed to load media", http.StatusInternalServerError)
+		return
+	}
+	switch len(found) {
+	case 0:
+		http.Error(w, "No media kept for this message", http.StatusNotFound)
+		return
+	case 2:
+		http.Error(w, "Message ID is in several chats, pass ?chat=", http.StatusConflict)
+		return
+	}
+	serveKeptMedia(w, r, found[0], r.URL.Query().Get("allow_unscanned") == "true")
+}
+
+// getMedia serves kept media by its media ID. ?allow_unscanned=true serves a
+// document not scanned clean.
+func getMedia(w http.ResponseWriter, r *http.Request) {
+	if gatewayDB == nil {
+		http.Error(w, "Media store unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid media ID", http.StatusBadRequest)
+		return
+	}
+	found, err := queryKeptMedia(r.Context(), `id = ?`, id)
+	if err != nil {
+		waLogger.Errorf("Failed to load media %d: %v", id, err)
+		http.Error(w, "Failed to load media", http.StatusInternalServerError)
+		return
+	}
+	if len(found) == 0 {
+		http.Error(w, "Media not found", http.StatusNotFound)
+		return
+	}
+	serveKeptMedia(w, r, found[0], r.URL.Query().Get("allow_unscanned") == "true")
+}
+
+// serveKeptMedia serves kept media, with range requests, downloading it
+// again if the file is gone. Media not scanned clean is refused unless
+// allowUnscanned; infected media always is.
+func serveKeptMedia(w http.ResponseWriter, r *http.Request, m keptMedia, allowUnscanned bool) {
+	switch m.Scan {
+	case "", "clean":
+	case "infected":
+		http.Error(w, "Media is infected and was quarantined", http.StatusForbidden)
+		return
+	default:
+		if !allowUnscanned {
+			http.Error(w, fmt.Sprintf("Media wasn't scanned clean (%s), pass ?allow_unscanned=true to download it anyway", m.Scan), http.StatusForbidden)
+			return
+		}
+	}
+	serve := func(content io.ReadSeeker) {
+		if m.Mimetype != "" {
+			w.Header().Set("Content-Type", m.Mimetype)
+		}
+		if m.FileName != "" {
+			w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": m.FileName}))
+		}
+		http.ServeContent(w, r, "", m.Created, content)
+	}
+	if m.Path != "" {
+		if f, err := os.Open(m.Path); err == nil {
+			defer f.Close()
+			serve(f)
+			return
+		}
+	}
+	if !requireClient(w, r, false) {
+		return
+	}
+	file, err := downloadKeptMedia(r.Context(), &m)
+	switch {
+	case errors.Is(err, errMediaGone), errors.Is(err, whatsmeow.ErrMediaDownloadFailedWith404), errors.Is(err, whatsmeow.ErrMediaDownloadFailedWith410):
+		http.Error(w, "Media is no longer available on WhatsApp or the sender's phone", http.StatusGone)
+		return
+	case errors.Is(err, errMediaRetryTimeout):
+		http.Error(w, "Media expired and the sender's phone did not upload it again in time", http.StatusGatewayTimeout)
+		return
+	case err != nil:
+		waLogger.Errorf("Failed to download media %d: %v", m.ID, err)
+		http.Error(w, "Failed to download media", http.StatusBadGateway)
+		return
+	}
+	defer file.Remove()
+	f, err := os.Open(file.Path)
+	if err != nil {
+		waLogger.Errorf("Failed to open downloaded media %d: %v", m.ID, err)
+		http.Error(w, "Failed to download media", http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+	serve(f)
+}
+
+func getMediaDownloadPolicy(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, currentMediaDownloadPolicy())
+}
+
+func putMediaDownloadPolicy(w http.ResponseWriter, r *http.Request) {
+	var policy mediaDownloadPolicy
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if policy.Types == nil {
+		policy.Types = map[string]string{}
+	}
+	if !policy.validate() {
+		http.Error(w, "types maps image, video, audio, voice, document or sticker to auto, on_demand or skip", http.StatusBadRequest)
+		return
+	}
+	raw, _ := json.Marshal(policy)
+	if err := setSetting(r.Context(), "media_download_policy", string(raw)); err != nil {
+		waLogger.Errorf("Failed to save media download policy: %v", err)
+		http.Error(w, "Failed to save policy", http.StatusInternalServerError)
+		return
+	}
+	mediaPolicyMu.Lock()
+	mediaPolicyConfig = policy
+	mediaPolicyMu.Unlock()
+	writeJSON(w, http.StatusOK, policy)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+)
+
+func TestKeptMediaScanStatus(t *testing.T) {
+	openTestDB(t)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /media/{id}", getMedia)
+	mux.HandleFunc("GET /messages/{id}/media", getMessageMedia)
+	path := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(path, []byte("contents"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	sender := types.NewJID("15551234567", types.DefaultUserServer)
+	keep := func(id, status string) int64 {
+		info := &mediaInfo{Type: "document", Mimetype: "application/pdf", FileName: id + ".pdf"}
+		msgInfo := types.MessageInfo{MessageSource: types.MessageSource{Chat: sender, Sender: sender}, ID: id, Timestamp: time.Now()}
+		if err := recordMedia(context.Background(), msgInfo, &waE2E.Message{}, info, path, status); err != nil {
+			t.Fatal(err)
+		}
+		return info.ID
+	}
+
+	for _, tc := range []struct {
+		status            string
+		want, wantAllowed int
+	}{
+		{"", http.StatusOK, http.StatusOK}, // not covered by the scanner
+		{"clean", http.StatusOK, http.StatusOK},
+		{"error", http.StatusForbidden, http.StatusOK},
+		{"unscanned", http.StatusForbidden, http.StatusOK},
+		{"infected", http.StatusForbidden, http.StatusForbidden},
+	} {
+		msgID := "DOC-" + tc.status
+		id := keep(msgID, tc.status)
+		for target, want := range map[string]int{
+			fmt.Sprintf("/media/%d", id):                                  tc.want,
+			fmt.Sprintf("/media/%d?allow_unscanned=true", id):             tc.wantAllowed,
+			fmt.Sprintf("/messages/%s/media", msgID):                      tc.want,
+			fmt.Sprintf("/messages/%s/media?allow_unscanned=true", msgID): tc.wantAllowed,
+		} {
+			w := request(t, mux, "GET", target, "")
+			if w.Code != want {
+				t.Errorf("%q: GET %s = %d, want %d", tc.status, target, w.Code, want)
+			} else if want == http.StatusOK && w.Body.String() != "contents" {
+				t.Errorf("%q: GET %s served %q", tc.status, target, w.Body)
+			}
+		}
+	}
+
+	// A rescan after a replay replaces the verdict.
+	keep("DOC-error", "clean")
+	if w := request(t, mux, "GET", "/messages/DOC-error/media", ""); w.Code != http.StatusOK {
+		t.Errorf("rescanned clean: status %d", w.Code)
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"time"
+)
+
+// Media sends may give "url" instead of the file itself; the gateway fetches
+// it (from public addresses only, like link previews) within
+// MEDIA_URL_TIMEOUT and the send's size limit. Its Content-Type, sniffed when
+// the server doesn't say, must match MEDIA_URL_TYPES, so that e.g. a link to
+// a download page isn't sent as the file. The URL's Content-Type and file
+// name fill in mimetype and filename when the request leaves them out.
+
+var (
+	mediaURLTimeout = envDuration("MEDIA_URL_TIMEOUT", time.Minute)
+	mediaURLTypes   = splitMediaTypes(envString("MEDIA_URL_TYPES", "image/*,video/*,audio/*,application/*"))
+)
+
+var errMediaURL = errors.New("failed to fetch media URL")
+
+var mediaURLClient = &http.Client{Transport: publicTransport}
+
+// fetchMediaURL downloads the file for a media send to the spool and returns
+// it with its MIME type and file name, if the server gave one.
+func fetchMediaURL(ctx context.Context, raw string, maxBytes int64) (*spooledFile, string, string, error) {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, "", "", fmt.Errorf("url must be an http or https URL")
+	}
+	ctx, cancel := context.WithTimeout(ctx, mediaURLTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return nil, "", "", fmt.Errorf("%w: %v", errMediaURL, err)
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; WhatsAppGateway-Media/1.0)")
+	resp, err := mediaURLClient.Do(req)
+	if err != nil {
+		return nil, "", "", fmt.Errorf("%w: %v", errMediaURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return nil, "", "", fmt.Errorf("%w: %s returned %s", errMediaURL, u.Host, resp.Status)
+	}
+	if resp.ContentLength > maxBytes {
+		return nil, "", "", mediaTooLarge(fmt.Sprintf("file is too large: %d bytes, at most %d allowed", resp.ContentLength, maxBytes), resp.ContentLength, maxBytes)
+	}
+	// Checked before downloading, so a page can't be fetched at length.
+	mimetype, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mimetype != "" && mimetype != "application/octet-stream" && !mimeMatches(mediaURLTypes, mimetype) {
+		return nil, "", "", urlTypeRejected(mimetype)
+	}
+	file, err := spoolReader(resp.Body, maxBytes, "file")
+	var rejected *mediaRejection
+	if errors.As(err, &rejected) {
+		return nil, "", "", err
+	} else if err != nil {
+		return nil, "", "", fmt.Errorf("%w: %v", errMediaURL, err)
+	}
+	if file.Size == 0 {
+		file.Remove()
+		return nil, "", "", fmt.Errorf("%w: %s returned an empty file", errMediaURL, u.Host)
+	}
+	if mimetype == "" || mimetype == "application/octet-stream" {
+		mimetype = sniffMediaType(file.Head)
+		if !mimeMatches(mediaURLTypes, mimetype) {
+			file.Remove()
+			return nil, "", "", urlTypeRejected(mimetype)
+		}
+	}
+	var filename string
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil {
+		filename = params["filename"]
+	}
+	if base := path.Base(resp.Request.URL.Path); filename == "" && base != "/" && base != "." {
+		filename = base
+	}
+	return file, mimetype, filename, nil
+}
+
+func urlTypeRejected(mimetype string) *mediaRejection {
+	rej := unsupportedMedia(fmt.Sprintf("url serves %s, which MEDIA_URL_TYPES doesn't allow", mimetype))
+	rej.Mimetype, rej.Allowed = mimetype, mediaURLTypes
+	return rej
+}
+
+// fetchURL fills in an upload's file from a URL.
+func (up *mediaUpload) fetchURL(ctx context.Context, raw string, maxBytes int64) error {
+	file, mimetype, filename, err := fetchMediaURL(ctx, raw, maxBytes)
+	if err != nil {
+		return err
+	}
+	up.File = file
+	if up.Mimetype == "" {
+		up.Mimetype = mimetype
+	}
+	if up.FileName == "" {
+		up.FileName = filename
+	}
+	return nil
+}
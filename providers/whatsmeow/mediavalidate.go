@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Every media upload is checked before anything is done with it. Its bytes
+// are sniffed for the real type, which must agree with the declared mimetype
+// (a PDF can't claim to be a JPEG) and match MEDIA_ALLOWED_TYPES; when that's
+// unset anything but executables is allowed. The file must also fit the
+// endpoint's limit (VIDEO_MAX_BYTES and so on) and any lower limit
+// MEDIA_TYPE_MAX_BYTES sets for its type, e.g.
+// "image/*=5242880,application/pdf=20971520". Rejections are JSON with a
+// code: media_too_large (413) or unsupported_media_type (415).
+
+var (
+	mediaAllowedTypes = splitMediaTypes(envString("MEDIA_ALLOWED_TYPES", ""))
+	mediaTypeMaxBytes = parseMediaTypeLimits(envString("MEDIA_TYPE_MAX_BYTES", ""))
+)
+
+var errMediaType = errors.New("media type is not allowed")
+
+var executableTypes = []string{"application/x-msdownload", "application/x-executable", "application/x-mach-binary"}
+
+type mediaTypeLimit struct {
+	pattern  string
+	maxBytes int64
+}
+
+// mediaRejection is a structured 413 or 415 answer to a media upload.
+type mediaRejection struct {
+	kind     error    // errMediaTooLarge or errMediaType
+	Code     string   `json:"code"`
+	Message  string   `json:"error"`
+	Mimetype string   `json:"mimetype,omitempty"` // as sniffed
+	Declared string   `json:"declared_mimetype,omitempty"`
+	Size     int64    `json:"size,omitempty"`
+	Limit    int64    `json:"limit,omitempty"`
+	Allowed  []string `json:"allowed,omitempty"`
+}
+
+func (e *mediaRejection) Error() string { return e.Message }
+func (e *mediaRejection) Unwrap() error { return e.kind }
+
+func (e *mediaRejection) status() int {
+	if e.kind == errMediaTooLarge {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusUnsupportedMediaType
+}
+
+func mediaTooLarge(msg string, size, limit int64) *mediaRejection {
+	return &mediaRejection{kind: errMediaTooLarge, Code: "media_too_large", Message: msg, Size: size, Limit: limit}
+}
+
+func unsupportedMedia(msg string) *mediaRejection {
+	return &mediaRejection{kind: errMediaType, Code: "unsupported_media_type", Message: msg}
+}
+
+func splitMediaTypes(s string) []string {
+	var types []string
+	for _, t := range strings.Split(s, ",") {
+		if t = strings.ToLower(strings.TrimSpace(t)); t != "" {
+			types = append(types, t)
+		}
+	}
+	return types
+}
+
+func parseMediaTypeLimits(s string) []mediaTypeLimit {
+	var limits []mediaTypeLimit
+	for _, entry := range splitMediaTypes(s) {
+		pattern, n, _ := strings.Cut(entry, "=")
+		if maxBytes, err := strconv.ParseInt(strings.TrimSpace(n), 10, 64); err == nil && maxBytes > 0 {
+			limits = append(limits, mediaTypeLimit{strings.TrimSpace(pattern), maxBytes})
+		}
+	}
+	return limits
+}
+
+// mimeMatches matches a MIME type against patterns that are exact types,
+// "type/*" or "*".
+func mimeMatches(patterns []string, mimetype string) bool {
+	for _, pattern := range patterns {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "*" || pattern == mimetype ||
+			(strings.HasSuffix(pattern, "/*") && strings.HasPrefix(mimetype, strings.TrimSuffix(pattern, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// sniffMediaType tells a file's type from its first bytes, adding the
+// executable formats http.DetectContentType doesn't know.
+func sniffMediaType(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte("MZ")):
+		return "application/x-msdownload"
+	case bytes.HasPrefix(data, []byte("\x7fELF")):
+		return "application/x-executable"
+	case bytes.HasPrefix(data, []byte{0xfe, 0xed, 0xfa, 0xce}), bytes.HasPrefix(data, []byte{0xfe, 0xed, 0xfa, 0xcf}),
+		bytes.HasPrefix(data, []byte{0xce, 0xfa, 0xed, 0xfe}), bytes.HasPrefix(data, []byte{0xcf, 0xfa, 0xed, 0xfe}):
+		return "application/x-mach-binary"
+	}
+	mimetype, _, _ := mime.ParseMediaType(http.DetectContentType(data))
+	return mimetype
+}
+
+// mediaFamily groups the sniffed types a declared type has to agree with.
+// Containers such as ZIP (Office files) or plain text (CSV) hold too many
+// formats to tell apart, so they have none and agree with anything.
+func mediaFamily(mimetype string) string {
+	switch {
+	case strings.HasPrefix(mimetype, "image/"):
+		return "image"
+	case strings.HasPrefix(mimetype, "audio/"), strings.HasPrefix(mimetype, "video/"), mimetype == "application/ogg":
+		// MP4 and WebM hold audio or video alike.
+		return "audiovisual"
+	case mimetype == "application/pdf", mimetype == "text/html":
+		return mimetype
+	case mimeMatches(executableTypes, mimetype):
+		return "executable"
+	}
+	return ""
+}
+
+// validateMediaUpload checks an upload against the type allowlist and the
+// size limits, maxBytes being the endpoint's.
+func validateMediaUpload(up *mediaUpload, maxBytes int64) error {
+	declared, _, _ := mime.ParseMediaType(up.Mimetype)
+	sniffed := sniffMediaType(up.File.Head)
+	effective := sniffed
+	if family := mediaFamily(sniffed); family != "" {
+		if declared != "" && declared != "application/octet-stream" && mediaFamily(declared) != family {
+			rej := unsupportedMedia(fmt.Sprintf("file content is %s, not the declared %s", sniffed, declared))
+			rej.Mimetype, rej.Declared = sniffed, declared
+			return rej
+		}
+	} else if declared != "" {
+		effective = declared
+	}
+
+	allowed := mediaAllowedTypes
+	if len(allowed) == 0 {
+		allowed = []string{"*"}
+		if mimeMatches(executableTypes, sniffed) {
+			allowed = nil
+		}
+	}
+	if !mimeMatches(allowed, effective) {
+		rej := unsupportedMedia(fmt.Sprintf("%s files are not allowed", effective))
+		rej.Mimetype, rej.Declared, rej.Allowed = sniffed, declared, mediaAllowedTypes
+		return rej
+	}
+
+	limit := maxBytes
+	for _, l := range mediaTypeMaxBytes {
+		if l.maxBytes < limit && mimeMatches([]string{l.pattern}, effective) {
+			limit = l.maxBytes
+		}
+	}
+	if size := up.File.Size; size > limit {
+		rej := mediaTooLarge(fmt.Sprintf("file is too large: %d bytes, at most %d allowed for %s", size, limit, effective), size, limit)
+		rej.Mimetype, rej.Declared = sniffed, declared
+		return rej
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// Mentions only notify someone when the message lists their JID and the text
+// carries the matching @<number> token, so /send checks that the two agree
+// rather than sending a mention that silently doesn't ping.
+
+var mentionTokenRe = regexp.MustCompile(`(?:^|[^\w@])@(\d{5,15})\b`)
+
+// mentionedJIDs validates the mentions of a text and returns their JIDs.
+func mentionedJIDs(text string, mentions []string) ([]string, error) {
+	inText := map[string]bool{}
+	for _, m := range mentionTokenRe.FindAllStringSubmatch(text, -1) {
+		inText[m[1]] = true
+	}
+	listed := map[string]bool{}
+	var jids []string
+	for _, mention := range mentions {
+		jid, err := parseRecipient(mention)
+		if err != nil || (jid.Server != types.DefaultUserServer && jid.Server != types.HiddenUserServer) {
+			return nil, fmt.Errorf("invalid mention %q", mention)
+		}
+		if listed[jid.User] {
+			continue
+		}
+		listed[jid.User] = true
+		if !inText[jid.User] {
+			return nil, fmt.Errorf("mention %s has no @%s in the text", jid.ToNonAD(), jid.User)
+		}
+		jids = append(jids, jid.ToNonAD().String())
+	}
+	var unlisted []string
+	for user := range inText {
+		if !listed[user] {
+			unlisted = append(unlisted, "@"+user)
+		}
+	}
+	if len(unlisted) > 0 {
+		return nil, fmt.Errorf("%s in the text must be listed in mentions", strings.Join(unlisted, ", "))
+	}
+	return jids, nil
+}
@@ -0,0 +1,354 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	"google.golang.org/protobuf/proto"
+)
+
+// The message store keeps the text of sent and received messages (plus voice
+// note transcripts) so they can be searched. It holds message content, so it
+// is opt-in like presence history.
+var messageStoreEnabled = envBool("MESSAGE_STORE")
+
+type storedMessage struct {
+	ID         string    `json:"id"`
+	Chat       string    `json:"chat"`
+	Sender     string    `json:"sender,omitempty"`
+	FromMe     bool      `json:"from_me"`
+	Type       string    `json:"type"`
+	Text       string    `json:"text,omitempty"`
+	Transcript string    `json:"transcript,omitempty"`
+	Timestamp  time.Time `json:"timestamp"`
+	Notes      []note    `json:"notes,omitempty"`
+}
+
+// messageType names the kind of content a message carries.
+func messageType(msg *waE2E.Message) string {
+	switch {
+	case msg.Conversation != nil, msg.ExtendedTextMessage != nil:
+		return "text"
+	case msg.ImageMessage != nil:
+		return "image"
+	case msg.VideoMessage != nil:
+		return "video"
+	case msg.AudioMessage != nil:
+		if msg.GetAudioMessage().GetPTT() {
+			return "voice"
+		}
+		return "audio"
+	case msg.DocumentMessage != nil:
+		return "document"
+	case msg.StickerMessage != nil:
+		return "sticker"
+	case msg.LocationMessage != nil, msg.LiveLocationMessage != nil:
+		return "location"
+	case msg.ContactMessage != nil, msg.ContactsArrayMessage != nil:
+		return "contact"
+	case msg.ReactionMessage != nil:
+		return "reaction"
+	case msg.PollCreationMessage != nil, msg.PollCreationMessageV3 != nil:
+		return "poll"
+	case msg.InteractiveMessage != nil, msg.ButtonsMessage != nil:
+		return "buttons"
+	case msg.InteractiveResponseMessage != nil, msg.ButtonsResponseMessage != nil, msg.TemplateButtonReplyMessage != nil:
+		return "button_reply"
+	case msg.ListMessage != nil:
+		return "list"
+	case msg.ListResponseMessage != nil:
+		return "list_reply"
+	case msg.RequestPaymentMessage != nil, msg.SendPaymentMessage != nil, msg.DeclinePaymentRequestMessage != nil,
+		msg.CancelPaymentRequestMessage != nil, msg.PaymentInviteMessage != nil:
+		return "payment"
+	case msg.OrderMessage != nil:
+		return "order"
+	case msg.InvoiceMessage != nil:
+		return "invoice"
+	}
+	return "other"
+}
+
+// storeMessage records a message; a later copy of the same message fills in
+// fields the earlier one lacked.
+func storeMessage(ctx context.Context, m storedMessage) {
+	if !messageStoreEnabled || gatewayDB == nil {
+		return
+	}
+	_, err := gatewayDB.ExecContext(ctx, `
+		INSERT INTO messages (chat_jid, id, sender_jid, from_me, type, text, transcript, timestamp)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (chat_jid, id) DO UPDATE SET
+			text = CASE WHEN excluded.text <> '' THEN excluded.text ELSE messages.text END,
+			transcript = CASE WHEN excluded.transcript <> '' THEN excluded.transcript ELSE messages.transcript END`,
+		m.Chat, m.ID, m.Sender, m.FromMe, m.Type, encryptStoreValue(m.Text), encryptStoreValue(m.Transcript), m.Timestamp.Unix())
+	if err != nil {
+		waLogger.Errorf("Failed to store message %s: %v", m.ID, err)
+	}
+}
+
+// recordReceipt keeps the delivery, read and played receipts others send for
+// our messages, alongside the messages themselves.
+func recordReceipt(evt *events.Receipt) {
+	if !messageStoreEnabled || gatewayDB == nil || evt.IsFromMe {
+		return
+	}
+	kind := string(evt.Type)
+	switch evt.Type {
+	case types.ReceiptTypeDelivered:
+		kind = "delivered"
+	case types.ReceiptTypeRead, types.ReceiptTypePlayed:
+	default:
+		return // retries and receipts between our own devices
+	}
+	ctx := context.Background()
+	chat := canonicalJID(ctx, evt.Chat).String()
+	sender := canonicalJID(ctx, evt.Sender).String()
+	for _, id := range evt.MessageIDs {
+		_, err := gatewayDB.ExecContext(ctx, `
+			INSERT INTO message_receipts (chat_jid, message_id, sender_jid, type, timestamp) VALUES (?, ?, ?, ?, ?)
+			ON CONFLICT DO NOTHING`,
+			chat, id, sender, kind, evt.Timestamp.Unix())
+		if err != nil {
+			waLogger.Errorf("Failed to record %s receipt for %s: %v", kind, id, err)
+		}
+	}
+}
+
+const storedMessageColumns = `chat_jid, id, sender_jid, from_me, type, text, transcript, timestamp`
+
+// queryStoredMessages loads stored messages; query is the part of the
+// statement after "FROM messages".
+func queryStoredMessages(ctx context.Context, query string, args ...interface{}) ([]storedMessage, error) {
+	return queryMatchingMessages(ctx, nil, 0, query, args...)
+}
+
+// queryMatchingMessages is queryStoredMessages with a filter on the decrypted
+// messages, stopping after limit matches (0 for no limit).
+func queryMatchingMessages(ctx context.Context, match func(storedMessage) bool, limit int, query string, args ...interface{}) ([]storedMessage, error) {
+	rows, err := gatewayDB.QueryContext(ctx, `SELECT `+storedMessageColumns+` FROM messages `+query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	messages := []storedMessage{}
+	for rows.Next() && (limit <= 0 || len(messages) < limit) {
+		var m storedMessage
+		var ts int64
+		if err := rows.Scan(&m.Chat, &m.ID, &m.Sender, &m.FromMe, &m.Type, &m.Text, &m.Transcript, &ts); err != nil {
+			return nil, err
+		}
+		if m.Text, err = decryptStoreValue(m.Text); err != nil {
+			return nil, fmt.Errorf("failed to decrypt message %s: %w", m.ID, err)
+		}
+		if m.Transcript, err = decryptStoreValue(m.Transcript); err != nil {
+			return nil, fmt.Errorf("failed to decrypt message %s: %w", m.ID, err)
+		}
+		m.Timestamp = time.Unix(ts, 0).UTC()
+		if match == nil || match(m) {
+			messages = append(messages, m)
+		}
+	}
+	return messages, rows.Err()
+}
+
+// likePattern matches s anywhere in a column, with LIKE wildcards escaped.
+func likePattern(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+	return "%" + s + "%"
+}
+
+func searchMessages(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	query := `WHERE 1 = 1`
+	var args []interface{}
+	// Encrypted text can't be matched in SQL; those rows are decrypted and
+	// matched here instead.
+	var match func(storedMessage) bool
+	if text := strings.TrimSpace(q.Get("q")); text != "" && currentStoreCipher() != nil {
+		lower := strings.ToLower(text)
+		match = func(m storedMessage) bool {
+			return strings.Contains(strings.ToLower(m.Text), lower) || strings.Contains(strings.ToLower(m.Transcript), lower)
+		}
+	} else if text != "" {
+		query += ` AND (text LIKE ? ESCAPE '\' OR transcript LIKE ? ESCAPE '\')`
+		args = append(args, likePattern(text), likePattern(text))
+	}
+	if chat := q.Get("chat"); chat != "" {
+		jid, ok := parseJID(chat)
+		if !ok {
+			http.Error(w, "Invalid chat JID", http.StatusBadRequest)
+			return
+		}
+		query += ` AND chat_jid = ?`
+		args = append(args, canonicalJID(r.Context(), jid).String())
+	}
+	for _, tag := range q["tag"] {
+		query += ` AND chat_jid IN (SELECT chat_jid FROM chat_tags WHERE tag = ?)`
+		args = append(args, normalizeTag(tag))
+	}
+	if typ := q.Get("type"); typ != "" {
+		query += ` AND type = ?`
+		args = append(args, typ)
+	}
+	for _, bound := range []struct{ param, op string }{{"since", ">="}, {"until", "<"}} {
+		if v := q.Get(bound.param); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				http.Error(w, "Invalid "+bound.param+", expected RFC 3339", http.StatusBadRequest)
+				return
+			}
+			query += ` AND timestamp ` + bound.op + ` ?`
+			args = append(args, t.Unix())
+		}
+	}
+	limit := 100
+	if l := q.Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 || n > 1000 {
+			http.Error(w, "Invalid limit, expected 1-1000", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	query += ` ORDER BY timestamp DESC`
+	if match == nil {
+		query += ` LIMIT ?`
+		args = append(args, limit)
+	}
+
+	messages, err := queryMatchingMessages(r.Context(), match, limit, query, args...)
+	if err != nil {
+		waLogger.Errorf("Failed to search messages: %v", err)
+		http.Error(w, "Failed to search messages", http.StatusInternalServerError)
+		return
+	}
+	if err := attachMessageNotes(r.Context(), messages); err != nil {
+		waLogger.Errorf("Failed to load message notes: %v", err)
+		http.Error(w, "Failed to search messages", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"messages": messages, "enabled": messageStoreEnabled})
+}
+
+// storeSentMessage records a message sent through the API; WhatsApp does not
+// echo those back as events.
+func storeSentMessage(ctx context.Context, to types.JID, msg *waE2E.Message, res sendResult) {
+	storeMessage(ctx, storedMessage{
+		ID:        res.ID,
+		Chat:      to.ToNonAD().String(),
+		FromMe:    true,
+		Type:      messageType(msg),
+		Text:      messageText(msg),
+		Timestamp: res.Timestamp,
+	})
+}
+
+// storedMessageByID looks a message up in the message store, if it's on.
+func storedMessageByID(ctx context.Context, chat types.JID, id string) (storedMessage, bool) {
+	if !messageStoreEnabled || gatewayDB == nil {
+		return storedMessage{}, false
+	}
+	stored, err := queryStoredMessages(ctx, `WHERE chat_jid = ? AND id = ?`, chat.ToNonAD().String(), id)
+	if err != nil {
+		waLogger.Errorf("Failed to look up message %s in %s: %v", id, chat, err)
+		return storedMessage{}, false
+	}
+	if len(stored) == 0 {
+		return storedMessage{}, false
+	}
+	return stored[0], true
+}
+
+// ownMessage finds a message we sent by ID, in the message store or, for
+// messages that went through the outbound queue, there. chat may be empty
+// when the caller doesn't know it.
+func ownMessage(ctx context.Context, chat types.JID, id string) (storedMessage, bool) {
+	if gatewayDB == nil {
+		return storedMessage{}, false
+	}
+	if messageStoreEnabled {
+		query, args := `WHERE id = ? AND from_me = 1`, []interface{}{id}
+		if !chat.IsEmpty() {
+			query += ` AND chat_jid = ?`
+			args = append(args, chat.ToNonAD().String())
+		}
+		stored, err := queryStoredMessages(ctx, query+` LIMIT 2`, args...)
+		if err != nil {
+			waLogger.Errorf("Failed to look up message %s: %v", id, err)
+		} else if len(stored) == 1 {
+			return stored[0], true
+		}
+	}
+	m := storedMessage{ID: id, FromMe: true}
+	var sentAt int64
+	err := gatewayDB.QueryRowContext(ctx,
+		`SELECT recipient, sent_at FROM outbound_queue WHERE message_id = ? AND status = 'sent'`, id).
+		Scan(&m.Chat, &sentAt)
+	if err != nil {
+		return storedMessage{}, false
+	}
+	if !chat.IsEmpty() && m.Chat != chat.ToNonAD().String() {
+		return storedMessage{}, false
+	}
+	m.Timestamp = time.Unix(sentAt, 0)
+	return m, true
+}
+
+// messageSender works out who sent a message WhatsApp identifies by chat, ID
+// and sender, for reacting to or quoting it. Without an explicit sender it
+// comes from the message store, or in private chats is taken to be the
+// contact (or us, with fromMe); in groups it's required unless fromMe.
+func messageSender(ctx context.Context, chat types.JID, id, sender string, fromMe bool) (types.JID, error) {
+	if sender != "" {
+		jid, err := parseRecipient(sender)
+		if err != nil {
+			return jid, fmt.Errorf("invalid sender: %w", err)
+		}
+		return jid, nil
+	}
+	own := types.EmptyJID
+	if client != nil && client.Store.ID != nil {
+		own = client.Store.ID.ToNonAD()
+	}
+	if fromMe {
+		return own, nil
+	}
+	if stored, ok := storedMessageByID(ctx, chat, id); ok {
+		if stored.FromMe {
+			return own, nil
+		}
+		if jid, err := types.ParseJID(stored.Sender); err == nil && stored.Sender != "" {
+			return jid, nil
+		}
+	}
+	if chat.Server == types.DefaultUserServer || chat.Server == types.HiddenUserServer {
+		return chat, nil
+	}
+	return types.EmptyJID, fmt.Errorf("sender is required for messages in %s chats", chat.Server)
+}
+
+// quotedContext makes an outgoing message a reply to an earlier one. The
+// quoted text comes from the message store when it's on; without it the
+// recipient's app shows the original from its own history.
+func quotedContext(ctx context.Context, chat types.JID, id, sender string) (*waE2E.ContextInfo, error) {
+	participant, err := messageSender(ctx, chat, id, sender, false)
+	if err != nil {
+		return nil, err
+	}
+	info := &waE2E.ContextInfo{
+		StanzaID:    proto.String(id),
+		Participant: proto.String(participant.ToNonAD().String()),
+	}
+	if stored, ok := storedMessageByID(ctx, chat, id); ok && stored.Text != "" {
+		info.QuotedMessage = &waE2E.Message{Conversation: proto.String(stored.Text)}
+	}
+	return info, nil
+}
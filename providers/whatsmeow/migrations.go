@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/pressly/goose/v3"
+)
+
+// Gateway tables are versioned with goose, separately from the whatsmeow
+// store which upgrades its own schema. Add new files as
+// migrations/NNNNN_description.sql; they're applied in order on startup.
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+var gatewayMigrations *goose.Provider
+
+func migrateGatewayDB(ctx context.Context, db *sql.DB) error {
+	fsys, err := fs.Sub(migrationFiles, "migrations")
+	if err != nil {
+		return err
+	}
+	provider, err := goose.NewProvider(goose.DialectSQLite3, db, fsys,
+		goose.WithTableName("gateway_schema_version"))
+	if err != nil {
+		return fmt.Errorf("failed to load gateway migrations: %w", err)
+	}
+	results, err := provider.Up(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to migrate gateway database: %w", err)
+	}
+	for _, res := range results {
+		waLogger.Infof("Applied gateway migration %s", res)
+	}
+	gatewayMigrations = provider
+	return nil
+}
+
+type migrationStatus struct {
+	Version   int64      `json:"version"`
+	Name      string     `json:"name"`
+	State     string     `json:"state"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+func getMigrations(w http.ResponseWriter, r *http.Request) {
+	current, target, err := gatewayMigrations.GetVersions(r.Context())
+	if err != nil {
+		waLogger.Errorf("Failed to read migration versions: %v", err)
+		http.Error(w, "Failed to read migration status", http.StatusInternalServerError)
+		return
+	}
+	statuses, err := gatewayMigrations.Status(r.Context())
+	if err != nil {
+		waLogger.Errorf("Failed to read migration status: %v", err)
+		http.Error(w, "Failed to read migration status", http.StatusInternalServerError)
+		return
+	}
+	migrations := make([]migrationStatus, 0, len(statuses))
+	for _, s := range statuses {
+		m := migrationStatus{
+			Version: s.Source.Version,
+			Name:    filepath.Base(s.Source.Path),
+			State:   string(s.State),
+		}
+		if s.State == goose.StateApplied {
+			appliedAt := s.AppliedAt
+			m.AppliedAt = &appliedAt
+		}
+		migrations = append(migrations, m)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"current_version": current,
+		"target_version":  target,
+		"migrations":      migrations,
+	})
+}
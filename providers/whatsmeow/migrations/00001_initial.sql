@@ -0,0 +1,88 @@
+-- Baseline of the gateway tables. IF NOT EXISTS keeps it a no-op on
+-- databases created before migrations were tracked.
+
+-- +goose Up
+CREATE TABLE IF NOT EXISTS group_participant_events (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    group_jid   TEXT    NOT NULL,
+    participant TEXT    NOT NULL,
+    action      TEXT    NOT NULL,
+    actor       TEXT    NOT NULL DEFAULT '',
+    reason      TEXT    NOT NULL DEFAULT '',
+    timestamp   INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS group_participant_events_group_idx
+    ON group_participant_events (group_jid, timestamp);
+
+CREATE TABLE IF NOT EXISTS presence_subscriptions (
+    contact    TEXT PRIMARY KEY,
+    created_at INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS presence_events (
+    id        INTEGER PRIMARY KEY AUTOINCREMENT,
+    contact   TEXT    NOT NULL,
+    online    INTEGER NOT NULL,
+    last_seen INTEGER NOT NULL DEFAULT 0,
+    timestamp INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS presence_events_contact_idx
+    ON presence_events (contact, timestamp);
+
+CREATE TABLE IF NOT EXISTS chats (
+    jid              TEXT PRIMARY KEY,
+    name             TEXT    NOT NULL DEFAULT '',
+    status           TEXT    NOT NULL DEFAULT 'open',
+    assignee         TEXT    NOT NULL DEFAULT '',
+    last_message_at  INTEGER NOT NULL DEFAULT 0,
+    last_inbound_at  INTEGER NOT NULL DEFAULT 0,
+    last_outbound_at INTEGER NOT NULL DEFAULT 0,
+    bot_paused       INTEGER NOT NULL DEFAULT 0,
+    bot_paused_at    INTEGER NOT NULL DEFAULT 0,
+    bot_idle_timeout INTEGER NOT NULL DEFAULT 0,
+    updated_at       INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS chats_inbox_idx ON chats (status, assignee);
+
+CREATE TABLE IF NOT EXISTS inbox_agents (
+    id         TEXT PRIMARY KEY,
+    name       TEXT    NOT NULL,
+    email      TEXT    NOT NULL DEFAULT '',
+    created_at INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS gateway_settings (
+    key   TEXT PRIMARY KEY,
+    value TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS outbound_queue (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    recipient  TEXT    NOT NULL,
+    message_id TEXT    NOT NULL,
+    message    BLOB    NOT NULL,
+    priority   INTEGER NOT NULL DEFAULT 0,
+    status     TEXT    NOT NULL DEFAULT 'pending',
+    attempts   INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT    NOT NULL DEFAULT '',
+    created_at INTEGER NOT NULL,
+    sent_at    INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS outbound_queue_pending_idx ON outbound_queue (status, priority, id);
+
+CREATE TABLE IF NOT EXISTS webhook_queue (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    url        TEXT    NOT NULL,
+    payload    BLOB    NOT NULL,
+    attempts   INTEGER NOT NULL DEFAULT 0,
+    created_at INTEGER NOT NULL
+);
+
+-- +goose Down
+DROP TABLE webhook_queue;
+DROP TABLE outbound_queue;
+DROP TABLE gateway_settings;
+DROP TABLE inbox_agents;
+DROP TABLE chats;
+DROP TABLE presence_events;
+DROP TABLE presence_subscriptions;
+DROP TABLE group_participant_events;
@@ -0,0 +1,12 @@
+-- +goose Up
+CREATE TABLE api_keys (
+    id           TEXT PRIMARY KEY,
+    name         TEXT    NOT NULL,
+    key_hash     TEXT    NOT NULL UNIQUE,
+    policy       TEXT    NOT NULL DEFAULT '{}',
+    created_at   INTEGER NOT NULL,
+    last_used_at INTEGER NOT NULL DEFAULT 0
+);
+
+-- +goose Down
+DROP TABLE api_keys;
@@ -0,0 +1,14 @@
+-- +goose Up
+CREATE TABLE newsletter_post_stats (
+    newsletter_jid TEXT    NOT NULL,
+    server_id      INTEGER NOT NULL,
+    message_id     TEXT    NOT NULL DEFAULT '',
+    posted_at      INTEGER NOT NULL DEFAULT 0,
+    views          INTEGER NOT NULL DEFAULT 0,
+    reactions      TEXT    NOT NULL DEFAULT '{}',
+    updated_at     INTEGER NOT NULL,
+    PRIMARY KEY (newsletter_jid, server_id)
+);
+
+-- +goose Down
+DROP TABLE newsletter_post_stats;
@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE chats ADD COLUMN language TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE chats DROP COLUMN language;
@@ -0,0 +1,16 @@
+-- +goose Up
+CREATE TABLE messages (
+    chat_jid   TEXT    NOT NULL,
+    id         TEXT    NOT NULL,
+    sender_jid TEXT    NOT NULL DEFAULT '',
+    from_me    INTEGER NOT NULL DEFAULT 0,
+    type       TEXT    NOT NULL,
+    text       TEXT    NOT NULL DEFAULT '',
+    transcript TEXT    NOT NULL DEFAULT '',
+    timestamp  INTEGER NOT NULL,
+    PRIMARY KEY (chat_jid, id)
+);
+CREATE INDEX messages_timestamp_idx ON messages (timestamp);
+
+-- +goose Down
+DROP TABLE messages;
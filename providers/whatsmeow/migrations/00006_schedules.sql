@@ -0,0 +1,21 @@
+-- +goose Up
+CREATE TABLE schedules (
+    id           INTEGER PRIMARY KEY AUTOINCREMENT,
+    recipient    TEXT    NOT NULL,
+    text         TEXT    NOT NULL,
+    cron         TEXT    NOT NULL DEFAULT '',
+    rrule        TEXT    NOT NULL DEFAULT '',
+    timezone     TEXT    NOT NULL,
+    dtstart      INTEGER NOT NULL,
+    next_run_at  INTEGER NOT NULL DEFAULT 0,
+    last_run_at  INTEGER NOT NULL DEFAULT 0,
+    run_count    INTEGER NOT NULL DEFAULT 0,
+    missed_count INTEGER NOT NULL DEFAULT 0,
+    last_error   TEXT    NOT NULL DEFAULT '',
+    paused       INTEGER NOT NULL DEFAULT 0,
+    created_at   INTEGER NOT NULL
+);
+CREATE INDEX schedules_due_idx ON schedules (paused, next_run_at);
+
+-- +goose Down
+DROP TABLE schedules;
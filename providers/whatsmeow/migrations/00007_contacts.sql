@@ -0,0 +1,12 @@
+-- +goose Up
+CREATE TABLE contacts (
+    jid        TEXT PRIMARY KEY,
+    phone      TEXT    NOT NULL,
+    name       TEXT    NOT NULL DEFAULT '',
+    attributes TEXT    NOT NULL DEFAULT '{}',
+    created_at INTEGER NOT NULL,
+    updated_at INTEGER NOT NULL
+);
+
+-- +goose Down
+DROP TABLE contacts;
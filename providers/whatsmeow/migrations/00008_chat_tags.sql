@@ -0,0 +1,11 @@
+-- +goose Up
+CREATE TABLE chat_tags (
+    chat_jid   TEXT    NOT NULL,
+    tag        TEXT    NOT NULL,
+    created_at INTEGER NOT NULL,
+    PRIMARY KEY (chat_jid, tag)
+);
+CREATE INDEX chat_tags_tag_idx ON chat_tags (tag);
+
+-- +goose Down
+DROP TABLE chat_tags;
@@ -0,0 +1,14 @@
+-- +goose Up
+CREATE TABLE notes (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    chat_jid   TEXT    NOT NULL,
+    message_id TEXT    NOT NULL DEFAULT '', -- empty for notes on the chat itself
+    author     TEXT    NOT NULL DEFAULT '',
+    text       TEXT    NOT NULL,
+    created_at INTEGER NOT NULL,
+    updated_at INTEGER NOT NULL
+);
+CREATE INDEX notes_chat_idx ON notes (chat_jid, message_id);
+
+-- +goose Down
+DROP TABLE notes;
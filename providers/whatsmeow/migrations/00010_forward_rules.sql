@@ -0,0 +1,17 @@
+-- +goose Up
+CREATE TABLE forward_rules (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    name        TEXT    NOT NULL DEFAULT '',
+    source_chat TEXT    NOT NULL,
+    target_chat TEXT    NOT NULL,
+    contains    TEXT    NOT NULL DEFAULT '[]', -- JSON array of keywords
+    pattern     TEXT    NOT NULL DEFAULT '',
+    types       TEXT    NOT NULL DEFAULT '[]', -- JSON array of message types
+    prefix      TEXT    NOT NULL DEFAULT '',
+    enabled     INTEGER NOT NULL DEFAULT 1,
+    created_at  INTEGER NOT NULL
+);
+CREATE INDEX forward_rules_source_idx ON forward_rules (source_chat);
+
+-- +goose Down
+DROP TABLE forward_rules;
@@ -0,0 +1,18 @@
+-- +goose Up
+CREATE TABLE group_moderation (
+    group_jid  TEXT    PRIMARY KEY,
+    rules      TEXT    NOT NULL, -- JSON moderationRules
+    updated_at INTEGER NOT NULL
+);
+
+CREATE TABLE moderation_offenses (
+    group_jid       TEXT    NOT NULL,
+    participant     TEXT    NOT NULL,
+    offenses        INTEGER NOT NULL DEFAULT 0,
+    last_offense_at INTEGER NOT NULL,
+    PRIMARY KEY (group_jid, participant)
+);
+
+-- +goose Down
+DROP TABLE moderation_offenses;
+DROP TABLE group_moderation;
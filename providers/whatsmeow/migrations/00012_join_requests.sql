@@ -0,0 +1,28 @@
+-- +goose Up
+CREATE TABLE suppressions (
+    phone      TEXT    PRIMARY KEY, -- E.164 digits without the plus
+    reason     TEXT    NOT NULL DEFAULT '',
+    created_at INTEGER NOT NULL
+);
+
+CREATE TABLE join_request_rules (
+    group_jid  TEXT    PRIMARY KEY,
+    rules      TEXT    NOT NULL, -- JSON joinRequestRules
+    updated_at INTEGER NOT NULL
+);
+
+CREATE TABLE join_request_decisions (
+    id           INTEGER PRIMARY KEY AUTOINCREMENT,
+    group_jid    TEXT    NOT NULL,
+    participant  TEXT    NOT NULL,
+    decision     TEXT    NOT NULL, -- approve or reject
+    reason       TEXT    NOT NULL,
+    requested_at INTEGER NOT NULL,
+    decided_at   INTEGER NOT NULL
+);
+CREATE INDEX join_request_decisions_group_idx ON join_request_decisions (group_jid, decided_at);
+
+-- +goose Down
+DROP TABLE join_request_decisions;
+DROP TABLE join_request_rules;
+DROP TABLE suppressions;
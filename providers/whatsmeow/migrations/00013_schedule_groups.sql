@@ -0,0 +1,10 @@
+-- +goose Up
+-- Announcement schedules send to a set of groups instead of one recipient.
+ALTER TABLE schedules ADD COLUMN group_jids TEXT NOT NULL DEFAULT '[]';
+ALTER TABLE schedules ADD COLUMN tag TEXT NOT NULL DEFAULT '';
+ALTER TABLE schedules ADD COLUMN last_results TEXT NOT NULL DEFAULT '[]';
+
+-- +goose Down
+ALTER TABLE schedules DROP COLUMN last_results;
+ALTER TABLE schedules DROP COLUMN tag;
+ALTER TABLE schedules DROP COLUMN group_jids;
@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE TABLE audit_log (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    action     TEXT    NOT NULL,
+    subject    TEXT    NOT NULL DEFAULT '',
+    actor      TEXT    NOT NULL DEFAULT '',
+    details    TEXT    NOT NULL DEFAULT '{}', -- JSON
+    created_at INTEGER NOT NULL
+);
+CREATE INDEX audit_log_action_idx ON audit_log (action, created_at);
+
+-- +goose Down
+DROP TABLE audit_log;
@@ -0,0 +1,21 @@
+-- +goose Up
+CREATE TABLE media_jobs (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    direction  TEXT    NOT NULL,            -- upload or download
+    media_type TEXT    NOT NULL,            -- messageType of the message
+    chat_jid   TEXT    NOT NULL,
+    message_id TEXT    NOT NULL DEFAULT '', -- downloads only
+    message    BLOB    NOT NULL,            -- waE2E.Message to send, or that the media belongs to
+    info       TEXT    NOT NULL DEFAULT '', -- JSON: send options for uploads, message info for downloads
+    spool_path TEXT    NOT NULL DEFAULT '', -- uploads only
+    status     TEXT    NOT NULL DEFAULT 'pending',
+    attempts   INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT    NOT NULL DEFAULT '',
+    created_at INTEGER NOT NULL,
+    updated_at INTEGER NOT NULL
+);
+CREATE UNIQUE INDEX media_jobs_download_idx ON media_jobs (chat_jid, message_id) WHERE direction = 'download';
+CREATE INDEX media_jobs_status_idx ON media_jobs (status, id);
+
+-- +goose Down
+DROP TABLE media_jobs;
@@ -0,0 +1,17 @@
+-- +goose Up
+CREATE TABLE canned_responses (
+    key        TEXT PRIMARY KEY,
+    triggers   TEXT    NOT NULL DEFAULT '[]', -- JSON array of auto-reply keywords
+    created_at INTEGER NOT NULL,
+    updated_at INTEGER NOT NULL
+);
+CREATE TABLE canned_response_variants (
+    key      TEXT NOT NULL,
+    language TEXT NOT NULL, -- BCP 47 tag
+    text     TEXT NOT NULL,
+    PRIMARY KEY (key, language)
+);
+
+-- +goose Down
+DROP TABLE canned_response_variants;
+DROP TABLE canned_responses;
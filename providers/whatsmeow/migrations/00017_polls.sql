@@ -0,0 +1,20 @@
+-- +goose Up
+CREATE TABLE polls (
+    message_id       TEXT PRIMARY KEY,
+    chat_jid         TEXT    NOT NULL,
+    question         TEXT    NOT NULL,
+    options          TEXT    NOT NULL, -- JSON array of option names
+    selectable_count INTEGER NOT NULL DEFAULT 0, -- 0: any number
+    created_at       INTEGER NOT NULL
+);
+CREATE TABLE poll_votes (
+    poll_id    TEXT    NOT NULL,
+    voter      TEXT    NOT NULL,
+    options    TEXT    NOT NULL DEFAULT '[]', -- JSON array; empty once retracted
+    updated_at INTEGER NOT NULL,
+    PRIMARY KEY (poll_id, voter)
+);
+
+-- +goose Down
+DROP TABLE poll_votes;
+DROP TABLE polls;
@@ -0,0 +1,18 @@
+-- +goose Up
+-- One row per conversation: from the first inbound message on a chat with
+-- nothing open until the chat is resolved.
+CREATE TABLE conversations (
+    id                      INTEGER PRIMARY KEY AUTOINCREMENT,
+    chat_jid                TEXT    NOT NULL,
+    assignee                TEXT    NOT NULL DEFAULT '',
+    opened_at               INTEGER NOT NULL,
+    first_response_at       INTEGER NOT NULL DEFAULT 0,
+    resolved_at             INTEGER NOT NULL DEFAULT 0,
+    first_response_breached INTEGER NOT NULL DEFAULT 0, -- sla.breached webhook sent
+    resolution_breached     INTEGER NOT NULL DEFAULT 0
+);
+CREATE UNIQUE INDEX conversations_open_idx ON conversations (chat_jid) WHERE resolved_at = 0;
+CREATE INDEX conversations_opened_idx ON conversations (opened_at);
+
+-- +goose Down
+DROP TABLE conversations;
@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE TABLE webhook_events (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    chat_jid   TEXT    NOT NULL,
+    event      TEXT    NOT NULL,
+    payload    TEXT    NOT NULL, -- webhook body, encrypted like message text
+    created_at INTEGER NOT NULL
+);
+CREATE INDEX webhook_events_chat_idx ON webhook_events (chat_jid, id);
+CREATE INDEX webhook_events_created_idx ON webhook_events (created_at);
+
+-- +goose Down
+DROP TABLE webhook_events;
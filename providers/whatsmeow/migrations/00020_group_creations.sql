@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE TABLE group_creations (
+    group_jid   TEXT PRIMARY KEY,
+    name        TEXT    NOT NULL,
+    segment     TEXT    NOT NULL DEFAULT '{}', -- JSON
+    status      TEXT    NOT NULL DEFAULT 'populating',
+    members     TEXT    NOT NULL DEFAULT '[]', -- JSON membership report
+    created_at  INTEGER NOT NULL,
+    finished_at INTEGER NOT NULL DEFAULT 0
+);
+
+-- +goose Down
+DROP TABLE group_creations;
@@ -0,0 +1,17 @@
+-- +goose Up
+CREATE TABLE quarantined_files (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    chat_jid   TEXT    NOT NULL,
+    message_id TEXT    NOT NULL,
+    sender_jid TEXT    NOT NULL,
+    file_name  TEXT    NOT NULL DEFAULT '',
+    mimetype   TEXT    NOT NULL DEFAULT '',
+    size       INTEGER NOT NULL,
+    sha256     TEXT    NOT NULL,
+    signature  TEXT    NOT NULL, -- what the scanner found
+    path       TEXT    NOT NULL,
+    created_at INTEGER NOT NULL
+);
+
+-- +goose Down
+DROP TABLE quarantined_files;
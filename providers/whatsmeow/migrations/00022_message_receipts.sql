@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE TABLE message_receipts (
+    chat_jid   TEXT    NOT NULL,
+    message_id TEXT    NOT NULL,
+    sender_jid TEXT    NOT NULL, -- who sent the receipt
+    type       TEXT    NOT NULL, -- delivered, read or played
+    timestamp  INTEGER NOT NULL,
+    PRIMARY KEY (chat_jid, message_id, sender_jid, type)
+);
+CREATE INDEX message_receipts_sender_idx ON message_receipts (sender_jid, timestamp);
+
+-- +goose Down
+DROP TABLE message_receipts;
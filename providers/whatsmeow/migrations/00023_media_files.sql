@@ -0,0 +1,21 @@
+-- +goose Up
+CREATE TABLE media_files (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    chat_jid   TEXT    NOT NULL,
+    message_id TEXT    NOT NULL,
+    sender_jid TEXT    NOT NULL DEFAULT '',
+    type       TEXT    NOT NULL,
+    mimetype   TEXT    NOT NULL DEFAULT '',
+    file_name  TEXT    NOT NULL DEFAULT '',
+    size       INTEGER NOT NULL DEFAULT 0,
+    sha256     TEXT    NOT NULL DEFAULT '',
+    message    TEXT    NOT NULL, -- the message with its media keys, encrypted like message text
+    info       TEXT    NOT NULL, -- message info, as JSON
+    path       TEXT    NOT NULL DEFAULT '', -- empty until downloaded
+    created_at INTEGER NOT NULL
+);
+CREATE UNIQUE INDEX media_files_message_idx ON media_files (chat_jid, message_id);
+CREATE INDEX media_files_created_idx ON media_files (created_at);
+
+-- +goose Down
+DROP TABLE media_files;
@@ -0,0 +1,22 @@
+-- +goose Up
+CREATE TABLE tracked_links (
+    token      TEXT PRIMARY KEY,
+    url        TEXT    NOT NULL,
+    recipient  TEXT    NOT NULL,
+    message_id TEXT    NOT NULL DEFAULT '', -- set once sent
+    campaign   TEXT    NOT NULL DEFAULT '',
+    created_at INTEGER NOT NULL
+);
+CREATE INDEX tracked_links_campaign_idx ON tracked_links (campaign);
+CREATE INDEX tracked_links_recipient_idx ON tracked_links (recipient);
+CREATE TABLE link_clicks (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    token      TEXT    NOT NULL,
+    recipient  TEXT    NOT NULL, -- of the link, to erase clicks with it
+    clicked_at INTEGER NOT NULL
+);
+CREATE INDEX link_clicks_token_idx ON link_clicks (token);
+
+-- +goose Down
+DROP TABLE link_clicks;
+DROP TABLE tracked_links;
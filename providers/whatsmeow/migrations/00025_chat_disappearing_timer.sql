@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE chats ADD COLUMN disappearing_timer INTEGER NOT NULL DEFAULT 0; -- seconds, 0: off
+
+-- +goose Down
+ALTER TABLE chats DROP COLUMN disappearing_timer;
@@ -0,0 +1,5 @@
+-- +goose Up
+ALTER TABLE media_files ADD COLUMN from_me INTEGER NOT NULL DEFAULT 0; -- sent media
+
+-- +goose Down
+ALTER TABLE media_files DROP COLUMN from_me;
@@ -0,0 +1,40 @@
+-- +goose Up
+CREATE TABLE surveys (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    name       TEXT    NOT NULL,
+    mode       TEXT    NOT NULL DEFAULT 'reply', -- reply (numbered replies) or poll
+    questions  TEXT    NOT NULL, -- JSON array of surveyQuestion
+    intro      TEXT    NOT NULL DEFAULT '',
+    outro      TEXT    NOT NULL DEFAULT '',
+    created_at INTEGER NOT NULL
+);
+-- One row per contact taking a survey.
+CREATE TABLE survey_runs (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    survey_id   INTEGER NOT NULL,
+    chat_jid    TEXT    NOT NULL,
+    campaign    TEXT    NOT NULL DEFAULT '',
+    status      TEXT    NOT NULL DEFAULT 'active', -- active, completed, expired or cancelled
+    step        INTEGER NOT NULL DEFAULT 0, -- the question awaiting an answer
+    poll_id     TEXT    NOT NULL DEFAULT '', -- the question's poll in poll mode
+    started_at  INTEGER NOT NULL,
+    updated_at  INTEGER NOT NULL,
+    finished_at INTEGER NOT NULL DEFAULT 0
+);
+CREATE UNIQUE INDEX survey_runs_active_idx ON survey_runs (chat_jid) WHERE status = 'active';
+CREATE INDEX survey_runs_survey_idx ON survey_runs (survey_id);
+CREATE INDEX survey_runs_poll_idx ON survey_runs (poll_id);
+CREATE TABLE survey_answers (
+    run_id      INTEGER NOT NULL,
+    question    INTEGER NOT NULL,
+    chat_jid    TEXT    NOT NULL, -- of the run, to erase answers with it
+    answer      TEXT    NOT NULL, -- encrypted like stored messages
+    score       INTEGER, -- NPS answers
+    answered_at INTEGER NOT NULL,
+    PRIMARY KEY (run_id, question)
+);
+
+-- +goose Down
+DROP TABLE survey_answers;
+DROP TABLE survey_runs;
+DROP TABLE surveys;
@@ -0,0 +1,10 @@
+-- +goose Up
+-- Per-session overrides of the FEATURE_FLAGS defaults.
+CREATE TABLE feature_flags (
+    name       TEXT    PRIMARY KEY,
+    enabled    INTEGER NOT NULL,
+    updated_at INTEGER NOT NULL
+);
+
+-- +goose Down
+DROP TABLE feature_flags;
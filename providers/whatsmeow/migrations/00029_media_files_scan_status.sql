@@ -0,0 +1,7 @@
+-- +goose Up
+-- Inbound documents when a scanner is configured: clean, infected, error or
+-- unscanned (over SCAN_MAX_BYTES). Empty for media that isn't scanned.
+ALTER TABLE media_files ADD COLUMN scan_status TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE media_files DROP COLUMN scan_status;
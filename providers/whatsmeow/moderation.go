@@ -0,0 +1,411 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	"google.golang.org/protobuf/proto"
+)
+
+// Group moderation checks inbound group messages against per-group rules
+// and, where the account is a group admin, deletes offending messages, warns
+// the sender and removes repeat offenders. Messages from group admins are
+// never moderated. Every action taken is reported as a moderation.action
+// webhook.
+
+type moderationRules struct {
+	Enabled        bool     `json:"enabled"`
+	BannedWords    []string `json:"banned_words,omitempty"`
+	BlockLinks     bool     `json:"block_links"`
+	AllowedDomains []string `json:"allowed_domains,omitempty"` // links to these are fine even with block_links
+
+	Delete        bool   `json:"delete"` // delete offending messages for everyone
+	Warn          bool   `json:"warn"`   // reply in the group mentioning the sender
+	WarnMessage   string `json:"warn_message,omitempty"`
+	RemoveAfter   int    `json:"remove_after,omitempty"`   // remove after this many offenses, 0 never
+	OffenseWindow string `json:"offense_window,omitempty"` // offenses older than this are forgotten, empty keeps them
+
+	matcher *contentPolicy
+	window  time.Duration
+}
+
+// The warning may use {mention}, {name}, {reason}, {count} and {limit}.
+const defaultModerationWarning = "{mention} your message broke the group rules: {reason}."
+
+func (m *moderationRules) compile() error {
+	m.window = 0
+	if m.OffenseWindow != "" {
+		d, err := time.ParseDuration(m.OffenseWindow)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("invalid offense_window %q", m.OffenseWindow)
+		}
+		m.window = d
+	}
+	if m.RemoveAfter < 0 {
+		return fmt.Errorf("remove_after must not be negative")
+	}
+	// Banned words and domain checks are the same as for the outbound
+	// content policy.
+	m.matcher = &contentPolicy{Action: "flag", BannedWords: m.BannedWords}
+	if m.BlockLinks {
+		m.matcher.AllowedDomains = append([]string(nil), m.AllowedDomains...)
+	}
+	return m.matcher.compile()
+}
+
+// violations lists the rules a message breaks.
+func (m *moderationRules) violations(text string) []string {
+	found := m.matcher.violations(text)
+	if m.BlockLinks && len(m.AllowedDomains) == 0 {
+		if link := linkRe.FindString(text); link != "" {
+			found = append(found, fmt.Sprintf("contains a link: %s", link))
+		}
+	}
+	return found
+}
+
+func loadModerationRules(ctx context.Context, group types.JID) (*moderationRules, error) {
+	var raw string
+	err := gatewayDB.QueryRowContext(ctx, `SELECT rules FROM group_moderation WHERE group_jid = ?`, group.String()).Scan(&raw)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	rules := &moderationRules{}
+	if err := json.Unmarshal([]byte(raw), rules); err != nil {
+		return nil, err
+	}
+	return rules, rules.compile()
+}
+
+// --- Group admin lookups ---
+
+const groupInfoCacheTTL = 5 * time.Minute
+
+type cachedGroupInfo struct {
+	info      *types.GroupInfo
+	fetchedAt time.Time
+}
+
+var (
+	groupInfoMu    sync.Mutex
+	groupInfoCache = map[types.JID]cachedGroupInfo{}
+)
+
+func cachedGroupInfoFor(ctx context.Context, group types.JID) (*types.GroupInfo, error) {
+	groupInfoMu.Lock()
+	cached, ok := groupInfoCache[group]
+	groupInfoMu.Unlock()
+	if ok && time.Since(cached.fetchedAt) < groupInfoCacheTTL {
+		return cached.info, nil
+	}
+	info, err := client.GetGroupInfo(ctx, group)
+	if err != nil {
+		return nil, err
+	}
+	groupInfoMu.Lock()
+	groupInfoCache[group] = cachedGroupInfo{info: info, fetchedAt: time.Now()}
+	groupInfoMu.Unlock()
+	return info, nil
+}
+
+// forgetGroupInfo drops a group's cached info after a membership or admin
+// change.
+func forgetGroupInfo(group types.JID) {
+	groupInfoMu.Lock()
+	delete(groupInfoCache, group)
+	groupInfoMu.Unlock()
+}
+
+// isGroupAdmin reports whether any of the given users (phone number or LID
+// user parts) is an admin of the group.
+func isGroupAdmin(info *types.GroupInfo, users ...string) bool {
+	for _, p := range info.Participants {
+		if !p.IsAdmin && !p.IsSuperAdmin {
+			continue
+		}
+		for _, user := range users {
+			if user != "" && (p.JID.User == user || p.LID.User == user || p.PhoneNumber.User == user) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func ownUsers() []string {
+	var users []string
+	if client.Store.ID != nil {
+		users = append(users, client.Store.ID.User)
+	}
+	if !client.Store.LID.IsEmpty() {
+		users = append(users, client.Store.LID.User)
+	}
+	return users
+}
+
+// --- Enforcement ---
+
+// recordOffense counts an offense against a participant and returns their
+// offense count within the rules' window.
+func recordOffense(ctx context.Context, group, participant types.JID, window time.Duration) (int, error) {
+	now := time.Now().Unix()
+	since := int64(0)
+	if window > 0 {
+		since = now - int64(window/time.Second)
+	}
+	var count int
+	err := gatewayDB.QueryRowContext(ctx, `
+		INSERT INTO moderation_offenses (group_jid, participant, offenses, last_offense_at) VALUES (?, ?, 1, ?)
+		ON CONFLICT (group_jid, participant) DO UPDATE SET
+			offenses = CASE WHEN moderation_offenses.last_offense_at < ? THEN 1 ELSE moderation_offenses.offenses + 1 END,
+			last_offense_at = excluded.last_offense_at
+		RETURNING offenses`,
+		group.String(), participant.String(), now, since).Scan(&count)
+	return count, err
+}
+
+func emitModerationAction(group, participant types.JID, messageID types.MessageID, action string, reasons []string, offenses int) {
+	emitWebhook("moderation.action", map[string]interface{}{
+		"group":       group.String(),
+		"participant": participant.String(),
+		"message_id":  messageID,
+		"action":      action,
+		"reasons":     reasons,
+		"offenses":    offenses,
+	})
+}
+
+// moderateGroupMessage applies the group's moderation rules to an inbound
+// message. evt is the raw event, whose sender is addressed the way the group
+// addresses it; data carries the normalized chat and sender.
+func moderateGroupMessage(evt *events.Message, data *messageWebhookData) {
+	if gatewayDB == nil || client == nil || !evt.Info.IsGroup || evt.Info.IsFromMe {
+		return
+	}
+	ctx := context.Background()
+	group := evt.Info.Chat
+	rules, err := loadModerationRules(ctx, group)
+	if err != nil {
+		waLogger.Errorf("Failed to load moderation rules of %s: %v", group, err)
+		return
+	}
+	if rules == nil || !rules.Enabled {
+		return
+	}
+	reasons := rules.violations(messageText(evt.Message))
+	if len(reasons) == 0 {
+		return
+	}
+	info, err := cachedGroupInfoFor(ctx, group)
+	if err != nil {
+		waLogger.Errorf("Failed to get info of group %s: %v", group, err)
+		return
+	}
+	if !isGroupAdmin(info, ownUsers()...) {
+		waLogger.Warnf("Not moderating %s: the account is not a group admin", group)
+		return
+	}
+	sender := evt.Info.Sender.ToNonAD()
+	if isGroupAdmin(info, sender.User, data.Info.Sender.User) {
+		return
+	}
+	participant := canonicalJID(ctx, sender)
+	count, err := recordOffense(ctx, group, participant, rules.window)
+	if err != nil {
+		waLogger.Errorf("Failed to record offense of %s in %s: %v", participant, group, err)
+		return
+	}
+	waLogger.Infof("Message %s from %s in %s broke moderation rules: %s", evt.Info.ID, participant, group, strings.Join(reasons, "; "))
+
+	if rules.Delete {
+		revoke := client.BuildRevoke(group, sender, evt.Info.ID)
+		if _, err := client.SendMessage(ctx, group, revoke); err != nil {
+			waLogger.Errorf("Failed to delete message %s in %s: %v", evt.Info.ID, group, err)
+		} else {
+			emitModerationAction(group, participant, evt.Info.ID, "deleted", reasons, count)
+		}
+	}
+	removing := rules.RemoveAfter > 0 && count >= rules.RemoveAfter
+	if rules.Warn && !removing {
+		template := rules.WarnMessage
+		if template == "" {
+			template = defaultModerationWarning
+		}
+		name := data.DisplayName
+		if name == "" {
+			name = "+" + data.Info.Sender.User
+		}
+		limit := "unlimited"
+		if rules.RemoveAfter > 0 {
+			limit = strconv.Itoa(rules.RemoveAfter)
+		}
+		text := strings.NewReplacer(
+			"{mention}", "@"+sender.User,
+			"{name}", name,
+			"{reason}", strings.Join(reasons, "; "),
+			"{count}", strconv.Itoa(count),
+			"{limit}", limit,
+		).Replace(template)
+		warning := &waE2E.Message{ExtendedTextMessage: &waE2E.ExtendedTextMessage{
+			Text:        proto.String(text),
+			ContextInfo: &waE2E.ContextInfo{MentionedJID: []string{sender.String()}},
+		}}
+		if _, err := sendOrQueue(ctx, group, warning, sendOptions{AllowDuplicate: true}); err != nil {
+			waLogger.Errorf("Failed to warn %s in %s: %v", participant, group, err)
+		} else {
+			emitModerationAction(group, participant, evt.Info.ID, "warned", reasons, count)
+		}
+	}
+	if removing {
+		_, err := client.UpdateGroupParticipants(ctx, group, []types.JID{sender}, whatsmeow.ParticipantChangeRemove)
+		if err != nil {
+			waLogger.Errorf("Failed to remove %s from %s: %v", participant, group, err)
+			return
+		}
+		forgetGroupInfo(group)
+		gatewayDB.ExecContext(ctx, `DELETE FROM moderation_offenses WHERE group_jid = ? AND participant = ?`,
+			group.String(), participant.String())
+		emitModerationAction(group, participant, evt.Info.ID, "removed", reasons, count)
+	}
+}
+
+// --- HTTP API ---
+
+func parseGroupPath(w http.ResponseWriter, r *http.Request) (types.JID, bool) {
+	group, ok := parseJID(r.PathValue("jid"))
+	if !ok || group.Server != types.GroupServer {
+		http.Error(w, "Invalid group JID", http.StatusBadRequest)
+		return types.JID{}, false
+	}
+	return group, true
+}
+
+func getGroupModeration(w http.ResponseWriter, r *http.Request) {
+	group, ok := parseGroupPath(w, r)
+	if !ok {
+		return
+	}
+	rules, err := loadModerationRules(r.Context(), group)
+	if err != nil {
+		waLogger.Errorf("Failed to load moderation rules of %s: %v", group, err)
+		http.Error(w, "Failed to load moderation rules", http.StatusInternalServerError)
+		return
+	}
+	if rules == nil {
+		rules = &moderationRules{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"group": group.String(), "rules": rules})
+}
+
+func putGroupModeration(w http.ResponseWriter, r *http.Request) {
+	group, ok := parseGroupPath(w, r)
+	if !ok {
+		return
+	}
+	rules := &moderationRules{}
+	if err := json.NewDecoder(r.Body).Decode(rules); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := rules.compile(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	raw, _ := json.Marshal(rules)
+	_, err := gatewayDB.ExecContext(r.Context(), `
+		INSERT INTO group_moderation (group_jid, rules, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT (group_jid) DO UPDATE SET rules = excluded.rules, updated_at = excluded.updated_at`,
+		group.String(), string(raw), time.Now().Unix())
+	if err != nil {
+		waLogger.Errorf("Failed to save moderation rules of %s: %v", group, err)
+		http.Error(w, "Failed to save moderation rules", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"group": group.String(), "rules": rules})
+}
+
+func deleteGroupModeration(w http.ResponseWriter, r *http.Request) {
+	group, ok := parseGroupPath(w, r)
+	if !ok {
+		return
+	}
+	_, err := gatewayDB.ExecContext(r.Context(), `DELETE FROM group_moderation WHERE group_jid = ?`, group.String())
+	if err == nil {
+		_, err = gatewayDB.ExecContext(r.Context(), `DELETE FROM moderation_offenses WHERE group_jid = ?`, group.String())
+	}
+	if err != nil {
+		waLogger.Errorf("Failed to delete moderation rules of %s: %v", group, err)
+		http.Error(w, "Failed to delete moderation rules", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func listModerationOffenses(w http.ResponseWriter, r *http.Request) {
+	group, ok := parseGroupPath(w, r)
+	if !ok {
+		return
+	}
+	rows, err := gatewayDB.QueryContext(r.Context(),
+		`SELECT participant, offenses, last_offense_at FROM moderation_offenses WHERE group_jid = ? ORDER BY last_offense_at DESC`,
+		group.String())
+	if err != nil {
+		waLogger.Errorf("Failed to list offenses in %s: %v", group, err)
+		http.Error(w, "Failed to list offenses", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	type offense struct {
+		Participant   string    `json:"participant"`
+		Offenses      int       `json:"offenses"`
+		LastOffenseAt time.Time `json:"last_offense_at"`
+	}
+	offenses := []offense{}
+	for rows.Next() {
+		var o offense
+		var last int64
+		if err := rows.Scan(&o.Participant, &o.Offenses, &last); err != nil {
+			waLogger.Errorf("Failed to scan offense: %v", err)
+			http.Error(w, "Failed to list offenses", http.StatusInternalServerError)
+			return
+		}
+		o.LastOffenseAt = time.Unix(last, 0).UTC()
+		offenses = append(offenses, o)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"group": group.String(), "offenses": offenses})
+}
+
+// pardonParticipant clears a participant's offense count.
+func pardonParticipant(w http.ResponseWriter, r *http.Request) {
+	group, ok := parseGroupPath(w, r)
+	if !ok {
+		return
+	}
+	jid, ok := parseJID(r.PathValue("participant"))
+	if !ok {
+		http.Error(w, "Invalid participant JID", http.StatusBadRequest)
+		return
+	}
+	participant := canonicalJID(r.Context(), jid)
+	_, err := gatewayDB.ExecContext(r.Context(),
+		`DELETE FROM moderation_offenses WHERE group_jid = ? AND participant = ?`, group.String(), participant.String())
+	if err != nil {
+		waLogger.Errorf("Failed to pardon %s in %s: %v", participant, group, err)
+		http.Error(w, "Failed to pardon participant", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
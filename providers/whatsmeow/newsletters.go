@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// Post metrics of owned channels: views and reaction counts are polled
+// periodically and kept current in between through live updates, which
+// WhatsApp only streams for a limited time per subscription.
+
+const newsletterStatsPollInterval = 10 * time.Minute
+
+type newsletterPostStats struct {
+	NewsletterJID string         `json:"newsletter_jid"`
+	ServerID      int            `json:"server_id"`
+	MessageID     string         `json:"message_id,omitempty"`
+	PostedAt      *time.Time     `json:"posted_at,omitempty"`
+	Views         int            `json:"views"`
+	Reactions     map[string]int `json:"reactions"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+}
+
+func recordNewsletterMessages(ctx context.Context, newsletter types.JID, msgs []*types.NewsletterMessage) {
+	now := time.Now().Unix()
+	for _, msg := range msgs {
+		reactions := []byte("{}")
+		if len(msg.ReactionCounts) > 0 {
+			reactions, _ = json.Marshal(msg.ReactionCounts)
+		}
+		var postedAt int64
+		if !msg.Timestamp.IsZero() {
+			postedAt = msg.Timestamp.Unix()
+		}
+		// Live updates may carry only the counter that changed, so never
+		// overwrite known values with missing ones.
+		_, err := gatewayDB.ExecContext(ctx, `
+			INSERT INTO newsletter_post_stats (newsletter_jid, server_id, message_id, posted_at, views, reactions, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT (newsletter_jid, server_id) DO UPDATE SET
+				message_id = CASE WHEN excluded.message_id = '' THEN message_id ELSE excluded.message_id END,
+				posted_at  = CASE WHEN excluded.posted_at = 0 THEN posted_at ELSE excluded.posted_at END,
+				views      = MAX(views, excluded.views),
+				reactions  = CASE WHEN excluded.reactions = '{}' THEN reactions ELSE excluded.reactions END,
+				updated_at = excluded.updated_at`,
+			newsletter.String(), msg.MessageServerID, msg.MessageID, postedAt, msg.ViewsCount, string(reactions), now)
+		if err != nil {
+			waLogger.Errorf("Failed to record stats of post %d in %s: %v", msg.MessageServerID, newsletter, err)
+		}
+	}
+}
+
+// ownedNewsletters lists the channels this account can see metrics for.
+func ownedNewsletters(ctx context.Context) ([]types.JID, error) {
+	subscribed, err := client.GetSubscribedNewsletters(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var owned []types.JID
+	for _, n := range subscribed {
+		if n.ViewerMeta == nil {
+			continue
+		}
+		if n.ViewerMeta.Role == types.NewsletterRoleOwner || n.ViewerMeta.Role == types.NewsletterRoleAdmin {
+			owned = append(owned, n.ID)
+		}
+	}
+	return owned, nil
+}
+
+var (
+	newsletterLiveMu    sync.Mutex
+	newsletterLiveUntil = make(map[types.JID]time.Time)
+)
+
+// refreshNewsletterStats polls recent posts of every owned channel and
+// renews live-update subscriptions that are about to lapse.
+func refreshNewsletterStats() {
+	if client == nil || !client.IsConnected() {
+		return
+	}
+	ctx := context.Background()
+	owned, err := ownedNewsletters(ctx)
+	if err != nil {
+		waLogger.Warnf("Failed to list owned newsletters: %v", err)
+		return
+	}
+	for _, jid := range owned {
+		msgs, err := client.GetNewsletterMessages(ctx, jid, &whatsmeow.GetNewsletterMessagesParams{Count: 50})
+		if err != nil {
+			waLogger.Warnf("Failed to fetch posts of newsletter %s: %v", jid, err)
+			continue
+		}
+		recordNewsletterMessages(ctx, jid, msgs)
+
+		newsletterLiveMu.Lock()
+		until := newsletterLiveUntil[jid]
+		newsletterLiveMu.Unlock()
+		if time.Until(until) > newsletterStatsPollInterval {
+			continue
+		}
+		dur, err := client.NewsletterSubscribeLiveUpdates(ctx, jid)
+		if err != nil {
+			waLogger.Warnf("Failed to subscribe to live updates of newsletter %s: %v", jid, err)
+			continue
+		}
+		newsletterLiveMu.Lock()
+		newsletterLiveUntil[jid] = time.Now().Add(dur)
+		newsletterLiveMu.Unlock()
+	}
+}
+
+// runNewsletterStatsCollector runs after every (re)connect; live-update
+// subscriptions don't survive a reconnect.
+func runNewsletterStatsCollector() {
+	newsletterLiveMu.Lock()
+	clear(newsletterLiveUntil)
+	newsletterLiveMu.Unlock()
+	refreshNewsletterStats()
+}
+
+func pollNewsletterStats() {
+	for range time.Tick(newsletterStatsPollInterval) {
+		refreshNewsletterStats()
+	}
+}
+
+func scanNewsletterPostStats(scan func(dest ...interface{}) error) (newsletterPostStats, error) {
+	var s newsletterPostStats
+	var postedAt, updatedAt int64
+	var reactions string
+	if err := scan(&s.NewsletterJID, &s.ServerID, &s.MessageID, &postedAt, &s.Views, &reactions, &updatedAt); err != nil {
+		return s, err
+	}
+	s.PostedAt = unixPtr(postedAt)
+	s.UpdatedAt = time.Unix(updatedAt, 0).UTC()
+	s.Reactions = map[string]int{}
+	json.Unmarshal([]byte(reactions), &s.Reactions)
+	return s, nil
+}
+
+const newsletterPostStatsColumns = `newsletter_jid, server_id, message_id, posted_at, views, reactions, updated_at`
+
+func getNewsletterPostStats(w http.ResponseWriter, r *http.Request) {
+	jid, err := types.ParseJID(r.PathValue("jid"))
+	if err != nil || jid.Server != types.NewsletterServer {
+		http.Error(w, "Invalid newsletter JID", http.StatusBadRequest)
+		return
+	}
+	serverID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid post id", http.StatusBadRequest)
+		return
+	}
+	if r.URL.Query().Get("refresh") == "true" && client != nil && client.IsConnected() {
+		msgs, err := client.GetNewsletterMessages(r.Context(), jid, &whatsmeow.GetNewsletterMessagesParams{Count: 1, Before: serverID + 1})
+		if err != nil {
+			waLogger.Warnf("Failed to refresh post %d of newsletter %s: %v", serverID, jid, err)
+		} else {
+			recordNewsletterMessages(r.Context(), jid, msgs)
+		}
+	}
+	row := gatewayDB.QueryRowContext(r.Context(),
+		`SELECT `+newsletterPostStatsColumns+` FROM newsletter_post_stats WHERE newsletter_jid = ? AND server_id = ?`,
+		jid.String(), serverID)
+	stats, err := scanNewsletterPostStats(row.Scan)
+	if err == sql.ErrNoRows {
+		http.Error(w, "No stats recorded for this post", http.StatusNotFound)
+		return
+	} else if err != nil {
+		waLogger.Errorf("Failed to load stats of post %d in %s: %v", serverID, jid, err)
+		http.Error(w, "Failed to load post stats", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
+
+func listNewsletterPostStats(w http.ResponseWriter, r *http.Request) {
+	jid, err := types.ParseJID(r.PathValue("jid"))
+	if err != nil || jid.Server != types.NewsletterServer {
+		http.Error(w, "Invalid newsletter JID", http.StatusBadRequest)
+		return
+	}
+	limit := 50
+	if v, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && v > 0 && v <= 500 {
+		limit = v
+	}
+	rows, err := gatewayDB.QueryContext(r.Context(),
+		`SELECT `+newsletterPostStatsColumns+` FROM newsletter_post_stats WHERE newsletter_jid = ? ORDER BY server_id DESC LIMIT ?`,
+		jid.String(), limit)
+	if err != nil {
+		waLogger.Errorf("Failed to list post stats of %s: %v", jid, err)
+		http.Error(w, "Failed to list post stats", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	posts := []newsletterPostStats{}
+	for rows.Next() {
+		stats, err := scanNewsletterPostStats(rows.Scan)
+		if err != nil {
+			waLogger.Errorf("Failed to scan post stats: %v", err)
+			http.Error(w, "Failed to list post stats", http.StatusInternalServerError)
+			return
+		}
+		posts = append(posts, stats)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"posts": posts})
+}
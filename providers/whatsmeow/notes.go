@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Notes are internal annotations on a chat or on one of its messages. They
+// are stored in the gateway only and are never sent to WhatsApp.
+
+type note struct {
+	ID        int64     `json:"id"`
+	Chat      string    `json:"chat"`
+	MessageID string    `json:"message_id,omitempty"`
+	Author    string    `json:"author,omitempty"`
+	Text      string    `json:"text"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+const noteColumns = `id, chat_jid, message_id, author, text, created_at, updated_at`
+
+func scanNote(scan func(dest ...interface{}) error) (note, error) {
+	var n note
+	var created, updated int64
+	if err := scan(&n.ID, &n.Chat, &n.MessageID, &n.Author, &n.Text, &created, &updated); err != nil {
+		return n, err
+	}
+	n.CreatedAt = time.Unix(created, 0).UTC()
+	n.UpdatedAt = time.Unix(updated, 0).UTC()
+	return n, nil
+}
+
+func queryNotes(ctx context.Context, query string, args ...interface{}) ([]note, error) {
+	rows, err := gatewayDB.QueryContext(ctx, `SELECT `+noteColumns+` FROM notes `+query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	notes := []note{}
+	for rows.Next() {
+		n, err := scanNote(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		notes = append(notes, n)
+	}
+	return notes, rows.Err()
+}
+
+// attachMessageNotes fills in the notes of each message in place.
+func attachMessageNotes(ctx context.Context, messages []storedMessage) error {
+	for i := range messages {
+		notes, err := queryNotes(ctx, `WHERE chat_jid = ? AND message_id = ? ORDER BY created_at, id`,
+			messages[i].Chat, messages[i].ID)
+		if err != nil {
+			return err
+		}
+		if len(notes) > 0 {
+			messages[i].Notes = notes
+		}
+	}
+	return nil
+}
+
+// listChatNotes returns the notes on a chat and its messages, oldest first.
+// ?message_id= narrows it to one message.
+func listChatNotes(w http.ResponseWriter, r *http.Request) {
+	jid, ok := parseJID(r.PathValue("jid"))
+	if !ok {
+		http.Error(w, "Invalid JID", http.StatusBadRequest)
+		return
+	}
+	chat := canonicalJID(r.Context(), jid)
+	query := `WHERE chat_jid = ?`
+	args := []interface{}{chat.String()}
+	if id, ok := r.URL.Query()["message_id"]; ok {
+		query += ` AND message_id = ?`
+		args = append(args, id[0])
+	}
+	notes, err := queryNotes(r.Context(), query+` ORDER BY created_at, id`, args...)
+	if err != nil {
+		waLogger.Errorf("Failed to list notes of %s: %v", chat, err)
+		http.Error(w, "Failed to list notes", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"jid": chat.String(), "notes": notes})
+}
+
+type noteRequest struct {
+	MessageID string `json:"message_id"`
+	Author    string `json:"author"`
+	Text      string `json:"text"`
+}
+
+func createNote(w http.ResponseWriter, r *http.Request) {
+	jid, ok := parseJID(r.PathValue("jid"))
+	if !ok {
+		http.Error(w, "Invalid JID", http.StatusBadRequest)
+		return
+	}
+	var req noteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Text) == "" {
+		http.Error(w, "text is required", http.StatusBadRequest)
+		return
+	}
+	now := time.Now().UTC()
+	n := note{
+		Chat:      canonicalJID(r.Context(), jid).String(),
+		MessageID: req.MessageID,
+		Author:    req.Author,
+		Text:      req.Text,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	res, err := gatewayDB.ExecContext(r.Context(),
+		`INSERT INTO notes (chat_jid, message_id, author, text, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		n.Chat, n.MessageID, n.Author, n.Text, now.Unix(), now.Unix())
+	if err != nil {
+		waLogger.Errorf("Failed to create note on %s: %v", n.Chat, err)
+		http.Error(w, "Failed to create note", http.StatusInternalServerError)
+		return
+	}
+	n.ID, _ = res.LastInsertId()
+	writeJSON(w, http.StatusCreated, n)
+}
+
+func updateNote(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Note not found", http.StatusNotFound)
+		return
+	}
+	var req noteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || strings.TrimSpace(req.Text) == "" {
+		http.Error(w, "text is required", http.StatusBadRequest)
+		return
+	}
+	res, err := gatewayDB.ExecContext(r.Context(),
+		`UPDATE notes SET text = ?, author = CASE WHEN ? <> '' THEN ? ELSE author END, updated_at = ? WHERE id = ?`,
+		req.Text, req.Author, req.Author, time.Now().Unix(), id)
+	if err != nil {
+		waLogger.Errorf("Failed to update note %d: %v", id, err)
+		http.Error(w, "Failed to update note", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		http.Error(w, "Note not found", http.StatusNotFound)
+		return
+	}
+	n, err := scanNote(gatewayDB.QueryRowContext(r.Context(), `SELECT `+noteColumns+` FROM notes WHERE id = ?`, id).Scan)
+	if err != nil && err != sql.ErrNoRows {
+		waLogger.Errorf("Failed to load note %d: %v", id, err)
+		http.Error(w, "Failed to load note", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, n)
+}
+
+func deleteNote(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Note not found", http.StatusNotFound)
+		return
+	}
+	res, err := gatewayDB.ExecContext(r.Context(), `DELETE FROM notes WHERE id = ?`, id)
+	if err != nil {
+		waLogger.Errorf("Failed to delete note %d: %v", id, err)
+		http.Error(w, "Failed to delete note", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		http.Error(w, "Note not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
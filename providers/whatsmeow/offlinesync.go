@@ -0,0 +1,103 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// After (re)connecting, WhatsApp delivers what arrived while we were away
+// before anything new: it announces the backlog (OfflineSyncPreview), sends
+// it, and marks the end (OfflineSyncCompleted). Until then, "no new
+// messages" can't be trusted. GET /sync/offline and /health report where
+// that is, and the sync.offline_preview, sync.offline_progress (every
+// quarter of the backlog) and sync.offline_completed webhooks follow it.
+
+type offlineSyncState struct {
+	// waiting (connected, no preview yet), syncing, done or disconnected
+	State          string     `json:"state"`
+	CaughtUp       bool       `json:"caught_up"`
+	Total          int        `json:"total"`
+	Messages       int        `json:"messages"`
+	Notifications  int        `json:"notifications"`
+	Receipts       int        `json:"receipts"`
+	AppDataChanges int        `json:"app_data_changes"`
+	Processed      int        `json:"processed"`
+	Progress       int        `json:"progress"` // percent, estimated from events received
+	StartedAt      *time.Time `json:"started_at,omitempty"`
+	CompletedAt    *time.Time `json:"completed_at,omitempty"`
+}
+
+var (
+	offlineSyncMu sync.Mutex
+	offlineSync   = offlineSyncState{State: "disconnected"}
+)
+
+func currentOfflineSync() offlineSyncState {
+	offlineSyncMu.Lock()
+	defer offlineSyncMu.Unlock()
+	return offlineSync
+}
+
+// offlineSyncConnected starts waiting for the backlog of a new connection.
+func offlineSyncConnected() {
+	offlineSyncMu.Lock()
+	now := time.Now().UTC()
+	offlineSync = offlineSyncState{State: "waiting", StartedAt: &now}
+	offlineSyncMu.Unlock()
+}
+
+func offlineSyncDisconnected() {
+	offlineSyncMu.Lock()
+	offlineSync.State, offlineSync.CaughtUp = "disconnected", false
+	offlineSyncMu.Unlock()
+}
+
+func offlineSyncPreview(evt *events.OfflineSyncPreview) offlineSyncState {
+	offlineSyncMu.Lock()
+	defer offlineSyncMu.Unlock()
+	offlineSync.State = "syncing"
+	offlineSync.Total, offlineSync.Messages, offlineSync.Notifications = evt.Total, evt.Messages, evt.Notifications
+	offlineSync.Receipts, offlineSync.AppDataChanges = evt.Receipts, evt.AppDataChanges
+	waLogger.Infof("Catching up on %d offline events (%d messages)", evt.Total, evt.Messages)
+	return offlineSync
+}
+
+// countOfflineSyncEvent counts an event received while catching up, and
+// reports the progress when it crosses a quarter of the backlog.
+func countOfflineSyncEvent() {
+	offlineSyncMu.Lock()
+	if offlineSync.State != "syncing" || offlineSync.Total == 0 {
+		offlineSyncMu.Unlock()
+		return
+	}
+	offlineSync.Processed++
+	// Live events arrive in between, so it's an estimate; done says 100.
+	progress := min(99, offlineSync.Processed*100/offlineSync.Total)
+	crossed := progress/25 > offlineSync.Progress/25
+	offlineSync.Progress = progress
+	state := offlineSync
+	offlineSyncMu.Unlock()
+	if crossed {
+		emitWebhook("sync.offline_progress", state)
+	}
+}
+
+func offlineSyncCompleted(evt *events.OfflineSyncCompleted) offlineSyncState {
+	offlineSyncMu.Lock()
+	defer offlineSyncMu.Unlock()
+	now := time.Now().UTC()
+	offlineSync.State, offlineSync.CaughtUp, offlineSync.Progress = "done", true, 100
+	offlineSync.Processed = max(offlineSync.Processed, evt.Count)
+	offlineSync.CompletedAt = &now
+	if offlineSync.StartedAt != nil {
+		waLogger.Infof("Caught up on %d offline events in %s", evt.Count, now.Sub(*offlineSync.StartedAt).Round(time.Millisecond))
+	}
+	return offlineSync
+}
+
+func getOfflineSync(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, currentOfflineSync())
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// The API description and the Go and TypeScript clients in sdk/ are
+// generated from the routes and handlers by gen_sdk.go, so they change with
+// the API rather than being kept in step by hand.
+
+//go:generate go run gen_sdk.go
+
+//go:embed openapi.json
+var openAPISpec []byte
+
+func getOpenAPISpec(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPISpec)
+}
@@ -0,0 +1,365 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"google.golang.org/protobuf/proto"
+)
+
+// All outbound messages go through sendOrQueue. Messages are sent
+// immediately unless dispatch is held (maintenance mode or a /queue pause),
+// in which case they're persisted to outbound_queue and sent by the
+// dispatcher later with the message ID that was handed back to the caller.
+// Messages over a new session's daily warm-up cap wait there too.
+
+const outboundMaxAttempts = 5
+
+type sendResult struct {
+	ID        types.MessageID `json:"id"`
+	Timestamp time.Time       `json:"timestamp,omitempty"`
+	Queued    bool            `json:"queued,omitempty"`
+	QueueID   int64           `json:"queue_id,omitempty"`
+	Warnings  []string        `json:"warnings,omitempty"`
+	MediaID   int64           `json:"media_id,omitempty"` // kept file, see /media/{id}
+
+	TranslatedTo string `json:"translated_to,omitempty"`
+}
+
+// sendOptions are per-request overrides for the send guards.
+type sendOptions struct {
+	AllowDuplicate bool
+	Translate      bool    // into the recipient's detected language
+	TrackLinks     bool    // rewrite URLs into tracked short links
+	Campaign       string  // groups tracked links for analytics
+	Expiration     *uint32 // disappearing after seconds; nil: the chat's timer
+}
+
+var outboundWake = make(chan struct{}, 1)
+
+func wakeDispatcher() {
+	select {
+	case outboundWake <- struct{}{}:
+	default:
+	}
+}
+
+// dispatchHeld reports whether outbound messages must be queued instead of
+// sent right away.
+func dispatchHeld() bool {
+	return maintenanceActive() || queuePaused()
+}
+
+func sendOrQueue(ctx context.Context, to types.JID, msg *waE2E.Message, opts sendOptions) (sendResult, error) {
+	if sessionArchived() {
+		return sendResult{}, errSessionArchived
+	}
+	if err := checkSendPolicy(ctx, to); err != nil {
+		return sendResult{}, err
+	}
+	if err := checkCampaignsEnabled(opts.Campaign); err != nil {
+		return sendResult{}, err
+	}
+	if err := checkCampaignEngagement(ctx, to, opts.Campaign); err != nil {
+		return sendResult{}, err
+	}
+	// ctx carries the caller's API key; don't abort a send halfway because
+	// the HTTP client went away.
+	ctx = context.WithoutCancel(ctx)
+
+	if err := renderMessageTemplate(ctx, to, msg); err != nil {
+		return sendResult{}, err
+	}
+	flagged, err := checkContentPolicy(ctx, to, msg)
+	if err != nil {
+		return sendResult{}, err
+	}
+	warnings := flagged
+	var translatedTo string
+	if opts.Translate {
+		if translatedTo, err = translateOutgoing(ctx, to, msg); err != nil {
+			waLogger.Errorf("Failed to translate message to %s: %v", to, err)
+			warnings = append(warnings, "translation failed, sent untranslated")
+		}
+	}
+	claim, duplicate := claimContent(to, msg)
+	if duplicate && !opts.AllowDuplicate {
+		if duplicateSendAction != "warn" {
+			return sendResult{}, errDuplicateContent
+		}
+		warnings = append(warnings, errDuplicateContent.Error())
+	}
+	applyEphemeralExpiration(ctx, to, msg, opts.Expiration)
+	// After the duplicate check, which the unique short links would defeat.
+	var linkTokens []string
+	if opts.TrackLinks && linkTrackingEnabled() {
+		if linkTokens, err = trackMessageLinks(ctx, to, msg, opts.Campaign); err != nil {
+			releaseContent(claim)
+			return sendResult{}, fmt.Errorf("failed to track links: %w", err)
+		}
+	}
+
+	var res sendResult
+	// Priority traffic let through while reconnecting waits in the queue.
+	disconnected := client == nil || !client.IsConnected()
+	if dispatchHeld() || (disconnected && priorityTraffic(ctx)) {
+		res, err = enqueueOutbound(ctx, to, msg, queuePriority(ctx))
+	} else if !takeWarmupSend(ctx, msg) {
+		res, err = enqueueOutbound(ctx, to, msg, queuePriority(ctx))
+		warnings = append(warnings, "daily warm-up cap reached, queued until tomorrow")
+	} else if res, err = deliverMessage(ctx, to, msg, ""); err != nil {
+		releaseWarmupSend(ctx, msg)
+	}
+	finishTrackedLinks(ctx, linkTokens, res.ID, err == nil)
+	if err != nil {
+		releaseContent(claim)
+		return res, err
+	}
+	if len(flagged) > 0 {
+		emitWebhook("message.flagged", map[string]interface{}{"id": res.ID, "to": to.String(), "violations": flagged})
+	}
+	res.Warnings = warnings
+	res.TranslatedTo = translatedTo
+	return res, nil
+}
+
+// writeSendError maps send guard failures to client errors.
+func writeSendError(w http.ResponseWriter, to types.JID, err error) {
+	switch {
+	case errors.Is(err, errDestinationNotAllowed):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, errDuplicateContent), errors.Is(err, errSessionArchived), errors.Is(err, errCampaignsDisabled):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, errContentPolicy), errors.Is(err, errTemplateValue), errors.Is(err, errLowEngagement):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	default:
+		waLogger.Errorf("Error sending message to %s: %v", to, err)
+		http.Error(w, "Failed to send message", http.StatusInternalServerError)
+	}
+}
+
+func writeSendResult(w http.ResponseWriter, to types.JID, res sendResult) {
+	status := http.StatusOK
+	body := map[string]interface{}{"status": "ok", "id": res.ID}
+	if res.Queued {
+		waLogger.Infof("Message to %s queued (ID: %s)", to, res.ID)
+		status = http.StatusAccepted
+		body["status"] = "queued"
+		body["queued"] = true
+		body["queue_id"] = res.QueueID // for /queue/{id}
+	} else {
+		waLogger.Infof("Message sent to %s (ID: %s, Timestamp: %s)", to, res.ID, res.Timestamp)
+	}
+	if len(res.Warnings) > 0 {
+		body["warnings"] = res.Warnings
+	}
+	if res.TranslatedTo != "" {
+		body["translated_to"] = res.TranslatedTo
+	}
+	if res.MediaID != 0 {
+		body["media_id"] = res.MediaID
+	}
+	writeJSON(w, status, body)
+}
+
+// deliverMessage sends a message to WhatsApp right away. id may be empty to
+// let whatsmeow generate one.
+func deliverMessage(ctx context.Context, to types.JID, msg *waE2E.Message, id types.MessageID) (sendResult, error) {
+	if client == nil || !client.IsConnected() {
+		return sendResult{}, fmt.Errorf("client not connected")
+	}
+	var extra []whatsmeow.SendRequestExtra
+	if id != "" {
+		extra = append(extra, whatsmeow.SendRequestExtra{ID: id})
+	}
+	pendingSends.Add(1)
+	resp, err := client.SendMessage(ctx, to, viewOnceEnvelope(msg), extra...)
+	pendingSends.Add(-1)
+	if err != nil {
+		return sendResult{}, err
+	}
+	res := sendResult{ID: resp.ID, Timestamp: resp.Timestamp}
+	// Reactions acknowledge a message but don't answer the conversation;
+	// edits and revokes change an earlier message rather than adding one.
+	changesEarlier := msg.EditedMessage != nil || msg.ProtocolMessage != nil
+	if msg.ReactionMessage == nil && !changesEarlier {
+		touchChat(ctx, to, "", false, resp.Timestamp)
+	}
+	if !changesEarlier {
+		storeSentMessage(ctx, to, msg, res)
+	}
+	return res, nil
+}
+
+func enqueueOutbound(ctx context.Context, to types.JID, msg *waE2E.Message, priority int) (sendResult, error) {
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		return sendResult{}, fmt.Errorf("failed to marshal message: %w", err)
+	}
+	id := client.GenerateMessageID()
+	res, err := gatewayDB.ExecContext(ctx,
+		`INSERT INTO outbound_queue (recipient, message_id, message, priority, created_at) VALUES (?, ?, ?, ?, ?)`,
+		to.String(), id, encryptStoreBytes(data), priority, time.Now().Unix())
+	if err != nil {
+		return sendResult{}, fmt.Errorf("failed to queue message: %w", err)
+	}
+	queueID, _ := res.LastInsertId()
+	wakeDispatcher()
+	return sendResult{ID: id, Queued: true, QueueID: queueID}, nil
+}
+
+type queuedMessage struct {
+	id        int64
+	recipient types.JID
+	messageID types.MessageID
+	message   *waE2E.Message
+	attempts  int
+}
+
+func nextQueuedMessage(ctx context.Context) (*queuedMessage, error) {
+	var qm queuedMessage
+	var recipient string
+	var data []byte
+	err := gatewayDB.QueryRowContext(ctx,
+		`SELECT id, recipient, message_id, message, attempts FROM outbound_queue
+		WHERE status = 'pending' ORDER BY priority DESC, id LIMIT 1`).
+		Scan(&qm.id, &recipient, &qm.messageID, &data, &qm.attempts)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if qm.recipient, err = types.ParseJID(recipient); err != nil {
+		return &qm, fmt.Errorf("invalid recipient %q: %w", recipient, err)
+	}
+	if data, err = decryptStoreBytes(data); err != nil {
+		return &qm, fmt.Errorf("can't decrypt queued message: %w", err)
+	}
+	qm.message = &waE2E.Message{}
+	if err := proto.Unmarshal(data, qm.message); err != nil {
+		return &qm, fmt.Errorf("invalid queued message: %w", err)
+	}
+	return &qm, nil
+}
+
+// claimQueuedMessage moves a pending message to sending, so it can't be
+// cancelled or reprioritized while it's being sent. It reports false when
+// the message is no longer pending, e.g. cancelled since it was read.
+func claimQueuedMessage(ctx context.Context, id int64) (bool, error) {
+	res, err := gatewayDB.ExecContext(ctx,
+		`UPDATE outbound_queue SET status = 'sending' WHERE id = ? AND status = 'pending'`, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// unclaimQueuedMessage puts a claimed message back in the queue unsent.
+func unclaimQueuedMessage(ctx context.Context, id int64) {
+	_, err := gatewayDB.ExecContext(ctx, `UPDATE outbound_queue SET status = 'pending' WHERE id = ? AND status = 'sending'`, id)
+	if err != nil {
+		waLogger.Errorf("Failed to requeue message %d: %v", id, err)
+	}
+}
+
+// markQueuedMessage records the outcome of sending a claimed message.
+func markQueuedMessage(ctx context.Context, id int64, status string, attempts int, lastErr string) {
+	var sentAt int64
+	if status == "sent" {
+		sentAt = time.Now().Unix()
+	}
+	_, err := gatewayDB.ExecContext(ctx,
+		`UPDATE outbound_queue SET status = ?, attempts = ?, last_error = ?, sent_at = ? WHERE id = ? AND status = 'sending'`,
+		status, attempts, lastErr, sentAt, id)
+	if err != nil {
+		waLogger.Errorf("Failed to update queued message %d: %v", id, err)
+	}
+}
+
+// releaseInterruptedSends returns messages left in sending by a restart to
+// the queue. They keep their message ID, so a message that did go out
+// before the restart isn't shown twice.
+func releaseInterruptedSends(ctx context.Context) {
+	res, err := gatewayDB.ExecContext(ctx, `UPDATE outbound_queue SET status = 'pending' WHERE status = 'sending'`)
+	if err != nil {
+		waLogger.Errorf("Failed to release interrupted sends: %v", err)
+		return
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		waLogger.Warnf("Requeued %d messages whose send was interrupted", n)
+	}
+}
+
+// runOutboundDispatcher drains the outbound queue whenever dispatch isn't
+// held and the client is connected.
+func runOutboundDispatcher() {
+	releaseInterruptedSends(context.Background())
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-outboundWake:
+		case <-ticker.C:
+		}
+		for !dispatchHeld() && client != nil && client.IsConnected() {
+			ctx := context.Background()
+			qm, err := nextQueuedMessage(ctx)
+			if qm == nil && err == nil {
+				break
+			}
+			if qm != nil {
+				claimed, claimErr := claimQueuedMessage(ctx, qm.id)
+				if claimErr != nil {
+					waLogger.Errorf("Failed to claim queued message %d: %v", qm.id, claimErr)
+					break
+				} else if !claimed {
+					continue // cancelled or already taken
+				}
+			}
+			if err != nil {
+				waLogger.Errorf("Failed to load queued message: %v", err)
+				if qm == nil {
+					break
+				}
+				markQueuedMessage(ctx, qm.id, "failed", qm.attempts, err.Error())
+				continue
+			}
+			if !takeWarmupSend(ctx, qm.message) {
+				unclaimQueuedMessage(ctx, qm.id)
+				break // until tomorrow's cap
+			}
+			_, err = deliverMessage(ctx, qm.recipient, qm.message, qm.messageID)
+			if err != nil {
+				releaseWarmupSend(ctx, qm.message)
+				attempts := qm.attempts + 1
+				status := "pending"
+				if attempts >= outboundMaxAttempts {
+					status = "failed"
+				}
+				waLogger.Warnf("Failed to send queued message %d (attempt %d): %v", qm.id, attempts, err)
+				markQueuedMessage(ctx, qm.id, status, attempts, err.Error())
+				break // back off until the next tick
+			}
+			markQueuedMessage(ctx, qm.id, "sent", qm.attempts+1, "")
+		}
+	}
+}
+
+func queuedOutboundCount() int64 {
+	if gatewayDB == nil {
+		return 0
+	}
+	var n int64
+	if err := gatewayDB.QueryRow(`SELECT COUNT(*) FROM outbound_queue WHERE status = 'pending'`).Scan(&n); err != nil {
+		waLogger.Errorf("Failed to count queued messages: %v", err)
+	}
+	return n
+}
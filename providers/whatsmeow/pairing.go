@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/skip2/go-qrcode"
+	"go.mau.fi/whatsmeow"
+)
+
+// watchQRChannel publishes the pairing QR codes for /qr until the QR channel
+// closes. whatsmeow rotates through a fixed number of codes; once the last
+// one expires without a scan the channel sends "timeout" and the pairing
+// attempt is over, which is reported with the qr.expired and pairing.timeout
+// webhooks so onboarding UIs stop showing the stale code.
+func watchQRChannel(qrChan <-chan whatsmeow.QRChannelItem) {
+	started := time.Now()
+	codes := 0
+	var expiresAt time.Time
+	for evt := range qrChan {
+		if evt.Event == whatsmeow.QRChannelEventCode {
+			codes++
+			expiresAt = time.Now().Add(evt.Timeout)
+			qrCodeMutex.Lock()
+			qrCodeStr = evt.Code
+			qrCodeMutex.Unlock()
+			// Also print to console for debugging
+			qr, _ := qrcode.New(evt.Code, qrcode.Medium)
+			fmt.Println("QR code:\n" + qr.ToString(true))
+			continue
+		}
+
+		waLogger.Infof("Login event: %s", evt.Event)
+		// Any other event ends the pairing attempt, so the code is stale.
+		qrCodeMutex.Lock()
+		qrCodeStr = ""
+		qrCodeMutex.Unlock()
+		if evt.Event == whatsmeow.QRChannelTimeout.Event {
+			emitWebhook("qr.expired", map[string]interface{}{
+				"expired_at": expiresAt,
+				"codes":      codes,
+			})
+			emitWebhook("pairing.timeout", map[string]interface{}{
+				"started_at": started,
+				"codes":      codes,
+			})
+		}
+		if evt.Event == whatsmeow.QRChannelSuccess.Event && len(warmupSchedule) > 0 {
+			if err := startWarmup(context.Background()); err != nil {
+				waLogger.Errorf("Failed to start warm-up: %v", err)
+			}
+		}
+	}
+}
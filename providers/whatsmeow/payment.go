@@ -0,0 +1,348 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"google.golang.org/protobuf/proto"
+)
+
+// /send/payment sends an order details message: the items and totals of an
+// order with a "Review and pay" button, the native flow message WhatsApp
+// shows business accounts' payment requests as. Paying in WhatsApp needs a
+// payment configuration set up in WhatsApp Business Manager (India and
+// Brazil only); anywhere else give payment_url, which adds a button opening
+// the tenant's own checkout. Amounts are in hundredths of the currency unit,
+// as with the Cloud API.
+//
+// Payment messages the session receives (payment requests, payments, their
+// declines and cancellations, payment invites, orders from the catalog and
+// invoices) are normalized into the message webhook's payment field and also
+// sent out as payment.<type> webhooks.
+
+const (
+	maxPaymentItems = 100
+	paymentOffset   = 100
+)
+
+var currencyRe = regexp.MustCompile(`^[A-Z]{3}$`)
+
+type paymentItem struct {
+	RetailerID string `json:"retailer_id,omitempty"`
+	Name       string `json:"name"`
+	Amount     int64  `json:"amount"` // per unit
+	Quantity   int    `json:"quantity"`
+}
+
+type sendPaymentRequest struct {
+	To                   string        `json:"to"`
+	ReferenceID          string        `json:"reference_id"`   // the tenant's order or invoice number
+	Type                 string        `json:"type,omitempty"` // physical-goods (default) or digital-goods
+	Currency             string        `json:"currency"`
+	Items                []paymentItem `json:"items"`
+	Tax                  int64         `json:"tax,omitempty"`
+	Shipping             int64         `json:"shipping,omitempty"`
+	Discount             int64         `json:"discount,omitempty"`
+	Header               string        `json:"header,omitempty"`
+	Text                 string        `json:"text,omitempty"` // default: a summary of the order
+	Footer               string        `json:"footer,omitempty"`
+	PaymentConfiguration string        `json:"payment_configuration,omitempty"`
+	PaymentURL           string        `json:"payment_url,omitempty"`
+	AllowDuplicate       bool          `json:"allow_duplicate,omitempty"`
+}
+
+func (req *sendPaymentRequest) validate() error {
+	req.ReferenceID = strings.TrimSpace(req.ReferenceID)
+	if req.ReferenceID == "" || len(req.ReferenceID) > 35 {
+		return fmt.Errorf("reference_id is required, at most 35 characters")
+	}
+	if req.Type == "" {
+		req.Type = "physical-goods"
+	}
+	if req.Type != "physical-goods" && req.Type != "digital-goods" {
+		return fmt.Errorf("type must be physical-goods or digital-goods")
+	}
+	req.Currency = strings.ToUpper(strings.TrimSpace(req.Currency))
+	if !currencyRe.MatchString(req.Currency) {
+		return fmt.Errorf("currency must be an ISO 4217 code such as USD")
+	}
+	if len(req.Items) == 0 || len(req.Items) > maxPaymentItems {
+		return fmt.Errorf("an order needs 1 to %d items", maxPaymentItems)
+	}
+	for i := range req.Items {
+		item := &req.Items[i]
+		if item.Name = strings.TrimSpace(item.Name); item.Name == "" {
+			return fmt.Errorf("item %d has no name", i+1)
+		}
+		if item.Amount < 0 {
+			return fmt.Errorf("item %d has a negative amount", i+1)
+		}
+		if item.Quantity == 0 {
+			item.Quantity = 1
+		}
+		if item.Quantity < 0 {
+			return fmt.Errorf("item %d has a negative quantity", i+1)
+		}
+	}
+	if req.Tax < 0 || req.Shipping < 0 || req.Discount < 0 {
+		return fmt.Errorf("tax, shipping and discount can't be negative")
+	}
+	if req.total() <= 0 {
+		return fmt.Errorf("the order's total must be more than zero")
+	}
+	if req.PaymentURL != "" {
+		if u, err := url.Parse(req.PaymentURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("payment_url must be an http or https URL")
+		}
+	}
+	return nil
+}
+
+func (req *sendPaymentRequest) subtotal() int64 {
+	var sum int64
+	for _, item := range req.Items {
+		sum += item.Amount * int64(item.Quantity)
+	}
+	return sum
+}
+
+func (req *sendPaymentRequest) total() int64 {
+	return req.subtotal() + req.Tax + req.Shipping - req.Discount
+}
+
+// summary is the message text for clients that don't show order details.
+func (req *sendPaymentRequest) summary() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Order %s\n", req.ReferenceID)
+	for _, item := range req.Items {
+		fmt.Fprintf(&b, "\n%d × %s: %s %s", item.Quantity, item.Name,
+			formatMoney(item.Amount*int64(item.Quantity), paymentOffset), req.Currency)
+	}
+	for _, line := range []struct {
+		label  string
+		amount int64
+	}{{"Tax", req.Tax}, {"Shipping", req.Shipping}, {"Discount", -req.Discount}} {
+		if line.amount != 0 {
+			fmt.Fprintf(&b, "\n%s: %s %s", line.label, formatMoney(line.amount, paymentOffset), req.Currency)
+		}
+	}
+	fmt.Fprintf(&b, "\n\nTotal: %s %s", formatMoney(req.total(), paymentOffset), req.Currency)
+	return b.String()
+}
+
+// buildPaymentMessage builds the order details message with its buttons.
+func buildPaymentMessage(req sendPaymentRequest) *waE2E.Message {
+	money := func(value int64) map[string]interface{} {
+		return map[string]interface{}{"value": value, "offset": paymentOffset}
+	}
+	items := make([]map[string]interface{}, 0, len(req.Items))
+	for _, item := range req.Items {
+		items = append(items, map[string]interface{}{
+			"retailer_id": item.RetailerID,
+			"name":        item.Name,
+			"amount":      money(item.Amount),
+			"quantity":    item.Quantity,
+		})
+	}
+	order := map[string]interface{}{
+		"status":   "pending",
+		"items":    items,
+		"subtotal": money(req.subtotal()),
+	}
+	if req.Tax > 0 {
+		order["tax"] = money(req.Tax)
+	}
+	if req.Shipping > 0 {
+		order["shipping"] = money(req.Shipping)
+	}
+	if req.Discount > 0 {
+		order["discount"] = money(req.Discount)
+	}
+	params := map[string]interface{}{
+		"reference_id": req.ReferenceID,
+		"type":         req.Type,
+		"currency":     req.Currency,
+		"total_amount": money(req.total()),
+		"order":        order,
+	}
+	if req.PaymentConfiguration != "" {
+		params["payment_configuration"] = req.PaymentConfiguration
+	}
+	raw, _ := json.Marshal(params)
+	buttons := []*waE2E.InteractiveMessage_NativeFlowMessage_NativeFlowButton{{
+		Name:             proto.String("review_and_pay"),
+		ButtonParamsJSON: proto.String(string(raw)),
+	}}
+	if req.PaymentURL != "" {
+		raw, _ := json.Marshal(map[string]string{"display_text": "Pay now", "url": req.PaymentURL, "merchant_url": req.PaymentURL})
+		buttons = append(buttons, &waE2E.InteractiveMessage_NativeFlowMessage_NativeFlowButton{
+			Name:             proto.String("cta_url"),
+			ButtonParamsJSON: proto.String(string(raw)),
+		})
+	}
+
+	text := req.Text
+	if strings.TrimSpace(text) == "" {
+		text = req.summary()
+	}
+	interactive := &waE2E.InteractiveMessage{
+		Body: &waE2E.InteractiveMessage_Body{Text: proto.String(text)},
+		InteractiveMessage: &waE2E.InteractiveMessage_NativeFlowMessage_{
+			NativeFlowMessage: &waE2E.InteractiveMessage_NativeFlowMessage{
+				Buttons:        buttons,
+				MessageVersion: proto.Int32(1),
+			},
+		},
+	}
+	if req.Header != "" {
+		interactive.Header = &waE2E.InteractiveMessage_Header{Title: proto.String(req.Header), HasMediaAttachment: proto.Bool(false)}
+	}
+	if req.Footer != "" {
+		interactive.Footer = &waE2E.InteractiveMessage_Footer{Text: proto.String(req.Footer)}
+	}
+	return &waE2E.Message{InteractiveMessage: interactive}
+}
+
+func sendPayment(w http.ResponseWriter, r *http.Request) {
+	if sessionArchived() {
+		http.Error(w, "Session is archived", http.StatusConflict)
+		return
+	}
+	if !requireClient(w, r, true) {
+		return
+	}
+	var req sendPaymentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := req.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	recipient, err := parseRecipient(req.To)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	recipient = toPhoneJID(r.Context(), recipient)
+
+	res, err := sendOrQueue(r.Context(), recipient, buildPaymentMessage(req), sendOptions{AllowDuplicate: req.AllowDuplicate})
+	if err != nil {
+		writeSendError(w, recipient, err)
+		return
+	}
+	writeSendResult(w, recipient, res)
+}
+
+// formatMoney writes an amount given in 1/offset units as a decimal.
+func formatMoney(value int64, offset int64) string {
+	sign := ""
+	if value < 0 {
+		sign, value = "-", -value
+	}
+	decimals := len(strconv.FormatInt(offset, 10)) - 1
+	if offset <= 1 || decimals == 0 {
+		return sign + strconv.FormatInt(value, 10)
+	}
+	return fmt.Sprintf("%s%d.%0*d", sign, value/offset, decimals, value%offset)
+}
+
+type paymentNotification struct {
+	Type      string     `json:"type"` // request, sent, declined, cancelled, invite, order or invoice
+	Amount    string     `json:"amount,omitempty"`
+	Currency  string     `json:"currency,omitempty"`
+	Note      string     `json:"note,omitempty"`
+	RequestID string     `json:"request_id,omitempty"` // the payment request a payment, decline or cancellation is about
+	From      string     `json:"request_from,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Service   string     `json:"service,omitempty"` // payment invites
+
+	OrderID   string `json:"order_id,omitempty"`
+	Title     string `json:"title,omitempty"`
+	ItemCount int32  `json:"item_count,omitempty"`
+	Status    string `json:"status,omitempty"`
+	Seller    string `json:"seller,omitempty"`
+
+	AttachmentType     string `json:"attachment_type,omitempty"` // invoices
+	AttachmentMimetype string `json:"attachment_mimetype,omitempty"`
+}
+
+// parsePaymentMessage normalizes the payment and commerce messages into a
+// single shape. It returns nil for any other message type.
+func parsePaymentMessage(msg *waE2E.Message) *paymentNotification {
+	switch {
+	case msg.GetRequestPaymentMessage() != nil:
+		req := msg.GetRequestPaymentMessage()
+		p := &paymentNotification{Type: "request", Note: messageText(req.GetNoteMessage()), From: req.GetRequestFrom()}
+		if amount := req.GetAmount(); amount != nil {
+			p.Amount, p.Currency = formatMoney(amount.GetValue(), int64(max(amount.GetOffset(), 1))), amount.GetCurrencyCode()
+		} else {
+			p.Amount, p.Currency = formatMoney(int64(req.GetAmount1000()), 1000), req.GetCurrencyCodeIso4217()
+		}
+		if exp := req.GetExpiryTimestamp(); exp > 0 {
+			t := time.Unix(exp, 0).UTC()
+			p.ExpiresAt = &t
+		}
+		return p
+	case msg.GetSendPaymentMessage() != nil:
+		sent := msg.GetSendPaymentMessage()
+		return &paymentNotification{Type: "sent", Note: messageText(sent.GetNoteMessage()), RequestID: sent.GetRequestMessageKey().GetID()}
+	case msg.GetDeclinePaymentRequestMessage() != nil:
+		return &paymentNotification{Type: "declined", RequestID: msg.GetDeclinePaymentRequestMessage().GetKey().GetID()}
+	case msg.GetCancelPaymentRequestMessage() != nil:
+		return &paymentNotification{Type: "cancelled", RequestID: msg.GetCancelPaymentRequestMessage().GetKey().GetID()}
+	case msg.GetPaymentInviteMessage() != nil:
+		invite := msg.GetPaymentInviteMessage()
+		p := &paymentNotification{Type: "invite", Service: strings.ToLower(invite.GetServiceType().String())}
+		if exp := invite.GetExpiryTimestamp(); exp > 0 {
+			t := time.Unix(exp, 0).UTC()
+			p.ExpiresAt = &t
+		}
+		return p
+	case msg.GetOrderMessage() != nil:
+		order := msg.GetOrderMessage()
+		p := &paymentNotification{
+			Type:      "order",
+			Note:      order.GetMessage(),
+			OrderID:   order.GetOrderID(),
+			Title:     order.GetOrderTitle(),
+			ItemCount: order.GetItemCount(),
+			Status:    strings.ToLower(order.GetStatus().String()),
+			Seller:    order.GetSellerJID(),
+			Currency:  order.GetTotalCurrencyCode(),
+		}
+		if order.TotalAmount1000 != nil {
+			p.Amount = formatMoney(order.GetTotalAmount1000(), 1000)
+		}
+		return p
+	case msg.GetInvoiceMessage() != nil:
+		invoice := msg.GetInvoiceMessage()
+		return &paymentNotification{
+			Type:               "invoice",
+			Note:               invoice.GetNote(),
+			AttachmentType:     strings.ToLower(invoice.GetAttachmentType().String()),
+			AttachmentMimetype: invoice.GetAttachmentMimetype(),
+		}
+	}
+	return nil
+}
+
+// emitPaymentWebhook sends a received payment message out as its
+// payment.<type> webhook.
+func emitPaymentWebhook(data *messageWebhookData) {
+	emitWebhook("payment."+data.Payment.Type, map[string]interface{}{
+		"id":        data.Info.ID,
+		"chat":      data.Info.Chat.String(),
+		"sender":    data.Info.Sender.ToNonAD().String(),
+		"payment":   data.Payment,
+		"timestamp": data.Info.Timestamp.Unix(),
+	})
+}
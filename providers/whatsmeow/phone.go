@@ -0,0 +1,131 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nyaruka/phonenumbers"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// Recipients given as phone numbers are normalized and validated with
+// libphonenumber before they become JIDs, so separators, a leading + or 00
+// and national formats are all accepted. Numbers without a country code are
+// read as national numbers of PHONE_DEFAULT_REGION (an ISO 3166 code such as
+// BR) when it's set; otherwise, as before, they're taken to start with the
+// country code.
+
+var defaultPhoneRegion = strings.ToUpper(envString("PHONE_DEFAULT_REGION", ""))
+
+var errInvalidRecipient = errors.New("invalid recipient")
+
+// parseRecipient turns a phone number or JID into a JID, saying what's wrong
+// with it when it can't.
+func parseRecipient(to string) (types.JID, error) {
+	to = strings.TrimSpace(to)
+	if to == "" {
+		return types.JID{}, fmt.Errorf("%w: to is required", errInvalidRecipient)
+	}
+	if !strings.ContainsRune(to, '@') {
+		phone, err := normalizeRecipientPhone(to)
+		if err != nil {
+			return types.JID{}, fmt.Errorf("%w %q: %v", errInvalidRecipient, to, err)
+		}
+		return types.NewJID(phone, types.DefaultUserServer), nil
+	}
+	jid, err := types.ParseJID(strings.TrimPrefix(to, "+"))
+	if err != nil {
+		return jid, fmt.Errorf("%w %q: %v", errInvalidRecipient, to, err)
+	}
+	if jid.User == "" {
+		return jid, fmt.Errorf("%w %q: no user specified", errInvalidRecipient, to)
+	}
+	switch jid.Server {
+	case types.DefaultUserServer, types.HiddenUserServer:
+		if onlyDigits(jid.User) != jid.User {
+			return jid, fmt.Errorf("%w %q: user must be digits", errInvalidRecipient, to)
+		}
+	case types.GroupServer, types.NewsletterServer, types.BroadcastServer:
+	default:
+		return jid, fmt.Errorf("%w %q: unknown server %q", errInvalidRecipient, to, jid.Server)
+	}
+	return jid, nil
+}
+
+// normalizeRecipientPhone returns the E.164 digits, without the +, of a
+// phone number.
+func normalizeRecipientPhone(raw string) (string, error) {
+	s := strings.TrimSpace(raw)
+	if strings.HasPrefix(s, "00") {
+		s = "+" + s[2:]
+	}
+	if strings.HasPrefix(s, "+") {
+		return parsePhone(s, "")
+	}
+	if defaultPhoneRegion == "" {
+		return parsePhone("+"+s, "")
+	}
+	phone, err := parsePhone(s, defaultPhoneRegion)
+	if err != nil {
+		// The country code may have been given without the +.
+		if international, ierr := parsePhone("+"+s, ""); ierr == nil {
+			return international, nil
+		}
+	}
+	return phone, err
+}
+
+func parsePhone(s, region string) (string, error) {
+	num, err := phonenumbers.Parse(s, region)
+	switch {
+	case errors.Is(err, phonenumbers.ErrInvalidCountryCode):
+		return "", errors.New("unknown country code")
+	case errors.Is(err, phonenumbers.ErrNotANumber):
+		return "", errors.New("not a phone number")
+	case errors.Is(err, phonenumbers.ErrTooShortNSN), errors.Is(err, phonenumbers.ErrTooShortAfterIDD):
+		return "", errors.New("number is too short")
+	case errors.Is(err, phonenumbers.ErrTooLong):
+		return "", errors.New("number is too long")
+	case err != nil:
+		return "", err
+	}
+	switch phonenumbers.IsPossibleNumberWithReason(num) {
+	case phonenumbers.INVALID_COUNTRY_CODE:
+		return "", errors.New("unknown country code")
+	case phonenumbers.TOO_SHORT:
+		return "", errors.New("number is too short")
+	case phonenumbers.TOO_LONG:
+		return "", errors.New("number is too long")
+	case phonenumbers.INVALID_LENGTH:
+		return "", errors.New("number has the wrong length")
+	}
+	if !phonenumbers.IsValidNumber(num) {
+		if region != "" {
+			return "", fmt.Errorf("not a valid number in %s", region)
+		}
+		return "", fmt.Errorf("not a valid number for country code +%d", num.GetCountryCode())
+	}
+	return strings.TrimPrefix(phonenumbers.Format(num, phonenumbers.E164), "+"), nil
+}
+
+// phoneTimezone returns the timezone of a phone number (E.164 digits), or ""
+// when it can't be told.
+func phoneTimezone(phone string) string {
+	num, err := phonenumbers.Parse("+"+phone, "")
+	if err != nil {
+		return ""
+	}
+	zones, err := phonenumbers.GetTimezonesForNumber(num)
+	if err != nil || len(zones) == 0 {
+		return ""
+	}
+	// Numbers that span several zones, such as mobiles in some countries,
+	// get the first one listed; unknown numbers get Etc/Unknown, which
+	// doesn't load.
+	if _, err := time.LoadLocation(zones[0]); err != nil {
+		return ""
+	}
+	return zones[0]
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// Polls sent through /send/poll, and polls seen in chats, are recorded so
+// votes can be tallied. Votes arrive encrypted and only carry hashes of the
+// chosen options; they're decrypted, mapped back to option names and sent
+// out as poll.vote webhooks with the current tally. A voter's latest vote
+// replaces their earlier one, and an empty selection retracts it.
+
+const maxPollOptions = 12
+
+type poll struct {
+	ID              string         `json:"id"`
+	Chat            string         `json:"chat"`
+	Question        string         `json:"question"`
+	Options         []string       `json:"options"`
+	SelectableCount int            `json:"selectable_count"` // 0: any number
+	Tally           map[string]int `json:"tally"`
+	Voters          int            `json:"voters"`
+	CreatedAt       time.Time      `json:"created_at"`
+}
+
+type sendPollRequest struct {
+	To              string   `json:"to"`
+	Question        string   `json:"question"`
+	Options         []string `json:"options"`
+	SelectableCount int      `json:"selectable_count,omitempty"` // 0: any number
+	AllowDuplicate  bool     `json:"allow_duplicate,omitempty"`
+}
+
+func (req *sendPollRequest) validate() error {
+	req.Question = strings.TrimSpace(req.Question)
+	if req.Question == "" {
+		return fmt.Errorf("question is required")
+	}
+	if len(req.Options) < 2 || len(req.Options) > maxPollOptions {
+		return fmt.Errorf("a poll needs 2 to %d options", maxPollOptions)
+	}
+	seen := map[string]bool{}
+	for i, option := range req.Options {
+		option = strings.TrimSpace(option)
+		if option == "" {
+			return fmt.Errorf("option %d is empty", i+1)
+		}
+		if seen[option] {
+			return fmt.Errorf("duplicate option %q", option)
+		}
+		seen[option] = true
+		req.Options[i] = option
+	}
+	if req.SelectableCount < 0 || req.SelectableCount > len(req.Options) {
+		return fmt.Errorf("selectable_count must be between 0 (any number) and %d", len(req.Options))
+	}
+	return nil
+}
+
+// recordPoll remembers a poll so its votes can be tallied.
+func recordPoll(ctx context.Context, id types.MessageID, chat types.JID, question string, options []string, selectable int, ts time.Time) {
+	if gatewayDB == nil {
+		return
+	}
+	raw, _ := json.Marshal(options)
+	_, err := gatewayDB.ExecContext(ctx, `
+		INSERT INTO polls (message_id, chat_jid, question, options, selectable_count, created_at)
+		VALUES (?, ?, ?, ?, ?, ?) ON CONFLICT (message_id) DO NOTHING`,
+		id, chat.ToNonAD().String(), question, string(raw), selectable, ts.Unix())
+	if err != nil {
+		waLogger.Errorf("Failed to record poll %s: %v", id, err)
+	}
+}
+
+// recordPollMessage records a poll created in a chat, ours or someone
+// else's.
+func recordPollMessage(evt *events.Message) {
+	creation := evt.Message.GetPollCreationMessage()
+	if creation == nil {
+		creation = evt.Message.GetPollCreationMessageV3()
+	}
+	if creation == nil {
+		return
+	}
+	options := make([]string, 0, len(creation.GetOptions()))
+	for _, option := range creation.GetOptions() {
+		options = append(options, option.GetOptionName())
+	}
+	recordPoll(context.Background(), evt.Info.ID, canonicalJID(context.Background(), evt.Info.Chat),
+		creation.GetName(), options, int(creation.GetSelectableOptionsCount()), evt.Info.Timestamp)
+}
+
+func loadPoll(ctx context.Context, id string) (poll, error) {
+	p := poll{ID: id, Tally: map[string]int{}}
+	var options string
+	var created int64
+	err := gatewayDB.QueryRowContext(ctx,
+		`SELECT chat_jid, question, options, selectable_count, created_at FROM polls WHERE message_id = ?`, id).
+		Scan(&p.Chat, &p.Question, &options, &p.SelectableCount, &created)
+	if err != nil {
+		return p, err
+	}
+	json.Unmarshal([]byte(options), &p.Options)
+	p.CreatedAt = time.Unix(created, 0).UTC()
+	for _, option := range p.Options {
+		p.Tally[option] = 0
+	}
+	rows, err := gatewayDB.QueryContext(ctx, `SELECT options FROM poll_votes WHERE poll_id = ?`, id)
+	if err != nil {
+		return p, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var raw string
+		var selected []string
+		if err := rows.Scan(&raw); err != nil {
+			return p, err
+		}
+		if json.Unmarshal([]byte(raw), &selected) != nil || len(selected) == 0 {
+			continue
+		}
+		p.Voters++
+		for _, option := range selected {
+			p.Tally[option]++
+		}
+	}
+	return p, rows.Err()
+}
+
+// handlePollVote decrypts a vote on a recorded poll, stores it and emits
+// the poll.vote webhook.
+func handlePollVote(evt *events.Message) {
+	ctx := context.Background()
+	pollID := evt.Message.GetPollUpdateMessage().GetPollCreationMessageKey().GetID()
+	if gatewayDB == nil || pollID == "" {
+		return
+	}
+	p, err := loadPoll(ctx, pollID)
+	if err == sql.ErrNoRows {
+		waLogger.Debugf("Ignoring vote from %s on unknown poll %s", evt.Info.Sender, pollID)
+		return
+	} else if err != nil {
+		waLogger.Errorf("Failed to load poll %s: %v", pollID, err)
+		return
+	}
+	vote, err := client.DecryptPollVote(ctx, evt)
+	if err != nil {
+		waLogger.Errorf("Failed to decrypt vote from %s on poll %s: %v", evt.Info.Sender, pollID, err)
+		return
+	}
+	hashes := whatsmeow.HashPollOptions(p.Options)
+	selected := []string{}
+	for _, hash := range vote.GetSelectedOptions() {
+		for i, h := range hashes {
+			if bytes.Equal(hash, h) {
+				selected = append(selected, p.Options[i])
+				break
+			}
+		}
+	}
+	voter := canonicalJID(ctx, evt.Info.Sender)
+	raw, _ := json.Marshal(selected)
+	// Votes can arrive out of order after being offline; keep the latest.
+	_, err = gatewayDB.ExecContext(ctx, `
+		INSERT INTO poll_votes (poll_id, voter, options, updated_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (poll_id, voter) DO UPDATE SET options = excluded.options, updated_at = excluded.updated_at
+		WHERE excluded.updated_at >= poll_votes.updated_at`,
+		pollID, voter.String(), string(raw), evt.Info.Timestamp.Unix())
+	if err != nil {
+		waLogger.Errorf("Failed to record vote from %s on poll %s: %v", voter, pollID, err)
+		return
+	}
+	if p, err = loadPoll(ctx, pollID); err != nil {
+		waLogger.Errorf("Failed to load poll %s: %v", pollID, err)
+		return
+	}
+	emitWebhook("poll.vote", map[string]interface{}{
+		"poll_id":   pollID,
+		"chat":      p.Chat,
+		"question":  p.Question,
+		"voter":     voter.String(),
+		"options":   selected,
+		"tally":     p.Tally,
+		"voters":    p.Voters,
+		"timestamp": evt.Info.Timestamp.Unix(),
+	})
+	answerSurveyPoll(ctx, pollID, voter, selected)
+}
+
+func sendPoll(w http.ResponseWriter, r *http.Request) {
+	if sessionArchived() {
+		http.Error(w, "Session is archived", http.StatusConflict)
+		return
+	}
+	if !requireClient(w, r, true) {
+		return
+	}
+	var req sendPollRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := req.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	recipient, err := parseRecipient(req.To)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	recipient = toPhoneJID(r.Context(), recipient)
+
+	msg := client.BuildPollCreation(req.Question, req.Options, req.SelectableCount)
+	res, err := sendOrQueue(r.Context(), recipient, msg, sendOptions{AllowDuplicate: req.AllowDuplicate})
+	if err != nil {
+		writeSendError(w, recipient, err)
+		return
+	}
+	recordPoll(r.Context(), res.ID, recipient, req.Question, req.Options, req.SelectableCount, time.Now())
+	writeSendResult(w, recipient, res)
+}
+
+func getPoll(w http.ResponseWriter, r *http.Request) {
+	p, err := loadPoll(r.Context(), r.PathValue("id"))
+	if err == sql.ErrNoRows {
+		http.Error(w, "Poll not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		waLogger.Errorf("Failed to load poll %s: %v", r.PathValue("id"), err)
+		http.Error(w, "Failed to load poll", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, p)
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// presenceHistoryEnabled turns on presence recording. WhatsApp only pushes
+// contact presence while we announce ourselves as available, so enabling it
+// makes the linked device show as online.
+var presenceHistoryEnabled = envBool("PRESENCE_HISTORY")
+
+type presenceSubscribeRequest struct {
+	JID string `json:"jid"`
+}
+
+func subscribePresence(w http.ResponseWriter, r *http.Request) {
+	var reqBody presenceSubscribeRequest
+	if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil || reqBody.JID == "" {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	jid, ok := parseJID(reqBody.JID)
+	if !ok {
+		http.Error(w, "Invalid JID", http.StatusBadRequest)
+		return
+	}
+	contact := canonicalJID(r.Context(), jid)
+	_, err := gatewayDB.ExecContext(r.Context(),
+		`INSERT INTO presence_subscriptions (contact, created_at) VALUES (?, ?) ON CONFLICT (contact) DO NOTHING`,
+		contact.String(), time.Now().Unix())
+	if err != nil {
+		waLogger.Errorf("Failed to store presence subscription for %s: %v", contact, err)
+		http.Error(w, "Failed to store subscription", http.StatusInternalServerError)
+		return
+	}
+	if presenceHistoryEnabled && client != nil && client.IsConnected() {
+		if err := client.SubscribePresence(r.Context(), contact); err != nil {
+			waLogger.Warnf("Failed to subscribe to presence of %s: %v", contact, err)
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"status": "ok", "jid": contact.String(), "recording": presenceHistoryEnabled})
+}
+
+func unsubscribePresence(w http.ResponseWriter, r *http.Request) {
+	jid, ok := parseJID(r.PathValue("jid"))
+	if !ok {
+		http.Error(w, "Invalid JID", http.StatusBadRequest)
+		return
+	}
+	contact := canonicalJID(r.Context(), jid)
+	if _, err := gatewayDB.ExecContext(r.Context(), `DELETE FROM presence_subscriptions WHERE contact = ?`, contact.String()); err != nil {
+		waLogger.Errorf("Failed to delete presence subscription for %s: %v", contact, err)
+		http.Error(w, "Failed to delete subscription", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func listPresenceSubscriptions(w http.ResponseWriter, r *http.Request) {
+	contacts, err := presenceSubscriptions(r.Context())
+	if err != nil {
+		waLogger.Errorf("Failed to list presence subscriptions: %v", err)
+		http.Error(w, "Failed to list subscriptions", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"subscriptions": contacts, "recording": presenceHistoryEnabled})
+}
+
+func presenceSubscriptions(ctx context.Context) ([]string, error) {
+	rows, err := gatewayDB.QueryContext(ctx, `SELECT contact FROM presence_subscriptions ORDER BY contact`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	contacts := []string{}
+	for rows.Next() {
+		var c string
+		if err := rows.Scan(&c); err != nil {
+			return nil, err
+		}
+		contacts = append(contacts, c)
+	}
+	return contacts, rows.Err()
+}
+
+// resubscribePresence renews presence subscriptions after (re)connecting;
+// WhatsApp drops them together with the websocket.
+func resubscribePresence() {
+	if !presenceHistoryEnabled || gatewayDB == nil {
+		return
+	}
+	ctx := context.Background()
+	contacts, err := presenceSubscriptions(ctx)
+	if err != nil {
+		waLogger.Errorf("Failed to load presence subscriptions: %v", err)
+		return
+	}
+	if len(contacts) == 0 {
+		return
+	}
+	if err := client.SendPresence(ctx, types.PresenceAvailable); err != nil {
+		waLogger.Warnf("Failed to send available presence: %v", err)
+	}
+	for _, c := range contacts {
+		jid, err := types.ParseJID(c)
+		if err != nil {
+			continue
+		}
+		if err := client.SubscribePresence(ctx, jid); err != nil {
+			waLogger.Warnf("Failed to subscribe to presence of %s: %v", c, err)
+		}
+	}
+}
+
+func recordPresence(evt *events.Presence) {
+	if !presenceHistoryEnabled || gatewayDB == nil {
+		return
+	}
+	contact := canonicalJID(context.Background(), evt.From)
+	var lastSeen int64
+	if !evt.LastSeen.IsZero() {
+		lastSeen = evt.LastSeen.Unix()
+	}
+	_, err := gatewayDB.Exec(
+		`INSERT INTO presence_events (contact, online, last_seen, timestamp) VALUES (?, ?, ?, ?)`,
+		contact.String(), !evt.Unavailable, lastSeen, time.Now().Unix())
+	if err != nil {
+		waLogger.Errorf("Failed to record presence of %s: %v", contact, err)
+	}
+}
+
+// --- Presence Analytics ---
+
+type onlineWindow struct {
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	Seconds int64     `json:"seconds"`
+	Ongoing bool      `json:"ongoing,omitempty"`
+}
+
+type presenceSlot struct {
+	Weekday       string  `json:"weekday"`
+	Hour          int     `json:"hour"`
+	OnlineMinutes float64 `json:"online_minutes"`
+}
+
+// onlineWindows pairs online/offline transitions into closed windows. A
+// window still open at the end of the range is closed at "until".
+func onlineWindows(ctx context.Context, contact string, since, until time.Time) ([]onlineWindow, error) {
+	rows, err := gatewayDB.QueryContext(ctx,
+		`SELECT online, timestamp FROM presence_events WHERE contact = ? AND timestamp >= ? AND timestamp < ? ORDER BY timestamp, id`,
+		contact, since.Unix(), until.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	windows := []onlineWindow{}
+	var open *time.Time
+	for rows.Next() {
+		var online bool
+		var ts int64
+		if err := rows.Scan(&online, &ts); err != nil {
+			return nil, err
+		}
+		at := time.Unix(ts, 0).UTC()
+		switch {
+		case online && open == nil:
+			open = &at
+		case !online && open != nil:
+			windows = append(windows, onlineWindow{Start: *open, End: at, Seconds: int64(at.Sub(*open).Seconds())})
+			open = nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if open != nil {
+		windows = append(windows, onlineWindow{Start: *open, End: until, Seconds: int64(until.Sub(*open).Seconds()), Ongoing: true})
+	}
+	return windows, nil
+}
+
+// bestTimeSlots spreads online time over a weekday x hour grid in loc and
+// returns the busiest slots first.
+func bestTimeSlots(windows []onlineWindow, loc *time.Location, top int) ([]presenceSlot, [24]float64) {
+	var grid [7][24]float64
+	var hourly [24]float64
+	for _, win := range windows {
+		cur := win.Start.In(loc)
+		end := win.End.In(loc)
+		for cur.Before(end) {
+			next := cur.Truncate(time.Hour).Add(time.Hour)
+			if next.After(end) {
+				next = end
+			}
+			minutes := next.Sub(cur).Minutes()
+			grid[cur.Weekday()][cur.Hour()] += minutes
+			hourly[cur.Hour()] += minutes
+			cur = next
+		}
+	}
+	var slots []presenceSlot
+	for day := 0; day < 7; day++ {
+		for hour := 0; hour < 24; hour++ {
+			if grid[day][hour] > 0 {
+				slots = append(slots, presenceSlot{Weekday: time.Weekday(day).String(), Hour: hour, OnlineMinutes: grid[day][hour]})
+			}
+		}
+	}
+	sort.SliceStable(slots, func(i, j int) bool { return slots[i].OnlineMinutes > slots[j].OnlineMinutes })
+	if len(slots) > top {
+		slots = slots[:top]
+	}
+	return slots, hourly
+}
+
+func getPresenceAnalytics(w http.ResponseWriter, r *http.Request) {
+	jid, ok := parseJID(r.PathValue("jid"))
+	if !ok {
+		http.Error(w, "Invalid JID", http.StatusBadRequest)
+		return
+	}
+	days := 7
+	if d := r.URL.Query().Get("days"); d != "" {
+		n, err := strconv.Atoi(d)
+		if err != nil || n <= 0 || n > 90 {
+			http.Error(w, "Invalid days, expected 1-90", http.StatusBadRequest)
+			return
+		}
+		days = n
+	}
+	loc, err := requestLocation(r)
+	if err != nil {
+		http.Error(w, "Invalid tz", http.StatusBadRequest)
+		return
+	}
+	contact := canonicalJID(r.Context(), jid).String()
+	until := time.Now().UTC()
+	since := until.AddDate(0, 0, -days)
+	windows, err := onlineWindows(r.Context(), contact, since, until)
+	if err != nil {
+		waLogger.Errorf("Failed to compute presence windows for %s: %v", contact, err)
+		http.Error(w, "Failed to compute presence analytics", http.StatusInternalServerError)
+		return
+	}
+	var total int64
+	for _, win := range windows {
+		total += win.Seconds
+	}
+	best, hourly := bestTimeSlots(windows, loc, 3)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"contact":               contact,
+		"since":                 since,
+		"until":                 until,
+		"timezone":              loc.String(),
+		"online_windows":        windows,
+		"total_online_seconds":  total,
+		"hourly_online_minutes": hourly,
+		"best_time_to_message":  best,
+	})
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"google.golang.org/protobuf/proto"
+)
+
+// The /queue API lets operators look into outbound_queue and intervene, e.g.
+// when a tenant queued a mistaken campaign: dispatch can be paused (sends are
+// then queued, as in maintenance mode), and pending messages reprioritized or
+// cancelled. A message the dispatcher has already picked up (status
+// sending) can't be cancelled any more; the dispatcher claims a message
+// before sending it, so a cancel that succeeded means it won't go out.
+
+var (
+	queuePauseMu     sync.RWMutex
+	queuePausedSince time.Time // zero when dispatching
+)
+
+func queuePaused() bool {
+	queuePauseMu.RLock()
+	defer queuePauseMu.RUnlock()
+	return !queuePausedSince.IsZero()
+}
+
+// loadQueuePause restores a dispatch pause across restarts.
+func loadQueuePause(ctx context.Context) {
+	since, _ := strconv.ParseInt(getSetting(ctx, "queue_paused_since", "0"), 10, 64)
+	if since <= 0 {
+		return
+	}
+	queuePauseMu.Lock()
+	queuePausedSince = time.Unix(since, 0)
+	queuePauseMu.Unlock()
+	waLogger.Warnf("Outbound dispatch is paused since %s; messages are queued", time.Unix(since, 0))
+}
+
+func setQueuePaused(ctx context.Context, paused bool) error {
+	queuePauseMu.Lock()
+	defer queuePauseMu.Unlock()
+	if paused == !queuePausedSince.IsZero() {
+		return nil
+	}
+	since := time.Time{}
+	value := "0"
+	if paused {
+		since = time.Now()
+		value = strconv.FormatInt(since.Unix(), 10)
+	}
+	if err := setSetting(ctx, "queue_paused_since", value); err != nil {
+		return err
+	}
+	queuePausedSince = since
+	return nil
+}
+
+type queueRecord struct {
+	ID        int64      `json:"id"`
+	Recipient string     `json:"recipient"`
+	MessageID string     `json:"message_id"`
+	Type      string     `json:"type"`
+	Text      string     `json:"text,omitempty"`
+	Priority  int        `json:"priority"`
+	Status    string     `json:"status"`
+	Attempts  int        `json:"attempts"`
+	LastError string     `json:"last_error,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	SentAt    *time.Time `json:"sent_at,omitempty"`
+}
+
+const queueRecordColumns = `id, recipient, message_id, message, priority, status, attempts, last_error, created_at, sent_at`
+
+func scanQueueRecord(row interface{ Scan(...interface{}) error }) (queueRecord, error) {
+	var q queueRecord
+	var data []byte
+	var created, sent int64
+	if err := row.Scan(&q.ID, &q.Recipient, &q.MessageID, &data, &q.Priority, &q.Status, &q.Attempts, &q.LastError, &created, &sent); err != nil {
+		return q, err
+	}
+	msg := &waE2E.Message{}
+	if data, err := decryptStoreBytes(data); err == nil && proto.Unmarshal(data, msg) == nil {
+		q.Type, q.Text = messageType(msg), messageText(msg)
+	}
+	q.CreatedAt = time.Unix(created, 0).UTC()
+	q.SentAt = unixPtr(sent)
+	return q, nil
+}
+
+func queueState() map[string]interface{} {
+	queuePauseMu.RLock()
+	since := queuePausedSince
+	queuePauseMu.RUnlock()
+	state := map[string]interface{}{
+		"paused":      !since.IsZero(),
+		"maintenance": maintenanceActive(),
+		"pending":     queuedOutboundCount(),
+	}
+	if !since.IsZero() {
+		state["paused_since"] = since
+	}
+	return state
+}
+
+// listQueue lists queued messages in dispatch order, pending ones by
+// default.
+func listQueue(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = "pending"
+	}
+	limit := 100
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 || n > 1000 {
+			http.Error(w, "Invalid limit, expected 1-1000", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	rows, err := gatewayDB.QueryContext(r.Context(),
+		`SELECT `+queueRecordColumns+` FROM outbound_queue WHERE status = ? ORDER BY priority DESC, id LIMIT ?`, status, limit)
+	if err != nil {
+		waLogger.Errorf("Failed to list queued messages: %v", err)
+		http.Error(w, "Failed to list queue", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	messages := []queueRecord{}
+	for rows.Next() {
+		q, err := scanQueueRecord(rows)
+		if err != nil {
+			waLogger.Errorf("Failed to scan queued message: %v", err)
+			http.Error(w, "Failed to list queue", http.StatusInternalServerError)
+			return
+		}
+		messages = append(messages, q)
+	}
+	state := queueState()
+	state["messages"] = messages
+	writeJSON(w, http.StatusOK, state)
+}
+
+// queueItem loads the queued message named by the path, writing the error
+// response if there's none.
+func queueItem(w http.ResponseWriter, r *http.Request) (queueRecord, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid queue ID", http.StatusBadRequest)
+		return queueRecord{}, false
+	}
+	q, err := scanQueueRecord(gatewayDB.QueryRowContext(r.Context(),
+		`SELECT `+queueRecordColumns+` FROM outbound_queue WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		http.Error(w, "Queued message not found", http.StatusNotFound)
+		return q, false
+	} else if err != nil {
+		waLogger.Errorf("Failed to load queued message %d: %v", id, err)
+		http.Error(w, "Failed to load queued message", http.StatusInternalServerError)
+		return q, false
+	}
+	return q, true
+}
+
+func getQueueItem(w http.ResponseWriter, r *http.Request) {
+	if q, ok := queueItem(w, r); ok {
+		writeJSON(w, http.StatusOK, q)
+	}
+}
+
+// updatePendingItem applies an update to a message that's still pending.
+func updatePendingItem(w http.ResponseWriter, r *http.Request, q queueRecord, query string, args ...interface{}) bool {
+	res, err := gatewayDB.ExecContext(r.Context(), query+` WHERE id = ? AND status = 'pending'`, append(args, q.ID)...)
+	if err != nil {
+		waLogger.Errorf("Failed to update queued message %d: %v", q.ID, err)
+		http.Error(w, "Failed to update queued message", http.StatusInternalServerError)
+		return false
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		http.Error(w, "Queued message is no longer pending", http.StatusConflict)
+		return false
+	}
+	return true
+}
+
+func setQueuePriority(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Priority *int `json:"priority"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Priority == nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	q, ok := queueItem(w, r)
+	if !ok || !updatePendingItem(w, r, q, `UPDATE outbound_queue SET priority = ?`, *req.Priority) {
+		return
+	}
+	q.Priority = *req.Priority
+	writeJSON(w, http.StatusOK, q)
+}
+
+func cancelQueueItem(w http.ResponseWriter, r *http.Request) {
+	q, ok := queueItem(w, r)
+	if !ok || !updatePendingItem(w, r, q, `UPDATE outbound_queue SET status = 'cancelled'`) {
+		return
+	}
+	waLogger.Infof("Cancelled queued message %d to %s", q.ID, q.Recipient)
+	q.Status = "cancelled"
+	writeJSON(w, http.StatusOK, q)
+}
+
+func pauseQueue(w http.ResponseWriter, r *http.Request) {
+	if err := setQueuePaused(r.Context(), true); err != nil {
+		waLogger.Errorf("Failed to pause outbound dispatch: %v", err)
+		http.Error(w, "Failed to pause dispatch", http.StatusInternalServerError)
+		return
+	}
+	waLogger.Warnf("Outbound dispatch paused")
+	writeJSON(w, http.StatusOK, queueState())
+}
+
+func resumeQueue(w http.ResponseWriter, r *http.Request) {
+	if err := setQueuePaused(r.Context(), false); err != nil {
+		waLogger.Errorf("Failed to resume outbound dispatch: %v", err)
+		http.Error(w, "Failed to resume dispatch", http.StatusInternalServerError)
+		return
+	}
+	waLogger.Infof("Outbound dispatch resumed")
+	wakeDispatcher()
+	writeJSON(w, http.StatusOK, queueState())
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// queueTestMux serves the /queue routes.
+func queueTestMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /queue", listQueue)
+	mux.HandleFunc("GET /queue/{id}", getQueueItem)
+	mux.HandleFunc("PUT /queue/{id}/priority", setQueuePriority)
+	mux.HandleFunc("DELETE /queue/{id}", cancelQueueItem)
+	return mux
+}
+
+// queueTestMessage queues a text message as enqueueOutbound does.
+func queueTestMessage(t *testing.T, priority int) int64 {
+	t.Helper()
+	message := []byte{0x0a, 0x05, 'h', 'e', 'l', 'l', 'o'} // conversation "hello"
+	res, err := gatewayDB.Exec(`INSERT INTO outbound_queue (recipient, message_id, message, priority, created_at) VALUES (?, ?, ?, ?, ?)`,
+		"15551234567@s.whatsapp.net", "3EB0"+strconv.Itoa(priority), message, priority, time.Now().Unix())
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, _ := res.LastInsertId()
+	return id
+}
+
+func queueTestStatus(t *testing.T, mux http.Handler, id int64) string {
+	t.Helper()
+	w := request(t, mux, "GET", "/queue/"+strconv.FormatInt(id, 10), "")
+	var q queueRecord
+	if err := json.Unmarshal(w.Body.Bytes(), &q); err != nil {
+		t.Fatalf("GET /queue/%d: %d %s", id, w.Code, w.Body)
+	}
+	return q.Status
+}
+
+func TestCancelWhileDispatchHoldsClaim(t *testing.T) {
+	openTestDB(t)
+	ctx := context.Background()
+	mux := queueTestMux()
+	first, second := queueTestMessage(t, 10), queueTestMessage(t, 0)
+
+	// The dispatcher picks the higher priority message and claims it.
+	qm, err := nextQueuedMessage(ctx)
+	if err != nil || qm == nil || qm.id != first {
+		t.Fatalf("nextQueuedMessage() = %+v, %v, want message %d", qm, err, first)
+	}
+	if claimed, err := claimQueuedMessage(ctx, qm.id); !claimed || err != nil {
+		t.Fatalf("claimQueuedMessage() = %v, %v", claimed, err)
+	}
+
+	// While it's being sent, it can't be cancelled or reprioritized.
+	if w := request(t, mux, "DELETE", "/queue/"+strconv.FormatInt(first, 10), ""); w.Code != http.StatusConflict {
+		t.Errorf("DELETE of the claimed message: %d %s, want 409", w.Code, w.Body)
+	}
+	if w := request(t, mux, "PUT", "/queue/"+strconv.FormatInt(first, 10)+"/priority", `{"priority": 1}`); w.Code != http.StatusConflict {
+		t.Errorf("PUT priority of the claimed message: %d %s, want 409", w.Code, w.Body)
+	}
+	// The message behind it still can.
+	if w := request(t, mux, "DELETE", "/queue/"+strconv.FormatInt(second, 10), ""); w.Code != http.StatusOK {
+		t.Errorf("DELETE of a pending message: %d %s, want 200", w.Code, w.Body)
+	}
+
+	markQueuedMessage(ctx, first, "sent", 1, "")
+	if got := queueTestStatus(t, mux, first); got != "sent" {
+		t.Errorf("claimed message is %s after sending, want sent", got)
+	}
+	if got := queueTestStatus(t, mux, second); got != "cancelled" {
+		t.Errorf("cancelled message is %s, want cancelled", got)
+	}
+	// Nothing is left for the dispatcher.
+	if qm, err := nextQueuedMessage(ctx); qm != nil || err != nil {
+		t.Errorf("nextQueuedMessage() = %+v, %v, want nothing", qm, err)
+	}
+}
+
+func TestCancelBeforeDispatchClaims(t *testing.T) {
+	openTestDB(t)
+	ctx := context.Background()
+	mux := queueTestMux()
+	id := queueTestMessage(t, 0)
+
+	// The dispatcher has read the message, but a cancel comes in before the
+	// claim: the cancel wins and the message isn't sent.
+	qm, err := nextQueuedMessage(ctx)
+	if err != nil || qm == nil {
+		t.Fatalf("nextQueuedMessage() = %+v, %v", qm, err)
+	}
+	if w := request(t, mux, "DELETE", "/queue/"+strconv.FormatInt(id, 10), ""); w.Code != http.StatusOK {
+		t.Fatalf("DELETE: %d %s, want 200", w.Code, w.Body)
+	}
+	if claimed, err := claimQueuedMessage(ctx, qm.id); claimed || err != nil {
+		t.Fatalf("claimQueuedMessage() after the cancel = %v, %v, want false", claimed, err)
+	}
+	// A late outcome from a send doesn't overwrite the cancel either.
+	markQueuedMessage(ctx, id, "sent", 1, "")
+	if got := queueTestStatus(t, mux, id); got != "cancelled" {
+		t.Errorf("message is %s, want cancelled", got)
+	}
+
+	w := request(t, mux, "GET", "/queue", "")
+	var list struct {
+		Pending  int64         `json:"pending"`
+		Messages []queueRecord `json:"messages"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &list)
+	if list.Pending != 0 || len(list.Messages) != 0 {
+		t.Errorf("GET /queue = %s, want nothing pending", w.Body)
+	}
+}
+
+func TestInterruptedSendCanBeCancelled(t *testing.T) {
+	openTestDB(t)
+	ctx := context.Background()
+	mux := queueTestMux()
+	id := queueTestMessage(t, 0)
+	if claimed, err := claimQueuedMessage(ctx, id); !claimed || err != nil {
+		t.Fatalf("claimQueuedMessage() = %v, %v", claimed, err)
+	}
+
+	// A restart puts the message back in the queue, where a cancel reaches it.
+	releaseInterruptedSends(ctx)
+	if got := queueTestStatus(t, mux, id); got != "pending" {
+		t.Fatalf("message is %s after the restart, want pending", got)
+	}
+	if w := request(t, mux, "DELETE", "/queue/"+strconv.FormatInt(id, 10), ""); w.Code != http.StatusOK {
+		t.Errorf("DELETE after the restart: %d %s, want 200", w.Code, w.Body)
+	}
+	if qm, err := nextQueuedMessage(ctx); qm != nil || err != nil {
+		t.Errorf("nextQueuedMessage() = %+v, %v, want nothing", qm, err)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// /send/reaction reacts to a message with an emoji, or removes our reaction
+// with an empty one. The sender of the message can often be left out; see
+// messageSender.
+
+// maxReactionBytes is generous for one emoji with skin tone and ZWJ
+// sequences while keeping text out.
+const maxReactionBytes = 32
+
+type sendReactionRequest struct {
+	Chat      string `json:"chat"`
+	MessageID string `json:"message_id"`
+	Sender    string `json:"sender,omitempty"`
+	FromMe    bool   `json:"from_me,omitempty"`
+	Emoji     string `json:"emoji"` // empty removes the reaction
+}
+
+func sendReaction(w http.ResponseWriter, r *http.Request) {
+	if sessionArchived() {
+		http.Error(w, "Session is archived", http.StatusConflict)
+		return
+	}
+	if !requireClient(w, r, true) {
+		return
+	}
+	var req sendReactionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.MessageID = strings.TrimSpace(req.MessageID)
+	if req.MessageID == "" {
+		http.Error(w, "message_id is required", http.StatusBadRequest)
+		return
+	}
+	if len(req.Emoji) > maxReactionBytes || !utf8.ValidString(req.Emoji) || strings.TrimSpace(req.Emoji) != req.Emoji {
+		http.Error(w, "emoji must be a single emoji, or empty to remove the reaction", http.StatusBadRequest)
+		return
+	}
+	chat, err := parseRecipient(req.Chat)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	chat = toPhoneJID(r.Context(), chat)
+	sender, err := messageSender(r.Context(), chat, req.MessageID, req.Sender, req.FromMe)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	msg := client.BuildReaction(chat, sender, req.MessageID, req.Emoji)
+	res, err := sendOrQueue(r.Context(), chat, msg, sendOptions{})
+	if err != nil {
+		writeSendError(w, chat, err)
+		return
+	}
+	writeSendResult(w, chat, res)
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// With the message store on, every webhook event about a chat (messages, and
+// events naming it as "chat" or "jid") is also journaled, encrypted like
+// message text, for WEBHOOK_EVENT_RETENTION. /chats/{jid}/events/replay
+// re-delivers a chat's journal in order, to WEBHOOK_URL or a one-off URL,
+// e.g. when a CRM lost one customer's thread. Replays carry the original
+// bodies with an X-Webhook-Replay header.
+
+var webhookEventRetention = envDuration("WEBHOOK_EVENT_RETENTION", 30*24*time.Hour)
+
+const maxReplayEvents = 10000
+
+// webhookEventChat returns the chat an event is about, if any.
+func webhookEventChat(data interface{}) string {
+	switch v := data.(type) {
+	case *messageWebhookData:
+		return v.Info.Chat.ToNonAD().String()
+	case *messageEnrichment:
+		return v.Chat.ToNonAD().String()
+	case map[string]string:
+		if v["chat"] != "" {
+			return v["chat"]
+		}
+		return v["jid"]
+	case map[string]interface{}:
+		for _, key := range []string{"chat", "jid"} {
+			if s, ok := v[key].(string); ok && s != "" {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+func journalWebhookEvent(event string, data interface{}, body []byte) {
+	if !messageStoreEnabled || gatewayDB == nil {
+		return
+	}
+	chat := webhookEventChat(data)
+	if chat == "" {
+		return
+	}
+	_, err := gatewayDB.Exec(`INSERT INTO webhook_events (chat_jid, event, payload, created_at) VALUES (?, ?, ?, ?)`,
+		chat, event, encryptStoreValue(string(body)), time.Now().Unix())
+	if err != nil {
+		waLogger.Errorf("Failed to journal %s event: %v", event, err)
+	}
+}
+
+func runWebhookJournalPruner() {
+	if !messageStoreEnabled || webhookEventRetention <= 0 {
+		return
+	}
+	for range time.Tick(time.Hour) {
+		_, err := gatewayDB.Exec(`DELETE FROM webhook_events WHERE created_at < ?`,
+			time.Now().Add(-webhookEventRetention).Unix())
+		if err != nil {
+			waLogger.Errorf("Failed to prune journaled webhook events: %v", err)
+		}
+	}
+}
+
+type journaledEvent struct {
+	ID        int64           `json:"id"`
+	Event     string          `json:"event"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+func queryJournaledEvents(ctx context.Context, where string, args ...interface{}) ([]journaledEvent, error) {
+	rows, err := gatewayDB.QueryContext(ctx,
+		`SELECT id, event, payload, created_at FROM webhook_events WHERE `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	events := []journaledEvent{}
+	for rows.Next() {
+		var e journaledEvent
+		var payload string
+		var created int64
+		if err := rows.Scan(&e.ID, &e.Event, &payload, &created); err != nil {
+			return nil, err
+		}
+		if payload, err = decryptStoreValue(payload); err != nil {
+			return nil, fmt.Errorf("failed to decrypt event %d: %w", e.ID, err)
+		}
+		e.Payload = json.RawMessage(payload)
+		e.CreatedAt = time.Unix(created, 0).UTC()
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// postReplay delivers one journaled event. Replays don't count towards the
+// webhook failure alert.
+func postReplay(ctx context.Context, target string, e journaledEvent) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", target, bytes.NewReader(e.Payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Replay", "true")
+	signWebhook(req, e.Payload)
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook call failed with status: %s", resp.Status)
+	}
+	return nil
+}
+
+type replayRequest struct {
+	URL     string   `json:"url,omitempty"`      // defaults to WEBHOOK_URL
+	AfterID int64    `json:"after_id,omitempty"` // resume after a partial replay
+	Events  []string `json:"events,omitempty"`   // only these event types
+}
+
+// replayChatEvents re-delivers a chat's journaled events in order, stopping
+// at the first failed delivery; last_id lets the caller resume from there.
+func replayChatEvents(w http.ResponseWriter, r *http.Request) {
+	jid, ok := parseJID(r.PathValue("jid"))
+	if !ok {
+		http.Error(w, "Invalid JID", http.StatusBadRequest)
+		return
+	}
+	if !messageStoreEnabled {
+		http.Error(w, "Event journal is disabled, enable MESSAGE_STORE", http.StatusConflict)
+		return
+	}
+	var req replayRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+	target := req.URL
+	if target == "" {
+		target = os.Getenv("WEBHOOK_URL")
+	}
+	if u, err := url.Parse(target); target == "" || err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		http.Error(w, "No webhook URL, set WEBHOOK_URL or pass an http(s) url", http.StatusBadRequest)
+		return
+	}
+
+	chat := canonicalJID(r.Context(), jid)
+	where := `chat_jid = ? AND id > ?`
+	args := []interface{}{chat.String(), req.AfterID}
+	if len(req.Events) > 0 {
+		where += ` AND event IN (SELECT value FROM json_each(?))`
+		events, _ := json.Marshal(req.Events)
+		args = append(args, string(events))
+	}
+	args = append(args, maxReplayEvents)
+	events, err := queryJournaledEvents(r.Context(), where+` ORDER BY id LIMIT ?`, args...)
+	if err != nil {
+		waLogger.Errorf("Failed to load journaled events of %s: %v", chat, err)
+		http.Error(w, "Failed to load events", http.StatusInternalServerError)
+		return
+	}
+
+	result := map[string]interface{}{"jid": chat.String(), "total": len(events), "last_id": req.AfterID}
+	delivered := 0
+	for _, e := range events {
+		if err := postReplay(r.Context(), target, e); err != nil {
+			waLogger.Warnf("Replay of %s stopped at event %d: %v", chat, e.ID, err)
+			result["error"] = err.Error()
+			break
+		}
+		delivered++
+		result["last_id"] = e.ID
+	}
+	result["delivered"] = delivered
+	result["complete"] = delivered == len(events) && len(events) < maxReplayEvents
+	writeJSON(w, http.StatusOK, result)
+}
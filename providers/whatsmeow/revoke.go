@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// /messages/{id}/revoke deletes a message for everyone. Without a sender it
+// revokes one of our own messages; with another participant as sender it
+// revokes their message in a group, which needs us to be a group admin.
+// A revoked message's content is dropped from the message store.
+
+type revokeMessageRequest struct {
+	Chat   string `json:"chat,omitempty"`   // optional for our own messages in the message store or queue
+	Sender string `json:"sender,omitempty"` // someone else's message in a group we administer
+}
+
+func revokeMessage(w http.ResponseWriter, r *http.Request) {
+	if sessionArchived() {
+		http.Error(w, "Session is archived", http.StatusConflict)
+		return
+	}
+	if !requireClient(w, r, true) {
+		return
+	}
+	id := r.PathValue("id")
+	var req revokeMessageRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+	chat := types.EmptyJID
+	if req.Chat != "" {
+		jid, err := parseRecipient(req.Chat)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		chat = toPhoneJID(r.Context(), jid)
+	}
+	sender := types.EmptyJID // ours
+	if req.Sender != "" {
+		jid, err := parseRecipient(req.Sender)
+		if err != nil {
+			http.Error(w, "invalid sender: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !slices.Contains(ownUsers(), jid.User) {
+			sender = jid
+		}
+	}
+	if chat.IsEmpty() && sender.IsEmpty() {
+		if original, ok := ownMessage(r.Context(), chat, id); ok {
+			chat, _ = types.ParseJID(original.Chat)
+		}
+	}
+	if chat.IsEmpty() {
+		http.Error(w, "chat is required for messages not in the message store", http.StatusBadRequest)
+		return
+	}
+
+	if !sender.IsEmpty() {
+		if chat.Server != types.GroupServer {
+			http.Error(w, "Only our own messages can be revoked outside groups", http.StatusForbidden)
+			return
+		}
+		info, err := cachedGroupInfoFor(r.Context(), chat)
+		if err != nil {
+			waLogger.Errorf("Failed to get info of group %s: %v", chat, err)
+			http.Error(w, "Failed to get group info", http.StatusBadGateway)
+			return
+		}
+		if !isGroupAdmin(info, ownUsers()...) {
+			http.Error(w, "Revoking others' messages needs the account to be a group admin", http.StatusForbidden)
+			return
+		}
+	}
+
+	msg := client.BuildRevoke(chat, sender, id)
+	res, err := sendOrQueue(r.Context(), chat, msg, sendOptions{AllowDuplicate: true})
+	if err != nil {
+		writeSendError(w, chat, err)
+		return
+	}
+	if messageStoreEnabled {
+		_, err := gatewayDB.ExecContext(r.Context(),
+			`UPDATE messages SET type = 'revoked', text = '', transcript = '' WHERE chat_jid = ? AND id = ?`,
+			chat.ToNonAD().String(), id)
+		if err != nil {
+			waLogger.Errorf("Failed to drop revoked message %s from the store: %v", id, err)
+		}
+	}
+	writeSendResult(w, chat, res)
+}
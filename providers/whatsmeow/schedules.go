@@ -0,0 +1,534 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/teambition/rrule-go"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"google.golang.org/protobuf/proto"
+)
+
+// Scheduled messages are sent once at send_at, or repeatedly by a cron
+// expression or an RFC 5545 RRULE evaluated in the schedule's timezone.
+//
+// Each occurrence is claimed before it is sent, so a crash or restart never
+// sends it twice. Occurrences that are overdue by more than
+// SCHEDULE_MISFIRE_GRACE (after downtime or a disconnect) are skipped rather
+// than sent in a burst; the schedule continues from its next future run.
+//
+// Announcement schedules send to a list of groups and/or every group carrying
+// a tag (resolved at each run) instead of a single recipient.
+//
+// With send_at_local, a schedule runs in its recipient's timezone, told from
+// the country (and for some countries the area) code of their number.
+
+var scheduleMisfireGrace = envDuration("SCHEDULE_MISFIRE_GRACE", 10*time.Minute)
+
+type schedule struct {
+	ID          int64                `json:"id"`
+	To          string               `json:"to,omitempty"`
+	Groups      []string             `json:"groups,omitempty"`
+	Tag         string               `json:"tag,omitempty"`
+	Text        string               `json:"text"`
+	Cron        string               `json:"cron,omitempty"`
+	RRule       string               `json:"rrule,omitempty"`
+	Timezone    string               `json:"timezone"`
+	Start       time.Time            `json:"start"`
+	NextRunAt   *time.Time           `json:"next_run_at,omitempty"` // unset once a schedule has finished
+	LastRunAt   *time.Time           `json:"last_run_at,omitempty"`
+	RunCount    int                  `json:"run_count"`
+	MissedCount int                  `json:"missed_count"`
+	LastError   string               `json:"last_error,omitempty"`
+	LastResults []announcementResult `json:"last_results,omitempty"` // announcements only
+	Paused      bool                 `json:"paused"`
+	CreatedAt   time.Time            `json:"created_at"`
+}
+
+const scheduleColumns = `id, recipient, text, cron, rrule, timezone, dtstart, next_run_at, last_run_at,
+	run_count, missed_count, last_error, paused, created_at, group_jids, tag, last_results`
+
+func scanSchedule(scan func(dest ...interface{}) error) (schedule, error) {
+	var s schedule
+	var start, next, last, created int64
+	var groups, results string
+	err := scan(&s.ID, &s.To, &s.Text, &s.Cron, &s.RRule, &s.Timezone, &start, &next, &last,
+		&s.RunCount, &s.MissedCount, &s.LastError, &s.Paused, &created, &groups, &s.Tag, &results)
+	if err != nil {
+		return s, err
+	}
+	json.Unmarshal([]byte(groups), &s.Groups)
+	json.Unmarshal([]byte(results), &s.LastResults)
+	s.Start = time.Unix(start, 0).UTC()
+	s.NextRunAt = unixPtr(next)
+	s.LastRunAt = unixPtr(last)
+	s.CreatedAt = time.Unix(created, 0).UTC()
+	return s, nil
+}
+
+// nextRun returns the first occurrence strictly after t, or the zero time
+// when the schedule has no more runs.
+func (s *schedule) nextRun(t time.Time) (time.Time, error) {
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timezone: %w", err)
+	}
+	switch {
+	case s.Cron != "":
+		sched, err := cron.ParseStandard(s.Cron)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid cron expression: %w", err)
+		}
+		if t.Before(s.Start) {
+			t = s.Start.Add(-time.Second)
+		}
+		return sched.Next(t.In(loc)), nil
+	case s.RRule != "":
+		opt, err := rrule.StrToROptionInLocation(s.RRule, loc)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid rrule: %w", err)
+		}
+		if opt.Dtstart.IsZero() {
+			opt.Dtstart = s.Start.In(loc)
+		}
+		rule, err := rrule.NewRRule(*opt)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid rrule: %w", err)
+		}
+		return rule.After(t, false), nil
+	default:
+		if s.Start.After(t) {
+			return s.Start, nil
+		}
+		return time.Time{}, nil
+	}
+}
+
+var scheduleWake = make(chan struct{}, 1)
+
+func wakeScheduler() {
+	select {
+	case scheduleWake <- struct{}{}:
+	default:
+	}
+}
+
+func runScheduler() {
+	ticker := time.NewTicker(15 * time.Second)
+	defer ticker.Stop()
+	for {
+		runDueSchedules(context.Background())
+		select {
+		case <-ticker.C:
+		case <-scheduleWake:
+		}
+	}
+}
+
+func runDueSchedules(ctx context.Context) {
+	if client == nil || !client.IsConnected() || sessionArchived() {
+		return // overdue runs are judged against the misfire grace once back
+	}
+	rows, err := gatewayDB.QueryContext(ctx,
+		`SELECT `+scheduleColumns+` FROM schedules WHERE paused = 0 AND next_run_at > 0 AND next_run_at <= ? ORDER BY next_run_at`,
+		time.Now().Unix())
+	if err != nil {
+		waLogger.Errorf("Failed to load due schedules: %v", err)
+		return
+	}
+	var due []schedule
+	for rows.Next() {
+		s, err := scanSchedule(rows.Scan)
+		if err != nil {
+			waLogger.Errorf("Failed to scan schedule: %v", err)
+			continue
+		}
+		due = append(due, s)
+	}
+	rows.Close()
+	for _, s := range due {
+		runSchedule(ctx, s)
+	}
+}
+
+// runSchedule claims the due occurrence by advancing next_run_at, then sends
+// it unless it is past the misfire grace.
+func runSchedule(ctx context.Context, s schedule) {
+	occurrence := *s.NextRunAt
+	now := time.Now()
+	var nextUnix int64
+	next, err := s.nextRun(now)
+	if err != nil {
+		waLogger.Errorf("Schedule %d has an invalid rule, pausing it: %v", s.ID, err)
+		gatewayDB.ExecContext(ctx, `UPDATE schedules SET paused = 1, last_error = ? WHERE id = ?`, err.Error(), s.ID)
+		return
+	}
+	if !next.IsZero() {
+		nextUnix = next.Unix()
+	}
+	res, err := gatewayDB.ExecContext(ctx,
+		`UPDATE schedules SET next_run_at = ? WHERE id = ? AND next_run_at = ? AND paused = 0`,
+		nextUnix, s.ID, occurrence.Unix())
+	if err != nil {
+		waLogger.Errorf("Failed to advance schedule %d: %v", s.ID, err)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return // paused, deleted or already claimed
+	}
+
+	if now.Sub(occurrence) > scheduleMisfireGrace {
+		waLogger.Warnf("Skipping run of schedule %d due at %s", s.ID, occurrence)
+		gatewayDB.ExecContext(ctx, `UPDATE schedules SET missed_count = missed_count + 1 WHERE id = ?`, s.ID)
+		emitWebhook("schedule.missed", map[string]interface{}{"id": s.ID, "to": s.To, "due_at": occurrence})
+		return
+	}
+	if s.To == "" {
+		runAnnouncement(ctx, s, occurrence, now)
+		return
+	}
+	to, ok := parseJID(s.To)
+	if !ok {
+		return
+	}
+	msg := &waE2E.Message{Conversation: proto.String(s.Text)}
+	// Repeating the same text is the point of a recurring schedule.
+	sent, err := sendOrQueue(ctx, to, msg, sendOptions{AllowDuplicate: true})
+	lastError := ""
+	if err != nil {
+		lastError = err.Error()
+		waLogger.Errorf("Failed to send scheduled message %d to %s: %v", s.ID, to, err)
+		emitWebhook("schedule.failed", map[string]interface{}{"id": s.ID, "to": s.To, "due_at": occurrence, "error": lastError})
+	}
+	_, err = gatewayDB.ExecContext(ctx,
+		`UPDATE schedules SET last_run_at = ?, run_count = run_count + ?, last_error = ? WHERE id = ?`,
+		now.Unix(), boolInt(lastError == ""), lastError, s.ID)
+	if err != nil {
+		waLogger.Errorf("Failed to record run of schedule %d: %v", s.ID, err)
+	}
+	if lastError == "" {
+		emitWebhook("schedule.sent", map[string]interface{}{"id": s.ID, "to": s.To, "due_at": occurrence, "message_id": sent.ID})
+	}
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+type scheduleRequest struct {
+	To         string     `json:"to"`
+	Recipients []string   `json:"recipients,omitempty"` // instead of to, one schedule each
+	Groups     []string   `json:"groups,omitempty"`     // instead of to, for announcements
+	Tag        string     `json:"tag,omitempty"`        // announce to every group with this tag
+	Text       string     `json:"text"`
+	SendAt     *time.Time `json:"send_at,omitempty"` // one-off, or the start of a recurrence
+	// SendAtLocal is a wall-clock time such as 2026-10-20T09:00, taken in
+	// each recipient's own timezone as told by their number.
+	SendAtLocal string `json:"send_at_local,omitempty"`
+	Cron        string `json:"cron,omitempty"`
+	RRule       string `json:"rrule,omitempty"`
+	Timezone    string `json:"timezone,omitempty"` // defaults to the session timezone
+}
+
+// localTimeLayouts are the accepted forms of send_at_local.
+var localTimeLayouts = []string{"2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02 15:04:05", "2006-01-02 15:04"}
+
+func parseLocalTime(s string) (time.Time, error) {
+	for _, layout := range localTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("send_at_local must be a local time like 2006-01-02T15:04, without an offset")
+}
+
+// createSchedule creates one schedule, or with recipients one per recipient,
+// so that a campaign with send_at_local goes out at the same local time in
+// every country it reaches.
+func createSchedule(w http.ResponseWriter, r *http.Request) {
+	var req scheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Text) == "" {
+		http.Error(w, "text is required", http.StatusBadRequest)
+		return
+	}
+	if req.To != "" && len(req.Recipients) > 0 {
+		http.Error(w, "Set either to or recipients, not both", http.StatusBadRequest)
+		return
+	}
+	announcement := len(req.Groups) > 0 || req.Tag != ""
+	if (req.To == "" && len(req.Recipients) == 0) == !announcement {
+		http.Error(w, "Set either to or recipients, or groups and/or tag", http.StatusBadRequest)
+		return
+	}
+	if req.Cron != "" && req.RRule != "" {
+		http.Error(w, "Set either cron or rrule, not both", http.StatusBadRequest)
+		return
+	}
+	if req.Cron == "" && req.RRule == "" && req.SendAt == nil && req.SendAtLocal == "" {
+		http.Error(w, "One of send_at, send_at_local, cron or rrule is required", http.StatusBadRequest)
+		return
+	}
+	var localStart time.Time
+	if req.SendAtLocal != "" {
+		switch {
+		case req.SendAt != nil:
+			http.Error(w, "Set either send_at or send_at_local, not both", http.StatusBadRequest)
+			return
+		case req.Timezone != "":
+			http.Error(w, "send_at_local uses each recipient's timezone, so timezone can't be set", http.StatusBadRequest)
+			return
+		case announcement:
+			http.Error(w, "send_at_local needs phone recipients, groups have no timezone", http.StatusBadRequest)
+			return
+		}
+		var err error
+		if localStart, err = parseLocalTime(req.SendAtLocal); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if req.Timezone == "" {
+		req.Timezone = sessionLocation().String()
+	}
+	now := time.Now()
+	base := schedule{
+		Tag:       req.Tag,
+		Text:      req.Text,
+		Cron:      req.Cron,
+		RRule:     req.RRule,
+		Timezone:  req.Timezone,
+		Start:     now.Truncate(time.Second),
+		CreatedAt: now.UTC(),
+	}
+	if req.SendAt != nil {
+		base.Start = req.SendAt.Truncate(time.Second)
+	}
+
+	var schedules []schedule
+	if announcement {
+		groups, err := parseAnnouncementTargets(r.Context(), req.Groups, req.Tag)
+		if err != nil {
+			if errors.Is(err, errDestinationNotAllowed) {
+				http.Error(w, err.Error(), http.StatusForbidden)
+			} else {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			}
+			return
+		}
+		base.Groups = groups
+		base.Tag = normalizeTag(req.Tag)
+		schedules = append(schedules, base)
+	} else {
+		recipients := req.Recipients
+		if req.To != "" {
+			recipients = []string{req.To}
+		}
+		for _, recipient := range recipients {
+			to, err := parseRecipient(recipient)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			to = toPhoneJID(r.Context(), to)
+			// Schedules run without the caller's key, so its policy is checked now.
+			if err := checkSendPolicy(r.Context(), to); err != nil {
+				writeSendError(w, to, err)
+				return
+			}
+			s := base
+			s.To = to.String()
+			if req.SendAtLocal != "" {
+				// Recipients whose country can't be told (groups, or
+				// LIDs without a known number) fall back to the session
+				// timezone.
+				if to.Server == types.DefaultUserServer {
+					if tz := phoneTimezone(to.User); tz != "" {
+						s.Timezone = tz
+					}
+				}
+				loc, _ := time.LoadLocation(s.Timezone)
+				s.Start = time.Date(localStart.Year(), localStart.Month(), localStart.Day(),
+					localStart.Hour(), localStart.Minute(), localStart.Second(), 0, loc)
+			}
+			schedules = append(schedules, s)
+		}
+	}
+
+	nexts := make([]time.Time, len(schedules))
+	for i := range schedules {
+		next, err := schedules[i].nextRun(now)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if next.IsZero() {
+			if len(schedules) > 1 {
+				http.Error(w, fmt.Sprintf("Schedule for %s has no future runs", schedules[i].To), http.StatusBadRequest)
+			} else {
+				http.Error(w, "Schedule has no future runs", http.StatusBadRequest)
+			}
+			return
+		}
+		nexts[i] = next
+	}
+	if err := insertSchedules(r.Context(), schedules, nexts); err != nil {
+		waLogger.Errorf("Failed to create schedule: %v", err)
+		http.Error(w, "Failed to create schedule", http.StatusInternalServerError)
+		return
+	}
+	wakeScheduler()
+	if len(req.Recipients) > 0 {
+		writeJSON(w, http.StatusCreated, map[string]interface{}{"schedules": schedules})
+		return
+	}
+	writeJSON(w, http.StatusCreated, schedules[0])
+}
+
+// insertSchedules stores new schedules all or none, filling in their ids.
+func insertSchedules(ctx context.Context, schedules []schedule, nexts []time.Time) error {
+	tx, err := gatewayDB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for i := range schedules {
+		s := &schedules[i]
+		groupsJSON, _ := json.Marshal(append([]string{}, s.Groups...))
+		res, err := tx.ExecContext(ctx, `
+			INSERT INTO schedules (recipient, group_jids, tag, text, cron, rrule, timezone, dtstart, next_run_at, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			s.To, string(groupsJSON), s.Tag, s.Text, s.Cron, s.RRule, s.Timezone, s.Start.Unix(), nexts[i].Unix(), s.CreatedAt.Unix())
+		if err != nil {
+			return err
+		}
+		s.ID, _ = res.LastInsertId()
+		s.Start = s.Start.UTC()
+		s.NextRunAt = unixPtr(nexts[i].Unix())
+	}
+	return tx.Commit()
+}
+
+func listSchedules(w http.ResponseWriter, r *http.Request) {
+	rows, err := gatewayDB.QueryContext(r.Context(), `SELECT `+scheduleColumns+` FROM schedules ORDER BY id`)
+	if err != nil {
+		waLogger.Errorf("Failed to list schedules: %v", err)
+		http.Error(w, "Failed to list schedules", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	schedules := []schedule{}
+	for rows.Next() {
+		s, err := scanSchedule(rows.Scan)
+		if err != nil {
+			waLogger.Errorf("Failed to scan schedule: %v", err)
+			http.Error(w, "Failed to list schedules", http.StatusInternalServerError)
+			return
+		}
+		schedules = append(schedules, s)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"schedules": schedules})
+}
+
+func loadSchedule(ctx context.Context, id string) (schedule, error) {
+	n, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return schedule{}, sql.ErrNoRows
+	}
+	return scanSchedule(gatewayDB.QueryRowContext(ctx, `SELECT `+scheduleColumns+` FROM schedules WHERE id = ?`, n).Scan)
+}
+
+func getSchedule(w http.ResponseWriter, r *http.Request) {
+	s, err := loadSchedule(r.Context(), r.PathValue("id"))
+	if err == sql.ErrNoRows {
+		http.Error(w, "Schedule not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		waLogger.Errorf("Failed to load schedule: %v", err)
+		http.Error(w, "Failed to load schedule", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, s)
+}
+
+func pauseSchedule(w http.ResponseWriter, r *http.Request) {
+	setSchedulePaused(w, r, true)
+}
+
+// resumeSchedule continues from the next future run; runs that fell in the
+// pause are not made up.
+func resumeSchedule(w http.ResponseWriter, r *http.Request) {
+	setSchedulePaused(w, r, false)
+}
+
+func setSchedulePaused(w http.ResponseWriter, r *http.Request, paused bool) {
+	s, err := loadSchedule(r.Context(), r.PathValue("id"))
+	if err == sql.ErrNoRows {
+		http.Error(w, "Schedule not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		waLogger.Errorf("Failed to load schedule: %v", err)
+		http.Error(w, "Failed to update schedule", http.StatusInternalServerError)
+		return
+	}
+	var nextUnix int64
+	if s.NextRunAt != nil {
+		nextUnix = s.NextRunAt.Unix()
+	}
+	if !paused && s.Paused {
+		next, err := s.nextRun(time.Now())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		nextUnix = 0
+		if !next.IsZero() {
+			nextUnix = next.Unix()
+		}
+	}
+	_, err = gatewayDB.ExecContext(r.Context(),
+		`UPDATE schedules SET paused = ?, next_run_at = ?, last_error = CASE WHEN ? THEN last_error ELSE '' END WHERE id = ?`,
+		paused, nextUnix, paused, s.ID)
+	if err != nil {
+		waLogger.Errorf("Failed to update schedule %d: %v", s.ID, err)
+		http.Error(w, "Failed to update schedule", http.StatusInternalServerError)
+		return
+	}
+	s.Paused = paused
+	s.NextRunAt = unixPtr(nextUnix)
+	writeJSON(w, http.StatusOK, s)
+}
+
+func deleteSchedule(w http.ResponseWriter, r *http.Request) {
+	n, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Schedule not found", http.StatusNotFound)
+		return
+	}
+	res, err := gatewayDB.ExecContext(r.Context(), `DELETE FROM schedules WHERE id = ?`, n)
+	if err != nil {
+		waLogger.Errorf("Failed to delete schedule %d: %v", n, err)
+		http.Error(w, "Failed to delete schedule", http.StatusInternalServerError)
+		return
+	}
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		http.Error(w, "Schedule not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleNextRun(t *testing.T) {
+	at := func(s string) time.Time {
+		v, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	tests := []struct {
+		name     string
+		schedule schedule
+		after    string
+		want     string // "" for no more runs
+		wantErr  bool
+	}{
+		{
+			name:     "cron in the schedule's timezone",
+			schedule: schedule{Cron: "0 9 * * *", Timezone: "America/New_York", Start: at("2026-01-01T00:00:00Z")},
+			after:    "2026-02-10T15:00:00Z",
+			want:     "2026-02-11T14:00:00Z",
+		},
+		{
+			name:     "cron across the start of daylight saving time",
+			schedule: schedule{Cron: "0 9 * * *", Timezone: "America/New_York", Start: at("2026-01-01T00:00:00Z")},
+			after:    "2026-03-07T15:00:00Z",
+			want:     "2026-03-08T13:00:00Z",
+		},
+		{
+			name:     "cron waits for the start",
+			schedule: schedule{Cron: "0 9 * * *", Timezone: "America/New_York", Start: at("2026-01-05T14:00:00Z")},
+			after:    "2025-12-01T00:00:00Z",
+			want:     "2026-01-05T14:00:00Z",
+		},
+		{
+			name:     "rrule weekly on two days",
+			schedule: schedule{RRule: "FREQ=WEEKLY;BYDAY=MO,WE;BYHOUR=8;BYMINUTE=30;BYSECOND=0", Timezone: "Europe/Berlin", Start: at("2026-01-04T23:00:00Z")},
+			after:    "2026-01-05T08:00:00Z",
+			want:     "2026-01-07T07:30:00Z",
+		},
+		{
+			name:     "rrule occurrence is strictly after",
+			schedule: schedule{RRule: "FREQ=DAILY", Timezone: "UTC", Start: at("2026-01-01T10:00:00Z")},
+			after:    "2026-01-03T10:00:00Z",
+			want:     "2026-01-04T10:00:00Z",
+		},
+		{
+			name:     "rrule with its runs used up",
+			schedule: schedule{RRule: "FREQ=DAILY;COUNT=2", Timezone: "UTC", Start: at("2026-01-01T10:00:00Z")},
+			after:    "2026-01-02T10:00:00Z",
+			want:     "",
+		},
+		{
+			name:     "one-off still to come",
+			schedule: schedule{Timezone: "UTC", Start: at("2026-02-01T12:00:00Z")},
+			after:    "2026-01-01T00:00:00Z",
+			want:     "2026-02-01T12:00:00Z",
+		},
+		{
+			name:     "one-off already sent",
+			schedule: schedule{Timezone: "UTC", Start: at("2026-02-01T12:00:00Z")},
+			after:    "2026-02-01T12:00:00Z",
+			want:     "",
+		},
+		{
+			name:     "invalid timezone",
+			schedule: schedule{Cron: "0 9 * * *", Timezone: "Mars/Olympus_Mons"},
+			after:    "2026-01-01T00:00:00Z",
+			wantErr:  true,
+		},
+		{
+			name:     "invalid cron expression",
+			schedule: schedule{Cron: "61 * * * *", Timezone: "UTC"},
+			after:    "2026-01-01T00:00:00Z",
+			wantErr:  true,
+		},
+		{
+			name:     "invalid rrule",
+			schedule: schedule{RRule: "FREQ=SOMETIMES", Timezone: "UTC"},
+			after:    "2026-01-01T00:00:00Z",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.schedule.nextRun(at(tt.after))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("nextRun() = %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("nextRun() error: %v", err)
+			}
+			if tt.want == "" {
+				if !got.IsZero() {
+					t.Errorf("nextRun() = %v, want no more runs", got)
+				}
+				return
+			}
+			if want := at(tt.want); !got.Equal(want) {
+				t.Errorf("nextRun() = %v, want %v", got.UTC(), want)
+			}
+		})
+	}
+}
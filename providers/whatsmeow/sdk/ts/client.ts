@@ -0,0 +1,2044 @@
+// Code generated by gen_sdk.go; DO NOT EDIT.
+//
+// Client for the HTTP API of the whatsmeow provider, generated from the
+// server's handlers alongside openapi.json.
+
+export interface ClientOptions {
+    baseUrl: string;
+    /** Sent as X-API-Key to the endpoints that take one. */
+    apiKey?: string;
+    /** Sent as X-Admin-Key to the operator endpoints. */
+    adminKey?: string;
+    fetch?: typeof fetch;
+}
+
+export type Query = Record<string, string | number | boolean | undefined>;
+
+/** A response with an error status; the message is its body. */
+export class GatewayError extends Error {
+    constructor(readonly status: number, message: string) {
+        super(message);
+        this.name = 'GatewayError';
+    }
+}
+
+export interface VersionInfo {
+    version: string;
+    git_commit?: string;
+    build_time?: string;
+    go_version: string;
+    whatsmeow_version: string;
+    wa_web_version: string;
+    protocol_version: string;
+    instance_id?: string;
+}
+
+export interface OfflineSyncState {
+    /** waiting (connected, no preview yet), syncing, done or disconnected */
+    state: string;
+    caught_up: boolean;
+    total: number;
+    messages: number;
+    notifications: number;
+    receipts: number;
+    app_data_changes: number;
+    processed: number;
+    /** percent, estimated from events received */
+    progress: number;
+    started_at?: string | null;
+    completed_at?: string | null;
+}
+
+export interface WebhookTestRequest {
+    /** defaults to message */
+    event?: string;
+}
+
+export interface WebhookTestResult {
+    url: string;
+    event: string;
+    /** a 2xx answer */
+    delivered: boolean;
+    status_code?: number;
+    latency_ms: number;
+    error?: string;
+    /** start of the body */
+    response?: string;
+    signed: boolean;
+    signature?: string;
+    timestamp?: string;
+    guidance: string;
+    payload: unknown;
+}
+
+export interface SendMessageRequest {
+    to: string;
+    text: string;
+    allow_duplicate?: boolean;
+    /** defaults to TRANSLATE_OUTBOUND */
+    translate?: boolean | null;
+    /** Reply to an earlier message in the chat; quoted_sender can often be left out (see messageSender). */
+    quoted_message_id?: string;
+    quoted_sender?: string;
+    /** preview the first URL in the text */
+    link_preview?: boolean;
+    /** Numbers or JIDs to notify; each needs its @<number> in the text. */
+    mentions?: string[];
+    /** see links.go */
+    track_links?: boolean;
+    campaign?: string;
+    /** off, 24h, 7d or 90d */
+    ephemeral_expiration?: string;
+}
+
+export interface SendResult {
+    status?: string;
+    id?: string;
+    queued?: boolean;
+    queue_id?: number;
+    warnings?: string[];
+    translated_to?: string;
+    media_id?: number;
+}
+
+export interface SendContactRequest {
+    to: string;
+    /** same shape as in message webhooks */
+    contacts: VCardContact[];
+    allow_duplicate?: boolean;
+}
+
+export interface VCardContact {
+    display_name: string;
+    full_name?: string;
+    first_name?: string;
+    last_name?: string;
+    org?: string;
+    phones: VCardPhone[];
+    emails?: string[];
+}
+
+export interface VCardPhone {
+    /** E.164 form, empty when it can't be determined */
+    number: string;
+    /** value exactly as written in the card */
+    raw: string;
+    /** e.g. CELL, WORK, HOME */
+    type?: string;
+    /** WhatsApp user ID attached by the sender's client */
+    wa_id?: string;
+}
+
+export interface SendCannedRequest {
+    to: string;
+    /** overrides the recipient's language */
+    language?: string;
+    allow_duplicate?: boolean;
+}
+
+export interface SendPollRequest {
+    to: string;
+    question: string;
+    options: string[];
+    /** 0: any number */
+    selectable_count?: number;
+    allow_duplicate?: boolean;
+}
+
+export interface SendButtonsRequest {
+    to: string;
+    header?: string;
+    text: string;
+    footer?: string;
+    buttons: MessageButton[];
+    allow_duplicate?: boolean;
+}
+
+export interface MessageButton {
+    /** reply or url */
+    type: string;
+    id?: string;
+    text: string;
+    url?: string;
+}
+
+export interface SendListRequest {
+    to: string;
+    title?: string;
+    text: string;
+    footer?: string;
+    /** opens the list */
+    button: string;
+    sections: ListSection[];
+    allow_duplicate?: boolean;
+}
+
+export interface ListSection {
+    title?: string;
+    rows: ListRow[];
+}
+
+export interface ListRow {
+    id: string;
+    title: string;
+    description?: string;
+}
+
+export interface SendPaymentRequest {
+    to: string;
+    /** the tenant's order or invoice number */
+    reference_id: string;
+    /** physical-goods (default) or digital-goods */
+    type?: string;
+    currency: string;
+    items: PaymentItem[];
+    tax?: number;
+    shipping?: number;
+    discount?: number;
+    header?: string;
+    /** default: a summary of the order */
+    text?: string;
+    footer?: string;
+    payment_configuration?: string;
+    payment_url?: string;
+    allow_duplicate?: boolean;
+}
+
+export interface PaymentItem {
+    retailer_id?: string;
+    name: string;
+    /** per unit */
+    amount: number;
+    quantity: number;
+}
+
+export interface SendReactionRequest {
+    chat: string;
+    message_id: string;
+    sender?: string;
+    from_me?: boolean;
+    /** empty removes the reaction */
+    emoji: string;
+}
+
+export interface Poll {
+    id: string;
+    chat: string;
+    question: string;
+    options: string[];
+    /** 0: any number */
+    selectable_count: number;
+    tally: Record<string, number>;
+    voters: number;
+    created_at: string;
+}
+
+export interface Survey {
+    id: number;
+    name: string;
+    /** reply or poll */
+    mode: string;
+    questions: SurveyQuestion[];
+    /** sent before the first question */
+    intro?: string;
+    /** sent after the last answer */
+    outro?: string;
+    created_at: string;
+}
+
+export interface SurveyQuestion {
+    text: string;
+    /** nps, choice or text */
+    type: string;
+    /** choice only */
+    options?: string[];
+}
+
+export interface ListSurveysResponse {
+    surveys?: Survey[];
+}
+
+export interface SendSurveyRequest {
+    to?: string[];
+    segment: GroupSegment;
+    campaign?: string;
+}
+
+export interface GroupSegment {
+    /** private chats with this tag */
+    tag?: string;
+    /** gateway contacts with these attribute values */
+    attributes?: Record<string, unknown>;
+}
+
+export interface SurveySendResult {
+    to: string;
+    /** started, skipped or failed */
+    status: string;
+    run_id?: number;
+    error?: string;
+}
+
+export interface SendSurveyResponse {
+    results?: SurveySendResult[];
+    summary?: Record<string, number>;
+}
+
+export interface SurveyRun {
+    id: number;
+    survey_id: number;
+    chat: string;
+    campaign?: string;
+    /** active, completed, expired or cancelled */
+    status: string;
+    step: number;
+    started_at: string;
+    updated_at: string;
+    finished_at?: string | null;
+    answers?: SurveyAnswer[];
+}
+
+export interface SurveyAnswer {
+    question: number;
+    answer: string;
+    /** NPS */
+    score?: number | null;
+    answered_at: string;
+}
+
+export interface ListSurveyResponsesResponse {
+    responses?: SurveyRun[];
+}
+
+export interface Schedule {
+    id: number;
+    to?: string;
+    groups?: string[];
+    tag?: string;
+    text: string;
+    cron?: string;
+    rrule?: string;
+    timezone: string;
+    start: string;
+    /** unset once a schedule has finished */
+    next_run_at?: string | null;
+    last_run_at?: string | null;
+    run_count: number;
+    missed_count: number;
+    last_error?: string;
+    /** announcements only */
+    last_results?: AnnouncementResult[];
+    paused: boolean;
+    created_at: string;
+}
+
+export interface AnnouncementResult {
+    group: string;
+    message_id?: string;
+    error?: string;
+}
+
+export interface ListSchedulesResponse {
+    schedules?: Schedule[];
+}
+
+export interface ScheduleRequest {
+    to: string;
+    /** instead of to, one schedule each */
+    recipients?: string[];
+    /** instead of to, for announcements */
+    groups?: string[];
+    /** announce to every group with this tag */
+    tag?: string;
+    text: string;
+    /** one-off, or the start of a recurrence */
+    send_at?: string | null;
+    /** SendAtLocal is a wall-clock time such as 2026-10-20T09:00, taken in each recipient's own timezone as told by their number. */
+    send_at_local?: string;
+    cron?: string;
+    rrule?: string;
+    /** defaults to the session timezone */
+    timezone?: string;
+}
+
+export interface CreateScheduleResponse {
+    schedules?: Schedule[];
+}
+
+export interface DeleteScheduleResponse {
+    status?: string;
+}
+
+export interface ListLiveLocationsResponse {
+    tracks?: unknown[];
+}
+
+export interface LiveLocationTrack {
+    contact: string;
+    chat: string;
+    caption?: string;
+    started_at: string;
+    updated_at: string;
+    points: LiveLocationPoint[];
+}
+
+export interface LiveLocationPoint {
+    lat: number;
+    lng: number;
+    accuracy_m?: number;
+    speed_mps?: number;
+    heading_deg?: number;
+    sequence?: number;
+    timestamp: string;
+}
+
+export interface GetLIDMappingResponse {
+    lid?: string;
+    pn?: string;
+}
+
+export interface CreateGroupRequest {
+    name: string;
+    /** same as name */
+    subject?: string;
+    segment: GroupSegment;
+    /** added to the segment */
+    participants?: string[];
+    /** Invite those who can't be added; defaults to true. */
+    invite_instead?: boolean | null;
+}
+
+export interface GroupCreation {
+    group: string;
+    name: string;
+    segment: GroupSegment;
+    /** populating or done */
+    status: string;
+    members: GroupMemberResult[];
+    summary: Record<string, number>;
+    created_at: string;
+    finished_at?: string | null;
+}
+
+export interface GroupMemberResult {
+    phone: string;
+    /** added, invited, already_member, removed, promoted, demoted, not_member, not_on_whatsapp or failed */
+    status: string;
+    /** WhatsApp's code when failed */
+    error?: number;
+    /** Their privacy settings kept them from being added, so they were sent an invite. */
+    invited_instead?: boolean;
+}
+
+export interface GroupInfoResponse {
+    jid: string;
+    subject: string;
+    subject_set_at?: string | null;
+    subject_set_by?: string;
+    description?: string;
+    description_id?: string;
+    owner?: string;
+    created_at?: string | null;
+    /** only admins send messages */
+    announce: boolean;
+    /** only admins edit the group info */
+    locked: boolean;
+    /** joining takes an admin's approval */
+    approval_needed: boolean;
+    /** Seconds until messages disappear; 0 when they don't. */
+    disappearing_timer?: number;
+    is_community?: boolean;
+    /** the community the group belongs to */
+    community?: string;
+    participants: GroupParticipantInfo[];
+    participant_count: number;
+    admin_count: number;
+}
+
+export interface GroupParticipantInfo {
+    jid: string;
+    phone?: string;
+    lid?: string;
+    display_name?: string;
+    admin: boolean;
+    /** the creator, who can't be demoted */
+    super_admin: boolean;
+}
+
+export interface GroupParticipantsRequest {
+    /** add, remove, promote or demote */
+    action: string;
+    participants: string[];
+    /** Invite those who can't be added; defaults to true. */
+    invite_instead?: boolean | null;
+}
+
+export interface UpdateGroupParticipantsResponse {
+    group?: string;
+    action?: string;
+    participants?: GroupMemberResult[];
+    summary?: Record<string, number>;
+}
+
+export interface GroupAuditEntry {
+    id: number;
+    group: string;
+    participant: string;
+    action: string;
+    actor?: string;
+    reason?: string;
+    timestamp: string;
+}
+
+export interface GetGroupAuditResponse {
+    group?: string;
+    events?: GroupAuditEntry[];
+}
+
+export interface ModerationRules {
+    enabled: boolean;
+    banned_words?: string[];
+    block_links: boolean;
+    /** links to these are fine even with block_links */
+    allowed_domains?: string[];
+    /** delete offending messages for everyone */
+    delete: boolean;
+    /** reply in the group mentioning the sender */
+    warn: boolean;
+    warn_message?: string;
+    /** remove after this many offenses, 0 never */
+    remove_after?: number;
+    /** offenses older than this are forgotten, empty keeps them */
+    offense_window?: string;
+}
+
+export interface GetGroupModerationResponse {
+    group?: string;
+    rules?: ModerationRules | null;
+}
+
+export interface PutGroupModerationResponse {
+    group?: string;
+    rules?: ModerationRules;
+}
+
+export interface DeleteGroupModerationResponse {
+    status?: string;
+}
+
+export interface ListModerationOffensesResponse {
+    group?: string;
+    offenses?: unknown[];
+}
+
+export interface PardonParticipantResponse {
+    status?: string;
+}
+
+export interface JoinRequestRules {
+    enabled: boolean;
+    /** international prefixes such as "+49" */
+    approve_prefixes?: string[];
+    reject_prefixes?: string[];
+    /** saved in the address book or imported */
+    approve_known_contacts: boolean;
+    reject_suppressed: boolean;
+    /** approve, reject or none */
+    default_action: string;
+}
+
+export interface GetJoinRequestRulesResponse {
+    group?: string;
+    rules?: JoinRequestRules | null;
+}
+
+export interface PutJoinRequestRulesResponse {
+    group?: string;
+    rules?: JoinRequestRules;
+}
+
+export interface DeleteJoinRequestRulesResponse {
+    status?: string;
+}
+
+export interface ListJoinRequestDecisionsResponse {
+    group?: string;
+    decisions?: unknown[];
+}
+
+export interface Suppression {
+    phone: string;
+    reason?: string;
+    created_at: string;
+}
+
+export interface ListSuppressionsResponse {
+    suppressions?: Suppression[];
+}
+
+export interface AddSuppressionRequest {
+    phone: string;
+    reason: string;
+}
+
+export interface DeleteSuppressionResponse {
+    status?: string;
+}
+
+export interface ListPresenceSubscriptionsResponse {
+    subscriptions?: string[];
+    recording?: unknown;
+}
+
+export interface PresenceSubscribeRequest {
+    jid: string;
+}
+
+export interface SubscribePresenceResponse {
+    status?: string;
+    jid?: string;
+    recording?: unknown;
+}
+
+export interface UnsubscribePresenceResponse {
+    status?: string;
+}
+
+export interface OnlineWindow {
+    start: string;
+    end: string;
+    seconds: number;
+    ongoing?: boolean;
+}
+
+export interface PresenceSlot {
+    weekday: string;
+    hour: number;
+    online_minutes: number;
+}
+
+export interface GetPresenceAnalyticsResponse {
+    contact?: unknown;
+    since?: unknown;
+    until?: unknown;
+    timezone?: string;
+    online_windows?: OnlineWindow[];
+    total_online_seconds?: number;
+    hourly_online_minutes?: number[];
+    best_time_to_message?: PresenceSlot[];
+}
+
+export interface SlaStats {
+    /** conversations that reached this point */
+    count: number;
+    avg_seconds: number;
+    median_seconds: number;
+    p90_seconds: number;
+    breached: number;
+    target_seconds?: number;
+}
+
+export interface GetSLAAnalyticsResponse {
+    since?: string;
+    until?: unknown;
+    conversations?: number;
+    open?: number;
+    first_response?: SlaStats;
+    resolution?: SlaStats;
+}
+
+export interface Conversation {
+    id: number;
+    jid: string;
+    assignee?: string;
+    opened_at: string;
+    first_response_at?: string | null;
+    resolved_at?: string | null;
+    first_response_seconds?: number | null;
+    resolution_seconds?: number | null;
+    first_response_breached: boolean;
+    resolution_breached: boolean;
+}
+
+export interface ListSLAConversationsResponse {
+    conversations?: Conversation[];
+}
+
+export interface TrackedLink {
+    token: string;
+    url: string;
+    short_url: string;
+    recipient: string;
+    message_id?: string;
+    campaign?: string;
+    created_at: string;
+    clicks: number;
+    last_clicked_at?: string | null;
+}
+
+export interface ListTrackedLinksResponse {
+    links?: TrackedLink[];
+}
+
+export interface LinkStats {
+    url?: string;
+    recipients: number;
+    clicks: number;
+    /** recipients who clicked */
+    unique_clicks: number;
+    click_through_rate: number;
+}
+
+export interface GetCampaignLinkAnalyticsResponse {
+    campaign?: unknown;
+    messages?: number;
+    recipients?: number;
+    clicks?: number;
+    unique_clicks?: number;
+    click_through_rate?: number;
+    urls?: LinkStats[];
+}
+
+export interface GetSurveyAnalyticsResponse {
+    survey_id?: number;
+    name?: string;
+    campaign?: unknown;
+    started?: unknown;
+    runs?: {
+        active?: number;
+        completed?: number;
+        expired?: number;
+        cancelled?: number;
+    };
+    completion_rate?: unknown;
+    questions?: unknown;
+}
+
+export interface NewsletterPostStats {
+    newsletter_jid: string;
+    server_id: number;
+    message_id?: string;
+    posted_at?: string | null;
+    views: number;
+    reactions: Record<string, number>;
+    updated_at: string;
+}
+
+export interface ListNewsletterPostStatsResponse {
+    posts?: NewsletterPostStats[];
+}
+
+export interface ChatRecord {
+    jid: string;
+    name?: string;
+    status: string;
+    assignee?: string;
+    last_message_at?: string | null;
+    last_inbound_at?: string | null;
+    last_outbound_at?: string | null;
+    /** detected from inbound messages */
+    language?: string;
+    tags: string[];
+    /** seconds */
+    disappearing_timer?: number;
+}
+
+export interface ListChatsResponse {
+    chats?: ChatRecord[];
+}
+
+export interface ChatAssignmentRequest {
+    /** empty to unassign */
+    agent_id: string;
+}
+
+export interface ChatStatusRequest {
+    status: string;
+}
+
+export interface BotState {
+    jid: string;
+    enabled: boolean;
+    paused_at?: string | null;
+    idle_timeout?: string;
+    resumes_at?: string | null;
+}
+
+export interface BotStateRequest {
+    enabled: boolean;
+    /** e.g. "2h"; defaults to BOT_PAUSE_IDLE_TIMEOUT */
+    idle_timeout?: string;
+}
+
+export interface GetChatDisappearingResponse {
+    jid?: string;
+    timer?: string;
+    seconds?: number;
+}
+
+export interface DisappearingRequest {
+    /** off, 24h, 7d or 90d */
+    timer: string;
+}
+
+export interface PutChatDisappearingResponse {
+    jid?: string;
+    timer?: string;
+    seconds?: unknown;
+}
+
+export interface GetChatTagsResponse {
+    jid?: string;
+    tags?: string[];
+}
+
+export interface ListTagsResponse {
+    tags?: unknown[];
+}
+
+export interface Note {
+    id: number;
+    chat: string;
+    message_id?: string;
+    author?: string;
+    text: string;
+    created_at: string;
+    updated_at: string;
+}
+
+export interface ListChatNotesResponse {
+    jid?: string;
+    notes?: Note[];
+}
+
+export interface NoteRequest {
+    message_id: string;
+    author: string;
+    text: string;
+}
+
+export interface GetChatSummaryResponse {
+    jid?: string;
+    summary?: unknown;
+    messages?: number;
+    from?: unknown;
+    to?: unknown;
+}
+
+export interface ReplayRequest {
+    /** defaults to WEBHOOK_URL */
+    url?: string;
+    /** resume after a partial replay */
+    after_id?: number;
+    /** only these event types */
+    events?: string[];
+}
+
+export interface ReplayChatEventsResponse {
+    jid?: string;
+    total?: number;
+    last_id?: number;
+    error?: unknown;
+    delivered?: unknown;
+    complete?: unknown;
+}
+
+export interface DeleteNoteResponse {
+    status?: string;
+}
+
+export interface ForwardRule {
+    id: number;
+    name?: string;
+    source: string;
+    target: string;
+    /** any keyword, case-insensitive */
+    contains?: string[];
+    /** regular expression on the text */
+    pattern?: string;
+    /** message types; empty matches all forwardable types */
+    types?: string[];
+    /** {name}, {phone} and {chat} are filled in */
+    prefix: string;
+    enabled: boolean;
+    created_at: string;
+}
+
+export interface ListForwardRulesResponse {
+    rules?: ForwardRule[];
+}
+
+export interface DeleteForwardRuleResponse {
+    status?: string;
+}
+
+export interface StoredMessage {
+    id: string;
+    chat: string;
+    sender?: string;
+    from_me: boolean;
+    type: string;
+    text?: string;
+    transcript?: string;
+    timestamp: string;
+    notes?: Note[];
+}
+
+export interface SearchMessagesResponse {
+    messages?: StoredMessage[];
+    enabled?: unknown;
+}
+
+export interface EditMessageRequest {
+    /** optional when the message is in the message store or queue */
+    chat?: string;
+    text: string;
+}
+
+export interface RevokeMessageRequest {
+    /** optional for our own messages in the message store or queue */
+    chat?: string;
+    /** someone else's message in a group we administer */
+    sender?: string;
+}
+
+export interface GatewayContact {
+    jid: string;
+    phone: string;
+    name?: string;
+    attributes: Record<string, unknown>;
+    created_at: string;
+    updated_at: string;
+    /** with MESSAGE_STORE */
+    engagement?: EngagementScore | null;
+}
+
+export interface EngagementScore {
+    /** null without enough history */
+    score?: number | null;
+    sent: number;
+    reply_rate: number;
+    read_rate: number;
+    delivery_rate: number;
+    avg_read_latency_seconds?: number | null;
+    /** suppressed, undelivered */
+    signals?: string[];
+}
+
+export interface ListGatewayContactsResponse {
+    contacts?: GatewayContact[];
+}
+
+export interface ContactImportError {
+    /** 1-based, not counting the CSV header */
+    row: number;
+    phone?: string;
+    error: string;
+}
+
+export interface ImportContactsResponse {
+    created?: unknown;
+    updated?: unknown;
+    skipped?: ContactImportError[];
+}
+
+export interface ContactUpdateRequest {
+    name: string;
+    attributes: Record<string, unknown>;
+}
+
+export interface TimelineEntry {
+    /** one of timelineTypes */
+    type: string;
+    timestamp: string;
+    data: unknown;
+}
+
+export interface GetContactTimelineResponse {
+    jid?: string;
+    entries?: TimelineEntry[];
+    has_more?: unknown;
+}
+
+export interface UserDevicesResponse {
+    jid: string;
+    lid?: string;
+    count: number;
+    devices: UserDevice[];
+}
+
+export interface UserDevice {
+    jid: string;
+    device: number;
+    /** the phone the account is registered on */
+    primary: boolean;
+}
+
+export interface DeleteGatewayContactResponse {
+    status?: string;
+}
+
+export interface DeleteContactAttributeResponse {
+    status?: string;
+}
+
+export interface RenderTemplateRequest {
+    to: string;
+    text: string;
+}
+
+export interface PreviewTemplateResponse {
+    text?: string;
+}
+
+export interface CannedResponse {
+    key: string;
+    /** language tag -> text */
+    variants: Record<string, string>;
+    triggers?: string[];
+    created_at: string;
+    updated_at: string;
+}
+
+export interface ListCannedResponsesResponse {
+    responses?: CannedResponse[];
+}
+
+export interface DeleteCannedResponseResponse {
+    status?: string;
+}
+
+export interface AutoReadPolicy {
+    mode: string;
+    chats?: string[];
+    /** also read group messages */
+    groups: boolean;
+    delay_seconds?: number;
+}
+
+export interface MediaDownloadPolicy {
+    /** media type: auto, on_demand or skip */
+    types: Record<string, string>;
+    auto_max_bytes?: number;
+}
+
+export interface InboxAgent {
+    id: string;
+    name: string;
+    email?: string;
+    created_at: string;
+}
+
+export interface ListInboxAgentsResponse {
+    agents?: InboxAgent[];
+}
+
+export interface DeleteInboxAgentResponse {
+    status?: string;
+}
+
+export interface QueueRecord {
+    id: number;
+    recipient: string;
+    message_id: string;
+    type: string;
+    text?: string;
+    priority: number;
+    status: string;
+    attempts: number;
+    last_error?: string;
+    created_at: string;
+    sent_at?: string | null;
+}
+
+export interface SetQueuePriorityRequest {
+    priority?: number | null;
+}
+
+export interface WarmupProgress {
+    active: boolean;
+    /** daily cap per week */
+    schedule: number[];
+    started_at?: string | null;
+    ends_at?: string | null;
+    /** 1-based */
+    week?: number;
+    daily_cap?: number;
+    /** daily_cap was set by hand */
+    override?: boolean;
+    sent_today: number;
+    remaining_today?: number | null;
+    /** when today's cap is used up */
+    resumes_at?: string | null;
+    queued: number;
+}
+
+export interface PutWarmupOverrideRequest {
+    daily_cap: number;
+}
+
+export interface AlertStatus {
+    rule: string;
+    firing: boolean;
+    since?: string | null;
+    detail?: string;
+}
+
+export interface GetAlertsResponse {
+    channel_configured?: boolean;
+    alerts?: AlertStatus[];
+}
+
+export interface MaintenanceRequest {
+    enabled?: boolean | null;
+}
+
+export interface FeatureFlagState {
+    name: string;
+    description: string;
+    enabled: boolean;
+    /** default, env, rollout or override */
+    source: string;
+    /** what it is without the override */
+    default: boolean;
+    /** percentage, for a staged rollout */
+    rollout?: number | null;
+    updated_at?: string | null;
+}
+
+export interface ListFeatureFlagsResponse {
+    flags?: FeatureFlagState[];
+}
+
+export interface FeatureFlagRequest {
+    enabled?: boolean | null;
+}
+
+export interface GetMigrationsResponse {
+    current_version?: unknown;
+    target_version?: unknown;
+    migrations?: unknown;
+}
+
+export interface ApiKey {
+    id: string;
+    name: string;
+    policy: SendPolicy;
+    created_at: string;
+    last_used_at?: string | null;
+}
+
+export interface SendPolicy {
+    allow_individuals: boolean;
+    allow_groups: boolean;
+    /** empty allows any group */
+    group_allowlist?: string[];
+    /** only chats that have messaged us */
+    no_new_contacts: boolean;
+    /** exempt from the content policy */
+    content_filter_override: boolean;
+    /** never shed, queued ahead of other sends */
+    priority: boolean;
+}
+
+export interface ListAPIKeysResponse {
+    api_keys?: ApiKey[];
+}
+
+export interface ApiKeyRequest {
+    name: string;
+    policy?: SendPolicy | null;
+}
+
+export interface CreateAPIKeyResponse {
+    id: string;
+    name: string;
+    policy: SendPolicy;
+    created_at: string;
+    last_used_at?: string | null;
+    key: string;
+}
+
+export interface DeleteAPIKeyResponse {
+    status?: string;
+}
+
+export interface ContentPolicy {
+    /** reject or flag */
+    action: string;
+    banned_words?: string[];
+    /** empty allows any domain */
+    allowed_domains?: string[];
+    /** 0 means unlimited */
+    max_links?: number;
+}
+
+export interface GetMessageStoreStatusResponse {
+    enabled?: unknown;
+    encrypted?: unknown;
+    kms?: string;
+    data_key_created_at?: string;
+    plaintext_messages?: number;
+}
+
+export interface MediaJob {
+    id: number;
+    direction: string;
+    type: string;
+    chat: string;
+    message_id?: string;
+    status: string;
+    attempts: number;
+    last_error?: string;
+    created_at: string;
+    updated_at: string;
+}
+
+export interface ListMediaJobsResponse {
+    jobs?: MediaJob[];
+}
+
+export interface QuarantinedFile {
+    id: number;
+    chat: string;
+    message_id: string;
+    sender: string;
+    file_name?: string;
+    mimetype?: string;
+    size: number;
+    sha256: string;
+    signature: string;
+    created_at: string;
+}
+
+export interface ListQuarantineResponse {
+    files?: QuarantinedFile[];
+}
+
+export interface ExportDataSubjectResponse {
+    phone?: unknown;
+    jids?: string[];
+    exported_at?: unknown;
+    suppressions?: (Record<string, unknown>)[];
+    group_creations?: Record<string, GroupMemberResult[]>;
+}
+
+export interface ErasureCertificate {
+    id: string;
+    /** HMAC-SHA-256 of the E.164 digits */
+    subject_hash: string;
+    erased_at: string;
+    /** rows per table */
+    deleted: Record<string, number>;
+    retained: string[];
+    /** SHA-256 of the certificate without the digest */
+    digest: string;
+}
+
+export interface AuditEntry {
+    id: number;
+    action: string;
+    subject?: string;
+    actor?: string;
+    details: unknown;
+    created_at: string;
+}
+
+export interface ListAuditLogResponse {
+    entries?: AuditEntry[];
+}
+
+export interface AppStateResyncRequest {
+    /** empty means all */
+    collections: string[];
+    /** default true */
+    full_sync?: boolean | null;
+}
+
+export interface DeviceIdentity {
+    name: string;
+    platform: string;
+    paired: boolean;
+}
+
+export interface ClientVersionInfo {
+    version: string;
+    /** whatsmeow's */
+    builtin: string;
+    /** default, env or saved */
+    source: string;
+    auto_update: boolean;
+    /** last rejection by WhatsApp */
+    outdated_at?: string | null;
+    platform: string;
+    os_version?: string;
+    manufacturer?: string;
+    device?: string;
+}
+
+export interface ClientVersionRequest {
+    /** e.g. 2.3000.1029000000, "latest", or empty for the default */
+    version: string;
+}
+
+export interface ListBackupsResponse {
+    backups?: unknown;
+    interval_seconds?: unknown;
+    retention_seconds?: unknown;
+}
+
+export interface BackupInfo {
+    name: string;
+    size: number;
+    created_at: string;
+}
+
+export interface RestoreBackupResponse {
+    backup?: unknown;
+    status?: string;
+}
+
+export interface ConsoleState {
+    connected: boolean;
+    logged_in: boolean;
+    phone_id?: string;
+    push_name?: string;
+    qr_available: boolean;
+    offline_sync: OfflineSyncState;
+    version: string;
+    uptime: string;
+}
+
+export interface ConsoleEvent {
+    id: number;
+    event: string;
+    payload: unknown;
+    at: string;
+}
+
+export interface ListConsoleEventsResponse {
+    events?: ConsoleEvent[];
+}
+
+export class GatewayClient {
+    private readonly baseUrl: string;
+
+    constructor(private readonly options: ClientOptions) {
+        this.baseUrl = options.baseUrl.replace(/\/$/, '');
+    }
+
+    private async request<T>(method: string, path: string, query?: Query, body?: unknown): Promise<T> {
+        let url = this.baseUrl + path;
+        if (query) {
+            const params = new URLSearchParams();
+            for (const [key, value] of Object.entries(query)) {
+                if (value !== undefined) {
+                    params.set(key, String(value));
+                }
+            }
+            if (params.size > 0) {
+                url += '?' + params.toString();
+            }
+        }
+        const headers: Record<string, string> = {};
+        if (body !== undefined) {
+            headers['Content-Type'] = 'application/json';
+        }
+        if (this.options.apiKey) {
+            headers['X-API-Key'] = this.options.apiKey;
+        }
+        if (this.options.adminKey) {
+            headers['X-Admin-Key'] = this.options.adminKey;
+        }
+        const doFetch = this.options.fetch ?? fetch;
+        const res = await doFetch(url, {
+            method,
+            headers,
+            body: body === undefined ? undefined : JSON.stringify(body),
+        });
+        if (!res.ok) {
+            throw new GatewayError(res.status, (await res.text()).trim());
+        }
+        if (res.status === 204) {
+            return undefined as T;
+        }
+        if (res.headers.get('Content-Type')?.includes('application/json')) {
+            return (await res.json()) as T;
+        }
+        return (await res.arrayBuffer()) as T;
+    }
+
+    health(): Promise<unknown> {
+        return this.request('GET', '/health', undefined, undefined);
+    }
+
+    version(): Promise<VersionInfo> {
+        return this.request('GET', '/version', undefined, undefined);
+    }
+
+    getOpenAPISpec(): Promise<unknown> {
+        return this.request('GET', '/openapi.json', undefined, undefined);
+    }
+
+    getOfflineSync(): Promise<OfflineSyncState> {
+        return this.request('GET', '/sync/offline', undefined, undefined);
+    }
+
+    getQR(): Promise<unknown> {
+        return this.request('GET', '/qr', undefined, undefined);
+    }
+
+    /** Sends a sample event to WEBHOOK_URL. */
+    testWebhook(body: WebhookTestRequest): Promise<WebhookTestResult> {
+        return this.request('POST', '/webhooks/test', undefined, body);
+    }
+
+    sendText(body: SendMessageRequest): Promise<SendResult> {
+        return this.request('POST', '/send', undefined, body);
+    }
+
+    sendVideo(body?: unknown): Promise<SendResult> {
+        return this.request('POST', '/send/video', undefined, body);
+    }
+
+    sendDocument(body?: unknown): Promise<SendResult> {
+        return this.request('POST', '/send/document', undefined, body);
+    }
+
+    sendAudio(body?: unknown): Promise<SendResult> {
+        return this.request('POST', '/send/audio', undefined, body);
+    }
+
+    sendSticker(body?: unknown): Promise<SendResult> {
+        return this.request('POST', '/send/sticker', undefined, body);
+    }
+
+    sendContact(body: SendContactRequest): Promise<SendResult> {
+        return this.request('POST', '/send/contact', undefined, body);
+    }
+
+    /** Sends a canned response in the recipient's language. */
+    sendCanned(key: string, body: SendCannedRequest): Promise<SendResult> {
+        return this.request('POST', `/send/canned/${encodeURIComponent(key)}`, undefined, body);
+    }
+
+    sendPoll(body: SendPollRequest): Promise<SendResult> {
+        return this.request('POST', '/send/poll', undefined, body);
+    }
+
+    sendButtons(body: SendButtonsRequest): Promise<SendResult> {
+        return this.request('POST', '/send/buttons', undefined, body);
+    }
+
+    sendList(body: SendListRequest): Promise<SendResult> {
+        return this.request('POST', '/send/list', undefined, body);
+    }
+
+    sendPayment(body: SendPaymentRequest): Promise<SendResult> {
+        return this.request('POST', '/send/payment', undefined, body);
+    }
+
+    sendReaction(body: SendReactionRequest): Promise<SendResult> {
+        return this.request('POST', '/send/reaction', undefined, body);
+    }
+
+    getPoll(id: string): Promise<Poll> {
+        return this.request('GET', `/polls/${encodeURIComponent(id)}`, undefined, undefined);
+    }
+
+    listSurveys(): Promise<ListSurveysResponse> {
+        return this.request('GET', '/surveys', undefined, undefined);
+    }
+
+    createSurvey(body: Survey): Promise<Survey> {
+        return this.request('POST', '/surveys', undefined, body);
+    }
+
+    getSurvey(id: string): Promise<Survey> {
+        return this.request('GET', `/surveys/${encodeURIComponent(id)}`, undefined, undefined);
+    }
+
+    /** Removes a survey; runs in progress are cancelled, answers already given are kept. */
+    deleteSurvey(id: string): Promise<void> {
+        return this.request('DELETE', `/surveys/${encodeURIComponent(id)}`, undefined, undefined);
+    }
+
+    /** Starts a survey for the given contacts and/or a segment. */
+    sendSurvey(id: string, body: SendSurveyRequest): Promise<SendSurveyResponse> {
+        return this.request('POST', `/surveys/${encodeURIComponent(id)}/send`, undefined, body);
+    }
+
+    /** Lists a survey's runs with their answers, newest first. */
+    listSurveyResponses(id: string, query: { limit?: string | number | boolean; offset?: string | number | boolean; status?: string | number | boolean } = {}): Promise<ListSurveyResponsesResponse> {
+        return this.request('GET', `/surveys/${encodeURIComponent(id)}/responses`, query, undefined);
+    }
+
+    listSchedules(): Promise<ListSchedulesResponse> {
+        return this.request('GET', '/schedules', undefined, undefined);
+    }
+
+    /** Creates one schedule, or with recipients one per recipient, so that a campaign with send_at_local goes out at the same local time in every country it reaches. */
+    createSchedule(body: ScheduleRequest): Promise<CreateScheduleResponse> {
+        return this.request('POST', '/schedules', undefined, body);
+    }
+
+    getSchedule(id: string): Promise<Schedule> {
+        return this.request('GET', `/schedules/${encodeURIComponent(id)}`, undefined, undefined);
+    }
+
+    pauseSchedule(id: string, body?: unknown): Promise<unknown> {
+        return this.request('POST', `/schedules/${encodeURIComponent(id)}/pause`, undefined, body);
+    }
+
+    /** Continues from the next future run; runs that fell in the pause are not made up. */
+    resumeSchedule(id: string, body?: unknown): Promise<unknown> {
+        return this.request('POST', `/schedules/${encodeURIComponent(id)}/resume`, undefined, body);
+    }
+
+    deleteSchedule(id: string): Promise<DeleteScheduleResponse> {
+        return this.request('DELETE', `/schedules/${encodeURIComponent(id)}`, undefined, undefined);
+    }
+
+    listLiveLocations(): Promise<ListLiveLocationsResponse> {
+        return this.request('GET', '/locations/live', undefined, undefined);
+    }
+
+    getLiveLocation(jid: string): Promise<LiveLocationTrack> {
+        return this.request('GET', `/locations/live/${encodeURIComponent(jid)}`, undefined, undefined);
+    }
+
+    getLIDMapping(jid: string): Promise<GetLIDMappingResponse> {
+        return this.request('GET', `/lid/${encodeURIComponent(jid)}`, undefined, undefined);
+    }
+
+    createGroupFromSegment(body: CreateGroupRequest): Promise<GroupCreation> {
+        return this.request('POST', '/groups', undefined, body);
+    }
+
+    getGroupInfo(jid: string, query: { refresh?: string | number | boolean } = {}): Promise<GroupInfoResponse> {
+        return this.request('GET', `/groups/${encodeURIComponent(jid)}`, query, undefined);
+    }
+
+    getGroupCreation(jid: string): Promise<GroupCreation> {
+        return this.request('GET', `/groups/${encodeURIComponent(jid)}/creation`, undefined, undefined);
+    }
+
+    updateGroupParticipants(jid: string, body: GroupParticipantsRequest): Promise<UpdateGroupParticipantsResponse> {
+        return this.request('POST', `/groups/${encodeURIComponent(jid)}/participants`, undefined, body);
+    }
+
+    getGroupAudit(jid: string, query: { participant?: string | number | boolean; action?: string | number | boolean; limit?: string | number | boolean } = {}): Promise<GetGroupAuditResponse> {
+        return this.request('GET', `/groups/${encodeURIComponent(jid)}/audit`, query, undefined);
+    }
+
+    getGroupModeration(jid: string): Promise<GetGroupModerationResponse> {
+        return this.request('GET', `/groups/${encodeURIComponent(jid)}/moderation`, undefined, undefined);
+    }
+
+    putGroupModeration(jid: string, body: ModerationRules): Promise<PutGroupModerationResponse> {
+        return this.request('PUT', `/groups/${encodeURIComponent(jid)}/moderation`, undefined, body);
+    }
+
+    deleteGroupModeration(jid: string): Promise<DeleteGroupModerationResponse> {
+        return this.request('DELETE', `/groups/${encodeURIComponent(jid)}/moderation`, undefined, undefined);
+    }
+
+    listModerationOffenses(jid: string): Promise<ListModerationOffensesResponse> {
+        return this.request('GET', `/groups/${encodeURIComponent(jid)}/moderation/offenses`, undefined, undefined);
+    }
+
+    /** Clears a participant's offense count. */
+    pardonParticipant(jid: string, participant: string): Promise<PardonParticipantResponse> {
+        return this.request('DELETE', `/groups/${encodeURIComponent(jid)}/moderation/offenses/${encodeURIComponent(participant)}`, undefined, undefined);
+    }
+
+    getJoinRequestRules(jid: string): Promise<GetJoinRequestRulesResponse> {
+        return this.request('GET', `/groups/${encodeURIComponent(jid)}/join-requests/rules`, undefined, undefined);
+    }
+
+    putJoinRequestRules(jid: string, body: JoinRequestRules): Promise<PutJoinRequestRulesResponse> {
+        return this.request('PUT', `/groups/${encodeURIComponent(jid)}/join-requests/rules`, undefined, body);
+    }
+
+    deleteJoinRequestRules(jid: string): Promise<DeleteJoinRequestRulesResponse> {
+        return this.request('DELETE', `/groups/${encodeURIComponent(jid)}/join-requests/rules`, undefined, undefined);
+    }
+
+    listJoinRequestDecisions(jid: string, query: { decision?: string | number | boolean; limit?: string | number | boolean } = {}): Promise<ListJoinRequestDecisionsResponse> {
+        return this.request('GET', `/groups/${encodeURIComponent(jid)}/join-requests/decisions`, query, undefined);
+    }
+
+    listSuppressions(): Promise<ListSuppressionsResponse> {
+        return this.request('GET', '/suppressions', undefined, undefined);
+    }
+
+    addSuppression(body: AddSuppressionRequest): Promise<Suppression> {
+        return this.request('POST', '/suppressions', undefined, body);
+    }
+
+    deleteSuppression(phone: string): Promise<DeleteSuppressionResponse> {
+        return this.request('DELETE', `/suppressions/${encodeURIComponent(phone)}`, undefined, undefined);
+    }
+
+    listPresenceSubscriptions(): Promise<ListPresenceSubscriptionsResponse> {
+        return this.request('GET', '/presence/subscriptions', undefined, undefined);
+    }
+
+    subscribePresence(body: PresenceSubscribeRequest): Promise<SubscribePresenceResponse> {
+        return this.request('POST', '/presence/subscriptions', undefined, body);
+    }
+
+    unsubscribePresence(jid: string): Promise<UnsubscribePresenceResponse> {
+        return this.request('DELETE', `/presence/subscriptions/${encodeURIComponent(jid)}`, undefined, undefined);
+    }
+
+    getPresenceAnalytics(jid: string, query: { days?: string | number | boolean } = {}): Promise<GetPresenceAnalyticsResponse> {
+        return this.request('GET', `/analytics/presence/${encodeURIComponent(jid)}`, query, undefined);
+    }
+
+    /** Aggregates the conversations opened in the last days (7 by default), optionally of one assignee or chat. */
+    getSLAAnalytics(): Promise<GetSLAAnalyticsResponse> {
+        return this.request('GET', '/analytics/sla', undefined, undefined);
+    }
+
+    /** Lists conversations, newest first; ?breached=true keeps those that missed a target. */
+    listSLAConversations(query: { limit?: string | number | boolean; breached?: string | number | boolean } = {}): Promise<ListSLAConversationsResponse> {
+        return this.request('GET', '/analytics/sla/conversations', query, undefined);
+    }
+
+    /** Lists tracked links with their clicks, newest first, filtered by ?campaign=, ?recipient= and ?message_id=. */
+    listTrackedLinks(query: { campaign?: string | number | boolean; recipient?: string | number | boolean; message_id?: string | number | boolean; limit?: string | number | boolean } = {}): Promise<ListTrackedLinksResponse> {
+        return this.request('GET', '/analytics/links', query, undefined);
+    }
+
+    /** Returns a campaign's click-through overall and per URL. */
+    getCampaignLinkAnalytics(campaign: string): Promise<GetCampaignLinkAnalyticsResponse> {
+        return this.request('GET', `/analytics/campaigns/${encodeURIComponent(campaign)}`, undefined, undefined);
+    }
+
+    /** Sums up a survey's answers, optionally for one campaign. */
+    getSurveyAnalytics(id: string, query: { campaign?: string | number | boolean } = {}): Promise<GetSurveyAnalyticsResponse> {
+        return this.request('GET', `/analytics/surveys/${encodeURIComponent(id)}`, query, undefined);
+    }
+
+    /** Records a click on a tracked link and redirects to its URL. */
+    followLink(token: string): Promise<unknown> {
+        return this.request('GET', `/l/${encodeURIComponent(token)}`, undefined, undefined);
+    }
+
+    listNewsletterPostStats(jid: string, query: { limit?: string | number | boolean } = {}): Promise<ListNewsletterPostStatsResponse> {
+        return this.request('GET', `/newsletters/${encodeURIComponent(jid)}/posts`, query, undefined);
+    }
+
+    getNewsletterPostStats(jid: string, id: string, query: { refresh?: string | number | boolean } = {}): Promise<NewsletterPostStats> {
+        return this.request('GET', `/newsletters/${encodeURIComponent(jid)}/posts/${encodeURIComponent(id)}/stats`, query, undefined);
+    }
+
+    listChats(query: { status?: string | number | boolean; assignee?: string | number | boolean; unassigned?: string | number | boolean; tag?: string | number | boolean; limit?: string | number | boolean } = {}): Promise<ListChatsResponse> {
+        return this.request('GET', '/chats', query, undefined);
+    }
+
+    assignChat(jid: string, body: ChatAssignmentRequest): Promise<ChatRecord> {
+        return this.request('PUT', `/chats/${encodeURIComponent(jid)}/assignment`, undefined, body);
+    }
+
+    setChatStatus(jid: string, body: ChatStatusRequest): Promise<ChatRecord> {
+        return this.request('PUT', `/chats/${encodeURIComponent(jid)}/status`, undefined, body);
+    }
+
+    getBotState(jid: string): Promise<BotState> {
+        return this.request('GET', `/chats/${encodeURIComponent(jid)}/bot`, undefined, undefined);
+    }
+
+    putBotState(jid: string, body: BotStateRequest): Promise<BotState> {
+        return this.request('PUT', `/chats/${encodeURIComponent(jid)}/bot`, undefined, body);
+    }
+
+    getChatDisappearing(jid: string): Promise<GetChatDisappearingResponse> {
+        return this.request('GET', `/chats/${encodeURIComponent(jid)}/disappearing`, undefined, undefined);
+    }
+
+    putChatDisappearing(jid: string, body: DisappearingRequest): Promise<PutChatDisappearingResponse> {
+        return this.request('PUT', `/chats/${encodeURIComponent(jid)}/disappearing`, undefined, body);
+    }
+
+    getChatTags(jid: string): Promise<GetChatTagsResponse> {
+        return this.request('GET', `/chats/${encodeURIComponent(jid)}/tags`, undefined, undefined);
+    }
+
+    /** Adds the given tags (POST) or replaces the chat's tags with them (PUT). */
+    updateChatTags(jid: string, body?: unknown): Promise<unknown> {
+        return this.request('POST', `/chats/${encodeURIComponent(jid)}/tags`, undefined, body);
+    }
+
+    /** Adds the given tags (POST) or replaces the chat's tags with them (PUT). */
+    updateChatTagsPut(jid: string, body?: unknown): Promise<unknown> {
+        return this.request('PUT', `/chats/${encodeURIComponent(jid)}/tags`, undefined, body);
+    }
+
+    deleteChatTag(jid: string, tag: string): Promise<unknown> {
+        return this.request('DELETE', `/chats/${encodeURIComponent(jid)}/tags/${encodeURIComponent(tag)}`, undefined, undefined);
+    }
+
+    /** Returns every tag in use with the number of chats carrying it. */
+    listTags(): Promise<ListTagsResponse> {
+        return this.request('GET', '/tags', undefined, undefined);
+    }
+
+    /** Returns the notes on a chat and its messages, oldest first. */
+    listChatNotes(jid: string, query: { message_id?: string | number | boolean } = {}): Promise<ListChatNotesResponse> {
+        return this.request('GET', `/chats/${encodeURIComponent(jid)}/notes`, query, undefined);
+    }
+
+    createNote(jid: string, body: NoteRequest): Promise<Note> {
+        return this.request('POST', `/chats/${encodeURIComponent(jid)}/notes`, undefined, body);
+    }
+
+    /** Summarizes the chat's messages since ?since= (default the last SUMMARY_WINDOW), up to ?limit= of the most recent ones. */
+    getChatSummary(jid: string, query: { since?: string | number | boolean; limit?: string | number | boolean } = {}): Promise<GetChatSummaryResponse> {
+        return this.request('GET', `/chats/${encodeURIComponent(jid)}/summary`, query, undefined);
+    }
+
+    /** Re-delivers a chat's journaled events in order, stopping at the first failed delivery; last_id lets the caller resume from there. */
+    replayChatEvents(jid: string, body: ReplayRequest): Promise<ReplayChatEventsResponse> {
+        return this.request('POST', `/chats/${encodeURIComponent(jid)}/events/replay`, undefined, body);
+    }
+
+    updateNote(id: string, body: NoteRequest): Promise<Note> {
+        return this.request('PUT', `/notes/${encodeURIComponent(id)}`, undefined, body);
+    }
+
+    deleteNote(id: string): Promise<DeleteNoteResponse> {
+        return this.request('DELETE', `/notes/${encodeURIComponent(id)}`, undefined, undefined);
+    }
+
+    listForwardRules(): Promise<ListForwardRulesResponse> {
+        return this.request('GET', '/forward-rules', undefined, undefined);
+    }
+
+    createForwardRule(body: ForwardRule): Promise<ForwardRule> {
+        return this.request('POST', '/forward-rules', undefined, body);
+    }
+
+    updateForwardRule(id: string, body: ForwardRule): Promise<ForwardRule> {
+        return this.request('PUT', `/forward-rules/${encodeURIComponent(id)}`, undefined, body);
+    }
+
+    deleteForwardRule(id: string): Promise<DeleteForwardRuleResponse> {
+        return this.request('DELETE', `/forward-rules/${encodeURIComponent(id)}`, undefined, undefined);
+    }
+
+    searchMessages(query: { q?: string | number | boolean; chat?: string | number | boolean; tag?: string | number | boolean; type?: string | number | boolean; limit?: string | number | boolean } = {}): Promise<SearchMessagesResponse> {
+        return this.request('GET', '/messages/search', query, undefined);
+    }
+
+    /** Serves the file of a media message: the kept file when the policy downloaded it (or the message was sent), else fresh from WhatsApp while it still has it. */
+    getMessageMedia(id: string, query: { chat?: string | number | boolean; allow_unscanned?: string | number | boolean } = {}): Promise<unknown> {
+        return this.request('GET', `/messages/${encodeURIComponent(id)}/media`, query, undefined);
+    }
+
+    /** Serves kept media by its media ID. */
+    getMedia(id: string, query: { allow_unscanned?: string | number | boolean } = {}): Promise<unknown> {
+        return this.request('GET', `/media/${encodeURIComponent(id)}`, query, undefined);
+    }
+
+    editMessage(id: string, body: EditMessageRequest): Promise<SendResult> {
+        return this.request('POST', `/messages/${encodeURIComponent(id)}/edit`, undefined, body);
+    }
+
+    revokeMessage(id: string, body: RevokeMessageRequest): Promise<SendResult> {
+        return this.request('POST', `/messages/${encodeURIComponent(id)}/revoke`, undefined, body);
+    }
+
+    listGatewayContacts(query: { q?: string | number | boolean; limit?: string | number | boolean; offset?: string | number | boolean } = {}): Promise<ListGatewayContactsResponse> {
+        return this.request('GET', '/contacts', query, undefined);
+    }
+
+    /** Takes text/csv or JSON. */
+    importContacts(body?: unknown): Promise<ImportContactsResponse> {
+        return this.request('POST', '/contacts/import', undefined, body);
+    }
+
+    getGatewayContact(jid: string): Promise<GatewayContact> {
+        return this.request('GET', `/contacts/${encodeURIComponent(jid)}`, undefined, undefined);
+    }
+
+    /** Creates the contact or merges the given name and attributes into it; an attribute set to null is removed. */
+    putGatewayContact(jid: string, body: ContactUpdateRequest): Promise<GatewayContact> {
+        return this.request('PUT', `/contacts/${encodeURIComponent(jid)}`, undefined, body);
+    }
+
+    /** Returns the newest entries up to ?until=, oldest first; has_more says whether there are earlier ones, to be fetched with the first entry's timestamp as the next until. */
+    getContactTimeline(jid: string, query: { limit?: string | number | boolean; types?: string | number | boolean } = {}): Promise<GetContactTimelineResponse> {
+        return this.request('GET', `/contacts/${encodeURIComponent(jid)}/timeline`, query, undefined);
+    }
+
+    getContactEngagement(jid: string): Promise<EngagementScore> {
+        return this.request('GET', `/contacts/${encodeURIComponent(jid)}/engagement`, undefined, undefined);
+    }
+
+    /** Lists the devices messages to a user are encrypted for. */
+    getUserDevices(jid: string): Promise<UserDevicesResponse> {
+        return this.request('GET', `/contacts/${encodeURIComponent(jid)}/devices`, undefined, undefined);
+    }
+
+    deleteGatewayContact(jid: string): Promise<DeleteGatewayContactResponse> {
+        return this.request('DELETE', `/contacts/${encodeURIComponent(jid)}`, undefined, undefined);
+    }
+
+    deleteContactAttribute(jid: string, key: string): Promise<DeleteContactAttributeResponse> {
+        return this.request('DELETE', `/contacts/${encodeURIComponent(jid)}/attributes/${encodeURIComponent(key)}`, undefined, undefined);
+    }
+
+    /** Renders a template for a recipient without sending it. */
+    previewTemplate(body: RenderTemplateRequest): Promise<PreviewTemplateResponse> {
+        return this.request('POST', '/templates/render', undefined, body);
+    }
+
+    listCannedResponses(): Promise<ListCannedResponsesResponse> {
+        return this.request('GET', '/canned-responses', undefined, undefined);
+    }
+
+    getCannedResponse(key: string): Promise<CannedResponse> {
+        return this.request('GET', `/canned-responses/${encodeURIComponent(key)}`, undefined, undefined);
+    }
+
+    /** Creates or replaces a canned response with all its variants. */
+    putCannedResponse(key: string, body: CannedResponse): Promise<unknown> {
+        return this.request('PUT', `/canned-responses/${encodeURIComponent(key)}`, undefined, body);
+    }
+
+    deleteCannedResponse(key: string): Promise<DeleteCannedResponseResponse> {
+        return this.request('DELETE', `/canned-responses/${encodeURIComponent(key)}`, undefined, undefined);
+    }
+
+    getLocaleSettings(): Promise<unknown> {
+        return this.request('GET', '/settings/locale', undefined, undefined);
+    }
+
+    putLocaleSettings(body: unknown): Promise<unknown> {
+        return this.request('PUT', '/settings/locale', undefined, body);
+    }
+
+    getAutoReadPolicy(): Promise<AutoReadPolicy> {
+        return this.request('GET', '/settings/auto-read', undefined, undefined);
+    }
+
+    putAutoReadPolicy(body: AutoReadPolicy): Promise<AutoReadPolicy> {
+        return this.request('PUT', '/settings/auto-read', undefined, body);
+    }
+
+    getMediaDownloadPolicy(): Promise<MediaDownloadPolicy> {
+        return this.request('GET', '/settings/media-download', undefined, undefined);
+    }
+
+    putMediaDownloadPolicy(body: MediaDownloadPolicy): Promise<MediaDownloadPolicy> {
+        return this.request('PUT', '/settings/media-download', undefined, body);
+    }
+
+    listInboxAgents(): Promise<ListInboxAgentsResponse> {
+        return this.request('GET', '/inbox/agents', undefined, undefined);
+    }
+
+    createInboxAgent(body: InboxAgent): Promise<InboxAgent> {
+        return this.request('POST', '/inbox/agents', undefined, body);
+    }
+
+    /** Removes an agent and returns their conversations to the unassigned pool. */
+    deleteInboxAgent(id: string): Promise<DeleteInboxAgentResponse> {
+        return this.request('DELETE', `/inbox/agents/${encodeURIComponent(id)}`, undefined, undefined);
+    }
+
+    /** Lists queued messages in dispatch order, pending ones by default. */
+    listQueue(query: { status?: string | number | boolean; limit?: string | number | boolean } = {}): Promise<Record<string, unknown>> {
+        return this.request('GET', '/queue', query, undefined);
+    }
+
+    pauseQueue(body?: unknown): Promise<Record<string, unknown>> {
+        return this.request('POST', '/queue/pause', undefined, body);
+    }
+
+    resumeQueue(body?: unknown): Promise<Record<string, unknown>> {
+        return this.request('POST', '/queue/resume', undefined, body);
+    }
+
+    getQueueItem(id: string): Promise<QueueRecord> {
+        return this.request('GET', `/queue/${encodeURIComponent(id)}`, undefined, undefined);
+    }
+
+    setQueuePriority(id: string, body: SetQueuePriorityRequest): Promise<QueueRecord> {
+        return this.request('PUT', `/queue/${encodeURIComponent(id)}/priority`, undefined, body);
+    }
+
+    cancelQueueItem(id: string): Promise<QueueRecord> {
+        return this.request('DELETE', `/queue/${encodeURIComponent(id)}`, undefined, undefined);
+    }
+
+    getWarmup(): Promise<WarmupProgress> {
+        return this.request('GET', '/admin/warmup', undefined, undefined);
+    }
+
+    /** Starts warm-up over from the first week. */
+    restartWarmup(body?: unknown): Promise<WarmupProgress> {
+        return this.request('POST', '/admin/warmup', undefined, body);
+    }
+
+    /** Lifts the caps before the schedule is through. */
+    endWarmup(): Promise<WarmupProgress> {
+        return this.request('DELETE', '/admin/warmup', undefined, undefined);
+    }
+
+    /** Replaces the scheduled daily cap until the override is deleted or warm-up restarts. */
+    putWarmupOverride(body: PutWarmupOverrideRequest): Promise<unknown> {
+        return this.request('PUT', '/admin/warmup/override', undefined, body);
+    }
+
+    deleteWarmupOverride(): Promise<unknown> {
+        return this.request('DELETE', '/admin/warmup/override', undefined, undefined);
+    }
+
+    getAlerts(): Promise<GetAlertsResponse> {
+        return this.request('GET', '/admin/alerts', undefined, undefined);
+    }
+
+    getMaintenance(): Promise<Record<string, unknown>> {
+        return this.request('GET', '/admin/maintenance', undefined, undefined);
+    }
+
+    putMaintenance(body: MaintenanceRequest): Promise<Record<string, unknown>> {
+        return this.request('PUT', '/admin/maintenance', undefined, body);
+    }
+
+    listFeatureFlags(): Promise<ListFeatureFlagsResponse> {
+        return this.request('GET', '/admin/flags', undefined, undefined);
+    }
+
+    putFeatureFlag(name: string, body: FeatureFlagRequest): Promise<unknown> {
+        return this.request('PUT', `/admin/flags/${encodeURIComponent(name)}`, undefined, body);
+    }
+
+    /** Removes the override, going back to the default. */
+    deleteFeatureFlag(name: string): Promise<unknown> {
+        return this.request('DELETE', `/admin/flags/${encodeURIComponent(name)}`, undefined, undefined);
+    }
+
+    getMigrations(): Promise<GetMigrationsResponse> {
+        return this.request('GET', '/admin/migrations', undefined, undefined);
+    }
+
+    listAPIKeys(): Promise<ListAPIKeysResponse> {
+        return this.request('GET', '/admin/api-keys', undefined, undefined);
+    }
+
+    /** Returns the plaintext key once; only its hash is stored. */
+    createAPIKey(body: ApiKeyRequest): Promise<CreateAPIKeyResponse> {
+        return this.request('POST', '/admin/api-keys', undefined, body);
+    }
+
+    putAPIKeyPolicy(id: string, body: SendPolicy): Promise<SendPolicy> {
+        return this.request('PUT', `/admin/api-keys/${encodeURIComponent(id)}/policy`, undefined, body);
+    }
+
+    deleteAPIKey(id: string): Promise<DeleteAPIKeyResponse> {
+        return this.request('DELETE', `/admin/api-keys/${encodeURIComponent(id)}`, undefined, undefined);
+    }
+
+    getContentPolicy(): Promise<ContentPolicy | null> {
+        return this.request('GET', '/admin/content-policy', undefined, undefined);
+    }
+
+    putContentPolicy(body: ContentPolicy): Promise<ContentPolicy> {
+        return this.request('PUT', '/admin/content-policy', undefined, body);
+    }
+
+    /** Reports whether the store is encrypted and how many rows are still in plaintext. */
+    getMessageStoreStatus(): Promise<GetMessageStoreStatusResponse> {
+        return this.request('GET', '/admin/message-store', undefined, undefined);
+    }
+
+    /** Lists the tracked media transfers: pending ones and failed ones kept for inspection. */
+    listMediaJobs(query: { status?: string | number | boolean; limit?: string | number | boolean } = {}): Promise<ListMediaJobsResponse> {
+        return this.request('GET', '/admin/media-jobs', query, undefined);
+    }
+
+    listQuarantine(query: { limit?: string | number | boolean } = {}): Promise<ListQuarantineResponse> {
+        return this.request('GET', '/admin/quarantine', query, undefined);
+    }
+
+    /** Removes a quarantined file once it's been dealt with. */
+    deleteQuarantinedFile(id: string): Promise<void> {
+        return this.request('DELETE', `/admin/quarantine/${encodeURIComponent(id)}`, undefined, undefined);
+    }
+
+    /** Returns everything stored about a phone number. */
+    exportDataSubject(phone: string): Promise<ExportDataSubjectResponse> {
+        return this.request('GET', `/admin/data-subjects/${encodeURIComponent(phone)}`, undefined, undefined);
+    }
+
+    eraseDataSubject(phone: string): Promise<ErasureCertificate> {
+        return this.request('DELETE', `/admin/data-subjects/${encodeURIComponent(phone)}`, undefined, undefined);
+    }
+
+    listAuditLog(query: { action?: string | number | boolean; subject?: string | number | boolean; limit?: string | number | boolean } = {}): Promise<ListAuditLogResponse> {
+        return this.request('GET', '/admin/audit-log', query, undefined);
+    }
+
+    getAppStateResync(): Promise<unknown> {
+        return this.request('GET', '/admin/appstate/resync', undefined, undefined);
+    }
+
+    startAppStateResync(body: AppStateResyncRequest): Promise<unknown> {
+        return this.request('POST', '/admin/appstate/resync', undefined, body);
+    }
+
+    getDeviceIdentity(): Promise<DeviceIdentity> {
+        return this.request('GET', '/admin/device', undefined, undefined);
+    }
+
+    putDeviceIdentity(body: DeviceIdentity): Promise<DeviceIdentity> {
+        return this.request('PUT', '/admin/device', undefined, body);
+    }
+
+    getClientVersion(): Promise<ClientVersionInfo> {
+        return this.request('GET', '/admin/client-version', undefined, undefined);
+    }
+
+    /** Overrides the client version and reconnects with it. */
+    putClientVersion(body: ClientVersionRequest): Promise<ClientVersionInfo> {
+        return this.request('PUT', '/admin/client-version', undefined, body);
+    }
+
+    listBackups(): Promise<ListBackupsResponse> {
+        return this.request('GET', '/admin/backups', undefined, undefined);
+    }
+
+    triggerBackup(body?: unknown): Promise<BackupInfo> {
+        return this.request('POST', '/admin/backups', undefined, body);
+    }
+
+    /** Downloads and checks a backup, stages it and shuts down so it's swapped in on restart. */
+    restoreBackup(name: string, body?: unknown): Promise<RestoreBackupResponse> {
+        return this.request('POST', `/admin/backups/${encodeURIComponent(name)}/restore`, undefined, body);
+    }
+
+    getSession(): Promise<Record<string, unknown>> {
+        return this.request('GET', '/admin/session', undefined, undefined);
+    }
+
+    getConsoleState(): Promise<ConsoleState> {
+        return this.request('GET', '/admin/console/state', undefined, undefined);
+    }
+
+    /** Returns the kept events newer than ?after, oldest first. */
+    listConsoleEvents(query: { after?: string | number | boolean } = {}): Promise<ListConsoleEventsResponse> {
+        return this.request('GET', '/admin/console/events', query, undefined);
+    }
+
+    archiveSession(body?: unknown): Promise<Record<string, unknown>> {
+        return this.request('POST', '/admin/session/archive', undefined, body);
+    }
+
+    restoreSession(body?: unknown): Promise<Record<string, unknown>> {
+        return this.request('POST', '/admin/session/restore', undefined, body);
+    }
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// An archived session is disconnected without logging out: the device keys,
+// the whatsmeow store and the gateway history stay in place (and in the state
+// snapshot) but nothing is sent. Restoring within the grace period reconnects
+// without re-pairing; after that WhatsApp has most likely unlinked the device.
+
+var sessionArchiveGrace = envDuration("SESSION_ARCHIVE_GRACE", 14*24*time.Hour)
+
+var errSessionArchived = errors.New("session is archived")
+
+var (
+	sessionMu         sync.RWMutex
+	sessionArchivedAt time.Time // zero when active
+)
+
+func sessionArchived() bool {
+	sessionMu.RLock()
+	defer sessionMu.RUnlock()
+	return !sessionArchivedAt.IsZero()
+}
+
+func loadSessionState(ctx context.Context) {
+	at, _ := strconv.ParseInt(getSetting(ctx, "session_archived_at", "0"), 10, 64)
+	if at > 0 {
+		sessionMu.Lock()
+		sessionArchivedAt = time.Unix(at, 0)
+		sessionMu.Unlock()
+		waLogger.Warnf("Session is archived since %s; not connecting", time.Unix(at, 0))
+	}
+}
+
+func setSessionArchivedAt(ctx context.Context, at time.Time) error {
+	value := "0"
+	if !at.IsZero() {
+		value = strconv.FormatInt(at.Unix(), 10)
+	}
+	if err := setSetting(ctx, "session_archived_at", value); err != nil {
+		return err
+	}
+	sessionArchivedAt = at
+	return nil
+}
+
+func sessionState() map[string]interface{} {
+	sessionMu.RLock()
+	archivedAt := sessionArchivedAt
+	sessionMu.RUnlock()
+	state := map[string]interface{}{
+		"archived":  !archivedAt.IsZero(),
+		"paired":    client != nil && client.Store.ID != nil,
+		"connected": client != nil && client.IsConnected(),
+	}
+	if !archivedAt.IsZero() {
+		state["archived_at"] = archivedAt
+		state["restore_until"] = archivedAt.Add(sessionArchiveGrace)
+	}
+	return state
+}
+
+func getSession(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, sessionState())
+}
+
+func archiveSession(w http.ResponseWriter, r *http.Request) {
+	sessionMu.Lock()
+	if !sessionArchivedAt.IsZero() {
+		sessionMu.Unlock()
+		writeJSON(w, http.StatusOK, sessionState())
+		return
+	}
+	if err := setSessionArchivedAt(r.Context(), time.Now()); err != nil {
+		sessionMu.Unlock()
+		waLogger.Errorf("Failed to archive session: %v", err)
+		http.Error(w, "Failed to archive session", http.StatusInternalServerError)
+		return
+	}
+	sessionMu.Unlock()
+
+	waLogger.Infof("Archiving session")
+	if client != nil {
+		client.Disconnect()
+	}
+	// Hand the retained store to the gateway now rather than at shutdown, so
+	// the container can be stopped right away.
+	go uploadStateSnapshot()
+	emitWebhook("session.archived", nil)
+	writeJSON(w, http.StatusOK, sessionState())
+}
+
+func restoreSession(w http.ResponseWriter, r *http.Request) {
+	sessionMu.Lock()
+	if sessionArchivedAt.IsZero() {
+		sessionMu.Unlock()
+		writeJSON(w, http.StatusOK, sessionState())
+		return
+	}
+	if time.Since(sessionArchivedAt) > sessionArchiveGrace {
+		sessionMu.Unlock()
+		http.Error(w, "Archive grace period has expired; the session must be re-paired", http.StatusGone)
+		return
+	}
+	if err := setSessionArchivedAt(r.Context(), time.Time{}); err != nil {
+		sessionMu.Unlock()
+		waLogger.Errorf("Failed to restore session: %v", err)
+		http.Error(w, "Failed to restore session", http.StatusInternalServerError)
+		return
+	}
+	sessionMu.Unlock()
+
+	waLogger.Infof("Restoring archived session")
+	if client != nil && client.Store.ID != nil && !client.IsConnected() {
+		if err := client.Connect(); err != nil {
+			waLogger.Errorf("Failed to reconnect restored session: %v", err)
+			http.Error(w, "Session restored but failed to reconnect", http.StatusBadGateway)
+			return
+		}
+	}
+	emitWebhook("session.restored", nil)
+	writeJSON(w, http.StatusOK, sessionState())
+}
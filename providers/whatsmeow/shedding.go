@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Send endpoints shed load instead of letting requests time out: while the
+// session is reconnecting, or while the outbound queue (in flight plus
+// queued) is deeper than SHED_QUEUE_DEPTH, they answer 503 with Retry-After.
+// Priority traffic, i.e. requests with an API key whose policy has priority
+// set (one-time passwords, say), is still accepted: it jumps the queue, and
+// text-like sends made while reconnecting are queued until the client is
+// back.
+
+var (
+	shedQueueDepth          = int64(envInt("SHED_QUEUE_DEPTH", 5000)) // 0 turns it off
+	shedQueueRetryAfter     = envDuration("SHED_QUEUE_RETRY_AFTER", time.Minute)
+	shedReconnectRetryAfter = envDuration("SHED_RECONNECT_RETRY_AFTER", 15*time.Second)
+)
+
+// priorityTraffic reports whether a request's API key sends priority traffic.
+func priorityTraffic(ctx context.Context) bool {
+	key := apiKeyFromContext(ctx)
+	return key != nil && key.Policy.Priority
+}
+
+// queuePriority is the outbound queue priority of a request's messages.
+func queuePriority(ctx context.Context) int {
+	if priorityTraffic(ctx) {
+		return 1
+	}
+	return 0
+}
+
+func writeShed(w http.ResponseWriter, retryAfter time.Duration, msg string) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Round(time.Second)/time.Second)))
+	http.Error(w, msg, http.StatusServiceUnavailable)
+}
+
+// shedLoad turns non-priority sends away while the outbound queue is too
+// deep. It goes inside requireAPIKey, which tells priority traffic apart.
+func shedLoad(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if shedQueueDepth > 0 && !priorityTraffic(r.Context()) && outboundQueueDepth() >= shedQueueDepth {
+			writeShed(w, shedQueueRetryAfter, "Outbound queue is full, retry later")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// requireClient answers 503 with Retry-After unless the client is connected.
+// With canQueue, priority traffic of a paired session gets through anyway,
+// to be queued until the client has reconnected.
+func requireClient(w http.ResponseWriter, r *http.Request, canQueue bool) bool {
+	if client != nil && client.IsConnected() {
+		return true
+	}
+	if canQueue && client != nil && client.Store.ID != nil && priorityTraffic(r.Context()) {
+		return true
+	}
+	writeShed(w, shedReconnectRetryAfter, "Client not connected")
+	return false
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// Response-time SLAs are tracked per conversation: a conversation opens with
+// the first inbound message on a private chat that has none open, gets its
+// first response with the next outbound message, and ends when the chat is
+// resolved in the inbox. A conversation still waiting past SLA_FIRST_RESPONSE
+// or SLA_RESOLUTION (0 disables either) emits an sla.breached webhook once
+// per target.
+
+var (
+	slaFirstResponse = envDuration("SLA_FIRST_RESPONSE", 15*time.Minute)
+	slaResolution    = envDuration("SLA_RESOLUTION", 24*time.Hour)
+)
+
+type conversation struct {
+	ID                    int64      `json:"id"`
+	JID                   string     `json:"jid"`
+	Assignee              string     `json:"assignee,omitempty"`
+	OpenedAt              time.Time  `json:"opened_at"`
+	FirstResponseAt       *time.Time `json:"first_response_at,omitempty"`
+	ResolvedAt            *time.Time `json:"resolved_at,omitempty"`
+	FirstResponseSeconds  *int64     `json:"first_response_seconds,omitempty"`
+	ResolutionSeconds     *int64     `json:"resolution_seconds,omitempty"`
+	FirstResponseBreached bool       `json:"first_response_breached"`
+	ResolutionBreached    bool       `json:"resolution_breached"`
+}
+
+const conversationColumns = `id, chat_jid, assignee, opened_at, first_response_at, resolved_at`
+
+func scanConversation(scan func(dest ...interface{}) error, now time.Time) (conversation, error) {
+	var c conversation
+	var opened, responded, resolved int64
+	if err := scan(&c.ID, &c.JID, &c.Assignee, &opened, &responded, &resolved); err != nil {
+		return c, err
+	}
+	c.OpenedAt = time.Unix(opened, 0).UTC()
+	c.FirstResponseAt = unixPtr(responded)
+	c.ResolvedAt = unixPtr(resolved)
+	if responded > 0 {
+		d := responded - opened
+		c.FirstResponseSeconds = &d
+	}
+	if resolved > 0 {
+		d := resolved - opened
+		c.ResolutionSeconds = &d
+	}
+	// Unanswered and unresolved conversations count until now (or until they
+	// were resolved without a reply).
+	end := now.Unix()
+	if resolved > 0 {
+		end = resolved
+	}
+	waited := end - opened
+	if c.FirstResponseSeconds != nil {
+		waited = *c.FirstResponseSeconds
+	}
+	c.FirstResponseBreached = slaFirstResponse > 0 && waited > int64(slaFirstResponse/time.Second)
+	c.ResolutionBreached = slaResolution > 0 && end-opened > int64(slaResolution/time.Second)
+	return c, nil
+}
+
+// recordConversationActivity opens a conversation on an inbound message, or
+// records the first response to an open one.
+func recordConversationActivity(ctx context.Context, chat types.JID, inbound bool, ts time.Time) {
+	if chat.Server != types.DefaultUserServer {
+		return
+	}
+	var err error
+	if inbound {
+		_, err = gatewayDB.ExecContext(ctx, `
+			INSERT INTO conversations (chat_jid, assignee, opened_at)
+			SELECT jid, assignee, ? FROM chats WHERE jid = ?
+			ON CONFLICT DO NOTHING`,
+			ts.Unix(), chat.String())
+	} else {
+		_, err = gatewayDB.ExecContext(ctx, `
+			UPDATE conversations SET first_response_at = ?
+			WHERE chat_jid = ? AND resolved_at = 0 AND first_response_at = 0 AND opened_at <= ?`,
+			ts.Unix(), chat.String(), ts.Unix())
+	}
+	if err != nil {
+		waLogger.Errorf("Failed to record conversation activity on %s: %v", chat, err)
+	}
+}
+
+// resolveConversation closes the open conversation of a chat.
+func resolveConversation(ctx context.Context, chat types.JID) {
+	_, err := gatewayDB.ExecContext(ctx, `UPDATE conversations SET resolved_at = ? WHERE chat_jid = ? AND resolved_at = 0`,
+		time.Now().Unix(), chat.String())
+	if err != nil {
+		waLogger.Errorf("Failed to resolve conversation on %s: %v", chat, err)
+	}
+}
+
+// assignConversation moves the open conversation of a chat to its new
+// assignee, who is then accountable for its SLA.
+func assignConversation(ctx context.Context, chat types.JID, assignee string) {
+	_, err := gatewayDB.ExecContext(ctx, `UPDATE conversations SET assignee = ? WHERE chat_jid = ? AND resolved_at = 0`,
+		assignee, chat.String())
+	if err != nil {
+		waLogger.Errorf("Failed to reassign conversation on %s: %v", chat, err)
+	}
+}
+
+// emitSLABreaches sends sla.breached for open conversations that just went
+// past a target.
+func emitSLABreaches(ctx context.Context) {
+	now := time.Now()
+	for _, metric := range []struct {
+		name   string
+		column string
+		target time.Duration
+		where  string
+	}{
+		{"first_response", "first_response_breached", slaFirstResponse, `first_response_at = 0`},
+		{"resolution", "resolution_breached", slaResolution, `1 = 1`},
+	} {
+		if metric.target <= 0 {
+			continue
+		}
+		rows, err := gatewayDB.QueryContext(ctx, `SELECT `+conversationColumns+` FROM conversations
+			WHERE resolved_at = 0 AND `+metric.column+` = 0 AND `+metric.where+` AND opened_at < ?`,
+			now.Add(-metric.target).Unix())
+		if err != nil {
+			waLogger.Errorf("Failed to check %s SLA: %v", metric.name, err)
+			continue
+		}
+		var breached []conversation
+		for rows.Next() {
+			if c, err := scanConversation(rows.Scan, now); err == nil {
+				breached = append(breached, c)
+			}
+		}
+		rows.Close()
+		for _, c := range breached {
+			if _, err := gatewayDB.ExecContext(ctx, `UPDATE conversations SET `+metric.column+` = 1 WHERE id = ?`, c.ID); err != nil {
+				waLogger.Errorf("Failed to flag SLA breach of conversation %d: %v", c.ID, err)
+				continue
+			}
+			emitWebhook("sla.breached", map[string]interface{}{
+				"conversation_id": c.ID,
+				"jid":             c.JID,
+				"assignee":        c.Assignee,
+				"metric":          metric.name,
+				"target_seconds":  int64(metric.target / time.Second),
+				"opened_at":       c.OpenedAt,
+				"elapsed_seconds": int64(now.Sub(c.OpenedAt) / time.Second),
+			})
+		}
+	}
+}
+
+func runSLAMonitor() {
+	if slaFirstResponse <= 0 && slaResolution <= 0 {
+		return
+	}
+	for range time.Tick(time.Minute) {
+		emitSLABreaches(context.Background())
+	}
+}
+
+// slaWindow reads the days, assignee and jid filters of the SLA analytics.
+func slaWindow(w http.ResponseWriter, r *http.Request) (string, []interface{}, time.Time, bool) {
+	days := 7
+	if d := r.URL.Query().Get("days"); d != "" {
+		n, err := strconv.Atoi(d)
+		if err != nil || n <= 0 || n > 90 {
+			http.Error(w, "Invalid days, expected 1-90", http.StatusBadRequest)
+			return "", nil, time.Time{}, false
+		}
+		days = n
+	}
+	since := time.Now().UTC().AddDate(0, 0, -days)
+	where := `opened_at >= ?`
+	args := []interface{}{since.Unix()}
+	if assignee := r.URL.Query().Get("assignee"); assignee != "" {
+		where += ` AND assignee = ?`
+		args = append(args, assignee)
+	}
+	if jid := r.URL.Query().Get("jid"); jid != "" {
+		chat, ok := parseJID(jid)
+		if !ok {
+			http.Error(w, "Invalid JID", http.StatusBadRequest)
+			return "", nil, time.Time{}, false
+		}
+		where += ` AND chat_jid = ?`
+		args = append(args, canonicalJID(r.Context(), chat).String())
+	}
+	return where, args, since, true
+}
+
+func queryConversations(ctx context.Context, where string, args ...interface{}) ([]conversation, error) {
+	rows, err := gatewayDB.QueryContext(ctx, `SELECT `+conversationColumns+` FROM conversations WHERE `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	now := time.Now()
+	conversations := []conversation{}
+	for rows.Next() {
+		c, err := scanConversation(rows.Scan, now)
+		if err != nil {
+			return nil, err
+		}
+		conversations = append(conversations, c)
+	}
+	return conversations, rows.Err()
+}
+
+type slaStats struct {
+	Count         int     `json:"count"` // conversations that reached this point
+	AvgSeconds    float64 `json:"avg_seconds"`
+	MedianSeconds int64   `json:"median_seconds"`
+	P90Seconds    int64   `json:"p90_seconds"`
+	Breached      int     `json:"breached"`
+	TargetSeconds int64   `json:"target_seconds,omitempty"`
+}
+
+func newSLAStats(durations []int64, breached int, target time.Duration) slaStats {
+	s := slaStats{Count: len(durations), Breached: breached, TargetSeconds: int64(target / time.Second)}
+	if len(durations) == 0 {
+		return s
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	var total int64
+	for _, d := range durations {
+		total += d
+	}
+	s.AvgSeconds = float64(total) / float64(len(durations))
+	s.MedianSeconds = durations[len(durations)/2]
+	s.P90Seconds = durations[(len(durations)*9)/10]
+	return s
+}
+
+// getSLAAnalytics aggregates the conversations opened in the last days
+// (7 by default), optionally of one assignee or chat.
+func getSLAAnalytics(w http.ResponseWriter, r *http.Request) {
+	where, args, since, ok := slaWindow(w, r)
+	if !ok {
+		return
+	}
+	conversations, err := queryConversations(r.Context(), where, args...)
+	if err != nil {
+		waLogger.Errorf("Failed to load conversations: %v", err)
+		http.Error(w, "Failed to compute SLA analytics", http.StatusInternalServerError)
+		return
+	}
+	var firstResponses, resolutions []int64
+	var frBreached, resBreached, open int
+	for _, c := range conversations {
+		if c.FirstResponseSeconds != nil {
+			firstResponses = append(firstResponses, *c.FirstResponseSeconds)
+		}
+		if c.ResolutionSeconds != nil {
+			resolutions = append(resolutions, *c.ResolutionSeconds)
+		} else {
+			open++
+		}
+		if c.FirstResponseBreached {
+			frBreached++
+		}
+		if c.ResolutionBreached {
+			resBreached++
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"since":          since,
+		"until":          time.Now().UTC(),
+		"conversations":  len(conversations),
+		"open":           open,
+		"first_response": newSLAStats(firstResponses, frBreached, slaFirstResponse),
+		"resolution":     newSLAStats(resolutions, resBreached, slaResolution),
+	})
+}
+
+// listSLAConversations lists conversations, newest first; ?breached=true
+// keeps those that missed a target.
+func listSLAConversations(w http.ResponseWriter, r *http.Request) {
+	where, args, _, ok := slaWindow(w, r)
+	if !ok {
+		return
+	}
+	limit := 100
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 || n > 1000 {
+			http.Error(w, "Invalid limit, expected 1-1000", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	breachedOnly, _ := strconv.ParseBool(r.URL.Query().Get("breached"))
+	conversations, err := queryConversations(r.Context(), where+` ORDER BY opened_at DESC`, args...)
+	if err != nil {
+		waLogger.Errorf("Failed to load conversations: %v", err)
+		http.Error(w, "Failed to list conversations", http.StatusInternalServerError)
+		return
+	}
+	out := []conversation{}
+	for _, c := range conversations {
+		if len(out) == limit {
+			break
+		}
+		if !breachedOnly || c.FirstResponseBreached || c.ResolutionBreached {
+			out = append(out, c)
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"conversations": out})
+}
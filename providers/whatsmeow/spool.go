@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+
+	"go.mau.fi/whatsmeow"
+)
+
+// Media that can be large goes through files in MEDIA_SPOOL_DIR instead of
+// memory, so a few concurrent 100 MB videos don't take the container down:
+// uploads are streamed from the request (or the url) to a spool file and
+// from there, encrypted through a second one, to WhatsApp, and downloads are
+// written to a spool file and moved into MEDIA_DIR, the bucket or the
+// response from there. Only the first bytes are kept in memory, to sniff the
+// type from. Files that are small by their limits and read whole anyway
+// (stickers and voice notes to convert, images to analyze, voice notes to
+// transcribe, documents to scan) are still buffered.
+
+// sniffLen is as much of a file as http.DetectContentType looks at.
+const sniffLen = 512
+
+// spooledFile is a media file in MEDIA_SPOOL_DIR.
+type spooledFile struct {
+	Path   string
+	Size   int64
+	SHA256 []byte
+	Head   []byte // the first sniffLen bytes
+}
+
+func createSpoolFile(pattern string) (*os.File, error) {
+	if err := os.MkdirAll(mediaSpoolDir, 0o700); err != nil {
+		return nil, err
+	}
+	return os.CreateTemp(mediaSpoolDir, pattern)
+}
+
+// spoolReader copies r to a spool file. Past maxBytes it fails with a
+// media_too_large rejection naming the file what.
+func spoolReader(r io.Reader, maxBytes int64, what string) (*spooledFile, error) {
+	f, err := createSpoolFile("upload-*")
+	if err != nil {
+		return nil, err
+	}
+	spooled, err := readSpool(f, r, maxBytes)
+	if err == nil {
+		spooled.Path = f.Name()
+	}
+	if err == nil && spooled.Size > maxBytes {
+		err = mediaTooLarge(fmt.Sprintf("%s is too large: over %d bytes", what, maxBytes), 0, maxBytes)
+	}
+	// Synced, since an upload job resumes from the file after a crash.
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return nil, err
+	}
+	return spooled, nil
+}
+
+// readSpool copies up to maxBytes+1 bytes of r to w, noting their size, hash
+// and first bytes.
+func readSpool(w io.Writer, r io.Reader, maxBytes int64) (*spooledFile, error) {
+	hash := sha256.New()
+	head := &headWriter{}
+	n, err := io.Copy(io.MultiWriter(w, hash, head), io.LimitReader(r, maxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	return &spooledFile{Size: n, SHA256: hash.Sum(nil), Head: head.buf}, nil
+}
+
+// spoolBytes spools a file already in memory, such as a converted one.
+func spoolBytes(data []byte) (*spooledFile, error) {
+	return spoolReader(bytes.NewReader(data), int64(len(data)), "file")
+}
+
+// downloadToSpool downloads the file of a media message to a spool file.
+func downloadToSpool(ctx context.Context, media whatsmeow.DownloadableMessage) (*spooledFile, error) {
+	f, err := createSpoolFile("download-*")
+	if err != nil {
+		return nil, err
+	}
+	err = client.DownloadToFile(ctx, media, f)
+	var spooled *spooledFile
+	if err == nil {
+		// Hashing it means reading it back, but from the page cache.
+		if _, err = f.Seek(0, io.SeekStart); err == nil {
+			if spooled, err = readSpool(io.Discard, f, math.MaxInt64-1); err == nil {
+				spooled.Path = f.Name()
+			}
+		}
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return nil, err
+	}
+	return spooled, nil
+}
+
+// describeSpoolFile describes a file something else wrote to the spool,
+// such as ffmpeg.
+func describeSpoolFile(path string) (*spooledFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	spooled, err := readSpool(io.Discard, f, math.MaxInt64-1)
+	if err != nil {
+		return nil, err
+	}
+	spooled.Path = path
+	return spooled, nil
+}
+
+// ReadAll reads a spooled file whole, for the small files that are.
+func (f *spooledFile) ReadAll() ([]byte, error) {
+	return os.ReadFile(f.Path)
+}
+
+// Remove deletes the file; it may be called on nil.
+func (f *spooledFile) Remove() {
+	if f != nil {
+		os.Remove(f.Path)
+	}
+}
+
+// removeStaleSpoolFiles deletes the spool files a crash left behind: those
+// older than this process that none of the pending jobs resumes from.
+func removeStaleSpoolFiles(jobs []mediaJob) {
+	entries, err := os.ReadDir(mediaSpoolDir)
+	if err != nil {
+		return
+	}
+	pending := map[string]bool{}
+	for _, job := range jobs {
+		pending[job.spool] = true
+	}
+	for _, entry := range entries {
+		path := filepath.Join(mediaSpoolDir, entry.Name())
+		info, err := entry.Info()
+		if err != nil || pending[path] || info.ModTime().After(startTime) {
+			continue
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			waLogger.Warnf("Failed to remove stale spool file %s: %v", path, err)
+		}
+	}
+}
+
+// headWriter keeps the first sniffLen bytes written to it.
+type headWriter struct {
+	buf []byte
+}
+
+func (w *headWriter) Write(p []byte) (int, error) {
+	if n := sniffLen - len(w.buf); n > 0 {
+		w.buf = append(w.buf, p[:min(n, len(p))]...)
+	}
+	return len(p), nil
+}
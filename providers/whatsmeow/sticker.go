@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image/gif"
+	"net/http"
+	"strconv"
+
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"google.golang.org/protobuf/proto"
+)
+
+// Stickers are 512x512 WebP images. /send/sticker converts PNG and JPEG
+// images (and GIFs, as animated stickers) with ffmpeg, fitting them into the
+// square on a transparent background, and lowers the quality until the result
+// fits WhatsApp's size limits.
+
+var stickerMaxBytes = int64(envInt("STICKER_MAX_BYTES", 10<<20))
+
+const (
+	stickerSize             = 512
+	stickerMaxWebPBytes     = 100 << 10
+	animatedStickerMaxBytes = 500 << 10
+	// Animated stickers are cut off after this many seconds.
+	animatedStickerSeconds = 10
+)
+
+var errStickerTooLarge = errors.New("sticker is too large even at the lowest quality")
+
+// isAnimatedGIF reports whether data is a GIF with more than one frame.
+func isAnimatedGIF(data []byte) bool {
+	g, err := gif.DecodeAll(bytes.NewReader(data))
+	return err == nil && len(g.Image) > 1
+}
+
+// convertToSticker converts an image to a sticker, or an animated GIF to an
+// animated sticker.
+func convertToSticker(ctx context.Context, data []byte, animated bool) ([]byte, error) {
+	filter := fmt.Sprintf("scale=%[1]d:%[1]d:force_original_aspect_ratio=decrease:flags=lanczos,"+
+		"format=yuva420p,pad=%[1]d:%[1]d:(ow-iw)/2:(oh-ih)/2:color=black@0", stickerSize)
+	maxBytes := stickerMaxWebPBytes
+	if animated {
+		filter = "fps=15," + filter
+		maxBytes = animatedStickerMaxBytes
+	}
+	for _, quality := range []int{80, 60, 40, 20} {
+		var args []string
+		if animated {
+			args = []string{"-t", strconv.Itoa(animatedStickerSeconds), "-vf", filter, "-an",
+				"-c:v", "libwebp_anim", "-q:v", strconv.Itoa(quality), "-loop", "0", "-f", "webp"}
+		} else {
+			args = []string{"-vf", filter, "-frames:v", "1",
+				"-c:v", "libwebp", "-q:v", strconv.Itoa(quality), "-f", "webp"}
+		}
+		out, err := runFFmpeg(ctx, data, args...)
+		if err != nil {
+			return nil, err
+		}
+		if len(out) <= maxBytes {
+			return out, nil
+		}
+	}
+	return nil, errStickerTooLarge
+}
+
+func sendSticker(w http.ResponseWriter, r *http.Request) {
+	if sessionArchived() {
+		http.Error(w, "Session is archived", http.StatusConflict)
+		return
+	}
+	if !requireClient(w, r, false) {
+		return
+	}
+	up, err := readMediaUpload(w, r, "sticker", stickerMaxBytes)
+	if err != nil {
+		writeMediaError(w, err)
+		return
+	}
+	defer up.File.Remove()
+	// Stickers are small and converted anyway, so they're read whole.
+	image, err := up.File.ReadAll()
+	if err != nil {
+		waLogger.Errorf("Failed to read spooled sticker: %v", err)
+		http.Error(w, "Failed to read sticker", http.StatusInternalServerError)
+		return
+	}
+	var animated bool
+	switch http.DetectContentType(image) {
+	case "image/png", "image/jpeg":
+	case "image/gif":
+		animated = isAnimatedGIF(image)
+	default:
+		writeMediaError(w, unsupportedMedia("Sticker must be a PNG, JPEG or GIF image"))
+		return
+	}
+	recipient, err := parseRecipient(up.To)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	recipient = toPhoneJID(r.Context(), recipient)
+	// Check the policy before spending time on the conversion and upload.
+	if err := checkSendPolicy(r.Context(), recipient); err != nil {
+		writeSendError(w, recipient, err)
+		return
+	}
+
+	data, err := convertToSticker(r.Context(), image, animated)
+	switch {
+	case errors.Is(err, errUnsupportedMedia):
+		writeMediaError(w, unsupportedMedia("Unsupported image"))
+		return
+	case errors.Is(err, errStickerTooLarge):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	case err != nil:
+		waLogger.Errorf("Failed to convert sticker for %s: %v", recipient, err)
+		http.Error(w, "Failed to convert sticker", http.StatusInternalServerError)
+		return
+	}
+	sticker := &waE2E.StickerMessage{
+		FileLength: proto.Uint64(uint64(len(data))),
+		Mimetype:   proto.String("image/webp"),
+		Width:      proto.Uint32(stickerSize),
+		Height:     proto.Uint32(stickerSize),
+	}
+	if animated {
+		sticker.IsAnimated = proto.Bool(true)
+	}
+
+	opts := sendOptions{AllowDuplicate: up.AllowDuplicate, Expiration: up.Expiration}
+	file, err := spoolBytes(data)
+	if err != nil {
+		waLogger.Errorf("Failed to spool sticker for %s: %v", recipient, err)
+		http.Error(w, "Failed to convert sticker", http.StatusInternalServerError)
+		return
+	}
+	defer file.Remove()
+	res, err := sendMedia(r.Context(), recipient, file, &waE2E.Message{StickerMessage: sticker}, opts)
+	if errors.Is(err, errMediaUpload) {
+		waLogger.Errorf("Failed to upload sticker for %s: %v", recipient, err)
+		http.Error(w, "Failed to upload sticker", http.StatusBadGateway)
+		return
+	} else if err != nil {
+		writeSendError(w, recipient, err)
+		return
+	}
+	writeSendResult(w, recipient, res)
+}
@@ -0,0 +1,410 @@
+package main
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message store encryption (MESSAGE_STORE_KMS) protects the text and
+// transcripts in the message store with envelope encryption: each tenant's
+// gateway has its own random data key, which is stored only in wrapped form,
+// encrypted by a key-encryption key held in a KMS. A leaked database is
+// useless without access to the tenant's KMS key.
+//
+// Values are encrypted with AES-256-GCM and stored as "enc:v1:" followed by
+// base64 of the nonce and ciphertext (see storeCiphertext). Plaintext rows
+// written before encryption was enabled are encrypted in the background at
+// startup. If the data key can't be unwrapped the message store is disabled
+// rather than written in plaintext.
+//
+// The media keys kept for inbound media, queued outbound messages and the
+// messages behind media jobs are encrypted the same way; files downloaded to
+// MEDIA_DIR are not.
+
+const storeCipherPrefix = "enc:v1:"
+
+// keyWrapper wraps and unwraps data keys with a KMS-held key.
+type keyWrapper interface {
+	Wrap(ctx context.Context, key []byte) (string, error)
+	Unwrap(ctx context.Context, wrapped string) ([]byte, error)
+}
+
+func newKeyWrapper(provider string) keyWrapper {
+	key := envString("MESSAGE_STORE_KMS_KEY", "")
+	switch strings.ToLower(provider) {
+	case "":
+		return nil
+	case "local":
+		// A key-encryption key from the environment, for setups whose
+		// secret manager injects it; it must not live in the database.
+		kek, err := base64.StdEncoding.DecodeString(key)
+		if err != nil || len(kek) != 32 {
+			waLogger.Errorf("MESSAGE_STORE_KMS_KEY must be 32 bytes of base64 for the local KMS")
+			return nil
+		}
+		return &localKeyWrapper{kek: kek}
+	case "vault":
+		// HashiCorp Vault's transit secrets engine, with a transit key
+		// per tenant.
+		url := envString("MESSAGE_STORE_KMS_URL", envString("VAULT_ADDR", ""))
+		if url == "" {
+			waLogger.Errorf("MESSAGE_STORE_KMS_URL is required for Vault")
+			return nil
+		}
+		if key == "" {
+			key = "whatsapp-gateway"
+		}
+		return &vaultKeyWrapper{
+			url:   strings.TrimSuffix(url, "/"),
+			token: envString("MESSAGE_STORE_KMS_TOKEN", envString("VAULT_TOKEN", "")),
+			key:   key,
+		}
+	default:
+		waLogger.Errorf("Unknown MESSAGE_STORE_KMS %q", provider)
+		return nil
+	}
+}
+
+type localKeyWrapper struct {
+	kek []byte
+}
+
+func (w *localKeyWrapper) Wrap(ctx context.Context, key []byte) (string, error) {
+	aead, err := newAEAD(w.kek)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(sealValue(aead, key)), nil
+}
+
+func (w *localKeyWrapper) Unwrap(ctx context.Context, wrapped string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(wrapped)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(w.kek)
+	if err != nil {
+		return nil, err
+	}
+	return openValue(aead, data)
+}
+
+type vaultKeyWrapper struct {
+	url   string
+	token string
+	key   string
+}
+
+func (w *vaultKeyWrapper) Wrap(ctx context.Context, key []byte) (string, error) {
+	var resp struct {
+		Data struct {
+			Ciphertext string `json:"ciphertext"`
+		} `json:"data"`
+	}
+	err := providerJSON(ctx, w.url+"/v1/transit/encrypt/"+w.key, http.Header{"X-Vault-Token": {w.token}},
+		map[string]string{"plaintext": base64.StdEncoding.EncodeToString(key)}, &resp)
+	return resp.Data.Ciphertext, err
+}
+
+func (w *vaultKeyWrapper) Unwrap(ctx context.Context, wrapped string) ([]byte, error) {
+	var resp struct {
+		Data struct {
+			Plaintext string `json:"plaintext"`
+		} `json:"data"`
+	}
+	err := providerJSON(ctx, w.url+"/v1/transit/decrypt/"+w.key, http.Header{"X-Vault-Token": {w.token}},
+		map[string]string{"ciphertext": wrapped}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(resp.Data.Plaintext)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func sealValue(aead cipher.AEAD, plaintext []byte) []byte {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		panic(err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, nil)
+}
+
+func openValue(aead cipher.AEAD, data []byte) ([]byte, error) {
+	if len(data) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	return aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+}
+
+// storedDataKey is the gateway setting holding the wrapped data key.
+type storedDataKey struct {
+	Provider  string    `json:"provider"`
+	Wrapped   string    `json:"wrapped"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+var (
+	storeCipherMu sync.RWMutex
+	storeCipher   cipher.AEAD // nil while encryption is off
+)
+
+func currentStoreCipher() cipher.AEAD {
+	storeCipherMu.RLock()
+	defer storeCipherMu.RUnlock()
+	return storeCipher
+}
+
+// loadMessageStoreKey unwraps the tenant's data key, creating it on first
+// use.
+func loadMessageStoreKey(ctx context.Context) {
+	if !messageStoreEnabled {
+		return
+	}
+	provider := envString("MESSAGE_STORE_KMS", "")
+	var stored storedDataKey
+	raw := getSetting(ctx, "message_store_data_key", "")
+	if raw != "" {
+		if err := json.Unmarshal([]byte(raw), &stored); err != nil {
+			waLogger.Errorf("Invalid message store data key, message store disabled: %v", err)
+			messageStoreEnabled = false
+			return
+		}
+	}
+	if provider == "" {
+		if raw != "" {
+			waLogger.Errorf("The message store is encrypted but MESSAGE_STORE_KMS is not set, message store disabled")
+			messageStoreEnabled = false
+		}
+		return
+	}
+	wrapper := newKeyWrapper(provider)
+	if wrapper == nil {
+		waLogger.Errorf("Message store encryption is misconfigured, message store disabled")
+		messageStoreEnabled = false
+		return
+	}
+	if raw != "" && !strings.EqualFold(stored.Provider, provider) {
+		waLogger.Errorf("The message store data key was wrapped by %s, not %s; message store disabled", stored.Provider, provider)
+		messageStoreEnabled = false
+		return
+	}
+
+	var key []byte
+	var err error
+	if raw == "" {
+		key = make([]byte, 32)
+		if _, err = rand.Read(key); err == nil {
+			stored = storedDataKey{Provider: strings.ToLower(provider), CreatedAt: time.Now().UTC()}
+			if stored.Wrapped, err = wrapper.Wrap(ctx, key); err == nil {
+				encoded, _ := json.Marshal(stored)
+				err = setSetting(ctx, "message_store_data_key", string(encoded))
+			}
+		}
+		if err == nil {
+			waLogger.Infof("Created message store data key (wrapped by %s)", stored.Provider)
+		}
+	} else {
+		key, err = wrapper.Unwrap(ctx, stored.Wrapped)
+	}
+	if err != nil {
+		waLogger.Errorf("Failed to get the message store data key, message store disabled: %v", err)
+		messageStoreEnabled = false
+		return
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		waLogger.Errorf("Invalid message store data key, message store disabled: %v", err)
+		messageStoreEnabled = false
+		return
+	}
+	storeCipherMu.Lock()
+	storeCipher = aead
+	storeCipherMu.Unlock()
+	go encryptPlaintextMessages(context.Background())
+}
+
+// encryptStoreValue encrypts a message store value; empty values stay empty
+// so upserts can still tell them apart.
+func encryptStoreValue(s string) string {
+	aead := currentStoreCipher()
+	if aead == nil || s == "" {
+		return s
+	}
+	return storeCipherPrefix + base64.StdEncoding.EncodeToString(sealValue(aead, []byte(s)))
+}
+
+func decryptStoreValue(s string) (string, error) {
+	data, ok := storeCiphertext(s)
+	if !ok {
+		return s, nil
+	}
+	aead := currentStoreCipher()
+	if aead == nil {
+		return "", errors.New("message store data key not loaded")
+	}
+	plain, err := openValue(aead, data)
+	return string(plain), err
+}
+
+// storeCipherMinLen is the shortest sealed value: a GCM nonce and tag.
+const storeCipherMinLen = 12 + 16
+
+// storeCiphertext returns the sealed bytes of an encrypted value. Text that
+// merely starts with the prefix isn't one: the rest must be base64 of at
+// least storeCipherMinLen bytes.
+func storeCiphertext(s string) ([]byte, bool) {
+	if !strings.HasPrefix(s, storeCipherPrefix) {
+		return nil, false
+	}
+	data, err := base64.StdEncoding.DecodeString(s[len(storeCipherPrefix):])
+	if err != nil || len(data) < storeCipherMinLen {
+		return nil, false
+	}
+	return data, true
+}
+
+// plaintextCondition is the SQL condition matching rows whose column isn't
+// encrypted, as storeCiphertext decides.
+func plaintextCondition(column string) string {
+	n := len(storeCipherPrefix)
+	return fmt.Sprintf(`(length(%[1]s) > 0 AND NOT (substr(%[2]s, 1, %[3]d) = '%[4]s' AND length(%[2]s) >= %[5]d`+
+		` AND (length(%[2]s) - %[3]d) %% 4 = 0 AND substr(%[2]s, %[3]d + 1) NOT GLOB '*[^A-Za-z0-9+/=]*'))`,
+		column, `CAST(`+column+` AS TEXT)`, n, storeCipherPrefix, n+base64.StdEncoding.EncodedLen(storeCipherMinLen))
+}
+
+// encryptStoreBytes encrypts a binary value, such as a marshalled message,
+// like encryptStoreValue.
+func encryptStoreBytes(b []byte) []byte {
+	return []byte(encryptStoreValue(string(b)))
+}
+
+func decryptStoreBytes(b []byte) ([]byte, error) {
+	s, err := decryptStoreValue(string(b))
+	return []byte(s), err
+}
+
+// encryptPlaintextMessages encrypts rows stored before encryption was
+// enabled, in batches.
+func encryptPlaintextMessages(ctx context.Context) {
+	const batch = 500
+	total := 0
+	for {
+		rows, err := gatewayDB.QueryContext(ctx, `
+			SELECT chat_jid, id, text, transcript FROM messages
+			WHERE `+plaintextCondition("text")+` OR `+plaintextCondition("transcript")+`
+			LIMIT ?`, batch)
+		if err != nil {
+			waLogger.Errorf("Failed to load plaintext messages: %v", err)
+			return
+		}
+		type plainRow struct{ chat, id, text, transcript string }
+		var pending []plainRow
+		for rows.Next() {
+			var p plainRow
+			if err := rows.Scan(&p.chat, &p.id, &p.text, &p.transcript); err != nil {
+				rows.Close()
+				waLogger.Errorf("Failed to scan plaintext message: %v", err)
+				return
+			}
+			pending = append(pending, p)
+		}
+		rows.Close()
+		for _, p := range pending {
+			if _, ok := storeCiphertext(p.text); !ok {
+				p.text = encryptStoreValue(p.text)
+			}
+			if _, ok := storeCiphertext(p.transcript); !ok {
+				p.transcript = encryptStoreValue(p.transcript)
+			}
+			_, err := gatewayDB.ExecContext(ctx, `UPDATE messages SET text = ?, transcript = ? WHERE chat_jid = ? AND id = ?`,
+				p.text, p.transcript, p.chat, p.id)
+			if err != nil {
+				waLogger.Errorf("Failed to encrypt message %s: %v", p.id, err)
+				return
+			}
+		}
+		total += len(pending)
+		if len(pending) < batch {
+			break
+		}
+	}
+	if total > 0 {
+		waLogger.Infof("Encrypted %d plaintext messages in the message store", total)
+	}
+	encryptPlaintextQueue(ctx)
+}
+
+// encryptPlaintextQueue encrypts queued outbound messages stored before
+// encryption was enabled.
+func encryptPlaintextQueue(ctx context.Context) {
+	rows, err := gatewayDB.QueryContext(ctx,
+		`SELECT id, message FROM outbound_queue WHERE `+plaintextCondition("message"))
+	if err != nil {
+		waLogger.Errorf("Failed to load plaintext queued messages: %v", err)
+		return
+	}
+	type plainRow struct {
+		id      int64
+		message []byte
+	}
+	var pending []plainRow
+	for rows.Next() {
+		var p plainRow
+		if err := rows.Scan(&p.id, &p.message); err != nil {
+			rows.Close()
+			waLogger.Errorf("Failed to scan plaintext queued message: %v", err)
+			return
+		}
+		pending = append(pending, p)
+	}
+	rows.Close()
+	for _, p := range pending {
+		_, err := gatewayDB.ExecContext(ctx, `UPDATE outbound_queue SET message = ? WHERE id = ?`, encryptStoreBytes(p.message), p.id)
+		if err != nil {
+			waLogger.Errorf("Failed to encrypt queued message %d: %v", p.id, err)
+			return
+		}
+	}
+	if len(pending) > 0 {
+		waLogger.Infof("Encrypted %d plaintext queued messages", len(pending))
+	}
+}
+
+// getMessageStoreStatus reports whether the store is encrypted and how many
+// rows are still in plaintext.
+func getMessageStoreStatus(w http.ResponseWriter, r *http.Request) {
+	status := map[string]interface{}{"enabled": messageStoreEnabled, "encrypted": currentStoreCipher() != nil}
+	var stored storedDataKey
+	if raw := getSetting(r.Context(), "message_store_data_key", ""); raw != "" && json.Unmarshal([]byte(raw), &stored) == nil {
+		status["kms"] = stored.Provider
+		status["data_key_created_at"] = stored.CreatedAt
+	}
+	var plaintext int
+	err := gatewayDB.QueryRowContext(r.Context(), `
+		SELECT COUNT(*) FROM messages
+		WHERE `+plaintextCondition("text")+` OR `+plaintextCondition("transcript")).Scan(&plaintext)
+	if err != nil {
+		waLogger.Errorf("Failed to count plaintext messages: %v", err)
+		http.Error(w, "Failed to load message store status", http.StatusInternalServerError)
+		return
+	}
+	status["plaintext_messages"] = plaintext
+	writeJSON(w, http.StatusOK, status)
+}
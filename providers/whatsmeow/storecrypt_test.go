@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+// useStoreKey turns store encryption on with key for the duration of a test.
+func useStoreKey(t *testing.T, key []byte) {
+	t.Helper()
+	aead, err := newAEAD(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	storeCipherMu.Lock()
+	storeCipher = aead
+	storeCipherMu.Unlock()
+	t.Cleanup(func() {
+		storeCipherMu.Lock()
+		storeCipher = nil
+		storeCipherMu.Unlock()
+	})
+}
+
+func TestStoreValueRoundTrip(t *testing.T) {
+	useStoreKey(t, bytes.Repeat([]byte{1}, 32))
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"text", "Hello, world"},
+		{"unicode", "Olá 👋 مرحبا"},
+		{"prefix lookalike", "enc:v1:not really"},
+		{"long", strings.Repeat("x", 10000)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enc := encryptStoreValue(tt.value)
+			if !strings.HasPrefix(enc, storeCipherPrefix) || strings.Contains(enc, tt.value) {
+				t.Fatalf("encryptStoreValue(%q) = %q, want ciphertext", tt.value, enc)
+			}
+			if enc == encryptStoreValue(tt.value) {
+				t.Errorf("encrypting twice gave the same ciphertext")
+			}
+			got, err := decryptStoreValue(enc)
+			if err != nil {
+				t.Fatalf("decryptStoreValue() error: %v", err)
+			}
+			if got != tt.value {
+				t.Errorf("decryptStoreValue() = %q, want %q", got, tt.value)
+			}
+		})
+	}
+
+	// Empty values stay empty, so upserts can tell them apart.
+	if enc := encryptStoreValue(""); enc != "" {
+		t.Errorf(`encryptStoreValue("") = %q, want ""`, enc)
+	}
+	raw := []byte{0x0a, 0x05, 'h', 'e', 'l', 'l', 'o', 0xff}
+	got, err := decryptStoreBytes(encryptStoreBytes(raw))
+	if err != nil || !bytes.Equal(got, raw) {
+		t.Errorf("decryptStoreBytes(encryptStoreBytes(%x)) = %x, %v", raw, got, err)
+	}
+}
+
+func TestDecryptStoreValue(t *testing.T) {
+	useStoreKey(t, bytes.Repeat([]byte{1}, 32))
+	sealed := encryptStoreValue("secret")
+	data, _ := storeCiphertext(sealed)
+	data[len(data)-1] ^= 1
+	tampered := storeCipherPrefix + base64.StdEncoding.EncodeToString(data)
+	tests := []struct {
+		name    string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"plaintext", "plain text", "plain text", false},
+		{"empty", "", "", false},
+		{"old prefix", "enc:hello", "enc:hello", false},
+		{"prefix without ciphertext", "enc:v1:hello world", "enc:v1:hello world", false},
+		{"prefix with too little ciphertext", "enc:v1:AAAA", "enc:v1:AAAA", false},
+		{"ciphertext", sealed, "secret", false},
+		{"tampered ciphertext", tampered, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decryptStoreValue(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decryptStoreValue(%q) error = %v, want error %v", tt.value, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("decryptStoreValue(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecryptStoreValueWithoutKey(t *testing.T) {
+	useStoreKey(t, bytes.Repeat([]byte{1}, 32))
+	sealed := encryptStoreValue("secret")
+
+	useStoreKey(t, bytes.Repeat([]byte{2}, 32))
+	if _, err := decryptStoreValue(sealed); err == nil {
+		t.Errorf("decrypting with another key succeeded")
+	}
+
+	storeCipherMu.Lock()
+	storeCipher = nil
+	storeCipherMu.Unlock()
+	if _, err := decryptStoreValue(sealed); err == nil {
+		t.Errorf("decrypting without a key succeeded")
+	}
+	if got := encryptStoreValue("secret"); got != "secret" {
+		t.Errorf("encryptStoreValue() without a key = %q, want the value as is", got)
+	}
+}
+
+func TestLocalKeyWrapperRoundTrip(t *testing.T) {
+	w := &localKeyWrapper{kek: bytes.Repeat([]byte{7}, 32)}
+	key := bytes.Repeat([]byte{9}, 32)
+	wrapped, err := w.Wrap(context.Background(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := w.Unwrap(context.Background(), wrapped)
+	if err != nil || !bytes.Equal(got, key) {
+		t.Fatalf("Unwrap(Wrap(key)) = %x, %v", got, err)
+	}
+	other := &localKeyWrapper{kek: bytes.Repeat([]byte{8}, 32)}
+	if _, err := other.Unwrap(context.Background(), wrapped); err == nil {
+		t.Errorf("unwrapping with another key-encryption key succeeded")
+	}
+}
+
+func TestPlaintextCondition(t *testing.T) {
+	useStoreKey(t, bytes.Repeat([]byte{1}, 32))
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	sealed := encryptStoreValue("secret")
+	tests := []struct {
+		name      string
+		value     interface{}
+		plaintext bool
+	}{
+		{"empty", "", false},
+		{"text", "hello", true},
+		{"old prefix", "enc:hello", true},
+		{"prefix without ciphertext", "enc:v1:hello world", true},
+		{"prefix with too little ciphertext", "enc:v1:AAAA", true},
+		{"ciphertext", sealed, false},
+		{"ciphertext as a blob", []byte(sealed), false},
+		{"marshalled message", []byte{0x0a, 0x03, 0xff, 0x00}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var n int
+			err := db.QueryRow(`SELECT COUNT(*) FROM (SELECT ? AS value) WHERE `+plaintextCondition("value"), tt.value).Scan(&n)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := n == 1; got != tt.plaintext {
+				t.Errorf("plaintextCondition matched %q: %v, want %v", tt.value, got, tt.plaintext)
+			}
+			// The SQL and the Go check agree.
+			s, _ := tt.value.(string)
+			if b, ok := tt.value.([]byte); ok {
+				s = string(b)
+			}
+			if _, sealed := storeCiphertext(s); s != "" && sealed == tt.plaintext {
+				t.Errorf("storeCiphertext(%q) = %v, disagrees with plaintextCondition", s, sealed)
+			}
+		})
+	}
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+	"golang.org/x/text/language"
+	"golang.org/x/text/language/display"
+)
+
+// Chat summaries condense the recent conversation in a chat, read from the
+// message store, into a short handoff note for the agent taking over. They
+// go through a pluggable LLM provider (SUMMARY_PROVIDER) and are disabled
+// unless one is configured.
+
+var (
+	summaryWindow      = envDuration("SUMMARY_WINDOW", 24*time.Hour)
+	summaryMaxMessages = envInt("SUMMARY_MAX_MESSAGES", 200)
+	summaryTimeout     = 60 * time.Second
+)
+
+type summarizer interface {
+	Summarize(ctx context.Context, instructions, transcript string) (string, error)
+}
+
+// activeSummarizer is nil when no provider is configured.
+var activeSummarizer summarizer
+
+func newSummarizer(provider string) summarizer {
+	key := envString("SUMMARY_API_KEY", "")
+	switch strings.ToLower(provider) {
+	case "":
+		return nil
+	case "openai":
+		// Any OpenAI-compatible chat completions API (vLLM, Ollama,
+		// LocalAI, ...) works with SUMMARY_API_URL.
+		return &openAISummarizer{
+			url:   strings.TrimSuffix(envString("SUMMARY_API_URL", "https://api.openai.com/v1"), "/"),
+			key:   key,
+			model: envString("SUMMARY_MODEL", "gpt-4o-mini"),
+		}
+	case "anthropic":
+		model := envString("SUMMARY_MODEL", "")
+		if model == "" {
+			waLogger.Errorf("SUMMARY_MODEL is required for Anthropic, chat summaries disabled")
+			return nil
+		}
+		return &anthropicSummarizer{
+			url:   strings.TrimSuffix(envString("SUMMARY_API_URL", "https://api.anthropic.com"), "/"),
+			key:   key,
+			model: model,
+		}
+	default:
+		waLogger.Errorf("Unknown SUMMARY_PROVIDER %q, chat summaries disabled", provider)
+		return nil
+	}
+}
+
+type openAISummarizer struct {
+	url   string
+	key   string
+	model string
+}
+
+func (s *openAISummarizer) Summarize(ctx context.Context, instructions, transcript string) (string, error) {
+	var resp struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	var header http.Header
+	if s.key != "" {
+		header = http.Header{"Authorization": {"Bearer " + s.key}}
+	}
+	err := providerJSON(ctx, s.url+"/chat/completions", header, map[string]interface{}{
+		"model": s.model,
+		"messages": []map[string]string{
+			{"role": "system", "content": instructions},
+			{"role": "user", "content": transcript},
+		},
+	}, &resp)
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("provider returned no summary")
+	}
+	return strings.TrimSpace(resp.Choices[0].Message.Content), nil
+}
+
+type anthropicSummarizer struct {
+	url   string
+	key   string
+	model string
+}
+
+func (s *anthropicSummarizer) Summarize(ctx context.Context, instructions, transcript string) (string, error) {
+	var resp struct {
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+	}
+	header := http.Header{"X-Api-Key": {s.key}, "Anthropic-Version": {"2023-06-01"}}
+	err := providerJSON(ctx, s.url+"/v1/messages", header, map[string]interface{}{
+		"model":      s.model,
+		"max_tokens": 1024,
+		"system":     instructions,
+		"messages":   []map[string]string{{"role": "user", "content": transcript}},
+	}, &resp)
+	if err != nil {
+		return "", err
+	}
+	var text strings.Builder
+	for _, block := range resp.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+	if text.Len() == 0 {
+		return "", fmt.Errorf("provider returned no summary")
+	}
+	return strings.TrimSpace(text.String()), nil
+}
+
+// summaryInstructions asks for the summary in the session language.
+func summaryInstructions() string {
+	lang := display.English.Languages().Name(language.Make(sessionLanguage()))
+	if lang == "" {
+		lang = "English"
+	}
+	return "You summarize WhatsApp conversations between a business and a customer for the support agent taking over the chat. " +
+		"Lines are prefixed with the time and the speaker; \"Business\" is our side. " +
+		"In a few short bullet points, state who the customer is, what they want, what has been answered or promised so far, " +
+		"and what is still open. Do not invent details that are not in the conversation. Write in " + lang + "."
+}
+
+// chatTranscript renders stored messages, oldest first, as one line each.
+func chatTranscript(messages []storedMessage, customer string) string {
+	var b strings.Builder
+	loc := sessionLocation()
+	for _, m := range messages {
+		speaker := customer
+		if m.FromMe {
+			speaker = "Business"
+		} else if m.Sender != "" && strings.HasSuffix(m.Chat, "@"+types.GroupServer) {
+			speaker = strings.SplitN(m.Sender, "@", 2)[0]
+		}
+		text := m.Text
+		if m.Transcript != "" {
+			text = "(voice note) " + m.Transcript
+		}
+		if text == "" {
+			text = "(" + m.Type + ")"
+		} else if m.Type != "text" && m.Transcript == "" {
+			text = "(" + m.Type + ") " + text
+		}
+		fmt.Fprintf(&b, "[%s] %s: %s\n", m.Timestamp.In(loc).Format("2006-01-02 15:04"), speaker, text)
+	}
+	return b.String()
+}
+
+// getChatSummary summarizes the chat's messages since ?since= (default the
+// last SUMMARY_WINDOW), up to ?limit= of the most recent ones.
+func getChatSummary(w http.ResponseWriter, r *http.Request) {
+	if activeSummarizer == nil {
+		http.Error(w, "Chat summaries are not configured", http.StatusNotImplemented)
+		return
+	}
+	if !messageStoreEnabled {
+		http.Error(w, "Chat summaries need the message store (MESSAGE_STORE)", http.StatusNotImplemented)
+		return
+	}
+	jid, ok := parseJID(r.PathValue("jid"))
+	if !ok {
+		http.Error(w, "Invalid JID", http.StatusBadRequest)
+		return
+	}
+	chat := canonicalJID(r.Context(), jid)
+	q := r.URL.Query()
+	since := time.Now().Add(-summaryWindow)
+	if v := q.Get("since"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(w, "Invalid since, expected RFC 3339", http.StatusBadRequest)
+			return
+		}
+		since = t
+	}
+	limit := summaryMaxMessages
+	if l := q.Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 || n > 1000 {
+			http.Error(w, "Invalid limit, expected 1-1000", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	messages, err := queryStoredMessages(r.Context(), `WHERE chat_jid = ? AND timestamp >= ? ORDER BY timestamp DESC LIMIT ?`,
+		chat.String(), since.Unix(), limit)
+	if err != nil {
+		waLogger.Errorf("Failed to load messages of %s: %v", chat, err)
+		http.Error(w, "Failed to load messages", http.StatusInternalServerError)
+		return
+	}
+	if len(messages) == 0 {
+		http.Error(w, "No stored messages in the window", http.StatusNotFound)
+		return
+	}
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+
+	customer := "Customer"
+	if c, err := getChat(r.Context(), chat); err == nil && c.Name != "" {
+		customer = c.Name
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), summaryTimeout)
+	defer cancel()
+	summary, err := activeSummarizer.Summarize(ctx, summaryInstructions(), chatTranscript(messages, customer))
+	if err != nil {
+		waLogger.Errorf("Failed to summarize %s: %v", chat, err)
+		http.Error(w, "Failed to generate summary", http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"jid":      chat.String(),
+		"summary":  summary,
+		"messages": len(messages),
+		"from":     messages[0].Timestamp,
+		"to":       messages[len(messages)-1].Timestamp,
+	})
+}
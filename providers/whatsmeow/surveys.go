@@ -0,0 +1,848 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"google.golang.org/protobuf/proto"
+)
+
+// Surveys ask a contact a series of questions one at a time: NPS (0-10),
+// multiple choice, or free text. In reply mode each question is a text
+// message answered with the option's number; in poll mode NPS and choice
+// questions are single-choice polls. A contact takes one survey at a time,
+// and a run nobody answers for SURVEY_TIMEOUT expires. Surveys are sent to
+// contacts or to a segment (as for POST /groups), optionally as a campaign,
+// which subjects them to the engagement minimum. Answers are kept like
+// stored messages; the survey.completed webhook carries them all, and
+// /analytics/surveys/{id} sums them up.
+
+var surveyTimeout = envDuration("SURVEY_TIMEOUT", 72*time.Hour)
+
+const (
+	maxSurveyQuestions  = 20
+	maxSurveyRecipients = 1000
+	npsHint             = "Reply with a number from 0 (not at all likely) to 10 (extremely likely)."
+	surveyRetryText     = "Sorry, I didn't get that. Please answer with one of the numbers above."
+)
+
+var errSurveyInProgress = errors.New("contact is already taking a survey")
+
+// surveyMu serializes answers, so a quick double reply can't skip a question.
+var surveyMu sync.Mutex
+
+type surveyQuestion struct {
+	Text    string   `json:"text"`
+	Type    string   `json:"type"`              // nps, choice or text
+	Options []string `json:"options,omitempty"` // choice only
+}
+
+type survey struct {
+	ID        int64            `json:"id"`
+	Name      string           `json:"name"`
+	Mode      string           `json:"mode"` // reply or poll
+	Questions []surveyQuestion `json:"questions"`
+	Intro     string           `json:"intro,omitempty"` // sent before the first question
+	Outro     string           `json:"outro,omitempty"` // sent after the last answer
+	CreatedAt time.Time        `json:"created_at"`
+}
+
+type surveyRun struct {
+	ID         int64          `json:"id"`
+	SurveyID   int64          `json:"survey_id"`
+	Chat       string         `json:"chat"`
+	Campaign   string         `json:"campaign,omitempty"`
+	Status     string         `json:"status"` // active, completed, expired or cancelled
+	Step       int            `json:"step"`
+	StartedAt  time.Time      `json:"started_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	FinishedAt *time.Time     `json:"finished_at,omitempty"`
+	Answers    []surveyAnswer `json:"answers,omitempty"`
+
+	pollID string
+}
+
+type surveyAnswer struct {
+	Question   int       `json:"question"`
+	Answer     string    `json:"answer"`
+	Score      *int      `json:"score,omitempty"` // NPS
+	AnsweredAt time.Time `json:"answered_at"`
+}
+
+func (s *survey) validate() error {
+	s.Name = strings.TrimSpace(s.Name)
+	if s.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if s.Mode == "" {
+		s.Mode = "reply"
+	}
+	if s.Mode != "reply" && s.Mode != "poll" {
+		return fmt.Errorf("mode must be reply or poll")
+	}
+	if len(s.Questions) == 0 || len(s.Questions) > maxSurveyQuestions {
+		return fmt.Errorf("a survey needs 1 to %d questions", maxSurveyQuestions)
+	}
+	for i := range s.Questions {
+		q := &s.Questions[i]
+		if q.Text = strings.TrimSpace(q.Text); q.Text == "" {
+			return fmt.Errorf("question %d has no text", i+1)
+		}
+		switch q.Type {
+		case "nps", "text":
+			q.Options = nil
+		case "choice":
+			// Polls carry the options, so they're limited like polls.
+			req := sendPollRequest{Question: q.Text, Options: q.Options}
+			if err := req.validate(); err != nil {
+				return fmt.Errorf("question %d: %w", i+1, err)
+			}
+			q.Options = req.Options
+		default:
+			return fmt.Errorf("question %d: type must be nps, choice or text", i+1)
+		}
+	}
+	return nil
+}
+
+// options are the answers a question offers, none for free text.
+func (q surveyQuestion) options() []string {
+	if q.Type == "nps" {
+		return []string{"0", "1", "2", "3", "4", "5", "6", "7", "8", "9", "10"}
+	}
+	return q.Options
+}
+
+// parseReply reads an answer to the question from a text reply: the number
+// of an option (or its text), a score for NPS, anything for free text.
+func (q surveyQuestion) parseReply(text string) (string, *int, bool) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return "", nil, false
+	}
+	switch q.Type {
+	case "text":
+		return text, nil, true
+	case "nps":
+		score, err := strconv.Atoi(strings.Trim(text, ".!"))
+		if err != nil || score < 0 || score > 10 {
+			return "", nil, false
+		}
+		return strconv.Itoa(score), &score, true
+	}
+	if n, err := strconv.Atoi(strings.Trim(text, ".)")); err == nil && n >= 1 && n <= len(q.Options) {
+		return q.Options[n-1], nil, true
+	}
+	for _, option := range q.Options {
+		if strings.EqualFold(option, text) {
+			return option, nil, true
+		}
+	}
+	return "", nil, false
+}
+
+// answerOption turns a poll choice into an answer.
+func (q surveyQuestion) answerOption(option string) (string, *int) {
+	if q.Type == "nps" {
+		score, _ := strconv.Atoi(option)
+		return option, &score
+	}
+	return option, nil
+}
+
+const surveyColumns = `id, name, mode, questions, intro, outro, created_at`
+
+func scanSurvey(scan func(dest ...interface{}) error) (survey, error) {
+	var s survey
+	var questions string
+	var created int64
+	if err := scan(&s.ID, &s.Name, &s.Mode, &questions, &s.Intro, &s.Outro, &created); err != nil {
+		return s, err
+	}
+	json.Unmarshal([]byte(questions), &s.Questions)
+	s.CreatedAt = time.Unix(created, 0).UTC()
+	return s, nil
+}
+
+func loadSurvey(ctx context.Context, id int64) (survey, error) {
+	return scanSurvey(gatewayDB.QueryRowContext(ctx, `SELECT `+surveyColumns+` FROM surveys WHERE id = ?`, id).Scan)
+}
+
+const surveyRunColumns = `id, survey_id, chat_jid, campaign, status, step, poll_id, started_at, updated_at, finished_at`
+
+func scanSurveyRun(scan func(dest ...interface{}) error) (surveyRun, error) {
+	var run surveyRun
+	var started, updated, finished int64
+	err := scan(&run.ID, &run.SurveyID, &run.Chat, &run.Campaign, &run.Status, &run.Step, &run.pollID,
+		&started, &updated, &finished)
+	if err != nil {
+		return run, err
+	}
+	run.StartedAt = time.Unix(started, 0).UTC()
+	run.UpdatedAt = time.Unix(updated, 0).UTC()
+	run.FinishedAt = unixPtr(finished)
+	return run, nil
+}
+
+// activeSurveyRun loads a chat's survey in progress, expiring it when it has
+// gone unanswered for too long.
+func activeSurveyRun(ctx context.Context, chat types.JID) (surveyRun, bool, error) {
+	run, err := scanSurveyRun(gatewayDB.QueryRowContext(ctx,
+		`SELECT `+surveyRunColumns+` FROM survey_runs WHERE chat_jid = ? AND status = 'active'`, chat.String()).Scan)
+	if err == sql.ErrNoRows {
+		return run, false, nil
+	} else if err != nil {
+		return run, false, err
+	}
+	if time.Since(run.UpdatedAt) > surveyTimeout {
+		if err := finishSurveyRun(ctx, run.ID, "expired"); err != nil {
+			return run, false, err
+		}
+		return run, false, nil
+	}
+	return run, true, nil
+}
+
+func finishSurveyRun(ctx context.Context, id int64, status string) error {
+	now := time.Now().Unix()
+	_, err := gatewayDB.ExecContext(ctx,
+		`UPDATE survey_runs SET status = ?, updated_at = ?, finished_at = ? WHERE id = ?`, status, now, now, id)
+	return err
+}
+
+func loadSurveyAnswers(ctx context.Context, runID int64) ([]surveyAnswer, error) {
+	rows, err := gatewayDB.QueryContext(ctx,
+		`SELECT question, answer, score, answered_at FROM survey_answers WHERE run_id = ? ORDER BY question`, runID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	answers := []surveyAnswer{}
+	for rows.Next() {
+		a, err := scanSurveyAnswer(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		answers = append(answers, a)
+	}
+	return answers, rows.Err()
+}
+
+func scanSurveyAnswer(scan func(dest ...interface{}) error) (surveyAnswer, error) {
+	var a surveyAnswer
+	var score sql.NullInt64
+	var answered int64
+	if err := scan(&a.Question, &a.Answer, &score, &answered); err != nil {
+		return a, err
+	}
+	if text, err := decryptStoreValue(a.Answer); err == nil {
+		a.Answer = text
+	}
+	if score.Valid {
+		n := int(score.Int64)
+		a.Score = &n
+	}
+	a.AnsweredAt = time.Unix(answered, 0).UTC()
+	return a, nil
+}
+
+// exportSurveyAnswers lists a data subject's survey answers, decrypted.
+func exportSurveyAnswers(ctx context.Context, where string, args []interface{}) ([]map[string]interface{}, error) {
+	rows, err := gatewayDB.QueryContext(ctx,
+		`SELECT run_id, question, answer, score, answered_at FROM survey_answers WHERE `+where+` ORDER BY run_id, question`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	answers := []map[string]interface{}{}
+	for rows.Next() {
+		var runID int64
+		a, err := scanSurveyAnswer(func(dest ...interface{}) error {
+			return rows.Scan(append([]interface{}{&runID}, dest...)...)
+		})
+		if err != nil {
+			return nil, err
+		}
+		answers = append(answers, map[string]interface{}{"run_id": runID, "answer": a})
+	}
+	return answers, rows.Err()
+}
+
+// sendSurveyQuestion sends a question and returns its poll's ID in poll mode.
+func sendSurveyQuestion(ctx context.Context, s survey, to types.JID, step int, campaign string) (string, error) {
+	q := s.Questions[step]
+	opts := sendOptions{AllowDuplicate: true, Campaign: campaign}
+	if s.Mode == "poll" && q.Type != "text" {
+		msg := client.BuildPollCreation(q.Text, q.options(), 1)
+		res, err := sendOrQueue(ctx, to, msg, opts)
+		if err != nil {
+			return "", err
+		}
+		recordPoll(ctx, res.ID, to, q.Text, q.options(), 1, time.Now())
+		return res.ID, nil
+	}
+	text := q.Text
+	switch q.Type {
+	case "nps":
+		text += "\n\n" + npsHint
+	case "choice":
+		text += "\n"
+		for i, option := range q.Options {
+			text += fmt.Sprintf("\n%d. %s", i+1, option)
+		}
+	}
+	_, err := sendOrQueue(ctx, to, &waE2E.Message{Conversation: proto.String(text)}, opts)
+	return "", err
+}
+
+func sendSurveyText(ctx context.Context, to types.JID, text, campaign string) error {
+	_, err := sendOrQueue(ctx, to, &waE2E.Message{Conversation: proto.String(text)},
+		sendOptions{AllowDuplicate: true, Campaign: campaign})
+	return err
+}
+
+// startSurvey starts a survey for a contact with the intro and first
+// question.
+func startSurvey(ctx context.Context, s survey, to types.JID, campaign string) (int64, error) {
+	surveyMu.Lock()
+	defer surveyMu.Unlock()
+	if _, active, err := activeSurveyRun(ctx, to); err != nil {
+		return 0, err
+	} else if active {
+		return 0, errSurveyInProgress
+	}
+	// Checked up front, so a contact below the minimum doesn't get the intro
+	// and nothing else.
+	if err := checkCampaignsEnabled(campaign); err != nil {
+		return 0, err
+	}
+	if err := checkCampaignEngagement(ctx, to, campaign); err != nil {
+		return 0, err
+	}
+	now := time.Now().Unix()
+	res, err := gatewayDB.ExecContext(ctx, `
+		INSERT INTO survey_runs (survey_id, chat_jid, campaign, started_at, updated_at) VALUES (?, ?, ?, ?, ?)`,
+		s.ID, to.String(), campaign, now, now)
+	if err != nil {
+		return 0, err
+	}
+	runID, _ := res.LastInsertId()
+	pollID := ""
+	if s.Intro != "" {
+		err = sendSurveyText(ctx, to, s.Intro, campaign)
+	}
+	if err == nil {
+		pollID, err = sendSurveyQuestion(ctx, s, to, 0, campaign)
+	}
+	if err != nil {
+		gatewayDB.ExecContext(ctx, `DELETE FROM survey_runs WHERE id = ?`, runID)
+		return 0, err
+	}
+	if _, err := gatewayDB.ExecContext(ctx, `UPDATE survey_runs SET poll_id = ? WHERE id = ?`, pollID, runID); err != nil {
+		waLogger.Errorf("Failed to record poll of survey run %d: %v", runID, err)
+	}
+	return runID, nil
+}
+
+// recordSurveyAnswer stores the answer to a run's current question and moves
+// on to the next one, or finishes the run after the last.
+func recordSurveyAnswer(ctx context.Context, s survey, run surveyRun, answer string, score *int) error {
+	to, err := types.ParseJID(run.Chat)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	_, err = gatewayDB.ExecContext(ctx, `
+		INSERT INTO survey_answers (run_id, question, chat_jid, answer, score, answered_at) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (run_id, question) DO UPDATE SET answer = excluded.answer, score = excluded.score, answered_at = excluded.answered_at`,
+		run.ID, run.Step, run.Chat, encryptStoreValue(answer), score, now.Unix())
+	if err != nil {
+		return err
+	}
+	next := run.Step + 1
+	if next < len(s.Questions) {
+		pollID, err := sendSurveyQuestion(ctx, s, to, next, run.Campaign)
+		if err != nil {
+			return fmt.Errorf("failed to send question %d: %w", next+1, err)
+		}
+		_, err = gatewayDB.ExecContext(ctx,
+			`UPDATE survey_runs SET step = ?, poll_id = ?, updated_at = ? WHERE id = ?`, next, pollID, now.Unix(), run.ID)
+		return err
+	}
+
+	if err := finishSurveyRun(ctx, run.ID, "completed"); err != nil {
+		return err
+	}
+	if s.Outro != "" {
+		if err := sendSurveyText(ctx, to, s.Outro, run.Campaign); err != nil {
+			waLogger.Errorf("Failed to send outro of survey %d to %s: %v", s.ID, to, err)
+		}
+	}
+	answers, err := loadSurveyAnswers(ctx, run.ID)
+	if err != nil {
+		return err
+	}
+	emitWebhook("survey.completed", map[string]interface{}{
+		"survey_id": s.ID,
+		"run_id":    run.ID,
+		"chat":      run.Chat,
+		"campaign":  run.Campaign,
+		"answers":   answers,
+	})
+	return nil
+}
+
+// answerSurvey takes a private message as the answer to the sender's survey
+// in progress, and reports whether it was one; an unreadable answer is
+// asked again. Polls are answered with votes, see answerSurveyPoll.
+func answerSurvey(ctx context.Context, chat types.JID, msg *waE2E.Message) (int64, bool) {
+	if gatewayDB == nil || chat.Server != types.DefaultUserServer {
+		return 0, false
+	}
+	surveyMu.Lock()
+	defer surveyMu.Unlock()
+	run, active, err := activeSurveyRun(ctx, chat)
+	if err != nil {
+		waLogger.Errorf("Failed to load survey of %s: %v", chat, err)
+		return 0, false
+	}
+	if !active {
+		return 0, false
+	}
+	s, err := loadSurvey(ctx, run.SurveyID)
+	if err != nil || run.Step >= len(s.Questions) {
+		waLogger.Errorf("Failed to load survey %d of run %d: %v", run.SurveyID, run.ID, err)
+		return 0, false
+	}
+	q := s.Questions[run.Step]
+	if run.pollID != "" {
+		return 0, false // waiting for a vote; the message is just a message
+	}
+	answer, score, ok := q.parseReply(messageText(msg))
+	if !ok {
+		if err := sendSurveyText(ctx, chat, surveyRetryText, run.Campaign); err != nil {
+			waLogger.Errorf("Failed to ask %s to answer the survey again: %v", chat, err)
+		}
+		return run.ID, true
+	}
+	if err := recordSurveyAnswer(ctx, s, run, answer, score); err != nil {
+		waLogger.Errorf("Failed to record survey answer of %s: %v", chat, err)
+	}
+	return run.ID, true
+}
+
+// answerSurveyPoll takes a vote on a survey question's poll as its answer.
+func answerSurveyPoll(ctx context.Context, pollID string, voter types.JID, selected []string) {
+	if len(selected) == 0 {
+		return
+	}
+	surveyMu.Lock()
+	defer surveyMu.Unlock()
+	run, err := scanSurveyRun(gatewayDB.QueryRowContext(ctx,
+		`SELECT `+surveyRunColumns+` FROM survey_runs WHERE poll_id = ? AND status = 'active'`, pollID).Scan)
+	if err == sql.ErrNoRows {
+		return
+	} else if err != nil {
+		waLogger.Errorf("Failed to load survey run of poll %s: %v", pollID, err)
+		return
+	}
+	if run.Chat != voter.String() {
+		return
+	}
+	s, err := loadSurvey(ctx, run.SurveyID)
+	if err != nil || run.Step >= len(s.Questions) {
+		waLogger.Errorf("Failed to load survey %d of run %d: %v", run.SurveyID, run.ID, err)
+		return
+	}
+	answer, score := s.Questions[run.Step].answerOption(selected[0])
+	if err := recordSurveyAnswer(ctx, s, run, answer, score); err != nil {
+		waLogger.Errorf("Failed to record survey answer of %s: %v", voter, err)
+	}
+}
+
+// --- API ---
+
+// surveyFromPath loads the survey named by the path, writing the error
+// response if there's none.
+func surveyFromPath(w http.ResponseWriter, r *http.Request) (survey, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid survey ID", http.StatusBadRequest)
+		return survey{}, false
+	}
+	s, err := loadSurvey(r.Context(), id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Survey not found", http.StatusNotFound)
+		return s, false
+	} else if err != nil {
+		waLogger.Errorf("Failed to load survey %d: %v", id, err)
+		http.Error(w, "Failed to load survey", http.StatusInternalServerError)
+		return s, false
+	}
+	return s, true
+}
+
+func listSurveys(w http.ResponseWriter, r *http.Request) {
+	rows, err := gatewayDB.QueryContext(r.Context(), `SELECT `+surveyColumns+` FROM surveys ORDER BY id`)
+	if err != nil {
+		waLogger.Errorf("Failed to list surveys: %v", err)
+		http.Error(w, "Failed to list surveys", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	surveys := []survey{}
+	for rows.Next() {
+		s, err := scanSurvey(rows.Scan)
+		if err != nil {
+			waLogger.Errorf("Failed to scan survey: %v", err)
+			http.Error(w, "Failed to list surveys", http.StatusInternalServerError)
+			return
+		}
+		surveys = append(surveys, s)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"surveys": surveys})
+}
+
+func createSurvey(w http.ResponseWriter, r *http.Request) {
+	var s survey
+	if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := s.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.CreatedAt = time.Now().UTC()
+	questions, _ := json.Marshal(s.Questions)
+	res, err := gatewayDB.ExecContext(r.Context(),
+		`INSERT INTO surveys (name, mode, questions, intro, outro, created_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		s.Name, s.Mode, string(questions), s.Intro, s.Outro, s.CreatedAt.Unix())
+	if err != nil {
+		waLogger.Errorf("Failed to create survey: %v", err)
+		http.Error(w, "Failed to create survey", http.StatusInternalServerError)
+		return
+	}
+	s.ID, _ = res.LastInsertId()
+	writeJSON(w, http.StatusCreated, s)
+}
+
+func getSurvey(w http.ResponseWriter, r *http.Request) {
+	if s, ok := surveyFromPath(w, r); ok {
+		writeJSON(w, http.StatusOK, s)
+	}
+}
+
+// deleteSurvey removes a survey; runs in progress are cancelled, answers
+// already given are kept.
+func deleteSurvey(w http.ResponseWriter, r *http.Request) {
+	s, ok := surveyFromPath(w, r)
+	if !ok {
+		return
+	}
+	now := time.Now().Unix()
+	if _, err := gatewayDB.ExecContext(r.Context(), `
+		UPDATE survey_runs SET status = 'cancelled', updated_at = ?, finished_at = ? WHERE survey_id = ? AND status = 'active'`,
+		now, now, s.ID); err != nil {
+		waLogger.Errorf("Failed to cancel runs of survey %d: %v", s.ID, err)
+		http.Error(w, "Failed to delete survey", http.StatusInternalServerError)
+		return
+	}
+	if _, err := gatewayDB.ExecContext(r.Context(), `DELETE FROM surveys WHERE id = ?`, s.ID); err != nil {
+		waLogger.Errorf("Failed to delete survey %d: %v", s.ID, err)
+		http.Error(w, "Failed to delete survey", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type sendSurveyRequest struct {
+	To       []string     `json:"to,omitempty"`
+	Segment  groupSegment `json:"segment"`
+	Campaign string       `json:"campaign,omitempty"`
+}
+
+type surveySendResult struct {
+	To     string `json:"to"`
+	Status string `json:"status"` // started, skipped or failed
+	RunID  int64  `json:"run_id,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// sendSurvey starts a survey for the given contacts and/or a segment.
+func sendSurvey(w http.ResponseWriter, r *http.Request) {
+	if sessionArchived() {
+		http.Error(w, "Session is archived", http.StatusConflict)
+		return
+	}
+	if !requireClient(w, r, true) {
+		return
+	}
+	s, ok := surveyFromPath(w, r)
+	if !ok {
+		return
+	}
+	var req sendSurveyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Segment.Tag != "" && !tagRe.MatchString(normalizeTag(req.Segment.Tag)) {
+		http.Error(w, fmt.Sprintf("invalid tag %q", req.Segment.Tag), http.StatusBadRequest)
+		return
+	}
+	var recipients []types.JID
+	if req.Segment.Tag != "" || len(req.Segment.Attributes) > 0 {
+		members, err := segmentMembers(r.Context(), req.Segment)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		recipients = members
+	}
+	for _, to := range req.To {
+		jid, err := parseRecipient(to)
+		if err != nil || jid.Server != types.DefaultUserServer {
+			http.Error(w, fmt.Sprintf("invalid recipient %q", to), http.StatusBadRequest)
+			return
+		}
+		recipients = append(recipients, toPhoneJID(r.Context(), jid))
+	}
+	if len(recipients) == 0 {
+		http.Error(w, "Set to, or a segment tag and/or attributes", http.StatusBadRequest)
+		return
+	}
+	if len(recipients) > maxSurveyRecipients {
+		http.Error(w, fmt.Sprintf("A survey goes to at most %d contacts at a time", maxSurveyRecipients), http.StatusBadRequest)
+		return
+	}
+	if err := checkCampaignsEnabled(req.Campaign); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	seen := map[types.JID]bool{}
+	results := []surveySendResult{}
+	summary := map[string]int{}
+	for _, to := range recipients {
+		if seen[to] {
+			continue
+		}
+		seen[to] = true
+		result := surveySendResult{To: to.String(), Status: "started"}
+		runID, err := startSurvey(r.Context(), s, to, req.Campaign)
+		switch {
+		case errors.Is(err, errSurveyInProgress), errors.Is(err, errLowEngagement),
+			errors.Is(err, errDestinationNotAllowed):
+			result.Status, result.Error = "skipped", err.Error()
+		case err != nil:
+			waLogger.Errorf("Failed to start survey %d for %s: %v", s.ID, to, err)
+			result.Status, result.Error = "failed", err.Error()
+		default:
+			result.RunID = runID
+		}
+		summary[result.Status]++
+		results = append(results, result)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"results": results, "summary": summary})
+}
+
+// listSurveyResponses lists a survey's runs with their answers, newest
+// first.
+func listSurveyResponses(w http.ResponseWriter, r *http.Request) {
+	s, ok := surveyFromPath(w, r)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	limit := 100
+	if l := q.Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 || n > 1000 {
+			http.Error(w, "Invalid limit, expected 1-1000", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	offset, _ := strconv.Atoi(q.Get("offset"))
+	query := `SELECT ` + surveyRunColumns + ` FROM survey_runs WHERE survey_id = ?`
+	args := []interface{}{s.ID}
+	if status := q.Get("status"); status != "" {
+		query += ` AND status = ?`
+		args = append(args, status)
+	}
+	rows, err := gatewayDB.QueryContext(r.Context(), query+` ORDER BY id DESC LIMIT ? OFFSET ?`,
+		append(args, limit, max(offset, 0))...)
+	if err != nil {
+		waLogger.Errorf("Failed to list responses of survey %d: %v", s.ID, err)
+		http.Error(w, "Failed to list responses", http.StatusInternalServerError)
+		return
+	}
+	runs := []surveyRun{}
+	for rows.Next() {
+		run, err := scanSurveyRun(rows.Scan)
+		if err != nil {
+			rows.Close()
+			waLogger.Errorf("Failed to scan survey run: %v", err)
+			http.Error(w, "Failed to list responses", http.StatusInternalServerError)
+			return
+		}
+		runs = append(runs, run)
+	}
+	rows.Close()
+	for i := range runs {
+		if runs[i].Answers, err = loadSurveyAnswers(r.Context(), runs[i].ID); err != nil {
+			waLogger.Errorf("Failed to load answers of survey run %d: %v", runs[i].ID, err)
+			http.Error(w, "Failed to list responses", http.StatusInternalServerError)
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"responses": runs})
+}
+
+type npsSummary struct {
+	Score      int     `json:"score"` // % promoters - % detractors, -100 to 100
+	Average    float64 `json:"average"`
+	Promoters  int     `json:"promoters"`  // 9-10
+	Passives   int     `json:"passives"`   // 7-8
+	Detractors int     `json:"detractors"` // 0-6
+}
+
+type surveyQuestionResults struct {
+	Question  int            `json:"question"`
+	Text      string         `json:"text"`
+	Type      string         `json:"type"`
+	Responses int            `json:"responses"`
+	Options   map[string]int `json:"options,omitempty"`
+	NPS       *npsSummary    `json:"nps,omitempty"`
+}
+
+// getSurveyAnalytics sums up a survey's answers, optionally for one
+// campaign.
+func getSurveyAnalytics(w http.ResponseWriter, r *http.Request) {
+	s, ok := surveyFromPath(w, r)
+	if !ok {
+		return
+	}
+	campaign := r.URL.Query().Get("campaign")
+	runFilter := `survey_id = ?`
+	args := []interface{}{s.ID}
+	if campaign != "" {
+		runFilter += ` AND campaign = ?`
+		args = append(args, campaign)
+	}
+	fail := func(err error) {
+		waLogger.Errorf("Failed to compute analytics of survey %d: %v", s.ID, err)
+		http.Error(w, "Failed to compute survey analytics", http.StatusInternalServerError)
+	}
+
+	runs := map[string]int{"active": 0, "completed": 0, "expired": 0, "cancelled": 0}
+	rows, err := gatewayDB.QueryContext(r.Context(),
+		`SELECT status, COUNT(*) FROM survey_runs WHERE `+runFilter+` GROUP BY status`, args...)
+	if err != nil {
+		fail(err)
+		return
+	}
+	started := 0
+	for rows.Next() {
+		var status string
+		var n int
+		if err := rows.Scan(&status, &n); err != nil {
+			rows.Close()
+			fail(err)
+			return
+		}
+		runs[status] = n
+		started += n
+	}
+	rows.Close()
+
+	results := make([]surveyQuestionResults, len(s.Questions))
+	scores := make([][]int, len(s.Questions))
+	for i, q := range s.Questions {
+		results[i] = surveyQuestionResults{Question: i, Text: q.Text, Type: q.Type}
+		if q.Type != "text" {
+			results[i].Options = map[string]int{}
+			for _, option := range q.options() {
+				results[i].Options[option] = 0
+			}
+		}
+	}
+	rows, err = gatewayDB.QueryContext(r.Context(), `
+		SELECT question, answer, score, answered_at FROM survey_answers
+		WHERE run_id IN (SELECT id FROM survey_runs WHERE `+runFilter+`)`, args...)
+	if err != nil {
+		fail(err)
+		return
+	}
+	for rows.Next() {
+		a, err := scanSurveyAnswer(rows.Scan)
+		if err != nil {
+			rows.Close()
+			fail(err)
+			return
+		}
+		if a.Question < 0 || a.Question >= len(results) {
+			continue
+		}
+		res := &results[a.Question]
+		res.Responses++
+		if res.Options != nil {
+			res.Options[a.Answer]++
+		}
+		if a.Score != nil {
+			scores[a.Question] = append(scores[a.Question], *a.Score)
+		}
+	}
+	rows.Close()
+	for i, q := range s.Questions {
+		if q.Type == "nps" && len(scores[i]) > 0 {
+			results[i].NPS = summarizeNPS(scores[i])
+		}
+	}
+
+	completionRate := 0.0
+	if started > 0 {
+		completionRate = math.Round(float64(runs["completed"])/float64(started)*1000) / 1000
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"survey_id":       s.ID,
+		"name":            s.Name,
+		"campaign":        campaign,
+		"started":         started,
+		"runs":            runs,
+		"completion_rate": completionRate,
+		"questions":       results,
+	})
+}
+
+func summarizeNPS(scores []int) *npsSummary {
+	var n npsSummary
+	sum := 0
+	for _, score := range scores {
+		sum += score
+		switch {
+		case score >= 9:
+			n.Promoters++
+		case score >= 7:
+			n.Passives++
+		default:
+			n.Detractors++
+		}
+	}
+	total := float64(len(scores))
+	n.Average = math.Round(float64(sum)/total*10) / 10
+	n.Score = int(math.Round(100 * float64(n.Promoters-n.Detractors) / total))
+	return &n
+}
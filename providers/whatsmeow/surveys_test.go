@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"google.golang.org/protobuf/proto"
+)
+
+func surveyTestMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /surveys", createSurvey)
+	mux.HandleFunc("GET /surveys/{id}", getSurvey)
+	mux.HandleFunc("DELETE /surveys/{id}", deleteSurvey)
+	mux.HandleFunc("GET /surveys/{id}/responses", listSurveyResponses)
+	mux.HandleFunc("GET /analytics/surveys/{id}", getSurveyAnalytics)
+	return mux
+}
+
+// startTestSurveyRun starts a run waiting for the answer to the first
+// question, as sendSurvey leaves it once the question has gone out.
+func startTestSurveyRun(t *testing.T, surveyID int64, chat types.JID, pollID string) {
+	t.Helper()
+	now := time.Now().Unix()
+	_, err := gatewayDB.Exec(`INSERT INTO survey_runs (survey_id, chat_jid, poll_id, started_at, updated_at) VALUES (?, ?, ?, ?, ?)`,
+		surveyID, chat.String(), pollID, now, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func createTestSurvey(t *testing.T, mux http.Handler, body string) survey {
+	t.Helper()
+	w := request(t, mux, "POST", "/surveys", body)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("POST /surveys: %d %s", w.Code, w.Body)
+	}
+	var s survey
+	json.Unmarshal(w.Body.Bytes(), &s)
+	return s
+}
+
+func TestSurveyAnsweredByReply(t *testing.T) {
+	openTestDB(t)
+	webhooks := captureWebhooks(t)
+	ctx := context.Background()
+	mux := surveyTestMux()
+
+	if w := request(t, mux, "POST", "/surveys", `{"name": "Pick", "questions": [{"text": "Which?", "type": "choice", "options": ["A"]}]}`); w.Code != http.StatusBadRequest {
+		t.Errorf("POST /surveys with a single option: %d, want 400", w.Code)
+	}
+	s := createTestSurvey(t, mux, `{"name": " NPS ", "questions": [{"text": "How likely are you to recommend us?", "type": "nps"}]}`)
+	if s.Name != "NPS" || s.Mode != "reply" {
+		t.Errorf("POST /surveys = %+v, want the name trimmed and reply mode", s)
+	}
+	path := "/surveys/" + strconv.FormatInt(s.ID, 10)
+
+	contacts := []types.JID{
+		types.NewJID("15550000001", types.DefaultUserServer),
+		types.NewJID("15550000002", types.DefaultUserServer),
+		types.NewJID("15550000003", types.DefaultUserServer),
+		types.NewJID("15550000004", types.DefaultUserServer),
+	}
+	for _, chat := range contacts {
+		startTestSurveyRun(t, s.ID, chat, "")
+	}
+	reply := func(chat types.JID, text string) bool {
+		_, answered := answerSurvey(ctx, chat, &waE2E.Message{Conversation: proto.String(text)})
+		return answered
+	}
+	for i, text := range []string{"10", " 7. ", "3"} {
+		if !reply(contacts[i], text) {
+			t.Fatalf("reply %q wasn't taken as an answer", text)
+		}
+		hook := nextWebhook(t, webhooks)
+		var completed struct {
+			Chat    string         `json:"chat"`
+			Answers []surveyAnswer `json:"answers"`
+		}
+		json.Unmarshal(hook.Data, &completed)
+		if hook.Event != "survey.completed" || completed.Chat != contacts[i].String() || len(completed.Answers) != 1 {
+			t.Errorf("webhook after reply %q = %s %s, want survey.completed with the answer", text, hook.Event, hook.Data)
+		}
+	}
+	// An answer that isn't a score is asked again and not recorded.
+	if !reply(contacts[3], "eleven") {
+		t.Errorf("an unreadable answer wasn't taken as one")
+	}
+	// Contacts without a survey just send messages.
+	if reply(types.NewJID("15550000009", types.DefaultUserServer), "10") {
+		t.Errorf("a message from a contact without a survey was taken as an answer")
+	}
+
+	w := request(t, mux, "GET", path+"/responses?status=completed", "")
+	var responses struct {
+		Responses []surveyRun `json:"responses"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &responses)
+	if len(responses.Responses) != 3 || responses.Responses[2].Answers[0].Answer != "10" || *responses.Responses[2].Answers[0].Score != 10 {
+		t.Errorf("GET %s/responses = %s, want the three completed runs", path, w.Body)
+	}
+
+	w = request(t, mux, "GET", "/analytics"+path, "")
+	var analytics struct {
+		Runs           map[string]int          `json:"runs"`
+		CompletionRate float64                 `json:"completion_rate"`
+		Questions      []surveyQuestionResults `json:"questions"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &analytics)
+	wantNPS := npsSummary{Score: 0, Average: 6.7, Promoters: 1, Passives: 1, Detractors: 1}
+	if analytics.Runs["completed"] != 3 || analytics.Runs["active"] != 1 || analytics.CompletionRate != 0.75 ||
+		len(analytics.Questions) != 1 || analytics.Questions[0].NPS == nil || *analytics.Questions[0].NPS != wantNPS {
+		t.Errorf("GET /analytics%s = %s, want 3 of 4 runs completed with NPS %+v", path, w.Body, wantNPS)
+	}
+
+	// Deleting the survey cancels the run still waiting for an answer.
+	if w := request(t, mux, "DELETE", path, ""); w.Code != http.StatusNoContent {
+		t.Fatalf("DELETE %s: %d %s", path, w.Code, w.Body)
+	}
+	if reply(contacts[3], "9") {
+		t.Errorf("an answer to a deleted survey was taken")
+	}
+	if w := request(t, mux, "GET", path, ""); w.Code != http.StatusNotFound {
+		t.Errorf("GET %s after the delete: %d, want 404", path, w.Code)
+	}
+}
+
+func TestSurveyAnsweredByPoll(t *testing.T) {
+	openTestDB(t)
+	webhooks := captureWebhooks(t)
+	ctx := context.Background()
+	mux := surveyTestMux()
+	s := createTestSurvey(t, mux, `{"name": "NPS", "mode": "poll", "questions": [{"text": "How likely are you to recommend us?", "type": "nps"}]}`)
+	chat := types.NewJID("15550000001", types.DefaultUserServer)
+	startTestSurveyRun(t, s.ID, chat, "3EB0POLL")
+
+	// A run waiting for a vote leaves text messages alone.
+	if _, answered := answerSurvey(ctx, chat, &waE2E.Message{Conversation: proto.String("8")}); answered {
+		t.Errorf("a text message was taken as the answer to a poll")
+	}
+	// Only the contact's own vote counts.
+	answerSurveyPoll(ctx, "3EB0POLL", types.NewJID("15550000002", types.DefaultUserServer), []string{"0"})
+	answerSurveyPoll(ctx, "3EB0POLL", chat, []string{"8"})
+
+	hook := nextWebhook(t, webhooks)
+	var completed struct {
+		Answers []surveyAnswer `json:"answers"`
+	}
+	json.Unmarshal(hook.Data, &completed)
+	if hook.Event != "survey.completed" || len(completed.Answers) != 1 || completed.Answers[0].Answer != "8" ||
+		completed.Answers[0].Score == nil || *completed.Answers[0].Score != 8 {
+		t.Errorf("webhook = %s %s, want survey.completed with the vote for 8", hook.Event, hook.Data)
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// Chat tags are free-form gateway-side labels for lightweight CRM-style
+// organization. They are independent of WhatsApp Business labels and never
+// leave the gateway. Tags are lowercased with spaces turned into dashes.
+
+var tagRe = regexp.MustCompile(`^[\p{Ll}\p{N}][\p{Ll}\p{N}_:./-]{0,49}$`)
+
+func normalizeTag(tag string) string {
+	return strings.Join(strings.Fields(strings.ToLower(tag)), "-")
+}
+
+func splitTags(joined string) []string {
+	tags := []string{}
+	if joined != "" {
+		tags = strings.Split(joined, ",")
+		sort.Strings(tags)
+	}
+	return tags
+}
+
+func chatTags(ctx context.Context, chat types.JID) ([]string, error) {
+	var joined *string
+	err := gatewayDB.QueryRowContext(ctx,
+		`SELECT group_concat(tag, ',') FROM chat_tags WHERE chat_jid = ?`, chat.String()).Scan(&joined)
+	if err != nil || joined == nil {
+		return []string{}, err
+	}
+	return splitTags(*joined), nil
+}
+
+type chatTagsRequest struct {
+	Tags []string `json:"tags"`
+}
+
+func parseChatTagsRequest(r *http.Request) ([]string, error) {
+	var req chatTagsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, fmt.Errorf("invalid request body")
+	}
+	tags := make([]string, 0, len(req.Tags))
+	for _, tag := range req.Tags {
+		tag = normalizeTag(tag)
+		if !tagRe.MatchString(tag) {
+			return nil, fmt.Errorf("invalid tag %q, expected up to 50 letters, digits and _ : . / -", tag)
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+func getChatTags(w http.ResponseWriter, r *http.Request) {
+	jid, ok := parseJID(r.PathValue("jid"))
+	if !ok {
+		http.Error(w, "Invalid JID", http.StatusBadRequest)
+		return
+	}
+	chat := canonicalJID(r.Context(), jid)
+	tags, err := chatTags(r.Context(), chat)
+	if err != nil {
+		waLogger.Errorf("Failed to load tags of %s: %v", chat, err)
+		http.Error(w, "Failed to load tags", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"jid": chat.String(), "tags": tags})
+}
+
+// updateChatTags adds the given tags (POST) or replaces the chat's tags with
+// them (PUT).
+func updateChatTags(w http.ResponseWriter, r *http.Request) {
+	jid, ok := parseJID(r.PathValue("jid"))
+	if !ok {
+		http.Error(w, "Invalid JID", http.StatusBadRequest)
+		return
+	}
+	chat := canonicalJID(r.Context(), jid)
+	tags, err := parseChatTagsRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	tx, err := gatewayDB.BeginTx(r.Context(), nil)
+	if err != nil {
+		waLogger.Errorf("Failed to update tags of %s: %v", chat, err)
+		http.Error(w, "Failed to update tags", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	if r.Method == http.MethodPut {
+		if _, err := tx.ExecContext(r.Context(), `DELETE FROM chat_tags WHERE chat_jid = ?`, chat.String()); err != nil {
+			waLogger.Errorf("Failed to clear tags of %s: %v", chat, err)
+			http.Error(w, "Failed to update tags", http.StatusInternalServerError)
+			return
+		}
+	}
+	now := time.Now().Unix()
+	for _, tag := range tags {
+		_, err := tx.ExecContext(r.Context(),
+			`INSERT INTO chat_tags (chat_jid, tag, created_at) VALUES (?, ?, ?) ON CONFLICT (chat_jid, tag) DO NOTHING`,
+			chat.String(), tag, now)
+		if err != nil {
+			waLogger.Errorf("Failed to tag %s: %v", chat, err)
+			http.Error(w, "Failed to update tags", http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		waLogger.Errorf("Failed to update tags of %s: %v", chat, err)
+		http.Error(w, "Failed to update tags", http.StatusInternalServerError)
+		return
+	}
+	getChatTags(w, r)
+}
+
+func deleteChatTag(w http.ResponseWriter, r *http.Request) {
+	jid, ok := parseJID(r.PathValue("jid"))
+	if !ok {
+		http.Error(w, "Invalid JID", http.StatusBadRequest)
+		return
+	}
+	chat := canonicalJID(r.Context(), jid)
+	tag := normalizeTag(r.PathValue("tag"))
+	res, err := gatewayDB.ExecContext(r.Context(), `DELETE FROM chat_tags WHERE chat_jid = ? AND tag = ?`, chat.String(), tag)
+	if err != nil {
+		waLogger.Errorf("Failed to untag %s: %v", chat, err)
+		http.Error(w, "Failed to update tags", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		http.Error(w, "Tag not found", http.StatusNotFound)
+		return
+	}
+	getChatTags(w, r)
+}
+
+// listTags returns every tag in use with the number of chats carrying it.
+func listTags(w http.ResponseWriter, r *http.Request) {
+	rows, err := gatewayDB.QueryContext(r.Context(), `SELECT tag, COUNT(*) FROM chat_tags GROUP BY tag ORDER BY tag`)
+	if err != nil {
+		waLogger.Errorf("Failed to list tags: %v", err)
+		http.Error(w, "Failed to list tags", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	type tagCount struct {
+		Tag   string `json:"tag"`
+		Chats int    `json:"chats"`
+	}
+	tags := []tagCount{}
+	for rows.Next() {
+		var t tagCount
+		if err := rows.Scan(&t.Tag, &t.Chats); err != nil {
+			waLogger.Errorf("Failed to scan tag: %v", err)
+			http.Error(w, "Failed to list tags", http.StatusInternalServerError)
+			return
+		}
+		tags = append(tags, t)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"tags": tags})
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// Message text may reference the recipient's gateway contact record with
+// {{contact.<field>}}, optionally with a fallback: {{contact.first_name|there}}.
+// Fields are name, first_name, last_name, phone and every custom attribute;
+// attributes of the same name take precedence over the derived fields.
+// Placeholders are resolved on every send, so schedules pick up later
+// changes to the contact.
+
+var contactPlaceholderRe = regexp.MustCompile(`\{\{\s*contact\.([a-z][a-z0-9_]*)\s*(?:\|([^}]*))?\}\}`)
+
+var errTemplateValue = errors.New("template references a contact field with no value")
+
+// contactTemplateValues collects the fields a template can reference. Without
+// a gateway contact the name falls back to the WhatsApp contact name.
+func contactTemplateValues(ctx context.Context, to types.JID) (map[string]string, error) {
+	values := map[string]string{"phone": to.User}
+	contact, err := getGatewayContact(ctx, to)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to load contact: %w", err)
+	}
+	name := contact.Name
+	if name == "" {
+		name = resolveContact(ctx, to, "", nil).DisplayName
+	}
+	if name != "" {
+		values["name"] = name
+		parts := strings.Fields(name)
+		values["first_name"] = parts[0]
+		if len(parts) > 1 {
+			values["last_name"] = parts[len(parts)-1]
+		}
+	}
+	for key, value := range contact.Attributes {
+		switch v := value.(type) {
+		case string:
+			values[key] = v
+		case float64:
+			values[key] = strconv.FormatFloat(v, 'f', -1, 64)
+		case bool:
+			values[key] = strconv.FormatBool(v)
+		}
+	}
+	return values, nil
+}
+
+// renderContactTemplate fills in contact placeholders. A field with neither
+// a value nor a fallback is an error rather than a blank in the message.
+func renderContactTemplate(ctx context.Context, to types.JID, text string) (string, error) {
+	if !contactPlaceholderRe.MatchString(text) {
+		return text, nil
+	}
+	values, err := contactTemplateValues(ctx, to)
+	if err != nil {
+		return "", err
+	}
+	var missing []string
+	out := contactPlaceholderRe.ReplaceAllStringFunc(text, func(placeholder string) string {
+		m := contactPlaceholderRe.FindStringSubmatch(placeholder)
+		if v := values[m[1]]; v != "" {
+			return v
+		}
+		if strings.Contains(placeholder, "|") {
+			return strings.TrimSpace(m[2])
+		}
+		missing = append(missing, m[1])
+		return placeholder
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("%w: %s", errTemplateValue, strings.Join(missing, ", "))
+	}
+	return out, nil
+}
+
+// renderMessageTemplate renders the text or caption of an outgoing message.
+func renderMessageTemplate(ctx context.Context, to types.JID, msg *waE2E.Message) error {
+	text := messageText(msg)
+	rendered, err := renderContactTemplate(ctx, to, text)
+	if err != nil {
+		return err
+	}
+	if rendered != text {
+		setMessageText(msg, rendered)
+	}
+	return nil
+}
+
+type renderTemplateRequest struct {
+	To   string `json:"to"`
+	Text string `json:"text"`
+}
+
+// previewTemplate renders a template for a recipient without sending it.
+func previewTemplate(w http.ResponseWriter, r *http.Request) {
+	var req renderTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.To == "" {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	to, err := parseRecipient(req.To)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	text, err := renderContactTemplate(r.Context(), toPhoneJID(r.Context(), to), req.Text)
+	if errors.Is(err, errTemplateValue) {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	} else if err != nil {
+		waLogger.Errorf("Failed to render template for %s: %v", to, err)
+		http.Error(w, "Failed to render template", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"text": text})
+}
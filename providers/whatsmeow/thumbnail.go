@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image/jpeg"
+	"os/exec"
+	"strings"
+)
+
+// Media sent without a thumbnail gets a generated one, so recipients see a
+// preview instead of a grey box while the file downloads: the most
+// representative of the first frames of a video, a scaled-down copy of an
+// image sent as a document, and the first page of a PDF (rendered with
+// pdftoppm, from poppler). Failing to make one only means going without.
+
+// mediaThumbnailSize is the longest side of generated thumbnails, about what
+// WhatsApp's own clients send inline.
+const mediaThumbnailSize = 128
+
+var pdftoppmPath = envString("PDFTOPPM_PATH", "pdftoppm")
+
+// scaleToJPEG has ffmpeg turn an image, or a frame of a video picked by
+// filter, into a JPEG of at most size pixels a side, and returns it with its
+// dimensions.
+func scaleToJPEG(ctx context.Context, data []byte, filter string, size int) ([]byte, int, int, error) {
+	thumb, err := runFFmpeg(ctx, data, scaleToJPEGArgs(filter, size)...)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	return jpegWithSize(thumb)
+}
+
+// scaleFileToJPEG is scaleToJPEG for a file on disk.
+func scaleFileToJPEG(ctx context.Context, path string, filter string, size int) ([]byte, int, int, error) {
+	thumb, err := runFFmpegFile(ctx, path, scaleToJPEGArgs(filter, size)...)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	return jpegWithSize(thumb)
+}
+
+func scaleToJPEGArgs(filter string, size int) []string {
+	vf := fmt.Sprintf("scale=%[1]d:%[1]d:force_original_aspect_ratio=decrease", size)
+	if filter != "" {
+		vf = filter + "," + vf
+	}
+	return []string{"-vf", vf, "-frames:v", "1", "-c:v", "mjpeg", "-q:v", "5", "-f", "image2"}
+}
+
+func jpegWithSize(thumb []byte) ([]byte, int, int, error) {
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(thumb))
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	return thumb, cfg.Width, cfg.Height, nil
+}
+
+// videoThumbnail picks a frame from the start of a video, skipping the black
+// or blurred first frames that fades begin with.
+func videoThumbnail(ctx context.Context, path string) ([]byte, error) {
+	thumb, _, _, err := scaleFileToJPEG(ctx, path, "thumbnail", mediaThumbnailSize)
+	return thumb, err
+}
+
+// pdfThumbnail renders the first page of a PDF.
+func pdfThumbnail(ctx context.Context, path string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, ffmpegTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, pdftoppmPath, "-jpeg", "-f", "1", "-l", "1", "-singlefile",
+		"-scale-to", fmt.Sprint(mediaThumbnailSize), path)
+	var out, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &out, &stderr
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && ctx.Err() == nil {
+			return nil, fmt.Errorf("%w: %s", errUnsupportedMedia, strings.TrimSpace(stderr.String()))
+		}
+		return nil, fmt.Errorf("pdftoppm failed: %w", err)
+	}
+	return out.Bytes(), nil
+}
+
+// documentThumbnail makes a thumbnail for a document of the given MIME type
+// and returns it with its dimensions, or nil if the type has no preview.
+func documentThumbnail(ctx context.Context, path string, mimetype string) ([]byte, int, int, error) {
+	switch {
+	case mimetype == "application/pdf":
+		thumb, err := pdfThumbnail(ctx, path)
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		return jpegWithSize(thumb)
+	case strings.HasPrefix(mimetype, "image/"):
+		return scaleFileToJPEG(ctx, path, "", mediaThumbnailSize)
+	case strings.HasPrefix(mimetype, "video/"):
+		return scaleFileToJPEG(ctx, path, "thumbnail", mediaThumbnailSize)
+	}
+	return nil, 0, 0, nil
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// /contacts/{jid}/timeline merges what the gateway knows about one contact
+// (messages with them or from them in groups, their receipts, presence
+// changes, group membership events and notes on their chat) into a single
+// feed for agent context views. Each source only has entries when it's
+// recorded: messages and receipts with MESSAGE_STORE, presence with
+// PRESENCE_HISTORY.
+
+var timelineTypes = []string{"message", "receipt", "presence", "group", "note"}
+
+type timelineEntry struct {
+	Type      string      `json:"type"` // one of timelineTypes
+	Timestamp time.Time   `json:"timestamp"`
+	Data      interface{} `json:"data"`
+}
+
+type timelineReceipt struct {
+	Chat      string `json:"chat"`
+	MessageID string `json:"message_id"`
+	Type      string `json:"type"`
+}
+
+type timelinePresence struct {
+	Online   bool       `json:"online"`
+	LastSeen *time.Time `json:"last_seen,omitempty"`
+}
+
+// timelineSource loads up to limit of the newest entries of one kind in
+// [since, until).
+type timelineSource func(ctx context.Context, subject dataSubject, since, until int64, limit int) ([]timelineEntry, error)
+
+var timelineSources = map[string]timelineSource{
+	"message": func(ctx context.Context, subject dataSubject, since, until int64, limit int) ([]timelineEntry, error) {
+		where, args := subject.condition(`(chat_jid IN (%s) OR sender_jid IN (%s))`)
+		messages, err := queryStoredMessages(ctx, `WHERE `+where+` AND timestamp >= ? AND timestamp < ? ORDER BY timestamp DESC LIMIT ?`,
+			append(args, since, until, limit)...)
+		if err != nil {
+			return nil, err
+		}
+		entries := make([]timelineEntry, len(messages))
+		for i, m := range messages {
+			entries[i] = timelineEntry{Type: "message", Timestamp: m.Timestamp, Data: m}
+		}
+		return entries, nil
+	},
+	"receipt": func(ctx context.Context, subject dataSubject, since, until int64, limit int) ([]timelineEntry, error) {
+		where, args := subject.condition(`sender_jid IN (%s)`)
+		return queryTimeline(ctx, "receipt",
+			`SELECT chat_jid, message_id, type, timestamp FROM message_receipts WHERE `+where+
+				` AND timestamp >= ? AND timestamp < ? ORDER BY timestamp DESC LIMIT ?`,
+			append(args, since, until, limit),
+			func(scan func(dest ...interface{}) error) (interface{}, int64, error) {
+				var rc timelineReceipt
+				var ts int64
+				err := scan(&rc.Chat, &rc.MessageID, &rc.Type, &ts)
+				return rc, ts, err
+			})
+	},
+	"presence": func(ctx context.Context, subject dataSubject, since, until int64, limit int) ([]timelineEntry, error) {
+		where, args := subject.condition(`contact IN (%s)`)
+		return queryTimeline(ctx, "presence",
+			`SELECT online, last_seen, timestamp FROM presence_events WHERE `+where+
+				` AND timestamp >= ? AND timestamp < ? ORDER BY timestamp DESC, id DESC LIMIT ?`,
+			append(args, since, until, limit),
+			func(scan func(dest ...interface{}) error) (interface{}, int64, error) {
+				var p timelinePresence
+				var lastSeen, ts int64
+				err := scan(&p.Online, &lastSeen, &ts)
+				p.LastSeen = unixPtr(lastSeen)
+				return p, ts, err
+			})
+	},
+	"group": func(ctx context.Context, subject dataSubject, since, until int64, limit int) ([]timelineEntry, error) {
+		where, args := subject.condition(`participant IN (%s)`)
+		return queryTimeline(ctx, "group",
+			`SELECT id, group_jid, participant, action, actor, reason, timestamp FROM group_participant_events WHERE `+where+
+				` AND timestamp >= ? AND timestamp < ? ORDER BY timestamp DESC, id DESC LIMIT ?`,
+			append(args, since, until, limit),
+			func(scan func(dest ...interface{}) error) (interface{}, int64, error) {
+				var e groupAuditEntry
+				var ts int64
+				err := scan(&e.ID, &e.Group, &e.Participant, &e.Action, &e.Actor, &e.Reason, &ts)
+				e.Timestamp = time.Unix(ts, 0).UTC()
+				return e, ts, err
+			})
+	},
+	"note": func(ctx context.Context, subject dataSubject, since, until int64, limit int) ([]timelineEntry, error) {
+		where, args := subject.condition(`chat_jid IN (%s)`)
+		notes, err := queryNotes(ctx, `WHERE `+where+` AND created_at >= ? AND created_at < ? ORDER BY created_at DESC, id DESC LIMIT ?`,
+			append(args, since, until, limit)...)
+		if err != nil {
+			return nil, err
+		}
+		entries := make([]timelineEntry, len(notes))
+		for i, n := range notes {
+			entries[i] = timelineEntry{Type: "note", Timestamp: n.CreatedAt, Data: n}
+		}
+		return entries, nil
+	},
+}
+
+func queryTimeline(ctx context.Context, kind, query string, args []interface{},
+	scan func(scan func(dest ...interface{}) error) (interface{}, int64, error)) ([]timelineEntry, error) {
+	rows, err := gatewayDB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var entries []timelineEntry
+	for rows.Next() {
+		data, ts, err := scan(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, timelineEntry{Type: kind, Timestamp: time.Unix(ts, 0).UTC(), Data: data})
+	}
+	return entries, rows.Err()
+}
+
+// getContactTimeline returns the newest entries up to ?until=, oldest first;
+// has_more says whether there are earlier ones, to be fetched with the first
+// entry's timestamp as the next until.
+func getContactTimeline(w http.ResponseWriter, r *http.Request) {
+	jid, ok := parseJID(r.PathValue("jid"))
+	if !ok || (jid.Server != types.DefaultUserServer && jid.Server != types.HiddenUserServer) {
+		http.Error(w, "Invalid contact JID", http.StatusBadRequest)
+		return
+	}
+	pn := canonicalJID(r.Context(), jid)
+	subject := dataSubject{phone: pn.User, jids: []string{pn.String()}}
+	if jid.ToNonAD() != pn {
+		subject.jids = append(subject.jids, jid.ToNonAD().String())
+	} else if lid, ok := lookupLID(r.Context(), pn); ok {
+		subject.jids = append(subject.jids, lid.String())
+	}
+
+	q := r.URL.Query()
+	since, until := int64(0), time.Now().Add(time.Second).Unix()
+	for _, bound := range []struct {
+		param string
+		dest  *int64
+	}{{"since", &since}, {"until", &until}} {
+		v := q.Get(bound.param)
+		if v == "" {
+			continue
+		}
+		ts, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(w, "Invalid "+bound.param+" timestamp, expected RFC 3339", http.StatusBadRequest)
+			return
+		}
+		*bound.dest = ts.Unix()
+	}
+	limit := 100
+	if l := q.Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 || n > 1000 {
+			http.Error(w, "Invalid limit, expected 1-1000", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	kinds := timelineTypes
+	if t := q.Get("types"); t != "" {
+		kinds = strings.Split(t, ",")
+		for _, kind := range kinds {
+			if !slices.Contains(timelineTypes, kind) {
+				http.Error(w, "Invalid type "+strconv.Quote(kind)+", expected "+strings.Join(timelineTypes, ", "), http.StatusBadRequest)
+				return
+			}
+		}
+	}
+
+	entries := []timelineEntry{}
+	for _, kind := range kinds {
+		// One more than asked for tells whether there is more.
+		found, err := timelineSources[kind](r.Context(), subject, since, until, limit+1)
+		if err != nil {
+			waLogger.Errorf("Failed to load %s timeline of %s: %v", kind, pn, err)
+			http.Error(w, "Failed to load timeline", http.StatusInternalServerError)
+			return
+		}
+		entries = append(entries, found...)
+	}
+	slices.SortStableFunc(entries, func(a, b timelineEntry) int {
+		return b.Timestamp.Compare(a.Timestamp)
+	})
+	hasMore := len(entries) > limit
+	if hasMore {
+		entries = entries[:limit]
+	}
+	slices.Reverse(entries)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"jid":      pn.String(),
+		"entries":  entries,
+		"has_more": hasMore,
+	})
+}
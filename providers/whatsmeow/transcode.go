@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Media in codecs WhatsApp clients can't play (HEVC or AV1 video, AMR audio
+// in 3GP files, videos in containers other than MP4) can go through a
+// pluggable transcoding stage (TRANSCODE_PROVIDER) before sending: the
+// bundled ffmpeg, or a hook that gets the file and returns it converted, for
+// deployments that transcode elsewhere. With TRANSCODE_INBOUND_AUDIO=mp3,
+// incoming audio that's downloaded on arrival is also kept (and offloaded)
+// as MP3, for webhook consumers that can't play Opus or AMR. Voice note
+// sends are converted to Opus regardless, see audio.go.
+
+var transcodeInboundAudio = strings.ToLower(envString("TRANSCODE_INBOUND_AUDIO", ""))
+
+const mp3Mimetype = "audio/mpeg"
+
+// playableMP4Codecs are the MP4 sample entries WhatsApp clients play: H.264
+// video and AAC audio.
+var playableMP4Codecs = []string{"avc1", "avc3", "mp4a"}
+
+type transcoder interface {
+	// Transcode converts in, of the given MIME type, to target (video/mp4
+	// or audio/mpeg) in a new spool file of at most maxBytes.
+	Transcode(ctx context.Context, in *spooledFile, mimetype, target string, maxBytes int64) (*spooledFile, error)
+}
+
+// activeTranscoder is nil when no provider is configured.
+var activeTranscoder transcoder
+
+func newTranscoder(provider string) transcoder {
+	switch strings.ToLower(provider) {
+	case "":
+		return nil
+	case "ffmpeg":
+		return ffmpegTranscoder{}
+	case "hook":
+		hook := envString("TRANSCODE_HOOK_URL", "")
+		if hook == "" {
+			waLogger.Errorf("TRANSCODE_PROVIDER=hook needs TRANSCODE_HOOK_URL, transcoding disabled")
+			return nil
+		}
+		return &hookTranscoder{
+			url:     hook,
+			secret:  envString("TRANSCODE_HOOK_SECRET", ""),
+			timeout: envDuration("TRANSCODE_HOOK_TIMEOUT", 5*time.Minute),
+		}
+	default:
+		waLogger.Errorf("Unknown TRANSCODE_PROVIDER %q, transcoding disabled", provider)
+		return nil
+	}
+}
+
+type ffmpegTranscoder struct{}
+
+func (ffmpegTranscoder) Transcode(ctx context.Context, in *spooledFile, mimetype, target string, maxBytes int64) (*spooledFile, error) {
+	var args []string
+	switch target {
+	case "video/mp4":
+		// H.264 in 4:2:0 needs even dimensions; +faststart puts the index
+		// first, so the video plays before it's fully downloaded.
+		args = []string{
+			"-map", "0:v:0", "-map", "0:a:0?", "-map_metadata", "-1",
+			"-vf", "scale=trunc(iw/2)*2:trunc(ih/2)*2,format=yuv420p",
+			"-c:v", "libx264", "-profile:v", "main", "-preset", "veryfast", "-crf", "23",
+			"-c:a", "aac", "-b:a", "128k", "-movflags", "+faststart", "-f", "mp4",
+		}
+	case mp3Mimetype:
+		args = []string{"-vn", "-map_metadata", "-1", "-c:a", "libmp3lame", "-q:a", "4", "-f", "mp3"}
+	default:
+		return nil, fmt.Errorf("can't transcode to %s", target)
+	}
+	f, err := createSpoolFile("transcode-*")
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+	if err := runFFmpegToFile(ctx, in.Path, f.Name(), args...); err != nil {
+		os.Remove(f.Name())
+		return nil, err
+	}
+	out, err := describeSpoolFile(f.Name())
+	if err != nil {
+		os.Remove(f.Name())
+		return nil, err
+	}
+	if out.Size > maxBytes {
+		out.Remove()
+		return nil, mediaTooLarge(fmt.Sprintf("transcoded file is too large: %d bytes, at most %d allowed", out.Size, maxBytes), out.Size, maxBytes)
+	}
+	return out, nil
+}
+
+// hookTranscoder posts the file to TRANSCODE_HOOK_URL with the target type
+// in ?target= and takes the response body as the converted file. The hook
+// answers 415 or 422 for files it can't convert.
+type hookTranscoder struct {
+	url     string
+	secret  string
+	timeout time.Duration
+}
+
+func (t *hookTranscoder) Transcode(ctx context.Context, in *spooledFile, mimetype, target string, maxBytes int64) (*spooledFile, error) {
+	u, err := url.Parse(t.url)
+	if err != nil {
+		return nil, err
+	}
+	q := u.Query()
+	q.Set("target", target)
+	u.RawQuery = q.Encode()
+	body, err := os.Open(in.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "POST", u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = in.Size
+	req.Header.Set("Content-Type", mimetype)
+	if t.secret != "" {
+		req.Header.Set("Authorization", "Bearer "+t.secret)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusUnsupportedMediaType || resp.StatusCode == http.StatusUnprocessableEntity {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("%w: %s", errUnsupportedMedia, strings.TrimSpace(string(msg)))
+	} else if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("hook returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return spoolReader(resp.Body, maxBytes, "transcoded file")
+}
+
+// transcode converts in to target with the active transcoder and checks the
+// result is what was asked for.
+func transcode(ctx context.Context, in *spooledFile, mimetype, target string, maxBytes int64) (*spooledFile, error) {
+	out, err := activeTranscoder.Transcode(ctx, in, mimetype, target, maxBytes)
+	if err != nil {
+		return nil, err
+	}
+	ok := false
+	switch target {
+	case "video/mp4":
+		ok = isMP4(out.Head) && mp4Playable(parseMP4(out.Path))
+	case mp3Mimetype:
+		ok = isMP3(out.Head)
+	}
+	if !ok {
+		out.Remove()
+		return nil, fmt.Errorf("transcoder returned no %s", target)
+	}
+	return out, nil
+}
+
+// mp4Playable reports whether WhatsApp clients play all tracks of an MP4.
+func mp4Playable(info mp4Info) bool {
+	for _, codec := range info.Codecs {
+		if !slices.Contains(playableMP4Codecs, codec) {
+			return false
+		}
+	}
+	return true
+}
+
+// videoNeedsTranscoding reports whether a video has to be transcoded before
+// sending: it isn't an MP4, or has a track clients can't play.
+func videoNeedsTranscoding(file *spooledFile) bool {
+	return !isMP4(file.Head) || !mp4Playable(parseMP4(file.Path))
+}
+
+// isMP3 checks for an ID3 tag or an MPEG audio frame sync.
+func isMP3(data []byte) bool {
+	return bytes.HasPrefix(data, []byte("ID3")) || (len(data) >= 2 && data[0] == 0xff && data[1]&0xe0 == 0xe0)
+}
+
+// normalizeInboundAudio converts a downloaded audio file to MP3 when
+// TRANSCODE_INBOUND_AUDIO asks for it, updating info to describe the MP3.
+// It returns nil when the file is kept as it is.
+func normalizeInboundAudio(ctx context.Context, info *mediaInfo, file *spooledFile) *spooledFile {
+	if transcodeInboundAudio != "mp3" || activeTranscoder == nil ||
+		(info.Type != "audio" && info.Type != "voice") || strings.HasPrefix(info.Mimetype, mp3Mimetype) {
+		return nil
+	}
+	mp3, err := transcode(ctx, file, info.Mimetype, mp3Mimetype, max(file.Size*8, 16<<20))
+	if err != nil {
+		waLogger.Warnf("Failed to convert incoming %s to MP3: %v", info.Mimetype, err)
+		return nil
+	}
+	info.OriginalMimetype, info.Mimetype = info.Mimetype, mp3Mimetype
+	info.SHA256 = hex.EncodeToString(mp3.SHA256)
+	return mp3
+}
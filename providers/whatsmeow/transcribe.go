@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.mau.fi/whatsmeow/proto/waE2E"
+)
+
+// Inbound voice notes can be transcribed through a pluggable speech-to-text
+// provider (TRANSCRIBE_PROVIDER). The transcript is reported with the
+// message (see enrichment.go) and kept in the message store for search.
+
+var transcribeMaxSeconds = envInt("TRANSCRIBE_MAX_SECONDS", 300) // longer voice notes are skipped
+
+type transcriber interface {
+	Transcribe(ctx context.Context, audio []byte, mimetype string) (string, error)
+}
+
+// activeTranscriber is nil when no provider is configured.
+var activeTranscriber transcriber
+
+func newTranscriber(provider string) transcriber {
+	switch strings.ToLower(provider) {
+	case "":
+		return nil
+	case "whisper":
+		// The OpenAI transcription API; self-hosted Whisper servers
+		// (faster-whisper-server, LocalAI, ...) expose the same endpoint.
+		return &whisperTranscriber{
+			url:   strings.TrimSuffix(envString("TRANSCRIBE_API_URL", "https://api.openai.com/v1"), "/"),
+			key:   envString("TRANSCRIBE_API_KEY", ""),
+			model: envString("TRANSCRIBE_MODEL", "whisper-1"),
+		}
+	default:
+		waLogger.Errorf("Unknown TRANSCRIBE_PROVIDER %q, transcription disabled", provider)
+		return nil
+	}
+}
+
+type whisperTranscriber struct {
+	url   string
+	key   string
+	model string
+}
+
+func (t *whisperTranscriber) Transcribe(ctx context.Context, audio []byte, mimetype string) (string, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	form.WriteField("model", t.model)
+	part, err := form.CreateFormFile("file", "voice"+audioExtension(mimetype))
+	if err != nil {
+		return "", err
+	}
+	part.Write(audio)
+	form.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "POST", t.url+"/audio/transcriptions", &body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	if t.key != "" {
+		req.Header.Set("Authorization", "Bearer "+t.key)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("provider returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	var out struct {
+		Text string `json:"text"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out.Text), nil
+}
+
+// audioExtension picks the file extension providers use to sniff the format.
+// Voice notes are "audio/ogg; codecs=opus".
+func audioExtension(mimetype string) string {
+	switch strings.TrimSpace(strings.SplitN(mimetype, ";", 2)[0]) {
+	case "audio/mpeg":
+		return ".mp3"
+	case "audio/mp4", "audio/aac":
+		return ".m4a"
+	case "audio/wav", "audio/x-wav":
+		return ".wav"
+	case "audio/webm":
+		return ".webm"
+	}
+	return ".ogg"
+}
+
+// voiceNoteToTranscribe returns the message's voice note if it should be
+// transcribed.
+func voiceNoteToTranscribe(msg *waE2E.Message) *waE2E.AudioMessage {
+	audio := msg.GetAudioMessage()
+	if activeTranscriber == nil || audio == nil || !audio.GetPTT() || mediaSkipped(msg) {
+		return nil
+	}
+	if transcribeMaxSeconds > 0 && int(audio.GetSeconds()) > transcribeMaxSeconds {
+		return nil
+	}
+	return audio
+}
+
+// transcribeVoiceNote downloads and transcribes an inbound voice note. It
+// returns "" for other messages or when transcription is off or fails.
+func transcribeVoiceNote(ctx context.Context, msg *waE2E.Message) string {
+	audio := voiceNoteToTranscribe(msg)
+	if audio == nil {
+		return ""
+	}
+	data, err := client.Download(ctx, audio)
+	if err != nil {
+		waLogger.Errorf("Failed to download voice note for transcription: %v", err)
+		return ""
+	}
+	text, err := activeTranscriber.Transcribe(ctx, data, audio.GetMimetype())
+	if err != nil {
+		waLogger.Errorf("Failed to transcribe voice note: %v", err)
+		return ""
+	}
+	return text
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"golang.org/x/text/language"
+)
+
+// Translation goes through a pluggable provider (TRANSLATE_PROVIDER).
+// Inbound text is translated to the session language and reported with the
+// message as translated_text; the detected language is remembered per chat
+// so sends with translate enabled reach the contact in their own language.
+
+var (
+	translateInbound  = envBool("TRANSLATE_INBOUND")
+	translateOutbound = envBool("TRANSLATE_OUTBOUND") // default for sends without "translate"
+
+	// Short messages ("ok", "thanks") are too ambiguous to tell a chat's
+	// language from.
+	translateMinDetectLength = 15
+)
+
+type translation struct {
+	Text   string
+	Source string // detected source language, lowercase ISO 639-1
+}
+
+type translator interface {
+	Translate(ctx context.Context, text, target string) (translation, error)
+}
+
+// activeTranslator is nil when no provider is configured.
+var activeTranslator translator
+
+func newTranslator(provider string) translator {
+	key := envString("TRANSLATE_API_KEY", "")
+	switch strings.ToLower(provider) {
+	case "":
+		return nil
+	case "libretranslate":
+		url := envString("TRANSLATE_API_URL", "")
+		if url == "" {
+			waLogger.Errorf("TRANSLATE_API_URL is required for LibreTranslate, translation disabled")
+			return nil
+		}
+		return &libreTranslator{url: strings.TrimSuffix(url, "/"), key: key}
+	case "deepl":
+		// DeepL free-tier keys end in ":fx" and use a separate host.
+		def := "https://api.deepl.com"
+		if strings.HasSuffix(key, ":fx") {
+			def = "https://api-free.deepl.com"
+		}
+		return &deeplTranslator{url: strings.TrimSuffix(envString("TRANSLATE_API_URL", def), "/"), key: key}
+	default:
+		waLogger.Errorf("Unknown TRANSLATE_PROVIDER %q, translation disabled", provider)
+		return nil
+	}
+}
+
+// providerJSON posts a JSON request to an external provider and decodes the
+// JSON response. It gives up after 15 seconds unless ctx has a deadline.
+func providerJSON(ctx context.Context, url string, header http.Header, in, out interface{}) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, 15*time.Second)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("provider returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+type libreTranslator struct {
+	url string
+	key string
+}
+
+func (t *libreTranslator) Translate(ctx context.Context, text, target string) (translation, error) {
+	var resp struct {
+		TranslatedText   string `json:"translatedText"`
+		DetectedLanguage struct {
+			Language string `json:"language"`
+		} `json:"detectedLanguage"`
+	}
+	err := providerJSON(ctx, t.url+"/translate", nil, map[string]string{
+		"q":       text,
+		"source":  "auto",
+		"target":  target,
+		"format":  "text",
+		"api_key": t.key,
+	}, &resp)
+	return translation{Text: resp.TranslatedText, Source: strings.ToLower(resp.DetectedLanguage.Language)}, err
+}
+
+type deeplTranslator struct {
+	url string
+	key string
+}
+
+func (t *deeplTranslator) Translate(ctx context.Context, text, target string) (translation, error) {
+	var resp struct {
+		Translations []struct {
+			DetectedSourceLanguage string `json:"detected_source_language"`
+			Text                   string `json:"text"`
+		} `json:"translations"`
+	}
+	header := http.Header{"Authorization": {"DeepL-Auth-Key " + t.key}}
+	err := providerJSON(ctx, t.url+"/v2/translate", header, map[string]interface{}{
+		"text":        []string{text},
+		"target_lang": strings.ToUpper(target),
+	}, &resp)
+	if err != nil {
+		return translation{}, err
+	}
+	if len(resp.Translations) == 0 {
+		return translation{}, fmt.Errorf("provider returned no translation")
+	}
+	return translation{Text: resp.Translations[0].Text, Source: strings.ToLower(resp.Translations[0].DetectedSourceLanguage)}, nil
+}
+
+// sessionLanguage is the base language of the session locale ("pt" for
+// pt-BR), the target for inbound translation.
+func sessionLanguage() string {
+	localeMu.RLock()
+	locale := localeCurrent.Locale
+	localeMu.RUnlock()
+	base, _ := language.Make(locale).Base()
+	return base.String()
+}
+
+// translateIncoming detects the language of an inbound message and records it
+// on the chat. It returns the translation to attach to the webhook, if inbound
+// translation is on and the message is not already in the session language.
+func translateIncoming(ctx context.Context, chat types.JID, msg *waE2E.Message) (translation, bool) {
+	if activeTranslator == nil || (!translateInbound && !translateOutbound) {
+		return translation{}, false
+	}
+	text := messageText(msg)
+	if strings.TrimSpace(text) == "" {
+		return translation{}, false
+	}
+	target := sessionLanguage()
+	res, err := activeTranslator.Translate(ctx, text, target)
+	if err != nil {
+		waLogger.Errorf("Failed to translate message in %s: %v", chat, err)
+		return translation{}, false
+	}
+	if chat.Server == types.DefaultUserServer && res.Source != "" && utf8.RuneCountInString(text) >= translateMinDetectLength {
+		if _, err := gatewayDB.ExecContext(ctx, `UPDATE chats SET language = ? WHERE jid = ?`, res.Source, chat.String()); err != nil {
+			waLogger.Errorf("Failed to record language of %s: %v", chat, err)
+		}
+	}
+	if !translateInbound || res.Source == target {
+		return translation{}, false
+	}
+	return res, true
+}
+
+// setMessageText replaces the text that messageText returns.
+func setMessageText(msg *waE2E.Message, text string) {
+	switch {
+	case msg.Conversation != nil:
+		msg.Conversation = &text
+	case msg.ExtendedTextMessage != nil:
+		msg.ExtendedTextMessage.Text = &text
+	case msg.ImageMessage != nil:
+		msg.ImageMessage.Caption = &text
+	case msg.VideoMessage != nil:
+		msg.VideoMessage.Caption = &text
+	case msg.DocumentMessage != nil:
+		msg.DocumentMessage.Caption = &text
+	}
+}
+
+// translateOutgoing rewrites a send into the recipient's detected language.
+// It returns the target language, or "" when the message was left as is.
+func translateOutgoing(ctx context.Context, to types.JID, msg *waE2E.Message) (string, error) {
+	if activeTranslator == nil {
+		return "", nil
+	}
+	text := messageText(msg)
+	if strings.TrimSpace(text) == "" {
+		return "", nil
+	}
+	chat, err := getChat(ctx, to.ToNonAD())
+	if err != nil || chat.Language == "" || chat.Language == sessionLanguage() {
+		return "", nil
+	}
+	res, err := activeTranslator.Translate(ctx, text, chat.Language)
+	if err != nil {
+		return "", err
+	}
+	setMessageText(msg, res.Text)
+	return chat.Language, nil
+}
@@ -0,0 +1,378 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"google.golang.org/protobuf/proto"
+)
+
+type vCardPhone struct {
+	Number string `json:"number"`          // E.164 form, empty when it can't be determined
+	Raw    string `json:"raw"`             // value exactly as written in the card
+	Type   string `json:"type,omitempty"`  // e.g. CELL, WORK, HOME
+	WAID   string `json:"wa_id,omitempty"` // WhatsApp user ID attached by the sender's client
+}
+
+type vCardContact struct {
+	DisplayName string       `json:"display_name"`
+	FullName    string       `json:"full_name,omitempty"`
+	FirstName   string       `json:"first_name,omitempty"`
+	LastName    string       `json:"last_name,omitempty"`
+	Org         string       `json:"org,omitempty"`
+	Phones      []vCardPhone `json:"phones"`
+	Emails      []string     `json:"emails,omitempty"`
+}
+
+// parseContactMessages extracts structured contacts from a shared contact card
+// or contact array. It returns nil for any other message type.
+func parseContactMessages(msg *waE2E.Message) []vCardContact {
+	if msg == nil {
+		return nil
+	}
+	var cards []*waE2E.ContactMessage
+	if cm := msg.GetContactMessage(); cm != nil {
+		cards = append(cards, cm)
+	}
+	if cam := msg.GetContactsArrayMessage(); cam != nil {
+		cards = append(cards, cam.GetContacts()...)
+	}
+	if len(cards) == 0 {
+		return nil
+	}
+	contacts := make([]vCardContact, 0, len(cards))
+	for _, card := range cards {
+		contact := parseVCard(card.GetVcard())
+		if contact.DisplayName == "" {
+			contact.DisplayName = card.GetDisplayName()
+		}
+		contacts = append(contacts, contact)
+	}
+	return contacts
+}
+
+// parseVCard parses the subset of vCard 2.1/3.0/4.0 that WhatsApp clients emit.
+// Unknown properties are ignored rather than rejected.
+func parseVCard(raw string) vCardContact {
+	contact := vCardContact{Phones: []vCardPhone{}}
+	for _, line := range unfoldVCardLines(raw) {
+		name, params, value, ok := splitVCardLine(line)
+		if !ok {
+			continue
+		}
+		switch name {
+		case "FN":
+			contact.FullName = unescapeVCardValue(value)
+		case "N":
+			parts := splitVCardComponents(value)
+			if len(parts) > 0 {
+				contact.LastName = parts[0]
+			}
+			if len(parts) > 1 {
+				contact.FirstName = parts[1]
+			}
+		case "ORG":
+			var units []string
+			for _, part := range splitVCardComponents(value) {
+				if part != "" {
+					units = append(units, part)
+				}
+			}
+			contact.Org = strings.Join(units, ", ")
+		case "TEL":
+			phone := vCardPhone{
+				Raw:  unescapeVCardValue(value),
+				Type: params["TYPE"],
+				WAID: params["WAID"],
+			}
+			phone.Number = normalizeVCardPhone(phone.Raw, phone.WAID)
+			contact.Phones = append(contact.Phones, phone)
+		case "EMAIL":
+			contact.Emails = append(contact.Emails, unescapeVCardValue(value))
+		}
+	}
+	contact.DisplayName = contact.FullName
+	if contact.DisplayName == "" {
+		contact.DisplayName = strings.TrimSpace(contact.FirstName + " " + contact.LastName)
+	}
+	return contact
+}
+
+// unfoldVCardLines joins folded continuation lines (RFC 6350 §3.2).
+func unfoldVCardLines(raw string) []string {
+	raw = strings.ReplaceAll(raw, "\r\n", "\n")
+	var lines []string
+	for _, line := range strings.Split(raw, "\n") {
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// splitVCardLine splits "item1.TEL;type=CELL;waid=123:+1 555" into the
+// upper-cased property name, its parameters and the raw value.
+func splitVCardLine(line string) (name string, params map[string]string, value string, ok bool) {
+	colon := strings.IndexByte(line, ':')
+	if colon < 0 {
+		return "", nil, "", false
+	}
+	head, value := line[:colon], line[colon+1:]
+	fields := strings.Split(head, ";")
+	name = strings.ToUpper(fields[0])
+	if dot := strings.LastIndexByte(name, '.'); dot >= 0 {
+		name = name[dot+1:] // drop Apple-style group prefixes like "item1."
+	}
+	params = make(map[string]string)
+	for _, field := range fields[1:] {
+		key, val, found := strings.Cut(field, "=")
+		if !found {
+			// vCard 2.1 allows bare type values, e.g. TEL;CELL:...
+			key, val = "TYPE", field
+		}
+		key = strings.ToUpper(key)
+		if existing, dup := params[key]; dup && key == "TYPE" {
+			val = existing + "," + val
+		}
+		params[key] = val
+	}
+	if t, has := params["TYPE"]; has {
+		params["TYPE"] = strings.ToUpper(t)
+	}
+	return name, params, value, true
+}
+
+func splitVCardComponents(value string) []string {
+	var parts []string
+	var cur strings.Builder
+	escaped := false
+	for _, r := range value {
+		switch {
+		case escaped:
+			cur.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			cur.WriteRune(r)
+			escaped = true
+		case r == ';':
+			parts = append(parts, unescapeVCardValue(cur.String()))
+			cur.Reset()
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	return append(parts, unescapeVCardValue(cur.String()))
+}
+
+func unescapeVCardValue(value string) string {
+	replacer := strings.NewReplacer(`\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";", `\\`, `\`)
+	return strings.TrimSpace(replacer.Replace(value))
+}
+
+// normalizeVCardPhone returns the E.164 form of a vCard phone number. The
+// waid parameter added by WhatsApp clients is authoritative when present;
+// otherwise only numbers written in international format can be normalized.
+func normalizeVCardPhone(raw, waid string) string {
+	if digits := onlyDigits(waid); digits != "" {
+		return "+" + digits
+	}
+	trimmed := strings.TrimSpace(raw)
+	international := strings.HasPrefix(trimmed, "+")
+	digits := onlyDigits(trimmed)
+	if !international && strings.HasPrefix(digits, "00") {
+		international = true
+		digits = digits[2:]
+	}
+	if !international || len(digits) < 7 || len(digits) > 15 {
+		return ""
+	}
+	return "+" + digits
+}
+
+func onlyDigits(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, s)
+}
+
+// --- Sending contact cards ---
+
+// maxSentContacts caps the cards in one contacts array.
+const maxSentContacts = 50
+
+type sendContactRequest struct {
+	To             string         `json:"to"`
+	Contacts       []vCardContact `json:"contacts"` // same shape as in message webhooks
+	AllowDuplicate bool           `json:"allow_duplicate,omitempty"`
+}
+
+// validateSentContact fills in the display name and normalized numbers of a
+// contact to send.
+func validateSentContact(c *vCardContact) error {
+	if c.DisplayName == "" {
+		c.DisplayName = c.FullName
+	}
+	if c.DisplayName == "" {
+		c.DisplayName = strings.TrimSpace(c.FirstName + " " + c.LastName)
+	}
+	if c.DisplayName == "" {
+		return fmt.Errorf("display_name or first_name/last_name is required")
+	}
+	if len(c.Phones) == 0 {
+		return fmt.Errorf("at least one phone is required")
+	}
+	for i := range c.Phones {
+		p := &c.Phones[i]
+		if p.Number == "" {
+			p.Number = p.Raw
+		}
+		// The card shows the number as given.
+		if p.Raw == "" {
+			p.Raw = strings.TrimSpace(p.Number)
+		}
+		number := normalizeVCardPhone(p.Number, "")
+		if number == "" {
+			return fmt.Errorf("phone %q must be in international format, e.g. +15551234567", p.Number)
+		}
+		p.Number = number
+		p.WAID = onlyDigits(p.WAID)
+	}
+	return nil
+}
+
+// fillWhatsAppIDs sets the waid of phones that are on WhatsApp, which gives
+// the card a button to message them. Without a connection the cards are sent
+// without.
+func fillWhatsAppIDs(ctx context.Context, contacts []vCardContact) {
+	var query []string
+	for _, c := range contacts {
+		for _, p := range c.Phones {
+			if p.WAID == "" {
+				query = append(query, p.Number)
+			}
+		}
+	}
+	if len(query) == 0 || client == nil {
+		return
+	}
+	resp, err := client.IsOnWhatsApp(ctx, query)
+	if err != nil {
+		waLogger.Warnf("Failed to look up contact card numbers on WhatsApp: %v", err)
+		return
+	}
+	waids := make(map[string]string)
+	for _, r := range resp {
+		if r.IsIn {
+			waids["+"+onlyDigits(r.Query)] = r.JID.User
+		}
+	}
+	for i := range contacts {
+		for j := range contacts[i].Phones {
+			p := &contacts[i].Phones[j]
+			if p.WAID == "" {
+				p.WAID = waids[p.Number]
+			}
+		}
+	}
+}
+
+func escapeVCardValue(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, "\n", `\n`, ",", `\,`, ";", `\;`)
+	return replacer.Replace(strings.ReplaceAll(value, "\r\n", "\n"))
+}
+
+// buildVCard renders a contact as the vCard 3.0 WhatsApp clients send.
+func buildVCard(c vCardContact) string {
+	var b strings.Builder
+	b.WriteString("BEGIN:VCARD\nVERSION:3.0\n")
+	fmt.Fprintf(&b, "N:%s;%s;;;\n", escapeVCardValue(c.LastName), escapeVCardValue(c.FirstName))
+	fmt.Fprintf(&b, "FN:%s\n", escapeVCardValue(c.DisplayName))
+	if c.Org != "" {
+		fmt.Fprintf(&b, "ORG:%s\n", escapeVCardValue(c.Org))
+	}
+	for _, p := range c.Phones {
+		b.WriteString("TEL")
+		if p.Type != "" {
+			for _, t := range strings.Split(p.Type, ",") {
+				if t = strings.TrimSpace(t); t != "" {
+					b.WriteString(";type=" + strings.ToUpper(t))
+				}
+			}
+		}
+		if p.WAID != "" {
+			b.WriteString(";waid=" + p.WAID)
+		}
+		b.WriteString(":" + escapeVCardValue(p.Raw) + "\n")
+	}
+	for _, email := range c.Emails {
+		fmt.Fprintf(&b, "EMAIL:%s\n", escapeVCardValue(email))
+	}
+	b.WriteString("END:VCARD")
+	return b.String()
+}
+
+// contactsMessage builds a contact card message, or a contacts array for
+// more than one contact.
+func contactsMessage(contacts []vCardContact) *waE2E.Message {
+	cards := make([]*waE2E.ContactMessage, len(contacts))
+	for i, c := range contacts {
+		cards[i] = &waE2E.ContactMessage{DisplayName: proto.String(c.DisplayName), Vcard: proto.String(buildVCard(c))}
+	}
+	if len(cards) == 1 {
+		return &waE2E.Message{ContactMessage: cards[0]}
+	}
+	return &waE2E.Message{ContactsArrayMessage: &waE2E.ContactsArrayMessage{
+		DisplayName: proto.String(fmt.Sprintf("%d contacts", len(cards))),
+		Contacts:    cards,
+	}}
+}
+
+func sendContact(w http.ResponseWriter, r *http.Request) {
+	if sessionArchived() {
+		http.Error(w, "Session is archived", http.StatusConflict)
+		return
+	}
+	if !requireClient(w, r, true) {
+		return
+	}
+	var req sendContactRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(req.Contacts) == 0 || len(req.Contacts) > maxSentContacts {
+		http.Error(w, fmt.Sprintf("contacts must have 1-%d entries", maxSentContacts), http.StatusBadRequest)
+		return
+	}
+	for i := range req.Contacts {
+		if err := validateSentContact(&req.Contacts[i]); err != nil {
+			http.Error(w, fmt.Sprintf("contacts[%d]: %v", i, err), http.StatusBadRequest)
+			return
+		}
+	}
+	recipient, err := parseRecipient(req.To)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	recipient = toPhoneJID(r.Context(), recipient)
+	fillWhatsAppIDs(r.Context(), req.Contacts)
+
+	res, err := sendOrQueue(r.Context(), recipient, contactsMessage(req.Contacts), sendOptions{AllowDuplicate: req.AllowDuplicate})
+	if err != nil {
+		writeSendError(w, recipient, err)
+		return
+	}
+	writeSendResult(w, recipient, res)
+}
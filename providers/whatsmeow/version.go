@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+
+	"go.mau.fi/whatsmeow/socket"
+	"go.mau.fi/whatsmeow/store"
+)
+
+// Set at build time with -ldflags "-X main.version=... -X main.gitCommit=... -X main.buildTime=...".
+var (
+	version   = "dev"
+	gitCommit = ""
+	buildTime = ""
+)
+
+type versionInfo struct {
+	Version          string `json:"version"`
+	GitCommit        string `json:"git_commit,omitempty"`
+	BuildTime        string `json:"build_time,omitempty"`
+	GoVersion        string `json:"go_version"`
+	WhatsmeowVersion string `json:"whatsmeow_version"`
+	WAWebVersion     string `json:"wa_web_version"`
+	ProtocolVersion  string `json:"protocol_version"`
+	InstanceID       string `json:"instance_id,omitempty"`
+}
+
+func currentVersionInfo() versionInfo {
+	info := versionInfo{
+		Version:         version,
+		GitCommit:       gitCommit,
+		BuildTime:       buildTime,
+		GoVersion:       runtime.Version(),
+		WAWebVersion:    store.GetWAVersion().String(),
+		ProtocolVersion: fmt.Sprintf("%d.%d", socket.WAConnHeader[2], socket.WAConnHeader[3]),
+		InstanceID:      instanceID,
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, dep := range bi.Deps {
+			if dep.Path == "go.mau.fi/whatsmeow" {
+				info.WhatsmeowVersion = dep.Version
+				if dep.Replace != nil {
+					info.WhatsmeowVersion = dep.Replace.Version
+				}
+			}
+		}
+		// Fall back to the VCS stamp when the commit wasn't passed in.
+		for _, s := range bi.Settings {
+			if s.Key == "vcs.revision" && info.GitCommit == "" {
+				info.GitCommit = s.Value
+			}
+		}
+	}
+	return info
+}
+
+func versionHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, currentVersionInfo())
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// Media sent with view_once can be opened only once by the recipient. The
+// flag is kept on the media message itself, so queued and resumed sends
+// carry it, and the message is wrapped in the view-once envelope WhatsApp
+// expects right before it goes out. Inbound view-once media has view_once
+// set in the message webhook.
+
+// viewOnceEnvelope wraps msg in a view-once envelope if its media is marked
+// view once.
+func viewOnceEnvelope(msg *waE2E.Message) *waE2E.Message {
+	if msg.GetImageMessage().GetViewOnce() || msg.GetVideoMessage().GetViewOnce() || msg.GetAudioMessage().GetViewOnce() {
+		return &waE2E.Message{ViewOnceMessage: &waE2E.FutureProofMessage{Message: msg}}
+	}
+	return msg
+}
+
+// isViewOnce reports whether an inbound message is view-once media, whichever
+// envelope it came in.
+func isViewOnce(evt *events.Message) bool {
+	if evt.IsViewOnce || evt.IsViewOnceV2 || evt.IsViewOnceV2Extension {
+		return true
+	}
+	msg := evt.Message
+	return msg.GetImageMessage().GetViewOnce() || msg.GetVideoMessage().GetViewOnce() || msg.GetAudioMessage().GetViewOnce()
+}
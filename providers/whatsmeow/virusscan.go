@@ -0,0 +1,297 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// Inbound documents can be scanned for malware (SCAN_PROVIDER: clamav over
+// clamd's INSTREAM, or an http scanner). The verdict is reported with the
+// message as scan; an infected file is copied to QUARANTINE_DIR for
+// inspection, listed under /admin/quarantine, and neither analyzed nor
+// forwarded. The gateway itself doesn't keep or serve other documents.
+
+var (
+	scanMaxBytes  = envInt("SCAN_MAX_BYTES", 25<<20)
+	quarantineDir = envString("QUARANTINE_DIR", "/app/session/quarantine")
+)
+
+type scanVerdict struct {
+	Status       string `json:"status"` // clean, infected or error
+	Signature    string `json:"signature,omitempty"`
+	QuarantineID int64  `json:"quarantine_id,omitempty"`
+}
+
+type virusScanner interface {
+	Scan(ctx context.Context, name string, data []byte) (infected bool, signature string, err error)
+}
+
+// activeVirusScanner is nil when no provider is configured.
+var activeVirusScanner virusScanner
+
+func newVirusScanner(provider string) virusScanner {
+	switch strings.ToLower(provider) {
+	case "":
+		return nil
+	case "clamav":
+		return &clamavScanner{addr: envString("SCAN_CLAMAV_ADDR", "tcp://clamav:3310")}
+	case "http":
+		url := envString("SCAN_API_URL", "")
+		if url == "" {
+			waLogger.Errorf("SCAN_API_URL is required for the http scanner, scanning disabled")
+			return nil
+		}
+		return &httpScanner{url: url, key: envString("SCAN_API_KEY", "")}
+	default:
+		waLogger.Errorf("Unknown SCAN_PROVIDER %q, scanning disabled", provider)
+		return nil
+	}
+}
+
+// clamavScanner streams files to clamd at tcp://host:port or
+// unix:///path/to/clamd.sock.
+type clamavScanner struct {
+	addr string
+}
+
+func (s *clamavScanner) Scan(ctx context.Context, name string, data []byte) (bool, string, error) {
+	network, addr := "tcp", strings.TrimPrefix(s.addr, "tcp://")
+	if strings.HasPrefix(s.addr, "unix://") {
+		network, addr = "unix", strings.TrimPrefix(s.addr, "unix://")
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, addr)
+	if err != nil {
+		return false, "", err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(2 * time.Minute))
+
+	w := bufio.NewWriter(conn)
+	w.WriteString("zINSTREAM\x00")
+	// clamd takes the stream in length-prefixed chunks, ended by an empty one.
+	const chunk = 64 << 10
+	for off := 0; off < len(data); off += chunk {
+		part := data[off:min(off+chunk, len(data))]
+		binary.Write(w, binary.BigEndian, uint32(len(part)))
+		w.Write(part)
+	}
+	binary.Write(w, binary.BigEndian, uint32(0))
+	if err := w.Flush(); err != nil {
+		return false, "", err
+	}
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && reply == "" {
+		return false, "", err
+	}
+	// "stream: OK", "stream: Eicar-Signature FOUND" or "... ERROR".
+	reply = strings.TrimSpace(strings.TrimPrefix(strings.TrimSuffix(reply, "\x00"), "stream:"))
+	switch {
+	case reply == "OK":
+		return false, "", nil
+	case strings.HasSuffix(reply, " FOUND"):
+		return true, strings.TrimSuffix(reply, " FOUND"), nil
+	default:
+		return false, "", fmt.Errorf("clamd: %s", reply)
+	}
+}
+
+// httpScanner posts the raw file and expects {"infected": bool,
+// "signature": "..."} back.
+type httpScanner struct {
+	url string
+	key string
+}
+
+func (s *httpScanner) Scan(ctx context.Context, name string, data []byte) (bool, string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "POST", s.url, bytes.NewReader(data))
+	if err != nil {
+		return false, "", err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("X-File-Name", name)
+	if s.key != "" {
+		req.Header.Set("Authorization", "Bearer "+s.key)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return false, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return false, "", fmt.Errorf("scanner returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	var out struct {
+		Infected  bool   `json:"infected"`
+		Signature string `json:"signature"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return false, "", err
+	}
+	return out.Infected, out.Signature, nil
+}
+
+// documentToScan returns the message's document if it should be scanned.
+func documentToScan(msg *waE2E.Message) *waE2E.DocumentMessage {
+	doc := msg.GetDocumentMessage()
+	if activeVirusScanner == nil || doc == nil || mediaSkipped(msg) {
+		return nil
+	}
+	if scanMaxBytes > 0 && doc.GetFileLength() > uint64(scanMaxBytes) {
+		return nil
+	}
+	return doc
+}
+
+// scanAttachment downloads and scans an inbound document, quarantining it
+// when infected. It returns nil for other messages or when scanning is off.
+func scanAttachment(ctx context.Context, evt *events.Message) *scanVerdict {
+	doc := documentToScan(evt.Message)
+	if doc == nil {
+		return nil
+	}
+	data, err := client.Download(ctx, doc)
+	if err != nil {
+		waLogger.Errorf("Failed to download document %s for scanning: %v", evt.Info.ID, err)
+		return &scanVerdict{Status: "error"}
+	}
+	infected, signature, err := activeVirusScanner.Scan(ctx, doc.GetFileName(), data)
+	if err != nil {
+		waLogger.Errorf("Failed to scan document %s: %v", evt.Info.ID, err)
+		return &scanVerdict{Status: "error"}
+	}
+	if !infected {
+		return &scanVerdict{Status: "clean"}
+	}
+	waLogger.Warnf("Document %s from %s is infected: %s", evt.Info.ID, evt.Info.Sender, signature)
+	verdict := &scanVerdict{Status: "infected", Signature: signature}
+	if verdict.QuarantineID, err = quarantineFile(ctx, evt, doc, data, signature); err != nil {
+		waLogger.Errorf("Failed to quarantine document %s: %v", evt.Info.ID, err)
+	}
+	return verdict
+}
+
+func quarantineFile(ctx context.Context, evt *events.Message, doc *waE2E.DocumentMessage, data []byte, signature string) (int64, error) {
+	if err := os.MkdirAll(quarantineDir, 0700); err != nil {
+		return 0, err
+	}
+	sum := sha256.Sum256(data)
+	// Files are kept under their hash, without an extension that would make
+	// them easy to open by accident.
+	path := filepath.Join(quarantineDir, hex.EncodeToString(sum[:]))
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return 0, err
+	}
+	var id int64
+	err := gatewayDB.QueryRowContext(ctx, `
+		INSERT INTO quarantined_files (chat_jid, message_id, sender_jid, file_name, mimetype, size, sha256, signature, path, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`,
+		canonicalJID(ctx, evt.Info.Chat).String(), evt.Info.ID, canonicalJID(ctx, evt.Info.Sender).String(),
+		doc.GetFileName(), doc.GetMimetype(), len(data), hex.EncodeToString(sum[:]), signature, path, time.Now().Unix()).Scan(&id)
+	return id, err
+}
+
+type quarantinedFile struct {
+	ID        int64     `json:"id"`
+	Chat      string    `json:"chat"`
+	MessageID string    `json:"message_id"`
+	Sender    string    `json:"sender"`
+	FileName  string    `json:"file_name,omitempty"`
+	Mimetype  string    `json:"mimetype,omitempty"`
+	Size      int64     `json:"size"`
+	SHA256    string    `json:"sha256"`
+	Signature string    `json:"signature"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func listQuarantine(w http.ResponseWriter, r *http.Request) {
+	limit := 100
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 || n > 1000 {
+			http.Error(w, "Invalid limit, expected 1-1000", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	rows, err := gatewayDB.QueryContext(r.Context(), `
+		SELECT id, chat_jid, message_id, sender_jid, file_name, mimetype, size, sha256, signature, created_at
+		FROM quarantined_files ORDER BY id DESC LIMIT ?`, limit)
+	if err != nil {
+		waLogger.Errorf("Failed to list quarantined files: %v", err)
+		http.Error(w, "Failed to list quarantined files", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	files := []quarantinedFile{}
+	for rows.Next() {
+		var f quarantinedFile
+		var created int64
+		if err := rows.Scan(&f.ID, &f.Chat, &f.MessageID, &f.Sender, &f.FileName, &f.Mimetype, &f.Size, &f.SHA256, &f.Signature, &created); err != nil {
+			waLogger.Errorf("Failed to list quarantined files: %v", err)
+			http.Error(w, "Failed to list quarantined files", http.StatusInternalServerError)
+			return
+		}
+		f.CreatedAt = time.Unix(created, 0).UTC()
+		files = append(files, f)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"files": files})
+}
+
+// deleteQuarantinedFile removes a quarantined file once it's been dealt
+// with. Copies of the same file share one stored file, which goes with the
+// last of them.
+func deleteQuarantinedFile(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid id", http.StatusBadRequest)
+		return
+	}
+	var path string
+	err = gatewayDB.QueryRowContext(r.Context(), `DELETE FROM quarantined_files WHERE id = ? RETURNING path`, id).Scan(&path)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Quarantined file not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		waLogger.Errorf("Failed to delete quarantined file %d: %v", id, err)
+		http.Error(w, "Failed to delete quarantined file", http.StatusInternalServerError)
+		return
+	}
+	removeUnreferencedQuarantineFiles(r.Context(), []string{path})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// removeUnreferencedQuarantineFiles deletes quarantined files no row points
+// at any more.
+func removeUnreferencedQuarantineFiles(ctx context.Context, paths []string) {
+	for _, path := range paths {
+		var n int
+		if err := gatewayDB.QueryRowContext(ctx, `SELECT COUNT(*) FROM quarantined_files WHERE path = ?`, path).Scan(&n); err != nil || n > 0 {
+			continue
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			waLogger.Errorf("Failed to delete quarantined file %s: %v", path, err)
+		}
+	}
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow/proto/waE2E"
+)
+
+// A freshly paired number that starts sending at full volume is likely to be
+// banned. New sessions therefore warm up: WARMUP_SCHEDULE is the daily send
+// cap for each week after pairing (empty disables warm-up), and messages over
+// the day's cap are queued to outbound_queue until the next day in the
+// session's timezone. Reactions, edits and revokes don't count. The cap can
+// be overridden, and warm-up restarted or ended, with /admin/warmup.
+
+const warmupWeek = 7 * 24 * time.Hour
+
+var (
+	warmupSchedule    []int // daily cap per week, from WARMUP_SCHEDULE
+	warmupMu          sync.Mutex
+	warmupStartedAt   time.Time // zero when not warming up
+	warmupOverride    int       // replaces the scheduled cap when set
+	warmupDay         string    // in the session's timezone
+	warmupSent        int       // on warmupDay
+	warmupCapNotified string    // day warmup.cap_reached was last emitted
+)
+
+func parseWarmupSchedule(s string) []int {
+	var caps []int
+	for _, f := range strings.Split(s, ",") {
+		if f = strings.TrimSpace(f); f == "" {
+			continue
+		}
+		n, err := strconv.Atoi(f)
+		if err != nil || n <= 0 {
+			waLogger.Errorf("Invalid WARMUP_SCHEDULE %q, warm-up is disabled", s)
+			return nil
+		}
+		caps = append(caps, n)
+	}
+	return caps
+}
+
+// loadWarmup restores warm-up progress and today's count across restarts.
+func loadWarmup(ctx context.Context) {
+	warmupSchedule = parseWarmupSchedule(envString("WARMUP_SCHEDULE", "20,50,100,250,500"))
+	started, _ := strconv.ParseInt(getSetting(ctx, "warmup_started_at", "0"), 10, 64)
+	override, _ := strconv.Atoi(getSetting(ctx, "warmup_override", "0"))
+	var day string
+	var sent int
+	fmt.Sscanf(getSetting(ctx, "warmup_sent", ""), "%s %d", &day, &sent)
+	warmupMu.Lock()
+	defer warmupMu.Unlock()
+	if started > 0 {
+		warmupStartedAt = time.Unix(started, 0)
+	}
+	warmupOverride, warmupDay, warmupSent = override, day, sent
+}
+
+// startWarmup (re)starts warm-up from the first week, e.g. after pairing.
+func startWarmup(ctx context.Context) error {
+	warmupMu.Lock()
+	defer warmupMu.Unlock()
+	now := time.Now()
+	if err := setSetting(ctx, "warmup_started_at", strconv.FormatInt(now.Unix(), 10)); err != nil {
+		return err
+	}
+	if err := setSetting(ctx, "warmup_override", "0"); err != nil {
+		return err
+	}
+	warmupStartedAt, warmupOverride = now, 0
+	return nil
+}
+
+// endWarmupLocked lifts the caps for good.
+func endWarmupLocked(ctx context.Context) error {
+	if err := setSetting(ctx, "warmup_started_at", "0"); err != nil {
+		return err
+	}
+	warmupStartedAt, warmupOverride = time.Time{}, 0
+	wakeDispatcher()
+	return nil
+}
+
+// warmupCapLocked returns today's cap, or false when sends aren't capped.
+func warmupCapLocked(now time.Time) (int, bool) {
+	if warmupStartedAt.IsZero() {
+		return 0, false
+	}
+	if warmupOverride > 0 {
+		return warmupOverride, true
+	}
+	week := int(now.Sub(warmupStartedAt) / warmupWeek)
+	if week >= len(warmupSchedule) {
+		return 0, false
+	}
+	return warmupSchedule[week], true
+}
+
+// rollWarmupDayLocked starts a new count when the session's day changes.
+func rollWarmupDayLocked(now time.Time) {
+	if today := now.In(sessionLocation()).Format(time.DateOnly); today != warmupDay {
+		warmupDay, warmupSent = today, 0
+	}
+}
+
+func saveWarmupSentLocked(ctx context.Context) {
+	if err := setSetting(ctx, "warmup_sent", fmt.Sprintf("%s %d", warmupDay, warmupSent)); err != nil {
+		waLogger.Errorf("Failed to save warm-up send count: %v", err)
+	}
+}
+
+// warmupCounts reports whether a message counts towards the daily cap; only
+// ones that add to a conversation do.
+func warmupCounts(msg *waE2E.Message) bool {
+	return msg.ReactionMessage == nil && msg.EditedMessage == nil && msg.ProtocolMessage == nil
+}
+
+// takeWarmupSend counts a message towards today's cap, or reports that the
+// cap has been reached and the message has to wait in the queue.
+func takeWarmupSend(ctx context.Context, msg *waE2E.Message) bool {
+	if !warmupCounts(msg) {
+		return true
+	}
+	now := time.Now()
+	warmupMu.Lock()
+	rollWarmupDayLocked(now)
+	limit, capped := warmupCapLocked(now)
+	if !capped {
+		if !warmupStartedAt.IsZero() {
+			waLogger.Infof("Warm-up completed, sends are no longer capped")
+			if err := endWarmupLocked(ctx); err != nil {
+				waLogger.Errorf("Failed to end warm-up: %v", err)
+			} else {
+				defer emitWebhook("warmup.completed", map[string]interface{}{"completed_at": now.UTC()})
+			}
+		}
+		warmupMu.Unlock()
+		return true
+	}
+	if warmupSent >= limit {
+		notify := warmupCapNotified != warmupDay
+		warmupCapNotified = warmupDay
+		sent := warmupSent
+		warmupMu.Unlock()
+		if notify {
+			waLogger.Warnf("Warm-up cap of %d messages reached for today; further sends are queued", limit)
+			emitWebhook("warmup.cap_reached", map[string]interface{}{
+				"daily_cap":  limit,
+				"sent_today": sent,
+				"resumes_at": nextSessionDay(now),
+			})
+		}
+		return false
+	}
+	warmupSent++
+	saveWarmupSentLocked(ctx)
+	warmupMu.Unlock()
+	return true
+}
+
+// releaseWarmupSend gives back a send taken for a message that then failed.
+func releaseWarmupSend(ctx context.Context, msg *waE2E.Message) {
+	if !warmupCounts(msg) {
+		return
+	}
+	warmupMu.Lock()
+	defer warmupMu.Unlock()
+	rollWarmupDayLocked(time.Now())
+	if warmupSent > 0 {
+		warmupSent--
+		saveWarmupSentLocked(ctx)
+	}
+}
+
+// nextSessionDay is when the next day starts in the session's timezone.
+func nextSessionDay(now time.Time) time.Time {
+	local := now.In(sessionLocation())
+	return time.Date(local.Year(), local.Month(), local.Day()+1, 0, 0, 0, 0, local.Location()).UTC()
+}
+
+type warmupProgress struct {
+	Active         bool       `json:"active"`
+	Schedule       []int      `json:"schedule"` // daily cap per week
+	StartedAt      *time.Time `json:"started_at,omitempty"`
+	EndsAt         *time.Time `json:"ends_at,omitempty"`
+	Week           int        `json:"week,omitempty"` // 1-based
+	DailyCap       int        `json:"daily_cap,omitempty"`
+	Override       bool       `json:"override,omitempty"` // daily_cap was set by hand
+	SentToday      int        `json:"sent_today"`
+	RemainingToday *int       `json:"remaining_today,omitempty"`
+	ResumesAt      *time.Time `json:"resumes_at,omitempty"` // when today's cap is used up
+	Queued         int64      `json:"queued"`
+}
+
+func currentWarmupProgress() warmupProgress {
+	now := time.Now()
+	warmupMu.Lock()
+	rollWarmupDayLocked(now)
+	p := warmupProgress{Schedule: warmupSchedule, SentToday: warmupSent}
+	if p.Schedule == nil {
+		p.Schedule = []int{}
+	}
+	if limit, capped := warmupCapLocked(now); capped {
+		started := warmupStartedAt.UTC()
+		ends := started.Add(time.Duration(len(warmupSchedule)) * warmupWeek)
+		p.Active = true
+		p.StartedAt, p.EndsAt = &started, &ends
+		p.Week = int(now.Sub(warmupStartedAt)/warmupWeek) + 1
+		p.DailyCap, p.Override = limit, warmupOverride > 0
+		remaining := max(limit-warmupSent, 0)
+		p.RemainingToday = &remaining
+		if remaining == 0 {
+			resumes := nextSessionDay(now)
+			p.ResumesAt = &resumes
+		}
+	}
+	warmupMu.Unlock()
+	p.Queued = queuedOutboundCount()
+	return p
+}
+
+func getWarmup(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, currentWarmupProgress())
+}
+
+// restartWarmup starts warm-up over from the first week.
+func restartWarmup(w http.ResponseWriter, r *http.Request) {
+	if len(warmupSchedule) == 0 {
+		http.Error(w, "No warm-up schedule configured (WARMUP_SCHEDULE)", http.StatusConflict)
+		return
+	}
+	if err := startWarmup(r.Context()); err != nil {
+		waLogger.Errorf("Failed to start warm-up: %v", err)
+		http.Error(w, "Failed to start warm-up", http.StatusInternalServerError)
+		return
+	}
+	waLogger.Infof("Warm-up restarted")
+	writeJSON(w, http.StatusOK, currentWarmupProgress())
+}
+
+// endWarmup lifts the caps before the schedule is through.
+func endWarmup(w http.ResponseWriter, r *http.Request) {
+	warmupMu.Lock()
+	err := endWarmupLocked(r.Context())
+	warmupMu.Unlock()
+	if err != nil {
+		waLogger.Errorf("Failed to end warm-up: %v", err)
+		http.Error(w, "Failed to end warm-up", http.StatusInternalServerError)
+		return
+	}
+	waLogger.Warnf("Warm-up ended early, sends are no longer capped")
+	writeJSON(w, http.StatusOK, currentWarmupProgress())
+}
+
+// putWarmupOverride replaces the scheduled daily cap until the override is
+// deleted or warm-up restarts.
+func putWarmupOverride(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		DailyCap int `json:"daily_cap"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.DailyCap <= 0 {
+		http.Error(w, "Invalid request body, expected a positive daily_cap", http.StatusBadRequest)
+		return
+	}
+	setWarmupOverride(w, r, req.DailyCap)
+}
+
+func deleteWarmupOverride(w http.ResponseWriter, r *http.Request) {
+	setWarmupOverride(w, r, 0)
+}
+
+func setWarmupOverride(w http.ResponseWriter, r *http.Request, dailyCap int) {
+	warmupMu.Lock()
+	if warmupStartedAt.IsZero() {
+		warmupMu.Unlock()
+		http.Error(w, "Session is not warming up", http.StatusConflict)
+		return
+	}
+	err := setSetting(r.Context(), "warmup_override", strconv.Itoa(dailyCap))
+	if err == nil {
+		warmupOverride = dailyCap
+	}
+	warmupMu.Unlock()
+	if err != nil {
+		waLogger.Errorf("Failed to save warm-up override: %v", err)
+		http.Error(w, "Failed to save warm-up override", http.StatusInternalServerError)
+		return
+	}
+	wakeDispatcher()
+	writeJSON(w, http.StatusOK, currentWarmupProgress())
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	"google.golang.org/protobuf/proto"
+)
+
+// With WEBHOOK_SECRET set, every webhook delivery (live, buffered, replayed
+// or test) is signed: X-Webhook-Signature is "sha256=" and the hex
+// HMAC-SHA256, keyed with the secret, of X-Webhook-Timestamp, a dot and the
+// raw body. Receivers should compare in constant time and reject old
+// timestamps, which stops recorded deliveries from being replayed.
+//
+// /webhooks/test sends a sample event of a chosen type to WEBHOOK_URL,
+// marked with X-Webhook-Test, and reports how the receiver answered.
+
+var webhookSecret = envString("WEBHOOK_SECRET", "")
+
+const webhookSignatureGuidance = `Compute the HMAC-SHA256 of the X-Webhook-Timestamp header, a "." and the raw request body, ` +
+	`keyed with WEBHOOK_SECRET; hex-encode it and compare it with X-Webhook-Signature after the "sha256=" prefix ` +
+	`using a constant-time comparison. Reject timestamps more than a few minutes old.`
+
+// webhookSignature signs a webhook body sent at ts.
+func webhookSignature(ts int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(webhookSecret))
+	mac.Write([]byte(strconv.FormatInt(ts, 10) + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// signWebhook adds the signature headers to a webhook request, if signing is
+// configured.
+func signWebhook(req *http.Request, body []byte) {
+	if webhookSecret == "" {
+		return
+	}
+	ts := time.Now().Unix()
+	req.Header.Set("X-Webhook-Timestamp", strconv.FormatInt(ts, 10))
+	req.Header.Set("X-Webhook-Signature", webhookSignature(ts, body))
+}
+
+// webhookSamples builds the sample data of the events /webhooks/test can send.
+var webhookSamples = map[string]func() interface{}{
+	"message": func() interface{} {
+		sender := types.NewJID("15551234567", types.DefaultUserServer)
+		return &messageWebhookData{
+			Message: &events.Message{
+				Info: types.MessageInfo{
+					MessageSource: types.MessageSource{Chat: sender, Sender: sender},
+					ID:            "TEST" + strings.ToUpper(randomHex(8)),
+					Type:          "text",
+					PushName:      "Test Contact",
+					Timestamp:     time.Now().UTC(),
+				},
+				Message: &waE2E.Message{Conversation: proto.String("This is a test message from the gateway")},
+			},
+			DisplayName: "Test Contact",
+		}
+	},
+	"message.enriched": func() interface{} {
+		sender := types.NewJID("15551234567", types.DefaultUserServer)
+		return &messageEnrichment{
+			ID:               "TEST" + strings.ToUpper(randomHex(8)),
+			Chat:             sender,
+			Sender:           sender,
+			TranslatedText:   "This is a test message from the gateway",
+			DetectedLanguage: "es",
+		}
+	},
+	"connected":    func() interface{} { return nil },
+	"disconnected": func() interface{} { return nil },
+	"poll.vote": func() interface{} {
+		return map[string]interface{}{
+			"poll_id":   "TEST" + strings.ToUpper(randomHex(8)),
+			"chat":      "15551234567@s.whatsapp.net",
+			"question":  "Is this a test?",
+			"voter":     "15551234567@s.whatsapp.net",
+			"options":   []string{"Yes"},
+			"tally":     map[string]int{"Yes": 1, "No": 0},
+			"voters":    1,
+			"timestamp": time.Now().Unix(),
+		}
+	},
+	"message.flagged": func() interface{} {
+		return map[string]interface{}{
+			"id":         "TEST" + strings.ToUpper(randomHex(8)),
+			"to":         "15551234567@s.whatsapp.net",
+			"violations": []string{"blocked keyword"},
+		}
+	},
+	"schedule.sent": func() interface{} {
+		return map[string]interface{}{
+			"id":         1,
+			"to":         "15551234567@s.whatsapp.net",
+			"due_at":     time.Now().UTC(),
+			"message_id": "TEST" + strings.ToUpper(randomHex(8)),
+		}
+	},
+	"sync.offline_completed": func() interface{} {
+		started, completed := time.Now().UTC().Add(-3*time.Second), time.Now().UTC()
+		return offlineSyncState{
+			State: "done", CaughtUp: true, Total: 42, Messages: 30, Notifications: 4, Receipts: 8,
+			Processed: 42, Progress: 100, StartedAt: &started, CompletedAt: &completed,
+		}
+	},
+	"unknown": func() interface{} {
+		return map[string]interface{}{
+			"type":  "*events.Example",
+			"event": map[string]interface{}{"JID": "15551234567@s.whatsapp.net", "Timestamp": time.Now().UTC()},
+		}
+	},
+}
+
+type webhookTestRequest struct {
+	Event string `json:"event,omitempty"` // defaults to message
+}
+
+type webhookTestResult struct {
+	URL        string          `json:"url"`
+	Event      string          `json:"event"`
+	Delivered  bool            `json:"delivered"` // a 2xx answer
+	StatusCode int             `json:"status_code,omitempty"`
+	LatencyMS  int64           `json:"latency_ms"`
+	Error      string          `json:"error,omitempty"`
+	Response   string          `json:"response,omitempty"` // start of the body
+	Signed     bool            `json:"signed"`
+	Signature  string          `json:"signature,omitempty"`
+	Timestamp  string          `json:"timestamp,omitempty"`
+	Guidance   string          `json:"guidance"`
+	Payload    json.RawMessage `json:"payload"`
+}
+
+// testWebhook sends a sample event to WEBHOOK_URL. Test deliveries don't
+// count towards the webhook failure alert.
+func testWebhook(w http.ResponseWriter, r *http.Request) {
+	target := os.Getenv("WEBHOOK_URL")
+	if target == "" {
+		http.Error(w, "No webhook URL, set WEBHOOK_URL", http.StatusConflict)
+		return
+	}
+	var req webhookTestRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+	if req.Event == "" {
+		req.Event = "message"
+	}
+	sample, ok := webhookSamples[req.Event]
+	if !ok {
+		names := make([]string, 0, len(webhookSamples))
+		for name := range webhookSamples {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		http.Error(w, "Unknown event, expected one of "+strings.Join(names, ", "), http.StatusBadRequest)
+		return
+	}
+	body, err := json.Marshal(webhookPayload{Event: req.Event, Data: sample()})
+	if err != nil {
+		waLogger.Errorf("Failed to marshal test webhook: %v", err)
+		http.Error(w, "Failed to build test event", http.StatusInternalServerError)
+		return
+	}
+
+	res := webhookTestResult{URL: target, Event: req.Event, Payload: body, Guidance: webhookSignatureGuidance}
+	if webhookSecret == "" {
+		res.Guidance = "WEBHOOK_SECRET is not set, so deliveries are unsigned. Set it to have them signed: " + webhookSignatureGuidance
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), webhookClient.Timeout)
+	defer cancel()
+	out, err := http.NewRequestWithContext(ctx, "POST", target, bytes.NewReader(body))
+	if err != nil {
+		http.Error(w, "Invalid WEBHOOK_URL", http.StatusConflict)
+		return
+	}
+	out.Header.Set("Content-Type", "application/json")
+	out.Header.Set("X-Webhook-Test", "true")
+	signWebhook(out, body)
+	res.Signed = webhookSecret != ""
+	res.Signature = out.Header.Get("X-Webhook-Signature")
+	res.Timestamp = out.Header.Get("X-Webhook-Timestamp")
+
+	start := time.Now()
+	resp, err := webhookClient.Do(out)
+	res.LatencyMS = time.Since(start).Milliseconds()
+	if err != nil {
+		res.Error = err.Error()
+		writeJSON(w, http.StatusOK, res)
+		return
+	}
+	defer resp.Body.Close()
+	head, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	res.StatusCode = resp.StatusCode
+	res.Delivered = resp.StatusCode >= 200 && resp.StatusCode < 300
+	res.Response = string(head)
+	if !res.Delivered {
+		res.Error = "webhook answered " + resp.Status
+	}
+	writeJSON(w, http.StatusOK, res)
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+)
+
+// WhatsApp traffic (the chat websocket and media transfers) can leave through
+// WA_PROXY (http, https or socks5), or for networks that only allow 443
+// egress to approved hosts, through a WSS relay at WA_WS_RELAY_URL. For the
+// relay a loopback CONNECT proxy is put in front of whatsmeow; each
+// connection it's asked for is opened as a websocket to the relay with the
+// destination in the target query parameter (host:port), and its bytes are
+// carried as binary frames, so TLS to WhatsApp stays end to end. Relays
+// such as websockify or wstunnel in a mode that honours the target do the
+// other end; WA_WS_RELAY_TOKEN is sent as a bearer token.
+//
+// The CONNECT proxy only tunnels to WhatsApp's hosts, so nothing else on the
+// machine can use it to reach arbitrary hosts through the relay.
+
+// relayAllowedDomains are the domains, with their subdomains, the relay
+// tunnel connects to: the chat and web hosts and the mmg media hosts.
+var relayAllowedDomains = []string{"whatsapp.net", "whatsapp.com"}
+
+// configureConnectionRoute points the client at the configured proxy or
+// relay, if any.
+func configureConnectionRoute(cli *whatsmeow.Client) error {
+	addr := envString("WA_PROXY", "")
+	relay := envString("WA_WS_RELAY_URL", "")
+	if relay != "" {
+		if addr != "" {
+			return fmt.Errorf("set either WA_PROXY or WA_WS_RELAY_URL, not both")
+		}
+		u, err := url.Parse(relay)
+		if err != nil || (u.Scheme != "wss" && u.Scheme != "ws") || u.Host == "" {
+			return fmt.Errorf("WA_WS_RELAY_URL must be a ws(s) URL")
+		}
+		local, err := startRelayTunnel(u, envString("WA_WS_RELAY_TOKEN", ""))
+		if err != nil {
+			return fmt.Errorf("failed to start relay tunnel: %w", err)
+		}
+		waLogger.Infof("Connecting to WhatsApp through the relay at %s", u.Host)
+		addr = "http://" + local
+	} else if addr != "" {
+		waLogger.Infof("Connecting to WhatsApp through the proxy in WA_PROXY")
+	}
+	if addr == "" {
+		return nil
+	}
+	return cli.SetProxyAddress(addr)
+}
+
+// startRelayTunnel listens on a loopback port for CONNECT requests and
+// returns its address.
+func startRelayTunnel(relay *url.URL, token string) (string, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				waLogger.Errorf("Relay tunnel stopped accepting: %v", err)
+				return
+			}
+			go serveRelayConnect(conn, relay, token)
+		}
+	}()
+	return ln.Addr().String(), nil
+}
+
+func serveRelayConnect(conn net.Conn, relay *url.URL, token string) {
+	defer conn.Close()
+	br := bufio.NewReader(conn)
+	req, err := http.ReadRequest(br)
+	if err != nil {
+		return
+	}
+	if req.Method != http.MethodConnect {
+		io.WriteString(conn, "HTTP/1.1 405 Method Not Allowed\r\nContent-Length: 0\r\n\r\n")
+		return
+	}
+	if !relayTargetAllowed(req.Host) {
+		waLogger.Warnf("Refused relay connection to %s", req.Host)
+		io.WriteString(conn, "HTTP/1.1 403 Forbidden\r\nContent-Length: 0\r\n\r\n")
+		return
+	}
+	upstream, err := dialRelay(relay, token, req.Host)
+	if err != nil {
+		waLogger.Errorf("Failed to open relay connection to %s: %v", req.Host, err)
+		io.WriteString(conn, "HTTP/1.1 502 Bad Gateway\r\nContent-Length: 0\r\n\r\n")
+		return
+	}
+	defer upstream.Close()
+	io.WriteString(conn, "HTTP/1.1 200 Connection Established\r\n\r\n")
+	done := make(chan struct{}, 2)
+	go func() {
+		// The client may have sent the TLS hello right behind the CONNECT.
+		io.Copy(upstream, br)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(conn, upstream)
+		done <- struct{}{}
+	}()
+	<-done
+}
+
+// relayTargetAllowed reports whether target (host:port) is a WhatsApp host.
+func relayTargetAllowed(target string) bool {
+	host, _, err := net.SplitHostPort(target)
+	if err != nil {
+		return false
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, domain := range relayAllowedDomains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// dialRelay opens a websocket to the relay for one tunnelled connection.
+func dialRelay(relay *url.URL, token, target string) (net.Conn, error) {
+	u := *relay
+	query := u.Query()
+	query.Set("target", target)
+	u.RawQuery = query.Encode()
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), map[string]string{"wss": "443", "ws": "80"}[u.Scheme])
+	}
+	dialer := &net.Dialer{Timeout: 15 * time.Second, KeepAlive: 30 * time.Second}
+	var conn net.Conn
+	var err error
+	if u.Scheme == "wss" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", host, &tls.Config{ServerName: u.Hostname()})
+	} else {
+		conn, err = dialer.Dial("tcp", host)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, 16)
+	rand.Read(nonce)
+	key := base64.StdEncoding.EncodeToString(nonce)
+	req, _ := http.NewRequest("GET", u.String(), nil)
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	conn.SetDeadline(time.Now().Add(15 * time.Second))
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp.Body.Close()
+	accept := sha1.Sum([]byte(key + websocketGUID))
+	if resp.StatusCode != http.StatusSwitchingProtocols ||
+		resp.Header.Get("Sec-WebSocket-Accept") != base64.StdEncoding.EncodeToString(accept[:]) {
+		conn.Close()
+		return nil, fmt.Errorf("relay refused the websocket: %s", resp.Status)
+	}
+	conn.SetDeadline(time.Time{})
+	return &wsConn{Conn: conn, br: br}, nil
+}
+
+// wsConn carries a byte stream in binary websocket frames.
+type wsConn struct {
+	net.Conn
+	br *bufio.Reader
+
+	remaining int64 // unread payload of the current data frame
+	mask      []byte
+	maskPos   int
+
+	writeMu sync.Mutex
+}
+
+const (
+	wsOpContinuation = 0x0
+	wsOpText         = 0x1
+	wsOpBinary       = 0x2
+	wsOpClose        = 0x8
+	wsOpPing         = 0x9
+	wsOpPong         = 0xA
+)
+
+func (c *wsConn) Read(p []byte) (int, error) {
+	for c.remaining == 0 {
+		op, length, mask, err := c.readHeader()
+		if err != nil {
+			return 0, err
+		}
+		switch op {
+		case wsOpContinuation, wsOpText, wsOpBinary:
+			c.remaining, c.mask, c.maskPos = length, mask, 0
+		case wsOpPing, wsOpPong:
+			if length > 125 {
+				return 0, errors.New("oversized websocket control frame")
+			}
+			payload := make([]byte, length)
+			if _, err := io.ReadFull(c.br, payload); err != nil {
+				return 0, err
+			}
+			if op == wsOpPing {
+				unmask(payload, mask, 0)
+				if err := c.writeFrame(wsOpPong, payload); err != nil {
+					return 0, err
+				}
+			}
+		case wsOpClose:
+			return 0, io.EOF
+		default:
+			return 0, fmt.Errorf("unexpected websocket opcode %d", op)
+		}
+	}
+	if int64(len(p)) > c.remaining {
+		p = p[:c.remaining]
+	}
+	n, err := c.br.Read(p)
+	unmask(p[:n], c.mask, c.maskPos)
+	c.maskPos += n
+	c.remaining -= int64(n)
+	return n, err
+}
+
+func (c *wsConn) readHeader() (op byte, length int64, mask []byte, err error) {
+	var head [2]byte
+	if _, err = io.ReadFull(c.br, head[:]); err != nil {
+		return
+	}
+	op = head[0] & 0x0f
+	length = int64(head[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(c.br, ext[:]); err != nil {
+			return
+		}
+		length = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(c.br, ext[:]); err != nil {
+			return
+		}
+		length = int64(binary.BigEndian.Uint64(ext[:]) & (1<<63 - 1))
+	}
+	if head[1]&0x80 != 0 {
+		mask = make([]byte, 4)
+		_, err = io.ReadFull(c.br, mask)
+	}
+	return
+}
+
+func unmask(p, mask []byte, pos int) {
+	if mask == nil {
+		return
+	}
+	for i := range p {
+		p[i] ^= mask[(pos+i)%4]
+	}
+}
+
+func (c *wsConn) Write(p []byte) (int, error) {
+	if err := c.writeFrame(wsOpBinary, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// writeFrame sends one frame, masked as clients must.
+func (c *wsConn) writeFrame(op byte, payload []byte) error {
+	frame := make([]byte, 0, len(payload)+14)
+	frame = append(frame, 0x80|op)
+	switch n := len(payload); {
+	case n < 126:
+		frame = append(frame, 0x80|byte(n))
+	case n <= 0xffff:
+		frame = append(frame, 0x80|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+	default:
+		frame = append(frame, 0x80|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(n))
+	}
+	mask := make([]byte, 4)
+	rand.Read(mask)
+	frame = append(frame, mask...)
+	start := len(frame)
+	frame = append(frame, payload...)
+	unmask(frame[start:], mask, 0)
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_, err := c.Conn.Write(frame)
+	return err
+}
+
+func (c *wsConn) Close() error {
+	c.writeFrame(wsOpClose, []byte{0x03, 0xe8}) // normal closure
+	return c.Conn.Close()
+}
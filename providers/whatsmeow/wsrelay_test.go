@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	waLog "go.mau.fi/whatsmeow/util/log"
+)
+
+// echoRelay is a WSS relay that records the targets it's asked for and
+// echoes the first frame of each connection back.
+func echoRelay(t *testing.T, token string) (*url.URL, <-chan string) {
+	t.Helper()
+	targets := make(chan string, 16)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		targets <- r.URL.Query().Get("target")
+		conn, brw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		accept := sha1.Sum([]byte(r.Header.Get("Sec-WebSocket-Key") + websocketGUID))
+		fmt.Fprintf(conn, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n",
+			base64.StdEncoding.EncodeToString(accept[:]))
+		// wsConn unmasks what the tunnel sends; servers reply unmasked.
+		buf := make([]byte, 125)
+		n, err := (&wsConn{Conn: conn, br: brw.Reader}).Read(buf)
+		if err != nil {
+			return
+		}
+		conn.Write(append([]byte{0x80 | wsOpBinary, byte(n)}, buf[:n]...))
+		io.Copy(io.Discard, conn)
+	}))
+	t.Cleanup(srv.Close)
+	u, _ := url.Parse("ws://" + srv.Listener.Addr().String() + "/relay")
+	return u, targets
+}
+
+// relayConnect asks the tunnel at addr for a connection to target.
+func relayConnect(t *testing.T, addr, method, target string) (net.Conn, *bufio.Reader, int) {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	fmt.Fprintf(conn, "%s %s HTTP/1.1\r\nHost: %s\r\n\r\n", method, target, target)
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, target, err)
+	}
+	return conn, br, resp.StatusCode
+}
+
+func TestRelayTunnel(t *testing.T) {
+	waLogger = waLog.Noop
+	relay, targets := echoRelay(t, "secret")
+	addr, err := startRelayTunnel(relay, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A WhatsApp host is tunnelled through the relay, both ways.
+	conn, br, status := relayConnect(t, addr, "CONNECT", "g.whatsapp.net:443")
+	if status != http.StatusOK {
+		t.Fatalf("CONNECT g.whatsapp.net:443: %d, want 200", status)
+	}
+	if got := <-targets; got != "g.whatsapp.net:443" {
+		t.Errorf("relay was asked for %q, want g.whatsapp.net:443", got)
+	}
+	io.WriteString(conn, "hello")
+	echo := make([]byte, 5)
+	if _, err := io.ReadFull(br, echo); err != nil || string(echo) != "hello" {
+		t.Errorf("read %q, %v through the tunnel, want the echo of hello", echo, err)
+	}
+
+	// Anything else is refused without reaching the relay.
+	for _, target := range []string{"169.254.169.254:80", "localhost:8080", "evilwhatsapp.net:443", "whatsapp.net.evil.com:443"} {
+		if _, _, status := relayConnect(t, addr, "CONNECT", target); status != http.StatusForbidden {
+			t.Errorf("CONNECT %s: %d, want 403", target, status)
+		}
+	}
+	if _, _, status := relayConnect(t, addr, "GET", "http://g.whatsapp.net/"); status != http.StatusMethodNotAllowed {
+		t.Errorf("GET through the tunnel: %d, want 405", status)
+	}
+	select {
+	case target := <-targets:
+		t.Errorf("relay was asked for %s", target)
+	default:
+	}
+}